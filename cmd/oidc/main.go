@@ -0,0 +1,339 @@
+/*
+Command oidc fetches provider metadata and verifies tokens from the command
+line.
+
+Usage:
+
+	oidc discovery -issuer URL
+	oidc jwks -issuer URL
+	oidc verify -issuer URL -client-id ID [-nonce N] [-access-token T] [-skip-expiry] TOKEN
+	oidc claims TOKEN
+	oidc login -issuer URL -client-id ID [-client-secret S] [-scopes openid,profile]
+
+Tokens are read from standard input if TOKEN is "-" or omitted. Unlike claims,
+which only decodes a token, verify checks the token against the issuer's keys
+and prints the outcome of every check. It exits with status 1 if the token is
+invalid.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+const usage = `usage: oidc <command> [flags]
+
+commands:
+  discovery  print the issuer's discovery document
+  jwks       print the issuer's key set
+  verify     verify a token and print a report of every check
+  claims     print the claims of a token without verifying it
+  login      log in with the device flow and print the tokens
+`
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+var (
+	// errInvalid is returned by commands that ran, but found a token invalid.
+	errInvalid = errors.New("token is invalid")
+	// errUsage is wrapped by errors caused by invalid arguments.
+	errUsage = errors.New("invalid usage")
+)
+
+type command func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	commands := map[string]command{
+		"discovery": discovery,
+		"jwks":      jwks,
+		"verify":    verify,
+		"claims":    claims,
+		"login":     login,
+	}
+	if len(args) == 0 || commands[args[0]] == nil {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	err := commands[args[0]](ctx, args[1:], stdin, stdout, stderr)
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 2
+	case errors.Is(err, errInvalid):
+		return 1
+	default:
+		fmt.Fprintf(stderr, "oidc %s: %v\n", args[0], err)
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		return 1
+	}
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("oidc "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+func requireFlag(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("%w: -%s is required", errUsage, name)
+	}
+	return nil
+}
+
+func discovery(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("discovery", stderr)
+	issuer := fs.String("issuer", "", "issuer URL")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("issuer", *issuer); err != nil {
+		return err
+	}
+	p, err := oidc.NewProvider(ctx, *issuer)
+	if err != nil {
+		return err
+	}
+	var doc json.RawMessage
+	if err := p.Claims(&doc); err != nil {
+		return err
+	}
+	return printJSON(stdout, doc)
+}
+
+func jwks(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("jwks", stderr)
+	issuer := fs.String("issuer", "", "issuer URL")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("issuer", *issuer); err != nil {
+		return err
+	}
+	p, err := oidc.NewProvider(ctx, *issuer)
+	if err != nil {
+		return err
+	}
+	var doc struct {
+		JWKSURL string `json:"jwks_uri"`
+	}
+	if err := p.Claims(&doc); err != nil {
+		return err
+	}
+	if doc.JWKSURL == "" {
+		return errors.New("provider has no jwks_uri")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", doc.JWKSURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return printJSON(stdout, body)
+}
+
+func verify(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("verify", stderr)
+	issuer := fs.String("issuer", "", "issuer URL")
+	clientID := fs.String("client-id", "", "expected audience of the token")
+	nonce := fs.String("nonce", "", "expected nonce of the token")
+	accessToken := fs.String("access-token", "", "access token to check the at_hash claim against")
+	skipExpiry := fs.Bool("skip-expiry", false, "don't check the token's expiry")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("issuer", *issuer); err != nil {
+		return err
+	}
+	raw, err := readToken(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	p, err := oidc.NewProvider(ctx, *issuer)
+	if err != nil {
+		return err
+	}
+	verifier := p.Verifier(&oidc.Config{
+		ClientID:          *clientID,
+		SkipClientIDCheck: *clientID == "",
+		SkipExpiryCheck:   *skipExpiry,
+	})
+	var opts []oidc.VerifyOption
+	if *nonce != "" {
+		opts = append(opts, oidc.WithNonce(*nonce))
+	}
+	if *accessToken != "" {
+		opts = append(opts, oidc.WithAccessToken(*accessToken))
+	}
+	report, err := verifier.Inspect(ctx, raw, opts...)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	for _, c := range report.Checks {
+		result := "pass"
+		switch {
+		case c.Skipped:
+			result = "skip"
+		case c.Err != nil:
+			result = "FAIL"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, result, c.Detail)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout)
+	if err := printJSON(stdout, report.Claims); err != nil {
+		return err
+	}
+	if !report.Valid() {
+		fmt.Fprintln(stderr, "token is INVALID")
+		return errInvalid
+	}
+	return nil
+}
+
+func claims(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("claims", stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	raw, err := readToken(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	t, err := oidc.ParseUnverified(raw)
+	if err != nil {
+		return err
+	}
+	var v json.RawMessage
+	if err := t.Claims(&v); err != nil {
+		return err
+	}
+	fmt.Fprintln(stderr, "warning: claims are not verified, use \"oidc verify\" to check the token")
+	return printJSON(stdout, v)
+}
+
+func login(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("login", stderr)
+	issuer := fs.String("issuer", "", "issuer URL")
+	clientID := fs.String("client-id", "", "client ID")
+	clientSecret := fs.String("client-secret", "", "client secret, if the client is confidential")
+	scopes := fs.String("scopes", "openid,profile,email", "comma separated scopes to request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("issuer", *issuer); err != nil {
+		return err
+	}
+	if err := requireFlag("client-id", *clientID); err != nil {
+		return err
+	}
+	p, err := oidc.NewProvider(ctx, *issuer)
+	if err != nil {
+		return err
+	}
+	if p.Endpoint().DeviceAuthURL == "" {
+		return errors.New("provider doesn't support the device flow")
+	}
+	config := p.OAuth2Config(*clientID, *clientSecret, "", strings.Split(*scopes, ","))
+	da, err := config.DeviceAuth(ctx)
+	if err != nil {
+		return err
+	}
+	if da.VerificationURIComplete != "" {
+		fmt.Fprintf(stderr, "Visit %s to log in.\n", da.VerificationURIComplete)
+	} else {
+		fmt.Fprintf(stderr, "Visit %s and enter the code %s to log in.\n", da.VerificationURI, da.UserCode)
+	}
+	token, err := config.DeviceAccessToken(ctx, da)
+	if err != nil {
+		return err
+	}
+
+	out := map[string]interface{}{
+		"access_token": token.AccessToken,
+		"token_type":   token.TokenType,
+		"expiry":       token.Expiry,
+	}
+	if token.RefreshToken != "" {
+		out["refresh_token"] = token.RefreshToken
+	}
+	if raw, ok := token.Extra("id_token").(string); ok {
+		verifier := p.Verifier(&oidc.Config{ClientID: *clientID})
+		idToken, err := verifier.Verify(ctx, raw, oidc.WithAccessToken(token.AccessToken))
+		if err != nil {
+			return fmt.Errorf("verifying id token: %v", err)
+		}
+		var claims json.RawMessage
+		if err := idToken.Claims(&claims); err != nil {
+			return err
+		}
+		out["id_token"] = raw
+		out["id_token_claims"] = claims
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	return printJSON(stdout, b)
+}
+
+// readToken returns the token of the arguments, or of stdin if there's none
+// or it's "-".
+func readToken(args []string, stdin io.Reader) (string, error) {
+	if len(args) > 1 {
+		return "", fmt.Errorf("%w: expected a single token", errUsage)
+	}
+	if len(args) == 1 && args[0] != "-" {
+		return args[0], nil
+	}
+	b, err := io.ReadAll(io.LimitReader(stdin, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	raw := strings.TrimSpace(string(b))
+	if raw == "" {
+		return "", fmt.Errorf("%w: no token given", errUsage)
+	}
+	return raw, nil
+}
+
+func printJSON(w io.Writer, b []byte) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func TestRun(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":"%s/keys","id_token_signing_alg_values_supported":["ES256"]}`, s.URL, s.URL)
+		case "/keys":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: priv.Public(), KeyID: "k1", Algorithm: "ES256", Use: "sig"}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: priv, KeyID: "k1"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(claims string) string {
+		jws, err := signer.Sign([]byte(claims))
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := jws.CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	valid := sign(fmt.Sprintf(`{"iss":%q,"aud":"app","sub":"jane","exp":4102444800}`, s.URL))
+	expired := sign(fmt.Sprintf(`{"iss":%q,"aud":"other","sub":"jane","exp":1}`, s.URL))
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantStatus int
+		wantOut    []string
+	}{
+		{name: "no command", wantStatus: 2},
+		{name: "discovery", args: []string{"discovery", "-issuer", s.URL}, wantOut: []string{`"jwks_uri": "` + s.URL + `/keys"`}},
+		{name: "discovery without issuer", args: []string{"discovery"}, wantStatus: 2},
+		{name: "jwks", args: []string{"jwks", "-issuer", s.URL}, wantOut: []string{`"kid": "k1"`}},
+		{name: "verify", args: []string{"verify", "-issuer", s.URL, "-client-id", "app", valid}, wantOut: []string{"pass  signed by a key", `"sub": "jane"`}},
+		{
+			name:       "verify invalid",
+			args:       []string{"verify", "-issuer", s.URL, "-client-id", "app", "-"},
+			stdin:      expired + "\n",
+			wantStatus: 1,
+			wantOut:    []string{"pass  signed by a key", "FAIL  oidc: expected audience", "FAIL  oidc: token is expired"},
+		},
+		{name: "claims", args: []string{"claims"}, stdin: valid, wantOut: []string{`"aud": "app"`}},
+		{name: "claims of malformed token", args: []string{"claims", "nope"}, wantStatus: 1},
+		{name: "login without client", args: []string{"login", "-issuer", s.URL}, wantStatus: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			status := run(context.Background(), test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+			if status != test.wantStatus {
+				t.Errorf("got status %d, want %d: %s", status, test.wantStatus, stderr.String())
+			}
+			for _, want := range test.wantOut {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("output doesn't contain %q:\n%s", want, stdout.String())
+				}
+			}
+		})
+	}
+}
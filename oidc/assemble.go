@@ -0,0 +1,220 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// Sources of assembled claims, reported by AssembledClaims.Sources. Claims
+// resolved from distributed or aggregated claim sources are reported with the
+// source's endpoint, or ClaimsFromAggregated.
+const (
+	ClaimsFromIDToken    = "id_token"
+	ClaimsFromUserInfo   = "userinfo"
+	ClaimsFromAggregated = "aggregated"
+)
+
+// protocolClaims describe the authentication event rather than the end user,
+// so they're only ever taken from the ID Token.
+var protocolClaims = map[string]bool{
+	"iss":       true,
+	"sub":       true,
+	"aud":       true,
+	"exp":       true,
+	"iat":       true,
+	"nbf":       true,
+	"auth_time": true,
+	"nonce":     true,
+	"acr":       true,
+	"amr":       true,
+	"azp":       true,
+	"at_hash":   true,
+	"c_hash":    true,
+	"sid":       true,
+	"jti":       true,
+}
+
+// AssembleOption configures Provider.AssembleClaims.
+type AssembleOption func(*assembleOptions)
+
+type assembleOptions struct {
+	resolver     ClaimResolver
+	verifier     TokenVerifier
+	userInfoOpts []UserInfoOption
+}
+
+// WithClaimResolver resolves distributed and aggregated claims with the
+// resolver, such as a DistributedClaimsResolver for claims providers other
+// than the token's issuer.
+func WithClaimResolver(r ClaimResolver) AssembleOption {
+	return func(o *assembleOptions) {
+		o.resolver = r
+	}
+}
+
+// WithClaimSourceVerifier verifies the JWTs of distributed and aggregated claim
+// sources with the verifier, instead of the provider's keys.
+func WithClaimSourceVerifier(v TokenVerifier) AssembleOption {
+	return func(o *assembleOptions) {
+		o.verifier = v
+	}
+}
+
+// WithAssembleUserInfoOptions passes options to the userinfo request, such as
+// WithUserInfoPost.
+func WithAssembleUserInfoOptions(opts ...UserInfoOption) AssembleOption {
+	return func(o *assembleOptions) {
+		o.userInfoOpts = append(o.userInfoOpts, opts...)
+	}
+}
+
+// AssembledClaims are the claims of an end user combined from an ID Token, the
+// userinfo endpoint and distributed and aggregated claim sources.
+type AssembledClaims struct {
+	// Sources maps each claim to where its value was taken from.
+	Sources map[string]string
+
+	claims []byte
+}
+
+// Claims unmarshals the combined claims into the provided object.
+func (a *AssembledClaims) Claims(v interface{}) error {
+	return decodeClaims(a.claims, v, false)
+}
+
+// ClaimsUseNumber is like Claims, except that numbers decoded into interface{}
+// values are json.Number, as for IDToken.ClaimsUseNumber.
+func (a *AssembledClaims) ClaimsUseNumber(v interface{}) error {
+	return decodeClaims(a.claims, v, true)
+}
+
+// AssembleClaims combines the claims about the end user of a verified ID Token
+// with those of the userinfo endpoint and of distributed and aggregated claim
+// sources, into what applications usually treat as "the user's claims".
+// Claims are combined as follows:
+//
+//   - claims of the ID Token are used as is
+//   - claims returned by the userinfo endpoint replace those of the ID Token,
+//     since they're more current, except for claims describing the
+//     authentication, such as "iss", "sub", "aud", "exp", "auth_time", "acr"
+//     and "amr", which are only taken from the ID Token
+//   - distributed and aggregated claims listed by either response are
+//     resolved, and used for claims that neither provides directly
+//
+// The userinfo endpoint is only called if accessToken is set and the provider
+// has one, and its response must be for the subject of the ID Token. Claim
+// sources are verified with the provider's keys unless WithClaimResolver or
+// WithClaimSourceVerifier is given. Any failure fails the call, rather than
+// returning claims some of which may be missing.
+//
+//	claims, err := provider.AssembleClaims(ctx, idToken, oauth2Token.AccessToken)
+//	if err != nil {
+//		// handle error
+//	}
+//	var user struct {
+//		Email  string   `json:"email"`
+//		Groups []string `json:"groups"`
+//	}
+//	if err := claims.Claims(&user); err != nil {
+//		// handle error
+//	}
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#ClaimTypes
+func (p *Provider) AssembleClaims(ctx context.Context, idToken *IDToken, accessToken string, opts ...AssembleOption) (*AssembledClaims, error) {
+	var o assembleOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	values := make(map[string]json.RawMessage)
+	sources := make(map[string]string)
+	var distributed []map[string]claimSource
+
+	rangeClaims(idToken.claims, func(name string, value json.RawMessage) bool {
+		values[name], sources[name] = value, ClaimsFromIDToken
+		return true
+	})
+	distributed = append(distributed, idToken.distributedClaims)
+
+	if accessToken != "" && p.userInfoURL != "" {
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})
+		userInfo, err := p.UserInfo(ctx, ts, append(o.userInfoOpts, WithIDToken(idToken))...)
+		if err != nil {
+			return nil, err
+		}
+		rangeClaims(userInfo.claims, func(name string, value json.RawMessage) bool {
+			if !protocolClaims[name] {
+				values[name], sources[name] = value, ClaimsFromUserInfo
+			}
+			return true
+		})
+		var raw struct {
+			ClaimNames   map[string]string      `json:"_claim_names"`
+			ClaimSources map[string]claimSource `json:"_claim_sources"`
+		}
+		if err := json.Unmarshal(userInfo.claims, &raw); err != nil {
+			return nil, fmt.Errorf("oidc: failed to decode userinfo: %v", err)
+		}
+		d, err := distributedClaims(raw.ClaimNames, raw.ClaimSources)
+		if err != nil {
+			return nil, err
+		}
+		distributed = append(distributed, d)
+	}
+	delete(values, "_claim_names")
+	delete(values, "_claim_sources")
+	delete(sources, "_claim_names")
+	delete(sources, "_claim_sources")
+
+	for _, d := range distributed {
+		for name, src := range d {
+			if _, ok := values[name]; ok || protocolClaims[name] {
+				continue
+			}
+			value, err := p.resolveClaim(ctx, &o, name, src)
+			if err != nil {
+				return nil, fmt.Errorf("oidc: resolving claim %q: %w", name, err)
+			}
+			values[name] = value
+			if src.JWT != "" {
+				sources[name] = ClaimsFromAggregated
+			} else {
+				sources[name] = src.Endpoint
+			}
+		}
+	}
+
+	claims, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: encoding claims: %v", err)
+	}
+	return &AssembledClaims{Sources: sources, claims: claims}, nil
+}
+
+// resolveClaim returns the value of a distributed or aggregated claim.
+func (p *Provider) resolveClaim(ctx context.Context, o *assembleOptions, name string, src claimSource) (json.RawMessage, error) {
+	if o.resolver != nil {
+		return o.resolver.ResolveClaim(ctx, name, ClaimSource(src))
+	}
+	verifier := o.verifier
+	if verifier == nil {
+		verifier = p.Verifier(&Config{SkipClientIDCheck: true})
+	}
+	body, err := fetchClaimSource(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	token, err := verifier.Verify(ctx, string(body))
+	if err != nil {
+		return nil, err
+	}
+	value, ok := token.ClaimValue(name)
+	if !ok {
+		return nil, errors.New("claim source didn't return the claim")
+	}
+	return value, nil
+}
@@ -0,0 +1,108 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func TestAssembleClaims(t *testing.T) {
+	key := newRSAKey(t)
+	groups := key.sign(t, []byte(`{"iss":"https://foo","sub":"jane","exp":4102444800,"groups":["admins"]}`))
+	roles := key.sign(t, []byte(`{"iss":"https://foo","sub":"jane","exp":4102444800,"roles":["editor"]}`))
+
+	userInfo := `{"sub":"jane","email":"jane@example.org","iss":"https://evil","_claim_names":{"roles":"r"},"_claim_sources":{"r":{"JWT":%q}}}`
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/keys":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}})
+		case "/userinfo":
+			w.Header().Set("Content-Type", "application/json")
+			if r.Header.Get("Authorization") == "Bearer other" {
+				fmt.Fprint(w, `{"sub":"john"}`)
+				return
+			}
+			fmt.Fprintf(w, userInfo, roles)
+		case "/groups":
+			if r.Header.Get("Authorization") != "Bearer src" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, groups)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	ctx := context.Background()
+	p := (&ProviderConfig{
+		IssuerURL:   "https://foo",
+		UserInfoURL: s.URL + "/userinfo",
+		JWKSURL:     s.URL + "/keys",
+		Algorithms:  []string{RS256},
+	}).NewProvider(ctx)
+	idToken, err := p.Verifier(&Config{ClientID: "app"}).Verify(ctx, key.sign(t, []byte(fmt.Sprintf(
+		`{"iss":"https://foo","aud":"app","sub":"jane","exp":4102444800,"email":"jane@example.com","name":"Jane",`+
+			`"_claim_names":{"groups":"g"},"_claim_sources":{"g":{"endpoint":%q,"access_token":"src"}}}`, s.URL+"/groups"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := p.AssembleClaims(ctx, idToken, "at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Issuer string      `json:"iss"`
+		Email  string      `json:"email"`
+		Name   string      `json:"name"`
+		Groups []string    `json:"groups"`
+		Roles  []string    `json:"roles"`
+		Names  interface{} `json:"_claim_names"`
+	}
+	if err := claims.Claims(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Issuer != "https://foo" || got.Email != "jane@example.org" || got.Name != "Jane" ||
+		!reflect.DeepEqual(got.Groups, []string{"admins"}) || !reflect.DeepEqual(got.Roles, []string{"editor"}) || got.Names != nil {
+		t.Errorf("unexpected claims %+v", got)
+	}
+	wantSources := map[string]string{
+		"iss":    ClaimsFromIDToken,
+		"email":  ClaimsFromUserInfo,
+		"name":   ClaimsFromIDToken,
+		"groups": s.URL + "/groups",
+		"roles":  ClaimsFromAggregated,
+	}
+	for name, want := range wantSources {
+		if claims.Sources[name] != want {
+			t.Errorf("claim %s: got source %q, want %q", name, claims.Sources[name], want)
+		}
+	}
+
+	// Without an access token, userinfo isn't called.
+	claims, err = p.AssembleClaims(ctx, idToken, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := claims.Sources["roles"]; ok || claims.Sources["email"] != ClaimsFromIDToken {
+		t.Errorf("unexpected sources %v", claims.Sources)
+	}
+
+	if _, err := p.AssembleClaims(ctx, idToken, "other"); err == nil {
+		t.Error("expected error for userinfo of another subject")
+	}
+	other := NewVerifier("https://foo", &StaticKeySet{}, &Config{SkipClientIDCheck: true})
+	if _, err := p.AssembleClaims(ctx, idToken, "", WithClaimSourceVerifier(other)); err == nil || !strings.Contains(err.Error(), `"groups"`) {
+		t.Errorf("expected error resolving groups, got %v", err)
+	}
+}
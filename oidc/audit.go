@@ -0,0 +1,174 @@
+package oidc
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Outcomes of verifications, reported by AuditEvent.Outcome.
+const (
+	AuditAccepted = "accepted"
+	AuditRejected = "rejected"
+)
+
+// Classes of verification errors, reported by AuditEvent.ErrorClass.
+const (
+	ErrorClassExpired             = "expired"
+	ErrorClassInvalidIssuer       = "invalid_issuer"
+	ErrorClassInvalidAudience     = "invalid_audience"
+	ErrorClassMalformed           = "malformed"
+	ErrorClassSignature           = "signature"
+	ErrorClassProviderUnavailable = "provider_unavailable"
+	ErrorClassOther               = "other"
+)
+
+// AuditEvent records an identity decision made by IDTokenVerifier.Verify, for
+// security information and event management (SIEM) systems.
+type AuditEvent struct {
+	Time time.Time
+	// Outcome is AuditAccepted or AuditRejected.
+	Outcome string
+	// Verifier is the issuer expected by the verifier.
+	Verifier string
+	// Issuer, Subject and Client identify the token: its "iss" and "sub"
+	// claims, and its "azp" claim or, if it has a single audience, its
+	// audience. For rejected tokens they're taken from the unverified token
+	// and can't be trusted, but show what the token claimed to be.
+	Issuer  string
+	Subject string
+	Client  string
+	// KeyID and Algorithm are taken from the token's header.
+	KeyID     string
+	Algorithm string
+	// Cached reports whether the token was found in the VerificationCache.
+	Cached bool
+	// ErrorClass and Err describe why the token was rejected. ErrorClass is
+	// one of the ErrorClass constants.
+	ErrorClass string
+	Err        error
+}
+
+// AuditSink receives the audit events of verifiers. Events are sent
+// synchronously, possibly from several goroutines at once, so implementations
+// should be fast and safe for concurrent use, for example by queueing events
+// for a background writer.
+type AuditSink interface {
+	Audit(AuditEvent)
+}
+
+// AuditSinkFunc adapts a function to an AuditSink.
+//
+//	config := &oidc.Config{
+//		ClientID: clientID,
+//		AuditSink: oidc.AuditSinkFunc(func(e oidc.AuditEvent) {
+//			slog.Info("token "+e.Outcome, "sub", e.Subject, "iss", e.Issuer, "error", e.ErrorClass)
+//		}),
+//	}
+type AuditSinkFunc func(AuditEvent)
+
+// Audit calls f(e).
+func (f AuditSinkFunc) Audit(e AuditEvent) {
+	f(e)
+}
+
+// SampleAudit returns an AuditSink forwarding a random sample of events to
+// sink, for gateways verifying too many tokens to record every decision. The
+// rates are the fractions of accepted and rejected tokens to keep, between 0
+// and 1. Rejections are usually all kept:
+//
+//	sink := oidc.SampleAudit(siem, 0.01, 1)
+func SampleAudit(sink AuditSink, acceptedRate, rejectedRate float64) AuditSink {
+	return &sampledSink{sink: sink, accepted: acceptedRate, rejected: rejectedRate}
+}
+
+type sampledSink struct {
+	sink               AuditSink
+	accepted, rejected float64
+}
+
+func (s *sampledSink) Audit(e AuditEvent) {
+	rate := s.accepted
+	if e.Outcome == AuditRejected {
+		rate = s.rejected
+	}
+	if rate >= 1 || (rate > 0 && rand.Float64() < rate) {
+		s.sink.Audit(e)
+	}
+}
+
+// errorClass returns the ErrorClass constant describing a verification error.
+func errorClass(err error) string {
+	var (
+		unavailable *ProviderUnavailableError
+		expired     *TokenExpiredError
+		issuer      *InvalidIssuerError
+		untrusted   *UntrustedIssuerError
+		audience    *InvalidAudienceError
+		malformed   *MalformedTokenError
+		signature   *signatureError
+	)
+	switch {
+	case errors.As(err, &unavailable):
+		return ErrorClassProviderUnavailable
+	case errors.As(err, &expired):
+		return ErrorClassExpired
+	case errors.As(err, &issuer), errors.As(err, &untrusted):
+		return ErrorClassInvalidIssuer
+	case errors.As(err, &audience):
+		return ErrorClassInvalidAudience
+	case errors.As(err, &malformed):
+		return ErrorClassMalformed
+	case errors.As(err, &signature):
+		return ErrorClassSignature
+	default:
+		return ErrorClassOther
+	}
+}
+
+// signatureError is returned when the signature of a token can't be verified.
+type signatureError struct {
+	err error
+}
+
+func (e *signatureError) Error() string {
+	return "failed to verify signature: " + e.err.Error()
+}
+
+func (e *signatureError) Unwrap() error {
+	return e.err
+}
+
+// audit sends the audit event of a call to Verify to the config's sink.
+func (v *IDTokenVerifier) audit(t *IDToken, u *UnverifiedIDToken, cached bool, err error) {
+	e := AuditEvent{
+		Time:     v.config.now(),
+		Outcome:  AuditAccepted,
+		Verifier: v.issuer,
+		Cached:   cached,
+		Err:      err,
+	}
+	var aud []string
+	switch {
+	case t != nil:
+		e.Issuer, e.Subject, e.Client = t.Issuer, t.Subject, t.authorizedParty
+		e.KeyID, e.Algorithm = t.header.KeyID, t.sigAlgorithm
+		aud = t.Audience
+	case u != nil:
+		e.Issuer, e.Subject = u.Issuer, u.Subject
+		e.KeyID, e.Algorithm = u.KeyID, u.Algorithm
+		var azp struct {
+			AuthorizedParty string `json:"azp"`
+		}
+		u.Claims(&azp)
+		e.Client = azp.AuthorizedParty
+		aud = u.Audience
+	}
+	if e.Client == "" && len(aud) == 1 {
+		e.Client = aud[0]
+	}
+	if err != nil {
+		e.Outcome, e.ErrorClass = AuditRejected, errorClass(err)
+	}
+	v.config.AuditSink.Audit(e)
+}
@@ -0,0 +1,78 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"testing"
+	"time"
+)
+
+func TestAuditSink(t *testing.T) {
+	key, other := newRSAKey(t), newRSAKey(t)
+	key.keyID = "key-1"
+	ctx := context.Background()
+
+	var events []AuditEvent
+	v := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		ClientID:  "api",
+		Now:       func() time.Time { return time.Unix(1700000000, 0) },
+		AuditSink: AuditSinkFunc(func(e AuditEvent) { events = append(events, e) }),
+	})
+
+	tests := []struct {
+		name    string
+		token   string
+		outcome string
+		class   string
+		client  string
+	}{
+		{"accepted", key.sign(t, []byte(`{"iss":"https://foo","aud":"api","sub":"jane","exp":4102444800}`)), AuditAccepted, "", "api"},
+		{"expired", key.sign(t, []byte(`{"iss":"https://foo","aud":"api","sub":"jane","exp":1600000000}`)), AuditRejected, ErrorClassExpired, "api"},
+		{"wrong issuer", key.sign(t, []byte(`{"iss":"https://bar","aud":"api","sub":"jane","exp":4102444800}`)), AuditRejected, ErrorClassInvalidIssuer, "api"},
+		{"wrong audience", key.sign(t, []byte(`{"iss":"https://foo","aud":["web","cli"],"azp":"web","sub":"jane","exp":4102444800}`)), AuditRejected, ErrorClassInvalidAudience, "web"},
+		{"bad signature", other.sign(t, []byte(`{"iss":"https://foo","aud":"api","sub":"jane","exp":4102444800}`)), AuditRejected, ErrorClassSignature, "api"},
+		{"malformed", "not-a-token", AuditRejected, ErrorClassMalformed, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events = nil
+			v.Verify(ctx, test.token)
+			if len(events) != 1 {
+				t.Fatalf("got %d audit events, want 1", len(events))
+			}
+			e := events[0]
+			if e.Outcome != test.outcome || e.ErrorClass != test.class || e.Client != test.client {
+				t.Errorf("got outcome %q, class %q, client %q, want %q, %q, %q", e.Outcome, e.ErrorClass, e.Client, test.outcome, test.class, test.client)
+			}
+			if e.Verifier != "https://foo" || !e.Time.Equal(time.Unix(1700000000, 0)) {
+				t.Errorf("unexpected verifier or time: %+v", e)
+			}
+			if test.class != ErrorClassMalformed && (e.Subject != "jane" || e.Algorithm != RS256) {
+				t.Errorf("expected subject and algorithm of the token, got %+v", e)
+			}
+		})
+	}
+	events = nil
+	v.Verify(ctx, tests[0].token)
+	if events[0].KeyID != "key-1" {
+		t.Errorf("got key id %q, want key-1", events[0].KeyID)
+	}
+}
+
+func TestSampleAudit(t *testing.T) {
+	var accepted, rejected int
+	sink := SampleAudit(AuditSinkFunc(func(e AuditEvent) {
+		if e.Outcome == AuditAccepted {
+			accepted++
+		} else {
+			rejected++
+		}
+	}), 0, 1)
+	for i := 0; i < 100; i++ {
+		sink.Audit(AuditEvent{Outcome: AuditAccepted})
+		sink.Audit(AuditEvent{Outcome: AuditRejected})
+	}
+	if accepted != 0 || rejected != 100 {
+		t.Errorf("got %d accepted and %d rejected events, want 0 and 100", accepted, rejected)
+	}
+}
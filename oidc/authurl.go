@@ -0,0 +1,274 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// Prompt values for the authorization request's prompt parameter.
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+type Prompt string
+
+const (
+	PromptNone          Prompt = "none"
+	PromptLogin         Prompt = "login"
+	PromptConsent       Prompt = "consent"
+	PromptSelectAccount Prompt = "select_account"
+	// PromptCreate asks the provider to show a sign up page. Defined by
+	// OpenID Connect Prompt Create 1.0.
+	PromptCreate Prompt = "create"
+)
+
+// Display values for the authorization request's display parameter.
+type Display string
+
+const (
+	DisplayPage  Display = "page"
+	DisplayPopup Display = "popup"
+	DisplayTouch Display = "touch"
+	DisplayWap   Display = "wap"
+)
+
+// ResponseMode values for the authorization request's response_mode parameter.
+type ResponseMode string
+
+const (
+	ResponseModeQuery    ResponseMode = "query"
+	ResponseModeFragment ResponseMode = "fragment"
+	ResponseModeFormPost ResponseMode = "form_post"
+)
+
+// providerMetadata holds discovery values used to validate requests.
+type providerMetadata struct {
+	PromptValuesSupported    []string `json:"prompt_values_supported"`
+	DisplayValuesSupported   []string `json:"display_values_supported"`
+	ResponseModesSupported   []string `json:"response_modes_supported"`
+	ACRValuesSupported       []string `json:"acr_values_supported"`
+	ClaimsParameterSupported bool     `json:"claims_parameter_supported"`
+}
+
+// metadata decodes validation related values from the discovery document. It
+// returns nil if the provider wasn't created through discovery.
+func (p *Provider) metadata() (*providerMetadata, error) {
+	if p.rawClaims == nil {
+		return nil, nil
+	}
+	var m providerMetadata
+	if err := json.Unmarshal(p.rawClaims, &m); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode provider metadata: %v", err)
+	}
+	return &m, nil
+}
+
+// AuthURLBuilder assembles authorization requests with OpenID Connect parameters.
+// Values are validated against the provider's discovery document, where the
+// provider advertises the values it supports.
+//
+//	authURL, err := provider.AuthURLBuilder(&oauth2Config).
+//		Nonce(nonce).
+//		Prompt(oidc.PromptLogin, oidc.PromptConsent).
+//		LoginHint("jane@example.com").
+//		AuthCodeURL(state)
+//
+// Builders aren't safe for concurrent use.
+type AuthURLBuilder struct {
+	provider *Provider
+	config   *oauth2.Config
+
+	opts []oauth2.AuthCodeOption
+	errs []error
+
+	prompts      []Prompt
+	display      Display
+	responseMode ResponseMode
+	acrValues    []string
+	uiLocales    []string
+	resources    []string
+	claims       bool
+}
+
+// AuthURLBuilder returns a builder for authorization requests using the provided
+// OAuth2 configuration.
+func (p *Provider) AuthURLBuilder(config *oauth2.Config) *AuthURLBuilder {
+	return &AuthURLBuilder{provider: p, config: config}
+}
+
+func (b *AuthURLBuilder) param(key, value string) *AuthURLBuilder {
+	b.opts = append(b.opts, oauth2.SetAuthURLParam(key, value))
+	return b
+}
+
+// Nonce sets the nonce parameter, which the provider includes in the ID Token.
+func (b *AuthURLBuilder) Nonce(nonce string) *AuthURLBuilder {
+	return b.param("nonce", nonce)
+}
+
+// Prompt sets the prompt parameter. PromptNone can't be combined with other
+// values.
+func (b *AuthURLBuilder) Prompt(prompts ...Prompt) *AuthURLBuilder {
+	b.prompts = append(b.prompts, prompts...)
+	return b
+}
+
+// Display sets how the provider displays the authentication and consent pages.
+func (b *AuthURLBuilder) Display(display Display) *AuthURLBuilder {
+	b.display = display
+	return b
+}
+
+// ResponseMode sets the mechanism used to return parameters from the
+// authorization endpoint.
+func (b *AuthURLBuilder) ResponseMode(mode ResponseMode) *AuthURLBuilder {
+	b.responseMode = mode
+	return b
+}
+
+// LoginHint sets a hint about the identifier the user may use to log in, such as
+// an email address.
+func (b *AuthURLBuilder) LoginHint(hint string) *AuthURLBuilder {
+	return b.param("login_hint", hint)
+}
+
+// IDTokenHint passes a previously issued ID Token as a hint about the user's
+// current session.
+func (b *AuthURLBuilder) IDTokenHint(rawIDToken string) *AuthURLBuilder {
+	return b.param("id_token_hint", rawIDToken)
+}
+
+// ACRValues requests authentication context class references, in order of
+// preference.
+func (b *AuthURLBuilder) ACRValues(values ...string) *AuthURLBuilder {
+	b.acrValues = append(b.acrValues, values...)
+	return b
+}
+
+// UILocales sets the user's preferred languages for the user interface, as BCP47
+// language tags in order of preference.
+func (b *AuthURLBuilder) UILocales(locales ...string) *AuthURLBuilder {
+	b.uiLocales = append(b.uiLocales, locales...)
+	return b
+}
+
+// Claims sets the claims request parameter. The value is serialized as JSON.
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#ClaimsParameter
+func (b *AuthURLBuilder) Claims(claims interface{}) *AuthURLBuilder {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		b.errs = append(b.errs, fmt.Errorf("oidc: failed to encode claims parameter: %v", err))
+		return b
+	}
+	b.claims = true
+	return b.param("claims", string(data))
+}
+
+// Resource sets the resource indicator for the requested access token. It may be
+// called more than once to request multiple resources, though only AuthCodeURL
+// can encode more than one.
+//
+// See: https://www.rfc-editor.org/rfc/rfc8707
+func (b *AuthURLBuilder) Resource(resource string) *AuthURLBuilder {
+	b.resources = append(b.resources, resource)
+	return b
+}
+
+// Param sets an arbitrary parameter that doesn't have a typed setter, such as
+// provider specific extensions.
+func (b *AuthURLBuilder) Param(key, value string) *AuthURLBuilder {
+	return b.param(key, value)
+}
+
+// Options validates the request and returns it as options for
+// oauth2.Config.AuthCodeURL, for callers that need to add their own options.
+func (b *AuthURLBuilder) Options() ([]oauth2.AuthCodeOption, error) {
+	if len(b.resources) > 1 {
+		return nil, errors.New("oidc: multiple resource parameters can't be expressed as options, use AuthCodeURL")
+	}
+	return b.options()
+}
+
+func (b *AuthURLBuilder) options() ([]oauth2.AuthCodeOption, error) {
+	if len(b.errs) > 0 {
+		return nil, b.errs[0]
+	}
+	m, err := b.provider.metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := append([]oauth2.AuthCodeOption(nil), b.opts...)
+	if len(b.prompts) > 0 {
+		values := make([]string, len(b.prompts))
+		for i, p := range b.prompts {
+			if p == PromptNone && len(b.prompts) > 1 {
+				return nil, errors.New("oidc: prompt \"none\" can't be combined with other values")
+			}
+			if m != nil && p != PromptNone && len(m.PromptValuesSupported) > 0 && !contains(m.PromptValuesSupported, string(p)) {
+				return nil, fmt.Errorf("oidc: provider doesn't support prompt %q", p)
+			}
+			values[i] = string(p)
+		}
+		opts = append(opts, oauth2.SetAuthURLParam("prompt", strings.Join(values, " ")))
+	}
+	if b.display != "" {
+		if m != nil && len(m.DisplayValuesSupported) > 0 && !contains(m.DisplayValuesSupported, string(b.display)) {
+			return nil, fmt.Errorf("oidc: provider doesn't support display %q", b.display)
+		}
+		opts = append(opts, oauth2.SetAuthURLParam("display", string(b.display)))
+	}
+	if b.responseMode != "" {
+		if m != nil && len(m.ResponseModesSupported) > 0 && !contains(m.ResponseModesSupported, string(b.responseMode)) {
+			return nil, fmt.Errorf("oidc: provider doesn't support response mode %q", b.responseMode)
+		}
+		opts = append(opts, oauth2.SetAuthURLParam("response_mode", string(b.responseMode)))
+	}
+	if len(b.acrValues) > 0 {
+		if m != nil && len(m.ACRValuesSupported) > 0 {
+			for _, v := range b.acrValues {
+				if !contains(m.ACRValuesSupported, v) {
+					return nil, fmt.Errorf("oidc: provider doesn't support acr value %q", v)
+				}
+			}
+		}
+		opts = append(opts, oauth2.SetAuthURLParam("acr_values", strings.Join(b.acrValues, " ")))
+	}
+	if len(b.uiLocales) > 0 {
+		// Unsupported locales aren't an error, providers fall back to the
+		// next preferred locale.
+		opts = append(opts, oauth2.SetAuthURLParam("ui_locales", strings.Join(b.uiLocales, " ")))
+	}
+	if b.claims && m != nil && !m.ClaimsParameterSupported {
+		return nil, errors.New("oidc: provider doesn't support the claims parameter")
+	}
+	if len(b.resources) > 0 {
+		opts = append(opts, oauth2.SetAuthURLParam("resource", b.resources[0]))
+	}
+	return opts, nil
+}
+
+// AuthCodeURL validates the request and returns the URL of the provider's
+// authorization endpoint.
+func (b *AuthURLBuilder) AuthCodeURL(state string) (string, error) {
+	opts, err := b.options()
+	if err != nil {
+		return "", err
+	}
+	authURL := b.config.AuthCodeURL(state, opts...)
+	if len(b.resources) < 2 {
+		return authURL, nil
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to parse authorization url: %v", err)
+	}
+	q := u.Query()
+	q["resource"] = b.resources
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
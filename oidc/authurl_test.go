@@ -0,0 +1,166 @@
+package oidc
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestAuthURLBuilder(t *testing.T) {
+	metadata := `{
+		"prompt_values_supported": ["none", "login", "consent"],
+		"display_values_supported": ["page", "popup"],
+		"response_modes_supported": ["query", "form_post"],
+		"acr_values_supported": ["urn:mace:incommon:iap:silver"],
+		"claims_parameter_supported": true
+	}`
+	newProvider := func(rawClaims string) *Provider {
+		p := &Provider{authURL: "https://example.com/auth"}
+		if rawClaims != "" {
+			p.rawClaims = []byte(rawClaims)
+		}
+		return p
+	}
+	config := &oauth2.Config{
+		ClientID:    "client",
+		RedirectURL: "https://rp.example.com/callback",
+		Scopes:      []string{ScopeOpenID},
+	}
+
+	tests := []struct {
+		name      string
+		rawClaims string
+		build     func(b *AuthURLBuilder) *AuthURLBuilder
+		want      url.Values
+		wantErr   string
+	}{
+		{
+			name:      "all parameters",
+			rawClaims: metadata,
+			build: func(b *AuthURLBuilder) *AuthURLBuilder {
+				return b.Nonce("n").
+					Prompt(PromptLogin, PromptConsent).
+					Display(DisplayPopup).
+					ResponseMode(ResponseModeFormPost).
+					LoginHint("jane@example.com").
+					IDTokenHint("hint").
+					ACRValues("urn:mace:incommon:iap:silver").
+					UILocales("fr-CA", "en").
+					Claims(map[string]interface{}{"userinfo": map[string]interface{}{"email": nil}}).
+					Resource("https://api.example.com").
+					Resource("https://other.example.com").
+					Param("foo", "bar")
+			},
+			want: url.Values{
+				"nonce":         {"n"},
+				"prompt":        {"login consent"},
+				"display":       {"popup"},
+				"response_mode": {"form_post"},
+				"login_hint":    {"jane@example.com"},
+				"id_token_hint": {"hint"},
+				"acr_values":    {"urn:mace:incommon:iap:silver"},
+				"ui_locales":    {"fr-CA en"},
+				"claims":        {`{"userinfo":{"email":null}}`},
+				"resource":      {"https://api.example.com", "https://other.example.com"},
+				"foo":           {"bar"},
+			},
+		},
+		{
+			name: "no metadata",
+			build: func(b *AuthURLBuilder) *AuthURLBuilder {
+				return b.Prompt(PromptSelectAccount).Display(DisplayWap).Claims(struct{}{})
+			},
+			want: url.Values{
+				"prompt":  {"select_account"},
+				"display": {"wap"},
+				"claims":  {"{}"},
+			},
+		},
+		{
+			name:      "prompt none combined",
+			rawClaims: metadata,
+			build: func(b *AuthURLBuilder) *AuthURLBuilder {
+				return b.Prompt(PromptNone, PromptLogin)
+			},
+			wantErr: `prompt "none" can't be combined`,
+		},
+		{
+			name:      "unsupported prompt",
+			rawClaims: metadata,
+			build: func(b *AuthURLBuilder) *AuthURLBuilder {
+				return b.Prompt(PromptSelectAccount)
+			},
+			wantErr: `doesn't support prompt "select_account"`,
+		},
+		{
+			name:      "unsupported display",
+			rawClaims: metadata,
+			build: func(b *AuthURLBuilder) *AuthURLBuilder {
+				return b.Display(DisplayTouch)
+			},
+			wantErr: `doesn't support display "touch"`,
+		},
+		{
+			name:      "unsupported response mode",
+			rawClaims: metadata,
+			build: func(b *AuthURLBuilder) *AuthURLBuilder {
+				return b.ResponseMode(ResponseModeFragment)
+			},
+			wantErr: `doesn't support response mode "fragment"`,
+		},
+		{
+			name:      "unsupported acr value",
+			rawClaims: metadata,
+			build: func(b *AuthURLBuilder) *AuthURLBuilder {
+				return b.ACRValues("urn:other")
+			},
+			wantErr: `doesn't support acr value "urn:other"`,
+		},
+		{
+			name:      "claims parameter unsupported",
+			rawClaims: `{}`,
+			build: func(b *AuthURLBuilder) *AuthURLBuilder {
+				return b.Claims(map[string]interface{}{})
+			},
+			wantErr: "doesn't support the claims parameter",
+		},
+		{
+			name: "unencodable claims",
+			build: func(b *AuthURLBuilder) *AuthURLBuilder {
+				return b.Claims(func() {})
+			},
+			wantErr: "failed to encode claims parameter",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newProvider(test.rawClaims)
+			authURL, err := test.build(p.AuthURLBuilder(config)).AuthCodeURL("state")
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("building url: %v", err)
+			}
+			u, err := url.Parse(authURL)
+			if err != nil {
+				t.Fatalf("parsing url: %v", err)
+			}
+			got := u.Query()
+			for _, key := range []string{"client_id", "redirect_uri", "response_type", "scope", "state"} {
+				if got.Get(key) == "" {
+					t.Errorf("expected %s to be set", key)
+				}
+				got.Del(key)
+			}
+			if got.Encode() != test.want.Encode() {
+				t.Errorf("unexpected parameters, got=%s, want=%s", got.Encode(), test.want.Encode())
+			}
+		})
+	}
+}
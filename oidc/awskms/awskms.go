@@ -0,0 +1,128 @@
+// Package awskms signs client assertions and DPoP proofs with asymmetric keys
+// held by AWS Key Management Service, so the private key never enters the
+// process. It's a separate module so the core module doesn't depend on the AWS
+// SDK.
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	if err != nil {
+//		// handle error
+//	}
+//	signer, err := awskms.NewSigner(ctx, kms.NewFromConfig(cfg), "alias/oidc-client", oidc.ES256, "key-1")
+//	if err != nil {
+//		// handle error
+//	}
+//	assertion, err := oidc.ClientAssertion(ctx, signer, clientID, provider.Endpoint().TokenURL, 0)
+//
+// The KMS key must have the SIGN_VERIFY key usage, and the caller needs the
+// kms:GetPublicKey and kms:Sign permissions.
+package awskms
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// Client is the subset of the KMS API used by signers. *kms.Client implements
+// it.
+type Client interface {
+	GetPublicKey(ctx context.Context, in *kms.GetPublicKeyInput, opts ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error)
+	Sign(ctx context.Context, in *kms.SignInput, opts ...func(*kms.Options)) (*kms.SignOutput, error)
+}
+
+// NewSigner returns a signer using the KMS key, identified by its key ID, ARN
+// or alias. The JWS algorithm alg must match the key spec, such as ES256 for
+// ECC_NIST_P256 keys, and be one of the key's signing algorithms. keyID is the
+// "kid" header of signed tokens, which may differ from the KMS key ID.
+func NewSigner(ctx context.Context, client Client, kmsKeyID, alg, keyID string) (oidc.Signer, error) {
+	out, err := client.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: aws.String(kmsKeyID)})
+	if err != nil {
+		return nil, fmt.Errorf("awskms: get public key: %w", err)
+	}
+	pub, err := x509.ParsePKIXPublicKey(out.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("awskms: parse public key: %v", err)
+	}
+	a, ok := algorithms[alg]
+	if !ok {
+		return nil, fmt.Errorf("awskms: unsupported algorithm %q", alg)
+	}
+	if !hasAlgorithm(out.SigningAlgorithms, a.spec) {
+		return nil, fmt.Errorf("awskms: key %s doesn't support %s", kmsKeyID, a.spec)
+	}
+	return oidc.NewCryptoSigner(&key{client: client, id: kmsKeyID, pub: pub, algorithm: a}, alg, keyID)
+}
+
+// algorithm is a KMS signing algorithm and the signer options that request it.
+type algorithm struct {
+	spec types.SigningAlgorithmSpec
+	hash crypto.Hash
+	pss  bool
+}
+
+// algorithms maps JWS algorithms to KMS signing algorithms.
+var algorithms = map[string]algorithm{
+	oidc.RS256: {types.SigningAlgorithmSpecRsassaPkcs1V15Sha256, crypto.SHA256, false},
+	oidc.RS384: {types.SigningAlgorithmSpecRsassaPkcs1V15Sha384, crypto.SHA384, false},
+	oidc.RS512: {types.SigningAlgorithmSpecRsassaPkcs1V15Sha512, crypto.SHA512, false},
+	oidc.PS256: {types.SigningAlgorithmSpecRsassaPssSha256, crypto.SHA256, true},
+	oidc.PS384: {types.SigningAlgorithmSpecRsassaPssSha384, crypto.SHA384, true},
+	oidc.PS512: {types.SigningAlgorithmSpecRsassaPssSha512, crypto.SHA512, true},
+	oidc.ES256: {types.SigningAlgorithmSpecEcdsaSha256, crypto.SHA256, false},
+	oidc.ES384: {types.SigningAlgorithmSpecEcdsaSha384, crypto.SHA384, false},
+	oidc.ES512: {types.SigningAlgorithmSpecEcdsaSha512, crypto.SHA512, false},
+	oidc.EdDSA: {types.SigningAlgorithmSpecEd25519Sha512, 0, false},
+}
+
+func hasAlgorithm(specs []types.SigningAlgorithmSpec, spec types.SigningAlgorithmSpec) bool {
+	for _, s := range specs {
+		if s == spec {
+			return true
+		}
+	}
+	return false
+}
+
+// key is an oidc.ContextSigner signing with a KMS key.
+type key struct {
+	client Client
+	id     string
+	pub    crypto.PublicKey
+	algorithm
+}
+
+func (k *key) Public() crypto.PublicKey { return k.pub }
+
+func (k *key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(context.Background(), digest, opts)
+}
+
+// SignContext signs the digest, or the message for Ed25519 keys. KMS returns
+// ECDSA signatures ASN.1 encoded, as crypto.Signer requires.
+func (k *key) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, pss := opts.(*rsa.PSSOptions); opts.HashFunc() != k.hash || pss != k.pss {
+		return nil, fmt.Errorf("awskms: key %s only signs with %s", k.id, k.spec)
+	}
+	messageType := types.MessageTypeDigest
+	if k.hash == 0 {
+		messageType = types.MessageTypeRaw
+	}
+	out, err := k.client.Sign(ctx, &kms.SignInput{
+		KeyId:            aws.String(k.id),
+		Message:          digest,
+		MessageType:      messageType,
+		SigningAlgorithm: k.spec,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("awskms: sign: %w", err)
+	}
+	return out.Signature, nil
+}
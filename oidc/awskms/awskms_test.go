@@ -0,0 +1,120 @@
+package awskms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// fakeKMS signs with local keys the way KMS does.
+type fakeKMS struct {
+	keys  map[string]crypto.Signer
+	specs map[string][]types.SigningAlgorithmSpec
+	signs []*kms.SignInput
+}
+
+func (f *fakeKMS) GetPublicKey(ctx context.Context, in *kms.GetPublicKeyInput, opts ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	key, ok := f.keys[aws.ToString(in.KeyId)]
+	if !ok {
+		return nil, errors.New("NotFoundException")
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &kms.GetPublicKeyOutput{PublicKey: der, SigningAlgorithms: f.specs[aws.ToString(in.KeyId)]}, nil
+}
+
+func (f *fakeKMS) Sign(ctx context.Context, in *kms.SignInput, opts ...func(*kms.Options)) (*kms.SignOutput, error) {
+	f.signs = append(f.signs, in)
+	key := f.keys[aws.ToString(in.KeyId)]
+	if in.MessageType != types.MessageTypeDigest {
+		return nil, errors.New("unexpected message type")
+	}
+	var signerOpts crypto.SignerOpts
+	switch in.SigningAlgorithm {
+	case types.SigningAlgorithmSpecEcdsaSha256, types.SigningAlgorithmSpecRsassaPkcs1V15Sha256:
+		signerOpts = crypto.SHA256
+	case types.SigningAlgorithmSpecRsassaPssSha384:
+		signerOpts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384}
+	default:
+		return nil, errors.New("unexpected signing algorithm")
+	}
+	sig, err := key.Sign(rand.Reader, in.Message, signerOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.SignOutput{Signature: sig, SigningAlgorithm: in.SigningAlgorithm}, nil
+}
+
+func TestSigner(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &fakeKMS{
+		keys: map[string]crypto.Signer{"alias/ec": ecKey, "alias/rsa": rsaKey},
+		specs: map[string][]types.SigningAlgorithmSpec{
+			"alias/ec":  {types.SigningAlgorithmSpecEcdsaSha256},
+			"alias/rsa": {types.SigningAlgorithmSpecRsassaPkcs1V15Sha256, types.SigningAlgorithmSpecRsassaPssSha384},
+		},
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		kmsKeyID, alg string
+		pub           crypto.PublicKey
+	}{
+		{"alias/ec", oidc.ES256, ecKey.Public()},
+		{"alias/rsa", oidc.RS256, rsaKey.Public()},
+		{"alias/rsa", oidc.PS384, rsaKey.Public()},
+	}
+	for _, test := range tests {
+		signer, err := NewSigner(ctx, client, test.kmsKeyID, test.alg, "kid1")
+		if err != nil {
+			t.Errorf("%s: creating signer: %v", test.alg, err)
+			continue
+		}
+		assertion, err := oidc.ClientAssertion(ctx, signer, "client", "https://foo/token", 0)
+		if err != nil {
+			t.Errorf("%s: signing: %v", test.alg, err)
+			continue
+		}
+		keySet := &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{test.pub}}
+		verifier := oidc.NewVerifier("client", keySet, &oidc.Config{ClientID: "https://foo/token", SupportedSigningAlgs: []string{test.alg}})
+		if _, err := verifier.Verify(ctx, assertion); err != nil {
+			t.Errorf("%s: verifying assertion: %v", test.alg, err)
+		}
+		if _, err := oidc.DPoPProof(ctx, signer, "POST", "https://foo/token", "", ""); err != nil {
+			t.Errorf("%s: signing dpop proof: %v", test.alg, err)
+		}
+	}
+	if got := aws.ToString(client.signs[0].KeyId); got != "alias/ec" {
+		t.Errorf("expected signing with alias/ec, got %q", got)
+	}
+
+	if _, err := NewSigner(ctx, client, "alias/ec", oidc.ES384, ""); err == nil {
+		t.Errorf("expected error for algorithm the key doesn't support")
+	}
+	if _, err := NewSigner(ctx, client, "alias/rsa", oidc.ES256, ""); err == nil {
+		t.Errorf("expected error for algorithm of another key type")
+	}
+	if _, err := NewSigner(ctx, client, "alias/missing", oidc.ES256, ""); err == nil {
+		t.Errorf("expected error for missing key")
+	}
+}
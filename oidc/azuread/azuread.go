@@ -0,0 +1,283 @@
+// Package azuread verifies tokens issued by Microsoft Entra ID, formerly Azure
+// Active Directory.
+//
+// Entra ID deviates from OpenID Connect in ways that trip up generic
+// verifiers:
+//
+//   - The discovery documents of the multi-tenant "common", "organizations"
+//     and "consumers" endpoints advertise the templated issuer
+//     "https://login.microsoftonline.com/{tenantid}/v2.0". Tokens carry the
+//     issuer of the user's tenant, so a plain issuer comparison always fails.
+//   - v1.0 tokens use the issuer "https://sts.windows.net/{tenantid}/", and
+//     v2.0 tokens use the login.microsoftonline.com issuer. Applications
+//     registered for v1.0 access tokens receive v1.0 tokens even from the v2.0
+//     endpoints.
+//   - Any tenant can sign in to a multi-tenant application, so accepting the
+//     templated issuer without restricting the "tid" claim accepts tokens from
+//     every Entra ID tenant.
+//   - Access tokens for Microsoft Graph can't be verified by anyone but Graph.
+//     They carry a nonce in their header that invalidates the signature.
+//
+// The Verifier handles all of these:
+//
+//	verifier, err := azuread.NewVerifier(ctx, azuread.Config{
+//		ClientID:       "00000000-0000-0000-0000-000000000001",
+//		TenantID:       azuread.TenantOrganizations,
+//		AllowedTenants: []string{"72f988bf-86f1-41af-91ab-2d7cd011db47"},
+//	})
+package azuread
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// Well known tenants and endpoints.
+const (
+	// Authority is the login endpoint of the public cloud. Sovereign clouds
+	// use their own authority, such as "https://login.microsoftonline.us".
+	Authority = "https://login.microsoftonline.com"
+	// V1IssuerTemplate is the issuer of v1.0 tokens in the public cloud.
+	V1IssuerTemplate = "https://sts.windows.net/{tenantid}/"
+
+	// TenantCommon accepts work, school and personal Microsoft accounts.
+	TenantCommon = "common"
+	// TenantOrganizations accepts work and school accounts.
+	TenantOrganizations = "organizations"
+	// TenantConsumers accepts personal Microsoft accounts.
+	TenantConsumers = "consumers"
+	// ConsumersTenantID is the tenant of personal Microsoft accounts.
+	ConsumersTenantID = "9188040d-6c67-4c5b-b112-36a304b66dad"
+)
+
+// graphAudiences are the audiences of Microsoft Graph access tokens.
+var graphAudiences = []string{
+	"00000003-0000-0000-c000-000000000000",
+	"https://graph.microsoft.com",
+	"https://graph.microsoft.com/",
+}
+
+// ErrGraphToken is returned for access tokens issued for Microsoft Graph.
+// These can only be validated by Graph; request a token for the application's
+// own API, such as "api://<client-id>/.default", instead.
+var ErrGraphToken = errors.New("azuread: token is issued for Microsoft Graph and can't be verified by applications")
+
+// Config configures a Verifier.
+type Config struct {
+	// ClientID is the application (client) ID. Tokens must be issued for it,
+	// or for "api://<ClientID>", the default Application ID URI. Required.
+	ClientID string
+	// Audiences lists additional accepted audiences, such as a custom
+	// Application ID URI.
+	Audiences []string
+
+	// TenantID is the tenant the application signs users in from: a tenant ID,
+	// or TenantCommon, TenantOrganizations or TenantConsumers for
+	// multi-tenant applications. Required.
+	TenantID string
+	// AllowedTenants restricts the "tid" claim of tokens accepted by
+	// multi-tenant applications. Either AllowedTenants or AllowAnyTenant is
+	// required with a multi-tenant TenantID.
+	AllowedTenants []string
+	// AllowAnyTenant accepts tokens from every tenant. Applications must then
+	// authorize users by their tenant and object ID themselves.
+	AllowAnyTenant bool
+
+	// AcceptV1Tokens accepts v1.0 tokens, issued by V1IssuerTemplate.
+	AcceptV1Tokens bool
+
+	// Authority overrides the login endpoint, for sovereign clouds. Defaults
+	// to Authority.
+	Authority string
+	// V1Issuer overrides the issuer template of v1.0 tokens. Defaults to
+	// V1IssuerTemplate.
+	V1Issuer string
+
+	// HTTPClient is used to fetch keys. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Now overrides the clock used for expiry checks.
+	Now func() time.Time
+}
+
+// Claims are the Entra ID specific claims of a token.
+type Claims struct {
+	TenantID          string   `json:"tid"`
+	ObjectID          string   `json:"oid"`
+	Version           string   `json:"ver"`
+	PreferredUsername string   `json:"preferred_username"`
+	UPN               string   `json:"upn"`
+	Name              string   `json:"name"`
+	AppID             string   `json:"appid"`
+	AuthorizedParty   string   `json:"azp"`
+	Roles             []string `json:"roles"`
+	Scope             string   `json:"scp"`
+
+	// Groups holds the object IDs of the user's groups. It's empty if the
+	// user is a member of too many groups, see GroupsResolver.
+	Groups []string `json:"groups"`
+	// HasGroups is set instead of a distributed groups claim in tokens issued
+	// through the implicit flow when the user has too many groups.
+	HasGroups bool `json:"hasgroups"`
+}
+
+// Token is a verified token.
+type Token struct {
+	*oidc.IDToken
+
+	Claims Claims
+}
+
+// Verifier verifies ID Tokens and access tokens issued by Entra ID.
+type Verifier struct {
+	verifier  *oidc.IDTokenVerifier
+	v1, v2    *oidc.IssuerTemplate
+	tenant    string
+	allowed   map[string]bool
+	audiences []string
+}
+
+// isMultiTenant reports whether tenant is one of the multi-tenant endpoints.
+func isMultiTenant(tenant string) bool {
+	switch tenant {
+	case TenantCommon, TenantOrganizations, TenantConsumers:
+		return true
+	}
+	return false
+}
+
+// NewVerifier returns a verifier for tokens issued to the configured
+// application. Keys are fetched from the tenant's JWKS endpoint on demand.
+func NewVerifier(ctx context.Context, config Config) (*Verifier, error) {
+	if config.ClientID == "" {
+		return nil, errors.New("azuread: client id is required")
+	}
+	if config.TenantID == "" {
+		return nil, errors.New("azuread: tenant id is required")
+	}
+	multiTenant := isMultiTenant(config.TenantID)
+	if multiTenant && len(config.AllowedTenants) == 0 && !config.AllowAnyTenant && config.TenantID != TenantConsumers {
+		return nil, errors.New("azuread: multi-tenant applications require AllowedTenants or AllowAnyTenant")
+	}
+	authority := strings.TrimSuffix(config.Authority, "/")
+	if authority == "" {
+		authority = Authority
+	}
+	v2, err := oidc.NewIssuerTemplate(authority + "/{tenantid}/v2.0")
+	if err != nil {
+		return nil, err
+	}
+	matchers := []oidc.IssuerMatcher{v2}
+	var v1 *oidc.IssuerTemplate
+	if config.AcceptV1Tokens {
+		tmpl := config.V1Issuer
+		if tmpl == "" {
+			tmpl = V1IssuerTemplate
+		}
+		if v1, err = oidc.NewIssuerTemplate(tmpl); err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, v1)
+	}
+
+	v := &Verifier{
+		v1:        v1,
+		v2:        v2,
+		tenant:    config.TenantID,
+		audiences: append([]string{config.ClientID, "api://" + config.ClientID}, config.Audiences...),
+	}
+	switch {
+	case config.TenantID == TenantConsumers:
+		v.allowed = map[string]bool{ConsumersTenantID: true}
+	case !multiTenant:
+		v.allowed = map[string]bool{config.TenantID: true}
+	case !config.AllowAnyTenant:
+		v.allowed = make(map[string]bool, len(config.AllowedTenants))
+		for _, tid := range config.AllowedTenants {
+			v.allowed[tid] = true
+		}
+	}
+
+	if config.HTTPClient != nil {
+		ctx = oidc.ClientContext(ctx, config.HTTPClient)
+	}
+	// All tenants of a cloud share keys, but the tenant specific endpoint also
+	// serves keys of applications using custom signing keys.
+	keys := oidc.NewRemoteKeySet(ctx, authority+"/"+config.TenantID+"/discovery/v2.0/keys")
+	v.verifier = oidc.NewVerifier("", keys, &oidc.Config{
+		// The audience is checked against several values by Verify.
+		SkipClientIDCheck:    true,
+		SupportedSigningAlgs: []string{oidc.RS256},
+		IssuerMatcher:        oidc.AnyIssuer(matchers...),
+		Now:                  config.Now,
+	})
+	return v, nil
+}
+
+// Verify verifies a token and decodes its Entra ID claims. Both ID Tokens and
+// access tokens issued for the application's API are accepted.
+func (v *Verifier) Verify(ctx context.Context, rawToken string) (*Token, error) {
+	if isGraphToken(rawToken) {
+		return nil, ErrGraphToken
+	}
+	idToken, err := v.verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, err
+	}
+	if !containsAny(idToken.Audience, v.audiences) {
+		return nil, &oidc.InvalidAudienceError{Expected: v.audiences[0], Actual: idToken.Audience}
+	}
+	var claims Claims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("azuread: decoding claims: %v", err)
+	}
+	if claims.TenantID == "" {
+		return nil, errors.New("azuread: token has no tid claim")
+	}
+
+	// The issuer must belong to the tenant of the token, and match its version.
+	tmpl := v.v2
+	if claims.Version == "1.0" {
+		if tmpl = v.v1; tmpl == nil {
+			return nil, errors.New("azuread: v1.0 tokens aren't accepted")
+		}
+	}
+	values, ok := tmpl.Match(idToken.Issuer)
+	if !ok {
+		return nil, fmt.Errorf("azuread: issuer %q doesn't match token version %q", idToken.Issuer, claims.Version)
+	}
+	if values["tenantid"] != claims.TenantID {
+		return nil, fmt.Errorf("azuread: issuer %q doesn't match tenant %q", idToken.Issuer, claims.TenantID)
+	}
+	if v.allowed != nil && !v.allowed[claims.TenantID] {
+		return nil, fmt.Errorf("azuread: tenant %q isn't allowed", claims.TenantID)
+	}
+	return &Token{IDToken: idToken, Claims: claims}, nil
+}
+
+// isGraphToken inspects the unverified payload of a token for a Microsoft
+// Graph audience. Graph tokens fail signature verification, which is confusing
+// to debug, so they're detected up front.
+func isGraphToken(rawToken string) bool {
+	unverified, err := oidc.ParseUnverified(rawToken)
+	if err != nil {
+		return false
+	}
+	return containsAny(unverified.Audience, graphAudiences)
+}
+
+func containsAny(values, want []string) bool {
+	for _, v := range values {
+		for _, w := range want {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
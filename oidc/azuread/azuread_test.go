@@ -0,0 +1,147 @@
+package azuread
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+const (
+	clientID = "11111111-1111-1111-1111-111111111111"
+	tenantA  = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+	tenantB  = "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb"
+)
+
+type testTenant struct {
+	priv *rsa.PrivateKey
+	srv  *httptest.Server
+}
+
+func newTestTenant(t *testing.T) *testTenant {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: priv.Public(), KeyID: "k1", Algorithm: "RS256", Use: "sig"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/discovery/v2.0/keys") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jwks)
+	}))
+	t.Cleanup(srv.Close)
+	return &testTenant{priv: priv, srv: srv}
+}
+
+func (tt *testTenant) sign(t *testing.T, claims map[string]interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: tt.priv, KeyID: "k1"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestVerifier(t *testing.T) {
+	tt := newTestTenant(t)
+	ctx := context.Background()
+	v2Issuer := func(tid string) string { return fmt.Sprintf("%s/%s/v2.0", tt.srv.URL, tid) }
+	v1Issuer := func(tid string) string { return fmt.Sprintf("https://sts.windows.net/%s/", tid) }
+	claims := func(iss, tid, ver, aud string) map[string]interface{} {
+		return map[string]interface{}{"iss": iss, "tid": tid, "ver": ver, "aud": aud, "oid": "user", "exp": 4102444800}
+	}
+
+	verifier, err := NewVerifier(ctx, Config{
+		ClientID:       clientID,
+		TenantID:       TenantOrganizations,
+		AllowedTenants: []string{tenantA},
+		AcceptV1Tokens: true,
+		Authority:      tt.srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		wantErr string
+	}{
+		{name: "v2 id token", claims: claims(v2Issuer(tenantA), tenantA, "2.0", clientID)},
+		{name: "v1 access token", claims: claims(v1Issuer(tenantA), tenantA, "1.0", "api://"+clientID)},
+		{name: "tenant not allowed", claims: claims(v2Issuer(tenantB), tenantB, "2.0", clientID), wantErr: "isn't allowed"},
+		{name: "issuer of another tenant", claims: claims(v2Issuer(tenantB), tenantA, "2.0", clientID), wantErr: "doesn't match tenant"},
+		{name: "version mismatch", claims: claims(v1Issuer(tenantA), tenantA, "2.0", clientID), wantErr: "doesn't match token version"},
+		{name: "wrong audience", claims: claims(v2Issuer(tenantA), tenantA, "2.0", "other"), wantErr: "expected audience"},
+		{name: "unknown issuer", claims: claims("https://evil/"+tenantA+"/v2.0", tenantA, "2.0", clientID), wantErr: "id token issued by a different provider"},
+		{name: "graph token", claims: claims(v2Issuer(tenantA), tenantA, "2.0", "https://graph.microsoft.com"), wantErr: ErrGraphToken.Error()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := verifier.Verify(ctx, tt.sign(t, test.claims))
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("verifying token: %v", err)
+				}
+				if token.Claims.TenantID != tenantA || token.Claims.ObjectID != "user" {
+					t.Errorf("unexpected claims %+v", token.Claims)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+
+	v2Only, err := NewVerifier(ctx, Config{ClientID: clientID, TenantID: tenantA, Authority: tt.srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v2Only.Verify(ctx, tt.sign(t, claims(v1Issuer(tenantA), tenantA, "1.0", clientID))); err == nil {
+		t.Errorf("expected v1.0 token to be rejected")
+	}
+	if _, err := v2Only.Verify(ctx, tt.sign(t, claims(v2Issuer(tenantB), tenantB, "2.0", clientID))); err == nil {
+		t.Errorf("expected token of another tenant to be rejected by single tenant verifier")
+	}
+}
+
+func TestNewVerifierConfig(t *testing.T) {
+	ctx := context.Background()
+	for _, config := range []Config{
+		{TenantID: tenantA},
+		{ClientID: clientID},
+		{ClientID: clientID, TenantID: TenantCommon},
+	} {
+		if _, err := NewVerifier(ctx, config); err == nil {
+			t.Errorf("expected error for config %+v", config)
+		}
+	}
+	if _, err := NewVerifier(ctx, Config{ClientID: clientID, TenantID: TenantCommon, AllowAnyTenant: true}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package azuread
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// GraphURL is the Microsoft Graph endpoint of the public cloud.
+const GraphURL = "https://graph.microsoft.com/v1.0"
+
+// defaultMaxGroupPages bounds the number of pages read for one user.
+const defaultMaxGroupPages = 100
+
+// maxResponseSize bounds responses read from Microsoft Graph.
+const maxResponseSize = 4 << 20
+
+// memberObjectsRE extracts the user from the endpoint of the distributed groups
+// claim, such as "https://graph.windows.net/<tid>/users/<oid>/getMemberObjects".
+var memberObjectsRE = regexp.MustCompile(`/users/([^/]+)/getMemberObjects$`)
+
+// GroupsResolver resolves the groups of users who are members of too many
+// groups to be included in tokens. In that case Entra ID replaces the "groups"
+// claim with a distributed claim, or the "hasgroups" claim, and the groups
+// have to be read from Microsoft Graph.
+//
+// The distributed claim points to the retired Azure AD Graph, which doesn't
+// return signed JWTs as OpenID Connect requires. GroupsResolver reads the
+// user's transitive group memberships from Microsoft Graph instead, following
+// paged responses.
+//
+// GroupsResolver implements oidc.ClaimResolver, so it can resolve groups for
+// an oidc.IdentityMapper.
+type GroupsResolver struct {
+	// TokenSource provides access tokens for Microsoft Graph, such as client
+	// credentials tokens of the application with the GroupMember.Read.All
+	// permission. Required.
+	TokenSource oauth2.TokenSource
+	// GraphURL overrides GraphURL, for sovereign clouds.
+	GraphURL string
+	// HTTPClient is used for requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// MaxPages bounds the number of pages read for one user. Defaults to 100.
+	MaxPages int
+}
+
+// Groups returns the object IDs of the user's groups, reading them from
+// Microsoft Graph if they aren't included in the token.
+func (r *GroupsResolver) Groups(ctx context.Context, token *Token) ([]string, error) {
+	if len(token.Claims.Groups) > 0 {
+		return token.Claims.Groups, nil
+	}
+	if _, ok := token.ClaimSource("groups"); !ok && !token.Claims.HasGroups {
+		return nil, nil
+	}
+	if token.Claims.ObjectID == "" {
+		return nil, errors.New("azuread: token has no oid claim")
+	}
+	return r.memberOf(ctx, token.Claims.ObjectID)
+}
+
+// ResolveClaim implements oidc.ClaimResolver for the "groups" claim.
+func (r *GroupsResolver) ResolveClaim(ctx context.Context, claim string, src oidc.ClaimSource) (json.RawMessage, error) {
+	if claim != "groups" {
+		return nil, fmt.Errorf("azuread: can't resolve claim %q", claim)
+	}
+	m := memberObjectsRE.FindStringSubmatch(src.Endpoint)
+	if m == nil {
+		return nil, fmt.Errorf("azuread: unexpected groups claim source %q", src.Endpoint)
+	}
+	user, err := url.PathUnescape(m[1])
+	if err != nil {
+		return nil, fmt.Errorf("azuread: unexpected groups claim source %q", src.Endpoint)
+	}
+	groups, err := r.memberOf(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(groups)
+}
+
+// memberOf lists the IDs of the groups a user is a transitive member of.
+func (r *GroupsResolver) memberOf(ctx context.Context, user string) ([]string, error) {
+	if r.TokenSource == nil {
+		return nil, errors.New("azuread: GroupsResolver requires a token source")
+	}
+	base := strings.TrimSuffix(r.GraphURL, "/")
+	if base == "" {
+		base = GraphURL
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("azuread: invalid graph url: %v", err)
+	}
+	maxPages := r.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxGroupPages
+	}
+
+	token, err := r.TokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("azuread: getting graph token: %w", err)
+	}
+	next := base + "/users/" + url.PathEscape(user) + "/transitiveMemberOf/microsoft.graph.group?$select=id&$top=999"
+	groups := []string{}
+	for page := 0; next != ""; page++ {
+		if page == maxPages {
+			return nil, fmt.Errorf("azuread: user is a member of more than %d pages of groups", maxPages)
+		}
+		// Only send the token to the Graph endpoint, even if a response
+		// links elsewhere.
+		u, err := url.Parse(next)
+		if err != nil || u.Scheme != baseURL.Scheme || u.Host != baseURL.Host {
+			return nil, fmt.Errorf("azuread: unexpected next link %q", next)
+		}
+		var resp struct {
+			Value []struct {
+				ID string `json:"id"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		if err := r.get(ctx, token, next, &resp); err != nil {
+			return nil, err
+		}
+		for _, v := range resp.Value {
+			groups = append(groups, v.ID)
+		}
+		next = resp.NextLink
+	}
+	return groups, nil
+}
+
+func (r *GroupsResolver) get(ctx context.Context, token *oauth2.Token, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	token.SetAuthHeader(req)
+	client := r.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("azuread: graph request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("azuread: reading graph response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error.Code != "" {
+			return fmt.Errorf("azuread: graph returned %d %s: %s", resp.StatusCode, e.Error.Code, e.Error.Message)
+		}
+		return fmt.Errorf("azuread: graph returned %s", resp.Status)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("azuread: decoding graph response: %v", err)
+	}
+	return nil
+}
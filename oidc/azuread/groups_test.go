@@ -0,0 +1,111 @@
+package azuread
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+func newFakeGraph(t *testing.T) *httptest.Server {
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer graph-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"code":"InvalidAuthenticationToken","message":"bad token"}}`)
+			return
+		}
+		if r.URL.Path != "/users/user/transitiveMemberOf/microsoft.graph.group" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "":
+			fmt.Fprintf(w, `{"value":[{"id":"g1"},{"id":"g2"}],"@odata.nextLink":"%s%s?page=2"}`, s.URL, r.URL.Path)
+		case "2":
+			fmt.Fprint(w, `{"value":[{"id":"g3"}]}`)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestGroupsResolver(t *testing.T) {
+	tt := newTestTenant(t)
+	graph := newFakeGraph(t)
+	ctx := context.Background()
+
+	verifier, err := NewVerifier(ctx, Config{ClientID: clientID, TenantID: tenantA, Authority: tt.srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := map[string]interface{}{
+		"iss": fmt.Sprintf("%s/%s/v2.0", tt.srv.URL, tenantA), "tid": tenantA, "ver": "2.0",
+		"aud": clientID, "oid": "user", "sub": "user", "exp": 4102444800,
+	}
+	with := func(extra map[string]interface{}) *Token {
+		claims := make(map[string]interface{})
+		for k, v := range base {
+			claims[k] = v
+		}
+		for k, v := range extra {
+			claims[k] = v
+		}
+		token, err := verifier.Verify(ctx, tt.sign(t, claims))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	overflow := with(map[string]interface{}{
+		"_claim_names":   map[string]string{"groups": "src1"},
+		"_claim_sources": map[string]interface{}{"src1": map[string]string{"endpoint": "https://graph.windows.net/" + tenantA + "/users/user/getMemberObjects"}},
+	})
+
+	resolver := &GroupsResolver{
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "graph-token"}),
+		GraphURL:    graph.URL,
+	}
+	want := []string{"g1", "g2", "g3"}
+	for name, token := range map[string]*Token{
+		"distributed claim": overflow,
+		"hasgroups":         with(map[string]interface{}{"hasgroups": true}),
+	} {
+		got, err := resolver.Groups(ctx, token)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got groups %v, want %v", name, got, want)
+		}
+	}
+	if got, err := resolver.Groups(ctx, with(map[string]interface{}{"groups": []string{"inline"}})); err != nil || !reflect.DeepEqual(got, []string{"inline"}) {
+		t.Errorf("expected inline groups, got %v, err=%v", got, err)
+	}
+
+	mapper := &oidc.IdentityMapper{ClaimResolver: resolver}
+	id, err := mapper.Map(ctx, overflow.IDToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(id.Groups, want) {
+		t.Errorf("mapper got groups %v, want %v", id.Groups, want)
+	}
+
+	limited := *resolver
+	limited.MaxPages = 1
+	if _, err := limited.Groups(ctx, overflow); err == nil {
+		t.Errorf("expected page limit to be enforced")
+	}
+	unauthorized := *resolver
+	unauthorized.TokenSource = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "wrong"})
+	if _, err := unauthorized.Groups(ctx, overflow); err == nil {
+		t.Errorf("expected graph error")
+	}
+}
@@ -0,0 +1,146 @@
+// Package azurekeyvault signs client assertions and DPoP proofs with keys held
+// by Azure Key Vault or Managed HSM, so the private key never enters the
+// process. It's a separate module so the core module doesn't depend on the
+// Azure SDK.
+//
+//	cred, err := azidentity.NewDefaultAzureCredential(nil)
+//	if err != nil {
+//		// handle error
+//	}
+//	client, err := azkeys.NewClient("https://my-vault.vault.azure.net", cred, nil)
+//	if err != nil {
+//		// handle error
+//	}
+//	signer, err := azurekeyvault.NewSigner(ctx, client, "oidc-client", "", oidc.ES256, "key-1")
+//	if err != nil {
+//		// handle error
+//	}
+//	assertion, err := oidc.ClientAssertion(ctx, signer, clientID, provider.Endpoint().TokenURL, 0)
+//
+// The caller needs the keys/get and keys/sign permissions on the key.
+package azurekeyvault
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// Client is the subset of the Key Vault keys API used by signers.
+// *azkeys.Client implements it.
+type Client interface {
+	GetKey(ctx context.Context, name, version string, options *azkeys.GetKeyOptions) (azkeys.GetKeyResponse, error)
+	Sign(ctx context.Context, name, version string, parameters azkeys.SignParameters, options *azkeys.SignOptions) (azkeys.SignResponse, error)
+}
+
+// NewSigner returns a signer using the version of the named key, or its
+// current version if version is empty. Key Vault names its signature
+// algorithms after JWS algorithms, so alg, such as ES256 or PS256, is also the
+// algorithm requested from Key Vault. keyID is the "kid" header of signed
+// tokens.
+//
+// Pin a version to keep signing with the same key after the key is rotated in
+// Key Vault, since the version used is resolved once, by NewSigner.
+func NewSigner(ctx context.Context, client Client, name, version, alg, keyID string) (oidc.Signer, error) {
+	resp, err := client.GetKey(ctx, name, version, nil)
+	if err != nil {
+		return nil, fmt.Errorf("azurekeyvault: get key: %w", err)
+	}
+	if resp.Key == nil {
+		return nil, errors.New("azurekeyvault: response has no key")
+	}
+	pub, err := publicKey(resp.Key)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" && resp.Key.KID != nil {
+		version = resp.Key.KID.Version()
+	}
+	return oidc.NewCryptoSigner(&key{client: client, name: name, version: version, pub: pub, alg: azkeys.SignatureAlgorithm(alg)}, alg, keyID)
+}
+
+// publicKey returns the public key of a Key Vault JSON Web Key.
+func publicKey(jwk *azkeys.JSONWebKey) (crypto.PublicKey, error) {
+	if jwk.Kty == nil {
+		return nil, errors.New("azurekeyvault: key has no type")
+	}
+	switch *jwk.Kty {
+	case azkeys.KeyTypeRSA, azkeys.KeyTypeRSAHSM:
+		e := new(big.Int).SetBytes(jwk.E)
+		if len(jwk.N) == 0 || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("azurekeyvault: invalid rsa key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(jwk.N), E: int(e.Int64())}, nil
+	case azkeys.KeyTypeEC, azkeys.KeyTypeECHSM:
+		var curve elliptic.Curve
+		if jwk.Crv != nil {
+			switch *jwk.Crv {
+			case azkeys.CurveNameP256:
+				curve = elliptic.P256()
+			case azkeys.CurveNameP384:
+				curve = elliptic.P384()
+			case azkeys.CurveNameP521:
+				curve = elliptic.P521()
+			}
+		}
+		if curve == nil {
+			return nil, fmt.Errorf("azurekeyvault: unsupported curve %v", jwk.Crv)
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(jwk.X), Y: new(big.Int).SetBytes(jwk.Y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("azurekeyvault: invalid ecdsa key")
+		}
+		return pub, nil
+	}
+	return nil, fmt.Errorf("azurekeyvault: unsupported key type %s", *jwk.Kty)
+}
+
+// key is an oidc.ContextSigner signing with a Key Vault key.
+type key struct {
+	client  Client
+	name    string
+	version string
+	pub     crypto.PublicKey
+	alg     azkeys.SignatureAlgorithm
+}
+
+func (k *key) Public() crypto.PublicKey { return k.pub }
+
+func (k *key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(context.Background(), digest, opts)
+}
+
+// SignContext signs the digest. Key Vault returns ECDSA signatures in the
+// fixed size r||s encoding of JWS, which are converted to the ASN.1 encoding
+// crypto.Signer requires.
+func (k *key) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if pss, ok := opts.(*rsa.PSSOptions); ok && pss.SaltLength != rsa.PSSSaltLengthEqualsHash {
+		return nil, errors.New("azurekeyvault: pss signatures use a salt as long as the hash")
+	}
+	alg := k.alg
+	resp, err := k.client.Sign(ctx, k.name, k.version, azkeys.SignParameters{Algorithm: &alg, Value: digest}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("azurekeyvault: sign: %w", err)
+	}
+	pub, ok := k.pub.(*ecdsa.PublicKey)
+	if !ok {
+		return resp.Result, nil
+	}
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	if len(resp.Result) != 2*size {
+		return nil, fmt.Errorf("azurekeyvault: malformed ecdsa signature of %d bytes", len(resp.Result))
+	}
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{new(big.Int).SetBytes(resp.Result[:size]), new(big.Int).SetBytes(resp.Result[size:])})
+}
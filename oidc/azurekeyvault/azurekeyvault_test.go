@@ -0,0 +1,113 @@
+package azurekeyvault
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// fakeVault signs with local keys the way Key Vault does.
+type fakeVault struct {
+	keys     map[string]crypto.Signer
+	versions []string
+}
+
+func (f *fakeVault) GetKey(ctx context.Context, name, version string, options *azkeys.GetKeyOptions) (azkeys.GetKeyResponse, error) {
+	k, ok := f.keys[name]
+	if !ok {
+		return azkeys.GetKeyResponse{}, errors.New("KeyNotFound")
+	}
+	kid := azkeys.ID("https://vault.example.com/keys/" + name + "/v2")
+	jwk := &azkeys.JSONWebKey{KID: &kid}
+	switch pub := k.Public().(type) {
+	case *rsa.PublicKey:
+		kty := azkeys.KeyTypeRSAHSM
+		jwk.Kty, jwk.N, jwk.E = &kty, pub.N.Bytes(), big.NewInt(int64(pub.E)).Bytes()
+	case *ecdsa.PublicKey:
+		kty, crv := azkeys.KeyTypeEC, azkeys.CurveNameP256
+		jwk.Kty, jwk.Crv, jwk.X, jwk.Y = &kty, &crv, pub.X.FillBytes(make([]byte, 32)), pub.Y.FillBytes(make([]byte, 32))
+	}
+	return azkeys.GetKeyResponse{KeyBundle: azkeys.KeyBundle{Key: jwk}}, nil
+}
+
+func (f *fakeVault) Sign(ctx context.Context, name, version string, parameters azkeys.SignParameters, options *azkeys.SignOptions) (azkeys.SignResponse, error) {
+	f.versions = append(f.versions, version)
+	k := f.keys[name]
+	var opts crypto.SignerOpts
+	switch *parameters.Algorithm {
+	case azkeys.SignatureAlgorithmES256:
+		opts = crypto.SHA256
+	case azkeys.SignatureAlgorithmPS256:
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	default:
+		return azkeys.SignResponse{}, errors.New("unexpected algorithm")
+	}
+	sig, err := k.Sign(rand.Reader, parameters.Value, opts)
+	if err != nil {
+		return azkeys.SignResponse{}, err
+	}
+	if _, ok := k.(*ecdsa.PrivateKey); ok {
+		var esig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &esig); err != nil {
+			return azkeys.SignResponse{}, err
+		}
+		sig = append(esig.R.FillBytes(make([]byte, 32)), esig.S.FillBytes(make([]byte, 32))...)
+	}
+	return azkeys.SignResponse{KeyOperationResult: azkeys.KeyOperationResult{Result: sig}}, nil
+}
+
+func TestSigner(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &fakeVault{keys: map[string]crypto.Signer{"ec": ecKey, "rsa": rsaKey}}
+	ctx := context.Background()
+
+	for name, alg := range map[string]string{"ec": oidc.ES256, "rsa": oidc.PS256} {
+		signer, err := NewSigner(ctx, client, name, "", alg, "kid1")
+		if err != nil {
+			t.Errorf("%s: creating signer: %v", name, err)
+			continue
+		}
+		assertion, err := oidc.ClientAssertion(ctx, signer, "client", "https://foo/token", 0)
+		if err != nil {
+			t.Errorf("%s: signing: %v", name, err)
+			continue
+		}
+		keySet := &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{client.keys[name].Public()}}
+		verifier := oidc.NewVerifier("client", keySet, &oidc.Config{ClientID: "https://foo/token", SupportedSigningAlgs: []string{alg}})
+		if _, err := verifier.Verify(ctx, assertion); err != nil {
+			t.Errorf("%s: verifying assertion: %v", name, err)
+		}
+		if _, err := oidc.DPoPProof(ctx, signer, "POST", "https://foo/token", "", ""); err != nil {
+			t.Errorf("%s: signing dpop proof: %v", name, err)
+		}
+	}
+	for _, v := range client.versions {
+		if v != "v2" {
+			t.Errorf("expected signing with the resolved key version, got %q", v)
+		}
+	}
+
+	if _, err := NewSigner(ctx, client, "ec", "", oidc.RS256, ""); err == nil {
+		t.Errorf("expected error for algorithm of another key type")
+	}
+	if _, err := NewSigner(ctx, client, "missing", "", oidc.ES256, ""); err == nil {
+		t.Errorf("expected error for missing key")
+	}
+}
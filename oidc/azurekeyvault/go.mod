@@ -0,0 +1,25 @@
+module github.com/coreos/go-oidc/v3/oidc/azurekeyvault
+
+go 1.25.0
+
+// See "Nested Modules" in CONTRIBUTING.md before releasing this module.
+replace github.com/coreos/go-oidc/v3 => ../..
+
+require (
+	github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys v1.5.0
+	github.com/coreos/go-oidc/v3 v3.21.0
+)
+
+require (
+	github.com/Azure/azure-sdk-for-go/sdk/azcore v1.21.0 // indirect
+	github.com/Azure/azure-sdk-for-go/sdk/internal v1.11.2 // indirect
+	github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/internal v1.2.0 // indirect
+	github.com/go-jose/go-jose/v3 v3.0.1 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	golang.org/x/crypto v0.49.0 // indirect
+	golang.org/x/net v0.52.0 // indirect
+	golang.org/x/oauth2 v0.13.0 // indirect
+	golang.org/x/text v0.35.0 // indirect
+	google.golang.org/appengine v1.6.8 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
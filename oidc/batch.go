@@ -0,0 +1,63 @@
+package oidc
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// VerifyResult holds the outcome of verifying a single token in a batch.
+type VerifyResult struct {
+	// Token is the verified ID Token. It's nil if verification failed.
+	Token *IDToken
+	// Err is the error returned by Verify, if any.
+	Err error
+}
+
+// VerifyBatch verifies a set of raw ID Tokens concurrently, returning a result
+// for each token in the same order as the input.
+//
+// At most GOMAXPROCS tokens are verified at once. Key lookups are shared between
+// workers: a RemoteKeySet only issues a single request for new keys regardless of
+// how many tokens reference an unknown key ID. If the context is canceled, tokens
+// that haven't been verified yet fail with the context's error.
+//
+//	results := verifier.VerifyBatch(ctx, rawIDTokens)
+//	for i, r := range results {
+//		if r.Err != nil {
+//			log.Printf("token %d: %v", i, r.Err)
+//			continue
+//		}
+//		// use r.Token
+//	}
+func (v *IDTokenVerifier) VerifyBatch(ctx context.Context, rawIDTokens []string) []VerifyResult {
+	results := make([]VerifyResult, len(rawIDTokens))
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(rawIDTokens) {
+		workers = len(rawIDTokens)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+				t, err := v.Verify(ctx, rawIDTokens[i])
+				results[i] = VerifyResult{Token: t, Err: err}
+			}
+		}()
+	}
+	for i := range rawIDTokens {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,75 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func TestVerifyBatch(t *testing.T) {
+	key := newRSAKey(t)
+	key.keyID = "key1"
+	other := newRSAKey(t)
+	other.keyID = "key2"
+
+	var fetches int32
+	ks := &keyServer{keys: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}}}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		ks.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	ctx := context.Background()
+	verifier := NewVerifier("https://foo", NewRemoteKeySet(ctx, s.URL), &Config{
+		SkipClientIDCheck: true,
+		SkipExpiryCheck:   true,
+	})
+
+	good := key.sign(t, []byte(`{"iss":"https://foo"}`))
+	var tokens []string
+	for i := 0; i < 50; i++ {
+		tokens = append(tokens, good)
+	}
+	tokens = append(tokens, other.sign(t, []byte(`{"iss":"https://foo"}`)), "not a token")
+
+	results := verifier.VerifyBatch(ctx, tokens)
+	if len(results) != len(tokens) {
+		t.Fatalf("expected %d results, got %d", len(tokens), len(results))
+	}
+	for i, r := range results[:50] {
+		if r.Err != nil || r.Token == nil {
+			t.Errorf("token %d: expected success, got %v", i, r.Err)
+		}
+	}
+	for i, r := range results[50:] {
+		if r.Err == nil || r.Token != nil {
+			t.Errorf("token %d: expected error", 50+i)
+		}
+	}
+	// Concurrent lookups of the same keys should be coalesced rather than
+	// issuing a request per token.
+	if n := atomic.LoadInt32(&fetches); n >= 25 {
+		t.Errorf("expected key set fetches to be shared, got %d fetches for %d tokens", n, len(tokens))
+	}
+}
+
+func TestVerifyBatchCanceled(t *testing.T) {
+	key := newRSAKey(t)
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: nil}, &Config{
+		SkipClientIDCheck: true,
+		SkipExpiryCheck:   true,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := verifier.VerifyBatch(ctx, []string{key.sign(t, []byte(`{"iss":"https://foo"}`))})
+	if len(results) != 1 || results[0].Err != context.Canceled {
+		t.Errorf("expected context canceled error, got %+v", results)
+	}
+}
@@ -0,0 +1,245 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Confirmation is the "cnf" claim of a token bound to a key held by its
+// client, so that a stolen token can't be used without the key.
+//
+// See: https://www.rfc-editor.org/rfc/rfc7800
+type Confirmation struct {
+	// X509ThumbprintS256 is the base64url encoded SHA-256 hash of the client
+	// certificate the token is bound to by mutual TLS (RFC 8705).
+	X509ThumbprintS256 string `json:"x5t#S256,omitempty"`
+	// JWKThumbprint is the thumbprint of the key the token is bound to by
+	// DPoP (RFC 9449).
+	JWKThumbprint string `json:"jkt,omitempty"`
+	// TokenBindingHash is the base64url encoded SHA-256 hash of the Token
+	// Binding ID the token is bound to (RFC 8473).
+	TokenBindingHash string `json:"tbh,omitempty"`
+}
+
+// Confirmation returns the "cnf" claim of the token, or nil if it has none.
+func (i *IDToken) Confirmation() (*Confirmation, error) {
+	var claims struct {
+		Confirmation *Confirmation `json:"cnf"`
+	}
+	if err := json.Unmarshal(i.claims, &claims); err != nil {
+		return nil, fmt.Errorf("oidc: malformed cnf claim: %v", err)
+	}
+	return claims.Confirmation, nil
+}
+
+// VerifyCertificateBinding checks that a token bound to a client certificate,
+// as described by RFC 8705, was presented over a TLS connection authenticated
+// with that certificate. The connection state is usually the TLS field of the
+// request. It fails if the token isn't bound to a certificate.
+//
+// The server must request client certificates, for example with
+// tls.RequireAnyClientCert; the certificate needn't be otherwise trusted,
+// since the token's issuer vouches for it.
+func VerifyCertificateBinding(token *IDToken, state *tls.ConnectionState) error {
+	cnf, err := token.Confirmation()
+	if err != nil {
+		return err
+	}
+	if cnf == nil || cnf.X509ThumbprintS256 == "" {
+		return errors.New("oidc: token isn't bound to a certificate")
+	}
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return errors.New("oidc: connection has no client certificate")
+	}
+	sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+	thumbprint := base64.RawURLEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(thumbprint), []byte(cnf.X509ThumbprintS256)) != 1 {
+		return errors.New("oidc: token is bound to another certificate")
+	}
+	return nil
+}
+
+// Token binding key parameters and types, from RFC 8471.
+const (
+	tokenBindingRSA2048PKCS1 = 0
+	tokenBindingRSA2048PSS   = 1
+	tokenBindingECDSAP256    = 2
+
+	tokenBindingProvided = 0
+
+	tokenBindingExporterLabel = "EXPORTER-Token-Binding"
+)
+
+// VerifyTokenBinding checks that a token bound to a Token Binding ID, as
+// described by RFC 8473, was presented over a TLS connection on which the
+// client proved possession of the Token Binding key. message is the
+// Sec-Token-Binding header of the request, whose provided token binding
+// signs the connection's exported keying material (RFC 8471). It fails if
+// the token isn't bound to a Token Binding ID.
+//
+// Token binding requires the connection to use TLS 1.3, or TLS 1.2 with the
+// extended master secret extension, so its keying material is unique.
+func VerifyTokenBinding(token *IDToken, state *tls.ConnectionState, message string) error {
+	cnf, err := token.Confirmation()
+	if err != nil {
+		return err
+	}
+	if cnf == nil || cnf.TokenBindingHash == "" {
+		return errors.New("oidc: token isn't bound to a token binding")
+	}
+	if state == nil {
+		return errors.New("oidc: token binding requires a TLS connection")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(message)
+	if err != nil {
+		return fmt.Errorf("oidc: malformed token binding message: %v", err)
+	}
+	id, err := verifyTokenBindingMessage(raw, state)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(id)
+	tbh := base64.RawURLEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(tbh), []byte(cnf.TokenBindingHash)) != 1 {
+		return errors.New("oidc: token is bound to another token binding")
+	}
+	return nil
+}
+
+// tlsReader reads the variable length vectors of TLS presentation language.
+type tlsReader struct {
+	b   []byte
+	err error
+}
+
+func (r *tlsReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.err = errors.New("oidc: malformed token binding message: truncated")
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *tlsReader) uint8() int {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return int(b[0])
+}
+
+func (r *tlsReader) vector8() []byte {
+	return r.bytes(r.uint8())
+}
+
+func (r *tlsReader) vector16() []byte {
+	b := r.bytes(2)
+	if b == nil {
+		return nil
+	}
+	return r.bytes(int(binary.BigEndian.Uint16(b)))
+}
+
+// verifyTokenBindingMessage verifies the signature of the provided token
+// binding of a TokenBindingMessage over the connection's keying material, and
+// returns its encoded Token Binding ID.
+func verifyTokenBindingMessage(msg []byte, state *tls.ConnectionState) ([]byte, error) {
+	r := &tlsReader{b: msg}
+	bindings := &tlsReader{b: r.vector16()}
+	if r.err != nil || len(r.b) != 0 {
+		return nil, errors.New("oidc: malformed token binding message")
+	}
+	for len(bindings.b) > 0 && bindings.err == nil {
+		bindingType := bindings.uint8()
+		idStart := bindings.b
+		params := bindings.uint8()
+		key := bindings.vector16()
+		if bindings.err != nil {
+			break
+		}
+		id := idStart[:3+len(key)]
+		sig := bindings.vector16()
+		bindings.vector16() // extensions
+		if bindings.err != nil || bindingType != tokenBindingProvided {
+			continue
+		}
+
+		ekm, err := state.ExportKeyingMaterial(tokenBindingExporterLabel, nil, 32)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: exporting keying material: %v", err)
+		}
+		signed := append([]byte{byte(bindingType), byte(params)}, ekm...)
+		if err := verifyTokenBindingSignature(params, key, signed, sig); err != nil {
+			return nil, err
+		}
+		return id, nil
+	}
+	if bindings.err != nil {
+		return nil, bindings.err
+	}
+	return nil, errors.New("oidc: token binding message has no provided token binding")
+}
+
+// verifyTokenBindingSignature verifies a signature made with a Token Binding
+// key, encoded as a TokenBindingPublicKey.
+func verifyTokenBindingSignature(params int, key, signed, sig []byte) error {
+	digest := sha256.Sum256(signed)
+	r := &tlsReader{b: key}
+	switch params {
+	case tokenBindingRSA2048PKCS1, tokenBindingRSA2048PSS:
+		modulus := r.vector16()
+		exponent := r.vector8()
+		if r.err != nil || len(r.b) != 0 || len(exponent) == 0 || len(exponent) > 4 {
+			return errors.New("oidc: malformed token binding rsa key")
+		}
+		e := 0
+		for _, b := range exponent {
+			e = e<<8 | int(b)
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: e}
+		var err error
+		if params == tokenBindingRSA2048PKCS1 {
+			err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+		} else {
+			err = rsa.VerifyPSS(pub, crypto.SHA256, digest[:], sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if err != nil {
+			return errors.New("oidc: invalid token binding signature")
+		}
+		return nil
+	case tokenBindingECDSAP256:
+		point := r.vector8()
+		if r.err != nil || len(r.b) != 0 || len(point) != 65 || point[0] != 4 {
+			return errors.New("oidc: malformed token binding ecdsa key")
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(point[1:33]),
+			Y:     new(big.Int).SetBytes(point[33:]),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return errors.New("oidc: malformed token binding ecdsa key")
+		}
+		if len(sig) != 64 || !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			return errors.New("oidc: invalid token binding signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("oidc: unsupported token binding key parameters %d", params)
+}
@@ -0,0 +1,223 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}
+}
+
+func certThumbprint(cert tls.Certificate) string {
+	sum := sha256.Sum256(cert.Certificate[0])
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// tlsPipe returns the states of both ends of a TLS connection.
+func tlsPipe(t *testing.T, clientCert *tls.Certificate) (client, server tls.ConnectionState) {
+	t.Helper()
+	c, s := net.Pipe()
+	defer c.Close()
+	defer s.Close()
+	serverConf := &tls.Config{
+		Certificates: []tls.Certificate{newTestCertificate(t)},
+		ClientAuth:   tls.RequestClientCert,
+	}
+	clientConf := &tls.Config{InsecureSkipVerify: true}
+	if clientCert != nil {
+		clientConf.Certificates = []tls.Certificate{*clientCert}
+	}
+	tc, ts := tls.Client(c, clientConf), tls.Server(s, serverConf)
+	errc := make(chan error, 1)
+	go func() { errc <- ts.Handshake() }()
+	if err := tc.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	return tc.ConnectionState(), ts.ConnectionState()
+}
+
+func tokenWithClaims(t *testing.T, claims string) *IDToken {
+	t.Helper()
+	key := newRSAKey(t)
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{SkipClientIDCheck: true})
+	token, err := verifier.Verify(context.Background(), key.sign(t, []byte(claims)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestVerifyCertificateBinding(t *testing.T) {
+	clientCert := newTestCertificate(t)
+	_, withCert := tlsPipe(t, &clientCert)
+	_, withoutCert := tlsPipe(t, nil)
+
+	bound := tokenWithClaims(t, fmt.Sprintf(`{"iss":"https://foo","exp":4102444800,"cnf":{"x5t#S256":%q}}`, certThumbprint(clientCert)))
+	other := tokenWithClaims(t, fmt.Sprintf(`{"iss":"https://foo","exp":4102444800,"cnf":{"x5t#S256":%q}}`, certThumbprint(newTestCertificate(t))))
+	unbound := tokenWithClaims(t, `{"iss":"https://foo","exp":4102444800}`)
+
+	if err := VerifyCertificateBinding(bound, &withCert); err != nil {
+		t.Errorf("bound token: %v", err)
+	}
+	if err := VerifyCertificateBinding(other, &withCert); err == nil {
+		t.Errorf("expected error for token bound to another certificate")
+	}
+	if err := VerifyCertificateBinding(bound, &withoutCert); err == nil {
+		t.Errorf("expected error for connection without client certificate")
+	}
+	if err := VerifyCertificateBinding(bound, nil); err == nil {
+		t.Errorf("expected error without TLS connection")
+	}
+	if err := VerifyCertificateBinding(unbound, &withCert); err == nil {
+		t.Errorf("expected error for unbound token")
+	}
+}
+
+// tokenBindingMessage returns a Sec-Token-Binding header with a provided
+// token binding for the connection, and its Token Binding hash.
+func tokenBindingMessage(t *testing.T, priv *ecdsa.PrivateKey, state tls.ConnectionState) (message, tbh string) {
+	t.Helper()
+	ekm, err := state.ExportKeyingMaterial(tokenBindingExporterLabel, nil, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(append([]byte{tokenBindingProvided, tokenBindingECDSAP256}, ekm...))
+	r, s, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	point := elliptic.Marshal(elliptic.P256(), priv.X, priv.Y)
+	key := append([]byte{byte(len(point))}, point...)
+	id := append([]byte{tokenBindingECDSAP256}, binary.BigEndian.AppendUint16(nil, uint16(len(key)))...)
+	id = append(id, key...)
+
+	binding := append([]byte{tokenBindingProvided}, id...)
+	binding = binary.BigEndian.AppendUint16(binding, uint16(len(sig)))
+	binding = append(binding, sig...)
+	binding = binary.BigEndian.AppendUint16(binding, 0)
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(binding)))
+	msg = append(msg, binding...)
+
+	sum := sha256.Sum256(id)
+	return base64.RawURLEncoding.EncodeToString(msg), base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestVerifyTokenBinding(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, server := tlsPipe(t, nil)
+	_, otherServer := tlsPipe(t, nil)
+	message, tbh := tokenBindingMessage(t, priv, client)
+
+	bound := tokenWithClaims(t, fmt.Sprintf(`{"iss":"https://foo","exp":4102444800,"cnf":{"tbh":%q}}`, tbh))
+	if err := VerifyTokenBinding(bound, &server, message); err != nil {
+		t.Errorf("bound token: %v", err)
+	}
+	if err := VerifyTokenBinding(bound, &otherServer, message); err == nil {
+		t.Errorf("expected error for token binding replayed on another connection")
+	}
+	if err := VerifyTokenBinding(bound, &server, message[:len(message)-8]); err == nil {
+		t.Errorf("expected error for truncated token binding message")
+	}
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherMessage, _ := tokenBindingMessage(t, otherKey, client)
+	if err := VerifyTokenBinding(bound, &server, otherMessage); err == nil {
+		t.Errorf("expected error for token bound to another key")
+	}
+
+	unbound := tokenWithClaims(t, `{"iss":"https://foo","exp":4102444800}`)
+	if err := VerifyTokenBinding(unbound, &server, message); err == nil {
+		t.Errorf("expected error for unbound token")
+	}
+}
+
+func TestVerifyChannelBinding(t *testing.T) {
+	clientCert := newTestCertificate(t)
+	key := newRSAKey(t)
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "api"})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name       string
+		required   bool
+		cnf        string
+		wantStatus int
+	}{
+		{"bound", false, fmt.Sprintf(`{"x5t#S256":%q}`, certThumbprint(clientCert)), http.StatusOK},
+		{"bound elsewhere", false, fmt.Sprintf(`{"x5t#S256":%q}`, certThumbprint(newTestCertificate(t))), http.StatusUnauthorized},
+		{"unbound", false, "", http.StatusOK},
+		{"unbound required", true, "", http.StatusUnauthorized},
+		{"bound required", true, fmt.Sprintf(`{"x5t#S256":%q}`, certThumbprint(clientCert)), http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := httptest.NewUnstartedServer(BearerMiddleware(verifier, VerifyChannelBinding(test.required))(ok))
+			s.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+			s.StartTLS()
+			defer s.Close()
+			client := s.Client()
+			client.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{clientCert}
+
+			claims := `{"iss":"https://foo","aud":"api","sub":"jane","exp":4102444800`
+			if test.cnf != "" {
+				claims += `,"cnf":` + test.cnf
+			}
+			req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+			req.Header.Set("Authorization", "Bearer "+key.sign(t, []byte(claims+"}")))
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("expected status %d, got %d", test.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package oidc
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// lru is a cache of values that expire, keyed by the SHA-256 hash of a token so
+// that tokens aren't kept in memory. Once it holds size values, adding one
+// evicts the least recently used. It isn't safe for concurrent use.
+type lru[V any] struct {
+	size    int
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List
+}
+
+type lruEntry[V any] struct {
+	key     [sha256.Size]byte
+	value   V
+	expires time.Time
+}
+
+func newLRU[V any](size int) lru[V] {
+	if size <= 0 {
+		size = 1
+	}
+	return lru[V]{
+		size:    size,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *lru[V]) len() int {
+	return c.order.Len()
+}
+
+// get returns the value of the token if present and unexpired at now, evicting
+// it if it has expired.
+func (c *lru[V]) get(token string, now time.Time) (V, bool) {
+	var zero V
+	key := sha256.Sum256([]byte(token))
+	e, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := e.Value.(*lruEntry[V])
+	if !now.Before(entry.expires) {
+		c.order.Remove(e)
+		delete(c.entries, key)
+		return zero, false
+	}
+	c.order.MoveToFront(e)
+	return entry.value, true
+}
+
+// add records the value of the token until expires.
+func (c *lru[V]) add(token string, value V, expires time.Time) {
+	key := sha256.Sum256([]byte(token))
+	entry := &lruEntry[V]{key: key, value: value, expires: expires}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+// VerificationCache is an LRU cache of successfully verified ID Tokens. When set
+// on a Config, tokens found in the cache are returned without repeating
+// signature and claim validation.
+//
+// Entries are evicted when the token expires, or once they're older than the
+// cache's maximum age, whichever comes first. A cache must only be used with a
+// single Config, since the cached result reflects that Config's checks.
+//
+//	cache := oidc.NewVerificationCache(10000, 5*time.Minute)
+//	verifier := provider.Verifier(&oidc.Config{
+//		ClientID:          clientID,
+//		VerificationCache: cache,
+//	})
+type VerificationCache struct {
+	maxAge time.Duration
+
+	mu     sync.Mutex
+	tokens lru[*IDToken]
+}
+
+// NewVerificationCache returns a cache holding at most size tokens, each for no
+// longer than maxAge. A maxAge of zero only bounds entries by token expiry.
+func NewVerificationCache(size int, maxAge time.Duration) *VerificationCache {
+	return &VerificationCache{maxAge: maxAge, tokens: newLRU[*IDToken](size)}
+}
+
+// Len returns the number of tokens in the cache, including expired tokens that
+// haven't been evicted yet.
+func (c *VerificationCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens.len()
+}
+
+// get returns a copy of the cached token if present and unexpired at now.
+func (c *VerificationCache) get(rawIDToken string, now time.Time) (*IDToken, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tokens.get(rawIDToken, now)
+	if !ok {
+		return nil, false
+	}
+	// Return a copy so callers can't modify the cached value.
+	return cloneIDToken(t), true
+}
+
+// cloneIDToken returns a deep copy of the token, which shares no slices or
+// maps with it.
+func cloneIDToken(t *IDToken) *IDToken {
+	cp := *t
+	cp.Audience = append([]string(nil), t.Audience...)
+	cp.claims = append([]byte(nil), t.claims...)
+	if t.distributedClaims != nil {
+		cp.distributedClaims = make(map[string]claimSource, len(t.distributedClaims))
+		for name, src := range t.distributedClaims {
+			cp.distributedClaims[name] = src
+		}
+	}
+	return &cp
+}
+
+// add records a verified token. Tokens without an expiry are only cached if the
+// cache has a maximum age.
+func (c *VerificationCache) add(rawIDToken string, t *IDToken, now time.Time) {
+	expires := t.Expiry
+	if c.maxAge > 0 && (expires.IsZero() || now.Add(c.maxAge).Before(expires)) {
+		expires = now.Add(c.maxAge)
+	}
+	if !now.Before(expires) {
+		return
+	}
+	cp := cloneIDToken(t)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens.add(rawIDToken, cp, expires)
+}
+
+// IntrospectionCache is an LRU cache of token introspection responses. When set
+// on an IntrospectionConfig, tokens found in the cache are checked against the
+// cached response instead of contacting the introspection endpoint again.
+//
+// Active responses are cached until the token's "exp", but no longer than the
+// cache's maximum age. Responses without an "exp" are cached for the maximum
+// age. Inactive responses are cached for a separate, usually much shorter,
+// duration so that clients replaying revoked or made-up tokens can't force an
+// introspection request for every call.
+//
+// Caching delays the detection of revoked tokens by up to the maximum age. A
+// cache must only be used with a single IntrospectionConfig.
+//
+//	cache := oidc.NewIntrospectionCache(10000, time.Minute, 10*time.Second)
+//	verifier := oidc.NewIntrospectionVerifier(endpoint, &oidc.IntrospectionConfig{
+//		ClientID:     clientID,
+//		ClientSecret: clientSecret,
+//		Cache:        cache,
+//	})
+type IntrospectionCache struct {
+	maxAge      time.Duration
+	inactiveAge time.Duration
+
+	mu        sync.Mutex
+	responses lru[introspectionCacheEntry]
+}
+
+type introspectionCacheEntry struct {
+	body     []byte
+	response *introspectionResponse
+}
+
+// NewIntrospectionCache returns a cache holding at most size responses. Active
+// responses are kept for no longer than maxAge, inactive ones for inactiveAge.
+// An inactiveAge of zero disables caching of inactive responses.
+func NewIntrospectionCache(size int, maxAge, inactiveAge time.Duration) *IntrospectionCache {
+	return &IntrospectionCache{
+		maxAge:      maxAge,
+		inactiveAge: inactiveAge,
+		responses:   newLRU[introspectionCacheEntry](size),
+	}
+}
+
+// Len returns the number of responses in the cache, including expired
+// responses that haven't been evicted yet.
+func (c *IntrospectionCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.responses.len()
+}
+
+// get returns the cached response for a token if present and unexpired at now.
+func (c *IntrospectionCache) get(rawToken string, now time.Time) ([]byte, *introspectionResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.responses.get(rawToken, now)
+	return entry.body, entry.response, ok
+}
+
+// add records an introspection response. Responses are never modified after
+// they've been added, so they're shared with callers of get.
+func (c *IntrospectionCache) add(rawToken string, body []byte, r *introspectionResponse, now time.Time) {
+	var expires time.Time
+	switch {
+	case !r.Active:
+		expires = now.Add(c.inactiveAge)
+	case r.Expiry == nil:
+		expires = now.Add(c.maxAge)
+	default:
+		expires = time.Time(*r.Expiry)
+		if maxExpires := now.Add(c.maxAge); maxExpires.Before(expires) {
+			expires = maxExpires
+		}
+	}
+	if !now.Before(expires) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responses.add(rawToken, introspectionCacheEntry{body: body, response: r}, expires)
+}
+
+// UserInfoCache is an LRU cache of userinfo responses, keyed by a hash of the
+// access token they were requested with. Concurrent requests for the same token
+// are deduplicated, so only one of them contacts the userinfo endpoint and the
+// others wait for its response. Failed requests aren't cached.
+//
+// Responses are cached for the cache's maximum age, even if the access token
+// expires or the user's claims change earlier. Checks such as WithIDToken are
+// applied to cached responses on every call.
+//
+//	cache := oidc.NewUserInfoCache(10000, time.Minute)
+//	provider, err := oidc.NewProvider(ctx, issuer, oidc.WithUserInfoCache(cache))
+type UserInfoCache struct {
+	maxAge time.Duration
+
+	mu        sync.Mutex
+	responses lru[[]byte]
+	calls     map[[sha256.Size]byte]*userInfoCall
+}
+
+// userInfoCall is a userinfo request other callers wait on.
+type userInfoCall struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+// NewUserInfoCache returns a cache holding at most size responses, each for no
+// longer than maxAge.
+func NewUserInfoCache(size int, maxAge time.Duration) *UserInfoCache {
+	return &UserInfoCache{
+		maxAge:    maxAge,
+		responses: newLRU[[]byte](size),
+		calls:     make(map[[sha256.Size]byte]*userInfoCall),
+	}
+}
+
+// Len returns the number of responses in the cache, including expired
+// responses that haven't been evicted yet.
+func (c *UserInfoCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.responses.len()
+}
+
+// userInfoFetchTimeout bounds requests made by UserInfoCache, which aren't
+// bounded by the context of any caller.
+const userInfoFetchTimeout = time.Minute
+
+// fetch returns the cached response for the access token, or calls fetch to
+// request it. The request is shared by callers arriving while it's in flight,
+// so it runs with the values of the first caller's context but isn't canceled
+// with it. Each caller waits for the result until its own context is done.
+func (c *UserInfoCache) fetch(ctx context.Context, accessToken string, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	key := sha256.Sum256([]byte(accessToken))
+
+	c.mu.Lock()
+	if body, ok := c.responses.get(accessToken, clockNow(ctx)); ok {
+		c.mu.Unlock()
+		return body, nil
+	}
+	call, ok := c.calls[key]
+	if !ok {
+		call = &userInfoCall{done: make(chan struct{})}
+		c.calls[key] = call
+		go c.run(detachedContext{ctx}, accessToken, key, call, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.body, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run makes a shared request, caching its response if it succeeds.
+func (c *UserInfoCache) run(ctx context.Context, accessToken string, key [sha256.Size]byte, call *userInfoCall, fetch func(context.Context) ([]byte, error)) {
+	ctx, cancel := context.WithTimeout(ctx, userInfoFetchTimeout)
+	defer cancel()
+	call.body, call.err = fetch(ctx)
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	if call.err == nil && c.maxAge > 0 {
+		c.responses.add(accessToken, call.body, clockNow(ctx).Add(c.maxAge))
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// detachedContext carries the values of a context, such as its HTTP client,
+// but not its deadline or cancelation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
@@ -0,0 +1,259 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type countingKeySet struct {
+	KeySet
+	calls int
+}
+
+func (c *countingKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	c.calls++
+	return c.KeySet.VerifySignature(ctx, jwt)
+}
+
+func TestVerificationCache(t *testing.T) {
+	key := newRSAKey(t)
+	now := time.Date(2022, 1, 29, 0, 0, 0, 0, time.UTC)
+	exp := now.Add(10 * time.Minute)
+	token := key.sign(t, []byte(fmt.Sprintf(`{"iss":"https://foo","aud":"client1","exp":%d}`, exp.Unix())))
+
+	ks := &countingKeySet{KeySet: &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}}
+	cache := NewVerificationCache(10, 5*time.Minute)
+	verifier := NewVerifier("https://foo", ks, &Config{
+		ClientID:          "client1",
+		Now:               func() time.Time { return now },
+		VerificationCache: cache,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := verifier.Verify(ctx, token); err != nil {
+			t.Fatalf("verifying token: %v", err)
+		}
+	}
+	if ks.calls != 1 {
+		t.Errorf("expected 1 signature verification, got %d", ks.calls)
+	}
+
+	// Past the cache's max age, the token is verified again.
+	now = now.Add(6 * time.Minute)
+	if _, err := verifier.Verify(ctx, token); err != nil {
+		t.Fatalf("verifying token: %v", err)
+	}
+	if ks.calls != 2 {
+		t.Errorf("expected 2 signature verifications, got %d", ks.calls)
+	}
+
+	// Past the token's expiry, cached tokens are rejected.
+	now = exp.Add(time.Second)
+	if _, err := verifier.Verify(ctx, token); err == nil {
+		t.Errorf("expected expired token to be rejected")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected expired token to be evicted, cache has %d entries", cache.Len())
+	}
+
+	// Failures aren't cached.
+	bad := newRSAKey(t).sign(t, []byte(fmt.Sprintf(`{"iss":"https://foo","aud":"client1","exp":%d}`, now.Add(time.Hour).Unix())))
+	for i := 0; i < 2; i++ {
+		if _, err := verifier.Verify(ctx, bad); err == nil {
+			t.Fatalf("expected invalid signature to be rejected")
+		}
+	}
+}
+
+func TestVerificationCacheCopies(t *testing.T) {
+	now := time.Now()
+	cache := NewVerificationCache(1, 0)
+	cache.add("a", &IDToken{
+		Expiry:            now.Add(time.Hour),
+		Audience:          []string{"client1"},
+		claims:            []byte(`{"sub":"jane"}`),
+		distributedClaims: map[string]claimSource{"groups": {Endpoint: "https://foo/groups"}},
+	}, now)
+
+	got, ok := cache.get("a", now)
+	if !ok {
+		t.Fatalf("expected token to be cached")
+	}
+	got.Audience[0] = "client2"
+	got.claims[2] = 'x'
+	got.distributedClaims["groups"] = claimSource{Endpoint: "https://evil"}
+
+	got, _ = cache.get("a", now)
+	if got.Audience[0] != "client1" || string(got.claims) != `{"sub":"jane"}` || got.distributedClaims["groups"].Endpoint != "https://foo/groups" {
+		t.Errorf("cached token was modified through a returned copy: %+v", got)
+	}
+}
+
+func TestVerificationCacheEviction(t *testing.T) {
+	now := time.Now()
+	cache := NewVerificationCache(2, 0)
+	tok := &IDToken{Expiry: now.Add(time.Hour)}
+
+	cache.add("a", tok, now)
+	cache.add("b", tok, now)
+	if _, ok := cache.get("a", now); !ok {
+		t.Fatalf("expected token a to be cached")
+	}
+	// "b" is now the least recently used.
+	cache.add("c", tok, now)
+
+	if _, ok := cache.get("b", now); ok {
+		t.Errorf("expected token b to be evicted")
+	}
+	for _, raw := range []string{"a", "c"} {
+		if _, ok := cache.get(raw, now); !ok {
+			t.Errorf("expected token %s to be cached", raw)
+		}
+	}
+
+	// Tokens without an expiry need a max age to be cached.
+	cache.add("d", &IDToken{}, now)
+	if _, ok := cache.get("d", now); ok {
+		t.Errorf("expected token without expiry not to be cached")
+	}
+}
+
+func TestUserInfoCache(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("Authorization") == "Bearer bad" {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		// Give concurrent callers time to pile up behind this request.
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"sub":%q}`, r.Header.Get("Authorization"))
+	}))
+	defer s.Close()
+
+	now := time.Unix(1700000000, 0)
+	var mu sync.Mutex
+	ctx := ClockContext(context.Background(), func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+	cache := NewUserInfoCache(10, time.Minute)
+	p := (&ProviderConfig{IssuerURL: "https://foo", UserInfoURL: s.URL}).NewProvider(ctx, WithUserInfoCache(cache))
+	userInfo := func(accessToken string) (*UserInfo, error) {
+		return p.UserInfo(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken, TokenType: "Bearer"}))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, err := userInfo("a")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if u.Subject != "Bearer a" {
+				t.Errorf("got subject %q", u.Subject)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("got %d requests for concurrent calls, want 1", n)
+	}
+
+	if _, err := userInfo("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := userInfo("b"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+	if _, err := p.UserInfo(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "a"}), WithIDToken(&IDToken{Subject: "other"})); err == nil {
+		t.Error("expected subject mismatch for a cached response")
+	}
+
+	// Errors aren't cached.
+	for i := 0; i < 2; i++ {
+		if _, err := userInfo("bad"); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 4 {
+		t.Errorf("got %d requests after failures, want 4", n)
+	}
+
+	mu.Lock()
+	now = now.Add(time.Minute)
+	mu.Unlock()
+	if _, err := userInfo("a"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 5 {
+		t.Errorf("got %d requests after expiry, want 5", n)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("got %d cached responses, want 2", cache.Len())
+	}
+}
+
+func TestUserInfoCacheDetached(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"sub":"jane"}`))
+	}))
+	defer s.Close()
+
+	cache := NewUserInfoCache(10, time.Minute)
+	p := (&ProviderConfig{IssuerURL: "https://foo", UserInfoURL: s.URL}).NewProvider(context.Background(), WithUserInfoCache(cache))
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "a", TokenType: "Bearer"})
+
+	// The first caller gives up, which doesn't cancel the request others
+	// are waiting on.
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := p.UserInfo(first, tokenSource)
+		firstErr <- err
+	}()
+	for atomic.LoadInt32(&requests) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	secondErr := make(chan error, 1)
+	go func() {
+		_, err := p.UserInfo(context.Background(), tokenSource)
+		secondErr <- err
+	}()
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected first caller to stop waiting, got %v", err)
+	}
+	close(release)
+	if err := <-secondErr; err != nil {
+		t.Errorf("expected shared request to succeed, got %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
@@ -0,0 +1,145 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// maxFormPostSize bounds the body of form_post authorization responses.
+const maxFormPostSize = 1 << 20
+
+// AuthorizationResponse holds the parameters returned from the authorization
+// endpoint. Which fields are set depends on the response type of the request.
+type AuthorizationResponse struct {
+	Code        string
+	State       string
+	IDToken     string
+	AccessToken string
+	TokenType   string
+	// Issuer is the iss parameter, returned by providers that implement
+	// RFC 9207 to defend against mix-up attacks.
+	Issuer string
+	// SessionState is the session_state parameter, returned by providers that
+	// support OpenID Connect Session Management. See CheckSessionMessage.
+	SessionState string
+
+	// Values holds all returned parameters, including ones not listed above.
+	Values url.Values
+}
+
+// ParseAuthorizationResponse parses the authorization response delivered to the
+// client's redirect URI. Both the default query response mode and the form_post
+// response mode, where the provider has the browser POST the parameters as an
+// HTML form, are supported.
+//
+// Error responses are returned as an *AuthorizationError. The state value still
+// must be compared to the one sent with the request.
+//
+//	http.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+//		resp, err := oidc.ParseAuthorizationResponse(r)
+//		if err != nil {
+//			// handle error
+//		}
+//		if resp.State != expectedState {
+//			// reject the response
+//		}
+//		oauth2Token, err := oauth2Config.Exchange(ctx, resp.Code)
+//		...
+//	})
+func ParseAuthorizationResponse(r *http.Request) (*AuthorizationResponse, error) {
+	switch r.Method {
+	case http.MethodGet:
+		return ParseAuthorizationResponseValues(r.URL.Query())
+	case http.MethodPost:
+		ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || ct != "application/x-www-form-urlencoded" {
+			return nil, fmt.Errorf("oidc: unexpected content type for form_post response: %q", r.Header.Get("Content-Type"))
+		}
+		r.Body = http.MaxBytesReader(nil, r.Body, maxFormPostSize)
+		if err := r.ParseForm(); err != nil {
+			return nil, fmt.Errorf("oidc: failed to parse form_post response: %v", err)
+		}
+		// Only consider the body so query parameters on the redirect URI
+		// can't be confused with the response.
+		return ParseAuthorizationResponseValues(r.PostForm)
+	default:
+		return nil, fmt.Errorf("oidc: unexpected method for authorization response: %s", r.Method)
+	}
+}
+
+// ParseAuthorizationResponseValues parses authorization response parameters that
+// have already been decoded, such as a fragment response forwarded to the server
+// by a script running in the browser.
+func ParseAuthorizationResponseValues(v url.Values) (*AuthorizationResponse, error) {
+	if code := v.Get("error"); code != "" {
+		return nil, &AuthorizationError{
+			Code:        code,
+			Description: v.Get("error_description"),
+			URI:         v.Get("error_uri"),
+			State:       v.Get("state"),
+		}
+	}
+	resp := &AuthorizationResponse{
+		Code:         v.Get("code"),
+		State:        v.Get("state"),
+		IDToken:      v.Get("id_token"),
+		AccessToken:  v.Get("access_token"),
+		TokenType:    v.Get("token_type"),
+		Issuer:       v.Get("iss"),
+		SessionState: v.Get("session_state"),
+		Values:       v,
+	}
+	if resp.Code == "" && resp.IDToken == "" && resp.AccessToken == "" {
+		return nil, errors.New("oidc: authorization response contains no code or tokens")
+	}
+	return resp, nil
+}
+
+// VerifyAuthorizationResponse verifies the ID Token returned directly from the
+// authorization endpoint in the implicit and hybrid flows, and binds the other
+// values of the response to it. In one call it:
+//
+//   - verifies the ID Token as Verify does
+//   - checks the nonce claim matches the nonce sent with the request
+//   - if the response contains a code, checks it against the c_hash claim
+//   - if the response contains an access token, checks it against the at_hash claim
+//
+// The c_hash and at_hash claims are required when the corresponding values are
+// returned. The state value must still be checked by the caller.
+//
+//	resp, err := oidc.ParseAuthorizationResponse(r)
+//	if err != nil {
+//		// handle error
+//	}
+//	idToken, err := verifier.VerifyAuthorizationResponse(ctx, resp, nonce)
+//	if err != nil {
+//		// handle error
+//	}
+//	// resp.Code can now be exchanged for tokens.
+func (v *IDTokenVerifier) VerifyAuthorizationResponse(ctx context.Context, resp *AuthorizationResponse, nonce string) (*IDToken, error) {
+	if resp.IDToken == "" {
+		return nil, errors.New("oidc: authorization response doesn't contain an id token")
+	}
+	if nonce == "" {
+		return nil, errors.New("oidc: nonce is required to verify an authorization response")
+	}
+	idToken, err := v.Verify(ctx, resp.IDToken, WithNonce(nonce))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Code != "" {
+		if err := idToken.VerifyCode(resp.Code); err != nil {
+			return nil, fmt.Errorf("oidc: %v", err)
+		}
+	}
+	if resp.AccessToken != "" {
+		if err := idToken.VerifyAccessToken(resp.AccessToken); err != nil {
+			return nil, fmt.Errorf("oidc: %v", err)
+		}
+	}
+	return idToken, nil
+}
@@ -0,0 +1,228 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAuthorizationResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     func() *http.Request
+		want    *AuthorizationResponse
+		wantErr *AuthorizationError
+		fail    bool
+	}{
+		{
+			name: "query",
+			req: func() *http.Request {
+				return httptest.NewRequest("GET", "/callback?code=abc&state=xyz&iss=https%3A%2F%2Fop", nil)
+			},
+			want: &AuthorizationResponse{Code: "abc", State: "xyz", Issuer: "https://op"},
+		},
+		{
+			name: "form_post",
+			req: func() *http.Request {
+				r := httptest.NewRequest("POST", "/callback?code=ignored", strings.NewReader("code=abc&state=xyz&id_token=a.b.c"))
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+				return r
+			},
+			want: &AuthorizationResponse{Code: "abc", State: "xyz", IDToken: "a.b.c"},
+		},
+		{
+			name: "form_post error",
+			req: func() *http.Request {
+				r := httptest.NewRequest("POST", "/callback", strings.NewReader("error=login_required&error_description=Login+required&state=xyz"))
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				return r
+			},
+			wantErr: &AuthorizationError{Code: "login_required", Description: "Login required", State: "xyz"},
+		},
+		{
+			name: "query error",
+			req: func() *http.Request {
+				return httptest.NewRequest("GET", "/callback?error=access_denied&error_uri=https%3A%2F%2Fop%2Fdocs", nil)
+			},
+			wantErr: &AuthorizationError{Code: "access_denied", URI: "https://op/docs"},
+		},
+		{
+			name: "wrong content type",
+			req: func() *http.Request {
+				r := httptest.NewRequest("POST", "/callback", strings.NewReader(`{"code":"abc"}`))
+				r.Header.Set("Content-Type", "application/json")
+				return r
+			},
+			fail: true,
+		},
+		{
+			name: "body too large",
+			req: func() *http.Request {
+				r := httptest.NewRequest("POST", "/callback", strings.NewReader("code="+strings.Repeat("a", maxFormPostSize)))
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				return r
+			},
+			fail: true,
+		},
+		{
+			name: "empty",
+			req: func() *http.Request {
+				return httptest.NewRequest("GET", "/callback?state=xyz", nil)
+			},
+			fail: true,
+		},
+		{
+			name: "unexpected method",
+			req: func() *http.Request {
+				return httptest.NewRequest("PUT", "/callback?code=abc", nil)
+			},
+			fail: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseAuthorizationResponse(test.req())
+			switch {
+			case test.wantErr != nil:
+				var authErr *AuthorizationError
+				if !errors.As(err, &authErr) {
+					t.Fatalf("expected *AuthorizationError, got %v", err)
+				}
+				if *authErr != *test.wantErr {
+					t.Errorf("unexpected error, got=%+v, want=%+v", authErr, test.wantErr)
+				}
+			case test.fail:
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+			default:
+				if err != nil {
+					t.Fatalf("parsing response: %v", err)
+				}
+				got.Values = nil
+				if !reflect.DeepEqual(got, test.want) {
+					t.Errorf("unexpected response, got=%+v, want=%+v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyAuthorizationResponse(t *testing.T) {
+	key := newRSAKey(t)
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		ClientID:        "client1",
+		SkipExpiryCheck: true,
+	})
+
+	code, accessToken := "Qcb0Orv1zh30vL1MPRsbm-diHiMwcLyZvn1arpZv-Jxf_11jnpEX3Tgfvk", "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y"
+	codeHash, err := tokenHash(RS256, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	atHash, err := tokenHash(RS256, accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newIDToken := func(claims string) string {
+		return key.sign(t, []byte(fmt.Sprintf(`{"iss":"https://foo","aud":"client1","sub":"user",%s}`, claims)))
+	}
+
+	tests := []struct {
+		name    string
+		resp    *AuthorizationResponse
+		nonce   string
+		wantErr bool
+	}{
+		{
+			name: "code id_token",
+			resp: &AuthorizationResponse{
+				Code:    code,
+				IDToken: newIDToken(fmt.Sprintf(`"nonce":"n","c_hash":%q`, codeHash)),
+			},
+			nonce: "n",
+		},
+		{
+			name: "code id_token token",
+			resp: &AuthorizationResponse{
+				Code:        code,
+				AccessToken: accessToken,
+				IDToken:     newIDToken(fmt.Sprintf(`"nonce":"n","c_hash":%q,"at_hash":%q`, codeHash, atHash)),
+			},
+			nonce: "n",
+		},
+		{
+			name: "id_token token",
+			resp: &AuthorizationResponse{
+				AccessToken: accessToken,
+				IDToken:     newIDToken(fmt.Sprintf(`"nonce":"n","at_hash":%q`, atHash)),
+			},
+			nonce: "n",
+		},
+		{
+			name: "missing c_hash",
+			resp: &AuthorizationResponse{
+				Code:    code,
+				IDToken: newIDToken(`"nonce":"n"`),
+			},
+			nonce:   "n",
+			wantErr: true,
+		},
+		{
+			name: "substituted code",
+			resp: &AuthorizationResponse{
+				Code:    "other",
+				IDToken: newIDToken(fmt.Sprintf(`"nonce":"n","c_hash":%q`, codeHash)),
+			},
+			nonce:   "n",
+			wantErr: true,
+		},
+		{
+			name: "missing at_hash",
+			resp: &AuthorizationResponse{
+				AccessToken: accessToken,
+				IDToken:     newIDToken(`"nonce":"n"`),
+			},
+			nonce:   "n",
+			wantErr: true,
+		},
+		{
+			name: "wrong nonce",
+			resp: &AuthorizationResponse{
+				IDToken: newIDToken(`"nonce":"n"`),
+			},
+			nonce:   "other",
+			wantErr: true,
+		},
+		{
+			name: "no nonce",
+			resp: &AuthorizationResponse{
+				IDToken: newIDToken(`"nonce":""`),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no id token",
+			resp:    &AuthorizationResponse{Code: code},
+			nonce:   "n",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := verifier.VerifyAuthorizationResponse(context.Background(), test.resp, test.nonce)
+			if err != nil && !test.wantErr {
+				t.Fatalf("verifying response: %v", err)
+			}
+			if err == nil && test.wantErr {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
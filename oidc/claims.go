@@ -0,0 +1,202 @@
+package oidc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StringOrArray is a claim holding a list of strings, which providers encode
+// either as a JSON array or, when it has a single value, as a plain string.
+// Both decode to a slice, so structs reading claims such as "aud", "amr" or
+// "groups" don't break when a provider switches between the two forms.
+//
+//	var claims struct {
+//		Groups oidc.StringOrArray `json:"groups"`
+//	}
+//
+// To decode a claim this way wherever claims are decoded into a map, register
+// it:
+//
+//	oidc.RegisterClaimType[oidc.StringOrArray]("groups")
+//
+// A null value decodes as an empty list. It's always encoded as an array.
+type StringOrArray []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *StringOrArray) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		*s = nil
+		return nil
+	}
+	var single string
+	if json.Unmarshal(b, &single) == nil {
+		*s = StringOrArray{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// StandardClaims holds the standard claims defined by OpenID Connect. Claims not
+// returned by the provider are left as zero values.
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+type StandardClaims struct {
+	Subject           string
+	Name              string
+	GivenName         string
+	FamilyName        string
+	MiddleName        string
+	Nickname          string
+	PreferredUsername string
+	Profile           string
+	Picture           string
+	Website           string
+
+	Email         string
+	EmailVerified bool
+
+	Gender    string
+	Birthdate string
+	Zoneinfo  string
+	Locale    string
+
+	PhoneNumber         string
+	PhoneNumberVerified bool
+
+	// Address is nil if the address claim wasn't returned.
+	Address *AddressClaim
+
+	// UpdatedAt is the time the user's information was last updated.
+	UpdatedAt time.Time
+}
+
+// AddressClaim is the structured address claim defined by OpenID Connect. Read
+// it with IDToken.Address or UserInfo.Address.
+//
+// Some providers return the address as a plain string rather than an object.
+// It's decoded into Formatted.
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#AddressClaim
+type AddressClaim struct {
+	// Formatted is the full mailing address, which may span multiple lines
+	// separated by newlines.
+	Formatted string `json:"formatted,omitempty"`
+	// StreetAddress is the full street address, which may include the house
+	// number, street name, post office box, and multi-line extended street
+	// address information, separated by newlines.
+	StreetAddress string `json:"street_address,omitempty"`
+	// Locality is the city or locality.
+	Locality string `json:"locality,omitempty"`
+	// Region is the state, province, prefecture, or region.
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+	// Country is the country name.
+	Country string `json:"country,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *AddressClaim) UnmarshalJSON(b []byte) error {
+	var formatted string
+	if json.Unmarshal(b, &formatted) == nil {
+		*a = AddressClaim{Formatted: formatted}
+		return nil
+	}
+	type address AddressClaim
+	return json.Unmarshal(b, (*address)(a))
+}
+
+// addressClaim decodes the address claim of a payload. It's nil if the claim
+// is absent or null.
+func addressClaim(claims []byte) (*AddressClaim, error) {
+	var v struct {
+		Address *AddressClaim `json:"address"`
+	}
+	if err := json.Unmarshal(claims, &v); err != nil {
+		return nil, fmt.Errorf("oidc: malformed address claim: %v", err)
+	}
+	return v.Address, nil
+}
+
+type standardClaimsRaw struct {
+	Subject           string `json:"sub"`
+	Name              string `json:"name"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+	MiddleName        string `json:"middle_name"`
+	Nickname          string `json:"nickname"`
+	PreferredUsername string `json:"preferred_username"`
+	Profile           string `json:"profile"`
+	Picture           string `json:"picture"`
+	Website           string `json:"website"`
+
+	Email         string      `json:"email"`
+	EmailVerified LenientBool `json:"email_verified"`
+
+	Gender    string `json:"gender"`
+	Birthdate string `json:"birthdate"`
+	Zoneinfo  string `json:"zoneinfo"`
+	Locale    string `json:"locale"`
+
+	PhoneNumber         string      `json:"phone_number"`
+	PhoneNumberVerified LenientBool `json:"phone_number_verified"`
+
+	Address   *AddressClaim `json:"address"`
+	UpdatedAt *jsonTime     `json:"updated_at"`
+}
+
+func parseStandardClaims(b []byte) (*StandardClaims, error) {
+	var raw standardClaimsRaw
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	c := &StandardClaims{
+		Subject:             raw.Subject,
+		Name:                raw.Name,
+		GivenName:           raw.GivenName,
+		FamilyName:          raw.FamilyName,
+		MiddleName:          raw.MiddleName,
+		Nickname:            raw.Nickname,
+		PreferredUsername:   raw.PreferredUsername,
+		Profile:             raw.Profile,
+		Picture:             raw.Picture,
+		Website:             raw.Website,
+		Email:               raw.Email,
+		EmailVerified:       bool(raw.EmailVerified),
+		Gender:              raw.Gender,
+		Birthdate:           raw.Birthdate,
+		Zoneinfo:            raw.Zoneinfo,
+		Locale:              raw.Locale,
+		PhoneNumber:         raw.PhoneNumber,
+		PhoneNumberVerified: bool(raw.PhoneNumberVerified),
+		Address:             raw.Address,
+	}
+	if raw.UpdatedAt != nil {
+		c.UpdatedAt = time.Time(*raw.UpdatedAt)
+	}
+	return c, nil
+}
+
+// Address returns the address claim of the token, or nil if the token has none.
+func (i *IDToken) Address() (*AddressClaim, error) {
+	if i.claims == nil {
+		return nil, errors.New("oidc: claims not set")
+	}
+	return addressClaim(i.claims)
+}
+
+// Address returns the address claim returned by the userinfo endpoint, or nil
+// if it returned none.
+func (u *UserInfo) Address() (*AddressClaim, error) {
+	if u.claims == nil {
+		return nil, errors.New("oidc: claims not set")
+	}
+	return addressClaim(u.claims)
+}
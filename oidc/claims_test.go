@@ -0,0 +1,84 @@
+package oidc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStringOrArray(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    StringOrArray
+		wantErr bool
+	}{
+		{json: `"a"`, want: StringOrArray{"a"}},
+		{json: `["a","b"]`, want: StringOrArray{"a", "b"}},
+		{json: `[]`, want: StringOrArray{}},
+		{json: `null`, want: nil},
+		{json: `1`, wantErr: true},
+		{json: `[1]`, wantErr: true},
+	}
+	for _, test := range tests {
+		var got StringOrArray
+		err := json.Unmarshal([]byte(test.json), &got)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %v", test.json, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.json, got, test.want)
+		}
+	}
+	if b, _ := json.Marshal(StringOrArray{"a"}); string(b) != `["a"]` {
+		t.Errorf("got encoding %s", b)
+	}
+}
+
+func TestStringListClaims(t *testing.T) {
+	token := &IDToken{claims: []byte(`{"amr":"pwd","groups":["a","b"]}`)}
+	if amr, err := token.AuthenticationMethods(); err != nil || !reflect.DeepEqual(amr, []string{"pwd"}) {
+		t.Errorf("amr: got %v, %v", amr, err)
+	}
+	if groups, err := token.Groups(); err != nil || !reflect.DeepEqual(groups, []string{"a", "b"}) {
+		t.Errorf("groups: got %v, %v", groups, err)
+	}
+
+	token = &IDToken{claims: []byte(`{"groups":{"a":1}}`)}
+	if amr, err := token.AuthenticationMethods(); err != nil || amr != nil {
+		t.Errorf("absent amr: got %v, %v", amr, err)
+	}
+	if _, err := token.Groups(); err == nil {
+		t.Error("expected error for malformed groups")
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		claims string
+		want   *AddressClaim
+	}{
+		{claims: `{"address":{"street_address":"1 Main St","locality":"Springfield","country":"US"}}`, want: &AddressClaim{StreetAddress: "1 Main St", Locality: "Springfield", Country: "US"}},
+		{claims: `{"address":"1 Main St\nSpringfield"}`, want: &AddressClaim{Formatted: "1 Main St\nSpringfield"}},
+		{claims: `{"address":null}`},
+		{claims: `{}`},
+	}
+	for _, test := range tests {
+		token := &IDToken{claims: []byte(test.claims)}
+		got, err := token.Address()
+		if err != nil {
+			t.Errorf("%s: %v", test.claims, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.claims, got, test.want)
+		}
+		userInfo := &UserInfo{claims: []byte(test.claims)}
+		if got, _ := userInfo.Address(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: userinfo got %+v, want %+v", test.claims, got, test.want)
+		}
+	}
+	if _, err := (&IDToken{claims: []byte(`{"address":1}`)}).Address(); err == nil {
+		t.Error("expected error for malformed address")
+	}
+}
@@ -0,0 +1,112 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ClaimsRequest is the value of the claims request parameter, used to request
+// individual claims be returned from the UserInfo endpoint or in the ID Token.
+// Pass it to AuthURLBuilder.Claims to include it in an authorization request.
+//
+//	req := &oidc.ClaimsRequest{}
+//	req.AddUserInfo("email", oidc.EssentialClaim())
+//	req.AddIDToken("acr", &oidc.ClaimRequest{Values: []interface{}{"urn:mace:incommon:iap:silver"}})
+//	authURL, err := provider.AuthURLBuilder(&oauth2Config).Claims(req).AuthCodeURL(state)
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#ClaimsParameter
+type ClaimsRequest struct {
+	UserInfo map[string]*ClaimRequest `json:"userinfo,omitempty"`
+	IDToken  map[string]*ClaimRequest `json:"id_token,omitempty"`
+}
+
+// ClaimRequest holds the requirements for an individual claim. A nil
+// ClaimRequest requests the claim in the default manner, and is serialized as
+// null.
+type ClaimRequest struct {
+	// Essential indicates the claim is needed for the authorization the
+	// client is requesting.
+	Essential bool `json:"essential,omitempty"`
+	// Value requests the claim be returned with a particular value.
+	Value interface{} `json:"value,omitempty"`
+	// Values requests the claim be returned with one of a set of values, in
+	// order of preference.
+	Values []interface{} `json:"values,omitempty"`
+}
+
+// EssentialClaim returns a request marking the claim as essential.
+func EssentialClaim() *ClaimRequest {
+	return &ClaimRequest{Essential: true}
+}
+
+// AddUserInfo requests a claim be returned from the UserInfo endpoint. req may
+// be nil.
+func (r *ClaimsRequest) AddUserInfo(name string, req *ClaimRequest) *ClaimsRequest {
+	if r.UserInfo == nil {
+		r.UserInfo = make(map[string]*ClaimRequest)
+	}
+	r.UserInfo[name] = req
+	return r
+}
+
+// AddIDToken requests a claim be returned in the ID Token. req may be nil.
+func (r *ClaimsRequest) AddIDToken(name string, req *ClaimRequest) *ClaimsRequest {
+	if r.IDToken == nil {
+		r.IDToken = make(map[string]*ClaimRequest)
+	}
+	r.IDToken[name] = req
+	return r
+}
+
+// ClaimsResult reports which requested claims the provider returned. Providers
+// are free to ignore claim requests, including essential ones. Names are sorted.
+type ClaimsResult struct {
+	Returned []string
+	Missing  []string
+	// MissingEssential holds the subset of Missing that were requested as
+	// essential.
+	MissingEssential []string
+}
+
+// CheckIDToken reports which of the claims requested in the ID Token section
+// are present in the token.
+func (r *ClaimsRequest) CheckIDToken(t *IDToken) (*ClaimsResult, error) {
+	if t.claims == nil {
+		return nil, errors.New("oidc: claims not set")
+	}
+	return checkRequestedClaims(r.IDToken, t.claims)
+}
+
+// CheckUserInfo reports which of the claims requested in the UserInfo section
+// are present in the UserInfo response.
+func (r *ClaimsRequest) CheckUserInfo(u *UserInfo) (*ClaimsResult, error) {
+	if u.claims == nil {
+		return nil, errors.New("oidc: claims not set")
+	}
+	return checkRequestedClaims(r.UserInfo, u.claims)
+}
+
+func checkRequestedClaims(requested map[string]*ClaimRequest, b []byte) (*ClaimsResult, error) {
+	var claims map[string]json.RawMessage
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode claims: %v", err)
+	}
+	result := &ClaimsResult{}
+	for name, req := range requested {
+		// Providers may return null for claims they don't have a value for.
+		if v, ok := claims[name]; ok && string(v) != "null" {
+			result.Returned = append(result.Returned, name)
+			continue
+		}
+		result.Missing = append(result.Missing, name)
+		if req != nil && req.Essential {
+			result.MissingEssential = append(result.MissingEssential, name)
+		}
+	}
+	sort.Strings(result.Returned)
+	sort.Strings(result.Missing)
+	sort.Strings(result.MissingEssential)
+	return result, nil
+}
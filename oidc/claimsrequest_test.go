@@ -0,0 +1,62 @@
+package oidc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestClaimsRequestJSON(t *testing.T) {
+	req := &ClaimsRequest{}
+	req.AddUserInfo("email", EssentialClaim()).
+		AddUserInfo("picture", nil).
+		AddIDToken("acr", &ClaimRequest{Values: []interface{}{"urn:a", "urn:b"}}).
+		AddIDToken("sub", &ClaimRequest{Value: "248289761001"})
+
+	got, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"userinfo":{"email":{"essential":true},"picture":null},` +
+		`"id_token":{"acr":{"values":["urn:a","urn:b"]},"sub":{"value":"248289761001"}}}`
+	if string(got) != want {
+		t.Errorf("unexpected json, got=%s, want=%s", got, want)
+	}
+}
+
+func TestClaimsRequestCheck(t *testing.T) {
+	req := &ClaimsRequest{}
+	req.AddUserInfo("email", EssentialClaim()).
+		AddUserInfo("picture", nil).
+		AddUserInfo("phone_number", EssentialClaim()).
+		AddUserInfo("locale", nil).
+		AddIDToken("auth_time", EssentialClaim())
+
+	userInfo := &UserInfo{claims: []byte(`{"sub":"1","email":"a@example.com","picture":null,"locale":"en"}`)}
+	got, err := req.CheckUserInfo(userInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ClaimsResult{
+		Returned:         []string{"email", "locale"},
+		Missing:          []string{"phone_number", "picture"},
+		MissingEssential: []string{"phone_number"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected userinfo result, got=%+v, want=%+v", got, want)
+	}
+
+	idToken := &IDToken{claims: []byte(`{"sub":"1","auth_time":1643414400}`)}
+	got, err = req.CheckIDToken(idToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = &ClaimsResult{Returned: []string{"auth_time"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected id token result, got=%+v, want=%+v", got, want)
+	}
+
+	if _, err := req.CheckIDToken(&IDToken{}); err == nil {
+		t.Errorf("expected error for token without claims")
+	}
+}
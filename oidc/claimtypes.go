@@ -0,0 +1,83 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// claimTypes holds the decoders registered with RegisterClaimType.
+var claimTypes = struct {
+	sync.RWMutex
+	decoders map[string]func(raw json.RawMessage, useNumber bool) (interface{}, error)
+}{decoders: make(map[string]func(json.RawMessage, bool) (interface{}, error))}
+
+// RegisterClaimType decodes the named claim as a T wherever claims are decoded
+// into a map[string]interface{} or an interface{}, by IDToken.Claims,
+// UserInfo.Claims and the other Claims methods of this package. Claims decoded
+// into structs use the types of their fields instead.
+//
+// Registering a claim once, typically in an init function, replaces the type
+// assertions on generic JSON values otherwise repeated wherever claims are
+// read:
+//
+//	func init() {
+//		oidc.RegisterClaimType[oidc.AddressClaim]("address")
+//	}
+//
+//	var claims map[string]interface{}
+//	if err := idToken.Claims(&claims); err != nil {
+//		// handle error
+//	}
+//	address, ok := claims["address"].(oidc.AddressClaim)
+//
+// T may implement json.Unmarshaler to accept encodings providers disagree on.
+// Claims that fail to decode as their registered type cause Claims to fail.
+// Registering a name again replaces its type.
+func RegisterClaimType[T any](name string) {
+	claimTypes.Lock()
+	defer claimTypes.Unlock()
+	claimTypes.decoders[name] = func(raw json.RawMessage, useNumber bool) (interface{}, error) {
+		var v T
+		if err := decodeJSON(raw, &v, useNumber); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// decodeRegisteredClaims replaces the values of registered claims in a generic
+// map of claims with values of their registered types.
+func decodeRegisteredClaims(b []byte, v interface{}, useNumber bool) error {
+	var claims map[string]interface{}
+	switch v := v.(type) {
+	case *map[string]interface{}:
+		claims = *v
+	case *interface{}:
+		claims, _ = (*v).(map[string]interface{})
+	}
+	if claims == nil {
+		return nil
+	}
+
+	claimTypes.RLock()
+	defer claimTypes.RUnlock()
+	if len(claimTypes.decoders) == 0 {
+		return nil
+	}
+	var err error
+	rangeClaims(b, func(name string, raw json.RawMessage) bool {
+		decode, ok := claimTypes.decoders[name]
+		if !ok {
+			return true
+		}
+		value, decodeErr := decode(raw, useNumber)
+		if decodeErr != nil {
+			err = fmt.Errorf("oidc: decoding claim %q: %v", name, decodeErr)
+			return false
+		}
+		claims[name] = value
+		return true
+	})
+	return err
+}
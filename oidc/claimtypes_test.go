@@ -0,0 +1,69 @@
+package oidc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// registerTestClaimType registers a claim type for the duration of a test.
+func registerTestClaimType[T any](t *testing.T, name string) {
+	RegisterClaimType[T](name)
+	t.Cleanup(func() {
+		claimTypes.Lock()
+		delete(claimTypes.decoders, name)
+		claimTypes.Unlock()
+	})
+}
+
+func TestRegisterClaimType(t *testing.T) {
+	registerTestClaimType[AddressClaim](t, "address")
+	registerTestClaimType[[]string](t, "roles")
+
+	token := &IDToken{claims: []byte(`{"sub":"jane","address":{"locality":"Springfield","country":"US"},"roles":["a","b"],"n":12345678901234567890}`)}
+
+	var claims map[string]interface{}
+	if err := token.Claims(&claims); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := claims["address"].(AddressClaim); !ok || got.Locality != "Springfield" {
+		t.Errorf("address: got %#v", claims["address"])
+	}
+	if got, ok := claims["roles"].([]string); !ok || !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("roles: got %#v", claims["roles"])
+	}
+	if _, ok := claims["n"].(float64); !ok {
+		t.Errorf("unregistered claim: got %#v", claims["n"])
+	}
+	claims = nil
+	if err := token.ClaimsUseNumber(&claims); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := claims["address"].(AddressClaim); !ok {
+		t.Errorf("address with ClaimsUseNumber: got %#v", claims["address"])
+	}
+	if _, ok := claims["n"].(json.Number); !ok {
+		t.Errorf("unregistered claim with ClaimsUseNumber: got %#v", claims["n"])
+	}
+
+	var generic interface{}
+	if err := token.Claims(&generic); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := generic.(map[string]interface{})["address"].(AddressClaim); !ok {
+		t.Errorf("interface{}: got %#v", generic)
+	}
+
+	// Structs use the types of their fields.
+	var s struct {
+		Roles []interface{} `json:"roles"`
+	}
+	if err := token.Claims(&s); err != nil || len(s.Roles) != 2 {
+		t.Errorf("struct: got %v, %v", s, err)
+	}
+
+	bad := &IDToken{claims: []byte(`{"roles":"a"}`)}
+	if err := bad.Claims(&claims); err == nil {
+		t.Error("expected error for claim not matching its registered type")
+	}
+}
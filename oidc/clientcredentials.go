@@ -0,0 +1,288 @@
+package oidc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// Client authentication methods of the token endpoint, as listed by the
+// token_endpoint_auth_methods_supported provider metadata.
+const (
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodPrivateKeyJWT     = "private_key_jwt"
+	AuthMethodTLSClientAuth     = "tls_client_auth"
+	AuthMethodNone              = "none"
+)
+
+// ClientAuth holds the credentials a client uses to authenticate to the token
+// endpoint. ClientID is always required, and at most one of ClientSecret,
+// Signer and Certificate may be set.
+type ClientAuth struct {
+	ClientID string
+
+	// ClientSecret authenticates with client_secret_basic or
+	// client_secret_post. See AuthMethod.
+	ClientSecret string
+	// AuthMethod is AuthMethodClientSecretBasic or AuthMethodClientSecretPost,
+	// to choose how ClientSecret is sent. By default it's chosen from the
+	// methods the provider supports, as reported by
+	// Provider.ClientAuthMethod.
+	AuthMethod string
+	// Signer authenticates with private_key_jwt. A new client assertion is
+	// signed for every token request.
+	Signer Signer
+	// Certificate authenticates with mutual TLS, as described by RFC 8705. It's
+	// presented by the TLS connection to the token endpoint.
+	Certificate *tls.Certificate
+}
+
+func (a *ClientAuth) validate() error {
+	if a.ClientID == "" {
+		return errors.New("oidc: client credentials require a client ID")
+	}
+	n := 0
+	for _, set := range []bool{a.ClientSecret != "", a.Signer != nil, a.Certificate != nil} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return errors.New("oidc: only one of ClientSecret, Signer and Certificate may be set")
+	}
+	switch a.AuthMethod {
+	case "":
+	case AuthMethodClientSecretBasic, AuthMethodClientSecretPost:
+		if a.ClientSecret == "" {
+			return fmt.Errorf("oidc: auth method %s requires a client secret", a.AuthMethod)
+		}
+	default:
+		return fmt.Errorf("oidc: unsupported auth method %q", a.AuthMethod)
+	}
+	return nil
+}
+
+// ClientAuthMethod returns the method the flow helpers of the provider, such
+// as RelyingParty and ClientCredentialsTokenSource, use to authenticate the
+// client to the token endpoint.
+//
+// Client secrets are sent with client_secret_basic unless the provider's
+// token_endpoint_auth_methods_supported metadata lists client_secret_post
+// but not client_secret_basic, or ClientAuth.AuthMethod says otherwise.
+// Several providers answer requests authenticated with a method they don't
+// support with a bare 401, so the method is worth logging when debugging
+// such failures.
+func (p *Provider) ClientAuthMethod(auth ClientAuth) string {
+	switch {
+	case auth.Signer != nil:
+		return AuthMethodPrivateKeyJWT
+	case auth.Certificate != nil:
+		return AuthMethodTLSClientAuth
+	case auth.ClientSecret == "":
+		return AuthMethodNone
+	case auth.AuthMethod != "":
+		return auth.AuthMethod
+	}
+	if m := p.secretAuthMethod(); m != "" {
+		return m
+	}
+	// The default of the specification.
+	return AuthMethodClientSecretBasic
+}
+
+// secretAuthMethod returns the method preferred by the provider to send client
+// secrets, or an empty string if its metadata doesn't say.
+func (p *Provider) secretAuthMethod() string {
+	var m struct {
+		Methods []string `json:"token_endpoint_auth_methods_supported"`
+	}
+	if p.Claims(&m) != nil {
+		return ""
+	}
+	switch {
+	case contains(m.Methods, AuthMethodClientSecretBasic):
+		return AuthMethodClientSecretBasic
+	case contains(m.Methods, AuthMethodClientSecretPost):
+		return AuthMethodClientSecretPost
+	}
+	return ""
+}
+
+// ClientCredentialsOption configures Provider.ClientCredentialsTokenSource.
+type ClientCredentialsOption func(*clientCredentials)
+
+// WithAccessTokenVerifier verifies access tokens returned by the token endpoint
+// as JWT access tokens in the RFC 9068 format. Tokens must be signed JWTs with
+// a "typ" header of "at+jwt", and pass the verifier's checks. The verifier's
+// ClientID should be set to the audience of the requested tokens.
+func WithAccessTokenVerifier(verifier *IDTokenVerifier) ClientCredentialsOption {
+	return func(c *clientCredentials) {
+		c.verifier = verifier
+	}
+}
+
+type clientCredentials struct {
+	ctx      context.Context
+	tokenURL string
+	client   *http.Client
+	auth     ClientAuth
+	scopes   []string
+	audience string
+	verifier *IDTokenVerifier
+}
+
+// ClientCredentialsTokenSource returns a token source for machine to machine
+// calls using the client credentials grant. Tokens are requested from the
+// provider's token endpoint, and reused until they expire. The audience, if
+// non-empty, is sent as the "audience" parameter understood by many providers.
+//
+// The context is used for all token requests.
+//
+//	src, err := provider.ClientCredentialsTokenSource(ctx, oidc.ClientAuth{
+//		ClientID: "billing-worker",
+//		Signer:   signer,
+//	}, []string{"invoices:write"}, "https://api.example.com",
+//		oidc.WithAccessTokenVerifier(provider.Verifier(&oidc.Config{ClientID: "https://api.example.com"})))
+//	if err != nil {
+//		// handle error
+//	}
+//	client := oauth2.NewClient(ctx, src)
+func (p *Provider) ClientCredentialsTokenSource(ctx context.Context, auth ClientAuth, scopes []string, audience string, opts ...ClientCredentialsOption) (oauth2.TokenSource, error) {
+	if err := auth.validate(); err != nil {
+		return nil, err
+	}
+	if p.tokenURL == "" {
+		return nil, errors.New("oidc: provider has no token endpoint")
+	}
+	client, err := p.tokenClient(ctx, &auth)
+	if err != nil {
+		return nil, err
+	}
+	auth.AuthMethod = p.ClientAuthMethod(auth)
+	c := &clientCredentials{
+		ctx:      ctx,
+		tokenURL: p.tokenURL,
+		client:   client,
+		auth:     auth,
+		scopes:   scopes,
+		audience: audience,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return oauth2.ReuseTokenSource(nil, c), nil
+}
+
+// tokenClient returns the HTTP client used for token requests: the one built by
+// the provider's options, else the client of the context, or else the one the
+// provider was created with. If the client authenticates with mutual TLS, the
+// client presents its certificate.
+func (p *Provider) tokenClient(ctx context.Context, auth *ClientAuth) (*http.Client, error) {
+	client := getClient(ctx)
+	if client == nil || p.clientOptions {
+		client = p.client
+	}
+	if auth.Certificate == nil {
+		return client, nil
+	}
+	return withClientCertificate(client, auth.Certificate)
+}
+
+// withClientCertificate returns a copy of the client whose transport presents
+// the certificate.
+func withClientCertificate(client *http.Client, cert *tls.Certificate) (*http.Client, error) {
+	return withTLSConfig(client, func(c *tls.Config) {
+		c.Certificates = []tls.Certificate{*cert}
+	})
+}
+
+// Token requests a new access token.
+func (c *clientCredentials) Token() (*oauth2.Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+	if c.audience != "" {
+		form.Set("audience", c.audience)
+	}
+	tr, err := requestToken(c.ctx, c.client, c.tokenURL, &c.auth, form)
+	if err != nil {
+		return nil, err
+	}
+	if c.verifier != nil {
+		if err := c.verifyAccessToken(tr.AccessToken); err != nil {
+			return nil, err
+		}
+	}
+	return tr.Token(), nil
+}
+
+// requestToken authenticates the client to the token endpoint and performs a
+// token request with the form's parameters.
+func requestToken(ctx context.Context, client *http.Client, tokenURL string, auth *ClientAuth, form url.Values) (*TokenResponse, error) {
+	switch {
+	case auth.Signer != nil:
+		assertion, err := ClientAssertion(ctx, auth.Signer, auth.ClientID, tokenURL, 0)
+		if err != nil {
+			return nil, err
+		}
+		form.Set("client_id", auth.ClientID)
+		form.Set("client_assertion_type", ClientAssertionType)
+		form.Set("client_assertion", assertion)
+	case auth.ClientSecret == "":
+		// Mutual TLS, or a public client.
+		form.Set("client_id", auth.ClientID)
+	case auth.AuthMethod == AuthMethodClientSecretPost:
+		form.Set("client_id", auth.ClientID)
+		form.Set("client_secret", auth.ClientSecret)
+	}
+	req, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: create token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if auth.ClientSecret != "" && auth.AuthMethod != AuthMethodClientSecretPost {
+		req.SetBasicAuth(url.QueryEscape(auth.ClientID), url.QueryEscape(auth.ClientSecret))
+	}
+	if client != nil {
+		ctx = ClientContext(ctx, client)
+	}
+	resp, err := doRequest(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := readBody(resp, getResponseLimits(ctx).maxDiscoverySize())
+	if err != nil {
+		return nil, fmt.Errorf("oidc: reading token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if oauthErr := parseOAuthError(resp, body); oauthErr != nil {
+			return nil, oauthErr
+		}
+		return nil, newHTTPError(resp, body, clockNow(ctx))
+	}
+	return parseTokenResponse(body, clockNow(ctx))
+}
+
+// verifyAccessToken checks a JWT access token as described by RFC 9068
+// section 4.
+func (c *clientCredentials) verifyAccessToken(raw string) error {
+	t, err := c.verifier.Verify(c.ctx, raw)
+	if err != nil {
+		return fmt.Errorf("oidc: invalid access token: %w", err)
+	}
+	typ := strings.ToLower(t.Header().Type)
+	if typ != "at+jwt" && typ != "application/at+jwt" {
+		return fmt.Errorf("oidc: invalid access token: expected typ at+jwt, got %q", t.Header().Type)
+	}
+	return nil
+}
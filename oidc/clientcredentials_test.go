@@ -0,0 +1,194 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func signAccessToken(t *testing.T, key *signingKey, typ, payload string) string {
+	opts := (&jose.SignerOptions{}).WithType(jose.ContentType(typ))
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: key.alg, Key: key.priv}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := signer.Sign([]byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestClientCredentialsTokenSource(t *testing.T) {
+	key := newRSAKey(t)
+	ecKey := newECDSAKey(t)
+	signer, err := NewCryptoSigner(ecKey.priv.(crypto.Signer), ES256, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken := signAccessToken(t, key, "at+jwt", `{"iss":"https://foo","aud":"https://api","sub":"worker","exp":4102444800}`)
+
+	var requests int
+	var tokenURL string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.PostFormValue("grant_type") != "client_credentials" || r.PostFormValue("scope") != "read write" || r.PostFormValue("audience") != "https://api" {
+			t.Errorf("unexpected form %v", r.PostForm)
+		}
+		if user, pass, ok := r.BasicAuth(); ok {
+			if user != "worker" || pass != "secret" {
+				t.Errorf("unexpected basic auth %q:%q", user, pass)
+			}
+		} else {
+			assertion, err := ParseUnverified(r.PostFormValue("client_assertion"))
+			if err != nil || r.PostFormValue("client_id") != "worker" || assertion.Subject != "worker" || !contains(assertion.Audience, tokenURL) {
+				t.Errorf("unexpected client assertion %+v, err=%v", assertion, err)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		token := accessToken
+		if strings.HasPrefix(r.URL.Path, "/plain") {
+			token = signAccessToken(t, key, "JWT", `{"iss":"https://foo","aud":"https://api","sub":"worker","exp":4102444800}`)
+		}
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":3600,"scope":"read write"}`, token)
+	}))
+	defer s.Close()
+	tokenURL = s.URL + "/token"
+
+	ctx := context.Background()
+	p := &Provider{issuer: "https://foo", tokenURL: tokenURL}
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "https://api"})
+
+	for name, auth := range map[string]ClientAuth{
+		"client_secret_basic": {ClientID: "worker", ClientSecret: "secret"},
+		"private_key_jwt":     {ClientID: "worker", Signer: signer},
+	} {
+		requests = 0
+		src, err := p.ClientCredentialsTokenSource(ctx, auth, []string{"read", "write"}, "https://api", WithAccessTokenVerifier(verifier))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for i := 0; i < 2; i++ {
+			token, err := src.Token()
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if token.AccessToken != accessToken || token.Extra("scope") != "read write" || token.Expiry.IsZero() {
+				t.Errorf("%s: unexpected token %+v", name, token)
+			}
+		}
+		if requests != 1 {
+			t.Errorf("%s: got %d token requests, want 1", name, requests)
+		}
+	}
+
+	p.tokenURL = s.URL + "/plain/token"
+	src, err := p.ClientCredentialsTokenSource(ctx, ClientAuth{ClientID: "worker", ClientSecret: "secret"}, []string{"read", "write"}, "https://api", WithAccessTokenVerifier(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Token(); err == nil || !strings.Contains(err.Error(), "at+jwt") {
+		t.Errorf("expected access token without at+jwt type to be rejected, got %v", err)
+	}
+
+	if _, err := p.ClientCredentialsTokenSource(ctx, ClientAuth{ClientID: "worker", ClientSecret: "secret", Signer: signer}, nil, ""); err == nil {
+		t.Errorf("expected error for multiple client authentication methods")
+	}
+}
+
+func TestClientCredentialsMutualTLS(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.PostFormValue("client_id") != "worker" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"opaque","token_type":"Bearer"}`)
+	}))
+	s.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	s.StartTLS()
+	defer s.Close()
+
+	ctx := ClientContext(context.Background(), s.Client())
+	p := &Provider{tokenURL: s.URL}
+	// The server's certificate doubles as the client certificate.
+	src, err := p.ClientCredentialsTokenSource(ctx, ClientAuth{ClientID: "worker", Certificate: &s.TLS.Certificates[0]}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := src.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "opaque" {
+		t.Errorf("unexpected access token %q", token.AccessToken)
+	}
+}
+
+func TestClientAuthMethod(t *testing.T) {
+	signer, err := NewCryptoSigner(newECDSAKey(t).priv.(crypto.Signer), ES256, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := func(methods string) *Provider {
+		return &Provider{rawClaims: []byte(`{"token_endpoint_auth_methods_supported":` + methods + `}`)}
+	}
+	secret := ClientAuth{ClientID: "worker", ClientSecret: "secret"}
+	tests := []struct {
+		name     string
+		provider *Provider
+		auth     ClientAuth
+		want     string
+	}{
+		{"no metadata", &Provider{}, secret, AuthMethodClientSecretBasic},
+		{"basic and post", provider(`["client_secret_post","client_secret_basic"]`), secret, AuthMethodClientSecretBasic},
+		{"post only", provider(`["private_key_jwt","client_secret_post"]`), secret, AuthMethodClientSecretPost},
+		{"neither", provider(`["private_key_jwt"]`), secret, AuthMethodClientSecretBasic},
+		{"override", provider(`["client_secret_post"]`), ClientAuth{ClientID: "worker", ClientSecret: "secret", AuthMethod: AuthMethodClientSecretBasic}, AuthMethodClientSecretBasic},
+		{"signer", provider(`["client_secret_post"]`), ClientAuth{ClientID: "worker", Signer: signer}, AuthMethodPrivateKeyJWT},
+		{"public", provider(`["client_secret_post"]`), ClientAuth{ClientID: "worker"}, AuthMethodNone},
+	}
+	for _, test := range tests {
+		if got := test.provider.ClientAuthMethod(test.auth); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+
+	// The chosen method is used for token requests.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); ok {
+			t.Errorf("unexpected basic auth")
+		}
+		if r.PostFormValue("client_id") != "worker" || r.PostFormValue("client_secret") != "secret" {
+			t.Errorf("unexpected form %v", r.PostForm)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"at","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer s.Close()
+	p := provider(`["client_secret_post"]`)
+	p.tokenURL = s.URL
+	src, err := p.ClientCredentialsTokenSource(context.Background(), secret, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Token(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.ClientCredentialsTokenSource(context.Background(), ClientAuth{ClientID: "worker", AuthMethod: AuthMethodClientSecretPost}, nil, ""); err == nil {
+		t.Errorf("expected auth method without a secret to be rejected")
+	}
+}
@@ -0,0 +1,145 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ConnectionAuth tracks the token authenticating a long-lived connection, such
+// as a WebSocket or a server-sent events stream. Tokens are normally checked
+// once when the connection is established, so without it a connection outlives
+// the token that opened it.
+//
+// The token is verified when the connection is upgraded, and the callback runs
+// once the token expires, unless the client sends a fresh token first:
+//
+//	// Browsers can't set headers on WebSocket requests, so those clients may
+//	// need to send the token in a query parameter or first message instead.
+//	raw := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+//	auth, err := oidc.AuthenticateConnection(r.Context(), verifier, raw, func() {
+//		conn.Close(websocket.StatusPolicyViolation, "token expired")
+//	})
+//	if err != nil {
+//		http.Error(w, "unauthorized", http.StatusUnauthorized)
+//		return
+//	}
+//	defer auth.Close()
+//	for {
+//		msg := readMessage(conn)
+//		if msg.Type == "reauthenticate" {
+//			if err := auth.Reauthenticate(ctx, msg.Token); err != nil {
+//				// close the connection
+//			}
+//		}
+//	}
+//
+// Handlers that prefer a channel can select on Expired instead.
+type ConnectionAuth struct {
+	verifier TokenVerifier
+	onExpire func()
+	expired  chan struct{}
+
+	mu     sync.Mutex
+	token  *IDToken
+	timer  *time.Timer
+	done   bool
+	closed bool
+}
+
+// AuthenticateConnection verifies the token of a connection and starts tracking
+// its expiry. onExpire may be nil. Time is read from the context's clock, see
+// ClockContext, though the expiry timer always runs in real time.
+func AuthenticateConnection(ctx context.Context, verifier TokenVerifier, rawToken string, onExpire func()) (*ConnectionAuth, error) {
+	token, err := verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, err
+	}
+	c := &ConnectionAuth{
+		verifier: verifier,
+		onExpire: onExpire,
+		expired:  make(chan struct{}),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setToken(ctx, token)
+	return c, nil
+}
+
+// Token returns the token currently authenticating the connection.
+func (c *ConnectionAuth) Token() *IDToken {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Expired returns a channel that's closed when the token expires.
+func (c *ConnectionAuth) Expired() <-chan struct{} {
+	return c.expired
+}
+
+// Reauthenticate replaces the token of the connection with a fresh one, such as
+// one sent by the client after a refresh, and restarts the expiry timer. The
+// new token must have the same issuer and subject as the original. Once the
+// token has expired, or the ConnectionAuth is closed, it always fails.
+func (c *ConnectionAuth) Reauthenticate(ctx context.Context, rawToken string) error {
+	token, err := c.verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done || c.closed {
+		return errors.New("oidc: connection is no longer authenticated")
+	}
+	if token.Issuer != c.token.Issuer || token.Subject != c.token.Subject {
+		return fmt.Errorf("oidc: reauthentication token is for subject %q, connection is for %q", token.Subject, c.token.Subject)
+	}
+	c.timer.Stop()
+	c.setToken(ctx, token)
+	return nil
+}
+
+// Close stops tracking the token. The callback isn't run after Close returns,
+// unless it was already running.
+func (c *ConnectionAuth) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+// setToken sets the token and starts a timer for its expiry. Tokens without
+// an expiry are tracked until the connection closes. It must be called with
+// c.mu held.
+func (c *ConnectionAuth) setToken(ctx context.Context, token *IDToken) {
+	c.token = token
+	d := time.Duration(1<<63 - 1)
+	if !token.Expiry.IsZero() {
+		d = token.Expiry.Sub(clockNow(ctx))
+	}
+	c.timer = time.AfterFunc(d, func() { c.expire(token) })
+}
+
+// expire runs when the timer for token fires.
+func (c *ConnectionAuth) expire(token *IDToken) {
+	c.mu.Lock()
+	// Skip timers that were stopped too late, after the token was replaced
+	// or the connection closed.
+	if c.token != token || c.done || c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.done = true
+	close(c.expired)
+	c.mu.Unlock()
+
+	if c.onExpire != nil {
+		c.onExpire()
+	}
+}
@@ -0,0 +1,64 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestConnectionAuth(t *testing.T) {
+	key := newRSAKey(t)
+	exp := time.Unix(4102444800, 0)
+	now := exp.Add(-50 * time.Millisecond)
+	ctx := ClockContext(context.Background(), func() time.Time { return now })
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		SkipClientIDCheck: true,
+		Now:               func() time.Time { return now },
+	})
+	token := func(sub string, exp time.Time) string {
+		return key.sign(t, []byte(fmt.Sprintf(`{"iss":"https://foo","sub":%q,"exp":%d}`, sub, exp.Unix())))
+	}
+
+	if _, err := AuthenticateConnection(ctx, verifier, "garbage", nil); err == nil {
+		t.Fatal("expected error for invalid token")
+	}
+
+	expired := make(chan struct{})
+	auth, err := AuthenticateConnection(ctx, verifier, token("jane", exp), func() { close(expired) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auth.Close()
+	if auth.Token().Subject != "jane" {
+		t.Errorf("unexpected token %+v", auth.Token())
+	}
+	select {
+	case <-expired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback wasn't called when the token expired")
+	}
+	<-auth.Expired()
+	if err := auth.Reauthenticate(ctx, token("jane", exp.Add(time.Hour))); err == nil {
+		t.Error("expected reauthentication of an expired connection to fail")
+	}
+
+	// Reauthenticating extends the connection.
+	auth, err = AuthenticateConnection(ctx, verifier, token("jane", exp), func() { t.Error("callback called after reauthentication") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.Reauthenticate(ctx, token("john", exp.Add(time.Hour))); err == nil {
+		t.Error("expected reauthentication as another subject to fail")
+	}
+	if err := auth.Reauthenticate(ctx, token("jane", exp.Add(time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-auth.Expired():
+		t.Error("connection expired after reauthentication")
+	case <-time.After(200 * time.Millisecond):
+	}
+	auth.Close()
+}
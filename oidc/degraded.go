@@ -0,0 +1,88 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DegradedModePolicy controls what happens when the provider can't be reached
+// to refresh its keys. Set it once with DegradedModeContext when creating the
+// provider or key set; verifiers created from the provider and BearerMiddleware
+// wrapping them follow it.
+//
+// Without a policy, keys cached by a RemoteKeySet are used until a refresh
+// succeeds, however long the outage, and requests are rejected when a token
+// can't be verified.
+//
+//	policy := &oidc.DegradedModePolicy{
+//		// Keep accepting tokens signed by known keys for 15 minutes.
+//		StaleKeysFor: 15 * time.Minute,
+//		// Let public pages render without a user when the IdP is down.
+//		FailOpen: oidc.FailOpenPaths("/public/"),
+//	}
+//	ctx := oidc.DegradedModeContext(context.Background(), policy)
+//	provider, err := oidc.NewProvider(ctx, "https://accounts.example.com")
+type DegradedModePolicy struct {
+	// StaleKeysFor is how long cached keys keep being used after a refresh of
+	// the key set has failed. Once it has elapsed, tokens are rejected with a
+	// *ProviderUnavailableError until a refresh succeeds. Zero uses cached keys
+	// for the whole outage.
+	StaleKeysFor time.Duration
+	// FailClosed rejects all tokens as soon as a refresh of the key set fails,
+	// until a refresh succeeds. It takes precedence over StaleKeysFor.
+	FailClosed bool
+	// FailOpen, if set, reports whether BearerMiddleware may pass a request to
+	// its handler without a verified token when the token couldn't be verified
+	// because the provider is unavailable. BearerTokenFromContext reports no
+	// token for such requests, so handlers must tolerate its absence.
+	//
+	// Failing open lets anyone reach the matching paths during an outage. Only
+	// use it for resources that are safe to serve unauthenticated.
+	FailOpen func(r *http.Request) bool
+}
+
+// FailOpenPaths returns a DegradedModePolicy.FailOpen function matching
+// requests whose path starts with one of the prefixes.
+func FailOpenPaths(prefixes ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// stale reports whether keys cached since a refresh failed at since can no
+// longer be used at now.
+func (p *DegradedModePolicy) stale(since, now time.Time) bool {
+	if p == nil || since.IsZero() {
+		return false
+	}
+	if p.FailClosed {
+		return true
+	}
+	return p.StaleKeysFor > 0 && now.Sub(since) >= p.StaleKeysFor
+}
+
+// failOpen reports whether the policy of the key set lets the request proceed
+// without a verified token.
+func (e *ProviderUnavailableError) failOpen(r *http.Request) bool {
+	return e.policy != nil && e.policy.FailOpen != nil && e.policy.FailOpen(r)
+}
+
+// DegradedModeContext returns a new Context that carries the policy. Providers
+// and key sets created with the returned context follow it.
+func DegradedModeContext(ctx context.Context, policy *DegradedModePolicy) context.Context {
+	return context.WithValue(ctx, degradedModeKey, policy)
+}
+
+func getDegradedMode(ctx context.Context) *DegradedModePolicy {
+	if p, ok := ctx.Value(degradedModeKey).(*DegradedModePolicy); ok {
+		return p
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func TestDegradedMode(t *testing.T) {
+	key, rotated := newRSAKey(t), newRSAKey(t)
+	down := false
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}})
+	}))
+	defer s.Close()
+
+	token := func(key *signingKey) string {
+		return key.sign(t, []byte(`{"iss":"https://foo","aud":"app","sub":"jane","exp":4102444800}`))
+	}
+	tests := []struct {
+		name   string
+		policy *DegradedModePolicy
+		// Whether a token signed by a cached key is accepted right after
+		// the outage started, and 10 minutes into it.
+		wantFresh, wantStale bool
+	}{
+		{name: "default", wantFresh: true, wantStale: true},
+		{name: "stale keys", policy: &DegradedModePolicy{StaleKeysFor: 5 * time.Minute}, wantFresh: true},
+		{name: "fail closed", policy: &DegradedModePolicy{FailClosed: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			down = false
+			now := time.Unix(1000, 0)
+			ctx := ClockContext(context.Background(), func() time.Time { return now })
+			if test.policy != nil {
+				ctx = DegradedModeContext(ctx, test.policy)
+			}
+			verifier := NewVerifier("https://foo", NewRemoteKeySet(ctx, s.URL), &Config{ClientID: "app"})
+			if _, err := verifier.Verify(context.Background(), token(key)); err != nil {
+				t.Fatal(err)
+			}
+
+			// A token signed by an unknown key triggers a refresh, which
+			// fails.
+			down = true
+			var unavailable *ProviderUnavailableError
+			if _, err := verifier.Verify(context.Background(), token(rotated)); !errors.As(err, &unavailable) || !unavailable.Since.Equal(now) {
+				t.Fatalf("expected provider unavailable error, got %v", err)
+			}
+			if _, err := verifier.Verify(context.Background(), token(key)); (err == nil) != test.wantFresh {
+				t.Errorf("verifying token with cached key at start of outage: %v", err)
+			}
+			now = now.Add(10 * time.Minute)
+			if _, err := verifier.Verify(context.Background(), token(key)); (err == nil) != test.wantStale {
+				t.Errorf("verifying token with cached key during outage: %v", err)
+			}
+
+			// Recovery makes cached keys usable again.
+			down = false
+			if _, err := verifier.Verify(context.Background(), token(key)); err != nil {
+				t.Errorf("verifying token after outage: %v", err)
+			}
+		})
+	}
+}
+
+func TestDegradedModeMiddleware(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+	ctx := DegradedModeContext(context.Background(), &DegradedModePolicy{FailOpen: FailOpenPaths("/public/")})
+	p := (&ProviderConfig{IssuerURL: "https://foo", JWKSURL: s.URL}).NewProvider(ctx)
+	verifier := p.Verifier(&Config{ClientID: "app"})
+	handler := BearerMiddleware(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := BearerTokenFromContext(r.Context()); ok {
+			t.Errorf("unexpected token for request that failed open")
+		}
+		fmt.Fprint(w, "ok")
+	}))
+
+	raw := newRSAKey(t).sign(t, []byte(`{"iss":"https://foo","aud":"app","sub":"jane","exp":4102444800}`))
+	for path, want := range map[string]int{
+		"/public/index.html": http.StatusOK,
+		"/private":           http.StatusServiceUnavailable,
+	} {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Authorization", "Bearer "+raw)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("%s: got status %d, want %d", path, w.Code, want)
+		}
+		if w.Header().Get("WWW-Authenticate") != "" {
+			t.Errorf("%s: unexpected challenge %q", path, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// DistributedClaimsResolver is a ClaimResolver for distributed claims served by
+// claims providers other than the token's issuer. The JWT returned by each
+// claim source is verified with the keys of its own issuer, discovered the
+// first time the issuer is seen, as OpenID Connect requires. Only issuers
+// accepted by the resolver's IssuerMatcher are trusted as claims providers.
+//
+//	resolver := oidc.NewDistributedClaimsResolver(ctx, oidc.Issuers{
+//		"https://claims.partner.example.com",
+//	}, &oidc.Config{SkipClientIDCheck: true})
+//	mapper := &oidc.IdentityMapper{ClaimResolver: resolver}
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#AggregatedDistributedClaims
+type DistributedClaimsResolver struct {
+	verifier *TrustedIssuers
+
+	// SourceIssuers optionally binds claim source endpoints to the issuer
+	// that must sign their responses, so one trusted claims provider can't
+	// answer for another's endpoint. Endpoints that aren't listed may be
+	// signed by any trusted issuer.
+	SourceIssuers map[string]string
+}
+
+// NewDistributedClaimsResolver returns a resolver trusting claims providers
+// accepted by issuers. The JWTs of claim sources are verified with the config,
+// which usually sets SkipClientIDCheck since claims providers needn't set an
+// audience. The context and options are used for discovery, and for fetching
+// the issuers' keys.
+func NewDistributedClaimsResolver(ctx context.Context, issuers IssuerMatcher, config *Config, opts ...ProviderOption) *DistributedClaimsResolver {
+	return &DistributedClaimsResolver{verifier: NewTrustedIssuers(ctx, issuers, config, opts...)}
+}
+
+// ResolveClaim fetches the claim source's JWT, or takes the JWT of an aggregated
+// claim source, verifies it with the keys of its issuer, and returns the value
+// of the claim. Responses from untrusted issuers are rejected with an
+// *UntrustedIssuerError before any discovery request.
+func (r *DistributedClaimsResolver) ResolveClaim(ctx context.Context, claim string, src ClaimSource) (json.RawMessage, error) {
+	body, err := fetchClaimSource(ctx, claimSource(src))
+	if err != nil {
+		return nil, err
+	}
+	unverified, err := ParseUnverified(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed response from claim source %s: %v", src.Endpoint, err)
+	}
+	if want, ok := r.SourceIssuers[src.Endpoint]; ok && unverified.Issuer != want {
+		return nil, fmt.Errorf("oidc: claim source %s returned a token issued by %q, expected %q", src.Endpoint, unverified.Issuer, want)
+	}
+	token, err := r.verifier.Verify(ctx, string(body))
+	if err != nil {
+		return nil, err
+	}
+	value, ok := token.ClaimValue(claim)
+	if !ok {
+		return nil, fmt.Errorf("oidc: claim source %s didn't return claim %q", src.Endpoint, claim)
+	}
+	return value, nil
+}
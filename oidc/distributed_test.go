@@ -0,0 +1,68 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDistributedClaimsResolver(t *testing.T) {
+	keyA, keyB := newRSAKey(t), newRSAKey(t)
+	a, _ := newIssuerServer(t, keyA)
+	b, _ := newIssuerServer(t, keyB)
+	untrusted, _ := newIssuerServer(t, keyA)
+
+	token := func(key *signingKey, iss string) string {
+		return key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"sub":"jane","exp":4102444800,"groups":["admins"]}`, iss)))
+	}
+	responses := map[string]string{
+		"/a":         token(keyA, a.URL),
+		"/b":         token(keyB, b.URL),
+		"/forged":    token(keyA, b.URL),
+		"/untrusted": token(keyA, untrusted.URL),
+	}
+	sources := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, responses[r.URL.Path])
+	}))
+	defer sources.Close()
+
+	ctx := context.Background()
+	resolver := NewDistributedClaimsResolver(ctx, Issuers{a.URL, b.URL}, &Config{SkipClientIDCheck: true})
+	resolver.SourceIssuers = map[string]string{sources.URL + "/b": a.URL}
+
+	src := func(path string) ClaimSource {
+		return ClaimSource{Endpoint: sources.URL + path, AccessToken: "at"}
+	}
+	value, err := resolver.ResolveClaim(ctx, "groups", src("/a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != `["admins"]` {
+		t.Errorf("got value %s", value)
+	}
+
+	if _, err := resolver.ResolveClaim(ctx, "roles", src("/a")); err == nil || !strings.Contains(err.Error(), `didn't return claim "roles"`) {
+		t.Errorf("missing claim: got %v", err)
+	}
+	if _, err := resolver.ResolveClaim(ctx, "groups", src("/forged")); err == nil {
+		t.Error("expected token signed by another issuer's key to be rejected")
+	}
+	var untrustedErr *UntrustedIssuerError
+	if _, err := resolver.ResolveClaim(ctx, "groups", src("/untrusted")); !errors.As(err, &untrustedErr) {
+		t.Errorf("untrusted issuer: got %v, want *UntrustedIssuerError", err)
+	}
+	if _, err := resolver.ResolveClaim(ctx, "groups", src("/b")); err == nil || !strings.Contains(err.Error(), "expected") {
+		t.Errorf("source bound to another issuer: got %v", err)
+	}
+	if _, err := resolver.ResolveClaim(ctx, "groups", ClaimSource{Endpoint: sources.URL + "/a"}); err == nil {
+		t.Error("expected error without access token")
+	}
+}
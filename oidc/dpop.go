@@ -0,0 +1,76 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// DPoPHeader is the HTTP header carrying DPoP proofs.
+const DPoPHeader = "DPoP"
+
+// dpopProofType is the "typ" header of DPoP proofs.
+const dpopProofType = "dpop+jwt"
+
+// DPoPProof returns a DPoP proof, as described by RFC 9449, for an HTTP request
+// with the method and URL. The proof is sent in the DPoPHeader of the request.
+//
+// accessToken is the access token sent with the request, if any, which the
+// proof is bound to with the "ath" claim. nonce is the most recent value of
+// the DPoP-Nonce header returned by the server, if any.
+//
+// Proofs carry the signer's public key, so the signer must implement
+// Public() crypto.PublicKey, as signers returned by NewCryptoSigner do.
+//
+//	proof, err := oidc.DPoPProof(ctx, signer, http.MethodPost, provider.Endpoint().TokenURL, "", "")
+//	if err != nil {
+//		// handle error
+//	}
+//	req.Header.Set(oidc.DPoPHeader, proof)
+//
+// See: https://www.rfc-editor.org/rfc/rfc9449
+func DPoPProof(ctx context.Context, s Signer, method, uri, accessToken, nonce string) (string, error) {
+	pub, ok := s.(interface{ Public() crypto.PublicKey })
+	if !ok {
+		return "", errors.New("oidc: dpop proofs require a signer exposing its public key")
+	}
+	if method == "" {
+		return "", errors.New("oidc: dpop proof requires an http method")
+	}
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() {
+		return "", fmt.Errorf("oidc: dpop proof requires an absolute url, got %q", uri)
+	}
+	// RFC 9449 section 4.2: "htu" excludes the query and fragment.
+	u.RawQuery, u.Fragment, u.RawFragment = "", "", ""
+
+	jti, err := newJTI()
+	if err != nil {
+		return "", err
+	}
+	claims := map[string]interface{}{
+		"jti": jti,
+		"htm": strings.ToUpper(method),
+		"htu": u.String(),
+		"iat": clockNow(ctx).Unix(),
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	jwk := &jose.JSONWebKey{Key: pub.Public()}
+	if !jwk.Valid() {
+		return "", fmt.Errorf("oidc: unsupported dpop public key %T", pub.Public())
+	}
+	return signJWT(ctx, s, dpopProofType, jwk, claims)
+}
@@ -0,0 +1,80 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func TestDPoPProof(t *testing.T) {
+	key := newECDSAKey(t)
+	signer, err := NewCryptoSigner(key.priv.(crypto.Signer), ES256, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	ctx := ClockContext(context.Background(), func() time.Time { return now })
+
+	proof, err := DPoPProof(ctx, signer, "post", "https://as.example.com/token?x=1#frag", "access", "server-nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := jose.ParseSigned(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := jws.Signatures[0].Header
+	if typ := header.ExtraHeaders[jose.HeaderType]; typ != "dpop+jwt" {
+		t.Errorf("expected typ dpop+jwt, got %v", typ)
+	}
+	if header.JSONWebKey == nil || !header.JSONWebKey.IsPublic() {
+		t.Fatalf("expected public jwk header, got %+v", header.JSONWebKey)
+	}
+	payload, err := jws.Verify(header.JSONWebKey)
+	if err != nil {
+		t.Fatalf("verifying proof with its jwk: %v", err)
+	}
+	var claims struct {
+		JTI   string `json:"jti"`
+		HTM   string `json:"htm"`
+		HTU   string `json:"htu"`
+		IAT   int64  `json:"iat"`
+		ATH   string `json:"ath"`
+		Nonce string `json:"nonce"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("access"))
+	want := claims
+	want.HTM, want.HTU, want.IAT = "POST", "https://as.example.com/token", now.Unix()
+	want.ATH, want.Nonce = base64.RawURLEncoding.EncodeToString(sum[:]), "server-nonce"
+	if claims != want || claims.JTI == "" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	other, err := DPoPProof(ctx, signer, "GET", "https://rs.example.com/", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == proof {
+		t.Errorf("expected proofs to differ")
+	}
+	if _, err := DPoPProof(ctx, signer, "GET", "/relative", "", ""); err == nil {
+		t.Errorf("expected error for relative url")
+	}
+	if _, err := DPoPProof(ctx, opaqueSigner{signer}, "GET", "https://rs.example.com/", "", ""); err == nil {
+		t.Errorf("expected error for signer without a public key")
+	}
+}
+
+// opaqueSigner hides all methods of a Signer but those of the interface.
+type opaqueSigner struct {
+	Signer
+}
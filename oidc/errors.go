@@ -2,6 +2,9 @@ package oidc
 
 import (
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -41,3 +44,165 @@ type InvalidAudienceError struct {
 func (e *InvalidAudienceError) Error() string {
 	return fmt.Sprintf("oidc: expected audience %q got %q", e.Expected, e.Actual)
 }
+
+// UntrustedIssuerError indicates that a TrustedIssuers verifier rejected a token
+// because its issuer isn't trusted. No other checks are performed on such
+// tokens, and the issuer isn't contacted.
+type UntrustedIssuerError struct {
+	Issuer string
+}
+
+func (e *UntrustedIssuerError) Error() string {
+	return fmt.Sprintf("oidc: token issued by untrusted issuer %q", e.Issuer)
+}
+
+// MalformedTokenError indicates that Verify failed because the token could not
+// be parsed, or exceeded the size or nesting limits configured on the verifier.
+// No other checks are performed on malformed tokens.
+type MalformedTokenError struct {
+	Err error
+}
+
+func (e *MalformedTokenError) Error() string {
+	return fmt.Sprintf("oidc: malformed jwt: %v", e.Err)
+}
+
+func (e *MalformedTokenError) Unwrap() error {
+	return e.Err
+}
+
+// UserInfoSubjectMismatchError indicates that the subject returned by the
+// userinfo endpoint didn't match the subject of the ID Token the response was
+// checked against. The response MUST NOT be used.
+type UserInfoSubjectMismatchError struct {
+	IDToken, UserInfo string
+}
+
+func (e *UserInfoSubjectMismatchError) Error() string {
+	return fmt.Sprintf("oidc: userinfo subject %q does not match id token subject %q", e.UserInfo, e.IDToken)
+}
+
+// AuthorizationError is an error response returned from the authorization
+// endpoint, such as when the user denies consent or a prompt=none request
+// requires interaction.
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#AuthError
+type AuthorizationError struct {
+	// Code is the error code, such as "access_denied" or "login_required".
+	Code        string
+	Description string
+	URI         string
+	// State is the state value from the request, so it can be checked before
+	// the error is acted on.
+	State string
+}
+
+func (e *AuthorizationError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oidc: authorization error %q: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("oidc: authorization error %q", e.Code)
+}
+
+// HTTPError is an unexpected HTTP response from an endpoint of the provider,
+// such as the discovery, key set or userinfo endpoints. Responses carrying an
+// RFC 6749 error are returned as an *OAuthError instead.
+//
+//	var httpErr *oidc.HTTPError
+//	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+//		// back off for httpErr.RetryAfter
+//	}
+type HTTPError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// URL of the request.
+	URL string
+	// Body holds the raw response body. It may be truncated.
+	Body []byte
+	// RetryAfter is the delay requested by the response's Retry-After header,
+	// or zero if there's none.
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("oidc: request to %s failed: %d %s: %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// newHTTPError returns the error for an unexpected response received at now.
+func newHTTPError(resp *http.Response, body []byte, now time.Time) *HTTPError {
+	e := &HTTPError{StatusCode: resp.StatusCode, Body: body}
+	if resp.Request != nil && resp.Request.URL != nil {
+		e.URL = resp.Request.URL.String()
+	}
+	e.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), now)
+	return e
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date. Invalid and past values are zero.
+func parseRetryAfter(h string, now time.Time) time.Duration {
+	h = strings.TrimSpace(h)
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseInt(h, 10, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	t, err := http.ParseTime(h)
+	if err != nil || !t.After(now) {
+		return 0
+	}
+	return t.Sub(now)
+}
+
+// ProviderUnavailableError is returned when a token can't be verified because
+// the provider's keys can't be fetched.
+type ProviderUnavailableError struct {
+	// Since is the time of the first failed refresh since the last
+	// successful one.
+	Since time.Time
+	// Err is the error of the last refresh.
+	Err error
+
+	policy *DegradedModePolicy
+}
+
+func (e *ProviderUnavailableError) Error() string {
+	return fmt.Sprintf("oidc: provider unavailable: fetching keys %v", e.Err)
+}
+
+func (e *ProviderUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// OAuthError is an RFC 6749 error response returned by an endpoint of the
+// provider, such as the userinfo or token endpoints. Use AsOAuthError to also
+// match errors returned by the token endpoint through the oauth2 package.
+//
+//	userInfo, err := provider.UserInfo(ctx, tokenSource)
+//	if oauthErr, ok := oidc.AsOAuthError(err); ok && oauthErr.Code == "invalid_token" {
+//		// prompt the user to log in again
+//	}
+//
+// See: https://www.rfc-editor.org/rfc/rfc6749#section-5.2
+type OAuthError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Code is the error parameter, such as "invalid_grant" or "invalid_token".
+	Code        string
+	Description string
+	URI         string
+	// Body holds the raw response body. It may be truncated.
+	Body []byte
+}
+
+func (e *OAuthError) Error() string {
+	s := fmt.Sprintf("oidc: %d error %q", e.StatusCode, e.Code)
+	if e.Description != "" {
+		s += fmt.Sprintf(": %s", e.Description)
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestHTTPError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("boom"))
+		default:
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer s.Close()
+	ctx := context.Background()
+
+	var httpErr *HTTPError
+	if _, err := NewProvider(ctx, s.URL); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusInternalServerError || string(httpErr.Body) != "boom" || httpErr.URL != s.URL+"/.well-known/openid-configuration" {
+		t.Errorf("unexpected discovery error %v", err)
+	}
+
+	keySet := NewRemoteKeySet(ctx, s.URL+"/keys")
+	if _, err := keySet.keysFromRemote(ctx); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests || httpErr.RetryAfter != 2*time.Minute {
+		t.Errorf("unexpected key set error %v", err)
+	}
+
+	p := (&ProviderConfig{IssuerURL: s.URL, UserInfoURL: s.URL + "/userinfo"}).NewProvider(ctx)
+	if _, err := p.UserInfo(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "at"})); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("unexpected userinfo error %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"-1", 0},
+		{"Mon, 01 Jan 2024 00:01:00 GMT", time.Minute},
+		{"Sun, 31 Dec 2023 23:59:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, test := range tests {
+		if got := parseRetryAfter(test.header, now); got != test.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", test.header, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// Cryptographic backends reported by FIPSStatus.Backend.
+const (
+	// BackendStandard is Go's standard cryptography, not running in a FIPS
+	// 140 validated mode.
+	BackendStandard = "standard"
+	// BackendGoFIPS140 is the Go Cryptographic Module running in FIPS 140-3
+	// mode, for example with GODEBUG=fips140=on.
+	BackendGoFIPS140 = "go-fips140"
+	// BackendBoringCrypto is BoringCrypto, used by toolchains built with
+	// GOEXPERIMENT=boringcrypto.
+	BackendBoringCrypto = "boringcrypto"
+)
+
+// fipsBuildTag is set by building with the "oidcfips" build tag.
+var fipsBuildTag bool
+
+// fipsRuntime is set by SetFIPSMode.
+var fipsRuntime atomic.Bool
+
+// FIPSStatus describes the FIPS 140-3 mode of the package, for compliance
+// audits.
+type FIPSStatus struct {
+	// Enabled reports whether signing algorithms are restricted to those
+	// approved by FIPS 186-5 and FIPS 198-1.
+	Enabled bool
+	// Reasons lists why FIPS mode is enabled: "build tag" when built with the
+	// "oidcfips" tag, "crypto backend" when the backend runs in FIPS mode,
+	// and "SetFIPSMode".
+	Reasons []string
+	// Backend is the cryptographic backend in use, one of BackendStandard,
+	// BackendGoFIPS140 or BackendBoringCrypto.
+	Backend string
+	// Algorithms are the signing algorithms this package accepts and
+	// produces. Symmetric algorithms additionally require
+	// Config.InsecureAllowSymmetricAlgs.
+	Algorithms []string
+}
+
+// FIPSMode reports whether FIPS mode is enabled, why, and which algorithms it
+// permits.
+//
+// FIPS mode is enabled by building with the "oidcfips" build tag, by calling
+// SetFIPSMode, or automatically when the cryptographic backend runs in a FIPS
+// 140 validated mode. In FIPS mode, tokens signed with algorithms that aren't
+// approved are rejected regardless of the verifier's configuration, and
+// signers and key managers refuse to use them. Ed25519 is only approved when
+// the Go Cryptographic Module itself runs in FIPS 140-3 mode, since other
+// backends don't implement it within their validated boundary.
+//
+// This package performs no cryptography of its own: running in FIPS mode also
+// requires a validated backend, such as the Go Cryptographic Module enabled
+// with GODEBUG=fips140=on, or a SignatureVerifier backed by a validated
+// module.
+func FIPSMode() FIPSStatus {
+	backend, backendFIPS := cryptoBackend()
+	s := FIPSStatus{Backend: backend}
+	if fipsBuildTag {
+		s.Reasons = append(s.Reasons, "build tag")
+	}
+	if backendFIPS {
+		s.Reasons = append(s.Reasons, "crypto backend")
+	}
+	if fipsRuntime.Load() {
+		s.Reasons = append(s.Reasons, "SetFIPSMode")
+	}
+	s.Enabled = len(s.Reasons) > 0
+	for _, alg := range []string{RS256, RS384, RS512, ES256, ES384, ES512, PS256, PS384, PS512, EdDSA, HS256, HS384, HS512} {
+		if fipsAllowed(alg) {
+			s.Algorithms = append(s.Algorithms, alg)
+		}
+	}
+	return s
+}
+
+// SetFIPSMode enables or disables FIPS mode at runtime. It should be called
+// before any provider or verifier is used. FIPS mode can't be disabled when
+// enabled by the build tag or the cryptographic backend.
+func SetFIPSMode(enabled bool) error {
+	if !enabled {
+		if fipsBuildTag {
+			return errors.New("oidc: FIPS mode is enabled by the oidcfips build tag")
+		}
+		if _, backendFIPS := cryptoBackend(); backendFIPS {
+			return errors.New("oidc: FIPS mode is enabled by the cryptographic backend")
+		}
+	}
+	fipsRuntime.Store(enabled)
+	return nil
+}
+
+func fipsEnabled() bool {
+	if fipsBuildTag || fipsRuntime.Load() {
+		return true
+	}
+	_, backendFIPS := cryptoBackend()
+	return backendFIPS
+}
+
+// fipsAllowed reports whether the algorithm may be used in the current mode.
+func fipsAllowed(alg string) bool {
+	if !fipsEnabled() {
+		return true
+	}
+	switch alg {
+	case RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384, ES512, HS256, HS384, HS512:
+		return true
+	case EdDSA:
+		backend, backendFIPS := cryptoBackend()
+		return backend == BackendGoFIPS140 && backendFIPS
+	}
+	return false
+}
+
+// checkFIPS returns an error if the algorithm isn't permitted in FIPS mode.
+func checkFIPS(alg string) error {
+	if !fipsAllowed(alg) {
+		return fmt.Errorf("oidc: algorithm %q is not approved in FIPS mode", alg)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build boringcrypto
+
+package oidc
+
+import "crypto/boring"
+
+func cryptoBackend() (name string, fips bool) {
+	return BackendBoringCrypto, boring.Enabled()
+}
@@ -0,0 +1,12 @@
+//go:build !boringcrypto && go1.24
+
+package oidc
+
+import "crypto/fips140"
+
+func cryptoBackend() (name string, fips bool) {
+	if fips140.Enabled() {
+		return BackendGoFIPS140, true
+	}
+	return BackendStandard, false
+}
@@ -0,0 +1,7 @@
+//go:build !boringcrypto && !go1.24
+
+package oidc
+
+func cryptoBackend() (name string, fips bool) {
+	return BackendStandard, false
+}
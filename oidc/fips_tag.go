@@ -0,0 +1,7 @@
+//go:build oidcfips
+
+package oidc
+
+func init() {
+	fipsBuildTag = true
+}
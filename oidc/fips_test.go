@@ -0,0 +1,63 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"strings"
+	"testing"
+)
+
+// fipsRejects reports whether FIPS mode, enabled by the "oidcfips" build tag or
+// the crypto backend, rejects the algorithm. Tests of such algorithms expect
+// errors instead.
+func fipsRejects(alg string) bool {
+	return FIPSMode().Enabled && !fipsAllowed(alg)
+}
+
+func TestFIPSMode(t *testing.T) {
+	if FIPSMode().Enabled {
+		t.Skip("FIPS mode is enabled by the build or the crypto backend")
+	}
+	if err := SetFIPSMode(true); err != nil {
+		t.Fatal(err)
+	}
+	defer SetFIPSMode(false)
+
+	status := FIPSMode()
+	if !status.Enabled || len(status.Reasons) != 1 || status.Reasons[0] != "SetFIPSMode" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if status.Backend != BackendStandard {
+		t.Errorf("expected standard backend, got %q", status.Backend)
+	}
+	if contains(status.Algorithms, EdDSA) {
+		t.Errorf("EdDSA shouldn't be approved without a validated backend: %v", status.Algorithms)
+	}
+
+	ctx := context.Background()
+	rsaKey, edKey := newRSAKey(t), newEdDSAKey(t)
+	config := &Config{ClientID: "api", SupportedSigningAlgs: []string{RS256, EdDSA}}
+	payload := []byte(`{"iss":"https://foo","aud":"api","sub":"jane","exp":4102444800}`)
+
+	v := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{rsaKey.pub}}, config)
+	if _, err := v.Verify(ctx, rsaKey.sign(t, payload)); err != nil {
+		t.Errorf("verifying RS256 token in FIPS mode: %v", err)
+	}
+	v = NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{edKey.pub}}, config)
+	if _, err := v.Verify(ctx, edKey.sign(t, payload)); err == nil {
+		t.Error("expected EdDSA token to be rejected in FIPS mode")
+	}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "FIPS") {
+		t.Errorf("expected Validate to report EdDSA, got %v", err)
+	}
+	if _, err := NewKeyManager(ctx, &KeyManagerConfig{Algorithm: EdDSA}); err == nil {
+		t.Error("expected key manager to refuse EdDSA in FIPS mode")
+	}
+
+	if err := SetFIPSMode(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(ctx, edKey.sign(t, payload)); err != nil {
+		t.Errorf("verifying EdDSA token after disabling FIPS mode: %v", err)
+	}
+}
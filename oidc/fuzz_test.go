@@ -0,0 +1,107 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var fuzzTokens = []string{
+	"eyJhbGciOiJSUzI1NiJ9.eyJpc3MiOiJodHRwczovL2ZvbyJ9.c2ln",
+	"eyJhbGciOiJub25lIn0.eyJzdWIiOiJqYW5lIn0.",
+	"e30.e30.e30",
+	"..",
+	"a.b.c.d",
+	"eyJhbGciOiJSUzI1NiIsImFsZyI6Im5vbmUifQ.e30.",
+}
+
+func FuzzParseCompactJWS(f *testing.F) {
+	for _, tok := range fuzzTokens {
+		f.Add(tok)
+	}
+	f.Fuzz(func(t *testing.T, raw string) {
+		jws, err := parseCompactJWS(raw)
+		if err != nil {
+			return
+		}
+		defer jws.release()
+		if _, err := jws.decodeHeader(); err != nil {
+			return
+		}
+		jws.verify(context.Background(), &StaticKeySet{})
+	})
+}
+
+func FuzzParseUnverified(f *testing.F) {
+	for _, tok := range fuzzTokens {
+		f.Add(tok)
+	}
+	f.Fuzz(func(t *testing.T, raw string) {
+		u, err := ParseUnverified(raw)
+		if err != nil {
+			return
+		}
+		var claims map[string]interface{}
+		u.Claims(&claims)
+	})
+}
+
+func FuzzVerify(f *testing.F) {
+	key := newRSAKey(f)
+	f.Add(key.sign(f, []byte(`{"iss":"https://foo","aud":"app","sub":"jane","exp":4102444800}`)))
+	for _, tok := range fuzzTokens {
+		f.Add(tok)
+	}
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		ClientID: "app",
+		Now:      func() time.Time { return time.Unix(1000, 0) },
+	})
+	f.Fuzz(func(t *testing.T, raw string) {
+		verifier.Verify(context.Background(), raw)
+		verifier.Inspect(context.Background(), raw)
+	})
+}
+
+func FuzzCheckJSON(f *testing.F) {
+	for _, s := range []string{
+		`{"iss":"a","sub":"b"}`,
+		`{"iss":"a","iss":"b"}`,
+		`{"a":{"b":[1,{"c":2}]}}`,
+		`{"a\"":1,"a":2}`,
+		`{"iss":"a","iss":"b"}`,
+		`[[[[]]]]`,
+		`"unterminated`,
+	} {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if err := checkJSON(b, DefaultMaxClaimDepth, false); err != nil {
+			return
+		}
+		// Documents that pass must decode without losing members to
+		// duplicate keys.
+		var m map[string]json.RawMessage
+		if json.Unmarshal(b, &m) != nil {
+			return
+		}
+		var count int
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.Token()
+		for dec.More() {
+			if _, err := dec.Token(); err != nil {
+				return
+			}
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				return
+			}
+			count++
+		}
+		if count != len(m) {
+			t.Errorf("checkJSON accepted %q with %d members decoded into %d", b, count, len(m))
+		}
+	})
+}
@@ -0,0 +1,144 @@
+// Package gcpkms signs client assertions and DPoP proofs with asymmetric keys
+// held by Google Cloud Key Management Service, so the private key never enters
+// the process. It's a separate module so the core module doesn't depend on the
+// Google Cloud client libraries.
+//
+//	client, err := kms.NewKeyManagementClient(ctx)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer client.Close()
+//	name := "projects/p/locations/global/keyRings/r/cryptoKeys/oidc-client/cryptoKeyVersions/1"
+//	signer, err := gcpkms.NewSigner(ctx, client, name, "key-1")
+//	if err != nil {
+//		// handle error
+//	}
+//	assertion, err := oidc.ClientAssertion(ctx, signer, clientID, provider.Endpoint().TokenURL, 0)
+//
+// The caller needs the cloudkms.cryptoKeyVersions.viewPublicKey and
+// cloudkms.cryptoKeyVersions.useToSign permissions on the key version.
+package gcpkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Client is the subset of the Cloud KMS API used by signers.
+// *kms.KeyManagementClient implements it.
+type Client interface {
+	GetPublicKey(ctx context.Context, req *kmspb.GetPublicKeyRequest, opts ...gax.CallOption) (*kmspb.PublicKey, error)
+	AsymmetricSign(ctx context.Context, req *kmspb.AsymmetricSignRequest, opts ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error)
+}
+
+// NewSigner returns a signer using the crypto key version with the resource
+// name. The JWS algorithm is chosen from the algorithm of the key version,
+// such as ES256 for EC_SIGN_P256_SHA256. keyID is the "kid" header of signed
+// tokens.
+func NewSigner(ctx context.Context, client Client, name, keyID string) (oidc.Signer, error) {
+	resp, err := client.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: name})
+	if err != nil {
+		return nil, fmt.Errorf("gcpkms: get public key: %w", err)
+	}
+	if resp.PemCrc32C != nil && int64(checksum([]byte(resp.Pem))) != resp.PemCrc32C.Value {
+		return nil, errors.New("gcpkms: public key was corrupted in transit")
+	}
+	block, _ := pem.Decode([]byte(resp.Pem))
+	if block == nil {
+		return nil, errors.New("gcpkms: public key isn't pem encoded")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("gcpkms: parse public key: %v", err)
+	}
+	alg, ok := algorithms[resp.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("gcpkms: unsupported key algorithm %s", resp.Algorithm)
+	}
+	return oidc.NewCryptoSigner(&key{client: client, name: name, pub: pub}, alg, keyID)
+}
+
+// algorithms maps key version algorithms to JWS algorithms.
+var algorithms = map[kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm]string{
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_2048_SHA256: oidc.RS256,
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_3072_SHA256: oidc.RS256,
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA256: oidc.RS256,
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA512: oidc.RS512,
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256:   oidc.PS256,
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_3072_SHA256:   oidc.PS256,
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_4096_SHA256:   oidc.PS256,
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_4096_SHA512:   oidc.PS512,
+	kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256:        oidc.ES256,
+	kmspb.CryptoKeyVersion_EC_SIGN_P384_SHA384:        oidc.ES384,
+	kmspb.CryptoKeyVersion_EC_SIGN_ED25519:            oidc.EdDSA,
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// checksum returns the CRC32C checksum Cloud KMS uses to detect corruption.
+func checksum(b []byte) uint32 {
+	return crc32.Checksum(b, castagnoli)
+}
+
+// key is an oidc.ContextSigner signing with a Cloud KMS key version. The key
+// version's algorithm is fixed, and NewCryptoSigner only requests it.
+type key struct {
+	client Client
+	name   string
+	pub    crypto.PublicKey
+}
+
+func (k *key) Public() crypto.PublicKey { return k.pub }
+
+func (k *key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(context.Background(), digest, opts)
+}
+
+// SignContext signs the digest, or the message for Ed25519 keys. Cloud KMS
+// returns ECDSA signatures ASN.1 encoded, as crypto.Signer requires.
+func (k *key) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := &kmspb.AsymmetricSignRequest{Name: k.name}
+	switch opts.HashFunc() {
+	case 0:
+		req.Data = digest
+		req.DataCrc32C = wrapperspb.Int64(int64(checksum(digest)))
+	case crypto.SHA256:
+		req.Digest = &kmspb.Digest{Digest: &kmspb.Digest_Sha256{Sha256: digest}}
+	case crypto.SHA384:
+		req.Digest = &kmspb.Digest{Digest: &kmspb.Digest_Sha384{Sha384: digest}}
+	case crypto.SHA512:
+		req.Digest = &kmspb.Digest{Digest: &kmspb.Digest_Sha512{Sha512: digest}}
+	default:
+		return nil, fmt.Errorf("gcpkms: unsupported hash %v", opts.HashFunc())
+	}
+	if pss, ok := opts.(*rsa.PSSOptions); ok && pss.SaltLength != rsa.PSSSaltLengthEqualsHash {
+		return nil, errors.New("gcpkms: pss signatures use a salt as long as the hash")
+	}
+	if req.Digest != nil {
+		req.DigestCrc32C = wrapperspb.Int64(int64(checksum(digest)))
+	}
+	resp, err := k.client.AsymmetricSign(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("gcpkms: sign: %w", err)
+	}
+	verified := resp.VerifiedDigestCrc32C
+	if req.Data != nil {
+		verified = resp.VerifiedDataCrc32C
+	}
+	if !verified || resp.SignatureCrc32C == nil || int64(checksum(resp.Signature)) != resp.SignatureCrc32C.Value {
+		return nil, errors.New("gcpkms: sign request or response was corrupted in transit")
+	}
+	return resp.Signature, nil
+}
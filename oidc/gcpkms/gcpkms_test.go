@@ -0,0 +1,114 @@
+package gcpkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type fakeKey struct {
+	key       crypto.Signer
+	algorithm kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm
+}
+
+// fakeKMS signs with local keys the way Cloud KMS does.
+type fakeKMS struct {
+	keys    map[string]fakeKey
+	corrupt bool
+}
+
+func (f *fakeKMS) GetPublicKey(ctx context.Context, req *kmspb.GetPublicKeyRequest, opts ...gax.CallOption) (*kmspb.PublicKey, error) {
+	k, ok := f.keys[req.Name]
+	if !ok {
+		return nil, errors.New("NotFound")
+	}
+	der, err := x509.MarshalPKIXPublicKey(k.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	p := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	return &kmspb.PublicKey{Pem: p, PemCrc32C: wrapperspb.Int64(int64(checksum([]byte(p)))), Algorithm: k.algorithm, Name: req.Name}, nil
+}
+
+func (f *fakeKMS) AsymmetricSign(ctx context.Context, req *kmspb.AsymmetricSignRequest, opts ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error) {
+	k := f.keys[req.Name]
+	digest := req.Digest.GetSha256()
+	if int64(checksum(digest)) != req.DigestCrc32C.GetValue() {
+		return nil, errors.New("digest checksum mismatch")
+	}
+	var signerOpts crypto.SignerOpts = crypto.SHA256
+	if k.algorithm == kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256 {
+		signerOpts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	}
+	sig, err := k.key.Sign(rand.Reader, digest, signerOpts)
+	if err != nil {
+		return nil, err
+	}
+	sum := int64(checksum(sig))
+	if f.corrupt {
+		sum++
+	}
+	return &kmspb.AsymmetricSignResponse{Signature: sig, SignatureCrc32C: wrapperspb.Int64(sum), VerifiedDigestCrc32C: true, Name: req.Name}, nil
+}
+
+func TestSigner(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &fakeKMS{keys: map[string]fakeKey{
+		"ec/1":  {ecKey, kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256},
+		"rsa/1": {rsaKey, kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256},
+		"k1/1":  {ecKey, kmspb.CryptoKeyVersion_EC_SIGN_SECP256K1_SHA256},
+	}}
+	ctx := context.Background()
+
+	for name, alg := range map[string]string{"ec/1": oidc.ES256, "rsa/1": oidc.PS256} {
+		signer, err := NewSigner(ctx, client, name, "kid1")
+		if err != nil {
+			t.Errorf("%s: creating signer: %v", name, err)
+			continue
+		}
+		if signer.Algorithm() != alg {
+			t.Errorf("%s: expected algorithm %s, got %s", name, alg, signer.Algorithm())
+		}
+		assertion, err := oidc.ClientAssertion(ctx, signer, "client", "https://foo/token", 0)
+		if err != nil {
+			t.Errorf("%s: signing: %v", name, err)
+			continue
+		}
+		keySet := &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{client.keys[name].key.Public()}}
+		verifier := oidc.NewVerifier("client", keySet, &oidc.Config{ClientID: "https://foo/token", SupportedSigningAlgs: []string{alg}})
+		if _, err := verifier.Verify(ctx, assertion); err != nil {
+			t.Errorf("%s: verifying assertion: %v", name, err)
+		}
+	}
+
+	if _, err := NewSigner(ctx, client, "k1/1", ""); err == nil {
+		t.Errorf("expected error for unsupported key algorithm")
+	}
+	signer, err := NewSigner(ctx, client, "ec/1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.corrupt = true
+	if _, err := oidc.SignJWT(ctx, signer, map[string]string{"sub": "client"}); err == nil {
+		t.Errorf("expected error for corrupted signature")
+	}
+}
@@ -0,0 +1,34 @@
+module github.com/coreos/go-oidc/v3/oidc/gcpkms
+
+go 1.26.0
+
+// See "Nested Modules" in CONTRIBUTING.md before releasing this module.
+replace github.com/coreos/go-oidc/v3 => ../..
+
+require (
+	cloud.google.com/go/kms v1.35.0
+	github.com/coreos/go-oidc/v3 v3.21.0
+	github.com/googleapis/gax-go/v2 v2.26.2
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	cloud.google.com/go/longrunning v1.2.0 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/go-jose/go-jose/v3 v3.0.1 // indirect
+	github.com/go-logr/logr v1.4.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/auto/sdk v1.2.1 // indirect
+	go.opentelemetry.io/otel v1.45.0 // indirect
+	go.opentelemetry.io/otel/metric v1.45.0 // indirect
+	go.opentelemetry.io/otel/trace v1.45.0 // indirect
+	golang.org/x/crypto v0.55.0 // indirect
+	golang.org/x/net v0.58.0 // indirect
+	golang.org/x/oauth2 v0.36.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/text v0.41.0 // indirect
+	google.golang.org/api v0.288.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20260715232425-e75dac1f907d // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260715232425-e75dac1f907d // indirect
+	google.golang.org/grpc v1.83.2 // indirect
+)
@@ -0,0 +1,121 @@
+package googleoidc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// certsTransport serves Google's keys without network access.
+type certsTransport struct {
+	jwks []byte
+}
+
+func (c *certsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.String() != CertsURL {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Request: r}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(c.jwks)),
+		Request:    r,
+	}, nil
+}
+
+func TestVerifyGoogleIDToken(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: priv.Public(), KeyID: "k1", Algorithm: "RS256", Use: "sig"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: priv, KeyID: "k1"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(claims string) string {
+		jws, err := signer.Sign([]byte(claims))
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := jws.CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	client := WithHTTPClient(&http.Client{Transport: &certsTransport{jwks: jwks}})
+
+	tests := []struct {
+		name    string
+		claims  string
+		opts    []Option
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			claims: `{"iss":"https://accounts.google.com","aud":"client","exp":4102444800,"email":"a@example.com","email_verified":true}`,
+		},
+		{
+			name:   "issuer without scheme",
+			claims: `{"iss":"accounts.google.com","aud":"client","exp":4102444800}`,
+		},
+		{
+			name:   "hosted domain",
+			claims: `{"iss":"https://accounts.google.com","aud":"client","exp":4102444800,"hd":"example.com"}`,
+			opts:   []Option{WithHostedDomain("example.org", "example.com")},
+		},
+		{
+			name:    "wrong hosted domain",
+			claims:  `{"iss":"https://accounts.google.com","aud":"client","exp":4102444800,"hd":"evil.com"}`,
+			opts:    []Option{WithHostedDomain("example.com")},
+			wantErr: "isn't allowed",
+		},
+		{
+			name:    "consumer account",
+			claims:  `{"iss":"https://accounts.google.com","aud":"client","exp":4102444800,"email":"a@example.com"}`,
+			opts:    []Option{WithHostedDomain("example.com")},
+			wantErr: "Google Workspace",
+		},
+		{
+			name:    "unverified email",
+			claims:  `{"iss":"https://accounts.google.com","aud":"client","exp":4102444800,"email":"a@example.com"}`,
+			opts:    []Option{RequireVerifiedEmail()},
+			wantErr: "isn't verified",
+		},
+		{
+			name:    "wrong audience",
+			claims:  `{"iss":"https://accounts.google.com","aud":"other","exp":4102444800}`,
+			wantErr: "expected audience",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := VerifyGoogleIDToken(context.Background(), sign(test.claims), "client", append(test.opts, client)...)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("verifying token: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := VerifyGoogleIDToken(context.Background(), "token", ""); err == nil {
+		t.Errorf("expected audience to be required")
+	}
+}
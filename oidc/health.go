@@ -0,0 +1,97 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// HealthCheckOption configures a call to Provider.HealthCheck.
+type HealthCheckOption func(*healthCheckOptions)
+
+type healthCheckOptions struct {
+	canary       string
+	canaryConfig *Config
+}
+
+// WithCanaryToken also verifies the token with the provider's current keys, to
+// detect providers that serve keys which can't verify their tokens. The
+// configuration is used as for Provider.Verifier. Long lived canary tokens
+// usually need Config.SkipExpiryCheck.
+func WithCanaryToken(rawToken string, config *Config) HealthCheckOption {
+	return func(o *healthCheckOptions) {
+		o.canary = rawToken
+		o.canaryConfig = config
+	}
+}
+
+// HealthCheck reports whether the provider can currently be used to verify
+// tokens, for readiness probes of services that can't function without it.
+//
+// It fetches the discovery document, if the provider was discovered, and
+// checks that the issuer and key set URL haven't changed since the provider
+// was created. Other changes are reported to the MetadataChange hook of the
+// provider's Observer. It then fetches the key set and checks that it holds
+// at least one usable key. Requests bypass the caches of the provider's
+// verifiers, but a key set that is backing off after rate limiting is
+// reported as unhealthy without a request.
+//
+//	http.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+//		if err := provider.HealthCheck(r.Context()); err != nil {
+//			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+//			return
+//		}
+//		w.WriteHeader(http.StatusOK)
+//	})
+func (p *Provider) HealthCheck(ctx context.Context, opts ...HealthCheckOption) error {
+	var o healthCheckOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	ctx = p.clientContext(ctx)
+	ctx = ResponseLimitsContext(ctx, p.limits)
+
+	if p.discoveryURL != "" {
+		var prev providerJSON
+		if err := json.Unmarshal(p.rawClaims, &prev); err != nil {
+			return fmt.Errorf("oidc: health check: decoding previous discovery document: %v", err)
+		}
+		doc, body, err := fetchDiscovery(ctx, p.discoveryURL)
+		if err != nil {
+			return fmt.Errorf("oidc: health check: discovery: %w", err)
+		}
+		// Report other changes to the observer, without failing the check.
+		p.compareMetadata(body)
+		if doc.Issuer != prev.Issuer {
+			return fmt.Errorf("oidc: health check: provider changed issuer from %q to %q", prev.Issuer, doc.Issuer)
+		}
+		if doc.JWKSURL != prev.JWKSURL {
+			return fmt.Errorf("oidc: health check: provider changed jwks_uri from %q to %q", prev.JWKSURL, doc.JWKSURL)
+		}
+	}
+
+	if p.jwksURL == "" {
+		return errors.New("oidc: health check: provider has no jwks_uri")
+	}
+	if r, ok := p.remoteKeySet().(*RemoteKeySet); ok {
+		if err := r.limiter.check(r.now()); err != nil {
+			return fmt.Errorf("oidc: health check: keys: %w", err)
+		}
+	}
+	keySet := newRemoteKeySet(ctx, p.jwksURL, p.now)
+	keys, err := keySet.keysFromRemote(ctx)
+	if err != nil {
+		return fmt.Errorf("oidc: health check: keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return errors.New("oidc: health check: key set contains no usable keys")
+	}
+
+	if o.canary != "" {
+		if _, err := p.newVerifier(keySet, o.canaryConfig).Verify(ctx, o.canary); err != nil {
+			return fmt.Errorf("oidc: health check: canary token: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func TestHealthCheck(t *testing.T) {
+	key, other := newRSAKey(t), newRSAKey(t)
+	var (
+		jwksPath = "/keys"
+		keys     = []jose.JSONWebKey{key.jwk()}
+		s        *httptest.Server
+	)
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":"%s%s"}`, s.URL, s.URL, jwksPath)
+		case "/keys":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: keys})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+	ctx := context.Background()
+	p, err := NewProvider(ctx, s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{ClientID: "app"}
+	canary := key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"app","sub":"canary","exp":4102444800}`, s.URL)))
+
+	if err := p.HealthCheck(ctx, WithCanaryToken(canary, config)); err != nil {
+		t.Fatalf("expected healthy provider, got %v", err)
+	}
+
+	keys = []jose.JSONWebKey{other.jwk()}
+	if err := p.HealthCheck(ctx); err != nil {
+		t.Errorf("expected healthy provider without canary, got %v", err)
+	}
+	if err := p.HealthCheck(ctx, WithCanaryToken(canary, config)); err == nil || !strings.Contains(err.Error(), "canary") {
+		t.Errorf("expected canary signed by a removed key to fail, got %v", err)
+	}
+
+	keys = nil
+	if err := p.HealthCheck(ctx); err == nil {
+		t.Errorf("expected empty key set to fail")
+	}
+
+	keys = []jose.JSONWebKey{key.jwk()}
+	jwksPath = "/moved"
+	if err := p.HealthCheck(ctx); err == nil || !strings.Contains(err.Error(), "jwks_uri") {
+		t.Errorf("expected changed jwks_uri to fail, got %v", err)
+	}
+
+	s.Close()
+	if err := p.HealthCheck(ctx); err == nil {
+		t.Errorf("expected unreachable provider to fail")
+	}
+}
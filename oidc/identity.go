@@ -0,0 +1,287 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Identity is a provider independent view of the user a token was issued to.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+	// Extra holds additional attributes configured by IdentityMapper.Extra.
+	Extra map[string][]string
+}
+
+// IdentityMapper converts verified ID Tokens into Identities, so applications
+// can support arbitrary providers through configuration instead of code.
+//
+// Each mapping is either a claim name, such as "cognito:groups", a path to a
+// nested claim, such as "realm_access.roles", or a text/template executed with
+// the token's claims, such as "{{.given_name}} {{.family_name}}". Claim names
+// take precedence over paths, so claims containing dots, such as
+// "https://example.com/roles", can be used directly. Templates fail if they
+// reference a claim the token doesn't have.
+//
+//	mapper := &oidc.IdentityMapper{
+//		Subject:      "email",
+//		Groups:       []string{"groups", "cognito:groups"},
+//		GroupsPrefix: "oidc:",
+//		Extra:        map[string]string{"tenant": "tid"},
+//	}
+//	identity, err := mapper.Map(ctx, idToken)
+type IdentityMapper struct {
+	// Subject is the mapping of Identity.Subject. Defaults to "sub".
+	Subject string
+	// SubjectPrefix is prepended to the subject, to keep users of different
+	// providers apart.
+	SubjectPrefix string
+
+	// Email is the mapping of Identity.Email. Defaults to "email".
+	Email string
+	// RequireVerifiedEmail fails mapping if the token has an email address
+	// and its "email_verified" claim isn't true.
+	RequireVerifiedEmail bool
+
+	// Name is the mapping of Identity.Name. Defaults to "name".
+	Name string
+
+	// Groups lists the mappings of Identity.Groups. Groups of all mappings are
+	// combined. Defaults to "groups".
+	Groups []string
+	// GroupsPrefix is prepended to each group.
+	GroupsPrefix string
+
+	// Extra maps keys of Identity.Extra to mappings. Keys with no value are
+	// omitted.
+	Extra map[string]string
+
+	// DistributedClaimsVerifier, if set, resolves groups provided as
+	// distributed claims. The JWTs returned by claim sources are verified with
+	// this verifier.
+	DistributedClaimsVerifier *IDTokenVerifier
+	// ClaimResolver, if set, resolves groups provided as distributed claims
+	// instead of DistributedClaimsVerifier. It's used for claim sources that
+	// don't return JWTs, such as Microsoft Graph, or that are run by other
+	// issuers, see DistributedClaimsResolver.
+	ClaimResolver ClaimResolver
+}
+
+// Map converts a verified ID Token into an Identity.
+func (m *IdentityMapper) Map(ctx context.Context, token *IDToken) (*Identity, error) {
+	claims, err := decodeClaimMap(token.claims)
+	if err != nil {
+		return nil, err
+	}
+	id := &Identity{}
+
+	subject, err := mapString(claims, orDefault(m.Subject, "sub"))
+	if err != nil {
+		return nil, err
+	}
+	if subject == "" {
+		return nil, fmt.Errorf("oidc: subject mapping %q has no value", orDefault(m.Subject, "sub"))
+	}
+	id.Subject = m.SubjectPrefix + subject
+
+	if id.Email, err = mapString(claims, orDefault(m.Email, "email")); err != nil {
+		return nil, err
+	}
+	if id.Email != "" {
+		var v struct {
+			EmailVerified LenientBool `json:"email_verified"`
+		}
+		if err := json.Unmarshal(token.claims, &v); err != nil {
+			return nil, fmt.Errorf("oidc: decoding email_verified: %v", err)
+		}
+		id.EmailVerified = bool(v.EmailVerified)
+		if m.RequireVerifiedEmail && !id.EmailVerified {
+			return nil, fmt.Errorf("oidc: email %q isn't verified", id.Email)
+		}
+	}
+
+	if id.Name, err = mapString(claims, orDefault(m.Name, "name")); err != nil {
+		return nil, err
+	}
+
+	groupMappings := m.Groups
+	if len(groupMappings) == 0 {
+		groupMappings = []string{"groups"}
+	}
+	seen := make(map[string]bool)
+	for _, mapping := range groupMappings {
+		groups, err := m.mapGroups(ctx, token, claims, mapping)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range groups {
+			if g = m.GroupsPrefix + g; !seen[g] {
+				seen[g] = true
+				id.Groups = append(id.Groups, g)
+			}
+		}
+	}
+
+	for key, mapping := range m.Extra {
+		values, err := mapValues(claims, mapping)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if id.Extra == nil {
+			id.Extra = make(map[string][]string)
+		}
+		id.Extra[key] = values
+	}
+	return id, nil
+}
+
+// mapGroups evaluates a groups mapping, falling back to a distributed claim of
+// the same name.
+func (m *IdentityMapper) mapGroups(ctx context.Context, token *IDToken, claims map[string]interface{}, mapping string) ([]string, error) {
+	if _, ok := lookupClaim(claims, mapping); ok || isTemplate(mapping) {
+		return mapValues(claims, mapping)
+	}
+	src, ok := token.ClaimSource(mapping)
+	if !ok {
+		return nil, nil
+	}
+	if m.ClaimResolver != nil {
+		value, err := m.ClaimResolver.ResolveClaim(ctx, mapping, src)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: resolving distributed claim %q: %v", mapping, err)
+		}
+		d := json.NewDecoder(bytes.NewReader(value))
+		d.UseNumber()
+		var resolved interface{}
+		if err := d.Decode(&resolved); err != nil {
+			return nil, fmt.Errorf("oidc: decoding distributed claim %q: %v", mapping, err)
+		}
+		return mapValues(map[string]interface{}{mapping: resolved}, mapping)
+	}
+	if m.DistributedClaimsVerifier == nil {
+		return nil, fmt.Errorf("oidc: claim %q is distributed, but no ClaimResolver or DistributedClaimsVerifier is configured", mapping)
+	}
+	payload, err := resolveDistributedClaim(ctx, m.DistributedClaimsVerifier, claimSource(src))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: resolving distributed claim %q: %v", mapping, err)
+	}
+	distributed, err := decodeClaimMap(payload)
+	if err != nil {
+		return nil, err
+	}
+	return mapValues(distributed, mapping)
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func decodeClaimMap(payload []byte) (map[string]interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(payload))
+	d.UseNumber()
+	var claims map[string]interface{}
+	if err := d.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("oidc: decoding claims: %v", err)
+	}
+	return claims, nil
+}
+
+func isTemplate(mapping string) bool {
+	return strings.Contains(mapping, "{{")
+}
+
+// lookupClaim returns the claim with the given name, or the nested claim at
+// the given dot separated path.
+func lookupClaim(claims map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := claims[name]; ok {
+		return v, true
+	}
+	var v interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[part]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// mapValues evaluates a mapping, flattening arrays into multiple values.
+func mapValues(claims map[string]interface{}, mapping string) ([]string, error) {
+	if isTemplate(mapping) {
+		tmpl, err := template.New("mapping").Option("missingkey=error").Parse(mapping)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: parsing mapping %q: %v", mapping, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, claims); err != nil {
+			return nil, fmt.Errorf("oidc: evaluating mapping %q: %v", mapping, err)
+		}
+		if b.Len() == 0 {
+			return nil, nil
+		}
+		return []string{b.String()}, nil
+	}
+	v, ok := lookupClaim(claims, mapping)
+	if !ok || v == nil {
+		return nil, nil
+	}
+	values, ok := v.([]interface{})
+	if !ok {
+		values = []interface{}{v}
+	}
+	var out []string
+	for _, value := range values {
+		s, err := claimString(value)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: mapping %q: %v", mapping, err)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// mapString evaluates a mapping that must have at most one value.
+func mapString(claims map[string]interface{}, mapping string) (string, error) {
+	values, err := mapValues(claims, mapping)
+	if err != nil {
+		return "", err
+	}
+	switch len(values) {
+	case 0:
+		return "", nil
+	case 1:
+		return values[0], nil
+	}
+	return "", fmt.Errorf("oidc: mapping %q has %d values, expected one", mapping, len(values))
+}
+
+func claimString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", errors.New("claim isn't a string, number, boolean, or array of them")
+}
@@ -0,0 +1,141 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIdentityMapper(t *testing.T) {
+	claims := []byte(`{
+		"sub": "user1",
+		"email": "jane@example.com",
+		"email_verified": "true",
+		"given_name": "Jane",
+		"family_name": "Doe",
+		"groups": ["admins", "devs"],
+		"cognito:groups": ["devs", "ops"],
+		"realm_access": {"roles": ["reader"]},
+		"https://example.com/tenant": "t1",
+		"level": 3
+	}`)
+	token := &IDToken{claims: claims}
+
+	tests := []struct {
+		name    string
+		mapper  IdentityMapper
+		want    *Identity
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			mapper: IdentityMapper{},
+			want: &Identity{
+				Subject:       "user1",
+				Email:         "jane@example.com",
+				EmailVerified: true,
+				Groups:        []string{"admins", "devs"},
+			},
+		},
+		{
+			name: "custom mappings",
+			mapper: IdentityMapper{
+				Subject:       "email",
+				SubjectPrefix: "oidc:",
+				Name:          "{{.given_name}} {{.family_name}}",
+				Groups:        []string{"groups", "cognito:groups", "realm_access.roles"},
+				GroupsPrefix:  "idp:",
+				Extra: map[string]string{
+					"tenant":  "https://example.com/tenant",
+					"level":   "level",
+					"missing": "missing",
+				},
+			},
+			want: &Identity{
+				Subject:       "oidc:jane@example.com",
+				Email:         "jane@example.com",
+				EmailVerified: true,
+				Name:          "Jane Doe",
+				Groups:        []string{"idp:admins", "idp:devs", "idp:ops", "idp:reader"},
+				Extra:         map[string][]string{"tenant": {"t1"}, "level": {"3"}},
+			},
+		},
+		{
+			name:    "missing subject",
+			mapper:  IdentityMapper{Subject: "preferred_username"},
+			wantErr: "has no value",
+		},
+		{
+			name:    "multiple subjects",
+			mapper:  IdentityMapper{Subject: "groups"},
+			wantErr: "expected one",
+		},
+		{
+			name:    "template with missing claim",
+			mapper:  IdentityMapper{Name: "{{.nickname}}"},
+			wantErr: "evaluating mapping",
+		},
+		{
+			name:    "object claim",
+			mapper:  IdentityMapper{Groups: []string{"realm_access"}},
+			wantErr: "isn't a string",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.mapper.Map(context.Background(), token)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+
+	unverified := &IDToken{claims: []byte(`{"sub":"user1","email":"jane@example.com"}`)}
+	if _, err := (&IdentityMapper{RequireVerifiedEmail: true}).Map(context.Background(), unverified); err == nil {
+		t.Errorf("expected unverified email to be rejected")
+	}
+}
+
+func TestIdentityMapperDistributedGroups(t *testing.T) {
+	key := newRSAKey(t)
+	groupsJWT := key.sign(t, []byte(`{"iss":"https://foo","aud":"client1","exp":4102444800,"groups":["remote1","remote2"]}`))
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(groupsJWT))
+	}))
+	defer s.Close()
+
+	token := &IDToken{
+		claims:            []byte(`{"sub":"user1","_claim_names":{"groups":"src1"}}`),
+		distributedClaims: map[string]claimSource{"groups": {Endpoint: s.URL, AccessToken: "at"}},
+	}
+	mapper := &IdentityMapper{}
+	if _, err := mapper.Map(context.Background(), token); err == nil {
+		t.Errorf("expected error without DistributedClaimsVerifier")
+	}
+
+	mapper.DistributedClaimsVerifier = NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "client1"})
+	id, err := mapper.Map(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"remote1", "remote2"}; !reflect.DeepEqual(id.Groups, want) {
+		t.Errorf("got groups %v, want %v", id.Groups, want)
+	}
+}
@@ -0,0 +1,228 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Names of the checks listed by a VerificationReport.
+const (
+	CheckAlgorithm     = "algorithm"
+	CheckSignature     = "signature"
+	CheckIssuer        = "issuer"
+	CheckAudience      = "audience"
+	CheckEmailVerified = "email_verified"
+	CheckExpiry        = "expiry"
+	CheckNotBefore     = "not_before"
+	CheckNonce         = "nonce"
+	CheckAtHash        = "at_hash"
+)
+
+// VerificationCheck is the outcome of one check of a VerificationReport.
+type VerificationCheck struct {
+	Name string
+	// Skipped is true if the check wasn't performed, because it was disabled
+	// by the configuration or lacked the input it needs. Detail explains why.
+	Skipped bool
+	// Err is the reason the check failed, or nil if it passed or was skipped.
+	Err error
+	// Detail is a human readable description of the outcome.
+	Detail string
+}
+
+// Passed reports whether the check was performed and succeeded.
+func (c *VerificationCheck) Passed() bool {
+	return !c.Skipped && c.Err == nil
+}
+
+// VerificationReport lists the outcome of every check made by Inspect.
+type VerificationReport struct {
+	// Header of the token.
+	Header TokenHeader
+	// Claims holds the raw claims of the token. They can't be trusted unless
+	// the report is valid.
+	Claims json.RawMessage
+	Checks []VerificationCheck
+	// Token is the verified token if all checks passed or were skipped, and
+	// nil otherwise.
+	Token *IDToken
+}
+
+// Valid reports whether no check failed.
+func (r *VerificationReport) Valid() bool {
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Check returns the outcome of the named check.
+func (r *VerificationReport) Check(name string) (VerificationCheck, bool) {
+	for _, c := range r.Checks {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return VerificationCheck{}, false
+}
+
+// Err returns the errors of the failed checks, or nil if the report is valid.
+func (r *VerificationReport) Err() error {
+	var msgs []string
+	var first error
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			if first == nil {
+				first = c.Err
+			}
+			msgs = append(msgs, fmt.Sprintf("%s: %v", c.Name, c.Err))
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	return &inspectError{msg: strings.Join(msgs, "; "), err: first}
+}
+
+// inspectError lists the failed checks and unwraps to the first one.
+type inspectError struct {
+	msg string
+	err error
+}
+
+func (e *inspectError) Error() string { return "oidc: token failed checks: " + e.msg }
+func (e *inspectError) Unwrap() error { return e.err }
+
+// Inspect runs every check of Verify, as configured by the verifier and the
+// options, and reports the outcome of each, rather than stopping at the first
+// failure. It's intended for debugging tools and admin UIs, where knowing that
+// a token is both expired and meant for another audience saves a round trip.
+//
+// An error is only returned if the token can't be parsed. Use
+// VerificationReport.Valid to tell whether the token is valid, and only trust
+// its claims if it is. Unlike Verify, the signature is checked even if other
+// checks fail, which may refresh the key set, and the VerificationCache and
+// Observer aren't used.
+func (v *IDTokenVerifier) Inspect(ctx context.Context, rawIDToken string, opts ...VerifyOption) (*VerificationReport, error) {
+	rawIDToken = trimToken(rawIDToken)
+	if max := v.config.maxTokenSize(); len(rawIDToken) > max {
+		return nil, &MalformedTokenError{Err: fmt.Errorf("token size %d exceeds maximum of %d bytes", len(rawIDToken), max)}
+	}
+	o := &verifyOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	jws, err := parseCompactJWS(rawIDToken)
+	if err != nil {
+		return nil, &MalformedTokenError{Err: err}
+	}
+	defer jws.release()
+	header, err := jws.decodeHeader()
+	if err != nil {
+		return nil, &MalformedTokenError{Err: err}
+	}
+	if err := v.config.checkClaims(jws.payload); err != nil {
+		return nil, &MalformedTokenError{Err: err}
+	}
+	var token idToken
+	if err := json.Unmarshal(jws.payload, &token); err != nil {
+		return nil, &MalformedTokenError{Err: fmt.Errorf("failed to unmarshal claims: %v", err)}
+	}
+	t, err := newIDToken(rawIDToken, jws.payload, &token)
+	if err != nil {
+		return nil, &MalformedTokenError{Err: err}
+	}
+	t.sigAlgorithm = header.Algorithm
+	t.header = header.tokenHeader()
+
+	r := &VerificationReport{
+		Header: t.header,
+		Claims: jws.payload,
+	}
+	pass := func(name, detail string) {
+		r.Checks = append(r.Checks, VerificationCheck{Name: name, Detail: detail})
+	}
+	skip := func(name, detail string) {
+		r.Checks = append(r.Checks, VerificationCheck{Name: name, Skipped: true, Detail: detail})
+	}
+	check := func(name string, err error, detail string) {
+		if err != nil {
+			r.Checks = append(r.Checks, VerificationCheck{Name: name, Err: err, Detail: err.Error()})
+			return
+		}
+		pass(name, detail)
+	}
+
+	if v.config.InsecureSkipSignatureCheck {
+		skip(CheckAlgorithm, "signature checks are disabled")
+		skip(CheckSignature, "signature checks are disabled")
+	} else if err := v.checkAlgorithm(header.Algorithm); err != nil {
+		check(CheckAlgorithm, err, "")
+		skip(CheckSignature, "not checked with an unsupported algorithm")
+	} else {
+		pass(CheckAlgorithm, fmt.Sprintf("%s is allowed", header.Algorithm))
+		check(CheckSignature, v.verifySignature(ctx, rawIDToken, jws, t.header), "signed by a key of the key set")
+	}
+
+	if v.config.SkipIssuerCheck {
+		skip(CheckIssuer, "issuer check is disabled")
+	} else {
+		check(CheckIssuer, v.checkIssuer(t.Issuer), fmt.Sprintf("issued by %q", t.Issuer))
+	}
+
+	if o.audience == nil && v.config.SkipClientIDCheck {
+		skip(CheckAudience, "client ID check is disabled")
+	} else {
+		check(CheckAudience, v.checkAudience(t, o), fmt.Sprintf("audience %q is expected", t.Audience))
+	}
+
+	if !v.config.RequireVerifiedEmail {
+		skip(CheckEmailVerified, "verified email isn't required")
+	} else {
+		email, _ := t.Email()
+		check(CheckEmailVerified, checkEmailVerified(t), fmt.Sprintf("email %q is verified", email))
+	}
+
+	if v.config.SkipExpiryCheck {
+		skip(CheckExpiry, "expiry check is disabled")
+		skip(CheckNotBefore, "expiry check is disabled")
+	} else {
+		now := v.config.now()
+		check(CheckExpiry, checkExpiry(t.Expiry, now), fmt.Sprintf("expires at %v", t.Expiry))
+		if token.NotBefore == nil {
+			skip(CheckNotBefore, "token has no nbf claim")
+		} else {
+			check(CheckNotBefore, checkNotBefore(token.NotBefore, now), "nbf is in the past")
+		}
+	}
+
+	switch {
+	case o.nonce == nil:
+		skip(CheckNonce, "no nonce expected")
+	case subtle.ConstantTimeCompare([]byte(t.Nonce), []byte(*o.nonce)) != 1:
+		check(CheckNonce, errors.New("oidc: id token nonce does not match"), "")
+	default:
+		pass(CheckNonce, "nonce matches")
+	}
+
+	switch {
+	case o.accessToken == nil:
+		skip(CheckAtHash, "no access token given")
+	case t.AccessTokenHash == "":
+		skip(CheckAtHash, "token has no at_hash claim")
+	default:
+		check(CheckAtHash, t.VerifyAccessToken(*o.accessToken), "at_hash matches the access token")
+	}
+
+	if r.Valid() {
+		r.Token = t
+	}
+	return r, nil
+}
@@ -0,0 +1,70 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	key, other := newRSAKey(t), newRSAKey(t)
+	atHash, err := tokenHash(RS256, "access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		ClientID: "app",
+		Now:      func() time.Time { return time.Unix(2000, 0) },
+	})
+	ctx := context.Background()
+
+	raw := key.sign(t, []byte(`{"iss":"https://foo","aud":"app","sub":"jane","exp":4102444800,"nonce":"n","at_hash":"`+atHash+`"}`))
+	r, err := verifier.Inspect(ctx, raw, WithNonce("n"), WithAccessToken("access-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Valid() || r.Token == nil || r.Token.Subject != "jane" || r.Err() != nil {
+		t.Fatalf("expected valid report, got %+v", r.Checks)
+	}
+	for _, c := range r.Checks {
+		if !c.Passed() && c.Name != CheckNotBefore && c.Name != CheckEmailVerified {
+			t.Errorf("check %s didn't pass: %+v", c.Name, c)
+		}
+	}
+
+	if _, err := verifier.Verify(ctx, raw, WithAccessToken("other-token")); err == nil {
+		t.Errorf("expected Verify to check at_hash against the access token")
+	}
+
+	// Every failure is reported, not only the first.
+	raw = other.sign(t, []byte(`{"iss":"https://bar","aud":"other","sub":"jane","exp":1000,"nbf":4102444800,"nonce":"x","at_hash":"AAAA"}`))
+	r, err = verifier.Inspect(ctx, raw, WithNonce("n"), WithAccessToken("access-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Valid() || r.Token != nil {
+		t.Fatalf("expected invalid report")
+	}
+	for _, name := range []string{CheckSignature, CheckIssuer, CheckAudience, CheckExpiry, CheckNotBefore, CheckNonce, CheckAtHash} {
+		if c, ok := r.Check(name); !ok || c.Err == nil {
+			t.Errorf("expected check %s to fail, got %+v", name, c)
+		}
+	}
+	if c, _ := r.Check(CheckAlgorithm); !c.Passed() {
+		t.Errorf("expected algorithm check to pass, got %+v", c)
+	}
+	if err := r.Err(); err == nil || !strings.Contains(err.Error(), "audience: ") {
+		t.Errorf("expected error listing failed checks, got %v", err)
+	}
+	var issuerErr *InvalidIssuerError
+	if c, _ := r.Check(CheckIssuer); !errors.As(c.Err, &issuerErr) {
+		t.Errorf("expected typed issuer error, got %v", c.Err)
+	}
+
+	if _, err := verifier.Inspect(ctx, "not.a.token"); err == nil {
+		t.Errorf("expected malformed token to fail")
+	}
+}
@@ -0,0 +1,264 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInactiveToken is returned by IntrospectionVerifier for tokens the
+// authorization server reports as inactive, such as revoked, expired or
+// unknown tokens.
+var ErrInactiveToken = errors.New("oidc: token is not active")
+
+// IntrospectionConfig is the configuration of an IntrospectionVerifier.
+type IntrospectionConfig struct {
+	// ClientID and ClientSecret authenticate the resource server to the
+	// introspection endpoint with HTTP basic authentication.
+	ClientID     string
+	ClientSecret string
+
+	// Audience, if set, must be part of the "aud" of introspected tokens.
+	Audience string
+	// Issuer, if set, must match the "iss" of introspected tokens that carry
+	// one.
+	Issuer string
+
+	// TokenTypeHint is sent as the token_type_hint parameter. Defaults to
+	// "access_token".
+	TokenTypeHint string
+
+	// Now overrides the clock used to check the "exp" claim and expire cached
+	// responses. Defaults to time.Now.
+	Now func() time.Time
+
+	// Cache, if set, holds recent introspection responses. Tokens found in the
+	// cache are checked against the cached response without contacting the
+	// introspection endpoint.
+	Cache *IntrospectionCache
+
+	// RequireSignedResponses requests signed JWT introspection responses, as
+	// described by RFC 9701, and rejects unsigned ones. Responses are
+	// verified with KeySet, and must be addressed to ClientID.
+	RequireSignedResponses bool
+	// KeySet holds the authorization server's keys signed introspection
+	// responses are verified with. Setting it implies
+	// RequireSignedResponses. Defaults to the provider's key set for
+	// verifiers created by Provider.IntrospectionVerifier, which verify
+	// signed responses even if they aren't required.
+	KeySet KeySet
+	// SupportedSigningAlgs lists the algorithms signed introspection
+	// responses may be signed with. Defaults to the algorithms advertised by
+	// the provider for verifiers created by Provider.IntrospectionVerifier,
+	// and RS256 otherwise.
+	SupportedSigningAlgs []string
+	// MaxResponseAge bounds how long after their "iat" signed introspection
+	// responses are accepted, so a forwarded response doesn't outlive the
+	// revocation of its token, or lack of an "exp", by more than this.
+	// Defaults to DefaultMaxIntrospectionResponseAge.
+	MaxResponseAge time.Duration
+}
+
+func (c *IntrospectionConfig) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// IntrospectionVerifier verifies opaque tokens by asking the authorization
+// server about them, as described by RFC 7662.
+//
+// Introspection responses are returned as an IDToken, so they can be handled
+// like verified JWTs. The token's claims are the members of the response, such
+// as "scope", "client_id" and "username".
+//
+// Signed responses, returned with the IntrospectionJWTMediaType, are verified
+// before use: their signature, "typ" header, issuer and audience are checked,
+// and the token's claims are the members of their "token_introspection"
+// claim. IDToken.Raw returns the signed response.
+type IntrospectionVerifier struct {
+	endpoint string
+	config   *IntrospectionConfig
+	client   *http.Client
+	// clientOptions is set if client was built by provider options, which
+	// take precedence over the client of the context.
+	clientOptions bool
+
+	// keySet and algs are the provider's, used when the config sets none.
+	keySet KeySet
+	algs   []string
+}
+
+// NewIntrospectionVerifier returns a verifier using the introspection endpoint
+// at the given URL.
+func NewIntrospectionVerifier(endpoint string, config *IntrospectionConfig) *IntrospectionVerifier {
+	return &IntrospectionVerifier{endpoint: endpoint, config: config}
+}
+
+// IntrospectionVerifier returns a verifier using the provider's introspection
+// endpoint. It returns an error if the provider doesn't advertise one.
+func (p *Provider) IntrospectionVerifier(config *IntrospectionConfig) (*IntrospectionVerifier, error) {
+	if p.introspectionURL == "" {
+		return nil, errors.New("oidc: introspection endpoint is not supported by this provider")
+	}
+	v := NewIntrospectionVerifier(p.introspectionURL, config)
+	v.client = p.client
+	v.clientOptions = p.clientOptions
+	v.keySet = p.remoteKeySet()
+	v.algs = p.introspectionAlgs
+	if len(v.algs) == 0 {
+		v.algs = p.algorithms
+	}
+	if config.Issuer == "" {
+		cp := *config
+		cp.Issuer = p.issuer
+		v.config = &cp
+	}
+	return v, nil
+}
+
+type introspectionResponse struct {
+	Active   bool          `json:"active"`
+	Issuer   string        `json:"iss"`
+	Subject  string        `json:"sub"`
+	Audience StringOrArray `json:"aud"`
+	Expiry   *jsonTime     `json:"exp"`
+	IssuedAt jsonTime      `json:"iat"`
+
+	// jwt is the signed response the members were taken from, if any.
+	jwt string
+}
+
+// Verify introspects a token. It returns ErrInactiveToken if the token isn't
+// active. The WithAudience option overrides IntrospectionConfig.Audience, and
+// WithNonce isn't supported.
+func (v *IntrospectionVerifier) Verify(ctx context.Context, rawToken string, opts ...VerifyOption) (*IDToken, error) {
+	var o verifyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.nonce != nil {
+		return nil, errors.New("oidc: introspected tokens have no nonce")
+	}
+	cache := v.config.Cache
+	if cache != nil {
+		if body, r, ok := cache.get(rawToken, v.config.now()); ok {
+			return v.check(body, r, &o)
+		}
+	}
+	body, signed, err := v.introspect(ctx, rawToken)
+	if err != nil {
+		return nil, err
+	}
+	var jwt string
+	if signed {
+		jwt = string(body)
+		if body, err = v.verifyJWT(ctx, jwt); err != nil {
+			return nil, err
+		}
+	} else if v.requireSigned() {
+		return nil, errors.New("oidc: introspection response isn't signed")
+	}
+	var r introspectionResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode introspection response: %v", err)
+	}
+	r.jwt = jwt
+	if cache != nil {
+		cache.add(rawToken, body, &r, v.config.now())
+	}
+	return v.check(body, &r, &o)
+}
+
+func (v *IntrospectionVerifier) requireSigned() bool {
+	return v.config.RequireSignedResponses || v.config.KeySet != nil
+}
+
+// introspect returns the introspection response of a token, and whether it's
+// signed.
+func (v *IntrospectionVerifier) introspect(ctx context.Context, rawToken string) ([]byte, bool, error) {
+	hint := v.config.TokenTypeHint
+	if hint == "" {
+		hint = "access_token"
+	}
+	form := url.Values{"token": {rawToken}, "token_type_hint": {hint}}
+	req, err := http.NewRequest(http.MethodPost, v.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, false, fmt.Errorf("oidc: create introspection request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if v.requireSigned() {
+		req.Header.Set("Accept", IntrospectionJWTMediaType)
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+	if v.config.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(v.config.ClientID), url.QueryEscape(v.config.ClientSecret))
+	}
+	if v.client != nil && (v.clientOptions || getClient(ctx) == nil) {
+		ctx = ClientContext(ctx, v.client)
+	}
+	resp, err := doRequest(ctx, req)
+	if err != nil {
+		return nil, false, fmt.Errorf("oidc: introspection request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	// Introspection responses are small metadata documents, bounded like
+	// discovery documents.
+	body, err := readBody(resp, getResponseLimits(ctx).maxDiscoverySize())
+	if err != nil {
+		return nil, false, fmt.Errorf("oidc: reading introspection response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if oauthErr := parseOAuthError(resp, body); oauthErr != nil {
+			return nil, false, oauthErr
+		}
+		return nil, false, newHTTPError(resp, body, v.config.now())
+	}
+	// Signed responses are told apart by their media type. Some servers use
+	// the generic JWT media type instead of the one of RFC 9701.
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	signed := mediaType == IntrospectionJWTMediaType || mediaType == "application/jwt"
+	return body, signed, nil
+}
+
+// check validates an introspection response and converts it into a token.
+// Cached responses are checked again on every use, since the expected audience
+// and the current time may differ between calls.
+func (v *IntrospectionVerifier) check(body []byte, r *introspectionResponse, o *verifyOptions) (*IDToken, error) {
+	if !r.Active {
+		return nil, ErrInactiveToken
+	}
+	if v.config.Issuer != "" && r.Issuer != "" && r.Issuer != v.config.Issuer {
+		return nil, &InvalidIssuerError{Expected: v.config.Issuer, Actual: r.Issuer}
+	}
+	aud := v.config.Audience
+	if o.audience != nil {
+		aud = *o.audience
+	}
+	if aud != "" && !contains(r.Audience, aud) {
+		return nil, &InvalidAudienceError{Expected: aud, Actual: r.Audience}
+	}
+	t := &IDToken{
+		Issuer:   r.Issuer,
+		Subject:  r.Subject,
+		Audience: []string(r.Audience),
+		IssuedAt: time.Time(r.IssuedAt),
+		claims:   body,
+		raw:      r.jwt,
+	}
+	if r.Expiry != nil {
+		t.Expiry = time.Time(*r.Expiry)
+		if t.Expiry.Before(v.config.now()) {
+			return nil, &TokenExpiredError{Expiry: t.Expiry}
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,266 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func newIntrospectionServer(t *testing.T) *httptest.Server {
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/.well-known/openid-configuration" {
+			fmt.Fprintf(w, `{"issuer":%q,"introspection_endpoint":"%s/introspect"}`, s.URL, s.URL)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "rs" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		if r.PostFormValue("token_type_hint") != "access_token" {
+			t.Errorf("unexpected token_type_hint %q", r.PostFormValue("token_type_hint"))
+		}
+		switch r.PostFormValue("token") {
+		case "active":
+			fmt.Fprintf(w, `{"active":true,"iss":%q,"sub":"jane","aud":"api","scope":"read write","client_id":"app","exp":4102444800}`, s.URL)
+		case "expired":
+			fmt.Fprint(w, `{"active":true,"sub":"jane","aud":"api","exp":1000}`)
+		case "other-issuer":
+			fmt.Fprint(w, `{"active":true,"iss":"https://other","sub":"jane","aud":"api"}`)
+		default:
+			fmt.Fprint(w, `{"active":false}`)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestIntrospectionVerifier(t *testing.T) {
+	s := newIntrospectionServer(t)
+	ctx := context.Background()
+	p, err := NewProvider(ctx, s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := p.IntrospectionVerifier(&IntrospectionConfig{ClientID: "rs", ClientSecret: "secret", Audience: "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := v.Verify(ctx, "active")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Subject != "jane" || token.Issuer != s.URL || !token.Expiry.Equal(time.Unix(4102444800, 0)) {
+		t.Errorf("unexpected token %+v", token)
+	}
+	scopes, err := token.Scopes()
+	if err != nil || !scopes.ContainsAll("read", "write") {
+		t.Errorf("unexpected scopes %v, err=%v", scopes, err)
+	}
+	var claims struct {
+		ClientID string `json:"client_id"`
+	}
+	if err := token.Claims(&claims); err != nil || claims.ClientID != "app" {
+		t.Errorf("unexpected claims %+v, err=%v", claims, err)
+	}
+
+	if _, err := v.Verify(ctx, "revoked"); !errors.Is(err, ErrInactiveToken) {
+		t.Errorf("expected inactive token error, got %v", err)
+	}
+	var expiredErr *TokenExpiredError
+	if _, err := v.Verify(ctx, "expired"); !errors.As(err, &expiredErr) {
+		t.Errorf("expected expired token error, got %v", err)
+	}
+	var issuerErr *InvalidIssuerError
+	if _, err := v.Verify(ctx, "other-issuer"); !errors.As(err, &issuerErr) {
+		t.Errorf("expected invalid issuer error, got %v", err)
+	}
+	var audErr *InvalidAudienceError
+	if _, err := v.Verify(ctx, "active", WithAudience("other")); !errors.As(err, &audErr) {
+		t.Errorf("expected invalid audience error, got %v", err)
+	}
+	if _, err := v.Verify(ctx, "active", WithNonce("n")); err == nil {
+		t.Errorf("expected nonce to be rejected")
+	}
+
+	unauthenticated := NewIntrospectionVerifier(s.URL+"/introspect", &IntrospectionConfig{})
+	var oauthErr *OAuthError
+	if _, err := unauthenticated.Verify(ctx, "active"); !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_client" {
+		t.Errorf("expected invalid_client error, got %v", err)
+	}
+
+	if _, err := (&Provider{}).IntrospectionVerifier(&IntrospectionConfig{}); err == nil {
+		t.Errorf("expected error for provider without introspection endpoint")
+	}
+}
+
+func TestBearerMiddlewareIntrospection(t *testing.T) {
+	s := newIntrospectionServer(t)
+	v := NewIntrospectionVerifier(s.URL+"/introspect", &IntrospectionConfig{ClientID: "rs", ClientSecret: "secret"})
+	handler := BearerMiddleware(v, RequireScopes("write"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for token, want := range map[string]int{"active": http.StatusOK, "revoked": http.StatusUnauthorized} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("%s: got status %d, want %d", token, w.Code, want)
+		}
+	}
+}
+
+func TestIntrospectionCache(t *testing.T) {
+	var hits int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		switch r.PostFormValue("token") {
+		case "short-lived":
+			fmt.Fprint(w, `{"active":true,"sub":"jane","aud":"api","exp":1030}`)
+		case "long-lived":
+			fmt.Fprint(w, `{"active":true,"sub":"jane","aud":"api","exp":4102444800}`)
+		default:
+			fmt.Fprint(w, `{"active":false}`)
+		}
+	}))
+	defer s.Close()
+
+	now := time.Unix(1000, 0)
+	v := NewIntrospectionVerifier(s.URL, &IntrospectionConfig{
+		Now:   func() time.Time { return now },
+		Cache: NewIntrospectionCache(10, time.Minute, 10*time.Second),
+	})
+	ctx := context.Background()
+	verify := func(token string, wantHits int, wantErr bool, opts ...VerifyOption) {
+		t.Helper()
+		hits = 0
+		if _, err := v.Verify(ctx, token, opts...); (err != nil) != wantErr {
+			t.Errorf("%s: unexpected error %v", token, err)
+		}
+		if hits != wantHits {
+			t.Errorf("%s: got %d introspection requests, want %d", token, hits, wantHits)
+		}
+	}
+
+	verify("long-lived", 1, false)
+	verify("long-lived", 0, false)
+	verify("long-lived", 0, true, WithAudience("other"))
+	verify("short-lived", 1, false)
+	verify("revoked", 1, true)
+	verify("revoked", 0, true)
+
+	// Inactive responses expire first, then tokens at their "exp", then
+	// everything else at the cache's maximum age.
+	now = now.Add(20 * time.Second)
+	verify("revoked", 1, true)
+	verify("short-lived", 0, false)
+	now = now.Add(20 * time.Second)
+	verify("short-lived", 1, true)
+	verify("long-lived", 0, false)
+	now = now.Add(time.Minute)
+	verify("long-lived", 1, false)
+}
+
+func TestIntrospectionSignedResponses(t *testing.T) {
+	key := newECDSAKey(t)
+	signer, err := NewCryptoSigner(key.priv.(crypto.Signer), string(key.alg), key.keyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":"%[1]s/keys","introspection_endpoint":"%[1]s/introspect","introspection_signing_alg_values_supported":["ES256"]}`, s.URL)
+			return
+		case "/keys":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}})
+			return
+		}
+		client, _, _ := r.BasicAuth()
+		response := map[string]interface{}{"active": true, "iss": s.URL, "sub": "jane", "scope": "read", "exp": 4102444800}
+		token := r.PostFormValue("token")
+		if token == "unsigned" || (token == "negotiated" && r.Header.Get("Accept") != IntrospectionJWTMediaType) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		issuer := s.URL
+		if token == "other-issuer" {
+			issuer = "https://other"
+		}
+		jwt, err := SignIntrospectionResponse(r.Context(), signer, issuer, client, response)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", IntrospectionJWTMediaType+"; charset=utf-8")
+		w.Write([]byte(jwt))
+	}))
+	defer s.Close()
+
+	ctx := context.Background()
+	p, err := NewProvider(ctx, s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Signed responses are verified with the provider's keys, even if
+	// they weren't asked for.
+	v, err := p.IntrospectionVerifier(&IntrospectionConfig{ClientID: "rs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := v.Verify(ctx, "signed")
+	if err != nil {
+		t.Fatalf("verifying signed response: %v", err)
+	}
+	var claims struct {
+		Scope string `json:"scope"`
+	}
+	if err := token.Claims(&claims); err != nil || claims.Scope != "read" || token.Subject != "jane" {
+		t.Errorf("unexpected token %+v, claims %+v, err=%v", token, claims, err)
+	}
+	if token.Raw() == "" {
+		t.Errorf("expected signed response to be returned by Raw")
+	}
+	if token, err := v.Verify(ctx, "negotiated"); err != nil || token.Raw() != "" {
+		t.Errorf("expected unsigned response, got %v", err)
+	}
+	var issuerErr *InvalidIssuerError
+	if _, err := v.Verify(ctx, "other-issuer"); !errors.As(err, &issuerErr) {
+		t.Errorf("expected invalid issuer error, got %v", err)
+	}
+
+	required, err := p.IntrospectionVerifier(&IntrospectionConfig{ClientID: "rs", RequireSignedResponses: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, err := required.Verify(ctx, "negotiated"); err != nil || token.Raw() == "" {
+		t.Errorf("expected signed response, got %v", err)
+	}
+	if _, err := required.Verify(ctx, "unsigned"); err == nil {
+		t.Errorf("expected unsigned response to be rejected")
+	}
+
+	// Verifiers not created from a provider have no keys to verify with.
+	other := NewIntrospectionVerifier(s.URL+"/introspect", &IntrospectionConfig{
+		ClientID:               "rs",
+		Issuer:                 s.URL,
+		RequireSignedResponses: true,
+	})
+	if _, err := other.Verify(ctx, "signed"); err == nil {
+		t.Errorf("expected error without key set")
+	}
+}
@@ -0,0 +1,150 @@
+package oidc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// IssuerMatcher decides which issuers a verifier accepts. It's used by
+// providers that issue tokens under several issuers sharing one key set, such
+// as multi-tenant providers that embed the tenant in the issuer URL.
+//
+// Matchers only replace the comparison of the "iss" claim. Tokens must still be
+// signed by the verifier's key set, so matchers must not accept issuers whose
+// keys differ from it.
+type IssuerMatcher interface {
+	MatchIssuer(issuer string) bool
+}
+
+// IssuerMatcherFunc adapts a function to the IssuerMatcher interface.
+type IssuerMatcherFunc func(issuer string) bool
+
+// MatchIssuer calls f(issuer).
+func (f IssuerMatcherFunc) MatchIssuer(issuer string) bool {
+	return f(issuer)
+}
+
+// Issuers is an IssuerMatcher accepting a fixed set of issuers, compared
+// exactly.
+type Issuers []string
+
+// MatchIssuer reports whether issuer is one of the listed issuers.
+func (i Issuers) MatchIssuer(issuer string) bool {
+	return contains(i, issuer)
+}
+
+// LegacyIssuer is an issuer accepted until a deadline, while clients move to a
+// new issuer. See AcceptIssuerUntil.
+type LegacyIssuer struct {
+	Issuer string
+	Until  time.Time
+}
+
+// AcceptIssuerUntil accepts tokens of the old issuer until the deadline, for
+// migrations from one provider, or one issuer URL, to another. Add it to
+// Config.LegacyIssuers of the verifier of the new issuer:
+//
+//	verifier := provider.Verifier(&oidc.Config{
+//		ClientID: clientID,
+//		LegacyIssuers: []oidc.LegacyIssuer{
+//			oidc.AcceptIssuerUntil("https://old-idp.example.com", cutover),
+//		},
+//	})
+//
+// Each token verified with the old issuer is reported to the LegacyIssuer hook
+// of the verifier's Observer, to track the remaining traffic before the
+// cutover. As with IssuerMatcher, tokens of the old issuer must be signed by
+// the verifier's key set.
+func AcceptIssuerUntil(oldIssuer string, deadline time.Time) LegacyIssuer {
+	return LegacyIssuer{Issuer: oldIssuer, Until: deadline}
+}
+
+// AnyIssuer returns an IssuerMatcher accepting issuers accepted by any of the
+// matchers.
+func AnyIssuer(matchers ...IssuerMatcher) IssuerMatcher {
+	return IssuerMatcherFunc(func(issuer string) bool {
+		for _, m := range matchers {
+			if m.MatchIssuer(issuer) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// IssuerTemplate matches issuers against a URL template with placeholders in
+// braces, such as "https://login.microsoftonline.com/{tenantid}/v2.0". Each
+// placeholder matches one non-empty path segment.
+type IssuerTemplate struct {
+	template string
+	re       *regexp.Regexp
+}
+
+var placeholderRE = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// NewIssuerTemplate parses an issuer template.
+func NewIssuerTemplate(template string) (*IssuerTemplate, error) {
+	var (
+		expr  strings.Builder
+		names = map[string]bool{}
+		last  int
+	)
+	expr.WriteString("^")
+	for _, m := range placeholderRE.FindAllStringSubmatchIndex(template, -1) {
+		name := template[m[2]:m[3]]
+		if names[name] {
+			return nil, fmt.Errorf("oidc: issuer template %q repeats placeholder %q", template, name)
+		}
+		names[name] = true
+		if err := writeLiteral(&expr, template, template[last:m[0]]); err != nil {
+			return nil, err
+		}
+		expr.WriteString("(?P<" + name + ">[^/?#]+)")
+		last = m[1]
+	}
+	if err := writeLiteral(&expr, template, template[last:]); err != nil {
+		return nil, err
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed issuer template %q: %v", template, err)
+	}
+	return &IssuerTemplate{template: template, re: re}, nil
+}
+
+func writeLiteral(expr *strings.Builder, template, literal string) error {
+	if strings.ContainsAny(literal, "{}") {
+		return fmt.Errorf("oidc: malformed issuer template %q", template)
+	}
+	expr.WriteString(regexp.QuoteMeta(literal))
+	return nil
+}
+
+// String returns the template.
+func (t *IssuerTemplate) String() string {
+	return t.template
+}
+
+// MatchIssuer reports whether issuer matches the template.
+func (t *IssuerTemplate) MatchIssuer(issuer string) bool {
+	return t.re.MatchString(issuer)
+}
+
+// Match returns the values of the template's placeholders if issuer matches
+// the template.
+func (t *IssuerTemplate) Match(issuer string) (map[string]string, bool) {
+	m := t.re.FindStringSubmatch(issuer)
+	if m == nil {
+		return nil, false
+	}
+	values := make(map[string]string, len(m)-1)
+	for i, name := range t.re.SubexpNames() {
+		if name != "" {
+			values[name] = m[i]
+		}
+	}
+	return values, true
+}
@@ -0,0 +1,115 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIssuerTemplate(t *testing.T) {
+	tmpl, err := NewIssuerTemplate("https://login.example.com/{tenant}/v2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		issuer string
+		want   map[string]string
+	}{
+		{issuer: "https://login.example.com/abc/v2.0", want: map[string]string{"tenant": "abc"}},
+		{issuer: "https://login.example.com/a/b/v2.0"},
+		{issuer: "https://login.example.com//v2.0"},
+		{issuer: "https://login.example.com/abc/v2.0/"},
+		{issuer: "https://loginXexample.com/abc/v2.0"},
+	}
+	for _, test := range tests {
+		got, ok := tmpl.Match(test.issuer)
+		if ok != (test.want != nil) || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Match(%q) = %v, %v, want %v", test.issuer, got, ok, test.want)
+		}
+		if tmpl.MatchIssuer(test.issuer) != ok {
+			t.Errorf("MatchIssuer(%q) disagrees with Match", test.issuer)
+		}
+	}
+
+	for _, bad := range []string{"https://{a}/{a}", "https://{a/x", "https://a}/x"} {
+		if _, err := NewIssuerTemplate(bad); err == nil {
+			t.Errorf("expected error for template %q", bad)
+		}
+	}
+}
+
+func TestIssuerMatcher(t *testing.T) {
+	key := newRSAKey(t)
+	tmpl, err := NewIssuerTemplate("https://op/{tenant}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewVerifier("", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		ClientID:      "client1",
+		IssuerMatcher: AnyIssuer(tmpl, Issuers{"https://legacy"}),
+	})
+	for _, iss := range []string{"https://op/t1", "https://op/t2", "https://legacy"} {
+		token := key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"client1","exp":4102444800}`, iss)))
+		if _, err := verifier.Verify(context.Background(), token); err != nil {
+			t.Errorf("verifying token issued by %s: %v", iss, err)
+		}
+	}
+
+	token := key.sign(t, []byte(`{"iss":"https://other","aud":"client1","exp":4102444800}`))
+	_, err = verifier.Verify(context.Background(), token)
+	var issErr *InvalidIssuerError
+	if !errors.As(err, &issErr) || issErr.Actual != "https://other" {
+		t.Errorf("expected invalid issuer error, got %v", err)
+	}
+
+	config := &Config{ClientID: "client1", IssuerMatcher: tmpl, SkipIssuerCheck: true}
+	if err := config.Validate(); err == nil {
+		t.Errorf("expected IssuerMatcher with SkipIssuerCheck to be invalid")
+	}
+}
+
+func TestAcceptIssuerUntil(t *testing.T) {
+	key := newRSAKey(t)
+	now := time.Unix(1000, 0)
+	var events []LegacyIssuerEvent
+	verifier := NewVerifier("https://new.example.com", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		ClientID:          "client1",
+		Now:               func() time.Time { return now },
+		LegacyIssuers:     []LegacyIssuer{AcceptIssuerUntil("https://old.example.com", time.Unix(2000, 0))},
+		VerificationCache: NewVerificationCache(10, 0),
+		Observer: &Observer{
+			LegacyIssuer: func(e LegacyIssuerEvent) { events = append(events, e) },
+		},
+	})
+	token := func(iss string) string {
+		return key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"client1","sub":"jane","exp":4102444800}`, iss)))
+	}
+	ctx := context.Background()
+
+	if _, err := verifier.Verify(ctx, token("https://new.example.com")); err != nil {
+		t.Fatal(err)
+	}
+	old := token("https://old.example.com")
+	for i := 0; i < 2; i++ {
+		if _, err := verifier.Verify(ctx, old); err != nil {
+			t.Fatalf("expected legacy issuer to be accepted before the deadline: %v", err)
+		}
+	}
+	want := LegacyIssuerEvent{Issuer: "https://new.example.com", LegacyIssuer: "https://old.example.com", Until: time.Unix(2000, 0), Subject: "jane"}
+	if len(events) != 2 || events[0] != want {
+		t.Errorf("expected an event for each use of the legacy issuer, got %+v", events)
+	}
+
+	now = time.Unix(2000, 0)
+	var issuerErr *InvalidIssuerError
+	if _, err := verifier.Verify(ctx, old); !errors.As(err, &issuerErr) {
+		t.Errorf("expected cached legacy token to be rejected after the deadline, got %v", err)
+	}
+	if _, err := verifier.Verify(ctx, token("https://old.example.com/")); err == nil {
+		t.Errorf("expected other issuers to be rejected")
+	}
+}
@@ -15,3 +15,25 @@ const (
 	PS512 = "PS512" // RSASSA-PSS using SHA512 and MGF1-SHA512
 	EdDSA = "EdDSA" // Ed25519 using SHA-512
 )
+
+// JOSE symmetric signing algorithm values as defined by RFC 7518. Tokens signed
+// with these algorithms are only accepted if Config.InsecureAllowSymmetricAlgs
+// is set.
+const (
+	HS256 = "HS256" // HMAC using SHA-256
+	HS384 = "HS384" // HMAC using SHA-384
+	HS512 = "HS512" // HMAC using SHA-512
+)
+
+// AlgNone is the "none" algorithm of unsecured JWTs. It's never accepted when
+// verifying signatures. Unsigned tokens can only be used by disabling signature
+// checks entirely with Config.InsecureSkipSignatureCheck.
+const AlgNone = "none"
+
+// symmetricAlgorithms are the HMAC algorithms, which use the client secret as
+// a shared key.
+var symmetricAlgorithms = map[string]bool{
+	HS256: true,
+	HS384: true,
+	HS512: true,
+}
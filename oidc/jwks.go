@@ -5,10 +5,10 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"sync"
 	"time"
@@ -25,10 +25,16 @@ type StaticKeySet struct {
 
 // VerifySignature compares the signature against a static set of public keys.
 func (s *StaticKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
-	jws, err := jose.ParseSigned(jwt)
-	if err != nil {
-		return nil, fmt.Errorf("parsing jwt: %v", err)
+	jws, ok := contextJWS(ctx, jwt)
+	if !ok {
+		var err error
+		jws, err = parseCompactJWS(jwt)
+		if err != nil {
+			return nil, fmt.Errorf("parsing jwt: %v", err)
+		}
+		defer jws.release()
 	}
+	attempts := getKeyAttempts(ctx)
 	for _, pub := range s.PublicKeys {
 		switch pub.(type) {
 		case *rsa.PublicKey:
@@ -37,7 +43,10 @@ func (s *StaticKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte,
 		default:
 			return nil, fmt.Errorf("invalid public key type provided: %T", pub)
 		}
-		payload, err := jws.Verify(pub)
+		if err := attempts.next(); err != nil {
+			return nil, err
+		}
+		payload, err := jws.verify(ctx, pub)
 		if err != nil {
 			continue
 		}
@@ -54,15 +63,53 @@ func (s *StaticKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte,
 //
 // The returned KeySet is a long lived verifier that caches keys based on any
 // keys change. Reuse a common remote key set instead of creating new ones as needed.
+//
+// If the remote answers with 429 Too Many Requests, the key set doesn't refresh
+// until the time given by the Retry-After header has elapsed, and tokens signed
+// by unknown keys are rejected in the meantime.
+//
+// Time based decisions made by the key set use the clock set by ClockContext, if
+// any.
 func NewRemoteKeySet(ctx context.Context, jwksURL string) *RemoteKeySet {
-	return newRemoteKeySet(ctx, jwksURL, time.Now)
+	return newRemoteKeySet(ctx, jwksURL, getClock(ctx))
 }
 
 func newRemoteKeySet(ctx context.Context, jwksURL string, now func() time.Time) *RemoteKeySet {
 	if now == nil {
 		now = time.Now
 	}
-	return &RemoteKeySet{jwksURL: jwksURL, ctx: ctx, now: now}
+	return &RemoteKeySet{
+		jwksURL:   jwksURL,
+		ctx:       ctx,
+		now:       now,
+		observer:  getObserver(ctx),
+		degraded:  getDegradedMode(ctx),
+		retention: getKeyRetention(ctx),
+		sigVerify: getSignatureVerifier(ctx),
+	}
+}
+
+// KeyRetentionContext returns a new Context that keeps keys removed from a
+// remote key set usable for the retention period after the refresh that
+// removed them. Providers and key sets created with the returned context
+// follow it.
+//
+// Some providers remove keys from their key set while tokens signed by them
+// are still valid. Without retention, such tokens are rejected as soon as a
+// refresh, triggered by any token signed with a new key, drops the old key.
+// The retention should be about the lifetime of the provider's tokens.
+//
+//	ctx := oidc.KeyRetentionContext(context.Background(), time.Hour)
+//	provider, err := oidc.NewProvider(ctx, "https://accounts.example.com")
+func KeyRetentionContext(ctx context.Context, retention time.Duration) context.Context {
+	return context.WithValue(ctx, keyRetentionKey, retention)
+}
+
+func getKeyRetention(ctx context.Context) time.Duration {
+	if d, ok := ctx.Value(keyRetentionKey).(time.Duration); ok {
+		return d
+	}
+	return 0
 }
 
 // RemoteKeySet is a KeySet implementation that validates JSON web tokens against
@@ -72,6 +119,14 @@ type RemoteKeySet struct {
 	ctx     context.Context
 	now     func() time.Time
 
+	observer  *Observer
+	degraded  *DegradedModePolicy
+	retention time.Duration
+	sigVerify SignatureVerifier
+	// Algorithms of the verifier the key set belongs to, if any. Keys that
+	// can't verify one of them aren't cached.
+	algs []string
+
 	// guard all other fields
 	mu sync.RWMutex
 
@@ -81,6 +136,21 @@ type RemoteKeySet struct {
 
 	// A set of cached keys.
 	cachedKeys []jose.JSONWebKey
+	// Keys removed by a refresh, kept until their retention expires.
+	retainedKeys []retainedKey
+	// Time of the last successful refresh, and the error of the last one.
+	refreshedAt time.Time
+	lastErr     error
+	// Time of the first failed refresh since the last successful one.
+	unavailableSince time.Time
+
+	// Suspends refreshes after the remote asked to back off.
+	limiter rateLimiter
+}
+
+type retainedKey struct {
+	key   jose.JSONWebKey
+	until time.Time
 }
 
 // inflight is used to wait on some in-flight request from multiple goroutines.
@@ -115,56 +185,86 @@ func (i *inflight) result() ([]jose.JSONWebKey, error) {
 	return i.keys, i.err
 }
 
-// paresdJWTKey is a context key that allows common setups to avoid parsing the
-// JWT twice. It holds a *jose.JSONWebSignature value.
-var parsedJWTKey contextKey
-
 // VerifySignature validates a payload against a signature from the jwks_uri.
 //
 // Users MUST NOT call this method directly and should use an IDTokenVerifier
 // instead. This method skips critical validations such as 'alg' values and is
 // only exported to implement the KeySet interface.
 func (r *RemoteKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
-	jws, ok := ctx.Value(parsedJWTKey).(*jose.JSONWebSignature)
+	jws, ok := contextJWS(ctx, jwt)
 	if !ok {
 		var err error
-		jws, err = jose.ParseSigned(jwt)
+		jws, err = parseCompactJWS(jwt)
 		if err != nil {
 			return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
 		}
+		defer jws.release()
+	}
+	if r.sigVerify != nil && getSignatureVerifier(ctx) == nil {
+		ctx = SignatureVerifierContext(ctx, r.sigVerify)
 	}
 	return r.verify(ctx, jws)
 }
 
-func (r *RemoteKeySet) verify(ctx context.Context, jws *jose.JSONWebSignature) ([]byte, error) {
-	// We don't support JWTs signed with multiple signatures.
-	keyID := ""
-	for _, sig := range jws.Signatures {
-		keyID = sig.Header.KeyID
-		break
+func (r *RemoteKeySet) verify(ctx context.Context, jws *compactJWS) ([]byte, error) {
+	header, err := jws.decodeHeader()
+	if err != nil {
+		return nil, err
 	}
+	keyID := header.KeyID
+	attempts := getKeyAttempts(ctx)
 
-	keys := r.keysFromCache()
-	for _, key := range keys {
-		if keyID == "" || key.KeyID == keyID {
-			if payload, err := jws.Verify(&key); err == nil {
+	keys, stale := r.keysFromCache()
+	if stale {
+		// The degraded mode policy forbids using the cached keys.
+		keys = nil
+	}
+	for i := range keys {
+		if keyMatches(&keys[i], keyID, header.Algorithm) {
+			if err := attempts.next(); err != nil {
+				return nil, err
+			}
+			if payload, err := jws.verify(ctx, &keys[i]); err == nil {
 				return payload, nil
 			}
 		}
 	}
+	// Keys recently removed from the key set are tried before refreshing it,
+	// since a refresh won't bring them back.
+	if !stale {
+		for _, key := range r.keysRetained() {
+			if keyMatches(&key, keyID, header.Algorithm) {
+				if err := attempts.next(); err != nil {
+					return nil, err
+				}
+				if payload, err := jws.verify(ctx, &key); err == nil {
+					return payload, nil
+				}
+			}
+		}
+	}
 
 	// If the kid doesn't match, check for new keys from the remote. This is the
 	// strategy recommended by the spec.
 	//
 	// https://openid.net/specs/openid-connect-core-1_0.html#RotateSigKeys
-	keys, err := r.keysFromRemote(ctx)
+	keys, err = r.keysFromRemote(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("fetching keys %w", err)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("fetching keys %w", err)
+		}
+		r.mu.RLock()
+		since := r.unavailableSince
+		r.mu.RUnlock()
+		return nil, &ProviderUnavailableError{Since: since, Err: err, policy: r.degraded}
 	}
 
-	for _, key := range keys {
-		if keyID == "" || key.KeyID == keyID {
-			if payload, err := jws.Verify(&key); err == nil {
+	for i := range keys {
+		if keyMatches(&keys[i], keyID, header.Algorithm) {
+			if err := attempts.next(); err != nil {
+				return nil, err
+			}
+			if payload, err := jws.verify(ctx, &keys[i]); err == nil {
 				return payload, nil
 			}
 		}
@@ -172,15 +272,144 @@ func (r *RemoteKeySet) verify(ctx context.Context, jws *jose.JSONWebSignature) (
 	return nil, errors.New("failed to verify id token signature")
 }
 
-func (r *RemoteKeySet) keysFromCache() (keys []jose.JSONWebKey) {
+// Refresh fetches the keys from the remote and replaces the cached keys with
+// them. Key sets refresh on their own when they see a token signed by an
+// unknown key, so Refresh is only needed to warm the cache, for example while
+// a service starts, or to pick up a rotation announced out of band.
+//
+// Concurrent refreshes, including those triggered by verifications, share a
+// single request. The context only bounds how long Refresh waits for it.
+func (r *RemoteKeySet) Refresh(ctx context.Context) error {
+	if _, err := r.keysFromRemote(ctx); err != nil {
+		return fmt.Errorf("oidc: refreshing keys: %w", err)
+	}
+	return nil
+}
+
+// verificationKey reports whether the key may verify signatures according to its
+// use and alg parameters, if present.
+func verificationKey(key *jose.JSONWebKey) bool {
+	if key.Use != "" && key.Use != "sig" {
+		return false
+	}
+	return key.Algorithm == "" || supportedAlgorithms[key.Algorithm]
+}
+
+// VerificationKeys returns the keys RemoteKeySet would use from a key set: the
+// public keys that may verify signatures according to their use and alg
+// parameters, and whose parameters are neither insecure nor unreasonably
+// expensive to verify against. It's intended for keys loaded from other
+// sources, such as files or secret stores.
+func VerificationKeys(keys []jose.JSONWebKey) []jose.JSONWebKey {
+	filtered := make([]jose.JSONWebKey, 0, len(keys))
+	for _, key := range keys {
+		if checkKey(&key) != nil || !verificationKey(&key) {
+			continue
+		}
+		filtered = append(filtered, key)
+	}
+	return filtered
+}
+
+// keyMatches reports whether the key can verify a token with the kid and alg
+// header parameters, so that only those keys are tried. Keys without an alg
+// parameter match any algorithm their type can verify.
+func keyMatches(key *jose.JSONWebKey, keyID, alg string) bool {
+	if keyID != "" && key.KeyID != keyID {
+		return false
+	}
+	if key.Algorithm != "" && key.Algorithm != alg {
+		return false
+	}
+	switch pub := key.Key.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case RS256, RS384, RS512, PS256, PS384, PS512:
+			return true
+		}
+	case *ecdsa.PublicKey:
+		switch alg {
+		case ES256:
+			return pub.Curve == elliptic.P256()
+		case ES384:
+			return pub.Curve == elliptic.P384()
+		case ES512:
+			return pub.Curve == elliptic.P521()
+		}
+	case ed25519.PublicKey:
+		return alg == EdDSA
+	}
+	return false
+}
+
+// keysFromCache returns the cached keys, and whether the degraded mode policy
+// forbids using them.
+func (r *RemoteKeySet) keysFromCache() (keys []jose.JSONWebKey, stale bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return r.cachedKeys
+	return r.cachedKeys, r.degraded.stale(r.unavailableSince, r.now())
+}
+
+// keysRetained returns the removed keys whose retention hasn't expired.
+func (r *RemoteKeySet) keysRetained() []jose.JSONWebKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.retainedKeys) == 0 {
+		return nil
+	}
+	now := r.now()
+	var keys []jose.JSONWebKey
+	for _, k := range r.retainedKeys {
+		if now.Before(k.until) {
+			keys = append(keys, k.key)
+		}
+	}
+	return keys
+}
+
+// retainRemoved updates the retained keys before the cached keys are replaced
+// by keys. It must be called with mu held.
+func (r *RemoteKeySet) retainRemoved(keys []jose.JSONWebKey, now time.Time) {
+	if r.retention <= 0 {
+		return
+	}
+	current := make(map[string]bool, len(keys))
+	for i := range keys {
+		current[keyThumbprint(&keys[i])] = true
+	}
+	retained := r.retainedKeys[:0]
+	for _, k := range r.retainedKeys {
+		if now.Before(k.until) && !current[keyThumbprint(&k.key)] {
+			retained = append(retained, k)
+		}
+	}
+	for i := range r.cachedKeys {
+		if !current[keyThumbprint(&r.cachedKeys[i])] {
+			retained = append(retained, retainedKey{key: r.cachedKeys[i], until: now.Add(r.retention)})
+		}
+	}
+	r.retainedKeys = retained
+}
+
+// keyThumbprint identifies a key by its kid and RFC 7638 thumbprint.
+func keyThumbprint(key *jose.JSONWebKey) string {
+	b, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return key.KeyID
+	}
+	return key.KeyID + "." + string(b)
 }
 
 // keysFromRemote syncs the key set from the remote set, records the values in the
 // cache, and returns the key set.
+//
+// If the remote rate limited a previous request, no request is made until the
+// time given by its Retry-After header has elapsed.
 func (r *RemoteKeySet) keysFromRemote(ctx context.Context) ([]jose.JSONWebKey, error) {
+	if err := r.limiter.check(r.now()); err != nil {
+		return nil, err
+	}
+
 	// Need to lock to inspect the inflight request field.
 	r.mu.Lock()
 	// If there's not a current inflight request, create one.
@@ -192,18 +421,29 @@ func (r *RemoteKeySet) keysFromRemote(ctx context.Context) ([]jose.JSONWebKey, e
 		// once the goroutine is done.
 		go func() {
 			// Sync keys and finish inflight when that's done.
+			start := time.Now()
 			keys, err := r.updateKeys()
-
-			r.inflight.done(keys, err)
+			if r.observer != nil && r.observer.KeySetFetch != nil {
+				r.observer.KeySetFetch(KeySetFetchEvent{URL: r.jwksURL, Duration: time.Since(start), Keys: len(keys), Err: err})
+			}
+			r.limiter.record(err, r.now())
 
 			// Lock to update the keys and indicate that there is no longer an
-			// inflight request.
+			// inflight request. Waiters are released once the cache reflects
+			// the result.
 			r.mu.Lock()
 			defer r.mu.Unlock()
 
+			r.lastErr = err
 			if err == nil {
+				r.retainRemoved(keys, r.now())
 				r.cachedKeys = keys
+				r.refreshedAt = r.now()
+				r.unavailableSince = time.Time{}
+			} else if r.unavailableSince.IsZero() {
+				r.unavailableSince = r.now()
 			}
+			r.inflight.done(keys, err)
 
 			// Free inflight so a different request can run.
 			r.inflight = nil
@@ -232,13 +472,17 @@ func (r *RemoteKeySet) updateKeys() ([]jose.JSONWebKey, error) {
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	limits := getResponseLimits(r.ctx)
+	body, err := readBody(resp, limits.maxJWKSSize())
 	if err != nil {
 		return nil, fmt.Errorf("unable to read response body: %v", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("oidc: get keys failed: %s %s", resp.Status, body)
+		return nil, newHTTPError(resp, body, r.now())
+	}
+	if err := limits.checkContentType(resp); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode keys: %v", err)
 	}
 
 	var keySet jose.JSONWebKeySet
@@ -246,5 +490,25 @@ func (r *RemoteKeySet) updateKeys() ([]jose.JSONWebKey, error) {
 	if err != nil {
 		return nil, fmt.Errorf("oidc: failed to decode keys: %v %s", err, body)
 	}
-	return keySet.Keys, nil
+	if n := len(keySet.Keys); n > limits.maxKeys() {
+		return nil, fmt.Errorf("oidc: key set contains %d keys, maximum allowed is %d", n, limits.maxKeys())
+	}
+
+	// Drop keys that can't be safely used for verification rather than
+	// failing the whole set, as well as encryption keys and keys meant for
+	// algorithms that can't sign tokens, which would never match one.
+	keys := VerificationKeys(keySet.Keys)
+	if r.algs == nil {
+		return keys, nil
+	}
+	filtered := keys[:0]
+	for i := range keys {
+		for _, alg := range r.algs {
+			if keyMatches(&keys[i], "", alg) {
+				filtered = append(filtered, keys[i])
+				break
+			}
+		}
+	}
+	return filtered, nil
 }
@@ -3,6 +3,7 @@ package oidc
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
@@ -103,6 +104,9 @@ func TestECDSAVerify(t *testing.T) {
 }
 
 func TestEdDSAVerify(t *testing.T) {
+	if fipsRejects(EdDSA) {
+		t.Skip("EdDSA isn't approved in FIPS mode")
+	}
 	good := newEdDSAKey(t)
 	bad := newEdDSAKey(t)
 	testKeyVerify(t, good, bad, good)
@@ -146,7 +150,7 @@ func TestKeyVerifyContextCanceled(t *testing.T) {
 	payload := []byte("a secret")
 
 	good := newECDSAKey(t)
-	jws, err := jose.ParseSigned(good.sign(t, payload))
+	jws, err := parseCompactJWS(good.sign(t, payload))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -185,11 +189,11 @@ func testKeyVerify(t *testing.T, good, bad *signingKey, verification ...*signing
 
 	payload := []byte("a secret")
 
-	jws, err := jose.ParseSigned(good.sign(t, payload))
+	jws, err := parseCompactJWS(good.sign(t, payload))
 	if err != nil {
 		t.Fatal(err)
 	}
-	badJWS, err := jose.ParseSigned(bad.sign(t, payload))
+	badJWS, err := parseCompactJWS(bad.sign(t, payload))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -234,11 +238,11 @@ func TestRotation(t *testing.T) {
 	key2.keyID = "key2"
 
 	payload := []byte("a secret")
-	jws1, err := jose.ParseSigned(key1.sign(t, payload))
+	jws1, err := parseCompactJWS(key1.sign(t, payload))
 	if err != nil {
 		t.Fatal(err)
 	}
-	jws2, err := jose.ParseSigned(key2.sign(t, payload))
+	jws2, err := parseCompactJWS(key2.sign(t, payload))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -324,6 +328,7 @@ func BenchmarkVerify(b *testing.B) {
 		b.Fatalf("verifying id token: %v", err)
 	}
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		if _, err := verifier.Verify(ctx, idToken); err != nil {
@@ -331,3 +336,172 @@ func BenchmarkVerify(b *testing.B) {
 		}
 	}
 }
+
+func TestKeyRetention(t *testing.T) {
+	oldKey, newKey := newRSAKey(t), newRSAKey(t)
+	oldKey.keyID, newKey.keyID = "old", "new"
+	server := &keyServer{keys: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{oldKey.jwk()}}}
+	s := httptest.NewServer(server)
+	defer s.Close()
+
+	oldToken := oldKey.sign(t, []byte("old"))
+	newToken := newKey.sign(t, []byte("new"))
+
+	for _, retention := range []time.Duration{0, time.Hour} {
+		t.Run(retention.String(), func(t *testing.T) {
+			server.keys.Keys = []jose.JSONWebKey{oldKey.jwk()}
+			now := time.Unix(1000, 0)
+			ctx := ClockContext(context.Background(), func() time.Time { return now })
+			ctx = KeyRetentionContext(ctx, retention)
+			keySet := NewRemoteKeySet(ctx, s.URL)
+			if _, err := keySet.VerifySignature(ctx, oldToken); err != nil {
+				t.Fatal(err)
+			}
+
+			// The provider rotates, and a token signed by the new key refreshes
+			// the key set.
+			server.keys.Keys = []jose.JSONWebKey{newKey.jwk()}
+			if _, err := keySet.VerifySignature(ctx, newToken); err != nil {
+				t.Fatal(err)
+			}
+			_, err := keySet.VerifySignature(ctx, oldToken)
+			if retention == 0 {
+				if err == nil {
+					t.Fatal("expected removed key to be rejected without retention")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected removed key to be retained: %v", err)
+			}
+			if status := keySet.Status(); len(status.RetainedKeys) != 1 || status.RetainedKeys[0].KeyID != "old" {
+				t.Errorf("expected old key to be listed as retained, got %+v", status.RetainedKeys)
+			}
+
+			now = now.Add(retention)
+			if _, err := keySet.VerifySignature(ctx, oldToken); err == nil {
+				t.Errorf("expected removed key to be rejected once its retention expired")
+			}
+		})
+	}
+}
+
+func TestKeyFiltering(t *testing.T) {
+	rsaKey, ecKey := newRSAKey(t), newECDSAKey(t)
+	rsaKey.keyID, ecKey.keyID = "rsa", "ec"
+	enc := rsaKey.jwk()
+	enc.KeyID, enc.Use, enc.Algorithm = "enc", "enc", ""
+	oaep := rsaKey.jwk()
+	oaep.KeyID, oaep.Use, oaep.Algorithm = "oaep", "", "RSA-OAEP"
+	s := httptest.NewServer(&keyServer{keys: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{enc, oaep, rsaKey.jwk(), ecKey.jwk()}}})
+	defer s.Close()
+
+	ctx := context.Background()
+	keySet := NewRemoteKeySet(ctx, s.URL)
+	if err := keySet.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var kids []string
+	for _, k := range keySet.Status().Keys {
+		kids = append(kids, k.KeyID)
+	}
+	if fmt.Sprint(kids) != "[rsa ec]" {
+		t.Errorf("expected only signing keys to be cached, got %v", kids)
+	}
+
+	p256 := ecKey.jwk()
+	p256.Algorithm = ""
+	tests := []struct {
+		key   jose.JSONWebKey
+		kid   string
+		alg   string
+		match bool
+	}{
+		{rsaKey.jwk(), "", RS256, true},
+		{rsaKey.jwk(), "rsa", RS256, true},
+		{rsaKey.jwk(), "other", RS256, false},
+		{rsaKey.jwk(), "", PS256, false}, // alg parameter is RS256
+		{ecKey.jwk(), "", RS256, false},
+		{p256, "", ES256, true},
+		{p256, "", ES384, false}, // wrong curve
+		{p256, "", EdDSA, false},
+	}
+	for i, test := range tests {
+		if got := keyMatches(&test.key, test.kid, test.alg); got != test.match {
+			t.Errorf("%d: keyMatches(%s, %q, %s) = %v, want %v", i, test.key.KeyID, test.kid, test.alg, got, test.match)
+		}
+	}
+}
+
+// delegatingKeySet checks another token with its inner key set, as a wrapper
+// checking a token it derived from the one being verified would.
+type delegatingKeySet struct {
+	inner KeySet
+	other string
+}
+
+func (d *delegatingKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	if _, err := d.inner.VerifySignature(ctx, d.other); err != nil {
+		return nil, err
+	}
+	return d.inner.VerifySignature(ctx, jwt)
+}
+
+func TestKeySetIgnoresParsedJWTOfOtherToken(t *testing.T) {
+	key, forger := newRSAKey(t), newRSAKey(t)
+	payload := []byte(`{"iss":"https://foo","aud":"client1","exp":4102444800}`)
+	token, forged := key.sign(t, payload), forger.sign(t, payload)
+
+	s := httptest.NewServer(&keyServer{keys: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}}})
+	defer s.Close()
+	ctx := context.Background()
+	keySets := map[string]KeySet{
+		"static": &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}},
+		"remote": NewRemoteKeySet(ctx, s.URL),
+	}
+	for name, inner := range keySets {
+		t.Run(name, func(t *testing.T) {
+			config := &Config{ClientID: "client1", SupportedSigningAlgs: []string{RS256}}
+			if _, err := NewVerifier("https://foo", &delegatingKeySet{inner: inner, other: token}, config).Verify(ctx, token); err != nil {
+				t.Fatalf("verifying token: %v", err)
+			}
+			if _, err := NewVerifier("https://foo", &delegatingKeySet{inner: inner, other: forged}, config).Verify(ctx, token); err == nil {
+				t.Errorf("expected forged token checked by the wrapper to be rejected")
+			}
+		})
+	}
+}
+
+func TestVerifierKeyFiltering(t *testing.T) {
+	rsaKey, ecKey := newRSAKey(t), newECDSAKey(t)
+	rsaKey.keyID, ecKey.keyID = "rsa", "ec"
+	noAlg := ecKey.jwk()
+	noAlg.KeyID, noAlg.Algorithm = "ec-no-alg", ""
+	s := httptest.NewServer(&keyServer{keys: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{rsaKey.jwk(), ecKey.jwk(), noAlg}}})
+	defer s.Close()
+
+	ctx := context.Background()
+	p := &Provider{issuer: "https://foo", jwksURL: s.URL}
+	tests := []struct {
+		algs []string
+		want string
+	}{
+		{[]string{RS256}, "[rsa]"},
+		{[]string{ES256}, "[ec ec-no-alg]"},
+		{nil, "[rsa]"}, // RS256 by default
+	}
+	for _, test := range tests {
+		verifier := p.VerifierContext(ctx, &Config{ClientID: "app", SupportedSigningAlgs: test.algs})
+		keySet := verifier.keySet.(*RemoteKeySet)
+		if err := keySet.Refresh(ctx); err != nil {
+			t.Fatal(err)
+		}
+		var kids []string
+		for _, k := range keySet.Status().Keys {
+			kids = append(kids, k.KeyID)
+		}
+		if fmt.Sprint(kids) != test.want {
+			t.Errorf("algorithms %v: expected keys %s to be cached, got %v", test.algs, test.want, kids)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// DefaultJWKSMaxAge is the default time providers may cache the key set served
+// by a JWKSHandler.
+const DefaultJWKSMaxAge = time.Hour
+
+// PublicKeySource supplies the keys served by a JWKSHandler. KeyManager
+// implements it.
+type PublicKeySource interface {
+	PublicKeys() []jose.JSONWebKey
+}
+
+// StaticPublicKeys is a PublicKeySource of a fixed set of keys.
+type StaticPublicKeys []jose.JSONWebKey
+
+// PublicKeys returns the keys.
+func (s StaticPublicKeys) PublicKeys() []jose.JSONWebKey {
+	return s
+}
+
+// JWKSHandler serves the client's public keys as a JSON Web Key Set, for
+// providers configured with the client's jwks_uri metadata. Keys without a
+// "kid" are assigned their RFC 7638 thumbprint, and private keys are published
+// with their public part only.
+//
+// Responses carry a Cache-Control max-age and an ETag, so providers can cache
+// the key set and revalidate it cheaply. When keys are rotated, the replaced
+// key must be published for at least MaxAge after the new key is first used,
+// which KeyManager's retention period takes care of.
+//
+//	http.Handle("/jwks.json", &oidc.JWKSHandler{Keys: keyManager, MaxAge: 15 * time.Minute})
+type JWKSHandler struct {
+	// Keys supplies the keys to serve.
+	Keys PublicKeySource
+	// MaxAge is how long providers may cache the key set. Defaults to
+	// DefaultJWKSMaxAge.
+	MaxAge time.Duration
+}
+
+// ServeHTTP serves the key set for GET and HEAD requests.
+func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	keys, err := publicJWKs(h.Keys.PublicKeys())
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	body, err := json.Marshal(jose.JSONWebKeySet{Keys: keys})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+
+	maxAge := h.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultJWKSMaxAge
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(maxAge/time.Second)))
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body)
+}
+
+// etagMatches reports whether an If-None-Match header matches the ETag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// publicJWKs returns the public parts of the keys, with key IDs assigned to
+// keys that lack one. Keys sharing an ID are rejected, since providers couldn't
+// tell them apart.
+func publicJWKs(keys []jose.JSONWebKey) ([]jose.JSONWebKey, error) {
+	out := make([]jose.JSONWebKey, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if !k.IsPublic() {
+			k = k.Public()
+			if !k.Valid() {
+				return nil, fmt.Errorf("oidc: key %q has no public part", k.KeyID)
+			}
+		}
+		if k.KeyID == "" {
+			thumbprint, err := k.Thumbprint(crypto.SHA256)
+			if err != nil {
+				return nil, fmt.Errorf("oidc: computing key id: %v", err)
+			}
+			k.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+		}
+		if seen[k.KeyID] {
+			return nil, fmt.Errorf("oidc: duplicate key id %q", k.KeyID)
+		}
+		seen[k.KeyID] = true
+		out = append(out, k)
+	}
+	return out, nil
+}
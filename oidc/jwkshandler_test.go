@@ -0,0 +1,72 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func TestJWKSHandler(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	ecKey := newECDSAKey(t)
+	h := &JWKSHandler{
+		Keys: StaticPublicKeys{
+			{Key: rsaKey.priv, KeyID: "rsa", Algorithm: RS256, Use: "sig"},
+			{Key: ecKey.pub, Algorithm: ES256, Use: "sig"},
+		},
+		MaxAge: 10 * time.Minute,
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jwks.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d", w.Code)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=600" {
+		t.Errorf("unexpected Cache-Control %q", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/jwk-set+json" {
+		t.Errorf("unexpected Content-Type %q", got)
+	}
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(w.Body.Bytes(), &jwks); err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("expected 2 keys, got %d", len(jwks.Keys))
+	}
+	for _, k := range jwks.Keys {
+		if !k.IsPublic() || k.KeyID == "" {
+			t.Errorf("expected public key with key id, got %+v", k)
+		}
+	}
+	if jwks.Keys[0].KeyID != "rsa" {
+		t.Errorf("expected key id to be preserved, got %q", jwks.Keys[0].KeyID)
+	}
+
+	etag := w.Header().Get("ETag")
+	r := httptest.NewRequest(http.MethodGet, "/jwks.json", nil)
+	r.Header.Set("If-None-Match", `"other", `+etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected 304 for matching ETag, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/jwks.json", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for POST, got %d", w.Code)
+	}
+
+	dup := &JWKSHandler{Keys: StaticPublicKeys{{Key: rsaKey.pub, KeyID: "k"}, {Key: ecKey.pub, KeyID: "k"}}}
+	w = httptest.NewRecorder()
+	dup.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jwks.json", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 for duplicate key ids, got %d", w.Code)
+	}
+}
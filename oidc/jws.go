@@ -0,0 +1,382 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+	"sync"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// jwsHeader holds the protected header values this package inspects.
+type jwsHeader struct {
+	Algorithm          string   `json:"alg"`
+	KeyID              string   `json:"kid"`
+	Type               string   `json:"typ"`
+	X509Thumbprint     string   `json:"x5t"`
+	X509ThumbprintS256 string   `json:"x5t#S256"`
+	Critical           []string `json:"crit"`
+}
+
+// TokenHeader holds values from the JOSE header of a verified token. These are
+// useful for auditing which key signed a token.
+type TokenHeader struct {
+	// Algorithm used to sign the token ("alg").
+	Algorithm string
+	// KeyID of the key that signed the token ("kid"), if provided.
+	KeyID string
+	// Type of the token ("typ"), if provided. For example "JWT".
+	Type string
+	// X509Thumbprint is the base64url encoded SHA-1 thumbprint of the
+	// certificate corresponding to the signing key ("x5t"), if provided.
+	X509Thumbprint string
+	// X509ThumbprintS256 is the base64url encoded SHA-256 thumbprint of the
+	// certificate corresponding to the signing key ("x5t#S256"), if provided.
+	X509ThumbprintS256 string
+}
+
+func (h *jwsHeader) tokenHeader() TokenHeader {
+	return TokenHeader{
+		Algorithm:          h.Algorithm,
+		KeyID:              h.KeyID,
+		Type:               h.Type,
+		X509Thumbprint:     h.X509Thumbprint,
+		X509ThumbprintS256: h.X509ThumbprintS256,
+	}
+}
+
+// compactJWS is a JWS in compact serialization. Unlike jose.JSONWebSignature it
+// verifies signatures over the original signing input, rather than re-encoding
+// the header and payload, and defers decoding the header until it's needed.
+type compactJWS struct {
+	// signingInput is the "<header>.<payload>" portion of the raw token. The
+	// raw fields are slices of a single copy of the token.
+	signingInput []byte
+	rawHeader    []byte
+	rawSignature []byte
+
+	payload []byte
+
+	header        jwsHeader
+	headerDecoded bool
+	signature     []byte
+
+	// scratch holds the decoded header and signature. It's borrowed from
+	// scratchPool and must be returned through release.
+	scratch *[]byte
+}
+
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+// parseCompactJWS splits a compact JWS and decodes its payload. The header is
+// decoded on first use.
+func parseCompactJWS(raw string) (*compactJWS, error) {
+	if err := checkTokenAlphabet(raw); err != nil {
+		return nil, err
+	}
+	i := strings.IndexByte(raw, '.')
+	if i < 0 {
+		return nil, fmt.Errorf("oidc: malformed jwt, expected 3 parts got 1")
+	}
+	j := strings.IndexByte(raw[i+1:], '.')
+	if j < 0 {
+		return nil, fmt.Errorf("oidc: malformed jwt, expected 3 parts got 2")
+	}
+	j += i + 1
+	if strings.IndexByte(raw[j+1:], '.') >= 0 {
+		return nil, fmt.Errorf("oidc: malformed jwt, expected 3 parts got %d", strings.Count(raw, ".")+1)
+	}
+
+	b := []byte(raw)
+	rawPayload := b[i+1 : j]
+	payload := make([]byte, b64.DecodedLen(len(rawPayload)))
+	n, err := b64.Decode(payload, rawPayload)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed jwt payload: %v", err)
+	}
+	return &compactJWS{
+		signingInput: b[:j],
+		rawHeader:    b[:i],
+		rawSignature: b[j+1:],
+		payload:      payload[:n],
+	}, nil
+}
+
+// parsedFrom reports whether the JWS was parsed from raw.
+func (c *compactJWS) parsedFrom(raw string) bool {
+	n := len(c.signingInput)
+	return len(raw) == n+1+len(c.rawSignature) &&
+		raw[:n] == string(c.signingInput) &&
+		raw[n] == '.' &&
+		raw[n+1:] == string(c.rawSignature)
+}
+
+// contextJWS returns the JWS the verifier stored in the context, if it was
+// parsed from jwt. Key sets called with other tokens, such as by a key set
+// wrapping them, parse those tokens themselves.
+func contextJWS(ctx context.Context, jwt string) (*compactJWS, bool) {
+	jws, ok := ctx.Value(parsedJWTKey).(*compactJWS)
+	if !ok || !jws.parsedFrom(jwt) {
+		return nil, false
+	}
+	return jws, true
+}
+
+// decodeHeader decodes and validates the protected header.
+func (c *compactJWS) decodeHeader() (*jwsHeader, error) {
+	if c.headerDecoded {
+		return &c.header, nil
+	}
+	if len(c.rawHeader) > maxHeaderSize {
+		return nil, fmt.Errorf("oidc: malformed jwt header: size %d exceeds maximum of %d bytes", len(c.rawHeader), maxHeaderSize)
+	}
+	buf := c.buffer(b64.DecodedLen(len(c.rawHeader)))
+	n, err := b64.Decode(buf, c.rawHeader)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed jwt header: %v", err)
+	}
+	if err := checkJSON(buf[:n], DefaultMaxClaimDepth, false); err != nil {
+		return nil, fmt.Errorf("oidc: malformed jwt header: %v", err)
+	}
+	if err := json.Unmarshal(buf[:n], &c.header); err != nil {
+		return nil, fmt.Errorf("oidc: malformed jwt header: %v", err)
+	}
+	if c.header.Algorithm == "" {
+		return nil, errors.New("oidc: malformed jwt header: missing alg")
+	}
+	// No extensions are understood by this package. RFC 7515 requires
+	// rejecting tokens that mark unknown extensions as critical.
+	if len(c.header.Critical) > 0 {
+		return nil, fmt.Errorf("oidc: unsupported critical header parameters %q", c.header.Critical)
+	}
+	c.headerDecoded = true
+	return &c.header, nil
+}
+
+// buffer returns a scratch buffer of length n.
+func (c *compactJWS) buffer(n int) []byte {
+	if c.scratch == nil {
+		c.scratch = scratchPool.Get().(*[]byte)
+	}
+	if cap(*c.scratch) < n {
+		*c.scratch = make([]byte, n)
+	}
+	return (*c.scratch)[:n]
+}
+
+// release returns scratch memory to the pool. The signature must not be
+// verified after release is called.
+func (c *compactJWS) release() {
+	if c.scratch != nil {
+		scratchPool.Put(c.scratch)
+		c.scratch = nil
+		c.signature = nil
+	}
+}
+
+// verify checks the signature against the provided key, returning the payload
+// on success. The key may be a *jose.JSONWebKey or a supported public key type.
+// The signature is checked by the context's SignatureVerifier, if any.
+func (c *compactJWS) verify(ctx context.Context, key interface{}) ([]byte, error) {
+	header, err := c.decodeHeader()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFIPS(header.Algorithm); err != nil {
+		return nil, err
+	}
+	if jwk, ok := key.(*jose.JSONWebKey); ok {
+		key = jwk.Key
+	}
+	if c.signature == nil {
+		// The header has already been decoded, so the scratch buffer can be
+		// reused for the signature.
+		sig := c.buffer(b64.DecodedLen(len(c.rawSignature)))
+		n, err := b64.Decode(sig, c.rawSignature)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: malformed jwt signature: %v", err)
+		}
+		c.signature = sig[:n]
+	}
+	if v := getSignatureVerifier(ctx); v != nil {
+		err = v.VerifySignature(header.Algorithm, key, c.signingInput, c.signature)
+	} else {
+		err = verifySignature(header.Algorithm, key, c.signingInput, c.signature)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.payload, nil
+}
+
+// SignatureVerifier checks JWS signatures. By default this package verifies
+// signatures itself with the crypto packages of the standard library. A
+// SignatureVerifier replaces those checks, for organizations required to use
+// a particular cryptographic module, such as a FIPS 140 validated one, or a
+// JOSE library like lestrrat-go/jwx.
+//
+// Only the signature check is replaced: parsing tokens, selecting keys and
+// validating claims, including the algorithm policy of the verifier, are
+// still done by this package.
+type SignatureVerifier interface {
+	// VerifySignature checks the signature over the signing input, the
+	// "<header>.<payload>" part of the token, with the key and algorithm. The
+	// key is an *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey, or the
+	// []byte secret of a SymmetricKeySet. It must return an error if the
+	// signature is invalid, or if the key can't be used with the algorithm.
+	VerifySignature(alg string, key interface{}, signingInput, signature []byte) error
+}
+
+// DefaultSignatureVerifier returns the SignatureVerifier used when none is set
+// by SignatureVerifierContext, for implementations that only handle some
+// algorithms themselves.
+func DefaultSignatureVerifier() SignatureVerifier {
+	return stdlibSignatureVerifier{}
+}
+
+// stdlibSignatureVerifier verifies signatures with the standard library.
+type stdlibSignatureVerifier struct{}
+
+func (stdlibSignatureVerifier) VerifySignature(alg string, key interface{}, signingInput, signature []byte) error {
+	return verifySignature(alg, key, signingInput, signature)
+}
+
+// SignatureVerifierContext returns a new Context that checks the signatures of
+// tokens with the SignatureVerifier. Verifications using the context, and
+// remote key sets, including those of providers, created with it, follow it.
+//
+//	ctx := oidc.SignatureVerifierContext(context.Background(), fipsVerifier)
+//	provider, err := oidc.NewProvider(ctx, "https://accounts.example.com")
+func SignatureVerifierContext(ctx context.Context, v SignatureVerifier) context.Context {
+	return context.WithValue(ctx, signatureVerifierKey, v)
+}
+
+func getSignatureVerifier(ctx context.Context) SignatureVerifier {
+	if v, ok := ctx.Value(signatureVerifierKey).(SignatureVerifier); ok {
+		return v
+	}
+	return nil
+}
+
+func hashForAlg(alg string) (crypto.Hash, bool) {
+	switch alg {
+	case RS256, PS256, ES256:
+		return crypto.SHA256, true
+	case RS384, PS384, ES384:
+		return crypto.SHA384, true
+	case RS512, PS512, ES512:
+		return crypto.SHA512, true
+	}
+	return 0, false
+}
+
+var errSignature = errors.New("oidc: signature verification failed")
+
+// verifySignature verifies a JWS signature for the given algorithm. The key type
+// must be compatible with the algorithm.
+func verifySignature(alg string, key interface{}, signingInput, sig []byte) error {
+	if alg == EdDSA {
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("oidc: key of type %T can't verify %s signatures", key, alg)
+		}
+		if !ed25519.Verify(pub, signingInput, sig) {
+			return errSignature
+		}
+		return nil
+	}
+
+	if symmetricAlgorithms[alg] {
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("oidc: key of type %T can't verify %s signatures", key, alg)
+		}
+		var mac hash.Hash
+		switch alg {
+		case HS256:
+			mac = hmac.New(sha256.New, secret)
+		case HS384:
+			mac = hmac.New(sha512.New384, secret)
+		default:
+			mac = hmac.New(sha512.New, secret)
+		}
+		mac.Write(signingInput) // hash documents that Write will never return an error
+		var sumBuf [64]byte
+		if !hmac.Equal(mac.Sum(sumBuf[:0]), sig) {
+			return errSignature
+		}
+		return nil
+	}
+
+	hash, ok := hashForAlg(alg)
+	if !ok {
+		return fmt.Errorf("oidc: unsupported signing algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signingInput) // hash documents that Write will never return an error
+	var digestBuf [64]byte
+	digest := h.Sum(digestBuf[:0])
+
+	switch alg {
+	case RS256, RS384, RS512, PS256, PS384, PS512:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("oidc: key of type %T can't verify %s signatures", key, alg)
+		}
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(pub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if err != nil {
+			return errSignature
+		}
+		return nil
+	default:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("oidc: key of type %T can't verify %s signatures", key, alg)
+		}
+		var curve elliptic.Curve
+		switch alg {
+		case ES256:
+			curve = elliptic.P256()
+		case ES384:
+			curve = elliptic.P384()
+		case ES512:
+			curve = elliptic.P521()
+		}
+		if pub.Curve != curve {
+			return fmt.Errorf("oidc: key curve can't verify %s signatures", alg)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errSignature
+		}
+		return nil
+	}
+}
@@ -0,0 +1,283 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func TestCompactJWSVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed := newEdDSAKey(t)
+
+	tests := []struct {
+		alg  jose.SignatureAlgorithm
+		priv interface{}
+		pub  interface{}
+	}{
+		{jose.RS256, rsaKey, rsaKey.Public()},
+		{jose.RS384, rsaKey, rsaKey.Public()},
+		{jose.RS512, rsaKey, rsaKey.Public()},
+		{jose.PS256, rsaKey, rsaKey.Public()},
+		{jose.PS384, rsaKey, rsaKey.Public()},
+		{jose.PS512, rsaKey, rsaKey.Public()},
+		{jose.ES256, p256, p256.Public()},
+		{jose.ES384, p384, p384.Public()},
+		{jose.ES512, p521, p521.Public()},
+		{jose.EdDSA, ed.priv, ed.pub},
+	}
+	for _, test := range tests {
+		t.Run(string(test.alg), func(t *testing.T) {
+			key := &signingKey{priv: test.priv, pub: test.pub, alg: test.alg}
+			payload := []byte(`{"iss":"https://foo"}`)
+			raw := key.sign(t, payload)
+
+			jws, err := parseCompactJWS(raw)
+			if err != nil {
+				t.Fatalf("parsing jws: %v", err)
+			}
+			defer jws.release()
+			got, err := jws.verify(context.Background(), test.pub)
+			if fipsRejects(string(test.alg)) {
+				if err == nil {
+					t.Fatalf("expected %s to be rejected in FIPS mode", test.alg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifying jws: %v", err)
+			}
+			if string(got) != string(payload) {
+				t.Errorf("expected payload %s got %s", payload, got)
+			}
+
+			// Tamper with the payload.
+			parts := strings.Split(raw, ".")
+			parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"https://bar"}`))
+			tampered, err := parseCompactJWS(strings.Join(parts, "."))
+			if err != nil {
+				t.Fatalf("parsing jws: %v", err)
+			}
+			defer tampered.release()
+			if _, err := tampered.verify(context.Background(), test.pub); err == nil {
+				t.Errorf("expected tampered payload to fail verification")
+			}
+		})
+	}
+}
+
+func TestCompactJWSVerifyKeyMismatch(t *testing.T) {
+	p256 := newECDSAKey(t)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey := newRSAKey(t)
+
+	tests := []struct {
+		name string
+		jwt  string
+		key  crypto.PublicKey
+	}{
+		{"rsa alg, ecdsa key", rsaKey.sign(t, []byte("{}")), p256.pub},
+		{"ecdsa alg, rsa key", p256.sign(t, []byte("{}")), rsaKey.pub},
+		{"ES256 alg, P-384 key", p256.sign(t, []byte("{}")), p384.Public()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			jws, err := parseCompactJWS(test.jwt)
+			if err != nil {
+				t.Fatalf("parsing jws: %v", err)
+			}
+			if _, err := jws.verify(context.Background(), test.key); err == nil {
+				t.Errorf("expected verification to fail")
+			}
+		})
+	}
+}
+
+func TestCompactJWSVerifyPSSSaltLength(t *testing.T) {
+	key := newRSAKey(t)
+	priv := key.priv.(*rsa.PrivateKey)
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"PS256"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"https://foo"}`))
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signingInput))
+
+	// RFC 7518 section 3.5 requires the salt to be as long as the hash.
+	for _, test := range []struct {
+		saltLength int
+		wantErr    bool
+	}{
+		{rsa.PSSSaltLengthEqualsHash, fipsRejects(PS256)},
+		{rsa.PSSSaltLengthAuto, true},
+	} {
+		sig, err := rsa.SignPSS(rand.Reader, priv, crypto.SHA256, digest.Sum(nil), &rsa.PSSOptions{SaltLength: test.saltLength})
+		if err != nil {
+			t.Fatal(err)
+		}
+		jws, err := parseCompactJWS(signingInput + "." + base64.RawURLEncoding.EncodeToString(sig))
+		if err != nil {
+			t.Fatalf("parsing jws: %v", err)
+		}
+		_, err = jws.verify(context.Background(), key.pub)
+		jws.release()
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("salt length %d: got err %v, want error %t", test.saltLength, err, test.wantErr)
+		}
+	}
+}
+
+func TestParseCompactJWS(t *testing.T) {
+	header := func(h string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(h))
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{}`))
+
+	tests := []struct {
+		name    string
+		jwt     string
+		wantErr bool
+	}{
+		{"valid", header(`{"alg":"RS256","kid":"1"}`) + "." + payload + ".sig", false},
+		{"two parts", header(`{"alg":"RS256"}`) + "." + payload, true},
+		{"four parts", header(`{"alg":"RS256"}`) + "." + payload + ".sig.sig", true},
+		{"json serialization", `{"payload":"e30","signatures":[]}`, true},
+		{"bad payload encoding", header(`{"alg":"RS256"}`) + ".!!!.sig", true},
+		{"bad header encoding", "!!!." + payload + ".sig", true},
+		{"header not json", header(`alg`) + "." + payload + ".sig", true},
+		{"missing alg", header(`{"kid":"1"}`) + "." + payload + ".sig", true},
+		{"critical header", header(`{"alg":"RS256","crit":["exp"],"exp":1}`) + "." + payload + ".sig", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			jws, err := parseCompactJWS(test.jwt)
+			if err == nil {
+				_, err = jws.decodeHeader()
+			}
+			if err != nil && !test.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if err == nil && test.wantErr {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func BenchmarkParseCompactJWS(b *testing.B) {
+	key := newRSAKey(b)
+	raw := key.sign(b, []byte(`{"iss":"https://example.com","sub":"test_user","aud":"test_client_id"}`))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		jws, err := parseCompactJWS(raw)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := jws.decodeHeader(); err != nil {
+			b.Fatal(err)
+		}
+		jws.release()
+	}
+}
+
+func BenchmarkVerifyStaticKeySet(b *testing.B) {
+	key := newRSAKey(b)
+
+	now := time.Date(2022, 1, 29, 0, 0, 0, 0, time.UTC)
+	payload := []byte(fmt.Sprintf(`{
+		"iss": "https://example.com",
+		"sub": "test_user",
+		"aud": "test_client_id",
+		"exp": %d
+	}`, now.Add(time.Hour).Unix()))
+	idToken := key.sign(b, payload)
+
+	verifier := NewVerifier("https://example.com", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		ClientID: "test_client_id",
+		Now:      func() time.Time { return now },
+	})
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := verifier.Verify(ctx, idToken); err != nil {
+			b.Fatalf("verifying id token: %v", err)
+		}
+	}
+}
+
+type countingVerifier struct {
+	calls  int32
+	reject bool
+}
+
+func (v *countingVerifier) VerifySignature(alg string, key interface{}, signingInput, signature []byte) error {
+	atomic.AddInt32(&v.calls, 1)
+	if v.reject {
+		return errors.New("rejected by module")
+	}
+	return DefaultSignatureVerifier().VerifySignature(alg, key, signingInput, signature)
+}
+
+func TestSignatureVerifierContext(t *testing.T) {
+	key := newRSAKey(t)
+	s, _ := newIssuerServer(t, key)
+	token := key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"api","sub":"jane","exp":4102444800}`, s.URL)))
+
+	v := &countingVerifier{}
+	provider, err := NewProvider(SignatureVerifierContext(context.Background(), v), s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := provider.Verifier(&Config{ClientID: "api"})
+	if _, err := verifier.Verify(context.Background(), token); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if n := atomic.LoadInt32(&v.calls); n != 1 {
+		t.Errorf("expected the key set's signature verifier to be called once, got %d", n)
+	}
+
+	rejecting := &countingVerifier{reject: true}
+	if _, err := verifier.Verify(SignatureVerifierContext(context.Background(), rejecting), token); err == nil {
+		t.Error("expected the context's signature verifier to reject the token")
+	}
+	if n := atomic.LoadInt32(&rejecting.calls); n == 0 {
+		t.Error("expected the context's signature verifier to override the key set's")
+	}
+
+	static := NewVerifier(s.URL, &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "api"})
+	if _, err := static.Verify(SignatureVerifierContext(context.Background(), rejecting), token); err == nil {
+		t.Error("expected static key set to use the context's signature verifier")
+	}
+}
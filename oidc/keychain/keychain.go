@@ -0,0 +1,97 @@
+// Package keychain keeps the tokens of command line logins in the operating
+// system's credential store, so users of command line tools stay logged in
+// between runs: the login Keychain on macOS, the Credential Manager on
+// Windows, and the Secret Service, provided by GNOME Keyring or KWallet, on
+// other systems.
+//
+//	cache := keychain.New("my-cli")
+//	key := oidc.TokenCacheKey{Issuer: issuer, ClientID: config.ClientID}
+//	src, err := oidc.CachedTokenSource(ctx, cache, key, &config)
+//	if errors.Is(err, oidc.ErrNotCached) {
+//		// Log in, then store the tokens with cache.Store.
+//	}
+//
+// The macOS and Secret Service stores are accessed through the security and
+// secret-tool commands, which must be installed. Secret-tool is part of
+// libsecret, packaged as libsecret-tools or libsecret on most distributions.
+package keychain
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// errNotFound is returned by stores that hold no item for an account.
+var errNotFound = errors.New("keychain: item not found")
+
+// store is a credential store of the operating system. Items are identified
+// by a service and an account, and hold printable data.
+type store interface {
+	get(ctx context.Context, service, account string) ([]byte, error)
+	set(ctx context.Context, service, account string, data []byte) error
+	delete(ctx context.Context, service, account string) error
+}
+
+// Cache is an oidc.TokenCache storing tokens in the operating system's
+// credential store. Each login is stored as an item of the cache's service,
+// whose account is the client ID and issuer of the login.
+type Cache struct {
+	service string
+	store   store
+}
+
+var _ oidc.TokenCache = (*Cache)(nil)
+
+// New returns a cache storing tokens under the service name, usually the name
+// of the command line tool.
+func New(service string) *Cache {
+	return &Cache{service: service, store: platformStore()}
+}
+
+func account(key oidc.TokenCacheKey) string {
+	return key.ClientID + "@" + key.Issuer
+}
+
+// Load returns the tokens stored for the key, or oidc.ErrNotCached.
+func (c *Cache) Load(ctx context.Context, key oidc.TokenCacheKey) (*oidc.CachedTokens, error) {
+	data, err := c.store.get(ctx, c.service, account(key))
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, oidc.ErrNotCached
+		}
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("keychain: malformed item: %v", err)
+	}
+	var tokens oidc.CachedTokens
+	if err := json.Unmarshal(b, &tokens); err != nil {
+		return nil, fmt.Errorf("keychain: malformed item: %v", err)
+	}
+	return &tokens, nil
+}
+
+// Store stores the tokens for the key.
+func (c *Cache) Store(ctx context.Context, key oidc.TokenCacheKey, tokens *oidc.CachedTokens) error {
+	b, err := json.Marshal(tokens)
+	if err != nil {
+		return fmt.Errorf("keychain: encoding tokens: %v", err)
+	}
+	// Stores are only given printable data, which some of them require.
+	data := base64.StdEncoding.EncodeToString(b)
+	return c.store.set(ctx, c.service, account(key), []byte(data))
+}
+
+// Delete removes the tokens stored for the key.
+func (c *Cache) Delete(ctx context.Context, key oidc.TokenCacheKey) error {
+	if err := c.store.delete(ctx, c.service, account(key)); err != nil && !errors.Is(err, errNotFound) {
+		return err
+	}
+	return nil
+}
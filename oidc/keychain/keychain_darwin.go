@@ -0,0 +1,70 @@
+//go:build darwin
+
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityPath is the command line interface of the macOS Keychain.
+const securityPath = "/usr/bin/security"
+
+// securityNotFound is the exit status of security for missing items.
+const securityNotFound = 44
+
+func platformStore() store {
+	return securityStore{}
+}
+
+// securityStore stores items as generic passwords of the login Keychain.
+type securityStore struct{}
+
+func (securityStore) get(ctx context.Context, service, account string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, securityPath, "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		return nil, securityError("reading", err)
+	}
+	return bytes.TrimSpace(out), nil
+}
+
+func (securityStore) set(ctx context.Context, service, account string, data []byte) error {
+	// Commands are read from stdin, rather than passed as arguments, so the
+	// data isn't visible to other processes.
+	cmd := exec.CommandContext(ctx, securityPath, "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n",
+		securityQuote(service), securityQuote(account), hex.EncodeToString(data)))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("keychain: writing item: %v: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (securityStore) delete(ctx context.Context, service, account string) error {
+	if err := exec.CommandContext(ctx, securityPath, "delete-generic-password", "-s", service, "-a", account).Run(); err != nil {
+		return securityError("deleting", err)
+	}
+	return nil
+}
+
+func securityError(op string, err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == securityNotFound {
+			return errNotFound
+		}
+		return fmt.Errorf("keychain: %s item: %v: %s", op, err, bytes.TrimSpace(exitErr.Stderr))
+	}
+	return fmt.Errorf("keychain: %s item: %v", op, err)
+}
+
+// securityQuote quotes an argument of a command read by security -i, which
+// splits commands like a shell.
+func securityQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,70 @@
+//go:build !darwin && !windows
+
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// secretTool is the command line interface of libsecret. It's a variable so
+// tests can replace it.
+var secretTool = "secret-tool"
+
+func platformStore() store {
+	return secretServiceStore{}
+}
+
+// secretServiceStore stores items in the default collection of the Secret
+// Service, with "service" and "account" attributes.
+type secretServiceStore struct{}
+
+func (secretServiceStore) get(ctx context.Context, service, account string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, secretTool, "lookup", "service", service, "account", account).Output()
+	if err != nil {
+		// secret-tool exits with status 1, and prints nothing, for
+		// missing items.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(out) == 0 && len(exitErr.Stderr) == 0 {
+			return nil, errNotFound
+		}
+		return nil, secretToolError("reading", err)
+	}
+	return bytes.TrimSpace(out), nil
+}
+
+func (secretServiceStore) set(ctx context.Context, service, account string, data []byte) error {
+	// The secret is read from stdin, so it isn't visible to other
+	// processes.
+	cmd := exec.CommandContext(ctx, secretTool, "store", "--label", service+": "+account, "service", service, "account", account)
+	cmd.Stdin = bytes.NewReader(data)
+	if _, err := cmd.Output(); err != nil {
+		return secretToolError("writing", err)
+	}
+	return nil
+}
+
+func (secretServiceStore) delete(ctx context.Context, service, account string) error {
+	if _, err := exec.CommandContext(ctx, secretTool, "clear", "service", service, "account", account).Output(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(exitErr.Stderr) == 0 {
+			return errNotFound
+		}
+		return secretToolError("deleting", err)
+	}
+	return nil
+}
+
+func secretToolError(op string, err error) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("keychain: %s item: %s isn't installed", op, secretTool)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("keychain: %s item: %v: %s", op, err, bytes.TrimSpace(exitErr.Stderr))
+	}
+	return fmt.Errorf("keychain: %s item: %v", op, err)
+}
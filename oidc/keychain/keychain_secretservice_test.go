@@ -0,0 +1,36 @@
+//go:build linux
+
+package keychain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSecretTool implements the secret-tool commands used by the store,
+// keeping items in files named after a hash of their attributes.
+const fakeSecretTool = `#!/bin/sh
+op=$1; shift
+if [ "$op" = store ]; then shift 2; fi
+f="$DIR/$(printf '%s\n%s' "$2" "$4" | sha256sum | cut -c1-16)"
+case $op in
+store) cat > "$f" ;;
+lookup) [ -f "$f" ] || exit 1; cat "$f" ;;
+clear) [ -f "$f" ] || exit 1; rm "$f" ;;
+*) echo "unknown command $op" >&2; exit 2 ;;
+esac
+`
+
+func TestSecretServiceStore(t *testing.T) {
+	dir := t.TempDir()
+	tool := filepath.Join(dir, "secret-tool")
+	if err := os.WriteFile(tool, []byte(fakeSecretTool), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DIR", dir)
+	defer func(s string) { secretTool = s }(secretTool)
+	secretTool = tool
+
+	testCache(t, New("test"))
+}
@@ -0,0 +1,78 @@
+package keychain
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+type memoryStore map[string][]byte
+
+func (m memoryStore) get(ctx context.Context, service, account string) ([]byte, error) {
+	data, ok := m[service+":"+account]
+	if !ok {
+		return nil, errNotFound
+	}
+	return data, nil
+}
+
+func (m memoryStore) set(ctx context.Context, service, account string, data []byte) error {
+	m[service+":"+account] = data
+	return nil
+}
+
+func (m memoryStore) delete(ctx context.Context, service, account string) error {
+	if _, ok := m[service+":"+account]; !ok {
+		return errNotFound
+	}
+	delete(m, service+":"+account)
+	return nil
+}
+
+func testCache(t *testing.T, cache *Cache) {
+	t.Helper()
+	ctx := context.Background()
+	key := oidc.TokenCacheKey{Issuer: "https://foo", ClientID: "cli"}
+	other := oidc.TokenCacheKey{Issuer: "https://bar", ClientID: "cli"}
+
+	if _, err := cache.Load(ctx, key); !errors.Is(err, oidc.ErrNotCached) {
+		t.Fatalf("expected ErrNotCached, got %v", err)
+	}
+	tokens := &oidc.CachedTokens{
+		IDToken:      strings.Repeat("i", 6000),
+		AccessToken:  "at",
+		RefreshToken: "rt",
+		Expiry:       time.Unix(4102444800, 0).UTC(),
+	}
+	if err := cache.Store(ctx, key, tokens); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cache.Load(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *tokens {
+		t.Errorf("expected %+v, got %+v", tokens, got)
+	}
+	if _, err := cache.Load(ctx, other); !errors.Is(err, oidc.ErrNotCached) {
+		t.Errorf("expected ErrNotCached for other issuer, got %v", err)
+	}
+
+	if err := cache.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Load(ctx, key); !errors.Is(err, oidc.ErrNotCached) {
+		t.Errorf("expected ErrNotCached after delete, got %v", err)
+	}
+	if err := cache.Delete(ctx, key); err != nil {
+		t.Errorf("deleting missing item: %v", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	testCache(t, &Cache{service: "test", store: memoryStore{}})
+}
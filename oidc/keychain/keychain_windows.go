@@ -0,0 +1,173 @@
+//go:build windows
+
+package keychain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+
+	// maxBlobSize is CRED_MAX_CREDENTIAL_BLOB_SIZE. Larger items, such as
+	// tokens with large ID Tokens, are split across several credentials.
+	maxBlobSize = 5 * 512
+
+	errorNotFound syscall.Errno = 1168
+)
+
+// credential is the CREDENTIALW structure.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+func platformStore() store {
+	return credentialStore{}
+}
+
+// credentialStore stores items as generic credentials of the Credential
+// Manager, named "<service>:<account>". Items larger than a credential are
+// continued in credentials named "<service>:<account>#1", "#2" and so on.
+type credentialStore struct{}
+
+func target(service, account string, part int) string {
+	t := service + ":" + account
+	if part > 0 {
+		t += "#" + strconv.Itoa(part)
+	}
+	return t
+}
+
+func (credentialStore) get(ctx context.Context, service, account string) ([]byte, error) {
+	var data []byte
+	for part := 0; ; part++ {
+		b, err := credRead(target(service, account, part))
+		if errors.Is(err, errNotFound) && part > 0 {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b...)
+	}
+}
+
+func (credentialStore) set(ctx context.Context, service, account string, data []byte) error {
+	part := 0
+	for ; part == 0 || len(data) > 0; part++ {
+		n := len(data)
+		if n > maxBlobSize {
+			n = maxBlobSize
+		}
+		if err := credWrite(target(service, account, part), account, data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	// Remove the remaining parts of a longer item written before.
+	for ; ; part++ {
+		if err := credDelete(target(service, account, part)); err != nil {
+			if errors.Is(err, errNotFound) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (credentialStore) delete(ctx context.Context, service, account string) error {
+	for part := 0; ; part++ {
+		if err := credDelete(target(service, account, part)); err != nil {
+			if errors.Is(err, errNotFound) && part > 0 {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func credRead(target string) ([]byte, error) {
+	name, err := syscall.UTF16PtrFromString(target)
+	if err != nil {
+		return nil, fmt.Errorf("keychain: invalid item name: %v", err)
+	}
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		return nil, credError("reading", err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	if cred.CredentialBlobSize == 0 {
+		return nil, nil
+	}
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	return append([]byte(nil), blob...), nil
+}
+
+func credWrite(target, account string, blob []byte) error {
+	name, err := syscall.UTF16PtrFromString(target)
+	if err != nil {
+		return fmt.Errorf("keychain: invalid item name: %v", err)
+	}
+	user, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return fmt.Errorf("keychain: invalid item name: %v", err)
+	}
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         name,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return credError("writing", err)
+	}
+	return nil
+}
+
+func credDelete(target string) error {
+	name, err := syscall.UTF16PtrFromString(target)
+	if err != nil {
+		return fmt.Errorf("keychain: invalid item name: %v", err)
+	}
+	if r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0); r == 0 {
+		return credError("deleting", err)
+	}
+	return nil
+}
+
+func credError(op string, err error) error {
+	if errors.Is(err, errorNotFound) {
+		return errNotFound
+	}
+	return fmt.Errorf("keychain: %s item: %v", op, err)
+}
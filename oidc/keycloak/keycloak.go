@@ -0,0 +1,168 @@
+// Package keycloak decodes the role and group claims of tokens issued by
+// Keycloak.
+//
+// Keycloak places realm roles in the "realm_access" claim, client roles in the
+// "resource_access" claim keyed by client ID, and group memberships in the
+// "groups" claim if the group membership mapper is configured:
+//
+//	{
+//		"realm_access": {"roles": ["offline_access", "admin"]},
+//		"resource_access": {"my-app": {"roles": ["editor"]}},
+//		"groups": ["/engineering/backend"]
+//	}
+//
+// Use ParseClaims on a verified token to authorize users by role:
+//
+//	claims, err := keycloak.ParseClaims(idToken)
+//	if err != nil {
+//		// handle error
+//	}
+//	if !claims.HasClientRole("my-app", "editor") {
+//		// deny
+//	}
+package keycloak
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// IssuerURL returns the issuer of a realm. baseURL is the address Keycloak is
+// served at, including the "/auth" prefix of versions before 17.
+func IssuerURL(baseURL, realm string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/realms/" + realm
+}
+
+// NewProvider discovers the provider of a realm.
+func NewProvider(ctx context.Context, baseURL, realm string) (*oidc.Provider, error) {
+	return oidc.NewProvider(ctx, IssuerURL(baseURL, realm))
+}
+
+// Access lists the roles granted in a realm or to a client.
+type Access struct {
+	Roles []string `json:"roles"`
+}
+
+// Claims are the Keycloak specific claims of a token.
+type Claims struct {
+	PreferredUsername string `json:"preferred_username"`
+
+	// RealmAccess holds the realm roles of the user.
+	RealmAccess Access `json:"realm_access"`
+	// ResourceAccess holds the client roles of the user, keyed by client ID.
+	ResourceAccess map[string]Access `json:"resource_access"`
+	// Groups holds group memberships. Depending on the "Full group path"
+	// setting of the mapper, groups are either full paths, such as
+	// "/engineering/backend", or names, such as "backend".
+	Groups []string `json:"groups"`
+}
+
+// claimsSource is implemented by verified ID Tokens and UserInfo responses.
+type claimsSource interface {
+	Claims(v interface{}) error
+}
+
+// ParseClaims decodes the Keycloak claims of a verified *oidc.IDToken or
+// *oidc.UserInfo.
+func ParseClaims(token claimsSource) (*Claims, error) {
+	var c Claims
+	if err := token.Claims(&c); err != nil {
+		return nil, fmt.Errorf("keycloak: decoding claims: %v", err)
+	}
+	return &c, nil
+}
+
+// HasRealmRole reports whether the user was granted a realm role.
+func (c *Claims) HasRealmRole(role string) bool {
+	return contains(c.RealmAccess.Roles, role)
+}
+
+// ClientRoles returns the roles the user was granted for a client.
+func (c *Claims) ClientRoles(clientID string) []string {
+	return c.ResourceAccess[clientID].Roles
+}
+
+// HasClientRole reports whether the user was granted a role of a client.
+func (c *Claims) HasClientRole(clientID, role string) bool {
+	return contains(c.ClientRoles(clientID), role)
+}
+
+// Roles returns the realm roles and the roles of a client, without duplicates.
+// Client roles are prefixed with the client ID and a colon, such as
+// "my-app:editor", so they can't be confused with realm roles of the same
+// name.
+func (c *Claims) Roles(clientID string) []string {
+	roles := make([]string, 0, len(c.RealmAccess.Roles)+len(c.ClientRoles(clientID)))
+	seen := make(map[string]bool)
+	add := func(role string) {
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	for _, role := range c.RealmAccess.Roles {
+		add(role)
+	}
+	for _, role := range c.ClientRoles(clientID) {
+		add(clientID + ":" + role)
+	}
+	return roles
+}
+
+// InGroup reports whether the user is a member of a group. A group with a
+// leading slash is compared to full group paths, and also matches members of
+// subgroups. Otherwise it's compared to the last element of each group, so it
+// works with either setting of the group mapper.
+func (c *Claims) InGroup(group string) bool {
+	fullPath := strings.HasPrefix(group, "/")
+	group = strings.Trim(group, "/")
+	if group == "" {
+		return false
+	}
+	for _, g := range c.Groups {
+		if fullPath {
+			if g == "/"+group || strings.HasPrefix(g, "/"+group+"/") {
+				return true
+			}
+			continue
+		}
+		if g == group || strings.HasSuffix(g, "/"+group) {
+			return true
+		}
+	}
+	return false
+}
+
+// MapGroups translates group memberships into application roles. mapping is
+// keyed by group, as accepted by InGroup. The result is sorted and has no
+// duplicates.
+func (c *Claims) MapGroups(mapping map[string][]string) []string {
+	var roles []string
+	seen := make(map[string]bool)
+	for group, mapped := range mapping {
+		if !c.InGroup(group) {
+			continue
+		}
+		for _, role := range mapped {
+			if !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
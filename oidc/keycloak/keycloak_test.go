@@ -0,0 +1,77 @@
+package keycloak
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type rawClaims string
+
+func (r rawClaims) Claims(v interface{}) error {
+	return json.Unmarshal([]byte(r), v)
+}
+
+const testClaims = `{
+	"preferred_username": "jane",
+	"realm_access": {"roles": ["offline_access", "admin"]},
+	"resource_access": {
+		"my-app": {"roles": ["editor", "admin"]},
+		"other-app": {"roles": ["viewer"]}
+	},
+	"groups": ["/engineering/backend", "/sales"]
+}`
+
+func TestClaims(t *testing.T) {
+	c, err := ParseClaims(rawClaims(testClaims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.PreferredUsername != "jane" {
+		t.Errorf("unexpected username %q", c.PreferredUsername)
+	}
+	if !c.HasRealmRole("admin") || c.HasRealmRole("editor") {
+		t.Errorf("unexpected realm roles %v", c.RealmAccess.Roles)
+	}
+	if !c.HasClientRole("my-app", "editor") || c.HasClientRole("my-app", "viewer") || c.HasClientRole("missing", "editor") {
+		t.Errorf("unexpected client roles %v", c.ResourceAccess)
+	}
+	want := []string{"offline_access", "admin", "my-app:editor", "my-app:admin"}
+	if got := c.Roles("my-app"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Roles() = %v, want %v", got, want)
+	}
+
+	for group, want := range map[string]bool{
+		"/engineering":         true,
+		"/engineering/backend": true,
+		"/engineer":            false,
+		"backend":              true,
+		"engineering":          false,
+		"sales":                true,
+		"/sales/":              true,
+		"/marketing":           false,
+	} {
+		if got := c.InGroup(group); got != want {
+			t.Errorf("InGroup(%q) = %v, want %v", group, got, want)
+		}
+	}
+
+	roles := c.MapGroups(map[string][]string{
+		"/engineering": {"deploy", "read"},
+		"sales":        {"read", "crm"},
+		"/marketing":   {"publish"},
+	})
+	if want := []string{"crm", "deploy", "read"}; !reflect.DeepEqual(roles, want) {
+		t.Errorf("MapGroups() = %v, want %v", roles, want)
+	}
+
+	if _, err := ParseClaims(rawClaims(`{"realm_access": []}`)); err == nil {
+		t.Errorf("expected error for malformed claims")
+	}
+}
+
+func TestIssuerURL(t *testing.T) {
+	if got, want := IssuerURL("https://sso.example.com/auth/", "main"), "https://sso.example.com/auth/realms/main"; got != want {
+		t.Errorf("IssuerURL() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,376 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+const (
+	// DefaultKeyRotationPeriod is the default age at which a KeyManager
+	// replaces its signing key.
+	DefaultKeyRotationPeriod = 30 * 24 * time.Hour
+	// DefaultKeyRetentionPeriod is the default time a KeyManager keeps
+	// publishing a key after it has been replaced.
+	DefaultKeyRetentionPeriod = 7 * 24 * time.Hour
+)
+
+// ManagedKey is a private key of a KeyManager.
+type ManagedKey struct {
+	// KeyID is the key's "kid", the RFC 7638 thumbprint of the public key.
+	KeyID     string
+	Algorithm string
+	Key       crypto.Signer
+	// Created is the time the key was generated.
+	Created time.Time
+	// Retired is the time the key was replaced by a newer key, or zero for
+	// the current signing key.
+	Retired time.Time
+}
+
+// KeyStore persists the keys of a KeyManager. Instances sharing a store, such
+// as replicas of a service, share their keys.
+type KeyStore interface {
+	// LoadKeys returns the stored keys. It returns no keys and no error if
+	// the store is empty.
+	LoadKeys(ctx context.Context) ([]*ManagedKey, error)
+	// SaveKeys replaces the stored keys.
+	SaveKeys(ctx context.Context, keys []*ManagedKey) error
+}
+
+// MemoryKeyStore is a KeyStore that keeps keys in memory. Keys are lost when
+// the process exits.
+type MemoryKeyStore struct {
+	mu   sync.Mutex
+	keys []*ManagedKey
+}
+
+// LoadKeys returns the stored keys.
+func (s *MemoryKeyStore) LoadKeys(ctx context.Context) ([]*ManagedKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*ManagedKey(nil), s.keys...), nil
+}
+
+// SaveKeys replaces the stored keys.
+func (s *MemoryKeyStore) SaveKeys(ctx context.Context, keys []*ManagedKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append([]*ManagedKey(nil), keys...)
+	return nil
+}
+
+// FileKeyStore is a KeyStore that keeps keys in a JSON file at the given path.
+// Private keys are stored unencrypted as PKCS #8, so the file is created
+// readable by its owner only.
+type FileKeyStore string
+
+type storedKey struct {
+	KeyID     string    `json:"kid"`
+	Algorithm string    `json:"alg"`
+	Key       string    `json:"pkcs8"`
+	Created   time.Time `json:"created"`
+	Retired   time.Time `json:"retired,omitempty"`
+}
+
+// LoadKeys reads the keys from the file. A missing file is an empty store.
+func (s FileKeyStore) LoadKeys(ctx context.Context) ([]*ManagedKey, error) {
+	b, err := os.ReadFile(string(s))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("oidc: reading key store: %v", err)
+	}
+	var stored []storedKey
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return nil, fmt.Errorf("oidc: decoding key store: %v", err)
+	}
+	keys := make([]*ManagedKey, 0, len(stored))
+	for _, k := range stored {
+		der, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: decoding key %s: %v", k.KeyID, err)
+		}
+		priv, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: parsing key %s: %v", k.KeyID, err)
+		}
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("oidc: key %s of type %T can't sign", k.KeyID, priv)
+		}
+		keys = append(keys, &ManagedKey{KeyID: k.KeyID, Algorithm: k.Algorithm, Key: signer, Created: k.Created, Retired: k.Retired})
+	}
+	return keys, nil
+}
+
+// SaveKeys replaces the file's contents. The file is replaced atomically.
+func (s FileKeyStore) SaveKeys(ctx context.Context, keys []*ManagedKey) error {
+	stored := make([]storedKey, 0, len(keys))
+	for _, k := range keys {
+		der, err := x509.MarshalPKCS8PrivateKey(k.Key)
+		if err != nil {
+			return fmt.Errorf("oidc: encoding key %s: %v", k.KeyID, err)
+		}
+		stored = append(stored, storedKey{KeyID: k.KeyID, Algorithm: k.Algorithm, Key: base64.StdEncoding.EncodeToString(der), Created: k.Created, Retired: k.Retired})
+	}
+	b, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(string(s)), filepath.Base(string(s))+".tmp")
+	if err != nil {
+		return fmt.Errorf("oidc: writing key store: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("oidc: writing key store: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("oidc: writing key store: %v", err)
+	}
+	if err := os.Rename(f.Name(), string(s)); err != nil {
+		return fmt.Errorf("oidc: writing key store: %v", err)
+	}
+	return nil
+}
+
+// KeyManagerConfig is the configuration of a KeyManager.
+type KeyManagerConfig struct {
+	// Algorithm of generated keys. Defaults to ES256.
+	Algorithm string
+	// Store persists keys. Defaults to a MemoryKeyStore.
+	Store KeyStore
+	// RotationPeriod is the age at which the signing key is replaced.
+	// Defaults to DefaultKeyRotationPeriod.
+	RotationPeriod time.Duration
+	// RetentionPeriod is how long replaced keys are still published, so
+	// providers that cached the previous key set can verify tokens signed
+	// before the rotation. It should exceed the MaxAge of the JWKSHandler
+	// serving the keys. Defaults to DefaultKeyRetentionPeriod.
+	RetentionPeriod time.Duration
+	// Now overrides the clock used to decide when to rotate keys. Defaults to
+	// time.Now.
+	Now func() time.Time
+}
+
+// KeyManager manages the client's own signing keys, used for private_key_jwt
+// client assertions, DPoP proofs and request objects. It generates a key when
+// none exists, replaces the signing key once it reaches the rotation period,
+// and publishes current and recently replaced public keys as a JSON Web Key
+// Set for providers that fetch the client's jwks_uri.
+//
+//	keys, err := oidc.NewKeyManager(ctx, &oidc.KeyManagerConfig{
+//		Store: oidc.FileKeyStore("/var/lib/app/keys.json"),
+//	})
+//	if err != nil {
+//		// handle error
+//	}
+//	http.Handle("/jwks.json", keys)
+//
+//	signer, err := keys.Signer(ctx)
+//	if err != nil {
+//		// handle error
+//	}
+//	assertion, err := oidc.ClientAssertion(ctx, signer, clientID, provider.Endpoint().TokenURL, 0)
+type KeyManager struct {
+	alg       string
+	store     KeyStore
+	rotation  time.Duration
+	retention time.Duration
+	now       func() time.Time
+
+	mu   sync.Mutex
+	keys []*ManagedKey
+}
+
+// NewKeyManager loads the keys of the store, generating a signing key if the
+// store has none or the current key is due for rotation.
+func NewKeyManager(ctx context.Context, config *KeyManagerConfig) (*KeyManager, error) {
+	m := &KeyManager{
+		alg:       config.Algorithm,
+		store:     config.Store,
+		rotation:  config.RotationPeriod,
+		retention: config.RetentionPeriod,
+		now:       config.Now,
+	}
+	if m.alg == "" {
+		m.alg = ES256
+	}
+	if !supportedAlgorithms[m.alg] {
+		return nil, fmt.Errorf("oidc: can't generate keys for algorithm %q", m.alg)
+	}
+	if err := checkFIPS(m.alg); err != nil {
+		return nil, err
+	}
+	if m.store == nil {
+		m.store = &MemoryKeyStore{}
+	}
+	if m.rotation <= 0 {
+		m.rotation = DefaultKeyRotationPeriod
+	}
+	if m.retention <= 0 {
+		m.retention = DefaultKeyRetentionPeriod
+	}
+	if m.now == nil {
+		m.now = time.Now
+	}
+	if _, err := m.current(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// generateKey returns a new private key for the algorithm.
+func generateKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case RS256, RS384, RS512, PS256, PS384, PS512:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case ES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case ES384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case ES512:
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case EdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	}
+	return nil, fmt.Errorf("oidc: can't generate keys for algorithm %q", alg)
+}
+
+// Signer returns a Signer using the current signing key, rotating the key
+// first if it's due.
+func (m *KeyManager) Signer(ctx context.Context) (Signer, error) {
+	key, err := m.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewCryptoSigner(key.Key, key.Algorithm, key.KeyID)
+}
+
+// current returns the current signing key, rotating it if it's due.
+func (m *KeyManager) current(ctx context.Context) (*ManagedKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if key := m.currentLocked(); m.fresh(key, now) {
+		return key, nil
+	}
+	// Another instance sharing the store may have rotated the key already.
+	keys, err := m.store.LoadKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.keys = keys
+	if key := m.currentLocked(); m.fresh(key, now) {
+		return key, nil
+	}
+	return m.rotateLocked(ctx, now)
+}
+
+// Rotate replaces the signing key immediately, for example after the key may
+// have been compromised. The replaced key is still published for the
+// retention period; to stop publishing a compromised key, remove it from the
+// store.
+func (m *KeyManager) Rotate(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.rotateLocked(ctx, m.now())
+	return err
+}
+
+// fresh reports whether the key can still be used as the signing key. Keys of
+// a different algorithm, left over from a previous configuration, are rotated.
+func (m *KeyManager) fresh(key *ManagedKey, now time.Time) bool {
+	return key != nil && key.Algorithm == m.alg && now.Before(key.Created.Add(m.rotation))
+}
+
+func (m *KeyManager) currentLocked() *ManagedKey {
+	var current *ManagedKey
+	for _, k := range m.keys {
+		if k.Retired.IsZero() && (current == nil || k.Created.After(current.Created)) {
+			current = k
+		}
+	}
+	return current
+}
+
+func (m *KeyManager) rotateLocked(ctx context.Context, now time.Time) (*ManagedKey, error) {
+	priv, err := generateKey(m.alg)
+	if err != nil {
+		return nil, err
+	}
+	jwk := jose.JSONWebKey{Key: priv.Public()}
+	thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: computing key id: %v", err)
+	}
+	key := &ManagedKey{
+		KeyID:     base64.RawURLEncoding.EncodeToString(thumbprint),
+		Algorithm: m.alg,
+		Key:       priv,
+		Created:   now,
+	}
+
+	keys := []*ManagedKey{key}
+	for _, k := range m.keys {
+		if k.Retired.IsZero() {
+			retired := *k
+			retired.Retired = now
+			k = &retired
+		}
+		if now.Before(k.Retired.Add(m.retention)) {
+			keys = append(keys, k)
+		}
+	}
+	if err := m.store.SaveKeys(ctx, keys); err != nil {
+		return nil, err
+	}
+	m.keys = keys
+	return key, nil
+}
+
+// PublicKeys returns the public keys of the current and recently replaced
+// signing keys, newest first.
+func (m *KeyManager) PublicKeys() []jose.JSONWebKey {
+	m.mu.Lock()
+	keys := append([]*ManagedKey(nil), m.keys...)
+	m.mu.Unlock()
+
+	now := m.now()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Created.After(keys[j].Created) })
+	jwks := make([]jose.JSONWebKey, 0, len(keys))
+	for _, k := range keys {
+		if !k.Retired.IsZero() && !now.Before(k.Retired.Add(m.retention)) {
+			continue
+		}
+		jwks = append(jwks, jose.JSONWebKey{Key: k.Key.Public(), KeyID: k.KeyID, Algorithm: k.Algorithm, Use: "sig"})
+	}
+	return jwks
+}
+
+// ServeHTTP serves the public keys as a JSON Web Key Set, using a JWKSHandler
+// with the default MaxAge.
+func (m *KeyManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(&JWKSHandler{Keys: m}).ServeHTTP(w, r)
+}
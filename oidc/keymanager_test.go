@@ -0,0 +1,123 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func TestKeyManager(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	store := &MemoryKeyStore{}
+	config := &KeyManagerConfig{
+		Store:           store,
+		RotationPeriod:  time.Hour,
+		RetentionPeriod: 10 * time.Minute,
+		Now:             func() time.Time { return now },
+	}
+	m, err := NewKeyManager(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := m.Signer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := signer.KeyID()
+	raw, err := SignJWT(ctx, signer, map[string]interface{}{"iss": "app", "aud": "app", "exp": 4102444800})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pubs []crypto.PublicKey
+	for _, k := range m.PublicKeys() {
+		pubs = append(pubs, k.Key)
+	}
+	verifier := NewVerifier("app", &StaticKeySet{PublicKeys: pubs}, &Config{ClientID: "app", SupportedSigningAlgs: []string{ES256}})
+	if _, err := verifier.Verify(ctx, raw); err != nil {
+		t.Fatalf("verifying token signed by key manager: %v", err)
+	}
+
+	// A second instance sharing the store uses the same key.
+	other, err := NewKeyManager(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := other.Signer(ctx); s.KeyID() != first {
+		t.Errorf("second key manager uses key %q, want %q", s.KeyID(), first)
+	}
+
+	now = now.Add(time.Hour)
+	signer, err = m.Signer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.KeyID() == first {
+		t.Fatalf("expected key to be rotated")
+	}
+	if keys := m.PublicKeys(); len(keys) != 2 || keys[0].KeyID != signer.KeyID() || keys[1].KeyID != first {
+		t.Errorf("unexpected public keys after rotation %v", keys)
+	}
+	// The other instance picks up the rotation from the store.
+	if s, _ := other.Signer(ctx); s.KeyID() != signer.KeyID() {
+		t.Errorf("second key manager uses key %q after rotation, want %q", s.KeyID(), signer.KeyID())
+	}
+
+	now = now.Add(10 * time.Minute)
+	if keys := m.PublicKeys(); len(keys) != 1 {
+		t.Errorf("expected replaced key to be unpublished after retention period, got %v", keys)
+	}
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/jwks.json", nil))
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(w.Body.Bytes(), &jwks); err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != signer.KeyID() || !jwks.Keys[0].IsPublic() {
+		t.Errorf("unexpected key set %s", w.Body.String())
+	}
+
+	if _, err := NewKeyManager(ctx, &KeyManagerConfig{Algorithm: "HS256"}); err == nil {
+		t.Errorf("expected error for symmetric algorithm")
+	}
+}
+
+func TestFileKeyStore(t *testing.T) {
+	ctx := context.Background()
+	store := FileKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	for _, alg := range []string{RS256, ES384, EdDSA} {
+		m, err := NewKeyManager(ctx, &KeyManagerConfig{Algorithm: alg, Store: store})
+		if fipsRejects(alg) {
+			if err == nil {
+				t.Errorf("%s: expected key manager to be refused in FIPS mode", alg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		keys, err := store.LoadKeys(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		signer, err := m.Signer(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		found := false
+		for _, k := range keys {
+			found = found || (k.KeyID == signer.KeyID() && k.Algorithm == alg && k.Retired.IsZero())
+		}
+		if !found {
+			t.Errorf("%s: current key %q not found in store", alg, signer.KeyID())
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// KeyInfo describes a key held by a RemoteKeySet. It holds no key material.
+type KeyInfo struct {
+	// KeyID is the kid of the key, which tokens reference in their header.
+	KeyID string
+	// Algorithm is the alg of the key, if the key set lists one.
+	Algorithm string
+	// Use is the use of the key, such as "sig", if the key set lists one.
+	Use string
+	// KeyType is "RSA", "EC" or "OKP".
+	KeyType string
+	// Size is the modulus size of RSA keys and the curve size of EC and OKP
+	// keys, in bits.
+	Size int
+	// Thumbprint is the base64url encoded SHA-256 thumbprint of the key, as
+	// defined by RFC 7638, which identifies it even without a kid.
+	Thumbprint string
+}
+
+// KeySetStatus is a snapshot of the state of a RemoteKeySet, for dashboards
+// and for debugging tokens rejected for an unknown kid.
+type KeySetStatus struct {
+	// URL the keys are fetched from.
+	URL string
+	// Keys currently cached, in the order of the key set. Keys that can't be
+	// used for verification, such as undersized RSA keys, are omitted.
+	Keys []KeyInfo
+	// RetainedKeys were removed from the key set by a refresh, but are still
+	// accepted until their retention, set by KeyRetentionContext, expires.
+	RetainedKeys []KeyInfo
+	// LastRefresh is when the keys were last fetched successfully. It's zero
+	// if they never were.
+	LastRefresh time.Time
+	// LastError is the error of the last refresh, or nil if it succeeded.
+	LastError error
+	// UnavailableSince is the time of the first failed refresh since the last
+	// successful one, or zero if the last refresh succeeded.
+	UnavailableSince time.Time
+	// Stale reports whether the DegradedModePolicy of the key set forbids
+	// using the cached keys.
+	Stale bool
+	// RetryAt is the time until which refreshes are suspended because the
+	// remote asked clients to back off, or zero if they aren't.
+	RetryAt time.Time
+}
+
+// Status returns the current state of the key set. It never makes a request.
+//
+//	status := keySet.Status()
+//	for _, k := range status.Keys {
+//		log.Printf("kid=%s alg=%s refreshed=%v", k.KeyID, k.Algorithm, status.LastRefresh)
+//	}
+func (r *RemoteKeySet) Status() KeySetStatus {
+	now := r.now()
+	r.mu.RLock()
+	s := KeySetStatus{
+		URL:              r.jwksURL,
+		LastRefresh:      r.refreshedAt,
+		LastError:        r.lastErr,
+		UnavailableSince: r.unavailableSince,
+		Stale:            r.degraded.stale(r.unavailableSince, now),
+	}
+	keys := r.cachedKeys
+	r.mu.RUnlock()
+
+	retained := r.keysRetained()
+	for i := range retained {
+		s.RetainedKeys = append(s.RetainedKeys, keyInfo(&retained[i]))
+	}
+	s.RetryAt = r.limiter.retryAt(now)
+	s.Keys = make([]KeyInfo, 0, len(keys))
+	for i := range keys {
+		s.Keys = append(s.Keys, keyInfo(&keys[i]))
+	}
+	return s
+}
+
+// KeySetStatus returns the status of the provider's key set, which verifiers
+// created by the provider share. It returns false if the provider has no
+// remote key set.
+func (p *Provider) KeySetStatus() (KeySetStatus, bool) {
+	if p.jwksURL == "" {
+		return KeySetStatus{}, false
+	}
+	r, ok := p.remoteKeySet().(*RemoteKeySet)
+	if !ok {
+		return KeySetStatus{}, false
+	}
+	return r.Status(), true
+}
+
+func keyInfo(key *jose.JSONWebKey) KeyInfo {
+	info := KeyInfo{
+		KeyID:     key.KeyID,
+		Algorithm: key.Algorithm,
+		Use:       key.Use,
+	}
+	switch k := key.Key.(type) {
+	case *rsa.PublicKey:
+		info.KeyType = "RSA"
+		info.Size = k.N.BitLen()
+	case *ecdsa.PublicKey:
+		info.KeyType = "EC"
+		info.Size = k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		info.KeyType = "OKP"
+		info.Size = 256
+	}
+	if b, err := key.Thumbprint(crypto.SHA256); err == nil {
+		info.Thumbprint = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return info
+}
@@ -0,0 +1,68 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func TestKeySetStatus(t *testing.T) {
+	rsaKey, ecKey := newRSAKey(t), newECDSAKey(t)
+	rsaKey.keyID, ecKey.keyID = "rsa", "ec"
+	var failing int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{rsaKey.jwk(), ecKey.jwk()}})
+	}))
+	defer s.Close()
+
+	now := time.Unix(1000, 0)
+	ctx := ClockContext(context.Background(), func() time.Time { return now })
+	keySet := NewRemoteKeySet(ctx, s.URL)
+
+	status := keySet.Status()
+	if status.URL != s.URL || len(status.Keys) != 0 || !status.LastRefresh.IsZero() {
+		t.Errorf("expected empty status before the first refresh, got %+v", status)
+	}
+
+	if _, err := keySet.keysFromRemote(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status = keySet.Status()
+	if !status.LastRefresh.Equal(now) || status.LastError != nil || !status.RetryAt.IsZero() {
+		t.Errorf("unexpected status after refresh: %+v", status)
+	}
+	if len(status.Keys) != 2 {
+		t.Fatalf("expected 2 keys, got %+v", status.Keys)
+	}
+	if k := status.Keys[0]; k.KeyID != "rsa" || k.Algorithm != RS256 || k.Use != "sig" || k.KeyType != "RSA" || k.Size != 1028 || k.Thumbprint == "" {
+		t.Errorf("unexpected RSA key info: %+v", k)
+	}
+	if k := status.Keys[1]; k.KeyID != "ec" || k.Algorithm != ES256 || k.KeyType != "EC" || k.Size != 256 {
+		t.Errorf("unexpected EC key info: %+v", k)
+	}
+
+	atomic.StoreInt32(&failing, 1)
+	refreshed := now
+	now = now.Add(time.Minute)
+	if _, err := keySet.keysFromRemote(ctx); err == nil {
+		t.Fatal("expected refresh to fail")
+	}
+	status = keySet.Status()
+	if len(status.Keys) != 2 || !status.LastRefresh.Equal(refreshed) {
+		t.Errorf("expected cached keys to be kept, got %+v", status)
+	}
+	if status.LastError == nil || !status.UnavailableSince.Equal(now) || !status.RetryAt.Equal(now.Add(30*time.Second)) {
+		t.Errorf("unexpected status after failed refresh: %+v", status)
+	}
+}
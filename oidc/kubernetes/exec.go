@@ -0,0 +1,126 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// API versions of ExecCredential objects.
+const (
+	ExecCredentialV1      = "client.authentication.k8s.io/v1"
+	ExecCredentialV1Beta1 = "client.authentication.k8s.io/v1beta1"
+)
+
+// ExecInfoEnv is the environment variable through which kubectl and other
+// clients pass an ExecCredential describing the request to credential
+// plugins.
+const ExecInfoEnv = "KUBERNETES_EXEC_INFO"
+
+// ExecCredential is the object exchanged between Kubernetes clients, such as
+// kubectl, and exec credential plugins: clients pass the plugin an
+// ExecCredential with a spec, and the plugin prints one holding the
+// credential in its status.
+//
+// See: https://kubernetes.io/docs/reference/access-authn-authz/authentication/#client-go-credential-plugins
+type ExecCredential struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Spec       ExecCredentialSpec    `json:"spec"`
+	Status     *ExecCredentialStatus `json:"status,omitempty"`
+}
+
+// ExecCredentialSpec describes the request of a client.
+type ExecCredentialSpec struct {
+	// Interactive reports whether the plugin may interact with the user
+	// through stdin, for example to run a device authorization flow.
+	Interactive bool `json:"interactive"`
+	// Cluster describes the cluster the credential is for, if the
+	// kubeconfig sets provideClusterInfo.
+	Cluster json.RawMessage `json:"cluster,omitempty"`
+}
+
+// ExecCredentialStatus holds the credential returned by a plugin.
+type ExecCredentialStatus struct {
+	// ExpirationTimestamp is when the client must run the plugin again.
+	// Clients reuse the credential until then.
+	ExpirationTimestamp *time.Time `json:"expirationTimestamp,omitempty"`
+	// Token is the bearer token sent to the API server.
+	Token string `json:"token,omitempty"`
+}
+
+// ExecInfo returns the ExecCredential passed to the plugin in the
+// KUBERNETES_EXEC_INFO environment variable. It returns an ExecCredential for
+// the v1 API, requesting a non-interactive credential, if the variable isn't
+// set, as is the case for older clients.
+func ExecInfo() (*ExecCredential, error) {
+	info := &ExecCredential{APIVersion: ExecCredentialV1, Kind: "ExecCredential"}
+	env := os.Getenv(ExecInfoEnv)
+	if env == "" {
+		return info, nil
+	}
+	if err := json.Unmarshal([]byte(env), info); err != nil {
+		return nil, fmt.Errorf("kubernetes: malformed %s: %v", ExecInfoEnv, err)
+	}
+	switch info.APIVersion {
+	case ExecCredentialV1, ExecCredentialV1Beta1:
+	default:
+		return nil, fmt.Errorf("kubernetes: unsupported ExecCredential version %q", info.APIVersion)
+	}
+	return info, nil
+}
+
+// NewExecCredential returns an ExecCredential holding a verified ID Token,
+// which the API server accepts if it's configured to authenticate users with
+// the token's issuer. The credential expires with the token. The API version
+// is the one requested through KUBERNETES_EXEC_INFO.
+func NewExecCredential(token *oidc.IDToken) (*ExecCredential, error) {
+	if token.Raw() == "" {
+		return nil, errors.New("kubernetes: exec credentials require a raw token")
+	}
+	cred, err := ExecInfo()
+	if err != nil {
+		return nil, err
+	}
+	cred.Spec = ExecCredentialSpec{}
+	cred.Status = &ExecCredentialStatus{Token: token.Raw()}
+	if !token.Expiry.IsZero() {
+		expiry := token.Expiry.UTC()
+		cred.Status.ExpirationTimestamp = &expiry
+	}
+	return cred, nil
+}
+
+// WriteExecCredential writes the ExecCredential of a verified ID Token, see
+// NewExecCredential. It's usually the last step of a credential plugin, which
+// writes the credential to stdout:
+//
+//	idToken, err := verifier.Verify(ctx, rawIDToken)
+//	if err != nil {
+//		// handle error
+//	}
+//	if err := kubernetes.WriteExecCredential(os.Stdout, idToken); err != nil {
+//		// handle error
+//	}
+//
+// Plugins are configured in the user's kubeconfig:
+//
+//	users:
+//	- name: oidc
+//	  user:
+//	    exec:
+//	      apiVersion: client.authentication.k8s.io/v1
+//	      command: my-login
+//	      interactiveMode: IfAvailable
+func WriteExecCredential(w io.Writer, token *oidc.IDToken) error {
+	cred, err := NewExecCredential(token)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(cred)
+}
@@ -0,0 +1,78 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+func TestWriteExecCredential(t *testing.T) {
+	c := newTestCluster(t)
+	keySet := &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{c.priv.Public()}}
+	verifier := oidc.NewVerifier("https://login.example.com", keySet, &oidc.Config{ClientID: "kubectl", SupportedSigningAlgs: []string{oidc.ES256}})
+	raw := c.sign(t, `{"iss":"https://login.example.com","aud":"kubectl","sub":"jane","exp":4102444800}`)
+	idToken, err := verifier.Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		execInfo    string
+		wantVersion string
+		wantErr     bool
+	}{
+		{name: "no exec info", wantVersion: ExecCredentialV1},
+		{
+			name:        "v1beta1",
+			execInfo:    `{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","spec":{"interactive":true}}`,
+			wantVersion: ExecCredentialV1Beta1,
+		},
+		{
+			name:     "unsupported version",
+			execInfo: `{"apiVersion":"client.authentication.k8s.io/v1alpha1","kind":"ExecCredential","spec":{}}`,
+			wantErr:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(ExecInfoEnv, test.execInfo)
+			var buf bytes.Buffer
+			err := WriteExecCredential(&buf, idToken)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got struct {
+				APIVersion string `json:"apiVersion"`
+				Kind       string `json:"kind"`
+				Status     struct {
+					ExpirationTimestamp string `json:"expirationTimestamp"`
+					Token               string `json:"token"`
+				} `json:"status"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.APIVersion != test.wantVersion || got.Kind != "ExecCredential" {
+				t.Errorf("unexpected object %s", buf.Bytes())
+			}
+			if got.Status.Token != raw {
+				t.Errorf("expected the raw id token, got %q", got.Status.Token)
+			}
+			if want := time.Unix(4102444800, 0).UTC().Format(time.RFC3339); got.Status.ExpirationTimestamp != want {
+				t.Errorf("expected expiration %s, got %s", want, got.Status.ExpirationTimestamp)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+// Package kubernetes verifies Kubernetes projected service account tokens.
+//
+// Service account tokens are OpenID Connect compatible JWTs. The API server
+// publishes its discovery document and keys, and tokens carry the identity of
+// the pod that requested them in the "kubernetes.io" claim.
+//
+//	verifier, err := kubernetes.NewVerifier(ctx, kubernetes.Config{
+//		Audience: "my-service",
+//	})
+//	if err != nil {
+//		// handle error
+//	}
+//	token, err := verifier.Verify(ctx, rawToken)
+//	if err != nil {
+//		// handle error
+//	}
+//	log.Printf("request from %s/%s", token.Claims.Namespace, token.Claims.ServiceAccount.Name)
+//
+// WriteExecCredential helps build kubectl credential plugins, which log users
+// in and hand their ID Tokens to kubectl.
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// Well known paths and addresses available to pods running in a cluster.
+const (
+	// InClusterAPIServer is the address of the API server within a cluster.
+	InClusterAPIServer = "https://kubernetes.default.svc"
+	// DefaultIssuer is the default service account issuer of many
+	// distributions, including kubeadm clusters.
+	DefaultIssuer = "https://kubernetes.default.svc.cluster.local"
+
+	// ServiceAccountTokenPath holds the token of the pod's service account.
+	ServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	// ServiceAccountCAPath holds the cluster's CA bundle.
+	ServiceAccountCAPath = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// Config configures a Verifier.
+type Config struct {
+	// Audience the token must be bound to, set through the audience field of
+	// the projected volume or TokenRequest. Required.
+	Audience string
+
+	// Issuer is the cluster's service account issuer, the --service-account-issuer
+	// flag of the API server. If empty, the issuer advertised by the API
+	// server's discovery document is used.
+	Issuer string
+
+	// APIServer is the URL discovery is requested from. Defaults to
+	// InClusterAPIServer. The API server publishes discovery at its own
+	// address, which usually differs from the issuer. Keys advertised under
+	// the issuer URL are also fetched from the API server.
+	APIServer string
+
+	// JWKSFile, if set, loads the cluster's keys from a JSON Web Key Set file,
+	// such as the output of "kubectl get --raw /openid/v1/jwks", instead of
+	// using discovery. Issuer is required with this option.
+	JWKSFile string
+
+	// HTTPClient is used for discovery and key requests. Defaults to
+	// InClusterClient.
+	HTTPClient *http.Client
+
+	// Now overrides the clock used for expiry checks.
+	Now func() time.Time
+}
+
+// Claims are the Kubernetes specific claims of a service account token.
+type Claims struct {
+	Namespace      string     `json:"namespace"`
+	ServiceAccount ObjectRef  `json:"serviceaccount"`
+	Pod            *ObjectRef `json:"pod,omitempty"`
+	Secret         *ObjectRef `json:"secret,omitempty"`
+	Node           *ObjectRef `json:"node,omitempty"`
+}
+
+// ObjectRef references the Kubernetes object a token is bound to.
+type ObjectRef struct {
+	Name string `json:"name"`
+	UID  string `json:"uid"`
+}
+
+// Token is a verified service account token.
+type Token struct {
+	*oidc.IDToken
+
+	// Claims holds the "kubernetes.io" claim.
+	Claims Claims
+}
+
+// Verifier verifies service account tokens.
+type Verifier struct {
+	verifier *oidc.IDTokenVerifier
+	issuer   string
+}
+
+// InClusterClient returns an HTTP client that trusts the cluster's CA and
+// authenticates with the pod's service account token. The API server only
+// serves discovery to anonymous clients if the cluster allows it, so
+// authenticating is the safer default.
+func InClusterClient() (*http.Client, error) {
+	ca, err := os.ReadFile(ServiceAccountCAPath)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: reading cluster ca: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("kubernetes: no certificates found in cluster ca")
+	}
+	return &http.Client{
+		Transport: &bearerTransport{
+			tokenPath: ServiceAccountTokenPath,
+			base: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, nil
+}
+
+// bearerTransport authenticates with a token file. The file is read for each
+// request since the kubelet rotates projected tokens.
+type bearerTransport struct {
+	tokenPath string
+	base      http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := os.ReadFile(t.tokenPath)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: reading service account token: %v", err)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	return t.base.RoundTrip(req)
+}
+
+// NewVerifier returns a verifier for service account tokens bound to the
+// configured audience.
+func NewVerifier(ctx context.Context, config Config) (*Verifier, error) {
+	if config.Audience == "" {
+		return nil, errors.New("kubernetes: audience is required")
+	}
+	verifierConfig := &oidc.Config{
+		ClientID: config.Audience,
+		// Service account tokens are signed with RS256 or ES256,
+		// depending on the key type of the cluster.
+		SupportedSigningAlgs: []string{oidc.RS256, oidc.ES256},
+		Now:                  config.Now,
+	}
+
+	if config.JWKSFile != "" {
+		if config.Issuer == "" {
+			return nil, errors.New("kubernetes: issuer is required when loading keys from a file")
+		}
+		data, err := os.ReadFile(config.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes: reading jwks: %v", err)
+		}
+		var jwks jose.JSONWebKeySet
+		if err := json.Unmarshal(data, &jwks); err != nil {
+			return nil, fmt.Errorf("kubernetes: decoding jwks: %v", err)
+		}
+		// Drop keys RemoteKeySet would, such as encryption keys and
+		// keys too weak to be trusted.
+		keySet := &oidc.StaticKeySet{}
+		for _, key := range oidc.VerificationKeys(jwks.Keys) {
+			keySet.PublicKeys = append(keySet.PublicKeys, key.Key)
+		}
+		if len(keySet.PublicKeys) == 0 {
+			return nil, errors.New("kubernetes: jwks contains no verification keys")
+		}
+		return &Verifier{
+			verifier: oidc.NewVerifier(config.Issuer, keySet, verifierConfig),
+			issuer:   config.Issuer,
+		}, nil
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		var err error
+		if client, err = InClusterClient(); err != nil {
+			return nil, err
+		}
+	}
+	apiServer := config.APIServer
+	if apiServer == "" {
+		apiServer = InClusterAPIServer
+	}
+
+	ctx = oidc.ClientContext(ctx, client)
+	issuer := config.Issuer
+	if issuer == "" {
+		// The API server is trusted through the cluster's CA, so the
+		// issuer it advertises is used.
+		var err error
+		if issuer, err = discoverIssuer(ctx, apiServer); err != nil {
+			return nil, err
+		}
+	}
+
+	// The API server publishes discovery at its own address, while the
+	// issuer, and endpoints under it, may only be reachable from outside
+	// the cluster. Fetch them through the API server instead.
+	provider, err := oidc.NewProvider(ctx, issuer, oidc.WithFetchURL(apiServer))
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: discovery failed: %v", err)
+	}
+	var claims struct {
+		JWKSURL string `json:"jwks_uri"`
+	}
+	if err := provider.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("kubernetes: decoding discovery document: %v", err)
+	}
+	if claims.JWKSURL == "" {
+		return nil, errors.New("kubernetes: discovery document has no jwks_uri")
+	}
+	return &Verifier{
+		verifier: provider.Verifier(verifierConfig),
+		issuer:   issuer,
+	}, nil
+}
+
+// discoverIssuer returns the issuer advertised by the API server.
+func discoverIssuer(ctx context.Context, apiServer string) (string, error) {
+	provider, err := oidc.NewProvider(oidc.InsecureIssuerURLContext(ctx, apiServer), apiServer)
+	if err != nil {
+		return "", fmt.Errorf("kubernetes: discovery failed: %v", err)
+	}
+	var claims struct {
+		Issuer string `json:"issuer"`
+	}
+	if err := provider.Claims(&claims); err != nil {
+		return "", fmt.Errorf("kubernetes: decoding discovery document: %v", err)
+	}
+	if claims.Issuer == "" {
+		return "", errors.New("kubernetes: discovery document has no issuer")
+	}
+	return claims.Issuer, nil
+}
+
+// Issuer returns the issuer tokens are verified against.
+func (v *Verifier) Issuer() string {
+	return v.issuer
+}
+
+// Verify verifies a service account token and decodes its Kubernetes claims.
+// Legacy secret based tokens, which don't expire and aren't bound to an
+// audience, are rejected.
+func (v *Verifier) Verify(ctx context.Context, rawToken string) (*Token, error) {
+	idToken, err := v.verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, err
+	}
+	var claims struct {
+		Kubernetes *Claims `json:"kubernetes.io"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("kubernetes: decoding claims: %v", err)
+	}
+	if claims.Kubernetes == nil || claims.Kubernetes.Namespace == "" || claims.Kubernetes.ServiceAccount.Name == "" {
+		return nil, errors.New("kubernetes: token has no service account claims")
+	}
+	if want := "system:serviceaccount:" + claims.Kubernetes.Namespace + ":" + claims.Kubernetes.ServiceAccount.Name; idToken.Subject != want {
+		return nil, fmt.Errorf("kubernetes: subject %q doesn't match service account %q", idToken.Subject, want)
+	}
+	return &Token{IDToken: idToken, Claims: *claims.Kubernetes}, nil
+}
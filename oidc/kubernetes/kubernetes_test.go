@@ -0,0 +1,226 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+const testIssuer = "https://oidc.cluster.example.com"
+
+type testCluster struct {
+	priv *ecdsa.PrivateKey
+	jwks []byte
+	// publicJWKS advertises keys under the issuer URL, as API servers do
+	// when --service-account-jwks-uri isn't set.
+	publicJWKS bool
+}
+
+func newTestCluster(t *testing.T) *testCluster {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: priv.Public(), KeyID: "k1", Algorithm: "ES256", Use: "sig"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCluster{priv: priv, jwks: jwks}
+}
+
+func (c *testCluster) sign(t *testing.T, claims string) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: c.priv, KeyID: "k1"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := signer.Sign([]byte(claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func (c *testCluster) serve(t *testing.T) *httptest.Server {
+	var s *httptest.Server
+	s = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			jwksBase := s.URL
+			if c.publicJWKS {
+				jwksBase = testIssuer
+			}
+			fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":"%s/openid/v1/jwks","id_token_signing_alg_values_supported":["ES256"]}`, testIssuer, jwksBase)
+		case "/openid/v1/jwks":
+			w.Write(c.jwks)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+const podClaims = `{
+	"iss": %q,
+	"aud": ["my-service"],
+	"sub": "system:serviceaccount:team-a:builder",
+	"exp": 4102444800,
+	"kubernetes.io": {
+		"namespace": "team-a",
+		"serviceaccount": {"name": "builder", "uid": "sa-uid"},
+		"pod": {"name": "builder-7d9f", "uid": "pod-uid"}
+	}
+}`
+
+func TestVerifierDiscovery(t *testing.T) {
+	cluster := newTestCluster(t)
+	s := cluster.serve(t)
+	ctx := context.Background()
+
+	verifier, err := NewVerifier(ctx, Config{Audience: "my-service", APIServer: s.URL, HTTPClient: s.Client()})
+	if err != nil {
+		t.Fatalf("creating verifier: %v", err)
+	}
+	if verifier.Issuer() != testIssuer {
+		t.Errorf("unexpected issuer %q", verifier.Issuer())
+	}
+	token, err := verifier.Verify(ctx, cluster.sign(t, fmt.Sprintf(podClaims, testIssuer)))
+	if err != nil {
+		t.Fatalf("verifying token: %v", err)
+	}
+	if token.Claims.Namespace != "team-a" || token.Claims.ServiceAccount.Name != "builder" ||
+		token.Claims.Pod == nil || token.Claims.Pod.Name != "builder-7d9f" {
+		t.Errorf("unexpected claims %+v", token.Claims)
+	}
+
+	if _, err := NewVerifier(ctx, Config{Audience: "my-service", Issuer: "https://other", APIServer: s.URL, HTTPClient: s.Client()}); err == nil {
+		t.Errorf("expected issuer mismatch")
+	}
+	if _, err := NewVerifier(ctx, Config{APIServer: s.URL, HTTPClient: s.Client()}); err == nil {
+		t.Errorf("expected audience to be required")
+	}
+}
+
+func TestVerifierPublicJWKS(t *testing.T) {
+	cluster := newTestCluster(t)
+	cluster.publicJWKS = true
+	s := cluster.serve(t)
+	ctx := context.Background()
+
+	// Keys published under the issuer are fetched through the API server.
+	for _, issuer := range []string{"", testIssuer} {
+		verifier, err := NewVerifier(ctx, Config{Audience: "my-service", Issuer: issuer, APIServer: s.URL, HTTPClient: s.Client()})
+		if err != nil {
+			t.Fatalf("creating verifier: %v", err)
+		}
+		if _, err := verifier.Verify(ctx, cluster.sign(t, fmt.Sprintf(podClaims, testIssuer))); err != nil {
+			t.Errorf("verifying token with issuer %q: %v", issuer, err)
+		}
+	}
+}
+
+func TestVerifierJWKSFile(t *testing.T) {
+	cluster := newTestCluster(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, cluster.jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	verifier, err := NewVerifier(ctx, Config{Audience: "my-service", Issuer: testIssuer, JWKSFile: path})
+	if err != nil {
+		t.Fatalf("creating verifier: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		claims  string
+		wantErr string
+	}{
+		{name: "valid", claims: fmt.Sprintf(podClaims, testIssuer)},
+		{
+			name:    "wrong audience",
+			claims:  strings.Replace(fmt.Sprintf(podClaims, testIssuer), `"my-service"`, `"other"`, 1),
+			wantErr: "expected audience",
+		},
+		{
+			name:    "subject mismatch",
+			claims:  strings.Replace(fmt.Sprintf(podClaims, testIssuer), "team-a:builder", "team-b:builder", 1),
+			wantErr: "doesn't match service account",
+		},
+		{
+			name:    "no kubernetes claims",
+			claims:  fmt.Sprintf(`{"iss":%q,"aud":"my-service","sub":"x","exp":4102444800}`, testIssuer),
+			wantErr: "no service account claims",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := verifier.Verify(ctx, cluster.sign(t, test.claims))
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("verifying token: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := NewVerifier(ctx, Config{Audience: "my-service", JWKSFile: path}); err == nil {
+		t.Errorf("expected issuer to be required with a jwks file")
+	}
+
+	// Keys that RemoteKeySet would drop aren't used either.
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: cluster.priv.Public(), KeyID: "k1", Use: "enc"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewVerifier(ctx, Config{Audience: "my-service", Issuer: testIssuer, JWKSFile: path}); err == nil {
+		t.Errorf("expected encryption keys to be dropped")
+	}
+}
+
+func TestBearerTransport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	var got string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer s.Close()
+	client := &http.Client{Transport: &bearerTransport{tokenPath: path, base: http.DefaultTransport}}
+
+	for _, token := range []string{"token1\n", "token2"} {
+		if err := os.WriteFile(path, []byte(token), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Get(s.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if want := "Bearer " + strings.TrimSpace(token); got != want {
+			t.Errorf("expected rotated token %q, got %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+const (
+	// DefaultMaxDiscoverySize is the default maximum size, in bytes, of a
+	// discovery document.
+	DefaultMaxDiscoverySize = 1 << 20
+	// DefaultMaxJWKSSize is the default maximum size, in bytes, of a JSON Web
+	// Key Set document.
+	DefaultMaxJWKSSize = 1 << 20
+	// DefaultMaxKeys is the default maximum number of keys accepted from a
+	// JSON Web Key Set document.
+	DefaultMaxKeys = 256
+
+	// Bounds on the size of RSA moduli accepted from a remote key set. Larger
+	// keys make signature verification arbitrarily expensive, smaller ones are
+	// trivially breakable. The upper bound matches the one used by crypto/tls.
+	minRSAKeyBits = 1024
+	maxRSAKeyBits = 8192
+)
+
+// ResponseLimits bounds the documents this package is willing to read from a
+// provider. Zero values use the package defaults.
+type ResponseLimits struct {
+	// MaxDiscoverySize is the maximum size, in bytes, of the discovery
+	// document. Defaults to DefaultMaxDiscoverySize.
+	MaxDiscoverySize int64
+	// MaxJWKSSize is the maximum size, in bytes, of the jwks_uri document.
+	// Defaults to DefaultMaxJWKSSize.
+	MaxJWKSSize int64
+	// MaxKeys is the maximum number of keys a JSON Web Key Set may contain.
+	// Defaults to DefaultMaxKeys.
+	MaxKeys int
+
+	// StrictContentType requires discovery and key set responses to declare a
+	// JSON media type, such as "application/json" or
+	// "application/jwk-set+json". By default any Content-Type is accepted as
+	// long as the body parses as JSON.
+	StrictContentType bool
+}
+
+// ResponseLimitsContext returns a new Context that carries limits applied when
+// reading discovery and key set responses.
+//
+//	ctx := oidc.ResponseLimitsContext(parentContext, oidc.ResponseLimits{
+//		MaxJWKSSize: 64 << 10,
+//		MaxKeys:     16,
+//	})
+//
+//	// Discovery and the provider's key set will enforce the limits.
+//	provider, err := oidc.NewProvider(ctx, "https://accounts.example.com")
+func ResponseLimitsContext(ctx context.Context, limits ResponseLimits) context.Context {
+	return context.WithValue(ctx, responseLimitsKey, limits)
+}
+
+func getResponseLimits(ctx context.Context) ResponseLimits {
+	limits, _ := ctx.Value(responseLimitsKey).(ResponseLimits)
+	return limits
+}
+
+func (l ResponseLimits) maxDiscoverySize() int64 {
+	if l.MaxDiscoverySize > 0 {
+		return l.MaxDiscoverySize
+	}
+	return DefaultMaxDiscoverySize
+}
+
+func (l ResponseLimits) maxJWKSSize() int64 {
+	if l.MaxJWKSSize > 0 {
+		return l.MaxJWKSSize
+	}
+	return DefaultMaxJWKSSize
+}
+
+func (l ResponseLimits) maxKeys() int {
+	if l.MaxKeys > 0 {
+		return l.MaxKeys
+	}
+	return DefaultMaxKeys
+}
+
+// checkContentType returns an error if strict content type checking is enabled
+// and the response doesn't declare a JSON media type.
+func (l ResponseLimits) checkContentType(r *http.Response) error {
+	if !l.StrictContentType {
+		return nil
+	}
+	ct := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+	return fmt.Errorf("expected Content-Type = application/json, got %q", ct)
+}
+
+// readBody reads at most max bytes from the response body, returning an error
+// if the body is larger.
+func readBody(r *http.Response, max int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > max {
+		return nil, fmt.Errorf("response body exceeds maximum size of %d bytes", max)
+	}
+	return body, nil
+}
+
+// checkKey rejects public keys with parameters that are either insecure or
+// unreasonably expensive to verify against.
+func checkKey(key *jose.JSONWebKey) error {
+	switch pub := key.Key.(type) {
+	case *rsa.PublicKey:
+		bits := pub.N.BitLen()
+		if bits < minRSAKeyBits || bits > maxRSAKeyBits {
+			return fmt.Errorf("rsa key size %d outside of allowed range [%d, %d]", bits, minRSAKeyBits, maxRSAKeyBits)
+		}
+		if pub.E < 3 || pub.E%2 == 0 {
+			return fmt.Errorf("invalid rsa public exponent %d", pub.E)
+		}
+	case *ecdsa.PublicKey:
+		if pub.Curve == nil || !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return fmt.Errorf("ecdsa point not on curve")
+		}
+	case ed25519.PublicKey:
+		if len(pub) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid ed25519 key size %d", len(pub))
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key.Key)
+	}
+	return nil
+}
+
+// keyAttempts counts the keys tried to verify a signature, for
+// Config.MaxKeyAttempts. A nil *keyAttempts allows any number of attempts.
+type keyAttempts struct {
+	n, max int
+}
+
+func getKeyAttempts(ctx context.Context) *keyAttempts {
+	a, _ := ctx.Value(keyAttemptsKey).(*keyAttempts)
+	return a
+}
+
+// next records an attempt, or returns an error if the maximum has been
+// reached.
+func (a *keyAttempts) next() error {
+	if a == nil {
+		return nil
+	}
+	if a.n >= a.max {
+		return fmt.Errorf("oidc: no key verified the signature within the maximum of %d attempts", a.max)
+	}
+	a.n++
+	return nil
+}
@@ -0,0 +1,112 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func TestRemoteKeySetLimits(t *testing.T) {
+	key := newRSAKey(t)
+	key.keyID = "key1"
+
+	tests := []struct {
+		name     string
+		keys     []jose.JSONWebKey
+		limits   ResponseLimits
+		headers  func(h http.Header)
+		wantKeys int
+		wantErr  bool
+	}{
+		{
+			name:     "defaults",
+			keys:     []jose.JSONWebKey{key.jwk()},
+			wantKeys: 1,
+		},
+		{
+			name:    "response too large",
+			keys:    []jose.JSONWebKey{key.jwk()},
+			limits:  ResponseLimits{MaxJWKSSize: 64},
+			wantErr: true,
+		},
+		{
+			name:    "too many keys",
+			keys:    []jose.JSONWebKey{key.jwk(), key.jwk()},
+			limits:  ResponseLimits{MaxKeys: 1},
+			wantErr: true,
+		},
+		{
+			name:    "strict content type",
+			keys:    []jose.JSONWebKey{key.jwk()},
+			limits:  ResponseLimits{StrictContentType: true},
+			wantErr: true,
+		},
+		{
+			name:   "strict content type jwk-set",
+			keys:   []jose.JSONWebKey{key.jwk()},
+			limits: ResponseLimits{StrictContentType: true},
+			headers: func(h http.Header) {
+				h.Set("Content-Type", "application/jwk-set+json")
+			},
+			wantKeys: 1,
+		},
+		{
+			name:     "weak key dropped",
+			keys:     []jose.JSONWebKey{key.jwk(), newWeakRSAKey(t).jwk()},
+			wantKeys: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := httptest.NewServer(&keyServer{
+				keys:       jose.JSONWebKeySet{Keys: test.keys},
+				setHeaders: test.headers,
+			})
+			defer s.Close()
+
+			ctx := ResponseLimitsContext(context.Background(), test.limits)
+			rks := NewRemoteKeySet(ctx, s.URL)
+			keys, err := rks.keysFromRemote(ctx)
+			if err != nil {
+				if !test.wantErr {
+					t.Fatalf("fetching keys: %v", err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Fatalf("expected error fetching keys")
+			}
+			if len(keys) != test.wantKeys {
+				t.Errorf("expected %d keys, got %d", test.wantKeys, len(keys))
+			}
+		})
+	}
+}
+
+func TestDiscoveryLimits(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"issuer":"`+strings.Repeat("a", 1024)+`"}`)
+	}))
+	defer s.Close()
+
+	ctx := ResponseLimitsContext(context.Background(), ResponseLimits{MaxDiscoverySize: 512})
+	if _, err := NewProvider(ctx, s.URL); err == nil || !strings.Contains(err.Error(), "maximum size") {
+		t.Errorf("expected maximum size error, got %v", err)
+	}
+}
+
+func newWeakRSAKey(t *testing.T) *signingKey {
+	priv, err := rsa.GenerateKey(rand.Reader, 512)
+	if err != nil {
+		t.Skipf("generating 512 bit rsa key: %v", err)
+	}
+	return &signingKey{"weak", priv, priv.Public(), jose.RS256}
+}
@@ -0,0 +1,213 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// LoopbackRedirect receives the authorization response of a native app, such
+// as a desktop application or command line tool, on an HTTP listener bound to
+// the loopback interface, as described by RFC 8252 section 7.3. The listener
+// uses an ephemeral port, which providers accept for loopback redirect URIs
+// registered without a port.
+//
+// Most apps use LoopbackLogin, which drives the whole flow.
+//
+//	redirect, err := oidc.NewLoopbackRedirect("/callback")
+//	if err != nil {
+//		// handle error
+//	}
+//	defer redirect.Close()
+//	config.RedirectURL = redirect.RedirectURL
+//	openBrowser(config.AuthCodeURL(redirect.State))
+//	resp, err := redirect.Wait(ctx)
+type LoopbackRedirect struct {
+	// RedirectURL is the redirect URI of the listener, such as
+	// "http://127.0.0.1:51004/callback".
+	RedirectURL string
+	// State is the state value to send with the authorization request.
+	// Responses with another state are rejected.
+	State string
+
+	path     string
+	server   *http.Server
+	listener net.Listener
+
+	once      sync.Once
+	responses chan loopbackResponse
+}
+
+type loopbackResponse struct {
+	resp *AuthorizationResponse
+	err  error
+}
+
+// NewLoopbackRedirect starts listening on an ephemeral port of 127.0.0.1 for
+// the authorization response delivered to path, "/callback" if empty. The
+// listener must be stopped with Close.
+func NewLoopbackRedirect(path string) (*LoopbackRedirect, error) {
+	if path == "" {
+		path = "/callback"
+	}
+	if path[0] != '/' {
+		return nil, fmt.Errorf("oidc: loopback redirect path %q must start with a slash", path)
+	}
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, fmt.Errorf("oidc: generating state: %v", err)
+	}
+	// RFC 8252 section 8.3: listen on the loopback IP literal rather than
+	// "localhost", which may resolve to another interface.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("oidc: starting loopback listener: %v", err)
+	}
+	l := &LoopbackRedirect{
+		RedirectURL: "http://" + listener.Addr().String() + path,
+		State:       base64.RawURLEncoding.EncodeToString(b),
+		path:        path,
+		listener:    listener,
+		responses:   make(chan loopbackResponse, 1),
+	}
+	l.server = &http.Server{
+		Handler:           http.HandlerFunc(l.serveHTTP),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go l.server.Serve(listener)
+	return l, nil
+}
+
+func (l *LoopbackRedirect) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != l.path {
+		http.NotFound(w, r)
+		return
+	}
+	resp, err := ParseAuthorizationResponse(r)
+	state := ""
+	var authErr *AuthorizationError
+	switch {
+	case err == nil:
+		state = resp.State
+	case errors.As(err, &authErr):
+		state = authErr.State
+	default:
+		http.Error(w, "Invalid authorization response.", http.StatusBadRequest)
+		return
+	}
+	// Other local processes and web pages can send requests to the
+	// listener, so only accept the response to our request.
+	if state != l.State {
+		http.Error(w, "Invalid authorization response state.", http.StatusBadRequest)
+		return
+	}
+	delivered := false
+	l.once.Do(func() {
+		l.responses <- loopbackResponse{resp: resp, err: err}
+		delivered = true
+	})
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	switch {
+	case !delivered:
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, "The login has already completed. You can close this window.\n")
+	case err != nil:
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "Login failed. You can close this window.\n")
+	default:
+		io.WriteString(w, "Login complete. You can close this window.\n")
+	}
+}
+
+// Wait waits for the authorization response, and returns it. Error responses
+// are returned as an *AuthorizationError. It returns the context's error if
+// the context is done first.
+func (l *LoopbackRedirect) Wait(ctx context.Context) (*AuthorizationResponse, error) {
+	select {
+	case r := <-l.responses:
+		return r.resp, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops the listener.
+func (l *LoopbackRedirect) Close() error {
+	return l.server.Close()
+}
+
+// LoopbackCode is the authorization code received by LoopbackLogin, with the
+// values needed to exchange it.
+type LoopbackCode struct {
+	// Code is the authorization code.
+	Code string
+	// CodeVerifier is the PKCE code verifier of the request.
+	CodeVerifier string
+	// RedirectURL is the redirect URI of the request, which must be sent
+	// again when the code is exchanged.
+	RedirectURL string
+	// Response is the authorization response the code was returned in.
+	Response *AuthorizationResponse
+}
+
+// Exchange exchanges the code for tokens, with the redirect URI and code
+// verifier of the request.
+func (c *LoopbackCode) Exchange(ctx context.Context, config *oauth2.Config, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	cp := *config
+	cp.RedirectURL = c.RedirectURL
+	return cp.Exchange(ctx, c.Code, append(opts[:len(opts):len(opts)], oauth2.VerifierOption(c.CodeVerifier))...)
+}
+
+// LoopbackLogin runs the authorization code flow of a native app with a
+// LoopbackRedirect: it builds the authorization URL with the listener's
+// redirect URI, a state and a PKCE code challenge, as RFC 8252 section 8.1
+// requires, passes it to open, which usually opens it in the user's browser,
+// and waits for the authorization code. The config's RedirectURL is ignored.
+//
+//	code, err := oidc.LoopbackLogin(ctx, &config, func(authURL string) error {
+//		fmt.Fprintf(os.Stderr, "Log in at %s\n", authURL)
+//		return browser.OpenURL(authURL)
+//	}, oidc.Nonce(nonce))
+//	if err != nil {
+//		// handle error
+//	}
+//	oauth2Token, err := code.Exchange(ctx, &config)
+//
+// Set a deadline on the context, since users may never complete the login.
+func LoopbackLogin(ctx context.Context, config *oauth2.Config, open func(authURL string) error, opts ...oauth2.AuthCodeOption) (*LoopbackCode, error) {
+	redirect, err := NewLoopbackRedirect("")
+	if err != nil {
+		return nil, err
+	}
+	defer redirect.Close()
+
+	verifier := oauth2.GenerateVerifier()
+	cp := *config
+	cp.RedirectURL = redirect.RedirectURL
+	authURL := cp.AuthCodeURL(redirect.State, append(opts[:len(opts):len(opts)], oauth2.S256ChallengeOption(verifier))...)
+	if err := open(authURL); err != nil {
+		return nil, fmt.Errorf("oidc: opening authorization url: %v", err)
+	}
+	resp, err := redirect.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Code == "" {
+		return nil, errors.New("oidc: authorization response contains no code")
+	}
+	return &LoopbackCode{
+		Code:         resp.Code,
+		CodeVerifier: verifier,
+		RedirectURL:  redirect.RedirectURL,
+		Response:     resp,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestLoopbackLogin(t *testing.T) {
+	var challenge string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if r.PostFormValue("code") != "abc" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		if !strings.HasPrefix(r.PostFormValue("redirect_uri"), "http://127.0.0.1:") {
+			t.Errorf("unexpected redirect_uri %q", r.PostFormValue("redirect_uri"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"at","token_type":"Bearer"}`))
+	}))
+	defer s.Close()
+	config := &oauth2.Config{
+		ClientID: "cli",
+		Endpoint: oauth2.Endpoint{AuthURL: s.URL + "/authorize", TokenURL: s.URL + "/token"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	code, err := LoopbackLogin(ctx, config, func(authURL string) error {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		q := u.Query()
+		if q.Get("code_challenge_method") != "S256" || q.Get("nonce") != "n" {
+			t.Errorf("unexpected authorization request %s", authURL)
+		}
+		challenge = q.Get("code_challenge")
+		redirectURI := q.Get("redirect_uri")
+
+		// A response with another state, as a malicious page could send,
+		// is rejected without completing the login.
+		resp, err := http.Get(redirectURI + "?code=evil&state=other")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400 for other state, got %d", resp.StatusCode)
+		}
+
+		// The browser follows the redirect of the provider in the
+		// background, as the user logs in.
+		go func() {
+			resp, err := http.Get(redirectURI + "?code=abc&state=" + url.QueryEscape(q.Get("state")))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("expected status 200, got %d", resp.StatusCode)
+			}
+		}()
+		return nil
+	}, Nonce("n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.Code != "abc" {
+		t.Errorf("expected code abc, got %q", code.Code)
+	}
+	token, err := code.Exchange(ctx, config)
+	if err != nil {
+		t.Fatalf("exchanging code: %v", err)
+	}
+	if token.AccessToken != "at" {
+		t.Errorf("unexpected token %+v", token)
+	}
+}
+
+func TestLoopbackRedirect(t *testing.T) {
+	redirect, err := NewLoopbackRedirect("/cb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redirect.Close()
+	if !strings.HasPrefix(redirect.RedirectURL, "http://127.0.0.1:") || !strings.HasSuffix(redirect.RedirectURL, "/cb") {
+		t.Errorf("unexpected redirect url %q", redirect.RedirectURL)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := redirect.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	resp, err := http.Get(redirect.RedirectURL + "?error=access_denied&state=" + url.QueryEscape(redirect.State))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	var authErr *AuthorizationError
+	if _, err := redirect.Wait(context.Background()); !errors.As(err, &authErr) || authErr.Code != "access_denied" {
+		t.Errorf("expected access_denied error, got %v", err)
+	}
+
+	resp, err = http.Get(redirect.RedirectURL + "?code=abc&state=" + url.QueryEscape(redirect.State))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 for second response, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,173 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// MetadataChange describes a top-level field of the discovery document that
+// differs from the document a provider was created with.
+type MetadataChange struct {
+	// Field is the name of the field, such as "token_endpoint".
+	Field string
+	// Old and New are the values of the field, or nil if it was absent.
+	Old, New json.RawMessage
+	// Added and Removed list the values added to and removed from fields
+	// holding arrays of strings, such as "id_token_signing_alg_values_supported".
+	Added, Removed []string
+}
+
+// MetadataChangeEvent describes a discovery document that differs from the
+// one its provider was created with.
+type MetadataChangeEvent struct {
+	Issuer string
+	// OldHash and NewHash are the values of Provider.MetadataHash for the
+	// previous and current document.
+	OldHash, NewHash string
+	Changes          []MetadataChange
+}
+
+// MetadataHash returns the hex encoded SHA-256 hash of the discovery document
+// the provider was created with, or an empty string for providers created
+// from a ProviderConfig. The document is canonicalized first, so the order of
+// its fields and its formatting don't affect the hash.
+//
+// Comparing hashes across instances or over time detects unexpected changes
+// of the provider's configuration. See Provider.CheckMetadata.
+func (p *Provider) MetadataHash() string {
+	if p.rawClaims == nil {
+		return ""
+	}
+	h, err := metadataHash(p.rawClaims)
+	if err != nil {
+		return ""
+	}
+	return h
+}
+
+// CheckMetadata fetches the discovery document again and returns the fields
+// that differ from the document the provider was created with. If any do, the
+// change is also reported to the MetadataChange hook of the provider's
+// Observer. Providers don't adopt the new document; create a new provider to
+// use it.
+//
+//	changes, err := provider.CheckMetadata(ctx)
+//	if err != nil {
+//		// handle error
+//	}
+//	for _, c := range changes {
+//		log.Printf("provider changed %s: removed %q, added %q", c.Field, c.Removed, c.Added)
+//	}
+func (p *Provider) CheckMetadata(ctx context.Context) ([]MetadataChange, error) {
+	if p.discoveryURL == "" {
+		return nil, errors.New("oidc: provider wasn't created by discovery")
+	}
+	ctx = p.clientContext(ctx)
+	ctx = ResponseLimitsContext(ctx, p.limits)
+	_, body, err := fetchDiscovery(ctx, p.discoveryURL)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	return p.compareMetadata(body)
+}
+
+// compareMetadata diffs the discovery document with the provider's, and
+// reports changes to the observer.
+func (p *Provider) compareMetadata(body []byte) ([]MetadataChange, error) {
+	var prev, cur map[string]json.RawMessage
+	if err := json.Unmarshal(p.rawClaims, &prev); err != nil {
+		return nil, fmt.Errorf("oidc: decoding previous discovery document: %v", err)
+	}
+	if err := json.Unmarshal(body, &cur); err != nil {
+		return nil, fmt.Errorf("oidc: decoding discovery document: %v", err)
+	}
+
+	fields := make([]string, 0, len(prev)+len(cur))
+	for k := range prev {
+		fields = append(fields, k)
+	}
+	for k := range cur {
+		if _, ok := prev[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	var changes []MetadataChange
+	for _, field := range fields {
+		before, after := prev[field], cur[field]
+		if canonicalEqual(before, after) {
+			continue
+		}
+		c := MetadataChange{Field: field, Old: before, New: after}
+		var oldValues, newValues []string
+		if json.Unmarshal(before, &oldValues) == nil && json.Unmarshal(after, &newValues) == nil {
+			c.Added = difference(newValues, oldValues)
+			c.Removed = difference(oldValues, newValues)
+		}
+		changes = append(changes, c)
+	}
+
+	if len(changes) > 0 && p.observer != nil && p.observer.MetadataChange != nil {
+		newHash, _ := metadataHash(body)
+		p.observer.MetadataChange(MetadataChangeEvent{
+			Issuer:  p.issuer,
+			OldHash: p.MetadataHash(),
+			NewHash: newHash,
+			Changes: changes,
+		})
+	}
+	return changes, nil
+}
+
+// canonicalJSON re-encodes the JSON value with sorted object keys and without
+// insignificant whitespace.
+func canonicalJSON(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func metadataHash(b []byte) (string, error) {
+	c, err := canonicalJSON(b)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(c)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalEqual reports whether two JSON values, either of which may be nil
+// if absent, are equal once canonicalized.
+func canonicalEqual(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ca, errA := canonicalJSON(a)
+	cb, errB := canonicalJSON(b)
+	if errA != nil || errB != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca, cb)
+}
+
+// difference returns the values of a that aren't in b.
+func difference(a, b []string) []string {
+	var d []string
+	for _, v := range a {
+		if !contains(b, v) {
+			d = append(d, v)
+		}
+	}
+	return d
+}
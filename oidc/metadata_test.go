@@ -0,0 +1,85 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCheckMetadata(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		doc string
+		s   *httptest.Server
+	)
+	setDoc := func(format string) {
+		mu.Lock()
+		defer mu.Unlock()
+		doc = fmt.Sprintf(format, s.URL, s.URL)
+	}
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprint(w, doc)
+	}))
+	defer s.Close()
+
+	var events []MetadataChangeEvent
+	ctx := ObserverContext(context.Background(), &Observer{
+		MetadataChange: func(e MetadataChangeEvent) { events = append(events, e) },
+	})
+	setDoc(`{"issuer":%q,"jwks_uri":"%s/keys","id_token_signing_alg_values_supported":["RS256","PS256"]}`)
+	p, err := NewProvider(ctx, s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := p.MetadataHash()
+	if len(hash) != 64 {
+		t.Fatalf("unexpected hash %q", hash)
+	}
+
+	// Reordering and reformatting the document doesn't change it.
+	setDoc(`{ "jwks_uri" : "%[2]s/keys", "id_token_signing_alg_values_supported" : ["RS256", "PS256"], "issuer" : %[1]q }`)
+	if changes, err := p.CheckMetadata(ctx); err != nil || len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v, %v", changes, err)
+	}
+	if other, err := NewProvider(ctx, s.URL); err != nil || other.MetadataHash() != hash {
+		t.Errorf("expected equivalent documents to have the same hash, got %q and %q (%v)", hash, other.MetadataHash(), err)
+	}
+	if len(events) != 0 {
+		t.Errorf("expected no change events, got %+v", events)
+	}
+
+	setDoc(`{"issuer":%q,"jwks_uri":"%s/keys","id_token_signing_alg_values_supported":["ES256","RS256"],"userinfo_endpoint":"https://example.com/userinfo"}`)
+	changes, err := p.CheckMetadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MetadataChange{
+		{
+			Field:   "id_token_signing_alg_values_supported",
+			Old:     []byte(`["RS256","PS256"]`),
+			New:     []byte(`["ES256","RS256"]`),
+			Added:   []string{"ES256"},
+			Removed: []string{"PS256"},
+		},
+		{
+			Field: "userinfo_endpoint",
+			New:   []byte(`"https://example.com/userinfo"`),
+		},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("unexpected changes:\ngot  %+v\nwant %+v", changes, want)
+	}
+	if len(events) != 1 || events[0].Issuer != s.URL || events[0].OldHash != hash || events[0].NewHash == hash || len(events[0].Changes) != 2 {
+		t.Errorf("unexpected change events %+v", events)
+	}
+
+	if _, err := (&ProviderConfig{IssuerURL: s.URL}).NewProvider(ctx).CheckMetadata(ctx); err == nil {
+		t.Errorf("expected CheckMetadata to fail for providers created without discovery")
+	}
+}
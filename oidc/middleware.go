@@ -0,0 +1,218 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MiddlewareOption configures BearerMiddleware.
+type MiddlewareOption func(*middleware)
+
+type middleware struct {
+	verifier TokenVerifier
+	realm    string
+	scopes   []string
+
+	channelBinding        bool
+	requireChannelBinding bool
+
+	forwardIntrospectionJWT bool
+}
+
+// RequireScopes rejects tokens that weren't granted all of the scopes with
+// 403 Forbidden and an "insufficient_scope" challenge, as described by RFC 6750
+// section 3.1. Scopes are read with IDToken.Scopes.
+func RequireScopes(scopes ...string) MiddlewareOption {
+	return func(m *middleware) {
+		m.scopes = append(m.scopes, scopes...)
+	}
+}
+
+// WithRealm sets the realm of the challenges sent with error responses.
+func WithRealm(realm string) MiddlewareOption {
+	return func(m *middleware) {
+		m.realm = realm
+	}
+}
+
+// VerifyChannelBinding checks that tokens bound to a client certificate (RFC
+// 8705) or a Token Binding ID (RFC 8473) are presented over a connection
+// proving possession of that certificate or key, rejecting them otherwise with
+// 401 Unauthorized and an "invalid_token" challenge. If required is true,
+// tokens that aren't bound are rejected too. See VerifyCertificateBinding and
+// VerifyTokenBinding.
+//
+// The TLS connection must be terminated by the server running the middleware,
+// not by a proxy in front of it.
+func VerifyChannelBinding(required bool) MiddlewareOption {
+	return func(m *middleware) {
+		m.channelBinding = true
+		m.requireChannelBinding = required
+	}
+}
+
+// ForwardIntrospectionJWT replaces the token of authenticated requests with
+// the signed introspection response it was verified with, before passing them
+// on, so that services behind a gateway receive a JWT instead of the client's
+// opaque token. The verifier must be an IntrospectionVerifier requiring signed
+// responses; requests authenticated with unsigned introspection responses are
+// rejected with 500 Internal Server Error, and JWTs verified by other
+// verifiers are passed on as is. See IntrospectionJWTVerifier.
+func ForwardIntrospectionJWT() MiddlewareOption {
+	return func(m *middleware) {
+		m.forwardIntrospectionJWT = true
+	}
+}
+
+// BearerMiddleware returns middleware that authenticates requests with bearer
+// tokens, such as JWT access tokens in the RFC 9068 format verified by an
+// IDTokenVerifier, or opaque tokens verified by an IntrospectionVerifier.
+// Requests without a valid token are rejected with 401 Unauthorized and a
+// WWW-Authenticate challenge. Requests whose token can't be verified because
+// the provider is unavailable are rejected with 503 Service Unavailable, or
+// passed on if the DegradedModePolicy allows it. The verified token is
+// available to the handler through BearerTokenFromContext.
+//
+//	verifier := provider.Verifier(&oidc.Config{ClientID: "https://api.example.com"})
+//	mux.Handle("/users", oidc.BearerMiddleware(verifier, oidc.RequireScopes("read:users"))(usersHandler))
+//
+// Routers built on net/http, such as chi, accept the middleware as is:
+//
+//	r.With(oidc.BearerMiddleware(verifier)).Get("/users", usersHandler)
+//
+// Adapters for Gin, Echo and Fiber are provided by the oidcgin, oidcecho and
+// oidcfiber modules.
+func BearerMiddleware(verifier TokenVerifier, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	m := &middleware{verifier: verifier}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.serveHTTP(w, r, next)
+		})
+	}
+}
+
+func (m *middleware) serveHTTP(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	raw, ok := bearerToken(r)
+	if !ok {
+		// RFC 6750 section 3.1: requests lacking authentication information
+		// shouldn't receive an error code.
+		m.challenge(w, http.StatusUnauthorized, "", "")
+		return
+	}
+	token, err := m.verifier.Verify(r.Context(), raw)
+	if err != nil {
+		var unavailable *ProviderUnavailableError
+		if errors.As(err, &unavailable) {
+			if unavailable.failOpen(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// The token may well be valid, so don't tell the client to
+			// discard it.
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		m.challenge(w, http.StatusUnauthorized, "invalid_token", "token is invalid")
+		return
+	}
+	if m.channelBinding {
+		if err := m.checkChannelBinding(r, token); err != nil {
+			m.challenge(w, http.StatusUnauthorized, "invalid_token", "token isn't bound to this connection")
+			return
+		}
+	}
+	if len(m.scopes) > 0 {
+		granted, err := token.Scopes()
+		if err != nil {
+			m.challenge(w, http.StatusUnauthorized, "invalid_token", "token has malformed scopes")
+			return
+		}
+		if !granted.ContainsAll(m.scopes...) {
+			m.challenge(w, http.StatusForbidden, "insufficient_scope", "token lacks required scopes")
+			return
+		}
+	}
+	r = r.WithContext(context.WithValue(r.Context(), bearerTokenKey, token))
+	if m.forwardIntrospectionJWT {
+		jwt := token.Raw()
+		if jwt == "" {
+			// Never forward the opaque token in its place.
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		r.Header = r.Header.Clone()
+		r.Header.Set("Authorization", "Bearer "+jwt)
+	}
+	next.ServeHTTP(w, r)
+}
+
+// checkChannelBinding verifies the bindings of the token's "cnf" claim that
+// are checked against the TLS connection.
+func (m *middleware) checkChannelBinding(r *http.Request, token *IDToken) error {
+	cnf, err := token.Confirmation()
+	if err != nil {
+		return err
+	}
+	if cnf == nil || (cnf.X509ThumbprintS256 == "" && cnf.TokenBindingHash == "") {
+		if m.requireChannelBinding {
+			return errors.New("oidc: token isn't bound to the connection")
+		}
+		return nil
+	}
+	if cnf.X509ThumbprintS256 != "" {
+		if err := VerifyCertificateBinding(token, r.TLS); err != nil {
+			return err
+		}
+	}
+	if cnf.TokenBindingHash != "" {
+		if err := VerifyTokenBinding(token, r.TLS, r.Header.Get("Sec-Token-Binding")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// challenge writes an error response with a Bearer challenge. Details of
+// verification errors aren't sent to clients.
+func (m *middleware) challenge(w http.ResponseWriter, status int, code, description string) {
+	var params []string
+	if m.realm != "" {
+		params = append(params, "realm="+strconv.Quote(m.realm))
+	}
+	if code != "" {
+		params = append(params, "error="+strconv.Quote(code))
+		params = append(params, "error_description="+strconv.Quote(description))
+	}
+	if code == "insufficient_scope" {
+		params = append(params, "scope="+strconv.Quote(strings.Join(m.scopes, " ")))
+	}
+	challenge := "Bearer"
+	if len(params) > 0 {
+		challenge += " " + strings.Join(params, ", ")
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, http.StatusText(status), status)
+}
+
+// bearerToken returns the token of the Authorization header. The scheme is
+// case insensitive.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// BearerTokenFromContext returns the token verified by BearerMiddleware.
+func BearerTokenFromContext(ctx context.Context) (*IDToken, bool) {
+	token, ok := ctx.Value(bearerTokenKey).(*IDToken)
+	return token, ok
+}
@@ -0,0 +1,72 @@
+package oidc
+
+import (
+	"crypto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBearerMiddleware(t *testing.T) {
+	key := newRSAKey(t)
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "api"})
+	handler := BearerMiddleware(verifier, RequireScopes("read:users"), WithRealm("api"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := BearerTokenFromContext(r.Context())
+		if !ok {
+			t.Errorf("expected token in context")
+			return
+		}
+		w.Write([]byte(token.Subject))
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantChallenge string
+		wantBody      string
+	}{
+		{
+			name:          "no token",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="api"`,
+		},
+		{
+			name:          "invalid token",
+			authorization: "Bearer not-a-jwt",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="api", error="invalid_token", error_description="token is invalid"`,
+		},
+		{
+			name:          "insufficient scope",
+			authorization: "Bearer " + key.sign(t, []byte(`{"iss":"https://foo","aud":"api","sub":"jane","exp":4102444800,"scope":"read:groups"}`)),
+			wantStatus:    http.StatusForbidden,
+			wantChallenge: `Bearer realm="api", error="insufficient_scope", error_description="token lacks required scopes", scope="read:users"`,
+		},
+		{
+			name:          "valid",
+			authorization: "bearer " + key.sign(t, []byte(`{"iss":"https://foo","aud":"api","sub":"jane","exp":4102444800,"scp":["read:users"]}`)),
+			wantStatus:    http.StatusOK,
+			wantBody:      "jane",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != test.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, test.wantStatus)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != test.wantChallenge {
+				t.Errorf("got challenge %q, want %q", got, test.wantChallenge)
+			}
+			if test.wantBody != "" && w.Body.String() != test.wantBody {
+				t.Errorf("got body %q, want %q", w.Body.String(), test.wantBody)
+			}
+		})
+	}
+}
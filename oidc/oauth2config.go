@@ -0,0 +1,45 @@
+package oidc
+
+import (
+	"golang.org/x/oauth2"
+)
+
+// OAuth2Config returns an OAuth 2.0 configuration for a client of the
+// provider, with the provider's endpoints. The "openid" scope is added to the
+// scopes if it's missing, since the provider doesn't return ID Tokens without
+// it.
+//
+// The endpoint's AuthStyle is chosen from the client authentication methods
+// the provider advertises: client_secret_basic if it supports it, then
+// client_secret_post. If the provider advertises neither, or wasn't created
+// by discovery, the oauth2 package tries both.
+//
+//	config := provider.OAuth2Config(clientID, clientSecret, "https://app.example.com/callback",
+//		[]string{"profile", "email"})
+//	http.Redirect(w, r, config.AuthCodeURL(state), http.StatusFound)
+func (p *Provider) OAuth2Config(clientID, clientSecret, redirectURL string, scopes []string) oauth2.Config {
+	endpoint := p.Endpoint()
+	endpoint.AuthStyle = p.authStyle()
+	if !contains(scopes, ScopeOpenID) {
+		scopes = append([]string{ScopeOpenID}, scopes...)
+	}
+	return oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint:     endpoint,
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+	}
+}
+
+// authStyle returns the oauth2.AuthStyle matching the client authentication
+// methods supported by the token endpoint.
+func (p *Provider) authStyle() oauth2.AuthStyle {
+	switch p.secretAuthMethod() {
+	case AuthMethodClientSecretBasic:
+		return oauth2.AuthStyleInHeader
+	case AuthMethodClientSecretPost:
+		return oauth2.AuthStyleInParams
+	}
+	return oauth2.AuthStyleAutoDetect
+}
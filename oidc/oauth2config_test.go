@@ -0,0 +1,52 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestOAuth2Config(t *testing.T) {
+	tests := []struct {
+		methods string
+		want    oauth2.AuthStyle
+	}{
+		{`"token_endpoint_auth_methods_supported":["client_secret_post","client_secret_basic"],`, oauth2.AuthStyleInHeader},
+		{`"token_endpoint_auth_methods_supported":["private_key_jwt","client_secret_post"],`, oauth2.AuthStyleInParams},
+		{`"token_endpoint_auth_methods_supported":["private_key_jwt"],`, oauth2.AuthStyleAutoDetect},
+		{``, oauth2.AuthStyleAutoDetect},
+	}
+	for _, test := range tests {
+		var s *httptest.Server
+		s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"issuer":%q,%s"authorization_endpoint":"%s/auth","token_endpoint":"%s/token","jwks_uri":"%s/keys"}`, s.URL, test.methods, s.URL, s.URL, s.URL)
+		}))
+		p, err := NewProvider(context.Background(), s.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := p.OAuth2Config("client", "secret", "https://app.example.com/callback", []string{"profile"})
+		want := oauth2.Config{
+			ClientID:     "client",
+			ClientSecret: "secret",
+			RedirectURL:  "https://app.example.com/callback",
+			Scopes:       []string{ScopeOpenID, "profile"},
+			Endpoint:     oauth2.Endpoint{AuthURL: s.URL + "/auth", TokenURL: s.URL + "/token", AuthStyle: test.want},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", test.methods, got, want)
+		}
+		s.Close()
+	}
+
+	p := (&ProviderConfig{AuthURL: "https://idp/auth", TokenURL: "https://idp/token"}).NewProvider(context.Background())
+	got := p.OAuth2Config("client", "", "", []string{"email", ScopeOpenID})
+	if got.Endpoint.AuthStyle != oauth2.AuthStyleAutoDetect || !reflect.DeepEqual(got.Scopes, []string{"email", ScopeOpenID}) {
+		t.Errorf("unexpected config %+v", got)
+	}
+}
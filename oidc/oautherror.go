@@ -0,0 +1,120 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// AsOAuthError returns the OAuth error response that caused err, if any. It
+// matches *OAuthError values returned by this package as well as
+// *oauth2.RetrieveError values returned when exchanging or refreshing tokens.
+func AsOAuthError(err error) (*OAuthError, bool) {
+	var oauthErr *OAuthError
+	if errors.As(err, &oauthErr) {
+		return oauthErr, true
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode != "" {
+		e := &OAuthError{
+			Code:        retrieveErr.ErrorCode,
+			Description: retrieveErr.ErrorDescription,
+			URI:         retrieveErr.ErrorURI,
+			Body:        retrieveErr.Body,
+		}
+		if retrieveErr.Response != nil {
+			e.StatusCode = retrieveErr.Response.StatusCode
+		}
+		return e, true
+	}
+	return nil, false
+}
+
+// parseOAuthError parses an error response from a provider endpoint. Errors are
+// read from a JSON body, or from the WWW-Authenticate header for endpoints
+// protected by bearer tokens, such as the userinfo endpoint. It returns nil if
+// the response doesn't contain an OAuth error.
+func parseOAuthError(resp *http.Response, body []byte) *OAuthError {
+	e := &OAuthError{StatusCode: resp.StatusCode, Body: body}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && mediaType == "application/json" {
+		var v struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+			ErrorURI         string `json:"error_uri"`
+		}
+		if json.Unmarshal(body, &v) == nil && v.Error != "" {
+			e.Code, e.Description, e.URI = v.Error, v.ErrorDescription, v.ErrorURI
+			return e
+		}
+	}
+	for _, h := range resp.Header.Values("WWW-Authenticate") {
+		scheme, params, ok := parseChallenge(h)
+		if !ok || !strings.EqualFold(scheme, "Bearer") || params["error"] == "" {
+			continue
+		}
+		e.Code, e.Description, e.URI = params["error"], params["error_description"], params["error_uri"]
+		return e
+	}
+	return nil
+}
+
+// parseChallenge parses a single WWW-Authenticate challenge with auth-params,
+// such as:
+//
+//	Bearer realm="example", error="invalid_token", error_description="expired"
+func parseChallenge(h string) (scheme string, params map[string]string, ok bool) {
+	h = strings.TrimSpace(h)
+	i := strings.IndexByte(h, ' ')
+	if i < 0 {
+		return h, nil, h != ""
+	}
+	scheme, h = h[:i], h[i+1:]
+	params = make(map[string]string)
+	for {
+		h = strings.TrimLeft(h, " ,")
+		if h == "" {
+			return scheme, params, true
+		}
+		eq := strings.IndexByte(h, '=')
+		if eq <= 0 {
+			return "", nil, false
+		}
+		key := strings.ToLower(strings.TrimSpace(h[:eq]))
+		h = strings.TrimLeft(h[eq+1:], " ")
+
+		var val strings.Builder
+		if strings.HasPrefix(h, `"`) {
+			h = h[1:]
+			closed := false
+			for len(h) > 0 {
+				c := h[0]
+				h = h[1:]
+				if c == '\\' && len(h) > 0 {
+					val.WriteByte(h[0])
+					h = h[1:]
+					continue
+				}
+				if c == '"' {
+					closed = true
+					break
+				}
+				val.WriteByte(c)
+			}
+			if !closed {
+				return "", nil, false
+			}
+		} else {
+			end := strings.IndexByte(h, ',')
+			if end < 0 {
+				end = len(h)
+			}
+			val.WriteString(strings.TrimSpace(h[:end]))
+			h = h[end:]
+		}
+		params[key] = val.String()
+	}
+}
@@ -0,0 +1,138 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestParseChallenge(t *testing.T) {
+	tests := []struct {
+		in         string
+		wantScheme string
+		wantParams map[string]string
+		wantOK     bool
+	}{
+		{
+			in:         `Bearer realm="example", error="invalid_token", error_description="The access token expired"`,
+			wantScheme: "Bearer",
+			wantParams: map[string]string{"realm": "example", "error": "invalid_token", "error_description": "The access token expired"},
+			wantOK:     true,
+		},
+		{
+			in:         `Bearer error=insufficient_scope,scope="openid profile"`,
+			wantScheme: "Bearer",
+			wantParams: map[string]string{"error": "insufficient_scope", "scope": "openid profile"},
+			wantOK:     true,
+		},
+		{
+			in:         `Bearer error="a \"quoted\" value"`,
+			wantScheme: "Bearer",
+			wantParams: map[string]string{"error": `a "quoted" value`},
+			wantOK:     true,
+		},
+		{
+			in:         "Basic",
+			wantScheme: "Basic",
+			wantOK:     true,
+		},
+		{in: `Bearer error="unterminated`},
+		{in: `Bearer =foo`},
+	}
+	for _, test := range tests {
+		scheme, params, ok := parseChallenge(test.in)
+		if ok != test.wantOK {
+			t.Errorf("parseChallenge(%q) ok=%v, want %v", test.in, ok, test.wantOK)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if scheme != test.wantScheme || !reflect.DeepEqual(params, test.wantParams) {
+			t.Errorf("parseChallenge(%q) = %q %v, want %q %v", test.in, scheme, params, test.wantScheme, test.wantParams)
+		}
+	}
+}
+
+func TestUserInfoOAuthError(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    *OAuthError
+	}{
+		{
+			name: "www-authenticate",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="expired"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			},
+			want: &OAuthError{StatusCode: http.StatusUnauthorized, Code: "invalid_token", Description: "expired"},
+		},
+		{
+			name: "json body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_request","error_uri":"https://op/docs"}`)
+			},
+			want: &OAuthError{StatusCode: http.StatusBadRequest, Code: "invalid_request", URI: "https://op/docs"},
+		},
+		{
+			name: "no error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, "oops")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := httptest.NewServer(test.handler)
+			defer s.Close()
+
+			p := &Provider{userInfoURL: s.URL}
+			_, err := p.UserInfo(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "a"}))
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			got, ok := AsOAuthError(err)
+			if test.want == nil {
+				if ok {
+					t.Fatalf("expected non-OAuth error, got %v", got)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("expected OAuth error, got %v", err)
+			}
+			got.Body = nil
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected error, got=%+v, want=%+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAsOAuthErrorRetrieveError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant","error_description":"code expired"}`)
+	}))
+	defer s.Close()
+
+	config := &oauth2.Config{ClientID: "client", Endpoint: oauth2.Endpoint{TokenURL: s.URL}}
+	_, err := config.Exchange(context.Background(), "code")
+	got, ok := AsOAuthError(err)
+	if !ok {
+		t.Fatalf("expected OAuth error, got %v", err)
+	}
+	if got.StatusCode != http.StatusBadRequest || got.Code != "invalid_grant" || got.Description != "code expired" {
+		t.Errorf("unexpected error %+v", got)
+	}
+}
@@ -0,0 +1,96 @@
+package oidc
+
+import (
+	"context"
+	"time"
+)
+
+// Observer holds hooks called by providers, key sets and verifiers, for
+// exporting metrics or traces. Any hook may be nil. Hooks are called
+// synchronously, possibly from several goroutines at once, so they should be
+// fast and safe for concurrent use.
+//
+// The oidcmetrics module provides an Observer that records metrics with the
+// Prometheus client library.
+type Observer struct {
+	// Verification is called when IDTokenVerifier.Verify returns.
+	Verification func(VerificationEvent)
+	// KeySetFetch is called when a request to a remote key set completes.
+	KeySetFetch func(KeySetFetchEvent)
+	// Discovery is called when a discovery request made by NewProvider
+	// completes.
+	Discovery func(DiscoveryEvent)
+	// MetadataChange is called when Provider.CheckMetadata or
+	// Provider.HealthCheck find that the provider's discovery document has
+	// changed since the provider was created.
+	MetadataChange func(MetadataChangeEvent)
+	// LegacyIssuer is called when IDTokenVerifier.Verify accepts a token of
+	// one of Config.LegacyIssuers.
+	LegacyIssuer func(LegacyIssuerEvent)
+}
+
+// VerificationEvent describes a call to IDTokenVerifier.Verify.
+type VerificationEvent struct {
+	// Issuer of the verifier. This is the expected issuer rather than the
+	// token's "iss" claim, which can't be trusted if verification fails.
+	Issuer string
+	// Algorithm from the token's header, if it could be parsed. It's
+	// controlled by whoever presented the token.
+	Algorithm string
+	// Cached reports whether the token was found in the VerificationCache.
+	Cached   bool
+	Duration time.Duration
+	// Err is the error returned by Verify, if any.
+	Err error
+}
+
+// KeySetFetchEvent describes a request made by a RemoteKeySet.
+type KeySetFetchEvent struct {
+	URL      string
+	Duration time.Duration
+	// Keys is the number of usable keys returned by the remote, which the key
+	// set caches if the request succeeded.
+	Keys int
+	Err  error
+}
+
+// LegacyIssuerEvent describes a token accepted because its issuer is listed
+// in Config.LegacyIssuers.
+type LegacyIssuerEvent struct {
+	// Issuer of the verifier.
+	Issuer string
+	// LegacyIssuer is the issuer of the token, accepted until Until.
+	LegacyIssuer string
+	Until        time.Time
+	// Subject of the token, to find the clients still using the legacy
+	// issuer.
+	Subject string
+}
+
+// DiscoveryEvent describes a discovery request made by NewProvider.
+type DiscoveryEvent struct {
+	Issuer   string
+	Duration time.Duration
+	Err      error
+}
+
+// ObserverContext returns a new Context that carries the observer. Providers
+// and key sets created with the returned context report events to it, and
+// verifiers created from such a provider default Config.Observer to it.
+//
+//	ctx := oidc.ObserverContext(parentContext, &oidc.Observer{
+//		Discovery: func(e oidc.DiscoveryEvent) {
+//			log.Printf("discovered %s in %s: %v", e.Issuer, e.Duration, e.Err)
+//		},
+//	})
+//	provider, err := oidc.NewProvider(ctx, "https://accounts.example.com")
+func ObserverContext(ctx context.Context, o *Observer) context.Context {
+	return context.WithValue(ctx, observerKey, o)
+}
+
+func getObserver(ctx context.Context) *Observer {
+	if o, ok := ctx.Value(observerKey).(*Observer); ok {
+		return o
+	}
+	return nil
+}
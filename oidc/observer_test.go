@@ -0,0 +1,44 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestObserver(t *testing.T) {
+	key := newRSAKey(t)
+	s, _ := newIssuerServer(t, key)
+
+	var (
+		discoveries   []DiscoveryEvent
+		fetches       []KeySetFetchEvent
+		verifications []VerificationEvent
+	)
+	ctx := ObserverContext(context.Background(), &Observer{
+		Discovery:    func(e DiscoveryEvent) { discoveries = append(discoveries, e) },
+		KeySetFetch:  func(e KeySetFetchEvent) { fetches = append(fetches, e) },
+		Verification: func(e VerificationEvent) { verifications = append(verifications, e) },
+	})
+	p, err := NewProvider(ctx, s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := p.VerifierContext(context.Background(), &Config{ClientID: "app", VerificationCache: NewVerificationCache(10, time.Hour)})
+	raw := key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"app","sub":"jane","exp":4102444800}`, s.URL)))
+	for i := 0; i < 2; i++ {
+		if _, err := verifier.Verify(context.Background(), raw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(discoveries) != 1 || discoveries[0].Issuer != s.URL || discoveries[0].Err != nil {
+		t.Errorf("unexpected discovery events %+v", discoveries)
+	}
+	if len(fetches) != 1 || fetches[0].URL != s.URL+"/keys" || fetches[0].Keys != 1 {
+		t.Errorf("unexpected key set fetch events %+v", fetches)
+	}
+	if len(verifications) != 2 || verifications[0].Algorithm != RS256 || verifications[0].Cached || !verifications[1].Cached {
+		t.Errorf("unexpected verification events %+v", verifications)
+	}
+}
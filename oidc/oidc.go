@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -13,6 +14,7 @@ import (
 	"io"
 	"mime"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -38,11 +40,29 @@ const (
 var (
 	errNoAtHash      = errors.New("id token did not have an access token hash")
 	errInvalidAtHash = errors.New("access token hash does not match value in ID token")
+	errNoCHash       = errors.New("id token did not have a code hash")
+	errInvalidCHash  = errors.New("code hash does not match value in ID token")
 )
 
 type contextKey int
 
-var issuerURLKey contextKey
+// Keys share the contextKey type, so each must have a distinct value.
+const (
+	issuerURLKey contextKey = iota
+	// parsedJWTKey allows common setups to avoid parsing the JWT twice. It
+	// holds a *compactJWS value.
+	parsedJWTKey
+	clockKey
+	responseLimitsKey
+	bearerTokenKey
+	observerKey
+	degradedModeKey
+	keyRetentionKey
+	// keyAttemptsKey holds the *keyAttempts of a verification.
+	keyAttemptsKey
+	keySetKey
+	signatureVerifierKey
+)
 
 // ClientContext returns a new Context that carries the provided HTTP client.
 //
@@ -84,6 +104,34 @@ func InsecureIssuerURLContext(ctx context.Context, issuerURL string) context.Con
 	return context.WithValue(ctx, issuerURLKey, issuerURL)
 }
 
+// ClockContext returns a new Context that carries a time source used in place of
+// time.Now. Providers and key sets created with the returned context use the
+// clock for all time based decisions, and verifiers created from such a provider
+// default Config.Now to it.
+//
+//	now := time.Date(2022, 1, 29, 0, 0, 0, 0, time.UTC)
+//	ctx := oidc.ClockContext(parentContext, func() time.Time { return now })
+//
+//	provider, err := oidc.NewProvider(ctx, "https://accounts.example.com")
+func ClockContext(ctx context.Context, now func() time.Time) context.Context {
+	return context.WithValue(ctx, clockKey, now)
+}
+
+func getClock(ctx context.Context) func() time.Time {
+	if now, ok := ctx.Value(clockKey).(func() time.Time); ok {
+		return now
+	}
+	return nil
+}
+
+// clockNow returns the current time of the context's clock.
+func clockNow(ctx context.Context) time.Time {
+	if now := getClock(ctx); now != nil {
+		return now()
+	}
+	return time.Now()
+}
+
 func doRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
 	client := http.DefaultClient
 	if c := getClient(ctx); c != nil {
@@ -102,14 +150,47 @@ type Provider struct {
 	jwksURL       string
 	algorithms    []string
 
+	// Endpoint for OpenID Connect Session Management, if supported.
+	checkSessionIframe string
+	// Token introspection endpoint, if supported.
+	introspectionURL string
+	// Algorithms signed introspection responses may be signed with.
+	introspectionAlgs []string
+
 	// Raw claims returned by the server.
 	rawClaims []byte
+	// URL of the discovery document, if the provider was discovered.
+	discoveryURL string
+
+	// Suspends userinfo requests after the provider asked to back off.
+	userInfoLimiter rateLimiter
+	// Caches userinfo responses, if set by WithUserInfoCache.
+	userInfoCache *UserInfoCache
 
 	// Guards all of the following fields.
 	mu sync.Mutex
 	// HTTP client specified from the initial NewProvider request. This is used
 	// when creating the common key set.
 	client *http.Client
+	// Whether client was built by provider options, which take precedence
+	// over the client carried by a request's context.
+	clientOptions bool
+	// Limits specified from the initial NewProvider request. These are applied
+	// to the common key set.
+	limits ResponseLimits
+	// Clock specified from the initial NewProvider request, if any.
+	now func() time.Time
+	// Observer specified from the initial NewProvider request, if any.
+	observer *Observer
+	// Degraded mode policy specified from the initial NewProvider request, if
+	// any.
+	degraded *DegradedModePolicy
+	// Retention of removed keys specified from the initial NewProvider
+	// request, if any.
+	keyRetention time.Duration
+	// Signature verifier specified from the initial NewProvider request, if
+	// any.
+	sigVerifier SignatureVerifier
 	// A key set that uses context.Background() and is shared between all code paths
 	// that don't have a convinent way of supplying a unique context.
 	commonRemoteKeySet KeySet
@@ -123,6 +204,22 @@ func (p *Provider) remoteKeySet() KeySet {
 		if p.client != nil {
 			ctx = ClientContext(ctx, p.client)
 		}
+		ctx = ResponseLimitsContext(ctx, p.limits)
+		if p.now != nil {
+			ctx = ClockContext(ctx, p.now)
+		}
+		if p.observer != nil {
+			ctx = ObserverContext(ctx, p.observer)
+		}
+		if p.degraded != nil {
+			ctx = DegradedModeContext(ctx, p.degraded)
+		}
+		if p.keyRetention > 0 {
+			ctx = KeyRetentionContext(ctx, p.keyRetention)
+		}
+		if p.sigVerifier != nil {
+			ctx = SignatureVerifierContext(ctx, p.sigVerifier)
+		}
 		p.commonRemoteKeySet = NewRemoteKeySet(ctx, p.jwksURL)
 	}
 	return p.commonRemoteKeySet
@@ -136,6 +233,11 @@ type providerJSON struct {
 	JWKSURL       string   `json:"jwks_uri"`
 	UserInfoURL   string   `json:"userinfo_endpoint"`
 	Algorithms    []string `json:"id_token_signing_alg_values_supported"`
+
+	CheckSessionIframe string `json:"check_session_iframe"`
+	IntrospectionURL   string `json:"introspection_endpoint"`
+
+	IntrospectionAlgorithms []string `json:"introspection_signing_alg_values_supported"`
 }
 
 // supportedAlgorithms is a list of algorithms explicitly supported by this
@@ -179,6 +281,22 @@ type ProviderConfig struct {
 	// verify issued ID tokens. This endpoint is polled as new keys are made
 	// available.
 	JWKSURL string
+	// CheckSessionIframe is the URL of the provider's iframe used for OpenID
+	// Connect Session Management. Optional.
+	//
+	// https://openid.net/specs/openid-connect-session-1_0.html
+	CheckSessionIframe string
+	// IntrospectionURL is the provider's OAuth 2.0 token introspection
+	// endpoint. Optional.
+	//
+	// https://www.rfc-editor.org/rfc/rfc7662
+	IntrospectionURL string
+	// IntrospectionAlgorithms, if provided, lists the JWT algorithms allowed
+	// to sign introspection responses. If not provided, this defaults to
+	// Algorithms.
+	//
+	// https://www.rfc-editor.org/rfc/rfc9701
+	IntrospectionAlgorithms []string
 
 	// Algorithms, if provided, indicate a list of JWT algorithms allowed to sign
 	// ID tokens. If not provided, this defaults to the algorithms advertised by
@@ -188,8 +306,17 @@ type ProviderConfig struct {
 
 // NewProvider initializes a provider from a set of endpoints, rather than
 // through discovery.
-func (p *ProviderConfig) NewProvider(ctx context.Context) *Provider {
-	return &Provider{
+//
+// Invalid options can't be reported by this method, and cause all requests of
+// the provider to fail instead.
+func (p *ProviderConfig) NewProvider(ctx context.Context, opts ...ProviderOption) *Provider {
+	o := newProviderOptions(opts)
+	client, err := o.httpClient(ctx, p.IssuerURL)
+	if err != nil {
+		client = &http.Client{Transport: errTransport{err}}
+	}
+	clientOptions := client != nil && client != getClient(ctx)
+	provider := &Provider{
 		issuer:        p.IssuerURL,
 		authURL:       p.AuthURL,
 		tokenURL:      p.TokenURL,
@@ -197,40 +324,91 @@ func (p *ProviderConfig) NewProvider(ctx context.Context) *Provider {
 		userInfoURL:   p.UserInfoURL,
 		jwksURL:       p.JWKSURL,
 		algorithms:    p.Algorithms,
-		client:        getClient(ctx),
+		client:        client,
+		clientOptions: clientOptions,
+		limits:        getResponseLimits(ctx),
+		now:           getClock(ctx),
+		observer:      getObserver(ctx),
+		degraded:      getDegradedMode(ctx),
+		keyRetention:  getKeyRetention(ctx),
+		sigVerifier:   getSignatureVerifier(ctx),
+
+		userInfoCache: o.userInfoCache,
+
+		checkSessionIframe: p.CheckSessionIframe,
+		introspectionURL:   p.IntrospectionURL,
+		introspectionAlgs:  p.IntrospectionAlgorithms,
 	}
+	o.overrideEndpoints(provider, p.IssuerURL)
+	return provider
 }
 
 // NewProvider uses the OpenID Connect discovery mechanism to construct a Provider.
 //
 // The issuer is the URL identifier for the service. For example: "https://accounts.google.com"
 // or "https://login.salesforce.com".
-func NewProvider(ctx context.Context, issuer string) (*Provider, error) {
-	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
-	req, err := http.NewRequest("GET", wellKnown, nil)
+func NewProvider(ctx context.Context, issuer string, opts ...ProviderOption) (*Provider, error) {
+	o := newProviderOptions(opts)
+	client, err := o.httpClient(ctx, issuer)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := doRequest(ctx, req)
+	clientOptions := client != nil && client != getClient(ctx)
+	if client != nil {
+		ctx = ClientContext(ctx, client)
+	}
+	start := time.Now()
+	base := issuer
+	if o.fetchURL != "" {
+		base = o.fetchURL
+	}
+	p, err := discover(ctx, issuer, base, client)
+	if o := getObserver(ctx); o != nil && o.Discovery != nil {
+		o.Discovery(DiscoveryEvent{Issuer: issuer, Duration: time.Since(start), Err: err})
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("unable to read response body: %v", err)
+	p.clientOptions = clientOptions
+	p.userInfoCache = o.userInfoCache
+	o.overrideEndpoints(p, issuer)
+	if o.prefetch {
+		if err := p.RefreshKeys(ctx); err != nil {
+			return nil, err
+		}
 	}
+	return p, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s: %s", resp.Status, body)
+// RefreshKeys fetches the keys of the provider's key set, which its verifiers
+// share, and fails if none of them is usable. Use it to warm the key cache of
+// providers created by ProviderConfig.NewProvider. See RemoteKeySet.Refresh.
+func (p *Provider) RefreshKeys(ctx context.Context) error {
+	if p.jwksURL == "" {
+		return errors.New("oidc: provider has no jwks_uri")
+	}
+	r, ok := p.remoteKeySet().(*RemoteKeySet)
+	if !ok {
+		return nil
+	}
+	if err := r.Refresh(ctx); err != nil {
+		return err
+	}
+	if keys, _ := r.keysFromCache(); len(keys) == 0 {
+		return fmt.Errorf("oidc: key set %s contains no usable keys", p.jwksURL)
 	}
+	return nil
+}
 
-	var p providerJSON
-	err = unmarshalResp(resp, body, &p)
+// discover fetches the discovery document of the issuer from base, which is the
+// issuer unless the provider was created with WithFetchURL.
+func discover(ctx context.Context, issuer, base string, client *http.Client) (*Provider, error) {
+	wellKnown := strings.TrimSuffix(base, "/") + "/.well-known/openid-configuration"
+	p, body, err := fetchDiscovery(ctx, wellKnown)
 	if err != nil {
-		return nil, fmt.Errorf("oidc: failed to decode provider discovery object: %v", err)
+		return nil, err
 	}
+	limits := getResponseLimits(ctx)
 
 	issuerURL, skipIssuerValidation := ctx.Value(issuerURLKey).(string)
 	if !skipIssuerValidation {
@@ -239,12 +417,17 @@ func NewProvider(ctx context.Context, issuer string) (*Provider, error) {
 	if p.Issuer != issuerURL && !skipIssuerValidation {
 		return nil, fmt.Errorf("oidc: issuer did not match the issuer returned by provider, expected %q got %q", issuer, p.Issuer)
 	}
-	var algs []string
+	var algs, introspectionAlgs []string
 	for _, a := range p.Algorithms {
 		if supportedAlgorithms[a] {
 			algs = append(algs, a)
 		}
 	}
+	for _, a := range p.IntrospectionAlgorithms {
+		if supportedAlgorithms[a] {
+			introspectionAlgs = append(introspectionAlgs, a)
+		}
+	}
 	return &Provider{
 		issuer:        issuerURL,
 		authURL:       p.AuthURL,
@@ -254,10 +437,53 @@ func NewProvider(ctx context.Context, issuer string) (*Provider, error) {
 		jwksURL:       p.JWKSURL,
 		algorithms:    algs,
 		rawClaims:     body,
-		client:        getClient(ctx),
+		discoveryURL:  wellKnown,
+		client:        client,
+		limits:        limits,
+		now:           getClock(ctx),
+		observer:      getObserver(ctx),
+		degraded:      getDegradedMode(ctx),
+		keyRetention:  getKeyRetention(ctx),
+		sigVerifier:   getSignatureVerifier(ctx),
+
+		checkSessionIframe: p.CheckSessionIframe,
+		introspectionURL:   p.IntrospectionURL,
+		introspectionAlgs:  introspectionAlgs,
 	}, nil
 }
 
+// fetchDiscovery fetches and decodes the discovery document at the URL.
+func fetchDiscovery(ctx context.Context, wellKnown string) (*providerJSON, []byte, error) {
+	req, err := http.NewRequest("GET", wellKnown, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := doRequest(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	limits := getResponseLimits(ctx)
+	body, err := readBody(resp, limits.maxDiscoverySize())
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read response body: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, newHTTPError(resp, body, clockNow(ctx))
+	}
+	if err := limits.checkContentType(resp); err != nil {
+		return nil, nil, fmt.Errorf("oidc: failed to decode provider discovery object: %v", err)
+	}
+
+	var p providerJSON
+	if err := unmarshalResp(resp, body, &p); err != nil {
+		return nil, nil, fmt.Errorf("oidc: failed to decode provider discovery object: %v", err)
+	}
+	return &p, body, nil
+}
+
 // Claims unmarshals raw fields returned by the server during discovery.
 //
 //	var claims struct {
@@ -283,12 +509,32 @@ func (p *Provider) Endpoint() oauth2.Endpoint {
 	return oauth2.Endpoint{AuthURL: p.authURL, DeviceAuthURL: p.deviceAuthURL, TokenURL: p.tokenURL}
 }
 
+// SigningAlgorithms returns the algorithms verifiers created by the provider
+// accept by default: the id_token_signing_alg_values_supported advertised by
+// the discovery document, limited to those this package supports, or the
+// ProviderConfig's Algorithms. If it's empty, verifiers fall back to RS256, the
+// algorithm every provider must support. Set Config.SupportedSigningAlgs to
+// override it.
+func (p *Provider) SigningAlgorithms() []string {
+	return append([]string(nil), p.algorithms...)
+}
+
 // UserInfoEndpoint returns the OpenID Connect userinfo endpoint for the given
 // provider.
 func (p *Provider) UserInfoEndpoint() string {
 	return p.userInfoURL
 }
 
+// CheckSessionIframe returns the URL of the provider's check_session_iframe, used
+// to detect changes to the user's login status at the provider. It returns an
+// empty string if the provider doesn't support OpenID Connect Session
+// Management.
+//
+// See: https://openid.net/specs/openid-connect-session-1_0.html#OPiframe
+func (p *Provider) CheckSessionIframe() string {
+	return p.checkSessionIframe
+}
+
 // UserInfo represents the OpenID Connect userinfo claims.
 type UserInfo struct {
 	Subject       string `json:"sub"`
@@ -306,7 +552,7 @@ type userInfoRaw struct {
 	// Handle providers that return email_verified as a string
 	// https://forums.aws.amazon.com/thread.jspa?messageID=949441&#949441 and
 	// https://discuss.elastic.co/t/openid-error-after-authenticating-against-aws-cognito/206018/11
-	EmailVerified stringAsBool `json:"email_verified"`
+	EmailVerified LenientBool `json:"email_verified"`
 }
 
 // Claims unmarshals the raw JSON object claims into the provided object.
@@ -314,27 +560,187 @@ func (u *UserInfo) Claims(v interface{}) error {
 	if u.claims == nil {
 		return errors.New("oidc: claims not set")
 	}
-	return json.Unmarshal(u.claims, v)
+	return decodeClaims(u.claims, v, false)
+}
+
+// ClaimsUseNumber is like Claims, except that numbers decoded into interface{}
+// values are json.Number, as for IDToken.ClaimsUseNumber.
+func (u *UserInfo) ClaimsUseNumber(v interface{}) error {
+	if u.claims == nil {
+		return errors.New("oidc: claims not set")
+	}
+	return decodeClaims(u.claims, v, true)
+}
+
+// StandardClaims decodes the standard OpenID Connect claims returned by the
+// userinfo endpoint.
+func (u *UserInfo) StandardClaims() (*StandardClaims, error) {
+	if u.claims == nil {
+		return nil, errors.New("oidc: claims not set")
+	}
+	return parseStandardClaims(u.claims)
+}
+
+// UserInfoClaims decodes the claims returned by the userinfo endpoint into a
+// value of type T.
+//
+//	type groupClaims struct {
+//		Groups []string `json:"groups"`
+//	}
+//
+//	claims, err := oidc.UserInfoClaims[groupClaims](userInfo)
+//	if err != nil {
+//		// handle error
+//	}
+func UserInfoClaims[T any](u *UserInfo) (T, error) {
+	var v T
+	err := u.Claims(&v)
+	return v, err
+}
+
+// UserInfoOption configures a request to the userinfo endpoint.
+type UserInfoOption func(*userInfoOptions)
+
+type userInfoOptions struct {
+	idToken *IDToken
+	strict  bool
+	post    bool
+	header  http.Header
+}
+
+// StrictBooleans causes UserInfo to reject responses that encode boolean claims,
+// such as email_verified, as strings. By default "true" and "false" strings are
+// accepted, since several providers return them.
+func StrictBooleans() UserInfoOption {
+	return func(o *userInfoOptions) {
+		o.strict = true
+	}
+}
+
+// WithUserInfoPost sends the userinfo request as a POST with the access token in
+// a form-encoded body, as described by RFC 6750 section 2.2, instead of a GET
+// with an Authorization header. OpenID Connect allows both, and some providers
+// only accept this form.
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#UserInfoRequest
+func WithUserInfoPost() UserInfoOption {
+	return func(o *userInfoOptions) {
+		o.post = true
+	}
+}
+
+// WithUserInfoHeader adds a header to the userinfo request, such as an API key
+// required by a gateway in front of the provider. It can't replace the
+// Authorization header carrying the access token.
+func WithUserInfoHeader(key, value string) UserInfoOption {
+	return func(o *userInfoOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Add(key, value)
+	}
+}
+
+// WithIDToken requires the subject returned by the userinfo endpoint to match
+// the subject of a verified ID Token. If they differ, UserInfo returns a
+// *UserInfoSubjectMismatchError.
+//
+// OpenID Connect requires this check, since the userinfo response may otherwise
+// be for a different user than the one who authenticated.
+//
+//	userInfo, err := provider.UserInfo(ctx, tokenSource, oidc.WithIDToken(idToken))
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse
+func WithIDToken(idToken *IDToken) UserInfoOption {
+	return func(o *userInfoOptions) {
+		o.idToken = idToken
+	}
 }
 
 // UserInfo uses the token source to query the provider's user info endpoint.
-func (p *Provider) UserInfo(ctx context.Context, tokenSource oauth2.TokenSource) (*UserInfo, error) {
+//
+// After the provider answers with 429 Too Many Requests, further calls fail
+// without a request until the time given by its Retry-After header has
+// elapsed. The error wraps an *HTTPError holding the remaining time.
+//
+// If the provider was created with WithUserInfoCache, responses are cached by
+// access token, and concurrent calls for the same token share one request.
+func (p *Provider) UserInfo(ctx context.Context, tokenSource oauth2.TokenSource, opts ...UserInfoOption) (*UserInfo, error) {
+	var o userInfoOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	if p.userInfoURL == "" {
 		return nil, errors.New("oidc: user info endpoint is not supported by this provider")
 	}
 
-	req, err := http.NewRequest("GET", p.userInfoURL, nil)
+	token, err := tokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("oidc: get access token: %w", err)
+	}
+	var body []byte
+	if p.userInfoCache != nil {
+		body, err = p.userInfoCache.fetch(ctx, token.AccessToken, func(ctx context.Context) ([]byte, error) {
+			return p.fetchUserInfo(ctx, token, &o)
+		})
+	} else {
+		body, err = p.fetchUserInfo(ctx, token, &o)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("oidc: create GET request: %v", err)
+		return nil, err
 	}
 
-	token, err := tokenSource.Token()
+	var userInfo userInfoRaw
+	if err := json.Unmarshal(body, &userInfo); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode userinfo: %v", err)
+	}
+	if o.strict {
+		var strict struct {
+			EmailVerified       *bool `json:"email_verified"`
+			PhoneNumberVerified *bool `json:"phone_number_verified"`
+		}
+		if err := json.Unmarshal(body, &strict); err != nil {
+			return nil, fmt.Errorf("oidc: failed to decode userinfo: %v", err)
+		}
+	}
+	if o.idToken != nil && o.idToken.Subject != userInfo.Subject {
+		return nil, &UserInfoSubjectMismatchError{IDToken: o.idToken.Subject, UserInfo: userInfo.Subject}
+	}
+	return &UserInfo{
+		Subject:       userInfo.Subject,
+		Profile:       userInfo.Profile,
+		Email:         userInfo.Email,
+		EmailVerified: bool(userInfo.EmailVerified),
+		claims:        body,
+	}, nil
+}
+
+// fetchUserInfo requests the claims of the token's user. JWT responses are
+// returned as their verified payload.
+func (p *Provider) fetchUserInfo(ctx context.Context, token *oauth2.Token, o *userInfoOptions) ([]byte, error) {
+	method, form := "GET", ""
+	if o.post {
+		method, form = "POST", url.Values{"access_token": {token.AccessToken}}.Encode()
+	}
+	req, err := http.NewRequest(method, p.userInfoURL, strings.NewReader(form))
 	if err != nil {
-		return nil, fmt.Errorf("oidc: get access token: %v", err)
+		return nil, fmt.Errorf("oidc: create %s request: %v", method, err)
+	}
+	if err := p.userInfoLimiter.check(clockNow(ctx)); err != nil {
+		return nil, err
+	}
+	for k, v := range o.header {
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	if o.post {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Del("Authorization")
+	} else {
+		token.SetAuthHeader(req)
 	}
-	token.SetAuthHeader(req)
 
-	resp, err := doRequest(ctx, req)
+	resp, err := doRequest(p.clientContext(ctx), req)
 	if err != nil {
 		return nil, err
 	}
@@ -344,7 +750,13 @@ func (p *Provider) UserInfo(ctx context.Context, tokenSource oauth2.TokenSource)
 		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s: %s", resp.Status, body)
+		// Back off even if the provider explained the error in the body.
+		httpErr := newHTTPError(resp, body, clockNow(ctx))
+		p.userInfoLimiter.record(httpErr, clockNow(ctx))
+		if oauthErr := parseOAuthError(resp, body); oauthErr != nil {
+			return nil, oauthErr
+		}
+		return nil, httpErr
 	}
 
 	ct := resp.Header.Get("Content-Type")
@@ -356,18 +768,7 @@ func (p *Provider) UserInfo(ctx context.Context, tokenSource oauth2.TokenSource)
 		}
 		body = payload
 	}
-
-	var userInfo userInfoRaw
-	if err := json.Unmarshal(body, &userInfo); err != nil {
-		return nil, fmt.Errorf("oidc: failed to decode userinfo: %v", err)
-	}
-	return &UserInfo{
-		Subject:       userInfo.Subject,
-		Profile:       userInfo.Profile,
-		Email:         userInfo.Email,
-		EmailVerified: bool(userInfo.EmailVerified),
-		claims:        body,
-	}, nil
+	return body, nil
 }
 
 // IDToken is an OpenID Connect extension that provides a predictable representation
@@ -400,6 +801,12 @@ type IDToken struct {
 	Expiry time.Time
 	// When the token was issued by the provider.
 	IssuedAt time.Time
+	// Time before which the token must not be accepted, from the nbf claim.
+	// Zero if the token has no nbf claim.
+	NotBefore time.Time
+	// When the end user authenticated, from the auth_time claim. Zero if the
+	// token has no auth_time claim.
+	AuthTime time.Time
 
 	// Initial nonce provided during the authentication redirect.
 	//
@@ -407,19 +814,44 @@ type IDToken struct {
 	// and it's the user's responsibility to ensure it contains a valid value.
 	Nonce string
 
+	// sid claim, if set in the ID token. It identifies the end user's session
+	// at the provider, and is used to match back-channel and front-channel
+	// logout requests to the sessions they end.
+	//
+	// See: https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+	SessionID string
+
 	// at_hash claim, if set in the ID token. Callers can verify an access token
 	// that corresponds to the ID token using the VerifyAccessToken method.
 	AccessTokenHash string
 
+	// c_hash claim, if set in the ID token. Callers can verify an authorization
+	// code returned alongside the ID token using the VerifyCode method.
+	CodeHash string
+
+	// azp claim, if set in the ID token.
+	authorizedParty string
+
 	// signature algorithm used for ID token, needed to compute a verification hash of an
 	// access token
 	sigAlgorithm string
 
+	// JOSE header of the token. Only set if the signature was verified.
+	header TokenHeader
+
 	// Raw payload of the id_token.
 	claims []byte
 
+	// The id_token as it was passed to Verify.
+	raw string
+
 	// Map of distributed claim names to claim sources
 	distributedClaims map[string]claimSource
+
+	// email, email_verified and hd claims. See Email and HostedDomain.
+	email         string
+	emailVerified bool
+	hostedDomain  string
 }
 
 // Claims unmarshals the raw JSON payload of the ID Token into a provided struct.
@@ -439,7 +871,86 @@ func (i *IDToken) Claims(v interface{}) error {
 	if i.claims == nil {
 		return errors.New("oidc: claims not set")
 	}
-	return json.Unmarshal(i.claims, v)
+	return decodeClaims(i.claims, v, false)
+}
+
+// ClaimsUseNumber is like Claims, except that numbers decoded into interface{}
+// values, such as the values of a map[string]interface{}, are json.Number
+// rather than float64, so that large integers like snowflake IDs and
+// nanosecond timestamps keep their precision.
+func (i *IDToken) ClaimsUseNumber(v interface{}) error {
+	if i.claims == nil {
+		return errors.New("oidc: claims not set")
+	}
+	return decodeClaims(i.claims, v, true)
+}
+
+// ClaimNames returns the names of the token's claims, in the order they appear
+// in its payload, without decoding their values.
+func (i *IDToken) ClaimNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	rangeClaims(i.claims, func(name string, _ json.RawMessage) bool {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		return true
+	})
+	return names
+}
+
+// ClaimValue returns the raw JSON value of the named claim, and whether the
+// token has it. Unlike Claims, only the requested value is copied out of the
+// payload, which suits callers looking up a few claims by name.
+//
+//	if v, ok := idToken.ClaimValue("groups"); ok {
+//		var groups []string
+//		if err := json.Unmarshal(v, &groups); err != nil {
+//			// handle error
+//		}
+//	}
+//
+// Claim names are matched exactly. If the claim appears more than once, which
+// Verify only accepts with Config.AllowDuplicateClaims, the last value is
+// returned, as with Claims.
+func (i *IDToken) ClaimValue(name string) (json.RawMessage, bool) {
+	var (
+		value json.RawMessage
+		found bool
+	)
+	rangeClaims(i.claims, func(n string, v json.RawMessage) bool {
+		if n == name {
+			value, found = v, true
+		}
+		return true
+	})
+	return value, found
+}
+
+// Raw returns the ID Token as it was passed to Verify, so it can be forwarded to
+// other services after verification.
+func (i *IDToken) Raw() string {
+	return i.raw
+}
+
+// Payload returns the decoded JSON payload of the ID Token. The returned slice
+// is a copy and may be modified by the caller.
+func (i *IDToken) Payload() []byte {
+	if i.claims == nil {
+		return nil
+	}
+	return append([]byte(nil), i.claims...)
+}
+
+// Header returns values from the JOSE header of the ID Token, such as the ID of
+// the key that signed it.
+//
+//	log.Printf("token for %s signed by key %q", idToken.Subject, idToken.Header().KeyID)
+//
+// The header is empty if the token was verified with InsecureSkipSignatureCheck.
+func (i *IDToken) Header() TokenHeader {
+	return i.header
 }
 
 // VerifyAccessToken verifies that the hash of the access token that corresponds to the iD token
@@ -450,51 +961,179 @@ func (i *IDToken) VerifyAccessToken(accessToken string) error {
 	if i.AccessTokenHash == "" {
 		return errNoAtHash
 	}
+	actual, err := tokenHash(i.sigAlgorithm, accessToken)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(actual), []byte(i.AccessTokenHash)) != 1 {
+		return errInvalidAtHash
+	}
+	return nil
+}
+
+// VerifyCode verifies that the hash of an authorization code returned alongside
+// the ID token in a hybrid flow response matches the c_hash claim of the token.
+// See https://openid.net/specs/openid-connect-core-1_0.html#HybridIDToken
+func (i *IDToken) VerifyCode(code string) error {
+	if i.CodeHash == "" {
+		return errNoCHash
+	}
+	actual, err := tokenHash(i.sigAlgorithm, code)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(actual), []byte(i.CodeHash)) != 1 {
+		return errInvalidCHash
+	}
+	return nil
+}
+
+// tokenHash computes the left-most half of the hash of value using the hash
+// function of the signature algorithm, as used by the at_hash and c_hash claims.
+func tokenHash(alg, value string) (string, error) {
 	var h hash.Hash
-	switch i.sigAlgorithm {
-	case RS256, ES256, PS256:
+	switch alg {
+	case RS256, ES256, PS256, HS256:
 		h = sha256.New()
-	case RS384, ES384, PS384:
+	case RS384, ES384, PS384, HS384:
 		h = sha512.New384()
-	case RS512, ES512, PS512, EdDSA:
+	case RS512, ES512, PS512, HS512, EdDSA:
 		h = sha512.New()
 	default:
-		return fmt.Errorf("oidc: unsupported signing algorithm %q", i.sigAlgorithm)
+		return "", fmt.Errorf("oidc: unsupported signing algorithm %q", alg)
 	}
-	h.Write([]byte(accessToken)) // hash documents that Write will never return an error
+	h.Write([]byte(value)) // hash documents that Write will never return an error
 	sum := h.Sum(nil)[:h.Size()/2]
-	actual := base64.RawURLEncoding.EncodeToString(sum)
-	if actual != i.AccessTokenHash {
-		return errInvalidAtHash
-	}
-	return nil
+	return base64.RawURLEncoding.EncodeToString(sum), nil
 }
 
 type idToken struct {
-	Issuer       string                 `json:"iss"`
-	Subject      string                 `json:"sub"`
-	Audience     audience               `json:"aud"`
-	Expiry       jsonTime               `json:"exp"`
-	IssuedAt     jsonTime               `json:"iat"`
-	NotBefore    *jsonTime              `json:"nbf"`
-	Nonce        string                 `json:"nonce"`
-	AtHash       string                 `json:"at_hash"`
-	ClaimNames   map[string]string      `json:"_claim_names"`
-	ClaimSources map[string]claimSource `json:"_claim_sources"`
+	Issuer        string                 `json:"iss"`
+	Subject       string                 `json:"sub"`
+	Audience      StringOrArray          `json:"aud"`
+	Expiry        jsonTime               `json:"exp"`
+	IssuedAt      jsonTime               `json:"iat"`
+	NotBefore     *jsonTime              `json:"nbf"`
+	AuthTime      *jsonTime              `json:"auth_time"`
+	Nonce         string                 `json:"nonce"`
+	SessionID     string                 `json:"sid"`
+	Email         json.RawMessage        `json:"email"`
+	EmailVerified json.RawMessage        `json:"email_verified"`
+	HostedDomain  json.RawMessage        `json:"hd"`
+	AtHash        string                 `json:"at_hash"`
+	CHash         string                 `json:"c_hash"`
+	AuthzParty    string                 `json:"azp"`
+	ClaimNames    map[string]string      `json:"_claim_names"`
+	ClaimSources  map[string]claimSource `json:"_claim_sources"`
 }
 
 type claimSource struct {
 	Endpoint    string `json:"endpoint"`
 	AccessToken string `json:"access_token"`
+	JWT         string `json:"JWT"`
+}
+
+// ClaimSource is the source of a distributed claim, listed in the
+// "_claim_sources" claim of a token.
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#AggregatedDistributedClaims
+type ClaimSource struct {
+	// Endpoint returns the claim.
+	Endpoint string
+	// AccessToken, if set, authenticates requests to the endpoint.
+	AccessToken string
+	// JWT holds the signed claims of an aggregated claim source, which are
+	// included in the token rather than fetched from an endpoint.
+	JWT string
+}
+
+// ClaimSource returns the source of a distributed or aggregated claim, if the
+// token provides the claim through one.
+func (i *IDToken) ClaimSource(claim string) (ClaimSource, bool) {
+	src, ok := i.distributedClaims[claim]
+	return ClaimSource(src), ok
+}
+
+// Email returns the email claim of the token, and whether the provider has
+// verified that the end user controls the address, as reported by the
+// email_verified claim. The strings "true" and "false" are accepted for
+// email_verified, since several providers encode it this way. An absent or
+// malformed email_verified claim is reported as unverified.
+//
+// Unverified addresses must not be used to identify users, since anyone may be
+// able to register an account with them at the provider. Set
+// Config.RequireVerifiedEmail to reject such tokens during Verify.
+func (i *IDToken) Email() (email string, verified bool) {
+	return i.email, i.emailVerified
 }
 
-type stringAsBool bool
+// HostedDomain returns the hd claim of the token, which Google sets to the
+// Google Workspace domain of the end user's account. It's empty for consumer
+// accounts and for other providers.
+//
+// Unlike the domain of the email claim, the hosted domain can't be chosen by
+// the end user, so it's the claim to use to restrict sign in to an
+// organization.
+func (i *IDToken) HostedDomain() string {
+	return i.hostedDomain
+}
+
+// AuthenticationMethods returns the amr claim of the token, which lists the
+// methods used to authenticate the end user, such as "pwd", "otp" or "mfa". A
+// single method encoded as a string is returned as a list of one.
+//
+// See: https://www.rfc-editor.org/rfc/rfc8176
+func (i *IDToken) AuthenticationMethods() ([]string, error) {
+	return i.stringList("amr")
+}
+
+// Groups returns the groups claim of the token, accepting both the array and
+// the single string encodings used by providers. Use IdentityMapper for
+// providers that name the claim differently.
+func (i *IDToken) Groups() ([]string, error) {
+	return i.stringList("groups")
+}
+
+// stringList decodes a claim as a StringOrArray. It's nil if the claim is
+// absent.
+func (i *IDToken) stringList(name string) ([]string, error) {
+	raw, ok := i.ClaimValue(name)
+	if !ok {
+		return nil, nil
+	}
+	var list StringOrArray
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("oidc: malformed %s claim: %v", name, err)
+	}
+	return list, nil
+}
+
+// ClaimResolver fetches the value of a distributed claim from its source. It's
+// used to resolve claims from sources that don't follow the specification,
+// which requires sources to return signed JWTs.
+type ClaimResolver interface {
+	// ResolveClaim returns the JSON encoded value of the claim.
+	ResolveClaim(ctx context.Context, claim string, src ClaimSource) (json.RawMessage, error)
+}
+
+// LenientBool is a boolean claim that also accepts the strings "true" and
+// "false", ignoring case. Some providers, such as AWS Cognito and older versions
+// of Keycloak, encode claims like email_verified this way.
+//
+//	var claims struct {
+//		Email         string           `json:"email"`
+//		EmailVerified oidc.LenientBool `json:"email_verified"`
+//	}
+//
+// A null value decodes as false. Use a plain bool to reject string encodings.
+type LenientBool bool
 
-func (sb *stringAsBool) UnmarshalJSON(b []byte) error {
-	switch string(b) {
+// UnmarshalJSON implements json.Unmarshaler.
+func (sb *LenientBool) UnmarshalJSON(b []byte) error {
+	switch strings.ToLower(string(b)) {
 	case "true", `"true"`:
 		*sb = true
-	case "false", `"false"`:
+	case "false", `"false"`, "null":
 		*sb = false
 	default:
 		return errors.New("invalid value for boolean")
@@ -502,25 +1141,16 @@ func (sb *stringAsBool) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
-type audience []string
+type jsonTime time.Time
 
-func (a *audience) UnmarshalJSON(b []byte) error {
-	var s string
-	if json.Unmarshal(b, &s) == nil {
-		*a = audience{s}
+func (j *jsonTime) UnmarshalJSON(b []byte) error {
+	// Fast path for the common case of a plain integer, which avoids the
+	// allocations of decoding through json.Number.
+	if unix, ok := parseUnixInt(b); ok {
+		*j = jsonTime(time.Unix(unix, 0))
 		return nil
 	}
-	var auds []string
-	if err := json.Unmarshal(b, &auds); err != nil {
-		return err
-	}
-	*a = auds
-	return nil
-}
 
-type jsonTime time.Time
-
-func (j *jsonTime) UnmarshalJSON(b []byte) error {
 	var n json.Number
 	if err := json.Unmarshal(b, &n); err != nil {
 		return err
@@ -540,6 +1170,21 @@ func (j *jsonTime) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// parseUnixInt parses a non-negative JSON integer of at most 18 digits.
+func parseUnixInt(b []byte) (int64, bool) {
+	if len(b) == 0 || len(b) > 18 {
+		return 0, false
+	}
+	var n int64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int64(c-'0')
+	}
+	return n, true
+}
+
 func unmarshalResp(r *http.Response, body []byte, v interface{}) error {
 	err := json.Unmarshal(body, &v)
 	if err == nil {
@@ -2,6 +2,7 @@ package oidc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,7 +10,9 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	jose "github.com/go-jose/go-jose/v3"
 	"golang.org/x/oauth2"
 )
 
@@ -558,3 +561,204 @@ func TestUserInfoEndpoint(t *testing.T) {
 	}
 
 }
+
+func TestClockContext(t *testing.T) {
+	key := newRSAKey(t)
+	s := httptest.NewServer(&keyServer{keys: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}}})
+	defer s.Close()
+
+	now := time.Date(2022, 1, 29, 0, 0, 0, 0, time.UTC)
+	token := key.sign(t, []byte(fmt.Sprintf(`{"iss":"https://foo","aud":"client1","exp":%d}`, now.Add(time.Hour).Unix())))
+
+	config := &ProviderConfig{IssuerURL: "https://foo", JWKSURL: s.URL}
+	ctx := context.Background()
+
+	p := config.NewProvider(ClockContext(ctx, func() time.Time { return now }))
+	if _, err := p.Verifier(&Config{ClientID: "client1"}).Verify(ctx, token); err != nil {
+		t.Errorf("verifying with provider clock: %v", err)
+	}
+
+	// Config.Now takes precedence over the provider's clock.
+	c := &Config{ClientID: "client1", Now: time.Now}
+	if _, err := p.Verifier(c).Verify(ctx, token); err == nil {
+		t.Errorf("expected token to be expired using Config.Now")
+	}
+
+	// Without a clock the token is expired.
+	p = config.NewProvider(ctx)
+	if _, err := p.Verifier(&Config{ClientID: "client1"}).Verify(ctx, token); err == nil {
+		t.Errorf("expected token to be expired using time.Now")
+	}
+
+	// Values set by other contexts of this package aren't shadowed.
+	ctx = ResponseLimitsContext(InsecureIssuerURLContext(ClockContext(ctx, time.Now), "https://bar"), ResponseLimits{})
+	if getClock(ctx) == nil || ctx.Value(issuerURLKey) != "https://bar" {
+		t.Errorf("expected clock and issuer to survive other context values")
+	}
+}
+
+func TestUserInfoStandardClaims(t *testing.T) {
+	claims := `{
+		"sub": "1234567890",
+		"name": "Jane Doe",
+		"given_name": "Jane",
+		"family_name": "Doe",
+		"picture": "https://example.com/jane.jpg",
+		"locale": "en-US",
+		"email": "jane@example.com",
+		"email_verified": "true",
+		"phone_number": "+1 555 0100",
+		"phone_number_verified": true,
+		"address": {"locality": "Los Angeles", "country": "US"},
+		"updated_at": 1643414400,
+		"groups": ["admins"]
+	}`
+	u := &UserInfo{claims: []byte(claims)}
+
+	got, err := u.StandardClaims()
+	if err != nil {
+		t.Fatalf("decoding standard claims: %v", err)
+	}
+	want := &StandardClaims{
+		Subject:             "1234567890",
+		Name:                "Jane Doe",
+		GivenName:           "Jane",
+		FamilyName:          "Doe",
+		Picture:             "https://example.com/jane.jpg",
+		Locale:              "en-US",
+		Email:               "jane@example.com",
+		EmailVerified:       true,
+		PhoneNumber:         "+1 555 0100",
+		PhoneNumberVerified: true,
+		Address:             &AddressClaim{Locality: "Los Angeles", Country: "US"},
+		UpdatedAt:           time.Unix(1643414400, 0),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	type groupClaims struct {
+		Groups []string `json:"groups"`
+	}
+	groups, err := UserInfoClaims[groupClaims](u)
+	if err != nil {
+		t.Fatalf("decoding custom claims: %v", err)
+	}
+	if !reflect.DeepEqual(groups.Groups, []string{"admins"}) {
+		t.Errorf("unexpected groups %v", groups.Groups)
+	}
+}
+
+func TestUserInfoSubjectMismatch(t *testing.T) {
+	server := testServer{
+		contentType: "application/json",
+		userInfo:    `{"sub": "1234567890", "email": "joe@doe.com"}`,
+	}
+	serverURL := server.run(t)
+
+	ctx := context.Background()
+	provider, err := NewProvider(ctx, serverURL)
+	if err != nil {
+		t.Fatalf("Failed to initialize provider for test %v", err)
+	}
+	ts := oauth2.StaticTokenSource(&oauth2.Token{})
+
+	if _, err := provider.UserInfo(ctx, ts, WithIDToken(&IDToken{Subject: "1234567890"})); err != nil {
+		t.Errorf("expected matching subject to succeed, got %v", err)
+	}
+
+	_, err = provider.UserInfo(ctx, ts, WithIDToken(&IDToken{Subject: "0987654321"}))
+	if msg := expectErrorType[*UserInfoSubjectMismatchError](err); msg != "" {
+		t.Error(msg)
+	}
+}
+
+func TestLenientBool(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    bool
+		wantErr bool
+	}{
+		{`true`, true, false},
+		{`false`, false, false},
+		{`"true"`, true, false},
+		{`"False"`, false, false},
+		{`"TRUE"`, true, false},
+		{`null`, false, false},
+		{`"yes"`, false, true},
+		{`1`, false, true},
+	}
+	for _, test := range tests {
+		var claims struct {
+			EmailVerified LenientBool `json:"email_verified"`
+		}
+		err := json.Unmarshal([]byte(`{"email_verified":`+test.data+`}`), &claims)
+		if err != nil {
+			if !test.wantErr {
+				t.Errorf("decoding %s: %v", test.data, err)
+			}
+			continue
+		}
+		if test.wantErr {
+			t.Errorf("decoding %s: expected error", test.data)
+		}
+		if bool(claims.EmailVerified) != test.want {
+			t.Errorf("decoding %s: expected %v got %v", test.data, test.want, claims.EmailVerified)
+		}
+	}
+}
+
+func TestUserInfoStrictBooleans(t *testing.T) {
+	server := testServer{
+		contentType: "application/json",
+		userInfo:    `{"sub": "1234567890", "email_verified": "true"}`,
+	}
+	serverURL := server.run(t)
+
+	ctx := context.Background()
+	provider, err := NewProvider(ctx, serverURL)
+	if err != nil {
+		t.Fatalf("Failed to initialize provider for test %v", err)
+	}
+	ts := oauth2.StaticTokenSource(&oauth2.Token{})
+
+	info, err := provider.UserInfo(ctx, ts)
+	if err != nil {
+		t.Fatalf("expected lenient decoding to succeed, got %v", err)
+	}
+	if !info.EmailVerified {
+		t.Errorf("expected email to be verified")
+	}
+	if _, err := provider.UserInfo(ctx, ts, StrictBooleans()); err == nil {
+		t.Errorf("expected strict decoding to fail")
+	}
+}
+
+func TestUserInfoRequestOptions(t *testing.T) {
+	var got *http.Request
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"sub":"1234567890"}`)
+	}))
+	defer s.Close()
+
+	ctx := context.Background()
+	p := (&ProviderConfig{IssuerURL: "https://foo", UserInfoURL: s.URL}).NewProvider(ctx)
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "at", TokenType: "Bearer"})
+
+	if _, err := p.UserInfo(ctx, ts, WithUserInfoHeader("X-Api-Key", "k")); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != "GET" || got.Header.Get("Authorization") != "Bearer at" || got.Header.Get("X-Api-Key") != "k" {
+		t.Errorf("unexpected GET request %s %v", got.Method, got.Header)
+	}
+
+	if _, err := p.UserInfo(ctx, ts, WithUserInfoPost(), WithUserInfoHeader("Authorization", "Basic x")); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != "POST" || got.PostForm.Get("access_token") != "at" || got.Header.Get("Authorization") != "" {
+		t.Errorf("unexpected POST request %s %v %v", got.Method, got.Header, got.PostForm)
+	}
+}
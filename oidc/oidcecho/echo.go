@@ -0,0 +1,39 @@
+// Package oidcecho adapts oidc.BearerMiddleware to the Echo web framework. It's
+// a separate module so the core module doesn't depend on Echo.
+//
+//	verifier := provider.Verifier(&oidc.Config{ClientID: "https://api.example.com"})
+//	e := echo.New()
+//	e.GET("/users", func(c echo.Context) error {
+//		token, _ := oidcecho.BearerToken(c)
+//		return c.String(http.StatusOK, token.Subject)
+//	}, oidcecho.Bearer(verifier, oidc.RequireScopes("read:users")))
+package oidcecho
+
+import (
+	"net/http"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/labstack/echo/v4"
+)
+
+// Bearer returns Echo middleware authenticating requests like
+// oidc.BearerMiddleware. Rejected requests get the middleware's response and
+// don't reach the next handler.
+func Bearer(verifier oidc.TokenVerifier, opts ...oidc.MiddlewareOption) echo.MiddlewareFunc {
+	mw := oidc.BearerMiddleware(verifier, opts...)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var err error
+			mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				err = next(c)
+			})).ServeHTTP(c.Response(), c.Request())
+			return err
+		}
+	}
+}
+
+// BearerToken returns the token verified by Bearer.
+func BearerToken(c echo.Context) (*oidc.IDToken, bool) {
+	return oidc.BearerTokenFromContext(c.Request().Context())
+}
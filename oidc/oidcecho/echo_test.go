@@ -0,0 +1,60 @@
+package oidcecho
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/labstack/echo/v4"
+)
+
+type fakeVerifier struct{}
+
+func (fakeVerifier) Verify(ctx context.Context, raw string, opts ...oidc.VerifyOption) (*oidc.IDToken, error) {
+	if raw != "good" {
+		return nil, errors.New("invalid token")
+	}
+	return &oidc.IDToken{Subject: "jane"}, nil
+}
+
+func TestBearer(t *testing.T) {
+	r := echo.New()
+	r.GET("/", func(c echo.Context) error {
+		token, ok := BearerToken(c)
+		if !ok {
+			t.Error("handler has no token")
+			return nil
+		}
+		return c.String(http.StatusOK, token.Subject)
+	}, Bearer(fakeVerifier{}))
+
+	tests := []struct {
+		auth       string
+		wantStatus int
+		wantBody   string
+	}{
+		{auth: "Bearer good", wantStatus: http.StatusOK, wantBody: "jane"},
+		{auth: "Bearer bad", wantStatus: http.StatusUnauthorized},
+		{wantStatus: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != test.wantStatus {
+			t.Errorf("%q: got status %d, want %d", test.auth, w.Code, test.wantStatus)
+		}
+		if test.wantBody != "" && w.Body.String() != test.wantBody {
+			t.Errorf("%q: got body %q, want %q", test.auth, w.Body.String(), test.wantBody)
+		}
+		if test.wantStatus == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%q: missing challenge", test.auth)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+module github.com/coreos/go-oidc/v3/oidc/oidcecho
+
+go 1.19
+
+// See "Nested Modules" in CONTRIBUTING.md before releasing this module.
+replace github.com/coreos/go-oidc/v3 => ../..
+
+require (
+	github.com/coreos/go-oidc/v3 v3.21.0
+	github.com/labstack/echo/v4 v4.11.2
+)
+
+require (
+	github.com/go-jose/go-jose/v3 v3.0.1 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/labstack/gommon v0.4.0 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.2.2 // indirect
+	golang.org/x/crypto v0.14.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/oauth2 v0.13.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/appengine v1.6.8 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
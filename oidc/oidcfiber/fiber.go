@@ -0,0 +1,54 @@
+// Package oidcfiber adapts oidc.BearerMiddleware to the Fiber web framework.
+// It's a separate module so the core module doesn't depend on Fiber.
+//
+//	verifier := provider.Verifier(&oidc.Config{ClientID: "https://api.example.com"})
+//	app := fiber.New()
+//	app.Get("/users", oidcfiber.Bearer(verifier, oidc.RequireScopes("read:users")), func(c *fiber.Ctx) error {
+//		token, _ := oidcfiber.BearerToken(c)
+//		return c.SendString(token.Subject)
+//	})
+package oidcfiber
+
+import (
+	"net/http"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+// tokenKey is the key of the verified token in the locals of a request.
+const tokenKey = "github.com/coreos/go-oidc/v3/oidc/oidcfiber.token"
+
+// Bearer returns a Fiber handler authenticating requests like
+// oidc.BearerMiddleware. Since Fiber doesn't use net/http requests, the
+// verified token is kept in the request's locals and read with BearerToken.
+func Bearer(verifier oidc.TokenVerifier, opts ...oidc.MiddlewareOption) fiber.Handler {
+	mw := oidc.BearerMiddleware(verifier, opts...)
+	return func(c *fiber.Ctx) error {
+		var (
+			passed bool
+			token  *oidc.IDToken
+		)
+		h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			passed = true
+			token, _ = oidc.BearerTokenFromContext(r.Context())
+		}))
+		if err := adaptor.HTTPHandler(h)(c); err != nil {
+			return err
+		}
+		if !passed {
+			return nil
+		}
+		if token != nil {
+			c.Locals(tokenKey, token)
+		}
+		return c.Next()
+	}
+}
+
+// BearerToken returns the token verified by Bearer.
+func BearerToken(c *fiber.Ctx) (*oidc.IDToken, bool) {
+	token, ok := c.Locals(tokenKey).(*oidc.IDToken)
+	return token, ok
+}
@@ -0,0 +1,65 @@
+package oidcfiber
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gofiber/fiber/v2"
+)
+
+type fakeVerifier struct{}
+
+func (fakeVerifier) Verify(ctx context.Context, raw string, opts ...oidc.VerifyOption) (*oidc.IDToken, error) {
+	if raw != "good" {
+		return nil, errors.New("invalid token")
+	}
+	return &oidc.IDToken{Subject: "jane"}, nil
+}
+
+func TestBearer(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", Bearer(fakeVerifier{}), func(c *fiber.Ctx) error {
+		token, ok := BearerToken(c)
+		if !ok {
+			t.Error("handler has no token")
+			return nil
+		}
+		return c.SendString(token.Subject)
+	})
+
+	tests := []struct {
+		auth       string
+		wantStatus int
+		wantBody   string
+	}{
+		{auth: "Bearer good", wantStatus: http.StatusOK, wantBody: "jane"},
+		{auth: "Bearer bad", wantStatus: http.StatusUnauthorized},
+		{wantStatus: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.wantStatus {
+			t.Errorf("%q: got status %d, want %d", test.auth, resp.StatusCode, test.wantStatus)
+		}
+		if test.wantBody != "" && string(body) != test.wantBody {
+			t.Errorf("%q: got body %q, want %q", test.auth, body, test.wantBody)
+		}
+		if test.wantStatus == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%q: missing challenge", test.auth)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Package oidcgin adapts oidc.BearerMiddleware to the Gin web framework. It's
+// a separate module so the core module doesn't depend on Gin.
+//
+//	verifier := provider.Verifier(&oidc.Config{ClientID: "https://api.example.com"})
+//	r := gin.Default()
+//	r.GET("/users", oidcgin.Bearer(verifier, oidc.RequireScopes("read:users")), func(c *gin.Context) {
+//		token, _ := oidcgin.BearerToken(c)
+//		c.JSON(http.StatusOK, gin.H{"subject": token.Subject})
+//	})
+package oidcgin
+
+import (
+	"net/http"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+)
+
+// Bearer returns a Gin handler authenticating requests like
+// oidc.BearerMiddleware. Rejected requests are aborted after the middleware
+// writes its response.
+func Bearer(verifier oidc.TokenVerifier, opts ...oidc.MiddlewareOption) gin.HandlerFunc {
+	mw := oidc.BearerMiddleware(verifier, opts...)
+	return func(c *gin.Context) {
+		var passed bool
+		mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			passed = true
+			c.Request = r
+			c.Next()
+		})).ServeHTTP(c.Writer, c.Request)
+		if !passed {
+			c.Abort()
+		}
+	}
+}
+
+// BearerToken returns the token verified by Bearer.
+func BearerToken(c *gin.Context) (*oidc.IDToken, bool) {
+	return oidc.BearerTokenFromContext(c.Request.Context())
+}
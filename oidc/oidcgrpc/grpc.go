@@ -0,0 +1,218 @@
+// Package oidcgrpc provides gRPC server interceptors that authenticate calls
+// with bearer tokens sent in the "authorization" metadata, the gRPC counterpart
+// of oidc.BearerMiddleware. It's a separate module so the core module doesn't
+// depend on gRPC.
+//
+//	verifier := provider.Verifier(&oidc.Config{ClientID: "https://api.example.com"})
+//	opts := []oidcgrpc.Option{
+//		oidcgrpc.SkipMethods("/grpc.health.v1.Health/Check"),
+//		oidcgrpc.ForMethod("/users.v1.Users/Delete", oidcgrpc.Requirements{Scopes: []string{"admin"}}),
+//	}
+//	s := grpc.NewServer(
+//		grpc.UnaryInterceptor(oidcgrpc.UnaryServerInterceptor(verifier, opts...)),
+//		grpc.StreamInterceptor(oidcgrpc.StreamServerInterceptor(verifier, opts...)),
+//	)
+//
+// Handlers read the verified token with TokenFromContext.
+package oidcgrpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Requirements are checked against a verified token in addition to the
+// verifier's own checks.
+type Requirements struct {
+	// Audience, if set, requires the token's audience to include one of these
+	// values.
+	Audience []string
+	// Scopes requires the token to be granted all of these scopes, read with
+	// IDToken.Scopes.
+	Scopes []string
+}
+
+// Option configures the interceptors.
+type Option func(*interceptor)
+
+// RequireScopes requires tokens of every method to be granted the scopes.
+func RequireScopes(scopes ...string) Option {
+	return func(i *interceptor) {
+		i.defaults.Scopes = append(i.defaults.Scopes, scopes...)
+	}
+}
+
+// RequireAudience requires tokens of every method to have one of the
+// audiences.
+func RequireAudience(audience ...string) Option {
+	return func(i *interceptor) {
+		i.defaults.Audience = append(i.defaults.Audience, audience...)
+	}
+}
+
+// ForMethod adds requirements for a method, named in the "/package.Service/Method"
+// form of grpc.UnaryServerInfo.FullMethod. They apply in addition to those of
+// RequireScopes and RequireAudience.
+func ForMethod(fullMethod string, req Requirements) Option {
+	return func(i *interceptor) {
+		i.methods[fullMethod] = req
+	}
+}
+
+// SkipMethods lets calls to the methods through without a token, such as
+// health checks.
+func SkipMethods(fullMethods ...string) Option {
+	return func(i *interceptor) {
+		for _, m := range fullMethods {
+			i.skip[m] = true
+		}
+	}
+}
+
+type interceptor struct {
+	verifier oidc.TokenVerifier
+	defaults Requirements
+	methods  map[string]Requirements
+	skip     map[string]bool
+}
+
+func newInterceptor(verifier oidc.TokenVerifier, opts []Option) *interceptor {
+	i := &interceptor{
+		verifier: verifier,
+		methods:  make(map[string]Requirements),
+		skip:     make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// UnaryServerInterceptor returns an interceptor authenticating unary calls.
+// Calls without a valid token fail with codes.Unauthenticated, and calls whose
+// token doesn't meet the method's requirements with codes.PermissionDenied. If
+// the provider is unavailable, calls fail with codes.Unavailable.
+func UnaryServerInterceptor(verifier oidc.TokenVerifier, opts ...Option) grpc.UnaryServerInterceptor {
+	i := newInterceptor(verifier, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := i.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor authenticating streaming calls
+// like UnaryServerInterceptor.
+func StreamServerInterceptor(verifier oidc.TokenVerifier, opts ...Option) grpc.StreamServerInterceptor {
+	i := newInterceptor(verifier, opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := i.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream overrides the context of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+type tokenKey struct{}
+
+// TokenFromContext returns the token verified by the interceptors.
+func TokenFromContext(ctx context.Context) (*oidc.IDToken, bool) {
+	token, ok := ctx.Value(tokenKey{}).(*oidc.IDToken)
+	return token, ok
+}
+
+// authenticate verifies the token of a call and returns a context carrying it.
+// Errors are gRPC status errors, which don't include verification details.
+func (i *interceptor) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if i.skip[method] {
+		return ctx, nil
+	}
+	raw, ok := bearerToken(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	token, err := i.verifier.Verify(ctx, raw)
+	if err != nil {
+		var unavailable *oidc.ProviderUnavailableError
+		if errors.As(err, &unavailable) {
+			return nil, status.Error(codes.Unavailable, "unable to verify token")
+		}
+		return nil, status.Error(codes.Unauthenticated, "token is invalid")
+	}
+	if err := check(token, i.defaults); err != nil {
+		return nil, err
+	}
+	if req, ok := i.methods[method]; ok {
+		if err := check(token, req); err != nil {
+			return nil, err
+		}
+	}
+	return context.WithValue(ctx, tokenKey{}, token), nil
+}
+
+// check returns a status error if the token doesn't meet the requirements.
+func check(token *oidc.IDToken, req Requirements) error {
+	if len(req.Audience) > 0 && !containsAny(token.Audience, req.Audience) {
+		return status.Error(codes.PermissionDenied, "token has an unexpected audience")
+	}
+	if len(req.Scopes) > 0 {
+		granted, err := token.Scopes()
+		if err != nil {
+			return status.Error(codes.Unauthenticated, "token has malformed scopes")
+		}
+		if !granted.ContainsAll(req.Scopes...) {
+			return status.Errorf(codes.PermissionDenied, "token lacks required scopes: %s", strings.Join(req.Scopes, " "))
+		}
+	}
+	return nil
+}
+
+func containsAny(values, want []string) bool {
+	for _, v := range values {
+		for _, w := range want {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bearerToken returns the token of the call's "authorization" metadata. The
+// scheme is case insensitive.
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	for _, v := range md.Get("authorization") {
+		scheme, token, ok := strings.Cut(v, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			continue
+		}
+		if token = strings.TrimSpace(token); token != "" {
+			return token, true
+		}
+	}
+	return "", false
+}
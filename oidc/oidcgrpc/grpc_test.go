@@ -0,0 +1,129 @@
+package oidcgrpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	jose "github.com/go-jose/go-jose/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newTestVerifier(t *testing.T) (oidc.TokenVerifier, func(claims string) string) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: priv}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(claims string) string {
+		jws, err := signer.Sign([]byte(claims))
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := jws.CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	keySet := &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{priv.Public()}}
+	verifier := oidc.NewVerifier("https://foo", keySet, &oidc.Config{
+		SkipClientIDCheck:    true,
+		SupportedSigningAlgs: []string{oidc.ES256},
+	})
+	return verifier, sign
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	verifier, sign := newTestVerifier(t)
+	reader := sign(`{"iss":"https://foo","aud":"api","sub":"jane","exp":4102444800,"scope":"read"}`)
+	admin := sign(`{"iss":"https://foo","aud":"api","sub":"jane","exp":4102444800,"scope":"read admin"}`)
+	other := sign(`{"iss":"https://foo","aud":"other","sub":"jane","exp":4102444800,"scope":"read admin"}`)
+
+	interceptor := UnaryServerInterceptor(verifier,
+		RequireAudience("api"),
+		RequireScopes("read"),
+		ForMethod("/svc/Delete", Requirements{Scopes: []string{"admin"}}),
+		SkipMethods("/svc/Health"),
+	)
+
+	tests := []struct {
+		name     string
+		method   string
+		auth     string
+		wantCode codes.Code
+	}{
+		{name: "read", method: "/svc/Get", auth: "Bearer " + reader, wantCode: codes.OK},
+		{name: "lowercase scheme", method: "/svc/Get", auth: "bearer " + reader, wantCode: codes.OK},
+		{name: "admin", method: "/svc/Delete", auth: "Bearer " + admin, wantCode: codes.OK},
+		{name: "missing method scope", method: "/svc/Delete", auth: "Bearer " + reader, wantCode: codes.PermissionDenied},
+		{name: "wrong audience", method: "/svc/Get", auth: "Bearer " + other, wantCode: codes.PermissionDenied},
+		{name: "invalid token", method: "/svc/Get", auth: "Bearer garbage", wantCode: codes.Unauthenticated},
+		{name: "no token", method: "/svc/Get", wantCode: codes.Unauthenticated},
+		{name: "skipped", method: "/svc/Health", wantCode: codes.OK},
+	}
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.auth != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", test.auth))
+		}
+		var subject string
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			if token, ok := TokenFromContext(ctx); ok {
+				subject = token.Subject
+			}
+			return "resp", nil
+		}
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: test.method}, handler)
+		if got := status.Code(err); got != test.wantCode {
+			t.Errorf("%s: got code %v, want %v", test.name, got, test.wantCode)
+			continue
+		}
+		if test.wantCode == codes.OK && test.auth != "" && subject != "jane" {
+			t.Errorf("%s: handler got subject %q, want jane", test.name, subject)
+		}
+	}
+}
+
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	verifier, sign := newTestVerifier(t)
+	raw := sign(`{"iss":"https://foo","aud":"api","sub":"jane","exp":4102444800}`)
+	interceptor := StreamServerInterceptor(verifier)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+raw))
+	err := interceptor(nil, &testStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/svc/Watch"}, func(srv interface{}, ss grpc.ServerStream) error {
+		if token, ok := TokenFromContext(ss.Context()); !ok || token.Subject != "jane" {
+			t.Errorf("stream context has no token")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = interceptor(nil, &testStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/svc/Watch"}, func(srv interface{}, ss grpc.ServerStream) error {
+		t.Error("handler called without a token")
+		return nil
+	})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("got %v, want Unauthenticated", err)
+	}
+}
@@ -0,0 +1,182 @@
+// Package oidcmetrics records metrics about token verification, key set
+// fetches and discovery, using the hooks of oidc.Observer, and exports them
+// with the Prometheus client library. It's a separate module so the core
+// module doesn't depend on the Prometheus client library.
+//
+//	metrics := oidcmetrics.New()
+//	prometheus.MustRegister(metrics)
+//	ctx := oidc.ObserverContext(context.Background(), metrics.Observer())
+//	provider, err := oidc.NewProvider(ctx, "https://accounts.example.com")
+//	if err != nil {
+//		// handle error
+//	}
+//	verifier := provider.Verifier(&oidc.Config{ClientID: clientID})
+//
+// The following metrics are recorded:
+//
+//	oidc_verifications_total{issuer,alg,result}           counter
+//	oidc_jwks_fetch_duration_seconds{url,result}          histogram
+//	oidc_jwks_keys{url}                                   gauge
+//	oidc_discovery_requests_total{issuer,result}          counter
+//	oidc_legacy_issuer_tokens_total{issuer,legacy_issuer} counter
+package oidcmetrics
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of the key
+// set fetch latency histogram.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Results of verifications and requests, used as the "result" label.
+const (
+	ResultOK              = "ok"
+	ResultExpired         = "expired"
+	ResultInvalidIssuer   = "invalid_issuer"
+	ResultInvalidAudience = "invalid_audience"
+	ResultMalformed       = "malformed"
+	ResultRateLimited     = "rate_limited"
+	ResultError           = "error"
+)
+
+// algorithms bounds the values of the "alg" label, which is taken from tokens
+// before they're verified.
+var algorithms = map[string]bool{
+	oidc.RS256: true, oidc.RS384: true, oidc.RS512: true,
+	oidc.ES256: true, oidc.ES384: true, oidc.ES512: true,
+	oidc.PS256: true, oidc.PS384: true, oidc.PS512: true,
+	oidc.EdDSA: true,
+	"HS256":    true, "HS384": true, "HS512": true,
+	"none": true,
+}
+
+// Metrics records the events of an oidc.Observer. It's safe for concurrent
+// use, and implements prometheus.Collector, so it's exported by registering
+// it with a prometheus.Registerer.
+type Metrics struct {
+	verifications *prometheus.CounterVec
+	fetches       *prometheus.HistogramVec
+	keys          *prometheus.GaugeVec
+	discoveries   *prometheus.CounterVec
+	legacy        *prometheus.CounterVec
+}
+
+// New returns metrics using DefaultBuckets.
+func New() *Metrics {
+	return NewWithBuckets(DefaultBuckets)
+}
+
+// NewWithBuckets returns metrics whose latency histogram uses the upper
+// bounds, in seconds, of the buckets. The bounds must be sorted in increasing
+// order.
+func NewWithBuckets(buckets []float64) *Metrics {
+	return &Metrics{
+		verifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oidc_verifications_total",
+			Help: "ID token verifications by issuer, signing algorithm and result.",
+		}, []string{"issuer", "alg", "result"}),
+		fetches: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "oidc_jwks_fetch_duration_seconds",
+			Help:    "Latency of requests to remote key sets.",
+			Buckets: buckets,
+		}, []string{"url", "result"}),
+		keys: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "oidc_jwks_keys",
+			Help: "Number of keys cached from remote key sets.",
+		}, []string{"url"}),
+		discoveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oidc_discovery_requests_total",
+			Help: "Provider discovery requests by issuer and result.",
+		}, []string{"issuer", "result"}),
+		legacy: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oidc_legacy_issuer_tokens_total",
+			Help: "Tokens accepted with a legacy issuer, by verifier issuer and legacy issuer.",
+		}, []string{"issuer", "legacy_issuer"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.verifications.Describe(ch)
+	m.fetches.Describe(ch)
+	m.keys.Describe(ch)
+	m.discoveries.Describe(ch)
+	m.legacy.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.verifications.Collect(ch)
+	m.fetches.Collect(ch)
+	m.keys.Collect(ch)
+	m.discoveries.Collect(ch)
+	m.legacy.Collect(ch)
+}
+
+// Observer returns the hooks recording the metrics. Pass it to
+// oidc.ObserverContext, or set it as oidc.Config.Observer.
+func (m *Metrics) Observer() *oidc.Observer {
+	return &oidc.Observer{
+		Verification: m.observeVerification,
+		KeySetFetch:  m.observeKeySetFetch,
+		Discovery:    m.observeDiscovery,
+		LegacyIssuer: m.observeLegacyIssuer,
+	}
+}
+
+func (m *Metrics) observeVerification(e oidc.VerificationEvent) {
+	alg := e.Algorithm
+	if !algorithms[alg] {
+		alg = "unknown"
+	}
+	m.verifications.WithLabelValues(e.Issuer, alg, Result(e.Err)).Inc()
+}
+
+func (m *Metrics) observeKeySetFetch(e oidc.KeySetFetchEvent) {
+	m.fetches.WithLabelValues(e.URL, Result(e.Err)).Observe(e.Duration.Seconds())
+	if e.Err == nil {
+		m.keys.WithLabelValues(e.URL).Set(float64(e.Keys))
+	}
+}
+
+func (m *Metrics) observeLegacyIssuer(e oidc.LegacyIssuerEvent) {
+	m.legacy.WithLabelValues(e.Issuer, e.LegacyIssuer).Inc()
+}
+
+func (m *Metrics) observeDiscovery(e oidc.DiscoveryEvent) {
+	m.discoveries.WithLabelValues(e.Issuer, Result(e.Err)).Inc()
+}
+
+// Result returns the "result" label of an error returned by a verification or
+// a request.
+func Result(err error) string {
+	var (
+		expired   *oidc.TokenExpiredError
+		issuer    *oidc.InvalidIssuerError
+		untrusted *oidc.UntrustedIssuerError
+		audience  *oidc.InvalidAudienceError
+		malformed *oidc.MalformedTokenError
+		httpErr   *oidc.HTTPError
+	)
+	switch {
+	case err == nil:
+		return ResultOK
+	case errors.As(err, &expired):
+		return ResultExpired
+	case errors.As(err, &issuer), errors.As(err, &untrusted):
+		return ResultInvalidIssuer
+	case errors.As(err, &audience):
+		return ResultInvalidAudience
+	case errors.As(err, &malformed):
+		return ResultMalformed
+	case errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests:
+		return ResultRateLimited
+	default:
+		return ResultError
+	}
+}
@@ -0,0 +1,102 @@
+package oidcmetrics
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestMetrics(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":"%s/keys"}`, s.URL, s.URL)
+		case "/keys":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: priv.Public(), KeyID: "k1", Algorithm: "ES256", Use: "sig"}}})
+		}
+	}))
+	defer s.Close()
+	sign := func(claims string) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: priv, KeyID: "k1"}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jws, err := signer.Sign([]byte(claims))
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := jws.CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	metrics := New()
+	ctx := oidc.ObserverContext(context.Background(), metrics.Observer())
+	if _, err := oidc.NewProvider(ctx, s.URL+"/missing"); err == nil {
+		t.Fatal("expected discovery of missing issuer to fail")
+	}
+	provider, err := oidc.NewProvider(ctx, s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := provider.Verifier(&oidc.Config{ClientID: "app", SupportedSigningAlgs: []string{oidc.ES256}})
+	for _, aud := range []string{"app", "app", "other"} {
+		verifier.Verify(ctx, sign(fmt.Sprintf(`{"iss":%q,"aud":%q,"sub":"jane","exp":4102444800}`, s.URL, aud)))
+	}
+	verifier.Verify(ctx, sign(fmt.Sprintf(`{"iss":%q,"aud":"app","sub":"jane","exp":1}`, s.URL)))
+	verifier.Verify(ctx, "not a token")
+	migrating := provider.Verifier(&oidc.Config{
+		ClientID:             "app",
+		SupportedSigningAlgs: []string{oidc.ES256},
+		LegacyIssuers:        []oidc.LegacyIssuer{oidc.AcceptIssuerUntil("https://old.example.com", time.Unix(4102444800, 0))},
+	})
+	if _, err := migrating.Verify(ctx, sign(`{"iss":"https://old.example.com","aud":"app","sub":"jane","exp":4102444800}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(metrics); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+	for _, want := range []string{
+		fmt.Sprintf(`oidc_verifications_total{alg="ES256",issuer=%q,result="ok"} 3`, s.URL),
+		fmt.Sprintf(`oidc_verifications_total{alg="ES256",issuer=%q,result="invalid_audience"} 1`, s.URL),
+		fmt.Sprintf(`oidc_verifications_total{alg="ES256",issuer=%q,result="expired"} 1`, s.URL),
+		fmt.Sprintf(`oidc_verifications_total{alg="unknown",issuer=%q,result="malformed"} 1`, s.URL),
+		fmt.Sprintf(`oidc_jwks_fetch_duration_seconds_count{result="ok",url="%s/keys"} 1`, s.URL),
+		fmt.Sprintf(`oidc_jwks_fetch_duration_seconds_bucket{result="ok",url="%s/keys",le="+Inf"} 1`, s.URL),
+		fmt.Sprintf(`oidc_jwks_keys{url="%s/keys"} 1`, s.URL),
+		fmt.Sprintf(`oidc_discovery_requests_total{issuer=%q,result="ok"} 1`, s.URL),
+		fmt.Sprintf(`oidc_discovery_requests_total{issuer="%s/missing",result="error"} 1`, s.URL),
+		fmt.Sprintf(`oidc_legacy_issuer_tokens_total{issuer=%q,legacy_issuer="https://old.example.com"} 1`, s.URL),
+		"# TYPE oidc_jwks_fetch_duration_seconds histogram",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics don't contain %s:\n%s", want, out)
+		}
+	}
+}
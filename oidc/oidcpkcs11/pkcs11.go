@@ -0,0 +1,52 @@
+// Package oidcpkcs11 signs client assertions and DPoP proofs with keys held by
+// a hardware security module or smart card accessed through PKCS#11, so the
+// private key never enters the process. It's a separate module so the core
+// module doesn't depend on cgo.
+//
+//	hsm, err := crypto11.Configure(&crypto11.Config{
+//		Path:       "/usr/lib/softhsm/libsofthsm2.so",
+//		TokenLabel: "oidc",
+//		Pin:        pin,
+//	})
+//	if err != nil {
+//		// handle error
+//	}
+//	defer hsm.Close()
+//	signer, err := oidcpkcs11.NewSigner(hsm, nil, []byte("oidc-client"), oidc.ES256, "key-1")
+//	if err != nil {
+//		// handle error
+//	}
+//	assertion, err := oidc.ClientAssertion(ctx, signer, clientID, provider.Endpoint().TokenURL, 0)
+package oidcpkcs11
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ThalesIgnite/crypto11"
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// KeyFinder finds key pairs on a PKCS#11 token. *crypto11.Context implements
+// it.
+type KeyFinder interface {
+	FindKeyPair(id, label []byte) (crypto11.Signer, error)
+}
+
+// NewSigner returns a signer using the key pair with the CKA_ID id or the
+// CKA_LABEL label, one of which must be set. The JWS algorithm alg must match
+// the key, such as ES256 for P-256 keys. keyID is the "kid" header of signed
+// tokens.
+func NewSigner(keys KeyFinder, id, label []byte, alg, keyID string) (oidc.Signer, error) {
+	if id == nil && label == nil {
+		return nil, errors.New("oidcpkcs11: key pair requires an id or label")
+	}
+	key, err := keys.FindKeyPair(id, label)
+	if err != nil {
+		return nil, fmt.Errorf("oidcpkcs11: find key pair: %w", err)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("oidcpkcs11: no key pair with id %x or label %q", id, label)
+	}
+	return oidc.NewCryptoSigner(key, alg, keyID)
+}
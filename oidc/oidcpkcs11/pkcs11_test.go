@@ -0,0 +1,59 @@
+package oidcpkcs11
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/ThalesIgnite/crypto11"
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// fakeToken holds key pairs by label.
+type fakeToken map[string]crypto11.Signer
+
+func (f fakeToken) FindKeyPair(id, label []byte) (crypto11.Signer, error) {
+	return f[string(label)], nil
+}
+
+type tokenKey struct {
+	*ecdsa.PrivateKey
+}
+
+func (tokenKey) Delete() error { return nil }
+
+func TestSigner(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := fakeToken{"oidc-client": tokenKey{priv}}
+	ctx := context.Background()
+
+	signer, err := NewSigner(token, nil, []byte("oidc-client"), oidc.ES256, "kid1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion, err := oidc.ClientAssertion(ctx, signer, "client", "https://foo/token", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySet := &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{priv.Public()}}
+	verifier := oidc.NewVerifier("client", keySet, &oidc.Config{ClientID: "https://foo/token", SupportedSigningAlgs: []string{oidc.ES256}})
+	if _, err := verifier.Verify(ctx, assertion); err != nil {
+		t.Errorf("verifying assertion: %v", err)
+	}
+
+	if _, err := NewSigner(token, nil, []byte("other"), oidc.ES256, ""); err == nil {
+		t.Errorf("expected error for missing key pair")
+	}
+	if _, err := NewSigner(token, nil, nil, oidc.ES256, ""); err == nil {
+		t.Errorf("expected error without id or label")
+	}
+	if _, err := NewSigner(token, nil, []byte("oidc-client"), oidc.RS256, ""); err == nil {
+		t.Errorf("expected error for algorithm of another key type")
+	}
+}
@@ -0,0 +1,82 @@
+package oidctest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BackChannelLogoutEvent is the member of the "events" claim identifying a
+// logout token.
+const BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// LogoutToken returns a back-channel logout token ending the sessions of the
+// subject, or the session with the sid, at the relying party. At least one of
+// sub and sid must be set. Claims override the standard claims of the token,
+// for testing how a relying party handles malformed logout tokens.
+//
+// See: https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+func (s *Server) LogoutToken(sub, sid string, claims map[string]interface{}) string {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		panic("oidctest: generating jti: " + err.Error())
+	}
+	now := time.Now()
+	c := map[string]interface{}{
+		"iss":    s.URL,
+		"aud":    s.clientID(),
+		"iat":    now.Unix(),
+		"exp":    now.Add(2 * time.Minute).Unix(),
+		"jti":    hex.EncodeToString(jti),
+		"events": map[string]interface{}{BackChannelLogoutEvent: map[string]interface{}{}},
+	}
+	if sub != "" {
+		c["sub"] = sub
+	}
+	if sid != "" {
+		c["sid"] = sid
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+	return s.Sign(c)
+}
+
+// BackChannelLogout sends a logout token to the back-channel logout URI of a
+// relying party, as the provider does when the end user logs out, and fails
+// unless the relying party responds with a 200 OK. The client is the
+// relying party's, such as the Client of its httptest.Server, and defaults to
+// http.DefaultClient.
+//
+//	rp := httptest.NewServer(app)
+//	defer rp.Close()
+//	err := s.BackChannelLogout(ctx, rp.Client(), rp.URL+"/backchannel-logout", s.LogoutToken("alice", sid, nil))
+//
+// See: https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRequest
+func (s *Server) BackChannelLogout(ctx context.Context, client *http.Client, logoutURI, logoutToken string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	form := url.Values{"logout_token": {logoutToken}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, logoutURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("oidctest: creating logout request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("oidctest: sending logout request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("oidctest: logout request failed: %s: %s", resp.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package oidctest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+func TestBackChannelLogout(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	ctx := context.Background()
+
+	p, err := oidc.NewProvider(ctx, s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := p.Verifier(&oidc.Config{ClientID: s.ClientID})
+
+	var loggedOut []string
+	rp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := v.Verify(r.Context(), r.PostFormValue("logout_token"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var claims struct {
+			Events map[string]interface{} `json:"events"`
+			Nonce  *string                `json:"nonce"`
+		}
+		if err := token.Claims(&claims); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, ok := claims.Events[BackChannelLogoutEvent]; !ok || claims.Nonce != nil {
+			http.Error(w, "not a logout token", http.StatusBadRequest)
+			return
+		}
+		loggedOut = append(loggedOut, token.SessionID)
+	}))
+	defer rp.Close()
+
+	if err := s.BackChannelLogout(ctx, rp.Client(), rp.URL, s.LogoutToken("alice", "session-1", nil)); err != nil {
+		t.Fatalf("back-channel logout: %v", err)
+	}
+	if len(loggedOut) != 1 || loggedOut[0] != "session-1" {
+		t.Errorf("expected session-1 to be logged out, got %v", loggedOut)
+	}
+
+	bad := s.LogoutToken("alice", "session-2", map[string]interface{}{"nonce": "n"})
+	if err := s.BackChannelLogout(ctx, rp.Client(), rp.URL, bad); err == nil {
+		t.Error("expected logout token with a nonce to be rejected")
+	}
+}
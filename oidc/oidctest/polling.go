@@ -0,0 +1,224 @@
+package oidctest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Paths of the token and polling flow endpoints served by a Server.
+const (
+	TokenPath                     = "/token"
+	DeviceAuthorizationPath       = "/device/authorize"
+	BackChannelAuthenticationPath = "/bc-authorize"
+)
+
+// Grant types of the polling flows.
+const (
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+	cibaGrantType       = "urn:openid:params:grant-type:ciba"
+)
+
+// pendingRequestLifetime is the expires_in of device and CIBA authorization
+// requests.
+const pendingRequestLifetime = 10 * time.Minute
+
+// pendingAuth is a device or CIBA authorization request waiting for the end
+// user.
+type pendingAuth struct {
+	grantType string
+	userCode  string
+	subject   string
+	scope     string
+	expires   time.Time
+	// approveAt is when the request is approved automatically. Zero leaves
+	// it pending until Approve or Deny.
+	approveAt time.Time
+	approved  bool
+	denied    bool
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("oidctest: generating random string: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Approve approves the device or CIBA authorization request with the user
+// code or auth_req_id, as the end user would on their device. It reports
+// whether the request exists.
+func (s *Server) Approve(id string) bool {
+	return s.decide(id, true)
+}
+
+// Deny denies the device or CIBA authorization request with the user code or
+// auth_req_id, so polling for it fails with access_denied. It reports whether
+// the request exists.
+func (s *Server) Deny(id string) bool {
+	return s.decide(id, false)
+}
+
+func (s *Server) decide(id string, approve bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for code, p := range s.pending {
+		if code == id || (p.userCode != "" && p.userCode == id) {
+			p.approved, p.denied = approve, !approve
+			return true
+		}
+	}
+	return false
+}
+
+// newPending records an authorization request and returns its code.
+func (s *Server) newPending(p *pendingAuth) string {
+	now := time.Now()
+	p.expires = now.Add(pendingRequestLifetime)
+	if s.ApprovalDelay >= 0 {
+		p.approveAt = now.Add(s.ApprovalDelay)
+	}
+	code := randomString(16)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[string]*pendingAuth)
+	}
+	s.pending[code] = p
+	return code
+}
+
+func (s *Server) pollInterval() int {
+	if s.PollInterval <= 0 {
+		return 1
+	}
+	return int((s.PollInterval + time.Second - 1) / time.Second)
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": description})
+}
+
+func (s *Server) serveDeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "device authorization requires POST")
+		return
+	}
+	userCode := strings.ToUpper(randomString(4))
+	userCode = userCode[:4] + "-" + userCode[4:]
+	code := s.newPending(&pendingAuth{
+		grantType: deviceCodeGrantType,
+		userCode:  userCode,
+		subject:   s.subject(),
+		scope:     r.PostFormValue("scope"),
+	})
+	writeJSON(w, map[string]interface{}{
+		"device_code":               code,
+		"user_code":                 userCode,
+		"verification_uri":          s.URL + "/device",
+		"verification_uri_complete": s.URL + "/device?user_code=" + userCode,
+		"expires_in":                int(pendingRequestLifetime / time.Second),
+		"interval":                  s.pollInterval(),
+	})
+}
+
+func (s *Server) serveBackChannelAuthentication(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "authentication request requires POST")
+		return
+	}
+	scope := r.PostFormValue("scope")
+	if !strings.Contains(" "+scope+" ", " openid ") {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "the openid scope is required")
+		return
+	}
+	subject := r.PostFormValue("login_hint")
+	if subject == "" && r.PostFormValue("id_token_hint") == "" && r.PostFormValue("login_hint_token") == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "one of login_hint, id_token_hint or login_hint_token is required")
+		return
+	}
+	if subject == "" {
+		subject = s.subject()
+	}
+	code := s.newPending(&pendingAuth{grantType: cibaGrantType, subject: subject, scope: scope})
+	writeJSON(w, map[string]interface{}{
+		"auth_req_id": code,
+		"expires_in":  int(pendingRequestLifetime / time.Second),
+		"interval":    s.pollInterval(),
+	})
+}
+
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "token requests require POST")
+		return
+	}
+	grantType := r.PostFormValue("grant_type")
+	var code string
+	switch grantType {
+	case deviceCodeGrantType:
+		code = r.PostFormValue("device_code")
+	case cibaGrantType:
+		code = r.PostFormValue("auth_req_id")
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "unsupported grant type "+grantType)
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	p, ok := s.pending[code]
+	if ok && p.grantType != grantType {
+		ok = false
+	}
+	if !ok {
+		s.mu.Unlock()
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "unknown or already used code")
+		return
+	}
+	if !p.approved && !p.denied && !p.approveAt.IsZero() && !now.Before(p.approveAt) {
+		p.approved = true
+	}
+	switch {
+	case !now.Before(p.expires):
+		delete(s.pending, code)
+		s.mu.Unlock()
+		writeOAuthError(w, http.StatusBadRequest, "expired_token", "the request has expired")
+		return
+	case p.denied:
+		delete(s.pending, code)
+		s.mu.Unlock()
+		writeOAuthError(w, http.StatusBadRequest, "access_denied", "the end user denied the request")
+		return
+	case !p.approved:
+		s.mu.Unlock()
+		writeOAuthError(w, http.StatusBadRequest, "authorization_pending", "the end user hasn't approved the request yet")
+		return
+	}
+	delete(s.pending, code)
+	s.mu.Unlock()
+
+	resp := map[string]interface{}{
+		"access_token": randomString(16),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"scope":        p.scope,
+	}
+	if strings.Contains(" "+p.scope+" ", " openid ") {
+		resp["id_token"] = s.IDToken(map[string]interface{}{"sub": p.subject})
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, resp)
+}
+
+func (s *Server) subject() string {
+	sub, _ := s.UserInfo["sub"].(string)
+	return sub
+}
@@ -0,0 +1,113 @@
+package oidctest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+func TestDeviceFlow(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p, err := oidc.NewProvider(ctx, s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &oauth2.Config{ClientID: s.ClientID, Endpoint: p.Endpoint(), Scopes: []string{oidc.ScopeOpenID}}
+
+	s.ApprovalDelay = -1
+	resp, err := config.DeviceAuth(ctx)
+	if err != nil {
+		t.Fatalf("device authorization: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		s.Approve(resp.UserCode)
+	}()
+	token, err := config.DeviceAccessToken(ctx, resp)
+	if err != nil {
+		t.Fatalf("polling for token: %v", err)
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	idToken, err := p.Verifier(&oidc.Config{ClientID: s.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		t.Fatalf("verifying id token: %v", err)
+	}
+	if idToken.Subject != "user" {
+		t.Errorf("expected subject user, got %q", idToken.Subject)
+	}
+
+	resp, err = config.DeviceAuth(ctx)
+	if err != nil {
+		t.Fatalf("device authorization: %v", err)
+	}
+	s.Deny(resp.UserCode)
+	if _, err := config.DeviceAccessToken(ctx, resp); err == nil {
+		t.Error("expected denied request to fail")
+	}
+}
+
+func TestCIBA(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.ApprovalDelay = 500 * time.Millisecond
+
+	post := func(path string, form url.Values) (int, map[string]interface{}) {
+		t.Helper()
+		resp, err := http.PostForm(s.URL+path, form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, body
+	}
+
+	status, body := post(BackChannelAuthenticationPath, url.Values{
+		"client_id":  {s.ClientID},
+		"scope":      {"openid"},
+		"login_hint": {"alice"},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("authentication request failed: %d %v", status, body)
+	}
+	authReqID, _ := body["auth_req_id"].(string)
+
+	poll := url.Values{"grant_type": {cibaGrantType}, "auth_req_id": {authReqID}}
+	if _, body := post(TokenPath, poll); body["error"] != "authorization_pending" {
+		t.Fatalf("expected authorization_pending, got %v", body)
+	}
+	time.Sleep(s.ApprovalDelay)
+	status, body = post(TokenPath, poll)
+	if status != http.StatusOK {
+		t.Fatalf("expected tokens after approval, got %d %v", status, body)
+	}
+	rawIDToken, _ := body["id_token"].(string)
+	unverified, err := oidc.ParseUnverified(rawIDToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unverified.Subject != "alice" {
+		t.Errorf("expected subject from login_hint, got %q", unverified.Subject)
+	}
+	if _, body := post(TokenPath, poll); body["error"] != "invalid_grant" {
+		t.Errorf("expected reused auth_req_id to fail with invalid_grant, got %v", body)
+	}
+
+	if status, _ := post(BackChannelAuthenticationPath, url.Values{"scope": {"openid"}}); status != http.StatusBadRequest {
+		t.Errorf("expected request without a hint to fail, got %d", status)
+	}
+}
@@ -0,0 +1,98 @@
+package oidctest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+func TestRecordingTransport(t *testing.T) {
+	var requests int32
+	mux := http.NewServeMux()
+	s := httptest.NewServer(mux)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "tracking=1")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 s.URL,
+			"authorization_endpoint": s.URL + "/auth",
+			"token_endpoint":         s.URL + "/token",
+			"jwks_uri":               s.URL + "/keys",
+			"userinfo_endpoint":      s.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"sub":"alice","email":"alice@example.com"}`))
+	})
+
+	dir := t.TempDir()
+	ctx := context.Background()
+	userInfo := func(p *oidc.Provider) (*oidc.UserInfo, error) {
+		return p.UserInfo(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "secret-token"}))
+	}
+
+	rec := &RecordingTransport{Dir: dir, Mode: Record}
+	p, err := oidc.NewProvider(ctx, s.URL, oidc.WithTransport(rec))
+	if err != nil {
+		t.Fatalf("recording discovery: %v", err)
+	}
+	if _, err := userInfo(p); err != nil {
+		t.Fatalf("recording userinfo: %v", err)
+	}
+	s.Close()
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Fatalf("expected 2 requests while recording, got %d", got)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 fixtures, got %d", len(entries))
+	}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(b), "secret-token") || strings.Contains(string(b), "tracking") {
+			t.Errorf("fixture %s contains request credentials or cookies:\n%s", e.Name(), b)
+		}
+	}
+
+	replay := &RecordingTransport{Dir: dir}
+	p, err = oidc.NewProvider(ctx, s.URL, oidc.WithTransport(replay))
+	if err != nil {
+		t.Fatalf("replaying discovery: %v", err)
+	}
+	info, err := userInfo(p)
+	if err != nil {
+		t.Fatalf("replaying userinfo: %v", err)
+	}
+	if info.Subject != "alice" || info.Email != "alice@example.com" {
+		t.Errorf("unexpected replayed userinfo: %+v", info)
+	}
+
+	req, _ := http.NewRequest("GET", s.URL+"/keys", nil)
+	if _, err := replay.RoundTrip(req); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("expected missing fixture error, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package oidctest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// IntrospectionPath is the path of the token introspection endpoint served by
+// a Server.
+const IntrospectionPath = "/introspect"
+
+// ReferenceToken mints an opaque reference token, whose claims are only
+// available through the introspection endpoint. The claims default like those
+// of IDToken.
+func (s *Server) ReferenceToken(claims map[string]interface{}) string {
+	now := time.Now()
+	lifetime := s.TokenLifetime
+	if lifetime <= 0 {
+		lifetime = time.Hour
+	}
+	c := s.withDefaults(claims, now, now.Add(lifetime))
+	token := randomString(32)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.references[token] = c
+	return token
+}
+
+// RevokeReferenceToken revokes a reference token, so the introspection
+// endpoint reports it as inactive.
+func (s *Server) RevokeReferenceToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.references[token]; ok {
+		s.references[token] = nil
+	}
+}
+
+// serveIntrospection answers introspection requests for reference tokens, as
+// described by RFC 7662. Requests accepting oidc.IntrospectionJWTMediaType
+// get a signed response addressed to the requesting client, as described by
+// RFC 9701. Clients aren't authenticated.
+func (s *Server) serveIntrospection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	client, _, ok := r.BasicAuth()
+	if !ok {
+		client = r.PostFormValue("client_id")
+	}
+	if client == "" {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "client isn't identified")
+		return
+	}
+
+	response := map[string]interface{}{"active": false}
+	s.mu.Lock()
+	claims := s.references[r.PostFormValue("token")]
+	s.mu.Unlock()
+	if claims != nil && time.Now().Unix() < unixTime(claims["exp"]) {
+		response = map[string]interface{}{"active": true, "token_type": "Bearer"}
+		for k, v := range claims {
+			response[k] = v
+		}
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), oidc.IntrospectionJWTMediaType) {
+		writeJSON(w, response)
+		return
+	}
+	s.mu.Lock()
+	signer := s.keys[len(s.keys)-1].signer
+	s.mu.Unlock()
+	jwt, err := oidc.SignIntrospectionResponse(r.Context(), signer, s.URL, client, response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", oidc.IntrospectionJWTMediaType)
+	w.Write([]byte(jwt))
+}
+
+// unixTime returns the value of a NumericDate claim set by tests, or zero.
+func unixTime(v interface{}) int64 {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package oidctest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+func TestReferenceToken(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	ctx := context.Background()
+
+	p, err := oidc.NewProvider(ctx, s.URL)
+	if err != nil {
+		t.Fatalf("discovery: %v", err)
+	}
+	gateway, err := p.IntrospectionVerifier(&oidc.IntrospectionConfig{
+		ClientID:               "gateway",
+		RequireSignedResponses: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := oidc.NewIntrospectionJWTVerifier(&oidc.IntrospectionConfig{
+		ClientID:             "gateway",
+		Issuer:               s.URL,
+		KeySet:               oidc.NewRemoteKeySet(ctx, s.URL+KeysPath),
+		SupportedSigningAlgs: []string{oidc.ES256},
+		Audience:             s.ClientID,
+	})
+
+	ref := s.ReferenceToken(map[string]interface{}{"sub": "alice", "scope": "read"})
+	token, err := gateway.Verify(ctx, ref)
+	if err != nil {
+		t.Fatalf("introspecting reference token: %v", err)
+	}
+	forwarded, err := service.Verify(ctx, token.Raw())
+	if err != nil {
+		t.Fatalf("verifying forwarded response: %v", err)
+	}
+	if forwarded.Subject != "alice" {
+		t.Errorf("expected subject alice, got %q", forwarded.Subject)
+	}
+
+	plain := oidc.NewIntrospectionVerifier(s.URL+IntrospectionPath, &oidc.IntrospectionConfig{ClientID: "rs"})
+	if token, err := plain.Verify(ctx, ref); err != nil || token.Raw() != "" {
+		t.Errorf("expected unsigned introspection response, got %v", err)
+	}
+
+	s.RevokeReferenceToken(ref)
+	if _, err := gateway.Verify(ctx, ref); !errors.Is(err, oidc.ErrInactiveToken) {
+		t.Errorf("expected inactive token error, got %v", err)
+	}
+	if _, err := gateway.Verify(ctx, "made-up"); !errors.Is(err, oidc.ErrInactiveToken) {
+		t.Errorf("expected inactive token error, got %v", err)
+	}
+}
@@ -0,0 +1,383 @@
+package oidctest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// Paths of the endpoints served by a Server.
+const (
+	DiscoveryPath = "/.well-known/openid-configuration"
+	KeysPath      = "/keys"
+	UserInfoPath  = "/userinfo"
+)
+
+// DefaultClientID is the audience of tokens minted by a Server whose ClientID
+// isn't set.
+const DefaultClientID = "oidctest-client"
+
+// Server is a mock OpenID Connect provider for tests. It serves a discovery
+// document, a key set, userinfo and token endpoints, and mints tokens signed with
+// its keys, so code using the oidc package can be tested without a real
+// provider:
+//
+//	s := oidctest.NewServer()
+//	defer s.Close()
+//
+//	provider, err := oidc.NewProvider(ctx, s.URL)
+//	if err != nil {
+//		// handle error
+//	}
+//	verifier := provider.Verifier(&oidc.Config{ClientID: s.ClientID})
+//	idToken, err := verifier.Verify(ctx, s.IDToken(map[string]interface{}{"sub": "alice"}))
+//
+// Failures of real providers, such as slow or failing endpoints, truncated key
+// sets and key rotations, can be injected with Inject and RotateKeys, to test
+// how code copes with them. LogoutToken and BackChannelLogout drive the
+// back-channel logout endpoint of a relying party.
+//
+// The token endpoint supports the device authorization grant and CIBA in poll
+// mode, whose requests the end user approves after ApprovalDelay, or through
+// Approve and Deny.
+//
+// ReferenceToken mints opaque tokens for the introspection endpoint, which
+// answers with plain or signed JWT responses, to test resource servers and
+// gateways implementing the phantom token pattern.
+type Server struct {
+	// URL is the issuer URL of the server, the base URL of its endpoints.
+	URL string
+	// ClientID is the audience of minted tokens. Defaults to DefaultClientID.
+	ClientID string
+	// UserInfo holds the claims returned by the userinfo endpoint. Defaults
+	// to a "sub" claim of "user".
+	UserInfo map[string]interface{}
+	// TokenLifetime is the lifetime of minted tokens. Defaults to an hour.
+	TokenLifetime time.Duration
+	// ApprovalDelay is how long device and CIBA authorization requests stay
+	// pending before the end user approves them. Zero approves them on the
+	// first poll, and a negative delay leaves them pending until Approve or
+	// Deny.
+	ApprovalDelay time.Duration
+	// PollInterval is the polling interval returned for device and CIBA
+	// authorization requests, rounded up to whole seconds. Defaults to one
+	// second.
+	PollInterval time.Duration
+
+	srv *httptest.Server
+	mux *http.ServeMux
+
+	mu       sync.Mutex
+	keys     []*serverKey
+	faults   []*Fault
+	requests map[string]int
+	pending  map[string]*pendingAuth
+	// references holds the claims of minted reference tokens, nil once
+	// revoked.
+	references map[string]map[string]interface{}
+}
+
+// serverKey is a signing key of a Server. The last key of Server.keys signs
+// new tokens.
+type serverKey struct {
+	signer oidc.Signer
+	jwk    jose.JSONWebKey
+}
+
+// NewServer starts a server with a fresh ES256 signing key. It must be closed
+// with Close.
+func NewServer() *Server {
+	s := &Server{
+		ClientID: DefaultClientID,
+		UserInfo: map[string]interface{}{"sub": "user"},
+		mux:      http.NewServeMux(),
+		requests: make(map[string]int),
+		pending:  make(map[string]*pendingAuth),
+
+		references: make(map[string]map[string]interface{}),
+	}
+	s.keys = []*serverKey{newServerKey()}
+	s.mux.HandleFunc(DiscoveryPath, s.serveDiscovery)
+	s.mux.HandleFunc(KeysPath, s.serveKeys)
+	s.mux.HandleFunc(UserInfoPath, s.serveUserInfo)
+	s.mux.HandleFunc(TokenPath, s.serveToken)
+	s.mux.HandleFunc(DeviceAuthorizationPath, s.serveDeviceAuthorization)
+	s.mux.HandleFunc(BackChannelAuthenticationPath, s.serveBackChannelAuthentication)
+	s.mux.HandleFunc(IntrospectionPath, s.serveIntrospection)
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns an HTTP client for the server, see oidc.ClientContext.
+func (s *Server) Client() *http.Client {
+	return s.srv.Client()
+}
+
+func newServerKey() *serverKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic("oidctest: generating key: " + err.Error())
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		panic("oidctest: generating key id: " + err.Error())
+	}
+	kid := hex.EncodeToString(id)
+	signer, err := oidc.NewCryptoSigner(key, oidc.ES256, kid)
+	if err != nil {
+		panic("oidctest: " + err.Error())
+	}
+	return &serverKey{
+		signer: signer,
+		jwk:    jose.JSONWebKey{Key: key.Public(), KeyID: kid, Algorithm: oidc.ES256, Use: "sig"},
+	}
+}
+
+// RotateKeys replaces the signing key with a new one, with a new key ID. If
+// keepOld is true, the replaced keys are still served, as providers do during
+// a graceful rotation; otherwise tokens signed with them no longer verify once
+// the key set is fetched again.
+func (s *Server) RotateKeys(keepOld bool) {
+	key := newServerKey()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if keepOld {
+		s.keys = append(s.keys, key)
+	} else {
+		s.keys = []*serverKey{key}
+	}
+}
+
+// KeyID returns the key ID of the current signing key.
+func (s *Server) KeyID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[len(s.keys)-1].jwk.KeyID
+}
+
+// Sign signs the claims as a JWT with the current signing key, as is.
+func (s *Server) Sign(claims interface{}) string {
+	s.mu.Lock()
+	signer := s.keys[len(s.keys)-1].signer
+	s.mu.Unlock()
+	token, err := oidc.SignJWT(context.Background(), signer, claims)
+	if err != nil {
+		panic("oidctest: signing token: " + err.Error())
+	}
+	return token
+}
+
+// IDToken returns an ID Token with the given claims. The "iss", "aud", "iat"
+// and "exp" claims are set to the server's URL and ClientID, and the current
+// time and TokenLifetime, unless the claims set them, and "sub" defaults to
+// the subject of UserInfo.
+func (s *Server) IDToken(claims map[string]interface{}) string {
+	now := time.Now()
+	lifetime := s.TokenLifetime
+	if lifetime <= 0 {
+		lifetime = time.Hour
+	}
+	return s.Sign(s.withDefaults(claims, now, now.Add(lifetime)))
+}
+
+// ExpiredIDToken returns an ID Token like IDToken, that expired a minute ago
+// unless the claims set "exp".
+func (s *Server) ExpiredIDToken(claims map[string]interface{}) string {
+	now := time.Now()
+	lifetime := s.TokenLifetime
+	if lifetime <= 0 {
+		lifetime = time.Hour
+	}
+	expiry := now.Add(-time.Minute)
+	return s.Sign(s.withDefaults(claims, expiry.Add(-lifetime), expiry))
+}
+
+// withDefaults returns a copy of the claims with the standard claims of minted
+// tokens set.
+func (s *Server) withDefaults(claims map[string]interface{}, issued, expiry time.Time) map[string]interface{} {
+	c := map[string]interface{}{
+		"iss": s.URL,
+		"aud": s.clientID(),
+		"sub": s.UserInfo["sub"],
+		"iat": issued.Unix(),
+		"exp": expiry.Unix(),
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+	return c
+}
+
+func (s *Server) clientID() string {
+	if s.ClientID == "" {
+		return DefaultClientID
+	}
+	return s.ClientID
+}
+
+// Requests returns the number of requests received for the path, including
+// those failed by injected faults.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// Fault is a failure injected into the responses of a Server.
+type Fault struct {
+	// Path is the endpoint the fault applies to, such as KeysPath. Empty
+	// applies to all endpoints.
+	Path string
+	// Times is the number of requests the fault applies to, such as the
+	// length of a burst of errors. Zero applies it until ClearFaults.
+	Times int
+	// Delay delays the response, or the error response.
+	Delay time.Duration
+	// Status fails the request with the status code, such as
+	// http.StatusTooManyRequests or http.StatusInternalServerError.
+	Status int
+	// RetryAfter sets the Retry-After header of failed requests.
+	RetryAfter time.Duration
+	// Truncate cuts the response body in half, as a connection dropped
+	// mid-response or a misbehaving proxy would.
+	Truncate bool
+}
+
+// Inject adds a fault. Faults apply in the order they were injected, at most
+// one to each request.
+//
+//	// The key set endpoint fails three times with a 503, then recovers.
+//	s.Inject(oidctest.Fault{Path: oidctest.KeysPath, Times: 3, Status: http.StatusServiceUnavailable})
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// fault counts a request for the path, and returns the fault that applies to
+// it, if any.
+func (s *Server) fault(path string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[path]++
+	for i, f := range s.faults {
+		if f.Path != "" && f.Path != path {
+			continue
+		}
+		applied := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return &applied
+	}
+	return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f := s.fault(r.URL.Path)
+	if f == nil {
+		s.mux.ServeHTTP(w, r)
+		return
+	}
+	if f.Delay > 0 {
+		t := time.NewTimer(f.Delay)
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			t.Stop()
+			return
+		}
+	}
+	if f.Status != 0 {
+		if f.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int((f.RetryAfter+time.Second-1)/time.Second)))
+		}
+		http.Error(w, http.StatusText(f.Status), f.Status)
+		return
+	}
+	if f.Truncate {
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, r)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		body := rec.Body.Bytes()
+		w.Write(body[:len(body)/2])
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"issuer":                                     s.URL,
+		"authorization_endpoint":                     s.URL + "/authorize",
+		"jwks_uri":                                   s.URL + KeysPath,
+		"userinfo_endpoint":                          s.URL + UserInfoPath,
+		"token_endpoint":                             s.URL + TokenPath,
+		"device_authorization_endpoint":              s.URL + DeviceAuthorizationPath,
+		"backchannel_authentication_endpoint":        s.URL + BackChannelAuthenticationPath,
+		"introspection_endpoint":                     s.URL + IntrospectionPath,
+		"introspection_signing_alg_values_supported": []string{oidc.ES256},
+		"backchannel_token_delivery_modes_supported": []string{"poll"},
+		"grant_types_supported":                      []string{deviceCodeGrantType, cibaGrantType},
+		"id_token_signing_alg_values_supported":      []string{oidc.ES256},
+		"response_types_supported":                   []string{"code"},
+		"subject_types_supported":                    []string{"public"},
+		"backchannel_logout_supported":               true,
+		"backchannel_logout_session_supported":       true,
+	})
+}
+
+func (s *Server) serveKeys(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	var jwks jose.JSONWebKeySet
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		jwks.Keys = append(jwks.Keys, s.keys[i].jwk)
+	}
+	s.mu.Unlock()
+	writeJSON(w, jwks)
+}
+
+func (s *Server) serveUserInfo(w http.ResponseWriter, r *http.Request) {
+	if len(r.Header.Get("Authorization")) <= len("Bearer ") {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "missing access token", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, s.UserInfo)
+}
@@ -0,0 +1,93 @@
+package oidctest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+func TestServer(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	ctx := context.Background()
+
+	p, err := oidc.NewProvider(ctx, s.URL)
+	if err != nil {
+		t.Fatalf("discovery: %v", err)
+	}
+	v := p.Verifier(&oidc.Config{ClientID: s.ClientID})
+
+	token, err := v.Verify(ctx, s.IDToken(map[string]interface{}{"sub": "alice"}))
+	if err != nil {
+		t.Fatalf("verifying minted token: %v", err)
+	}
+	if token.Subject != "alice" {
+		t.Errorf("expected subject alice, got %q", token.Subject)
+	}
+
+	var expired *oidc.TokenExpiredError
+	if _, err := v.Verify(ctx, s.ExpiredIDToken(nil)); !errors.As(err, &expired) {
+		t.Errorf("expected expired token error, got %v", err)
+	}
+}
+
+func TestServerFaults(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	ctx := context.Background()
+
+	s.Inject(Fault{Path: DiscoveryPath, Times: 2, Status: http.StatusTooManyRequests, RetryAfter: time.Second})
+	for i := 0; i < 2; i++ {
+		if _, err := oidc.NewProvider(ctx, s.URL); err == nil {
+			t.Fatalf("discovery %d: expected error during burst", i)
+		}
+	}
+	p, err := oidc.NewProvider(ctx, s.URL)
+	if err != nil {
+		t.Fatalf("discovery after burst: %v", err)
+	}
+	if got := s.Requests(DiscoveryPath); got != 3 {
+		t.Errorf("expected 3 discovery requests, got %d", got)
+	}
+
+	v := p.Verifier(&oidc.Config{ClientID: s.ClientID})
+	s.Inject(Fault{Path: KeysPath, Times: 1, Truncate: true})
+	if _, err := v.Verify(ctx, s.IDToken(nil)); err == nil {
+		t.Fatal("expected truncated key set to fail verification")
+	}
+	if _, err := v.Verify(ctx, s.IDToken(nil)); err != nil {
+		t.Fatalf("verify after truncated key set: %v", err)
+	}
+
+	old := s.IDToken(nil)
+	oldKeyID := s.KeyID()
+	s.RotateKeys(false)
+	if s.KeyID() == oldKeyID {
+		t.Fatal("expected a new key id after rotation")
+	}
+	if _, err := v.Verify(ctx, s.IDToken(nil)); err != nil {
+		t.Fatalf("verify with rotated key: %v", err)
+	}
+	if _, err := v.Verify(ctx, old); err == nil {
+		t.Error("expected token signed with a dropped key to fail verification")
+	}
+
+	s.Inject(Fault{Delay: time.Second})
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := oidc.NewProvider(ctx, s.URL); err == nil {
+		t.Error("expected delayed discovery to time out")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("delayed request took %v, expected the context to cancel it", d)
+	}
+	s.ClearFaults()
+	if _, err := oidc.NewProvider(context.Background(), s.URL); err != nil {
+		t.Errorf("discovery after clearing faults: %v", err)
+	}
+}
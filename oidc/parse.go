@@ -0,0 +1,202 @@
+package oidc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxHeaderSize is the maximum length, in bytes, of the encoded protected
+// header of a token. Headers of legitimate tokens are a few hundred bytes.
+const maxHeaderSize = 8 << 10
+
+// b64 decodes the segments of compact tokens. Strict decoding rejects
+// encodings with non-zero trailing bits, so each token has a single encoding.
+var b64 = base64.RawURLEncoding.Strict()
+
+// trimToken removes the ASCII whitespace surrounding a token, such as the
+// trailing newline of a token read from a file, which go-jose used to accept.
+// Whitespace within the token is still rejected by checkTokenAlphabet.
+func trimToken(raw string) string {
+	return strings.Trim(raw, " \t\r\n")
+}
+
+// checkTokenAlphabet ensures the compact token only contains base64url
+// characters and the separators. The base64 decoder skips newlines, which would
+// otherwise let different strings decode to the same token.
+func checkTokenAlphabet(raw string) error {
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return fmt.Errorf("oidc: malformed jwt, invalid character %q at offset %d", c, i)
+		}
+	}
+	return nil
+}
+
+var errDuplicateKey = errors.New("duplicate key")
+
+// checkJSON rejects JSON documents that encoding/json would decode in a way
+// other parsers may disagree with, before they're decoded:
+//
+//   - Invalid UTF-8, which encoding/json silently replaces.
+//   - Duplicate keys within an object, which encoding/json resolves by using
+//     the last one while other parsers use the first, unless allowDuplicates
+//     is set. Keys of the outermost object are compared case-insensitively,
+//     since encoding/json matches struct fields case-insensitively.
+//   - Objects and arrays nested deeper than max.
+//
+// The document's syntax isn't otherwise validated; that's left to the decoder.
+func checkJSON(b []byte, max int, allowDuplicates bool) error {
+	if !utf8.Valid(b) {
+		return errors.New("invalid UTF-8 in JSON")
+	}
+	// keys holds the keys seen in each open object, or nil for arrays and
+	// objects without keys so far. isObject tells them apart.
+	var (
+		keys     []map[string]struct{}
+		isObject []bool
+	)
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '"':
+			end := stringEnd(b, i)
+			if end < 0 {
+				return errors.New("unterminated string in JSON")
+			}
+			depth := len(isObject)
+			if !allowDuplicates && depth > 0 && isObject[depth-1] && followedByColon(b, end+1) {
+				key, err := decodeKey(b[i : end+1])
+				if err != nil {
+					return err
+				}
+				if depth == 1 {
+					key = strings.ToLower(key)
+				}
+				if keys[depth-1] == nil {
+					keys[depth-1] = make(map[string]struct{})
+				}
+				if _, ok := keys[depth-1][key]; ok {
+					return fmt.Errorf("%w %q in JSON object", errDuplicateKey, key)
+				}
+				keys[depth-1][key] = struct{}{}
+			}
+			i = end
+		case '{', '[':
+			if len(isObject) >= max {
+				return fmt.Errorf("claims exceed maximum nesting depth of %d", max)
+			}
+			keys = append(keys, nil)
+			isObject = append(isObject, b[i] == '{')
+		case '}', ']':
+			if n := len(isObject); n > 0 {
+				keys = keys[:n-1]
+				isObject = isObject[:n-1]
+			}
+		}
+	}
+	return nil
+}
+
+// stringEnd returns the index of the quote closing the string starting at
+// start, or -1 if it's unterminated.
+func stringEnd(b []byte, start int) int {
+	for i := start + 1; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func followedByColon(b []byte, i int) bool {
+	for ; i < len(b); i++ {
+		switch b[i] {
+		case ' ', '\t', '\n', '\r':
+		case ':':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// decodeKey returns the value of a quoted key, resolving escapes so that
+// "iss" and "\u0069ss" compare equal.
+func decodeKey(quoted []byte) (string, error) {
+	if bytes.IndexByte(quoted, '\\') < 0 {
+		return string(quoted[1 : len(quoted)-1]), nil
+	}
+	var key string
+	if err := json.Unmarshal(quoted, &key); err != nil {
+		return "", fmt.Errorf("malformed key in JSON: %v", err)
+	}
+	return key, nil
+}
+
+// decodeClaims unmarshals claims like json.Unmarshal, except that claims
+// decoded into a generic map use the types registered with RegisterClaimType.
+// If useNumber is set, numbers decoded into interface{} values are json.Number
+// rather than float64.
+func decodeClaims(b []byte, v interface{}, useNumber bool) error {
+	if err := decodeJSON(b, v, useNumber); err != nil {
+		return err
+	}
+	return decodeRegisteredClaims(b, v, useNumber)
+}
+
+// decodeJSON unmarshals like json.Unmarshal, except that if useNumber is set,
+// numbers decoded into interface{} values are json.Number rather than float64.
+func decodeJSON(b []byte, v interface{}, useNumber bool) error {
+	if !useNumber {
+		return json.Unmarshal(b, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid character after top-level value")
+	}
+	return nil
+}
+
+// rangeClaims calls fn with the name and raw value of each member of the
+// payload's top-level object, in order, until fn returns false. Values are
+// only passed to fn once they've been scanned, so a malformed payload stops
+// the iteration.
+func rangeClaims(payload []byte, fn func(name string, value json.RawMessage) bool) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return
+		}
+		name, ok := t.(string)
+		if !ok {
+			return
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return
+		}
+		if !fn(name, value) {
+			return
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{name: "valid", json: `{"iss":"a","aud":["b","c"],"x":{"iss":"d"}}`},
+		{name: "duplicate", json: `{"iss":"a","sub":"b","iss":"c"}`, wantErr: true},
+		{name: "escaped duplicate", json: `{"iss":"a","\u0069ss":"c"}`, wantErr: true},
+		{name: "case duplicate", json: `{"iss":"a","ISS":"c"}`, wantErr: true},
+		{name: "nested case variants", json: `{"x":{"name":"a","Name":"b"}}`},
+		{name: "nested duplicate", json: `{"x":{"a":1,"a":2}}`, wantErr: true},
+		{name: "duplicate in array element", json: `{"x":[{"a":1},{"a":2,"a":3}]}`, wantErr: true},
+		{name: "keys in separate objects", json: `{"x":{"a":1},"y":{"a":2}}`},
+		{name: "string values that look like keys", json: `{"a":"b","c":["b","b"]}`},
+		{name: "whitespace before colon", json: "{\"a\" : 1, \"a\"\n: 2}", wantErr: true},
+		{name: "quote in key", json: `{"a\"":1,"a":2}`},
+		{name: "invalid utf-8", json: "{\"iss\":\"a\xff\"}", wantErr: true},
+		{name: "too deep", json: strings.Repeat("[", DefaultMaxClaimDepth+1), wantErr: true},
+		{name: "unterminated", json: `{"a`, wantErr: true},
+	}
+	for _, test := range tests {
+		if err := checkJSON([]byte(test.json), DefaultMaxClaimDepth, false); (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %t", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestVerifySurroundingWhitespace(t *testing.T) {
+	key := newRSAKey(t)
+	raw := key.sign(t, []byte(`{"iss":"https://foo","aud":"app","sub":"jane","exp":4102444800}`))
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "app"})
+
+	// Tokens read from files or environment variables often end in a newline.
+	for _, padded := range []string{raw + "\n", raw + "\r\n", " " + raw, "\t" + raw + " "} {
+		token, err := verifier.Verify(context.Background(), padded)
+		if err != nil {
+			t.Errorf("verifying %q: %v", padded, err)
+			continue
+		}
+		if token.Raw() != raw {
+			t.Errorf("got raw token %q, want it without whitespace", token.Raw())
+		}
+		if _, err := ParseUnverified(padded); err != nil {
+			t.Errorf("parsing %q: %v", padded, err)
+		}
+		if _, err := verifier.Inspect(context.Background(), padded); err != nil {
+			t.Errorf("inspecting %q: %v", padded, err)
+		}
+	}
+	i := strings.IndexByte(raw, '.')
+	if _, err := verifier.Verify(context.Background(), raw[:i]+"\n"+raw[i:]); err == nil {
+		t.Errorf("expected whitespace within the token to be rejected")
+	}
+}
+
+func TestParseCompactJWSHardening(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{name: "newline", raw: "eyJhbGciOiJSUzI1NiJ9.e30\n.c2ln"},
+		{name: "padding", raw: "eyJhbGciOiJSUzI1NiJ9.e30=.c2ln"},
+		{name: "standard encoding", raw: "eyJhbGciOiJSUzI1NiJ9.e3+/.c2ln"},
+		// "e31" decodes to "{}" with non-zero trailing bits.
+		{name: "non-canonical encoding", raw: "eyJhbGciOiJSUzI1NiJ9.e31.c2ln"},
+	}
+	for _, test := range tests {
+		if _, err := parseCompactJWS(test.raw); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+
+	for name, header := range map[string]string{
+		"duplicate alg": `{"alg":"RS256","alg":"none"}`,
+		"huge header":   `{"alg":"RS256","x":"` + strings.Repeat("a", maxHeaderSize) + `"}`,
+	} {
+		jws, err := parseCompactJWS(b64.EncodeToString([]byte(header)) + ".e30.c2ln")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := jws.decodeHeader(); err == nil {
+			t.Errorf("%s: expected error decoding header", name)
+		}
+		jws.release()
+	}
+}
+
+func TestVerifyDuplicateClaims(t *testing.T) {
+	key := newRSAKey(t)
+	raw := key.sign(t, []byte(`{"iss":"https://foo","aud":"evil","sub":"jane","exp":4102444800,"aud":"app"}`))
+	ctx := context.Background()
+
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "app"})
+	var malformed *MalformedTokenError
+	if _, err := verifier.Verify(ctx, raw); !errors.As(err, &malformed) || !errors.Is(err, errDuplicateKey) {
+		t.Errorf("expected duplicate claims to be rejected, got %v", err)
+	}
+	if _, err := ParseUnverified(raw); !errors.As(err, &malformed) {
+		t.Errorf("expected duplicate claims to be rejected by ParseUnverified, got %v", err)
+	}
+
+	verifier = NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "app", AllowDuplicateClaims: true})
+	if _, err := verifier.Verify(ctx, raw); err != nil {
+		t.Errorf("expected duplicate claims to be allowed, got %v", err)
+	}
+}
+
+func TestClaimsPreserveLargeNumbers(t *testing.T) {
+	key := newRSAKey(t)
+	raw := key.sign(t, []byte(`{"iss":"https://foo","aud":"app","sub":"jane","exp":4102444800,"user_id":1234567890123456789,"ts":1700000000123456789}`))
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "app"})
+	token, err := verifier.Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Claims keeps the semantics of json.Unmarshal.
+	var claims map[string]interface{}
+	if err := token.Claims(&claims); err != nil {
+		t.Fatal(err)
+	}
+	if exp, ok := claims["exp"].(float64); !ok || exp != 4102444800 {
+		t.Errorf("exp: got %#v, want float64", claims["exp"])
+	}
+
+	claims = nil
+	if err := token.ClaimsUseNumber(&claims); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]int64{"user_id": 1234567890123456789, "ts": 1700000000123456789} {
+		n, ok := claims[name].(json.Number)
+		if !ok {
+			t.Fatalf("%s: got %T, want json.Number", name, claims[name])
+		}
+		if got, err := n.Int64(); err != nil || got != want {
+			t.Errorf("%s: got %v (%v), want %d", name, got, err, want)
+		}
+	}
+
+	// Typed fields decode as usual.
+	var typed struct {
+		UserID uint64 `json:"user_id"`
+		Exp    int64  `json:"exp"`
+	}
+	if err := token.Claims(&typed); err != nil || typed.UserID != 1234567890123456789 || typed.Exp != 4102444800 {
+		t.Errorf("unexpected typed claims %+v: %v", typed, err)
+	}
+	unverified, err := ParseUnverified(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims = nil
+	if err := unverified.ClaimsUseNumber(&claims); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := claims["user_id"].(json.Number); !ok {
+		t.Errorf("unverified user_id: got %T, want json.Number", claims["user_id"])
+	}
+	for _, useNumber := range []bool{false, true} {
+		if err := decodeClaims([]byte(`{"a":1} {}`), &claims, useNumber); err == nil {
+			t.Errorf("useNumber=%t: expected trailing data to be rejected", useNumber)
+		}
+	}
+}
+
+func TestClaimNamesAndValue(t *testing.T) {
+	key := newRSAKey(t)
+	raw := key.sign(t, []byte(`{"iss":"https://foo","aud":"app","exp":4102444800, "groups" : ["a","b"],"profile":{"name":"Jane"}}`))
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "app"})
+	token, err := verifier.Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"iss", "aud", "exp", "groups", "profile"}
+	if got := token.ClaimNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected claim names %q, got %q", want, got)
+	}
+	for name, want := range map[string]string{
+		"groups":  `["a","b"]`,
+		"profile": `{"name":"Jane"}`,
+		"exp":     `4102444800`,
+	} {
+		v, ok := token.ClaimValue(name)
+		if !ok || string(v) != want {
+			t.Errorf("%s: expected %s, got %s (%v)", name, want, v, ok)
+		}
+	}
+	if v, ok := token.ClaimValue("name"); ok {
+		t.Errorf("expected nested claim not to be found, got %s", v)
+	}
+
+	// With duplicates allowed, the last value wins as with Claims.
+	verifier = NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "app", AllowDuplicateClaims: true})
+	token, err = verifier.Verify(context.Background(), key.sign(t, []byte(`{"iss":"https://foo","aud":"app","exp":4102444800,"role":"user","role":"admin"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := token.ClaimValue("role"); string(v) != `"admin"` {
+		t.Errorf("expected last duplicate, got %s", v)
+	}
+	if got := token.ClaimNames(); len(got) != 4 {
+		t.Errorf("expected duplicate names to be listed once, got %q", got)
+	}
+}
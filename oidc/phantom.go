@@ -0,0 +1,161 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// IntrospectionJWTMediaType is the media type of signed JWT introspection
+// responses. Its subtype is the "typ" header of the responses.
+//
+// See: https://www.rfc-editor.org/rfc/rfc9701
+const IntrospectionJWTMediaType = "application/token-introspection+jwt"
+
+// introspectionJWTType is the "typ" header of signed introspection responses.
+const introspectionJWTType = "token-introspection+jwt"
+
+// DefaultMaxIntrospectionResponseAge is the default of
+// IntrospectionConfig.MaxResponseAge.
+const DefaultMaxIntrospectionResponseAge = 5 * time.Minute
+
+// SignIntrospectionResponse signs a token introspection response as a JWT, as
+// described by RFC 9701, for authorization servers and gateways answering
+// introspection requests with the IntrospectionJWTMediaType. The audience is
+// the client ID of the resource server that made the request, and response
+// is the introspection response, such as a map holding "active" and the
+// token's claims.
+func SignIntrospectionResponse(ctx context.Context, s Signer, issuer, audience string, response interface{}) (string, error) {
+	if issuer == "" || audience == "" {
+		return "", errors.New("oidc: signed introspection responses require an issuer and audience")
+	}
+	return signJWT(ctx, s, introspectionJWTType, nil, struct {
+		Issuer   string      `json:"iss"`
+		Audience string      `json:"aud"`
+		IssuedAt int64       `json:"iat"`
+		Response interface{} `json:"token_introspection"`
+	}{issuer, audience, time.Now().Unix(), response})
+}
+
+// verifyJWT verifies a signed introspection response and returns the
+// introspection response it holds.
+func (v *IntrospectionVerifier) verifyJWT(ctx context.Context, raw string) ([]byte, error) {
+	config := v.config
+	keySet, algs := config.KeySet, config.SupportedSigningAlgs
+	if keySet == nil {
+		keySet = v.keySet
+	}
+	if len(algs) == 0 {
+		algs = v.algs
+	}
+	if keySet == nil {
+		return nil, errors.New("oidc: signed introspection responses require a key set")
+	}
+	if config.Issuer == "" || config.ClientID == "" {
+		return nil, errors.New("oidc: signed introspection responses require an issuer and client ID")
+	}
+	verifier := NewVerifier(config.Issuer, keySet, &Config{
+		ClientID:             config.ClientID,
+		SupportedSigningAlgs: algs,
+		// Signed responses have no "exp", their "iat" is checked against
+		// MaxResponseAge and the expiry of the introspected token instead.
+		SkipExpiryCheck: true,
+		Now:             config.Now,
+	})
+	t, err := verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	// RFC 9701 section 5: the "typ" header prevents other JWTs issued by the
+	// authorization server, such as access tokens, from being accepted.
+	typ := strings.ToLower(t.Header().Type)
+	if strings.TrimPrefix(typ, "application/") != introspectionJWTType {
+		return nil, fmt.Errorf("oidc: token of type %q isn't a signed introspection response", t.Header().Type)
+	}
+	if t.IssuedAt.IsZero() {
+		return nil, errors.New("oidc: signed introspection response has no iat claim")
+	}
+	now := config.now()
+	if err := checkIssuedAt(t.IssuedAt, now); err != nil {
+		return nil, err
+	}
+	maxAge := config.MaxResponseAge
+	if maxAge <= 0 {
+		maxAge = DefaultMaxIntrospectionResponseAge
+	}
+	if now.Sub(t.IssuedAt) > maxAge {
+		return nil, fmt.Errorf("oidc: signed introspection response issued at %v is older than %v", t.IssuedAt, maxAge)
+	}
+	var claims struct {
+		Response json.RawMessage `json:"token_introspection"`
+	}
+	if err := t.Claims(&claims); err != nil {
+		return nil, err
+	}
+	if len(claims.Response) == 0 || claims.Response[0] != '{' {
+		return nil, errors.New("oidc: signed introspection response has no token_introspection claim")
+	}
+	return claims.Response, nil
+}
+
+// IntrospectionJWTVerifier verifies signed introspection responses that a
+// gateway forwards in place of opaque tokens, in the phantom token pattern:
+// clients only hold opaque tokens, while the services behind the gateway
+// receive JWTs they verify locally, without introspecting tokens themselves.
+//
+//	// At the gateway.
+//	introspect, err := provider.IntrospectionVerifier(&oidc.IntrospectionConfig{
+//		ClientID:               "gateway",
+//		ClientSecret:           secret,
+//		RequireSignedResponses: true,
+//	})
+//	handler := oidc.BearerMiddleware(introspect, oidc.ForwardIntrospectionJWT())(proxy)
+//
+//	// At the services.
+//	verifier := oidc.NewIntrospectionJWTVerifier(&oidc.IntrospectionConfig{
+//		ClientID: "gateway",
+//		Issuer:   issuer,
+//		KeySet:   keySet,
+//		Audience: "https://api.example.com",
+//	})
+//	handler := oidc.BearerMiddleware(verifier)(service)
+//
+// Tokens are returned like those of an IntrospectionVerifier. The responses
+// are addressed to the gateway, so IntrospectionConfig.ClientID is the
+// gateway's client ID, while IntrospectionConfig.Audience is checked against
+// the introspected token. ClientSecret, TokenTypeHint and Cache are unused.
+type IntrospectionJWTVerifier struct {
+	introspection *IntrospectionVerifier
+}
+
+// NewIntrospectionJWTVerifier returns a verifier of signed introspection
+// responses. KeySet and Issuer must be set.
+func NewIntrospectionJWTVerifier(config *IntrospectionConfig) *IntrospectionJWTVerifier {
+	return &IntrospectionJWTVerifier{introspection: &IntrospectionVerifier{config: config}}
+}
+
+// Verify verifies a signed introspection response. It returns
+// ErrInactiveToken if the introspected token isn't active. The WithAudience
+// option overrides IntrospectionConfig.Audience, and WithNonce isn't
+// supported.
+func (v *IntrospectionJWTVerifier) Verify(ctx context.Context, rawToken string, opts ...VerifyOption) (*IDToken, error) {
+	var o verifyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.nonce != nil {
+		return nil, errors.New("oidc: introspected tokens have no nonce")
+	}
+	body, err := v.introspection.verifyJWT(ctx, rawToken)
+	if err != nil {
+		return nil, err
+	}
+	r := introspectionResponse{jwt: rawToken}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode introspection response: %v", err)
+	}
+	return v.introspection.check(body, &r, &o)
+}
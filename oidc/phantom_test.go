@@ -0,0 +1,144 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPhantomToken(t *testing.T) {
+	key := newECDSAKey(t)
+	signer, err := NewCryptoSigner(key.priv.(crypto.Signer), string(key.alg), key.keyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySet := &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}
+	const issuer = "https://foo"
+
+	as := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept"); got != IntrospectionJWTMediaType {
+			t.Errorf("unexpected Accept header %q", got)
+		}
+		client, _, _ := r.BasicAuth()
+		response := map[string]interface{}{"active": false}
+		if r.PostFormValue("token") == "opaque" {
+			response = map[string]interface{}{"active": true, "iss": issuer, "sub": "jane", "aud": "api", "exp": 4102444800}
+		}
+		jwt, err := SignIntrospectionResponse(r.Context(), signer, issuer, client, response)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", IntrospectionJWTMediaType)
+		w.Write([]byte(jwt))
+	}))
+	defer as.Close()
+
+	introspect := NewIntrospectionVerifier(as.URL, &IntrospectionConfig{
+		ClientID:             "gateway",
+		Issuer:               issuer,
+		KeySet:               keySet,
+		SupportedSigningAlgs: []string{ES256},
+	})
+	service := NewIntrospectionJWTVerifier(&IntrospectionConfig{
+		ClientID:             "gateway",
+		Issuer:               issuer,
+		KeySet:               keySet,
+		SupportedSigningAlgs: []string{ES256},
+		Audience:             "api",
+	})
+	var forwarded string
+	backend := BearerMiddleware(service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded, _ = bearerToken(r)
+		token, _ := BearerTokenFromContext(r.Context())
+		w.Write([]byte(token.Subject))
+	}))
+	gateway := BearerMiddleware(introspect, ForwardIntrospectionJWT())(backend)
+
+	for token, want := range map[string]int{"opaque": http.StatusOK, "revoked": http.StatusUnauthorized} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		gateway.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("token %q: expected status %d, got %d", token, want, w.Code)
+		}
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			t.Errorf("token %q: request of the caller was modified", token)
+		}
+	}
+	if forwarded == "" || forwarded == "opaque" {
+		t.Fatalf("expected signed introspection response to be forwarded, got %q", forwarded)
+	}
+
+	ctx := context.Background()
+	if _, err := service.Verify(ctx, forwarded, WithAudience("other")); err == nil {
+		t.Errorf("expected error for other audience")
+	}
+	inactive, err := SignIntrospectionResponse(ctx, signer, issuer, "gateway", map[string]interface{}{"active": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Verify(ctx, inactive); !errors.Is(err, ErrInactiveToken) {
+		t.Errorf("expected inactive token error, got %v", err)
+	}
+	otherClient, err := SignIntrospectionResponse(ctx, signer, issuer, "other-gateway", map[string]interface{}{"active": true, "aud": "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Verify(ctx, otherClient); err == nil {
+		t.Errorf("expected error for response addressed to another client")
+	}
+	// An access token with the same claims isn't a signed introspection
+	// response.
+	accessToken, err := SignJWT(ctx, signer, map[string]interface{}{
+		"iss": issuer, "aud": "gateway", "iat": 1, "token_introspection": map[string]interface{}{"active": true, "aud": "api"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Verify(ctx, accessToken); err == nil {
+		t.Errorf("expected error for token of type JWT")
+	}
+
+	// Forwarded responses expire, even for tokens without an "exp".
+	later := func() time.Time { return time.Now().Add(10 * time.Minute) }
+	stale := NewIntrospectionJWTVerifier(&IntrospectionConfig{
+		ClientID:             "gateway",
+		Issuer:               issuer,
+		KeySet:               keySet,
+		SupportedSigningAlgs: []string{ES256},
+		Now:                  later,
+	})
+	noExpiry, err := SignIntrospectionResponse(ctx, signer, issuer, "gateway", map[string]interface{}{"active": true, "sub": "jane"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stale.Verify(ctx, noExpiry); err == nil {
+		t.Errorf("expected error for stale signed introspection response")
+	}
+	lenient := NewIntrospectionJWTVerifier(&IntrospectionConfig{
+		ClientID:             "gateway",
+		Issuer:               issuer,
+		KeySet:               keySet,
+		SupportedSigningAlgs: []string{ES256},
+		Now:                  later,
+		MaxResponseAge:       time.Hour,
+	})
+	if _, err := lenient.Verify(ctx, noExpiry); err != nil {
+		t.Errorf("expected response within MaxResponseAge to be accepted, got %v", err)
+	}
+
+	unsigned := NewIntrospectionVerifier(newIntrospectionServer(t).URL+"/introspect", &IntrospectionConfig{ClientID: "rs", ClientSecret: "secret"})
+	plain := BearerMiddleware(unsigned, ForwardIntrospectionJWT())(backend)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer active")
+	w := httptest.NewRecorder()
+	plain.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 for unsigned introspection response, got %d", w.Code)
+	}
+}
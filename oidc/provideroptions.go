@@ -0,0 +1,391 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProviderOption configures a Provider created by NewProvider or
+// ProviderConfig.NewProvider. Options take precedence over values carried by
+// the context, such as the client set by ClientContext.
+type ProviderOption func(*providerOptions)
+
+type providerOptions struct {
+	client    ClientProvider
+	transport http.RoundTripper
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	rootCAs   *x509.CertPool
+	pins      [][]byte
+	prefetch  bool
+	err       error
+
+	userInfoCache *UserInfoCache
+
+	fetchURL    string
+	tokenURL    string
+	userInfoURL string
+	jwksURL     string
+}
+
+func newProviderOptions(opts []ProviderOption) *providerOptions {
+	o := &providerOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// ClientProvider returns the HTTP client used for requests to an issuer, or nil
+// to fall back to the client of the context.
+type ClientProvider func(issuer string) *http.Client
+
+// WithClientProvider chooses the HTTP client of each provider by its issuer.
+// This lets different issuers use different proxies, root CAs or timeouts
+// within one process, for example when options are shared by the providers of
+// a TrustedIssuers verifier.
+//
+//	internal := oidc.WithIssuerHTTPClient("https://idp.corp.example.com", mtlsClient)
+//	external := oidc.WithClientProvider(func(issuer string) *http.Client {
+//		return egressProxyClient
+//	})
+//	provider, err := oidc.NewProvider(ctx, issuer, external, internal)
+//
+// When several client options are given, later options take precedence for
+// the issuers they return a client for.
+func WithClientProvider(f ClientProvider) ProviderOption {
+	return func(o *providerOptions) {
+		prev := o.client
+		o.client = func(issuer string) *http.Client {
+			if c := f(issuer); c != nil {
+				return c
+			}
+			if prev != nil {
+				return prev(issuer)
+			}
+			return nil
+		}
+	}
+}
+
+// WithIssuerHTTPClient uses the HTTP client for the provider with the given
+// issuer.
+func WithIssuerHTTPClient(issuer string, client *http.Client) ProviderOption {
+	return WithClientProvider(func(iss string) *http.Client {
+		if iss == issuer {
+			return client
+		}
+		return nil
+	})
+}
+
+// WithHTTPClient uses the HTTP client for all requests of the provider.
+func WithHTTPClient(client *http.Client) ProviderOption {
+	return WithClientProvider(func(string) *http.Client {
+		return client
+	})
+}
+
+// WithKeyPrefetch fetches the provider's keys while NewProvider creates it, so
+// the first verification doesn't wait for them. NewProvider fails if the keys
+// can't be fetched or none of them is usable, which lets a service that can't
+// function without the provider fail at startup instead of on its first
+// request.
+//
+// ProviderConfig.NewProvider can't report errors and ignores this option. Call
+// Provider.RefreshKeys instead.
+func WithKeyPrefetch() ProviderOption {
+	return func(o *providerOptions) {
+		o.prefetch = true
+	}
+}
+
+// WithUserInfoCache caches the provider's userinfo responses, so services that
+// look up the same user several times while handling a request only contact
+// the userinfo endpoint once. See UserInfoCache.
+//
+//	provider, err := oidc.NewProvider(ctx, issuer, oidc.WithUserInfoCache(oidc.NewUserInfoCache(10000, time.Minute)))
+func WithUserInfoCache(c *UserInfoCache) ProviderOption {
+	return func(o *providerOptions) {
+		o.userInfoCache = c
+	}
+}
+
+// WithTokenURL uses the token endpoint instead of the one advertised by the
+// provider's discovery document, for example when split-horizon DNS makes the
+// advertised hostname unreachable from the relying party's network. Other
+// metadata is still taken from discovery.
+//
+//	provider, err := oidc.NewProvider(ctx, "https://idp.example.com",
+//		oidc.WithTokenURL("https://idp.internal:8443/token"),
+//		oidc.WithJWKSURL("https://idp.internal:8443/keys"),
+//	)
+//
+// The URL must be absolute. Provider.Claims still returns the discovery
+// document as published.
+func WithTokenURL(u string) ProviderOption {
+	return func(o *providerOptions) {
+		o.setEndpoint(&o.tokenURL, "token", u)
+	}
+}
+
+// WithUserInfoURL uses the userinfo endpoint instead of the one advertised by
+// the provider's discovery document. See WithTokenURL.
+func WithUserInfoURL(u string) ProviderOption {
+	return func(o *providerOptions) {
+		o.setEndpoint(&o.userInfoURL, "userinfo", u)
+	}
+}
+
+// WithJWKSURL fetches the provider's keys from the URL instead of the jwks_uri
+// advertised by the provider's discovery document. See WithTokenURL.
+func WithJWKSURL(u string) ProviderOption {
+	return func(o *providerOptions) {
+		o.setEndpoint(&o.jwksURL, "jwks", u)
+	}
+}
+
+// WithFetchURL fetches the provider's discovery document, keys and other
+// server-to-server endpoints from base instead of the issuer URL, while tokens
+// are still required to carry the issuer as their "iss" claim. This supports
+// split-horizon deployments, such as a Kubernetes API server whose issuer is a
+// public URL but which pods must reach through the cluster service.
+//
+//	provider, err := oidc.NewProvider(ctx, "https://oidc.example.com",
+//		oidc.WithFetchURL("https://kubernetes.default.svc"),
+//	)
+//
+// The discovery document is fetched from base and must still report the
+// issuer. Its token, userinfo, jwks, introspection and device authorization
+// endpoints under the issuer URL are rewritten to the same path under base.
+// The authorization endpoint and check_session_iframe are used by browsers and
+// keep their published URLs. WithTokenURL, WithUserInfoURL and WithJWKSURL
+// take precedence over rewritten endpoints.
+//
+// For providers created by ProviderConfig.NewProvider, the configured
+// endpoints are rewritten the same way.
+func WithFetchURL(base string) ProviderOption {
+	return func(o *providerOptions) {
+		o.setEndpoint(&o.fetchURL, "fetch", base)
+	}
+}
+
+func (o *providerOptions) setEndpoint(dst *string, name, u string) {
+	parsed, err := url.Parse(u)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+		o.err = fmt.Errorf("oidc: invalid %s endpoint override %q", name, u)
+		return
+	}
+	*dst = u
+}
+
+// overrideEndpoints rewrites the provider's endpoints under the issuer URL to
+// the base set by WithFetchURL, then replaces them with the ones set by
+// WithTokenURL, WithUserInfoURL and WithJWKSURL.
+func (o *providerOptions) overrideEndpoints(p *Provider, issuer string) {
+	if o.fetchURL != "" {
+		for _, u := range []*string{&p.tokenURL, &p.userInfoURL, &p.jwksURL, &p.introspectionURL, &p.deviceAuthURL} {
+			*u = rebaseURL(*u, issuer, o.fetchURL)
+		}
+	}
+	if o.tokenURL != "" {
+		p.tokenURL = o.tokenURL
+	}
+	if o.userInfoURL != "" {
+		p.userInfoURL = o.userInfoURL
+	}
+	if o.jwksURL != "" {
+		p.jwksURL = o.jwksURL
+	}
+}
+
+// WithTransport sends the provider's requests through the round tripper, for
+// example one provided by a service mesh library, while keeping the timeouts
+// and redirect policy of the provider's HTTP client.
+func WithTransport(rt http.RoundTripper) ProviderOption {
+	return func(o *providerOptions) {
+		o.transport = rt
+	}
+}
+
+// WithDialer opens the provider's connections with the dial function, for
+// example to reach an issuer through a sidecar proxy. The provider's transport
+// must be an *http.Transport, which is copied rather than modified.
+func WithDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) ProviderOption {
+	return func(o *providerOptions) {
+		o.dial = dial
+	}
+}
+
+// WithUnixSocket connects to the provider over the Unix domain socket at the
+// path, regardless of the host of its URLs. The issuer and endpoints still use
+// http or https URLs, whose host is sent in the Host header.
+//
+//	provider, err := oidc.NewProvider(ctx, "http://idp.internal", oidc.WithUnixSocket("/run/idp/http.sock"))
+func WithUnixSocket(path string) ProviderOption {
+	var d net.Dialer
+	return WithDialer(func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, "unix", path)
+	})
+}
+
+// WithRootCAs verifies the provider's TLS certificates against the pool
+// instead of the system roots, for providers using a private PKI. It applies
+// to discovery, key set and userinfo requests.
+//
+// The provider's HTTP client, if any, must use an *http.Transport, which is
+// copied rather than modified.
+func WithRootCAs(pool *x509.CertPool) ProviderOption {
+	return func(o *providerOptions) {
+		o.rootCAs = pool
+	}
+}
+
+// WithPinnedCertificates only accepts TLS connections to the provider whose
+// certificate chain contains one of the certificates. Certificates are
+// identified by the hex encoded SHA-256 fingerprint of their DER encoding, with
+// optional colons, as printed by "openssl x509 -noout -fingerprint -sha256".
+// Certificate chains are verified as usual before pins are checked.
+//
+// Pinning a CA certificate rather than the provider's leaf certificate keeps
+// working when the leaf certificate is renewed.
+func WithPinnedCertificates(fingerprints ...string) ProviderOption {
+	return func(o *providerOptions) {
+		for _, fp := range fingerprints {
+			pin, err := hex.DecodeString(strings.ReplaceAll(fp, ":", ""))
+			if err != nil || len(pin) != sha256.Size {
+				o.err = fmt.Errorf("oidc: invalid sha-256 certificate fingerprint %q", fp)
+				return
+			}
+			o.pins = append(o.pins, pin)
+		}
+	}
+}
+
+// rebaseURL replaces the issuer prefix of u with base. URLs that aren't under
+// the issuer URL are returned unchanged.
+func rebaseURL(u, issuer, base string) string {
+	issuer = strings.TrimSuffix(issuer, "/")
+	rest := strings.TrimPrefix(u, issuer)
+	if u == "" || rest == u || (rest != "" && !strings.ContainsAny(rest[:1], "/?#")) {
+		return u
+	}
+	return strings.TrimSuffix(base, "/") + rest
+}
+
+// httpClient returns the client of the provider of the issuer.
+func (o *providerOptions) httpClient(ctx context.Context, issuer string) (*http.Client, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	var client *http.Client
+	if o.client != nil {
+		client = o.client(issuer)
+	}
+	if client == nil {
+		client = getClient(ctx)
+	}
+	if o.transport != nil {
+		cp := http.Client{}
+		if client != nil {
+			cp = *client
+		}
+		cp.Transport = o.transport
+		client = &cp
+	}
+	if o.dial != nil {
+		var err error
+		if client, err = withTransport(client, func(t *http.Transport) { t.DialContext = o.dial }); err != nil {
+			return nil, err
+		}
+	}
+	if o.rootCAs == nil && len(o.pins) == 0 {
+		return client, nil
+	}
+	return withTLSConfig(client, func(c *tls.Config) {
+		if o.rootCAs != nil {
+			c.RootCAs = o.rootCAs
+		}
+		if len(o.pins) > 0 {
+			c.VerifyConnection = o.verifyPins
+		}
+	})
+}
+
+// verifyPins checks that a verified certificate chain contains a pinned
+// certificate.
+func (o *providerOptions) verifyPins(cs tls.ConnectionState) error {
+	for _, chain := range cs.VerifiedChains {
+		for _, cert := range chain {
+			sum := sha256.Sum256(cert.Raw)
+			for _, pin := range o.pins {
+				if bytes.Equal(sum[:], pin) {
+					return nil
+				}
+			}
+		}
+	}
+	return errors.New("oidc: provider certificate doesn't match any pinned certificate")
+}
+
+// errTransport fails every request. It's used by providers whose options are
+// invalid but can't report an error when they're created.
+type errTransport struct {
+	err error
+}
+
+func (t errTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// clientContext returns a context carrying the provider's HTTP client. A client
+// carried by the context is only kept if the provider's client wasn't built by
+// provider options, so it can't bypass options such as WithPinnedCertificates.
+func (p *Provider) clientContext(ctx context.Context) context.Context {
+	if p.client != nil && (p.clientOptions || getClient(ctx) == nil) {
+		return ClientContext(ctx, p.client)
+	}
+	return ctx
+}
+
+// withTLSConfig returns a copy of the client whose transport's TLS
+// configuration is modified by f. The client's transport must be an
+// *http.Transport.
+func withTLSConfig(client *http.Client, f func(*tls.Config)) (*http.Client, error) {
+	return withTransport(client, func(t *http.Transport) {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		f(t.TLSClientConfig)
+	})
+}
+
+// withTransport returns a copy of the client with a copy of its transport
+// modified by f. The client's transport must be an *http.Transport.
+func withTransport(client *http.Client, f func(*http.Transport)) (*http.Client, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	rt := client.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("oidc: configuring the transport requires an *http.Transport, got %T", rt)
+	}
+	t = t.Clone()
+	f(t)
+	cp := *client
+	cp.Transport = t
+	return &cp, nil
+}
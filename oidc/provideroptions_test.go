@@ -0,0 +1,401 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"golang.org/x/oauth2"
+)
+
+// countingTransport counts the requests sent through it.
+type countingTransport struct {
+	n int32
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&c.n, 1)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestProviderHTTPClientOptions(t *testing.T) {
+	key := newRSAKey(t)
+	s, _ := newIssuerServer(t, key)
+
+	var fallback, issuer countingTransport
+	ctx := ClientContext(context.Background(), &http.Client{Transport: &fallback})
+	p, err := NewProvider(ctx, s.URL,
+		WithHTTPClient(&http.Client{Transport: &fallback}),
+		WithIssuerHTTPClient(s.URL, &http.Client{Transport: &issuer}),
+		WithIssuerHTTPClient("https://other.example.com", &http.Client{Transport: &fallback}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"app","sub":"jane","exp":4102444800}`, s.URL)))
+	if _, err := p.Verifier(&Config{ClientID: "app"}).Verify(context.Background(), raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.VerifierContext(context.Background(), &Config{ClientID: "app"}).Verify(context.Background(), raw); err != nil {
+		t.Fatal(err)
+	}
+	// Discovery, and a key set fetch by each verifier.
+	if n := atomic.LoadInt32(&issuer.n); n != 3 {
+		t.Errorf("got %d requests with the issuer's client, want 3", n)
+	}
+	if n := atomic.LoadInt32(&fallback.n); n != 0 {
+		t.Errorf("got %d requests with other clients, want 0", n)
+	}
+
+	// Without a matching option, the context's client is used.
+	p = (&ProviderConfig{IssuerURL: "https://foo", JWKSURL: s.URL + "/keys"}).NewProvider(ctx, WithIssuerHTTPClient(s.URL, &http.Client{Transport: &issuer}))
+	verifier := p.Verifier(&Config{ClientID: "app", SkipIssuerCheck: true})
+	if _, err := verifier.Verify(context.Background(), raw); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&fallback.n); n != 1 {
+		t.Errorf("got %d requests with the context's client, want 1", n)
+	}
+}
+
+func TestProviderOptionsOverrideContextClient(t *testing.T) {
+	key := newRSAKey(t)
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":"%s/keys","token_endpoint":"%s/token","userinfo_endpoint":"%s/userinfo"}`,
+				s.URL, s.URL, s.URL, s.URL)
+		case "/keys":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}})
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"token","token_type":"Bearer","expires_in":3600}`)
+		case "/userinfo":
+			fmt.Fprint(w, `{"sub":"jane"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	var options, other countingTransport
+	p, err := NewProvider(context.Background(), s.URL, WithTransport(&options))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A context client mustn't bypass options such as WithPinnedCertificates.
+	ctx := ClientContext(context.Background(), &http.Client{Transport: &other})
+
+	raw := key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"app","exp":4102444800}`, s.URL)))
+	if _, err := p.VerifierContext(ctx, &Config{ClientID: "app"}).Verify(ctx, raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.UserInfo(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := p.ClientCredentialsTokenSource(ctx, ClientAuth{ClientID: "app", ClientSecret: "secret"}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.CheckMetadata(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&other.n); n != 0 {
+		t.Errorf("got %d requests with the context's client, want 0", n)
+	}
+	// Discovery, keys, userinfo, token and metadata.
+	if n := atomic.LoadInt32(&options.n); n != 5 {
+		t.Errorf("got %d requests with the options' client, want 5", n)
+	}
+}
+
+func TestProviderTLSOptions(t *testing.T) {
+	var s *httptest.Server
+	s = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":"%s/keys"}`, s.URL, s.URL)
+	}))
+	defer s.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Certificate())
+	sum := sha256.Sum256(s.Certificate().Raw)
+	pin := strings.ToUpper(hex.EncodeToString(sum[:]))
+	other := strings.Repeat("00", sha256.Size)
+
+	tests := []struct {
+		name    string
+		opts    []ProviderOption
+		wantErr bool
+	}{
+		{name: "system roots", wantErr: true},
+		{name: "root CAs", opts: []ProviderOption{WithRootCAs(pool)}},
+		{name: "pinned", opts: []ProviderOption{WithRootCAs(pool), WithPinnedCertificates(other, pin)}},
+		{name: "other pin", opts: []ProviderOption{WithRootCAs(pool), WithPinnedCertificates(other)}, wantErr: true},
+		{name: "invalid pin", opts: []ProviderOption{WithRootCAs(pool), WithPinnedCertificates("AB:CD")}, wantErr: true},
+	}
+	for _, test := range tests {
+		_, err := NewProvider(context.Background(), s.URL, test.opts...)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %t", test.name, err, test.wantErr)
+		}
+	}
+
+	p := (&ProviderConfig{IssuerURL: s.URL, UserInfoURL: s.URL}).NewProvider(context.Background(), WithPinnedCertificates("nope"))
+	if _, err := p.UserInfo(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "at"})); err == nil || !strings.Contains(err.Error(), "fingerprint") {
+		t.Errorf("expected invalid options to fail requests, got %v", err)
+	}
+}
+
+func TestProviderUnixSocket(t *testing.T) {
+	// t.TempDir may exceed the maximum length of socket paths.
+	dir, err := os.MkdirTemp("", "oidc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "idp.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets not supported: %v", err)
+	}
+	key := newRSAKey(t)
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprint(w, `{"issuer":"http://idp.internal","jwks_uri":"http://idp.internal/keys"}`)
+		case "/keys":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}})
+		}
+	})}
+	go s.Serve(l)
+	defer s.Close()
+
+	p, err := NewProvider(context.Background(), "http://idp.internal", WithUnixSocket(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := key.sign(t, []byte(`{"iss":"http://idp.internal","aud":"app","sub":"jane","exp":4102444800}`))
+	if _, err := p.Verifier(&Config{ClientID: "app"}).Verify(context.Background(), raw); err != nil {
+		t.Fatal(err)
+	}
+
+	// A custom transport replaces the context client's transport.
+	var transport countingTransport
+	ctx := ClientContext(context.Background(), &http.Client{Transport: &transport})
+	if _, err := NewProvider(ctx, "http://idp.internal", WithUnixSocket(path)); err == nil {
+		t.Errorf("expected dialer to require an *http.Transport")
+	}
+	if _, err := NewProvider(ctx, "http://idp.internal", WithTransport(&http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&transport.n); n != 0 {
+		t.Errorf("got %d requests through the context's transport, want 0", n)
+	}
+}
+
+func TestWithKeyPrefetch(t *testing.T) {
+	key := newRSAKey(t)
+	var keysStatus int32 = http.StatusOK
+	var keyRequests int32
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":"%s/keys"}`, s.URL, s.URL)
+		case "/keys":
+			atomic.AddInt32(&keyRequests, 1)
+			if status := int(atomic.LoadInt32(&keysStatus)); status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}})
+		}
+	}))
+	defer s.Close()
+	ctx := context.Background()
+
+	p, err := NewProvider(ctx, s.URL, WithKeyPrefetch())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&keyRequests); n != 1 {
+		t.Fatalf("expected keys to be fetched once, got %d requests", n)
+	}
+	raw := key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"app","exp":4102444800}`, s.URL)))
+	if _, err := p.Verifier(&Config{ClientID: "app"}).Verify(ctx, raw); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&keyRequests); n != 1 {
+		t.Errorf("expected verification to use prefetched keys, got %d requests", n)
+	}
+
+	atomic.StoreInt32(&keysStatus, http.StatusInternalServerError)
+	if _, err := NewProvider(ctx, s.URL, WithKeyPrefetch()); err == nil {
+		t.Errorf("expected unreachable keys to fail NewProvider")
+	}
+	if _, err := NewProvider(ctx, s.URL); err != nil {
+		t.Errorf("expected NewProvider without prefetch to succeed, got %v", err)
+	}
+	if err := p.RefreshKeys(ctx); err == nil {
+		t.Errorf("expected RefreshKeys to fail")
+	}
+}
+
+func TestProviderEndpointOverrides(t *testing.T) {
+	key := newRSAKey(t)
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":%q,"authorization_endpoint":"https://idp.invalid/auth",`+
+				`"token_endpoint":"https://idp.invalid/token","userinfo_endpoint":"https://idp.invalid/userinfo",`+
+				`"jwks_uri":"https://idp.invalid/keys"}`, s.URL)
+		case "/internal/keys":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}})
+		case "/internal/userinfo":
+			fmt.Fprint(w, `{"sub":"alice"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+	ctx := context.Background()
+
+	p, err := NewProvider(ctx, s.URL,
+		WithTokenURL(s.URL+"/internal/token"),
+		WithUserInfoURL(s.URL+"/internal/userinfo"),
+		WithJWKSURL(s.URL+"/internal/keys"),
+		WithKeyPrefetch(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Endpoint().TokenURL, s.URL+"/internal/token"; got != want {
+		t.Errorf("expected token url %q, got %q", want, got)
+	}
+	if got, want := p.Endpoint().AuthURL, "https://idp.invalid/auth"; got != want {
+		t.Errorf("expected discovered auth url %q, got %q", want, got)
+	}
+	raw := key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"app","exp":4102444800}`, s.URL)))
+	if _, err := p.Verifier(&Config{ClientID: "app"}).Verify(ctx, raw); err != nil {
+		t.Fatal(err)
+	}
+	info, err := p.UserInfo(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Subject != "alice" {
+		t.Errorf("expected subject alice, got %q", info.Subject)
+	}
+
+	config := &ProviderConfig{IssuerURL: s.URL, JWKSURL: "https://idp.invalid/keys"}
+	if err := config.NewProvider(ctx, WithJWKSURL(s.URL+"/internal/keys")).RefreshKeys(ctx); err != nil {
+		t.Errorf("expected ProviderConfig.NewProvider to use the jwks override, got %v", err)
+	}
+
+	for _, u := range []string{"", "/token", "idp.internal/token", "https://"} {
+		if _, err := NewProvider(ctx, s.URL, WithTokenURL(u)); err == nil {
+			t.Errorf("expected invalid token url %q to fail NewProvider", u)
+		}
+	}
+}
+
+func TestWithFetchURL(t *testing.T) {
+	const issuer = "https://oidc.example.invalid"
+	key := newRSAKey(t)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":%q,"authorization_endpoint":"%s/auth","token_endpoint":"%s/token",`+
+				`"jwks_uri":"%s/openid/v1/jwks","introspection_endpoint":"https://other.invalid/introspect"}`,
+				issuer, issuer, issuer, issuer)
+		case "/openid/v1/jwks":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+	ctx := context.Background()
+
+	p, err := NewProvider(ctx, issuer, WithFetchURL(s.URL+"/"), WithKeyPrefetch())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Endpoint().AuthURL; got != issuer+"/auth" {
+		t.Errorf("expected auth url to keep its published value, got %q", got)
+	}
+	if got, want := p.Endpoint().TokenURL, s.URL+"/token"; got != want {
+		t.Errorf("expected token url %q, got %q", want, got)
+	}
+	if got := p.introspectionURL; got != "https://other.invalid/introspect" {
+		t.Errorf("expected endpoint outside the issuer to be unchanged, got %q", got)
+	}
+	raw := key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"app","exp":4102444800}`, issuer)))
+	if _, err := p.Verifier(&Config{ClientID: "app"}).Verify(ctx, raw); err != nil {
+		t.Fatal(err)
+	}
+	raw = key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"app","exp":4102444800}`, s.URL)))
+	if _, err := p.Verifier(&Config{ClientID: "app"}).Verify(ctx, raw); err == nil {
+		t.Errorf("expected token issued by the fetch url to be rejected")
+	}
+
+	p, err = NewProvider(ctx, issuer, WithFetchURL(s.URL), WithTokenURL("https://token.internal/token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Endpoint().TokenURL; got != "https://token.internal/token" {
+		t.Errorf("expected WithTokenURL to take precedence, got %q", got)
+	}
+
+	if _, err := NewProvider(ctx, "https://wrong.example.invalid", WithFetchURL(s.URL)); err == nil {
+		t.Errorf("expected mismatched issuer to fail discovery")
+	}
+	if _, err := NewProvider(ctx, issuer, WithFetchURL("kubernetes.default.svc")); err == nil {
+		t.Errorf("expected relative fetch url to fail NewProvider")
+	}
+
+	config := &ProviderConfig{IssuerURL: issuer, JWKSURL: issuer + "/openid/v1/jwks"}
+	if err := config.NewProvider(ctx, WithFetchURL(s.URL)).RefreshKeys(ctx); err != nil {
+		t.Errorf("expected ProviderConfig.NewProvider to fetch keys from the fetch url, got %v", err)
+	}
+}
+
+func TestRebaseURL(t *testing.T) {
+	tests := []struct {
+		u, issuer, base, want string
+	}{
+		{"https://idp/keys", "https://idp", "https://internal/", "https://internal/keys"},
+		{"https://idp/keys", "https://idp/", "https://internal", "https://internal/keys"},
+		{"https://idp/tenant/keys", "https://idp/tenant", "https://internal/t", "https://internal/t/keys"},
+		{"https://idp", "https://idp", "https://internal", "https://internal"},
+		{"https://idp?x=1", "https://idp", "https://internal", "https://internal?x=1"},
+		{"https://idp.evil/keys", "https://idp", "https://internal", "https://idp.evil/keys"},
+		{"https://other/keys", "https://idp", "https://internal", "https://other/keys"},
+		{"", "https://idp", "https://internal", ""},
+	}
+	for _, tc := range tests {
+		if got := rebaseURL(tc.u, tc.issuer, tc.base); got != tc.want {
+			t.Errorf("rebaseURL(%q, %q, %q) = %q, want %q", tc.u, tc.issuer, tc.base, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRateLimitBackoff is the time requests to an endpoint are suspended
+	// after a rate limited response without a Retry-After header.
+	defaultRateLimitBackoff = 10 * time.Second
+	// maxRateLimitBackoff bounds the Retry-After values honored, so that a
+	// misbehaving provider can't suspend key rotation indefinitely.
+	maxRateLimitBackoff = time.Hour
+)
+
+// rateLimiter suspends requests to an endpoint after the provider asked
+// clients to back off, rather than retrying on the next verification.
+type rateLimiter struct {
+	mu    sync.Mutex
+	until time.Time
+	err   *HTTPError
+}
+
+// check returns an error if requests are suspended at now. The error wraps an
+// *HTTPError whose RetryAfter is the remaining time.
+func (l *rateLimiter) check(now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil || !now.Before(l.until) {
+		return nil
+	}
+	err := *l.err
+	err.RetryAfter = l.until.Sub(now)
+	return fmt.Errorf("oidc: rate limited, not retrying for %s: %w", err.RetryAfter, &err)
+}
+
+// retryAt returns the time until which requests are suspended, or zero if they
+// aren't at now.
+func (l *rateLimiter) retryAt(now time.Time) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil || !now.Before(l.until) {
+		return time.Time{}
+	}
+	return l.until
+}
+
+// record suspends requests if err is a response asking the client to back off:
+// a 429, or a 503 with a Retry-After header.
+func (l *rateLimiter) record(err error, now time.Time) {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return
+	}
+	backoff := httpErr.RetryAfter
+	switch {
+	case httpErr.StatusCode == http.StatusTooManyRequests:
+		if backoff <= 0 {
+			backoff = defaultRateLimitBackoff
+		}
+	case httpErr.StatusCode == http.StatusServiceUnavailable && backoff > 0:
+	default:
+		return
+	}
+	if backoff > maxRateLimitBackoff {
+		backoff = maxRateLimitBackoff
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.until = now.Add(backoff)
+	l.err = httpErr
+}
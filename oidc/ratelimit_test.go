@@ -0,0 +1,130 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"golang.org/x/oauth2"
+)
+
+func TestRateLimitBackoff(t *testing.T) {
+	key := newRSAKey(t)
+	var requests, limited int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&limited) == 1 {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		switch r.URL.Path {
+		case "/keys":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}})
+		case "/userinfo":
+			w.Write([]byte(`{"sub":"jane"}`))
+		}
+	}))
+	defer s.Close()
+
+	now := time.Unix(1000, 0)
+	ctx := ClockContext(context.Background(), func() time.Time { return now })
+	atomic.StoreInt32(&limited, 1)
+
+	keySet := NewRemoteKeySet(ctx, s.URL+"/keys")
+	p := (&ProviderConfig{IssuerURL: s.URL, UserInfoURL: s.URL + "/userinfo"}).NewProvider(ctx)
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "at"})
+	fetch := map[string]func() error{
+		"keys": func() error {
+			_, err := keySet.keysFromRemote(ctx)
+			return err
+		},
+		"userinfo": func() error {
+			_, err := p.UserInfo(ctx, tokenSource)
+			return err
+		},
+	}
+	for name, f := range fetch {
+		f()
+		var httpErr *HTTPError
+		if err := f(); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests || httpErr.RetryAfter != time.Minute {
+			t.Errorf("%s: expected rate limit error, got %v", name, err)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("got %d requests while rate limited, want 2", n)
+	}
+
+	now = now.Add(30 * time.Second)
+	var httpErr *HTTPError
+	if err := fetch["keys"](); !errors.As(err, &httpErr) || httpErr.RetryAfter != 30*time.Second {
+		t.Errorf("expected remaining backoff of 30s, got %v", err)
+	}
+
+	atomic.StoreInt32(&limited, 0)
+	now = now.Add(30 * time.Second)
+	for name, f := range fetch {
+		if err := f(); err != nil {
+			t.Errorf("%s: expected request after backoff to succeed, got %v", name, err)
+		}
+	}
+}
+
+func TestUserInfoRateLimitOAuthError(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":"slow_down","error_description":"too many requests"}`))
+	}))
+	defer s.Close()
+
+	now := time.Unix(1000, 0)
+	ctx := ClockContext(context.Background(), func() time.Time { return now })
+	p := (&ProviderConfig{IssuerURL: s.URL, UserInfoURL: s.URL + "/userinfo"}).NewProvider(ctx)
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "at"})
+
+	var oauthErr *OAuthError
+	if _, err := p.UserInfo(ctx, tokenSource); !errors.As(err, &oauthErr) || oauthErr.Code != "slow_down" {
+		t.Fatalf("expected oauth error, got %v", err)
+	}
+	var httpErr *HTTPError
+	if _, err := p.UserInfo(ctx, tokenSource); !errors.As(err, &httpErr) || httpErr.RetryAfter != time.Minute {
+		t.Errorf("expected rate limit error, got %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("got %d requests while rate limited, want 1", n)
+	}
+}
+
+func TestRateLimiterRecord(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{"too many requests", &HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}, time.Minute},
+		{"no retry after", &HTTPError{StatusCode: http.StatusTooManyRequests}, defaultRateLimitBackoff},
+		{"capped", &HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: 24 * time.Hour}, maxRateLimitBackoff},
+		{"unavailable", &HTTPError{StatusCode: http.StatusServiceUnavailable, RetryAfter: time.Minute}, time.Minute},
+		{"unavailable without retry after", &HTTPError{StatusCode: http.StatusServiceUnavailable}, 0},
+		{"server error", &HTTPError{StatusCode: http.StatusInternalServerError, RetryAfter: time.Minute}, 0},
+		{"other error", errors.New("connection refused"), 0},
+	}
+	for _, test := range tests {
+		var l rateLimiter
+		l.record(test.err, now)
+		if got := l.until.Sub(now); test.want != 0 && got != test.want || test.want == 0 && !l.until.IsZero() {
+			t.Errorf("%s: got backoff %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"unicode/utf8"
+)
+
+// RedactionPolicy describes how RedactClaims makes claims safe to log. Claims
+// it doesn't list are kept as is.
+type RedactionPolicy struct {
+	// Hash lists claims replaced by a hash of their value, so log entries
+	// about the same user or session can be correlated without recording
+	// the identifier itself.
+	Hash []string
+	// HashKey, if set, keys the hashes with HMAC-SHA256. Without a key,
+	// values with little entropy, such as email addresses or phone numbers,
+	// can be recovered from their hashes by guessing.
+	HashKey []byte
+	// Drop lists claims removed entirely, such as personal information.
+	Drop []string
+	// Truncate lists claims holding secrets, such as tokens, of which only
+	// the first TruncateLength characters are kept, enough to tell values
+	// apart while debugging.
+	Truncate []string
+	// TruncateLength defaults to 8.
+	TruncateLength int
+}
+
+// DefaultRedactionPolicy is used by RedactClaims when no policy is given. It
+// hashes identifiers, drops the standard claims holding personal information,
+// and truncates tokens.
+var DefaultRedactionPolicy = &RedactionPolicy{
+	Hash: []string{"sub", "sid", "oid"},
+	Drop: []string{
+		"email", "name", "given_name", "family_name", "middle_name", "nickname",
+		"preferred_username", "upn", "unique_name", "phone_number", "address",
+		"birthdate", "picture", "profile", "website", "gender",
+	},
+	Truncate: []string{"access_token", "refresh_token", "id_token", "code", "client_secret"},
+}
+
+// redactedLength is the number of hex characters kept of claim hashes.
+const redactedLength = 16
+
+// RedactClaims returns a copy of the claims that's safe to log according to the
+// policy, or DefaultRedactionPolicy if policy is nil. Nested claims are only
+// redacted as a whole, by naming the top level claim.
+//
+//	var claims map[string]interface{}
+//	if err := idToken.Claims(&claims); err != nil {
+//		// handle error
+//	}
+//	log.Printf("verified token: %v", oidc.RedactClaims(claims, nil))
+func RedactClaims(claims map[string]interface{}, policy *RedactionPolicy) map[string]interface{} {
+	if policy == nil {
+		policy = DefaultRedactionPolicy
+	}
+	redacted := make(map[string]interface{}, len(claims))
+	for k, v := range claims {
+		redacted[k] = v
+	}
+	for _, name := range policy.Drop {
+		delete(redacted, name)
+	}
+	for _, name := range policy.Hash {
+		if v, ok := redacted[name]; ok {
+			redacted[name] = policy.hash(v)
+		}
+	}
+	for _, name := range policy.Truncate {
+		if v, ok := redacted[name]; ok {
+			redacted[name] = policy.truncate(v)
+		}
+	}
+	return redacted
+}
+
+// hash returns the hash of a claim value, of the value itself for strings and
+// of its JSON encoding otherwise.
+func (p *RedactionPolicy) hash(v interface{}) string {
+	b, ok := v.(string)
+	data := []byte(b)
+	if !ok {
+		data, _ = json.Marshal(v)
+	}
+	var sum []byte
+	if len(p.HashKey) > 0 {
+		mac := hmac.New(sha256.New, p.HashKey)
+		mac.Write(data)
+		sum = mac.Sum(nil)
+	} else {
+		s := sha256.Sum256(data)
+		sum = s[:]
+	}
+	return "sha256:" + hex.EncodeToString(sum)[:redactedLength]
+}
+
+// truncate returns the first characters of a string claim, and replaces other
+// values entirely.
+func (p *RedactionPolicy) truncate(v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		return "[redacted]"
+	}
+	n := p.TruncateLength
+	if n <= 0 {
+		n = 8
+	}
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n]) + "..."
+}
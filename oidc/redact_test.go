@@ -0,0 +1,51 @@
+package oidc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedactClaims(t *testing.T) {
+	claims := map[string]interface{}{
+		"iss":          "https://foo",
+		"sub":          "248289761001",
+		"email":        "jane@example.com",
+		"name":         "Jane Doe",
+		"access_token": "SlAV32hkKG0123456789",
+		"groups":       []interface{}{"admins"},
+	}
+	redacted := RedactClaims(claims, nil)
+
+	if _, ok := redacted["email"]; ok {
+		t.Error("expected email to be dropped")
+	}
+	if _, ok := redacted["name"]; ok {
+		t.Error("expected name to be dropped")
+	}
+	sub, _ := redacted["sub"].(string)
+	if !strings.HasPrefix(sub, "sha256:") || strings.Contains(sub, "248289761001") {
+		t.Errorf("expected sub to be hashed, got %q", sub)
+	}
+	if got := redacted["access_token"]; got != "SlAV32hk..." {
+		t.Errorf("expected access token to be truncated, got %q", got)
+	}
+	if redacted["iss"] != "https://foo" || !reflect.DeepEqual(redacted["groups"], []interface{}{"admins"}) {
+		t.Errorf("expected other claims to be kept, got %v", redacted)
+	}
+	if claims["email"] != "jane@example.com" {
+		t.Error("RedactClaims modified its input")
+	}
+
+	again := RedactClaims(map[string]interface{}{"sub": "248289761001"}, nil)
+	if again["sub"] != sub {
+		t.Errorf("expected hashes of the same subject to match, got %q and %q", again["sub"], sub)
+	}
+	keyed := RedactClaims(claims, &RedactionPolicy{Hash: []string{"sub"}, HashKey: []byte("secret")})
+	if keyed["sub"] == sub {
+		t.Error("expected keyed hash to differ from the unkeyed hash")
+	}
+	if keyed["email"] != "jane@example.com" {
+		t.Error("expected claims not listed by a custom policy to be kept")
+	}
+}
@@ -0,0 +1,159 @@
+package oidc
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// RedirectURIError indicates that a redirect_uri was rejected by a
+// RedirectURIPolicy.
+type RedirectURIError struct {
+	RedirectURI string
+	// Reason describes why the redirect URI was rejected.
+	Reason string
+}
+
+func (e *RedirectURIError) Error() string {
+	return fmt.Sprintf("oidc: invalid redirect_uri %q: %s", e.RedirectURI, e.Reason)
+}
+
+// RedirectURIPolicy validates the redirect_uri of authorization requests
+// against the redirect URIs registered for a client, for providers embedded in
+// an application and for clients checking their own configuration. Loose
+// redirect URI matching lets attackers have authorization codes and tokens
+// sent to them, so the policy is strict by default:
+//
+//   - Redirect URIs must be absolute, without a fragment or user info, and use
+//     https, or http on a loopback address.
+//   - The redirect_uri of a request must be identical to a registered one, as
+//     described by RFC 6749 section 3.1.2.3. URIs aren't normalized, so case
+//     and escaping must match too.
+//
+// The Allow fields relax these rules for native apps and development.
+//
+//	policy := &oidc.RedirectURIPolicy{
+//		Registered:         client.RedirectURIs,
+//		AllowLoopbackPorts: true,
+//	}
+//	if err := policy.Validate(r.FormValue("redirect_uri")); err != nil {
+//		// Don't redirect to an unvalidated URI, show the error to the user.
+//		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+//		return
+//	}
+type RedirectURIPolicy struct {
+	// Registered lists the redirect URIs registered for the client.
+	Registered []string
+
+	// AllowLoopbackPorts accepts any port for registered http redirect URIs
+	// on a loopback address, such as "http://127.0.0.1/callback", as
+	// described by RFC 8252 section 7.3. Native apps listen on a port chosen
+	// by the operating system, which can't be registered in advance. The
+	// scheme, host, path and query must still match.
+	AllowLoopbackPorts bool
+	// AllowCustomSchemes accepts redirect URIs with private-use URI schemes
+	// of native apps, such as "com.example.app:/callback", as described by
+	// RFC 8252 section 7.1. The scheme must be a reverse domain name, which
+	// rules out schemes such as "javascript" and "file".
+	AllowCustomSchemes bool
+	// AllowInsecureHTTP accepts http redirect URIs on hosts other than
+	// loopback addresses. Codes and tokens sent to them can be read by anyone
+	// on the network, so this is only meant for development.
+	AllowInsecureHTTP bool
+}
+
+// Validate returns a RedirectURIError if the redirect URI of a request isn't
+// acceptable under the policy or doesn't match a registered redirect URI.
+// Registered redirect URIs that aren't acceptable are ignored.
+func (p *RedirectURIPolicy) Validate(redirectURI string) error {
+	u, err := p.parse(redirectURI)
+	if err != nil {
+		return err
+	}
+	for _, registered := range p.Registered {
+		if registered == redirectURI {
+			if _, err := p.parse(registered); err == nil {
+				return nil
+			}
+			continue
+		}
+		if !p.AllowLoopbackPorts {
+			continue
+		}
+		r, err := p.parse(registered)
+		if err != nil {
+			continue
+		}
+		if u.Scheme == "http" && r.Scheme == "http" && isLoopbackHost(r.Hostname()) &&
+			u.Hostname() == r.Hostname() &&
+			u.EscapedPath() == r.EscapedPath() &&
+			u.RawQuery == r.RawQuery && u.ForceQuery == r.ForceQuery {
+			return nil
+		}
+	}
+	return &RedirectURIError{RedirectURI: redirectURI, Reason: "not registered for the client"}
+}
+
+// ValidateRegistration returns a RedirectURIError if a redirect URI isn't
+// acceptable under the policy, for checking redirect URIs when clients are
+// registered or configured.
+func (p *RedirectURIPolicy) ValidateRegistration(redirectURI string) error {
+	_, err := p.parse(redirectURI)
+	return err
+}
+
+// parse parses a redirect URI and checks that it's acceptable under the
+// policy.
+func (p *RedirectURIPolicy) parse(redirectURI string) (*url.URL, error) {
+	reject := func(reason string) (*url.URL, error) {
+		return nil, &RedirectURIError{RedirectURI: redirectURI, Reason: reason}
+	}
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return reject("malformed")
+	}
+	if !u.IsAbs() {
+		return reject("not an absolute URI")
+	}
+	if strings.Contains(redirectURI, "#") {
+		return reject("has a fragment")
+	}
+	if u.User != nil {
+		return reject("has user info")
+	}
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return reject("has no host")
+		}
+	case "http":
+		if u.Host == "" {
+			return reject("has no host")
+		}
+		if !isLoopbackHost(u.Hostname()) && !p.AllowInsecureHTTP {
+			return reject("uses http on a host other than a loopback address")
+		}
+	default:
+		if !p.AllowCustomSchemes {
+			return reject(fmt.Sprintf("uses unsupported scheme %q", u.Scheme))
+		}
+		// RFC 8252 section 7.1: private-use schemes are reverse domain
+		// names of a domain the app's publisher controls.
+		if !strings.Contains(u.Scheme, ".") {
+			return reject(fmt.Sprintf("custom scheme %q isn't a reverse domain name", u.Scheme))
+		}
+	}
+	return u, nil
+}
+
+// isLoopbackHost reports whether the host is a loopback address, or
+// "localhost". RFC 8252 section 8.3 recommends loopback IP literals over
+// "localhost", which may resolve to other interfaces, but it's widely used.
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
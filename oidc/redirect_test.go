@@ -0,0 +1,143 @@
+package oidc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRedirectURIPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      RedirectURIPolicy
+		redirectURI string
+		wantErr     bool
+	}{
+		{
+			name:        "exact match",
+			policy:      RedirectURIPolicy{Registered: []string{"https://app.example.com/callback"}},
+			redirectURI: "https://app.example.com/callback",
+		},
+		{
+			name:        "not registered",
+			policy:      RedirectURIPolicy{Registered: []string{"https://app.example.com/callback"}},
+			redirectURI: "https://evil.example.com/callback",
+			wantErr:     true,
+		},
+		{
+			name:        "prefix of registered",
+			policy:      RedirectURIPolicy{Registered: []string{"https://app.example.com/callback"}},
+			redirectURI: "https://app.example.com/callback/../../evil",
+			wantErr:     true,
+		},
+		{
+			name:        "differs in case",
+			policy:      RedirectURIPolicy{Registered: []string{"https://app.example.com/callback"}},
+			redirectURI: "https://APP.example.com/callback",
+			wantErr:     true,
+		},
+		{
+			name:        "extra query",
+			policy:      RedirectURIPolicy{Registered: []string{"https://app.example.com/callback"}},
+			redirectURI: "https://app.example.com/callback?next=https://evil.example.com",
+			wantErr:     true,
+		},
+		{
+			name:        "fragment",
+			policy:      RedirectURIPolicy{Registered: []string{"https://app.example.com/callback#"}},
+			redirectURI: "https://app.example.com/callback#",
+			wantErr:     true,
+		},
+		{
+			name:        "user info",
+			policy:      RedirectURIPolicy{Registered: []string{"https://app.example.com@evil.example.com/"}},
+			redirectURI: "https://app.example.com@evil.example.com/",
+			wantErr:     true,
+		},
+		{
+			name:        "relative",
+			policy:      RedirectURIPolicy{Registered: []string{"/callback"}},
+			redirectURI: "/callback",
+			wantErr:     true,
+		},
+		{
+			name:        "insecure http",
+			policy:      RedirectURIPolicy{Registered: []string{"http://app.example.com/callback"}},
+			redirectURI: "http://app.example.com/callback",
+			wantErr:     true,
+		},
+		{
+			name:        "insecure http allowed",
+			policy:      RedirectURIPolicy{Registered: []string{"http://app.example.com/callback"}, AllowInsecureHTTP: true},
+			redirectURI: "http://app.example.com/callback",
+		},
+		{
+			name:        "loopback exact",
+			policy:      RedirectURIPolicy{Registered: []string{"http://127.0.0.1:8080/callback"}},
+			redirectURI: "http://127.0.0.1:8080/callback",
+		},
+		{
+			name:        "loopback other port",
+			policy:      RedirectURIPolicy{Registered: []string{"http://127.0.0.1/callback"}},
+			redirectURI: "http://127.0.0.1:51004/callback",
+			wantErr:     true,
+		},
+		{
+			name:        "loopback any port",
+			policy:      RedirectURIPolicy{Registered: []string{"http://127.0.0.1/callback"}, AllowLoopbackPorts: true},
+			redirectURI: "http://127.0.0.1:51004/callback",
+		},
+		{
+			name:        "loopback ipv6 any port",
+			policy:      RedirectURIPolicy{Registered: []string{"http://[::1]/callback"}, AllowLoopbackPorts: true},
+			redirectURI: "http://[::1]:51004/callback",
+		},
+		{
+			name:        "loopback other path",
+			policy:      RedirectURIPolicy{Registered: []string{"http://127.0.0.1/callback"}, AllowLoopbackPorts: true},
+			redirectURI: "http://127.0.0.1:51004/other",
+			wantErr:     true,
+		},
+		{
+			name:        "loopback other host",
+			policy:      RedirectURIPolicy{Registered: []string{"http://127.0.0.1/callback"}, AllowLoopbackPorts: true},
+			redirectURI: "http://localhost:51004/callback",
+			wantErr:     true,
+		},
+		{
+			name:        "port flexibility isn't for https",
+			policy:      RedirectURIPolicy{Registered: []string{"https://app.example.com/callback"}, AllowLoopbackPorts: true},
+			redirectURI: "https://app.example.com:8443/callback",
+			wantErr:     true,
+		},
+		{
+			name:        "custom scheme",
+			policy:      RedirectURIPolicy{Registered: []string{"com.example.app:/callback"}, AllowCustomSchemes: true},
+			redirectURI: "com.example.app:/callback",
+		},
+		{
+			name:        "custom scheme not allowed",
+			policy:      RedirectURIPolicy{Registered: []string{"com.example.app:/callback"}},
+			redirectURI: "com.example.app:/callback",
+			wantErr:     true,
+		},
+		{
+			name:        "custom scheme without domain",
+			policy:      RedirectURIPolicy{Registered: []string{"javascript:alert(1)"}, AllowCustomSchemes: true},
+			redirectURI: "javascript:alert(1)",
+			wantErr:     true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.policy.Validate(test.redirectURI)
+			if test.wantErr {
+				var redirectErr *RedirectURIError
+				if !errors.As(err, &redirectErr) {
+					t.Errorf("expected redirect uri error, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
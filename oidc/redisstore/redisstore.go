@@ -0,0 +1,126 @@
+// Package redisstore provides an oidc.SessionStore keeping sessions in Redis,
+// so they're shared between replicas and survive restarts. It's a separate
+// module so the core module doesn't depend on a Redis client.
+//
+//	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	store := redisstore.New(client)
+//	store.MaxAge = 8 * time.Hour
+//
+// Like oidc.MemoryStore, the browser is only sent a random session ID, and a
+// new ID is issued every time a session is saved, so an ID obtained before
+// login can't be used to take over the session after it. Sessions are stored
+// in plaintext; use a Redis deployment that encrypts data at rest and in
+// transit if tokens must not be stored unencrypted.
+package redisstore
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultSessionAge is the lifetime of sessions when MaxAge isn't set, the
+// same as for oidc.MemoryStore.
+const defaultSessionAge = 24 * time.Hour
+
+// Store is an oidc.SessionStore keeping sessions in Redis. Sessions expire
+// with the TTL of their key.
+type Store struct {
+	oidc.SessionCookie
+
+	// Prefix is prepended to session IDs to form Redis keys. Defaults to
+	// "oidc:session:".
+	Prefix string
+
+	client redis.Cmdable
+}
+
+// New returns a store using the client, such as a *redis.Client or a
+// *redis.ClusterClient.
+func New(client redis.Cmdable) *Store {
+	return &Store{client: client}
+}
+
+func (s *Store) key(id string) string {
+	if s.Prefix == "" {
+		return "oidc:session:" + id
+	}
+	return s.Prefix + id
+}
+
+func (s *Store) maxAge() time.Duration {
+	if s.MaxAge > 0 {
+		return s.MaxAge
+	}
+	return defaultSessionAge
+}
+
+// Load returns the session referenced by the request's cookie.
+func (s *Store) Load(r *http.Request) (*oidc.Session, error) {
+	c, err := r.Cookie(s.CookieName())
+	if err != nil {
+		return nil, oidc.ErrNoSession
+	}
+	b, err := s.client.Get(r.Context(), s.key(c.Value)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, oidc.ErrNoSession
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redisstore: loading session: %w", err)
+	}
+	var session oidc.Session
+	if err := json.Unmarshal(b, &session); err != nil {
+		return nil, oidc.ErrNoSession
+	}
+	return &session, nil
+}
+
+// Save stores the session under a new ID, replacing the request's session.
+func (s *Store) Save(w http.ResponseWriter, r *http.Request, session *oidc.Session) error {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return fmt.Errorf("redisstore: generating session id: %v", err)
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+	value, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("redisstore: encoding session: %v", err)
+	}
+	if err := s.client.Set(r.Context(), s.key(id), value, s.maxAge()).Err(); err != nil {
+		return fmt.Errorf("redisstore: saving session: %w", err)
+	}
+	if err := s.delete(r); err != nil {
+		return err
+	}
+	http.SetCookie(w, s.Cookie(id, s.MaxAge))
+	return nil
+}
+
+// Delete removes the request's session and clears its cookie.
+func (s *Store) Delete(w http.ResponseWriter, r *http.Request) error {
+	if err := s.delete(r); err != nil {
+		return err
+	}
+	http.SetCookie(w, s.Cookie("", -1))
+	return nil
+}
+
+// delete removes the session referenced by the request's cookie, if any.
+func (s *Store) delete(r *http.Request) error {
+	c, err := r.Cookie(s.CookieName())
+	if err != nil {
+		return nil
+	}
+	if err := s.client.Del(r.Context(), s.key(c.Value)).Err(); err != nil {
+		return fmt.Errorf("redisstore: deleting session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package redisstore
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/redis/go-redis/v9"
+)
+
+// roundTrip saves the session, replacing the session of prev if set, and
+// returns a request carrying the resulting cookies.
+func roundTrip(t *testing.T, store *Store, prev *http.Request, s *oidc.Session) *http.Request {
+	t.Helper()
+	if prev == nil {
+		prev = httptest.NewRequest(http.MethodGet, "/", nil)
+	}
+	w := httptest.NewRecorder()
+	if err := store.Save(w, prev, s); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func TestStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	store.MaxAge = time.Hour
+
+	if _, err := store.Load(httptest.NewRequest(http.MethodGet, "/", nil)); !errors.Is(err, oidc.ErrNoSession) {
+		t.Errorf("load without cookie: got %v, want ErrNoSession", err)
+	}
+
+	r := roundTrip(t, store, nil, &oidc.Session{Values: map[string]string{"state": "s"}})
+	got, err := store.Load(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Values["state"] != "s" {
+		t.Errorf("unexpected session %+v", got)
+	}
+	keys := mr.Keys()
+	if len(keys) != 1 || mr.TTL(keys[0]) != time.Hour {
+		t.Errorf("expected one key with a ttl of an hour, got %v", keys)
+	}
+
+	// Saving issues a new ID and invalidates the old one.
+	next := roundTrip(t, store, r, &oidc.Session{IDToken: "id"})
+	if _, err := store.Load(r); !errors.Is(err, oidc.ErrNoSession) {
+		t.Errorf("load replaced session: got %v, want ErrNoSession", err)
+	}
+	if got, err := store.Load(next); err != nil || got.IDToken != "id" {
+		t.Errorf("load saved session: %v", err)
+	}
+	if n := len(mr.Keys()); n != 1 {
+		t.Errorf("got %d sessions, want 1", n)
+	}
+
+	w := httptest.NewRecorder()
+	if err := store.Delete(w, next); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(next); !errors.Is(err, oidc.ErrNoSession) {
+		t.Errorf("load deleted session: got %v, want ErrNoSession", err)
+	}
+	if c := w.Result().Cookies(); len(c) != 1 || c[0].MaxAge != -1 {
+		t.Errorf("expected cookie to be cleared, got %v", c)
+	}
+
+	r = roundTrip(t, store, nil, &oidc.Session{})
+	mr.FastForward(time.Hour)
+	if _, err := store.Load(r); !errors.Is(err, oidc.ErrNoSession) {
+		t.Errorf("load expired session: got %v, want ErrNoSession", err)
+	}
+
+	mr.Close()
+	if _, err := store.Load(next); err == nil || errors.Is(err, oidc.ErrNoSession) {
+		t.Errorf("expected redis errors to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,303 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// RelyingPartyConfig is the configuration of a RelyingParty.
+type RelyingPartyConfig struct {
+	// ClientAuth holds the client's ID and credentials for the token endpoint.
+	ClientAuth
+
+	// RedirectURL is the redirect_uri used in authorization requests, which
+	// must be sent again when the code is exchanged. It must be an absolute
+	// URI without a fragment, see RedirectURIPolicy.
+	RedirectURL string
+
+	// Verifier verifies the ID Tokens returned by the token endpoint. Defaults
+	// to the provider's verifier with the client ID as the expected audience.
+	Verifier *IDTokenVerifier
+}
+
+// RelyingParty performs the token requests of an OpenID Connect client, and
+// verifies the ID Tokens it receives.
+type RelyingParty struct {
+	provider *Provider
+	config   RelyingPartyConfig
+	verifier *IDTokenVerifier
+}
+
+// RelyingParty returns a client of the provider.
+//
+//	rp, err := provider.RelyingParty(&oidc.RelyingPartyConfig{
+//		ClientAuth:  oidc.ClientAuth{ClientID: clientID, ClientSecret: clientSecret},
+//		RedirectURL: "https://app.example.com/callback",
+//	})
+func (p *Provider) RelyingParty(config *RelyingPartyConfig) (*RelyingParty, error) {
+	if err := config.ClientAuth.validate(); err != nil {
+		return nil, err
+	}
+	if p.tokenURL == "" {
+		return nil, errors.New("oidc: provider has no token endpoint")
+	}
+	if config.RedirectURL != "" {
+		// Only reject redirect URIs that no provider accepts. Whether http
+		// and custom schemes are allowed is up to the provider.
+		policy := &RedirectURIPolicy{AllowCustomSchemes: true, AllowInsecureHTTP: true}
+		if err := policy.ValidateRegistration(config.RedirectURL); err != nil {
+			return nil, err
+		}
+	}
+	verifier := config.Verifier
+	if verifier == nil {
+		verifier = p.Verifier(&Config{ClientID: config.ClientID})
+	}
+	rp := &RelyingParty{provider: p, config: *config, verifier: verifier}
+	rp.config.AuthMethod = p.ClientAuthMethod(config.ClientAuth)
+	return rp, nil
+}
+
+// Tokens holds the result of a token request, with the verified ID Token.
+type Tokens struct {
+	// Response is the response of the token endpoint.
+	Response *TokenResponse
+	// IDToken is the verified ID Token of the response. It's nil if a
+	// refresh response had none.
+	IDToken *IDToken
+	// ClaimChanges lists the claims of a refreshed ID Token that differ from
+	// the previous ID Token, ignoring claims that change with every token,
+	// such as "exp" and "iat". It's only set by RefreshAndVerify.
+	ClaimChanges []ClaimChange
+}
+
+// ClaimChange describes a claim whose value differs between two ID Tokens.
+type ClaimChange struct {
+	Name string
+	// Old and New are the values of the claim, or nil if it was absent.
+	Old, New json.RawMessage
+}
+
+// ExchangeOption configures RelyingParty.ExchangeAndVerify and
+// RelyingParty.RefreshAndVerify.
+type ExchangeOption func(*exchangeOptions)
+
+type exchangeOptions struct {
+	nonce  *string
+	params url.Values
+}
+
+// WithExpectedNonce requires the ID Token to carry the nonce sent with the
+// authorization request.
+func WithExpectedNonce(nonce string) ExchangeOption {
+	return func(o *exchangeOptions) {
+		o.nonce = &nonce
+	}
+}
+
+// WithCodeVerifier sends the PKCE code verifier of the authorization request,
+// as described by RFC 7636.
+func WithCodeVerifier(verifier string) ExchangeOption {
+	return WithTokenParam("code_verifier", verifier)
+}
+
+// WithTokenParam sends an additional parameter with the token request.
+func WithTokenParam(key, value string) ExchangeOption {
+	return func(o *exchangeOptions) {
+		o.params.Set(key, value)
+	}
+}
+
+// ExchangeAndVerify exchanges an authorization code, and verifies the ID Token
+// returned by the token endpoint. If the ID Token has an "at_hash" claim, the
+// access token is checked against it with IDToken.VerifyAccessToken. The
+// exchange fails if the response has no ID Token, or if any check fails.
+//
+//	tokens, err := rp.ExchangeAndVerify(ctx, r.URL.Query().Get("code"),
+//		oidc.WithExpectedNonce(nonce), oidc.WithCodeVerifier(codeVerifier))
+//	if err != nil {
+//		// handle error
+//	}
+//	log.Printf("%s logged in", tokens.IDToken.Subject)
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#TokenResponseValidation
+func (rp *RelyingParty) ExchangeAndVerify(ctx context.Context, code string, opts ...ExchangeOption) (*Tokens, error) {
+	o := exchangeOptions{params: url.Values{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	form := o.params
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	if rp.config.RedirectURL != "" {
+		form.Set("redirect_uri", rp.config.RedirectURL)
+	}
+	client, err := rp.provider.tokenClient(ctx, &rp.config.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := requestToken(ctx, client, rp.provider.tokenURL, &rp.config.ClientAuth, form)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IDToken == "" {
+		return nil, errors.New("oidc: token response has no id_token")
+	}
+	var verifyOpts []VerifyOption
+	if o.nonce != nil {
+		verifyOpts = append(verifyOpts, WithNonce(*o.nonce))
+	}
+	idToken, err := rp.verifyIDToken(ctx, resp, verifyOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{Response: resp, IDToken: idToken}, nil
+}
+
+// verifyIDToken verifies the ID Token of the response, and the access token
+// against its "at_hash" claim.
+func (rp *RelyingParty) verifyIDToken(ctx context.Context, resp *TokenResponse, opts ...VerifyOption) (*IDToken, error) {
+	idToken, err := rp.verifier.Verify(ctx, resp.IDToken, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to verify id_token: %w", err)
+	}
+	if idToken.AccessTokenHash != "" {
+		if err := idToken.VerifyAccessToken(resp.AccessToken); err != nil {
+			return nil, fmt.Errorf("oidc: access token doesn't match id_token: %w", err)
+		}
+	}
+	return idToken, nil
+}
+
+// RefreshAndVerify uses a refresh token to request new tokens. If the response
+// has an ID Token, it's verified like those of ExchangeAndVerify, and checked
+// against the previous ID Token of the session as required by OpenID Connect:
+// its iss, sub, aud and azp claims must be the same, its auth_time must be
+// unchanged, and its nonce, if any, must match the previous one. Claims that
+// otherwise differ, such as a changed email address or group membership, are
+// listed by Tokens.ClaimChanges.
+//
+// The previous ID Token may be nil to skip these checks, for example if the
+// session doesn't keep it.
+//
+//	tokens, err := rp.RefreshAndVerify(ctx, session.RefreshToken, session.IDToken)
+//	if err != nil {
+//		// end the session
+//	}
+//	for _, c := range tokens.ClaimChanges {
+//		log.Printf("claim %s changed from %s to %s", c.Name, c.Old, c.New)
+//	}
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#RefreshTokenResponse
+func (rp *RelyingParty) RefreshAndVerify(ctx context.Context, refreshToken string, previous *IDToken, opts ...ExchangeOption) (*Tokens, error) {
+	o := exchangeOptions{params: url.Values{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	form := o.params
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+	client, err := rp.provider.tokenClient(ctx, &rp.config.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := requestToken(ctx, client, rp.provider.tokenURL, &rp.config.ClientAuth, form)
+	if err != nil {
+		return nil, err
+	}
+	tokens := &Tokens{Response: resp}
+	if resp.IDToken == "" {
+		return tokens, nil
+	}
+	if tokens.IDToken, err = rp.verifyIDToken(ctx, resp); err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		if err := checkRefreshedIDToken(previous, tokens.IDToken); err != nil {
+			return nil, err
+		}
+		tokens.ClaimChanges = claimChanges(previous, tokens.IDToken)
+	}
+	return tokens, nil
+}
+
+// checkRefreshedIDToken applies the rules of OpenID Connect Core section 12.2
+// to an ID Token returned by a refresh.
+func checkRefreshedIDToken(prev, t *IDToken) error {
+	if t.Issuer != prev.Issuer {
+		return fmt.Errorf("oidc: refreshed id_token has issuer %q, previous had %q", t.Issuer, prev.Issuer)
+	}
+	if t.Subject != prev.Subject {
+		return fmt.Errorf("oidc: refreshed id_token has subject %q, previous had %q", t.Subject, prev.Subject)
+	}
+	if !sameStrings(t.Audience, prev.Audience) {
+		return fmt.Errorf("oidc: refreshed id_token has audience %q, previous had %q", t.Audience, prev.Audience)
+	}
+	if subtle.ConstantTimeCompare([]byte(t.authorizedParty), []byte(prev.authorizedParty)) != 1 {
+		return fmt.Errorf("oidc: refreshed id_token has azp %q, previous had %q", t.authorizedParty, prev.authorizedParty)
+	}
+	// auth_time only has to match if the refreshed token includes it.
+	if !prev.AuthTime.IsZero() && !t.AuthTime.IsZero() && !t.AuthTime.Equal(prev.AuthTime) {
+		return fmt.Errorf("oidc: refreshed id_token has auth_time %v, previous had %v", t.AuthTime, prev.AuthTime)
+	}
+	if t.Nonce != "" && subtle.ConstantTimeCompare([]byte(t.Nonce), []byte(prev.Nonce)) != 1 {
+		return errors.New("oidc: refreshed id_token nonce doesn't match the previous id_token")
+	}
+	return nil
+}
+
+// volatileClaims change with every ID Token, so they're not reported by
+// claimChanges.
+var volatileClaims = map[string]bool{
+	"exp":     true,
+	"iat":     true,
+	"nbf":     true,
+	"jti":     true,
+	"at_hash": true,
+	"c_hash":  true,
+	"s_hash":  true,
+	"rt_hash": true,
+}
+
+// claimChanges lists the claims that differ between two ID Tokens.
+func claimChanges(prev, t *IDToken) []ClaimChange {
+	values := func(tok *IDToken) map[string]json.RawMessage {
+		m := make(map[string]json.RawMessage)
+		rangeClaims(tok.claims, func(name string, value json.RawMessage) bool {
+			m[name] = value
+			return true
+		})
+		return m
+	}
+	before, after := values(prev), values(t)
+
+	var changes []ClaimChange
+	for _, name := range prev.ClaimNames() {
+		if !volatileClaims[name] && !canonicalEqual(before[name], after[name]) {
+			changes = append(changes, ClaimChange{Name: name, Old: before[name], New: after[name]})
+		}
+	}
+	for _, name := range t.ClaimNames() {
+		if _, ok := before[name]; !ok && !volatileClaims[name] {
+			changes = append(changes, ClaimChange{Name: name, New: after[name]})
+		}
+	}
+	return changes
+}
+
+// sameStrings reports whether a and b hold the same values, ignoring order.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, v := range a {
+		if !contains(b, v) {
+			return false
+		}
+	}
+	return true
+}
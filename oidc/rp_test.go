@@ -0,0 +1,168 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExchangeAndVerify(t *testing.T) {
+	key := newRSAKey(t)
+	atHash, err := tokenHash(RS256, "at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idTokens := map[string]string{
+		"good":        key.sign(t, []byte(fmt.Sprintf(`{"iss":"https://foo","aud":"app","sub":"jane","exp":4102444800,"nonce":"n","at_hash":%q}`, atHash))),
+		"no-at-hash":  key.sign(t, []byte(`{"iss":"https://foo","aud":"app","sub":"jane","exp":4102444800,"nonce":"n"}`)),
+		"bad-at-hash": key.sign(t, []byte(`{"iss":"https://foo","aud":"app","sub":"jane","exp":4102444800,"nonce":"n","at_hash":"AAAAAAAAAAAAAAAAAAAAAA"}`)),
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != "https://app/callback" || r.PostFormValue("code_verifier") != "cv" {
+			t.Errorf("unexpected form %v", r.PostForm)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		code := r.PostFormValue("code")
+		if code == "none" {
+			fmt.Fprint(w, `{"access_token":"at","token_type":"Bearer"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"at","token_type":"Bearer","expires_in":300,"id_token":%q}`, idTokens[code])
+	}))
+	defer s.Close()
+
+	p := &Provider{issuer: "https://foo", tokenURL: s.URL}
+	rp, err := p.RelyingParty(&RelyingPartyConfig{
+		ClientAuth:  ClientAuth{ClientID: "app", ClientSecret: "secret"},
+		RedirectURL: "https://app/callback",
+		Verifier:    NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "app"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.RelyingParty(&RelyingPartyConfig{
+		ClientAuth:  ClientAuth{ClientID: "app", ClientSecret: "secret"},
+		RedirectURL: "https://app/callback#fragment",
+	}); err == nil {
+		t.Errorf("expected redirect url with fragment to be rejected")
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		code    string
+		nonce   string
+		wantErr string
+	}{
+		{code: "good", nonce: "n"},
+		{code: "no-at-hash", nonce: "n"},
+		{code: "bad-at-hash", nonce: "n", wantErr: "access token doesn't match"},
+		{code: "good", nonce: "other", wantErr: "nonce"},
+		{code: "none", nonce: "n", wantErr: "no id_token"},
+	}
+	for _, test := range tests {
+		tokens, err := rp.ExchangeAndVerify(ctx, test.code, WithExpectedNonce(test.nonce), WithCodeVerifier("cv"))
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v, want %q", test.code, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.code, err)
+			continue
+		}
+		if tokens.IDToken.Subject != "jane" || tokens.Response.AccessToken != "at" || tokens.Response.Expiry.IsZero() {
+			t.Errorf("%s: unexpected tokens %+v", test.code, tokens)
+		}
+	}
+}
+
+func TestRefreshAndVerify(t *testing.T) {
+	key := newRSAKey(t)
+	const base = `"iss":"https://foo","aud":"app","exp":4102444800,"iat":1700000000,"nonce":"n"`
+	idTokens := map[string]string{
+		"same":         key.sign(t, []byte(`{`+base+`,"sub":"jane","auth_time":1600000000,"email":"jane@example.com","jti":"2"}`)),
+		"drift":        key.sign(t, []byte(`{`+base+`,"sub":"jane","auth_time":1600000000,"email":"jane@example.org","groups":["admin"]}`)),
+		"other-sub":    key.sign(t, []byte(`{`+base+`,"sub":"john","auth_time":1600000000}`)),
+		"auth-time":    key.sign(t, []byte(`{`+base+`,"sub":"jane","auth_time":1600000500}`)),
+		"no-auth-time": key.sign(t, []byte(`{`+base+`,"sub":"jane","email":"jane@example.com"}`)),
+		"nonce":        key.sign(t, []byte(`{"iss":"https://foo","aud":"app","exp":4102444800,"sub":"jane","auth_time":1600000000,"nonce":"other"}`)),
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("grant_type") != "refresh_token" {
+			t.Errorf("unexpected form %v", r.PostForm)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		rt := r.PostFormValue("refresh_token")
+		if rt == "none" {
+			fmt.Fprint(w, `{"access_token":"at","token_type":"Bearer"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"at","token_type":"Bearer","id_token":%q}`, idTokens[rt])
+	}))
+	defer s.Close()
+
+	p := &Provider{issuer: "https://foo", tokenURL: s.URL}
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{ClientID: "app"})
+	rp, err := p.RelyingParty(&RelyingPartyConfig{
+		ClientAuth: ClientAuth{ClientID: "app", ClientSecret: "secret"},
+		Verifier:   verifier,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	previous, err := verifier.Verify(ctx, key.sign(t, []byte(`{`+base+`,"sub":"jane","auth_time":1600000000,"email":"jane@example.com"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		refreshToken string
+		wantChanges  []string
+		wantErr      string
+	}{
+		{refreshToken: "same"},
+		{refreshToken: "drift", wantChanges: []string{"email", "groups"}},
+		{refreshToken: "other-sub", wantErr: "subject"},
+		{refreshToken: "auth-time", wantErr: "auth_time"},
+		{refreshToken: "no-auth-time", wantChanges: []string{"auth_time"}},
+		{refreshToken: "nonce", wantErr: "nonce"},
+	}
+	for _, test := range tests {
+		tokens, err := rp.RefreshAndVerify(ctx, test.refreshToken, previous)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v, want %q", test.refreshToken, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.refreshToken, err)
+			continue
+		}
+		var names []string
+		for _, c := range tokens.ClaimChanges {
+			names = append(names, c.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(test.wantChanges, ",") {
+			t.Errorf("%s: got changes %v, want %v", test.refreshToken, names, test.wantChanges)
+		}
+	}
+
+	tokens, err := rp.RefreshAndVerify(ctx, "none", previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.IDToken != nil || tokens.Response.AccessToken != "at" {
+		t.Errorf("unexpected tokens %+v", tokens)
+	}
+	if _, err := rp.RefreshAndVerify(ctx, "other-sub", nil); err != nil {
+		t.Errorf("refresh without previous id_token: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// Scopes is a set of OAuth 2.0 scopes, in the order they were first listed.
+type Scopes []string
+
+// ParseScopes parses a space delimited list of scopes, as used by the "scope"
+// claim and token responses. Duplicates are removed.
+func ParseScopes(s string) Scopes {
+	return newScopes(strings.Fields(s))
+}
+
+func newScopes(list []string) Scopes {
+	var scopes Scopes
+	for _, scope := range list {
+		if scope != "" && !scopes.Contains(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// ScopesFromToken returns the scopes granted by a token response. Per RFC 6749
+// section 5.1, an empty result means the requested scopes were granted.
+func ScopesFromToken(t *oauth2.Token) Scopes {
+	s, _ := t.Extra("scope").(string)
+	return ParseScopes(s)
+}
+
+// String returns the space delimited list of scopes.
+func (s Scopes) String() string {
+	return strings.Join(s, " ")
+}
+
+// Contains reports whether scope is part of the set.
+func (s Scopes) Contains(scope string) bool {
+	return contains(s, scope)
+}
+
+// ContainsAll reports whether every scope is part of the set.
+func (s Scopes) ContainsAll(scopes ...string) bool {
+	return len(s.Missing(scopes...)) == 0
+}
+
+// Missing returns the scopes that aren't part of the set.
+func (s Scopes) Missing(scopes ...string) Scopes {
+	var missing Scopes
+	for _, scope := range scopes {
+		if !s.Contains(scope) && !missing.Contains(scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// Union returns the scopes that are part of either set.
+func (s Scopes) Union(other Scopes) Scopes {
+	return newScopes(append(append([]string(nil), s...), other...))
+}
+
+// Intersect returns the scopes that are part of both sets.
+func (s Scopes) Intersect(other Scopes) Scopes {
+	var both Scopes
+	for _, scope := range s {
+		if other.Contains(scope) && !both.Contains(scope) {
+			both = append(both, scope)
+		}
+	}
+	return both
+}
+
+// UnmarshalJSON accepts both a space delimited string, as used by the "scope"
+// claim of RFC 9068, and an array, as used by the "scp" claim of some
+// providers.
+func (s *Scopes) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		*s = ParseScopes(str)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return fmt.Errorf("oidc: scopes must be a string or an array of strings")
+	}
+	*s = newScopes(list)
+	return nil
+}
+
+// Scopes returns the scopes granted to a token, read from the "scope" claim, or
+// the "scp" claim if the former is missing.
+func (i *IDToken) Scopes() (Scopes, error) {
+	var claims struct {
+		Scope *Scopes `json:"scope"`
+		SCP   *Scopes `json:"scp"`
+	}
+	if err := i.Claims(&claims); err != nil {
+		return nil, err
+	}
+	switch {
+	case claims.Scope != nil:
+		return *claims.Scope, nil
+	case claims.SCP != nil:
+		return *claims.SCP, nil
+	}
+	return nil, nil
+}
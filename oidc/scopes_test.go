@@ -0,0 +1,66 @@
+package oidc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestScopes(t *testing.T) {
+	s := ParseScopes("  openid profile  openid email ")
+	if want := (Scopes{"openid", "profile", "email"}); !reflect.DeepEqual(s, want) {
+		t.Fatalf("ParseScopes() = %v, want %v", s, want)
+	}
+	if s.String() != "openid profile email" {
+		t.Errorf("unexpected String() %q", s.String())
+	}
+	if !s.ContainsAll("email", "openid") || s.ContainsAll("openid", "admin") {
+		t.Errorf("unexpected ContainsAll results")
+	}
+	if got, want := s.Missing("admin", "openid", "admin", "write"), (Scopes{"admin", "write"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Missing() = %v, want %v", got, want)
+	}
+	other := Scopes{"email", "admin"}
+	if got, want := s.Union(other), (Scopes{"openid", "profile", "email", "admin"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Union() = %v, want %v", got, want)
+	}
+	if got, want := s.Intersect(other), (Scopes{"email"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Intersect() = %v, want %v", got, want)
+	}
+
+	token := (&oauth2.Token{AccessToken: "at"}).WithExtra(map[string]interface{}{"scope": "read write"})
+	if got, want := ScopesFromToken(token), (Scopes{"read", "write"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("ScopesFromToken() = %v, want %v", got, want)
+	}
+}
+
+func TestScopesClaim(t *testing.T) {
+	tests := []struct {
+		claims  string
+		want    Scopes
+		wantErr bool
+	}{
+		{claims: `{"scope":"read write"}`, want: Scopes{"read", "write"}},
+		{claims: `{"scp":["read","write"]}`, want: Scopes{"read", "write"}},
+		{claims: `{"scope":"read","scp":["write"]}`, want: Scopes{"read"}},
+		{claims: `{}`},
+		{claims: `{"scope":42}`, wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := (&IDToken{claims: []byte(test.claims)}).Scopes()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error %v", test.claims, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.claims, got, test.want)
+		}
+	}
+
+	var s Scopes
+	if err := json.Unmarshal([]byte(`["a","a","b"]`), &s); err != nil || !reflect.DeepEqual(s, Scopes{"a", "b"}) {
+		t.Errorf("unexpected scopes %v, err=%v", s, err)
+	}
+}
@@ -0,0 +1,43 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// ClientSecretSource supplies the client secret used to authenticate to the
+// provider's token endpoint. Implementations can load secrets from a secret
+// manager and rotate them without restarting the client.
+type ClientSecretSource interface {
+	ClientSecret(ctx context.Context) (string, error)
+}
+
+// StaticClientSecret is a ClientSecretSource that always returns the same
+// secret.
+type StaticClientSecret string
+
+// ClientSecret returns the static secret.
+func (s StaticClientSecret) ClientSecret(ctx context.Context) (string, error) {
+	return string(s), nil
+}
+
+// ConfigWithClientSecret returns a copy of the OAuth2 configuration with the
+// client secret loaded from the source. Call it for each token request so
+// rotated secrets are picked up.
+//
+//	config, err := oidc.ConfigWithClientSecret(ctx, &oauth2Config, secretSource)
+//	if err != nil {
+//		// handle error
+//	}
+//	oauth2Token, err := config.Exchange(ctx, code)
+func ConfigWithClientSecret(ctx context.Context, config *oauth2.Config, src ClientSecretSource) (*oauth2.Config, error) {
+	secret, err := src.ClientSecret(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: loading client secret: %w", err)
+	}
+	cp := *config
+	cp.ClientSecret = secret
+	return &cp, nil
+}
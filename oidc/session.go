@@ -0,0 +1,34 @@
+package oidc
+
+// Status values posted back by the provider's check_session_iframe.
+//
+// See: https://openid.net/specs/openid-connect-session-1_0.html#OPiframe
+const (
+	// SessionUnchanged indicates the user's login status at the provider
+	// hasn't changed.
+	SessionUnchanged = "unchanged"
+	// SessionChanged indicates the user's login status at the provider has
+	// changed, such as by logging out or switching accounts. The client
+	// should re-authenticate the user, typically with prompt=none.
+	SessionChanged = "changed"
+	// SessionError indicates the provider couldn't check the session, for
+	// example because the message was malformed.
+	SessionError = "error"
+)
+
+// CheckSessionMessage returns the message the client's iframe posts to the
+// provider's check_session_iframe to poll the login status of a session. The
+// session state is the value returned with the authorization response, and
+// should be stored alongside the client's session so the page can poll with it.
+//
+//	resp, err := oidc.ParseAuthorizationResponse(r)
+//	...
+//	session.Values["oidc_check_session"] = oidc.CheckSessionMessage(clientID, resp.SessionState)
+//
+// The page then posts the message to the iframe, loaded from
+// Provider.CheckSessionIframe, with the provider's origin as the target origin:
+//
+//	opFrame.postMessage(message, opOrigin);
+func CheckSessionMessage(clientID, sessionState string) string {
+	return clientID + " " + sessionState
+}
@@ -0,0 +1,49 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckSessionIframe(t *testing.T) {
+	var issuer string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{
+			"issuer": %[1]q,
+			"authorization_endpoint": "%[1]s/auth",
+			"token_endpoint": "%[1]s/token",
+			"jwks_uri": "%[1]s/keys",
+			"check_session_iframe": "%[1]s/session/check"
+		}`, issuer)
+	}))
+	defer s.Close()
+	issuer = s.URL
+
+	p, err := NewProvider(context.Background(), issuer)
+	if err != nil {
+		t.Fatalf("creating provider: %v", err)
+	}
+	if got, want := p.CheckSessionIframe(), issuer+"/session/check"; got != want {
+		t.Errorf("unexpected check_session_iframe, got=%q, want=%q", got, want)
+	}
+
+	config := &ProviderConfig{CheckSessionIframe: "https://op/check"}
+	if got := config.NewProvider(context.Background()).CheckSessionIframe(); got != "https://op/check" {
+		t.Errorf("unexpected check_session_iframe from config %q", got)
+	}
+}
+
+func TestCheckSessionMessage(t *testing.T) {
+	r := httptest.NewRequest("GET", "/callback?code=abc&state=xyz&session_state=c1d2.salt", nil)
+	resp, err := ParseAuthorizationResponse(r)
+	if err != nil {
+		t.Fatalf("parsing response: %v", err)
+	}
+	if got, want := CheckSessionMessage("client", resp.SessionState), "client c1d2.salt"; got != want {
+		t.Errorf("unexpected message, got=%q, want=%q", got, want)
+	}
+}
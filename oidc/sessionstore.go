@@ -0,0 +1,389 @@
+package oidc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNoSession is returned by a SessionStore when the request has no session,
+// or its session has expired or can't be decrypted.
+var ErrNoSession = errors.New("oidc: no session")
+
+// Session is the state a relying party keeps for a browser between requests:
+// the tokens of a logged in user, or the state, nonce and PKCE verifier of a
+// login in progress.
+type Session struct {
+	IDToken      string    `json:"id_token,omitempty"`
+	AccessToken  string    `json:"access_token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+
+	// Values holds any other state the application wants to keep.
+	Values map[string]string `json:"values,omitempty"`
+}
+
+// clone returns a deep copy of the session.
+func (s *Session) clone() *Session {
+	c := *s
+	if s.Values != nil {
+		c.Values = make(map[string]string, len(s.Values))
+		for k, v := range s.Values {
+			c.Values[k] = v
+		}
+	}
+	return &c
+}
+
+// SessionStore loads and saves the Session of a request. Implementations in
+// this package are CookieStore, which keeps the session in the browser, and
+// MemoryStore, which keeps it in the process and only sends the browser an ID.
+// The redisstore module keeps sessions in Redis, shared between replicas.
+type SessionStore interface {
+	// Load returns the session of the request, or ErrNoSession.
+	Load(r *http.Request) (*Session, error)
+	// Save stores the session and sets the cookies referencing it.
+	Save(w http.ResponseWriter, r *http.Request, s *Session) error
+	// Delete removes the session of the request and clears its cookies.
+	Delete(w http.ResponseWriter, r *http.Request) error
+}
+
+// SessionCookie configures the cookies set by a SessionStore. The zero value
+// sets a Secure, HttpOnly, SameSite=Lax cookie named "oidc_session" on "/".
+type SessionCookie struct {
+	// Name of the cookie. Defaults to "oidc_session".
+	Name   string
+	Path   string
+	Domain string
+	// MaxAge is the lifetime of a session. Zero sets a browser session cookie,
+	// and for MemoryStore keeps sessions for 24 hours.
+	MaxAge time.Duration
+	// SameSite defaults to http.SameSiteLaxMode, which still sends the cookie
+	// when the provider redirects back to the client.
+	SameSite http.SameSite
+	// Insecure omits the Secure attribute, for serving over plain HTTP during
+	// development.
+	Insecure bool
+}
+
+func (c *SessionCookie) name() string {
+	if c.Name == "" {
+		return "oidc_session"
+	}
+	return c.Name
+}
+
+// CookieName returns the name of the session cookie, for SessionStore
+// implementations in other packages.
+func (c *SessionCookie) CookieName() string {
+	return c.name()
+}
+
+// Cookie returns the session cookie holding the value, with the configured
+// attributes, for SessionStore implementations in other packages. A negative
+// maxAge deletes the cookie, and zero sets a browser session cookie.
+func (c *SessionCookie) Cookie(value string, maxAge time.Duration) *http.Cookie {
+	return c.cookie(c.name(), value, maxAge)
+}
+
+// cookie returns a cookie with the configured attributes. A negative maxAge
+// deletes the cookie.
+func (c *SessionCookie) cookie(name, value string, maxAge time.Duration) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		Secure:   !c.Insecure,
+		HttpOnly: true,
+		SameSite: c.SameSite,
+	}
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	if cookie.SameSite == 0 {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	switch {
+	case maxAge < 0:
+		cookie.MaxAge = -1
+	case maxAge > 0:
+		cookie.MaxAge = int(maxAge / time.Second)
+	}
+	return cookie
+}
+
+const (
+	// maxCookieChunk is the most session data set in a single cookie, leaving
+	// room for the name and attributes within the 4096 bytes browsers accept.
+	maxCookieChunk = 3800
+	// maxCookieChunks bounds the number of cookies a session is split into.
+	maxCookieChunks = 10
+)
+
+// CookieStore is a SessionStore that keeps sessions in the browser, encrypted
+// and authenticated with AES-GCM. Sessions too large for one cookie are split
+// across several, named "oidc_session", "oidc_session.1", and so on.
+//
+// Keys are tried in order when decrypting, and the first encrypts new sessions,
+// so a key is rotated by prepending its replacement:
+//
+//	store, err := oidc.NewCookieStore(newKey, oldKey)
+//	if err != nil {
+//		// handle error
+//	}
+//	store.MaxAge = 8 * time.Hour
+//
+// Sessions are bound to the cookie name, and expire after MaxAge even if the
+// browser keeps the cookie. Like any cookie session, a CookieStore can't revoke
+// a copied session before it expires; use MemoryStore if that's required.
+type CookieStore struct {
+	SessionCookie
+
+	aeads []cipher.AEAD
+}
+
+// NewCookieStore returns a store using the given AES keys, each 16, 24 or 32
+// bytes long.
+func NewCookieStore(keys ...[]byte) (*CookieStore, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("oidc: cookie store requires a key")
+	}
+	s := &CookieStore{}
+	for i, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: cookie store key %d: %v", i, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: cookie store key %d: %v", i, err)
+		}
+		s.aeads = append(s.aeads, aead)
+	}
+	return s, nil
+}
+
+// cookieSession is the plaintext of a session cookie.
+type cookieSession struct {
+	Session *Session `json:"s"`
+	Created int64    `json:"t"`
+}
+
+// chunkName returns the name of the i-th cookie of a session.
+func (s *CookieStore) chunkName(i int) string {
+	if i == 0 {
+		return s.name()
+	}
+	return s.name() + "." + strconv.Itoa(i)
+}
+
+// Load decrypts the session of the request.
+func (s *CookieStore) Load(r *http.Request) (*Session, error) {
+	var value []byte
+	for i := 0; i < maxCookieChunks; i++ {
+		c, err := r.Cookie(s.chunkName(i))
+		if err != nil {
+			break
+		}
+		value = append(value, c.Value...)
+	}
+	if len(value) == 0 {
+		return nil, ErrNoSession
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(string(value))
+	if err != nil {
+		return nil, ErrNoSession
+	}
+	var plaintext []byte
+	for _, aead := range s.aeads {
+		if len(sealed) < aead.NonceSize() {
+			break
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if plaintext, err = aead.Open(nil, nonce, ciphertext, []byte(s.name())); err == nil {
+			break
+		}
+	}
+	if plaintext == nil {
+		return nil, ErrNoSession
+	}
+	var cs cookieSession
+	if err := json.Unmarshal(plaintext, &cs); err != nil || cs.Session == nil {
+		return nil, ErrNoSession
+	}
+	if s.MaxAge > 0 && !clockNow(r.Context()).Before(time.Unix(cs.Created, 0).Add(s.MaxAge)) {
+		return nil, ErrNoSession
+	}
+	return cs.Session, nil
+}
+
+// Save encrypts the session into the response's cookies, and clears cookies
+// left from a previous, larger session.
+func (s *CookieStore) Save(w http.ResponseWriter, r *http.Request, session *Session) error {
+	plaintext, err := json.Marshal(cookieSession{Session: session, Created: clockNow(r.Context()).Unix()})
+	if err != nil {
+		return fmt.Errorf("oidc: encoding session: %v", err)
+	}
+	aead := s.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("oidc: generating nonce: %v", err)
+	}
+	value := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, []byte(s.name())))
+
+	n := (len(value) + maxCookieChunk - 1) / maxCookieChunk
+	if n > maxCookieChunks {
+		return fmt.Errorf("oidc: session of %d bytes is too large for cookies", len(value))
+	}
+	for i := 0; i < n; i++ {
+		chunk := value[i*maxCookieChunk:]
+		if len(chunk) > maxCookieChunk {
+			chunk = chunk[:maxCookieChunk]
+		}
+		http.SetCookie(w, s.cookie(s.chunkName(i), chunk, s.MaxAge))
+	}
+	s.clear(w, r, n)
+	return nil
+}
+
+// Delete clears the session's cookies.
+func (s *CookieStore) Delete(w http.ResponseWriter, r *http.Request) error {
+	s.clear(w, r, 0)
+	return nil
+}
+
+// clear deletes the request's session cookies from the n-th on.
+func (s *CookieStore) clear(w http.ResponseWriter, r *http.Request, n int) {
+	for i := n; i < maxCookieChunks; i++ {
+		if _, err := r.Cookie(s.chunkName(i)); err != nil {
+			break
+		}
+		http.SetCookie(w, s.cookie(s.chunkName(i), "", -1))
+	}
+}
+
+// defaultMemorySessionAge is the lifetime of MemoryStore sessions when MaxAge
+// isn't set.
+const defaultMemorySessionAge = 24 * time.Hour
+
+// memorySweepInterval is how often Save removes expired MemoryStore sessions
+// that weren't loaded again, so sweeping doesn't cost every login a pass over
+// all sessions.
+const memorySweepInterval = time.Minute
+
+// MemoryStore is a SessionStore that keeps sessions in memory, sending the
+// browser only a random session ID. Sessions are lost when the process exits
+// and aren't shared between replicas, so it suits development and single
+// instance deployments.
+//
+// A new ID is issued every time a session is saved, so an ID obtained before
+// login can't be used to take over the session after it.
+type MemoryStore struct {
+	SessionCookie
+
+	mu       sync.Mutex
+	sessions map[string]memorySession
+	// nextSweep is when Save next removes expired sessions.
+	nextSweep time.Time
+}
+
+type memorySession struct {
+	session *Session
+	expires time.Time
+}
+
+// NewMemoryStore returns an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memorySession)}
+}
+
+func (s *MemoryStore) maxAge() time.Duration {
+	if s.MaxAge > 0 {
+		return s.MaxAge
+	}
+	return defaultMemorySessionAge
+}
+
+// Len returns the number of sessions in the store, including expired sessions
+// that haven't been removed yet. Expired sessions are removed when they're
+// loaded, and at most a minute after they expire by Save.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Load returns the session referenced by the request's cookie.
+func (s *MemoryStore) Load(r *http.Request) (*Session, error) {
+	c, err := r.Cookie(s.name())
+	if err != nil {
+		return nil, ErrNoSession
+	}
+	now := clockNow(r.Context())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.sessions[c.Value]
+	if !ok {
+		return nil, ErrNoSession
+	}
+	if !now.Before(e.expires) {
+		delete(s.sessions, c.Value)
+		return nil, ErrNoSession
+	}
+	// Return a copy so callers must Save to modify the stored session.
+	return e.session.clone(), nil
+}
+
+// Save stores the session under a new ID, replacing the request's session.
+func (s *MemoryStore) Save(w http.ResponseWriter, r *http.Request, session *Session) error {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return fmt.Errorf("oidc: generating session id: %v", err)
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+	now := clockNow(r.Context())
+	stored := session.clone()
+
+	s.mu.Lock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]memorySession)
+	}
+	if c, err := r.Cookie(s.name()); err == nil {
+		delete(s.sessions, c.Value)
+	}
+	if !now.Before(s.nextSweep) {
+		for k, e := range s.sessions {
+			if !now.Before(e.expires) {
+				delete(s.sessions, k)
+			}
+		}
+		s.nextSweep = now.Add(memorySweepInterval)
+	}
+	s.sessions[id] = memorySession{session: stored, expires: now.Add(s.maxAge())}
+	s.mu.Unlock()
+
+	http.SetCookie(w, s.cookie(s.name(), id, s.MaxAge))
+	return nil
+}
+
+// Delete removes the request's session and clears its cookie.
+func (s *MemoryStore) Delete(w http.ResponseWriter, r *http.Request) error {
+	if c, err := r.Cookie(s.name()); err == nil {
+		s.mu.Lock()
+		delete(s.sessions, c.Value)
+		s.mu.Unlock()
+	}
+	http.SetCookie(w, s.cookie(s.name(), "", -1))
+	return nil
+}
@@ -0,0 +1,206 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTrip saves the session to a response and returns a request carrying the
+// resulting cookies.
+func roundTrip(t *testing.T, store SessionStore, ctx context.Context, prev *http.Request, s *Session) *http.Request {
+	t.Helper()
+	if prev == nil {
+		prev = httptest.NewRequest("GET", "/", nil)
+	}
+	w := httptest.NewRecorder()
+	if err := store.Save(w, prev.WithContext(ctx), s); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge >= 0 {
+			r.AddCookie(c)
+		}
+	}
+	return r.WithContext(ctx)
+}
+
+func TestCookieStore(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+	store, err := NewCookieStore(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.MaxAge = time.Hour
+
+	now := time.Unix(1700000000, 0)
+	ctx := ClockContext(context.Background(), func() time.Time { return now })
+
+	r := roundTrip(t, store, ctx, nil, &Session{IDToken: "id", Values: map[string]string{"state": "s"}})
+	cookies := r.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "oidc_session" || strings.Contains(cookies[0].Value, "state") {
+		t.Fatalf("unexpected cookies %v", cookies)
+	}
+	got, err := store.Load(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.IDToken != "id" || got.Values["state"] != "s" {
+		t.Errorf("unexpected session %+v", got)
+	}
+
+	// Rotating keys still accepts sessions encrypted with the old key.
+	rotated, err := NewCookieStore(newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated.MaxAge = time.Hour
+	if _, err := rotated.Load(r); err != nil {
+		t.Errorf("load with rotated keys: %v", err)
+	}
+	other, err := NewCookieStore(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Load(r); !errors.Is(err, ErrNoSession) {
+		t.Errorf("load with unknown key: got %v, want ErrNoSession", err)
+	}
+
+	// Sessions are bound to the cookie name.
+	renamed := httptest.NewRequest("GET", "/", nil)
+	renamed.AddCookie(&http.Cookie{Name: "other", Value: cookies[0].Value})
+	otherName, _ := NewCookieStore(oldKey)
+	otherName.Name = "other"
+	if _, err := otherName.Load(renamed); !errors.Is(err, ErrNoSession) {
+		t.Errorf("load under another name: got %v, want ErrNoSession", err)
+	}
+
+	// Large sessions are split across cookies, and shrinking them clears the
+	// extra cookies.
+	large := &Session{IDToken: strings.Repeat("x", 2*maxCookieChunk)}
+	r = roundTrip(t, store, ctx, r, large)
+	if n := len(r.Cookies()); n != 3 {
+		t.Errorf("got %d cookies for a large session, want 3", n)
+	}
+	if got, err := store.Load(r); err != nil || got.IDToken != large.IDToken {
+		t.Errorf("load large session: %v", err)
+	}
+	w := httptest.NewRecorder()
+	if err := store.Save(w, r, &Session{IDToken: "small"}); err != nil {
+		t.Fatal(err)
+	}
+	var deleted int
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge < 0 {
+			deleted++
+		}
+	}
+	if deleted != 2 {
+		t.Errorf("got %d deleted cookies, want 2", deleted)
+	}
+	if err := store.Save(httptest.NewRecorder(), r, &Session{IDToken: strings.Repeat("x", maxCookieChunks*maxCookieChunk)}); err == nil {
+		t.Error("expected error saving an oversized session")
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := store.Load(r); !errors.Is(err, ErrNoSession) {
+		t.Errorf("load expired session: got %v, want ErrNoSession", err)
+	}
+
+	if _, err := NewCookieStore([]byte("short")); err == nil {
+		t.Error("expected error for invalid key")
+	}
+}
+
+func TestSessionCookieDefaults(t *testing.T) {
+	store, err := NewCookieStore(bytes.Repeat([]byte{1}, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	if err := store.Save(w, httptest.NewRequest("GET", "/", nil), &Session{}); err != nil {
+		t.Fatal(err)
+	}
+	c := w.Result().Cookies()[0]
+	if !c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode || c.Path != "/" || c.MaxAge != 0 {
+		t.Errorf("unexpected cookie attributes %+v", c)
+	}
+
+	config := SessionCookie{Name: "sid", Path: "/app", MaxAge: time.Hour}
+	if c := config.Cookie("id", config.MaxAge); c.Name != config.CookieName() || c.Name != "sid" || c.Value != "id" || c.Path != "/app" || c.MaxAge != 3600 {
+		t.Errorf("unexpected cookie %+v", c)
+	}
+	if c := config.Cookie("", -1); c.MaxAge != -1 {
+		t.Errorf("expected deleting cookie, got %+v", c)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	store.MaxAge = time.Hour
+
+	now := time.Unix(1700000000, 0)
+	ctx := ClockContext(context.Background(), func() time.Time { return now })
+
+	r := roundTrip(t, store, ctx, nil, &Session{Values: map[string]string{"state": "s"}})
+	got, err := store.Load(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Values["state"] = "modified"
+	if got, _ := store.Load(r); got.Values["state"] != "s" {
+		t.Error("modifying a loaded session changed the stored session")
+	}
+
+	// Saving issues a new ID and invalidates the old one.
+	next := roundTrip(t, store, ctx, r, &Session{IDToken: "id"})
+	if _, err := store.Load(r); !errors.Is(err, ErrNoSession) {
+		t.Errorf("load replaced session: got %v, want ErrNoSession", err)
+	}
+	if got, err := store.Load(next); err != nil || got.IDToken != "id" {
+		t.Errorf("load saved session: %v", err)
+	}
+	if store.Len() != 1 {
+		t.Errorf("got %d sessions, want 1", store.Len())
+	}
+
+	w := httptest.NewRecorder()
+	if err := store.Delete(w, next); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(next); !errors.Is(err, ErrNoSession) {
+		t.Errorf("load deleted session: got %v, want ErrNoSession", err)
+	}
+
+	r = roundTrip(t, store, ctx, nil, &Session{})
+	now = now.Add(time.Hour)
+	if _, err := store.Load(r); !errors.Is(err, ErrNoSession) {
+		t.Errorf("load expired session: got %v, want ErrNoSession", err)
+	}
+	if store.Len() != 0 {
+		t.Errorf("expired session wasn't removed")
+	}
+
+	// Sessions that aren't loaded again are swept by Save, at most once per
+	// sweep interval.
+	roundTrip(t, store, ctx, nil, &Session{})
+	now = now.Add(time.Hour - 30*time.Second)
+	roundTrip(t, store, ctx, nil, &Session{})
+	now = now.Add(40 * time.Second)
+	roundTrip(t, store, ctx, nil, &Session{})
+	if store.Len() != 3 {
+		t.Errorf("got %d sessions before the sweep interval passed, want 3", store.Len())
+	}
+	now = now.Add(memorySweepInterval)
+	roundTrip(t, store, ctx, nil, &Session{})
+	if store.Len() != 3 {
+		t.Errorf("got %d sessions after sweeping, want 3", store.Len())
+	}
+}
@@ -0,0 +1,225 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"golang.org/x/oauth2"
+)
+
+// Signer signs JWTs created by the client, such as private_key_jwt client
+// assertions and DPoP proofs. Implementations may keep the private key outside of the process,
+// for example in a cloud KMS or an HSM accessed through PKCS#11.
+//
+// Many KMS and PKCS#11 libraries expose keys as a crypto.Signer, which can be
+// adapted with NewCryptoSigner. The awskms, gcpkms, azurekeyvault and
+// oidcpkcs11 modules provide signers for AWS KMS, Google Cloud KMS, Azure Key
+// Vault and PKCS#11 tokens.
+type Signer interface {
+	// Algorithm returns the JWS algorithm of signatures, such as "RS256".
+	Algorithm() string
+	// KeyID returns the kid header value for signed tokens. It may be empty.
+	KeyID() string
+	// Sign returns the JWS signature of the signing input. ECDSA signatures
+	// use the fixed size r||s encoding required by JWS, not ASN.1.
+	Sign(ctx context.Context, signingInput []byte) ([]byte, error)
+}
+
+// ContextSigner is a crypto.Signer that can also sign with a context, such as a
+// key held by a cloud KMS or an HSM, whose signing requests should be canceled
+// with the JWT being signed. Signers returned by NewCryptoSigner use
+// SignContext when their key implements it.
+type ContextSigner interface {
+	crypto.Signer
+	SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// NewCryptoSigner returns a Signer backed by a crypto.Signer. The key must be an
+// RSA, ECDSA or Ed25519 key compatible with the algorithm, which must be
+// approved if FIPS mode is enabled.
+func NewCryptoSigner(key crypto.Signer, alg, keyID string) (Signer, error) {
+	if err := checkFIPS(alg); err != nil {
+		return nil, err
+	}
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case RS256, RS384, RS512, PS256, PS384, PS512:
+		default:
+			return nil, fmt.Errorf("oidc: rsa key can't sign %s", alg)
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsaCurveMatches(alg, pub) {
+			return nil, fmt.Errorf("oidc: ecdsa key can't sign %s", alg)
+		}
+	case ed25519.PublicKey:
+		if alg != EdDSA {
+			return nil, fmt.Errorf("oidc: ed25519 key can't sign %s", alg)
+		}
+	default:
+		return nil, fmt.Errorf("oidc: unsupported key type %T", pub)
+	}
+	return &cryptoSigner{key: key, alg: alg, keyID: keyID}, nil
+}
+
+func ecdsaCurveMatches(alg string, pub *ecdsa.PublicKey) bool {
+	switch alg {
+	case ES256:
+		return pub.Curve.Params().BitSize == 256
+	case ES384:
+		return pub.Curve.Params().BitSize == 384
+	case ES512:
+		return pub.Curve.Params().BitSize == 521
+	}
+	return false
+}
+
+type cryptoSigner struct {
+	key   crypto.Signer
+	alg   string
+	keyID string
+}
+
+func (s *cryptoSigner) Algorithm() string { return s.alg }
+func (s *cryptoSigner) KeyID() string     { return s.keyID }
+
+// Public returns the public key of the signer, as embedded in DPoP proofs.
+func (s *cryptoSigner) Public() crypto.PublicKey { return s.key.Public() }
+
+func (s *cryptoSigner) sign(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if cs, ok := s.key.(ContextSigner); ok {
+		return cs.SignContext(ctx, digest, opts)
+	}
+	return s.key.Sign(rand.Reader, digest, opts)
+}
+
+func (s *cryptoSigner) Sign(ctx context.Context, signingInput []byte) ([]byte, error) {
+	if s.alg == EdDSA {
+		return s.sign(ctx, signingInput, crypto.Hash(0))
+	}
+	hash, _ := hashForAlg(s.alg)
+	h := hash.New()
+	h.Write(signingInput) // hash documents that Write will never return an error
+	digest := h.Sum(nil)
+
+	var opts crypto.SignerOpts = hash
+	if s.alg[0] == 'P' {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+	}
+	sig, err := s.sign(ctx, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+	if s.alg[0] != 'E' {
+		return sig, nil
+	}
+
+	// crypto.Signer returns ASN.1 encoded ECDSA signatures.
+	var esig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(sig, &esig); err != nil {
+		return nil, fmt.Errorf("oidc: malformed ecdsa signature: %v", err)
+	}
+	size := (s.key.Public().(*ecdsa.PublicKey).Curve.Params().BitSize + 7) / 8
+	out := make([]byte, 2*size)
+	esig.R.FillBytes(out[:size])
+	esig.S.FillBytes(out[size:])
+	return out, nil
+}
+
+// SignJWT signs the claims as a compact JWT using the signer.
+func SignJWT(ctx context.Context, s Signer, claims interface{}) (string, error) {
+	return signJWT(ctx, s, "JWT", nil, claims)
+}
+
+// signJWT signs the claims as a compact JWT with the given "typ" header, and
+// the public key in the "jwk" header if set.
+func signJWT(ctx context.Context, s Signer, typ string, jwk *jose.JSONWebKey, claims interface{}) (string, error) {
+	header, err := json.Marshal(struct {
+		Algorithm string           `json:"alg"`
+		KeyID     string           `json:"kid,omitempty"`
+		Type      string           `json:"typ"`
+		JWK       *jose.JSONWebKey `json:"jwk,omitempty"`
+	}{s.Algorithm(), s.KeyID(), typ, jwk})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to encode claims: %v", err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := s.Sign(ctx, []byte(signingInput))
+	if err != nil {
+		return "", fmt.Errorf("oidc: signing jwt: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// ClientAssertionType is the client_assertion_type of JWT client assertions.
+const ClientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// ClientAssertion returns a signed private_key_jwt client assertion for
+// authenticating to the provider's token endpoint. The assertion is valid for
+// the provided lifetime, or one minute if zero.
+//
+//	assertion, err := oidc.ClientAssertion(ctx, signer, clientID, provider.Endpoint().TokenURL, 0)
+//	if err != nil {
+//		// handle error
+//	}
+//	oauth2Token, err := oauth2Config.Exchange(ctx, code, oidc.ClientAssertionOptions(assertion)...)
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication
+func ClientAssertion(ctx context.Context, s Signer, clientID, audience string, lifetime time.Duration) (string, error) {
+	if clientID == "" || audience == "" {
+		return "", errors.New("oidc: client assertion requires a client ID and audience")
+	}
+	if lifetime == 0 {
+		lifetime = time.Minute
+	}
+	jti, err := newJTI()
+	if err != nil {
+		return "", err
+	}
+	iat := clockNow(ctx)
+	return SignJWT(ctx, s, map[string]interface{}{
+		"iss": clientID,
+		"sub": clientID,
+		"aud": audience,
+		"jti": jti,
+		"iat": iat.Unix(),
+		"exp": iat.Add(lifetime).Unix(),
+	})
+}
+
+// ClientAssertionOptions returns the token request parameters that send a client
+// assertion. The oauth2.Config used with them should have an empty ClientSecret.
+func ClientAssertionOptions(assertion string) []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("client_assertion_type", ClientAssertionType),
+		oauth2.SetAuthURLParam("client_assertion", assertion),
+	}
+}
+
+// newJTI returns a random "jti" claim.
+func newJTI() (string, error) {
+	jti := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, jti); err != nil {
+		return "", fmt.Errorf("oidc: generating jti: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(jti), nil
+}
@@ -0,0 +1,138 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+)
+
+func TestCryptoSigner(t *testing.T) {
+	rsaKey, ecKey, edKey := newRSAKey(t), newECDSAKey(t), newEdDSAKey(t)
+	tests := []struct {
+		key *signingKey
+		alg string
+	}{
+		{rsaKey, RS256},
+		{rsaKey, RS512},
+		{rsaKey, PS256},
+		{ecKey, ES256},
+		{edKey, EdDSA},
+	}
+	for _, test := range tests {
+		signer, err := NewCryptoSigner(test.key.priv.(crypto.Signer), test.alg, "kid1")
+		if fipsRejects(test.alg) {
+			if err == nil {
+				t.Errorf("%s: expected signer to be refused in FIPS mode", test.alg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: creating signer: %v", test.alg, err)
+			continue
+		}
+		raw, err := SignJWT(context.Background(), signer, map[string]string{"sub": "user"})
+		if err != nil {
+			t.Errorf("%s: signing: %v", test.alg, err)
+			continue
+		}
+		jws, err := parseCompactJWS(raw)
+		if err != nil {
+			t.Errorf("%s: parsing: %v", test.alg, err)
+			continue
+		}
+		header, err := jws.decodeHeader()
+		if err != nil || header.Algorithm != test.alg || header.KeyID != "kid1" || header.Type != "JWT" {
+			t.Errorf("%s: unexpected header %+v, err=%v", test.alg, header, err)
+		}
+		if payload, err := jws.verify(context.Background(), test.key.pub); err != nil || string(payload) != `{"sub":"user"}` {
+			t.Errorf("%s: verifying signature: payload=%s err=%v", test.alg, payload, err)
+		}
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []struct {
+		key crypto.Signer
+		alg string
+	}{
+		{rsaKey.priv.(crypto.Signer), ES256},
+		{p384, ES256},
+		{edKey.priv.(crypto.Signer), RS256},
+	} {
+		if _, err := NewCryptoSigner(bad.key, bad.alg, ""); err == nil {
+			t.Errorf("expected %T to be rejected for %s", bad.key, bad.alg)
+		}
+	}
+}
+
+// contextKeySigner is a ContextSigner recording the contexts it signs with.
+type contextKeySigner struct {
+	crypto.Signer
+	ctxs []context.Context
+}
+
+func (s *contextKeySigner) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.ctxs = append(s.ctxs, ctx)
+	return s.Sign(rand.Reader, digest, opts)
+}
+
+func TestCryptoSignerContext(t *testing.T) {
+	key := newECDSAKey(t)
+	remote := &contextKeySigner{Signer: key.priv.(crypto.Signer)}
+	signer, err := NewCryptoSigner(remote, ES256, "kid1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	raw, err := SignJWT(ctx, signer, map[string]string{"iss": "https://foo", "aud": "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remote.ctxs) != 1 || remote.ctxs[0].Value(ctxKey{}) != "request" {
+		t.Errorf("expected SignContext to be called with the signing context")
+	}
+	keySet := &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}
+	if _, err := keySet.VerifySignature(ctx, raw); err != nil {
+		t.Errorf("verifying signature: %v", err)
+	}
+}
+
+func TestClientAssertion(t *testing.T) {
+	key := newECDSAKey(t)
+	signer, err := NewCryptoSigner(key.priv.(crypto.Signer), ES256, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	ctx := ClockContext(context.Background(), func() time.Time { return now })
+	raw, err := ClientAssertion(ctx, signer, "client1", "https://op/token", 0)
+	if err != nil {
+		t.Fatalf("creating assertion: %v", err)
+	}
+
+	verifier := NewVerifier("client1", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		ClientID:             "https://op/token",
+		SupportedSigningAlgs: []string{ES256},
+		Now:                  func() time.Time { return now },
+	})
+	token, err := verifier.Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("verifying assertion: %v", err)
+	}
+	var claims struct {
+		JTI string `json:"jti"`
+	}
+	if err := token.Claims(&claims); err != nil || claims.JTI == "" {
+		t.Errorf("expected jti claim, err=%v", err)
+	}
+	if token.Subject != "client1" || !token.Expiry.Equal(now.Add(time.Minute)) || !token.IssuedAt.Equal(now) {
+		t.Errorf("unexpected assertion claims %+v", token)
+	}
+}
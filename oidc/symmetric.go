@@ -0,0 +1,67 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+)
+
+// SymmetricKeySet is a KeySet that validates JWTs signed with HMAC algorithms
+// (HS256, HS384 and HS512) using a shared secret, typically the client secret.
+//
+// WARNING: Symmetric signatures don't prove the provider issued a token. Anyone
+// who holds the secret, including every deployment of the client, can mint
+// tokens that pass verification. Only use this key set for legacy providers
+// that can't sign ID Tokens with an asymmetric key, and only for tokens
+// received directly from the token endpoint.
+//
+// The verifier rejects symmetric algorithms unless they're opted into
+// explicitly:
+//
+//	keySet := &oidc.SymmetricKeySet{Secret: []byte(clientSecret)}
+//	verifier := oidc.NewVerifier(issuerURL, keySet, &oidc.Config{
+//		ClientID:                   clientID,
+//		SupportedSigningAlgs:       []string{oidc.HS256},
+//		InsecureAllowSymmetricAlgs: true,
+//	})
+type SymmetricKeySet struct {
+	// Secret is the shared key. RFC 7518 requires it to be at least as long
+	// as the output of the hash function, for example 32 bytes for HS256.
+	Secret []byte
+}
+
+// minSecretSize returns the minimum secret length for an HMAC algorithm.
+func minSecretSize(alg string) int {
+	switch alg {
+	case HS256:
+		return 32
+	case HS384:
+		return 48
+	default:
+		return 64
+	}
+}
+
+// VerifySignature validates the HMAC of the JWT. Signatures are compared in
+// constant time.
+func (s *SymmetricKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	jws, ok := contextJWS(ctx, jwt)
+	if !ok {
+		var err error
+		jws, err = parseCompactJWS(jwt)
+		if err != nil {
+			return nil, fmt.Errorf("parsing jwt: %v", err)
+		}
+		defer jws.release()
+	}
+	header, err := jws.decodeHeader()
+	if err != nil {
+		return nil, err
+	}
+	if !symmetricAlgorithms[header.Algorithm] {
+		return nil, fmt.Errorf("oidc: symmetric key set can't verify algorithm %q", header.Algorithm)
+	}
+	if min := minSecretSize(header.Algorithm); len(s.Secret) < min {
+		return nil, fmt.Errorf("oidc: secret must be at least %d bytes for %s", min, header.Algorithm)
+	}
+	return jws.verify(ctx, s.Secret)
+}
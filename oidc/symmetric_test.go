@@ -0,0 +1,87 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"strings"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+func TestSymmetricKeySet(t *testing.T) {
+	secret := bytes.Repeat([]byte("s"), 64)
+	payload := `{"iss":"https://foo","aud":"client1","exp":4102444800}`
+	sign := func(alg jose.SignatureAlgorithm, key []byte) string {
+		return (&signingKey{priv: key, alg: alg}).sign(t, []byte(payload))
+	}
+	keySet := &SymmetricKeySet{Secret: secret}
+	ctx := context.Background()
+
+	for _, alg := range []jose.SignatureAlgorithm{jose.HS256, jose.HS384, jose.HS512} {
+		got, err := keySet.VerifySignature(ctx, sign(alg, secret))
+		if err != nil {
+			t.Errorf("%s: verifying signature: %v", alg, err)
+			continue
+		}
+		if string(got) != payload {
+			t.Errorf("%s: unexpected payload %s", alg, got)
+		}
+	}
+
+	other := bytes.Repeat([]byte("o"), 64)
+	if _, err := keySet.VerifySignature(ctx, sign(jose.HS256, other)); err == nil {
+		t.Errorf("expected signature with a different secret to fail")
+	}
+
+	short := &SymmetricKeySet{Secret: secret[:16]}
+	if _, err := short.VerifySignature(ctx, sign(jose.HS256, secret[:16])); err == nil || !strings.Contains(err.Error(), "at least 32 bytes") {
+		t.Errorf("expected short secret to be rejected, got %v", err)
+	}
+
+	rsaKey := newRSAKey(t)
+	if _, err := keySet.VerifySignature(ctx, rsaKey.sign(t, []byte(payload))); err == nil {
+		t.Errorf("expected asymmetric algorithm to be rejected")
+	}
+
+	// Symmetric algorithms must be opted into on the verifier.
+	raw := sign(jose.HS256, secret)
+	verifier := NewVerifier("https://foo", keySet, &Config{ClientID: "client1", SupportedSigningAlgs: []string{HS256}})
+	if _, err := verifier.Verify(ctx, raw); err == nil {
+		t.Errorf("expected HS256 to be rejected without InsecureAllowSymmetricAlgs")
+	}
+	verifier = NewVerifier("https://foo", keySet, &Config{
+		ClientID:                   "client1",
+		SupportedSigningAlgs:       []string{HS256},
+		InsecureAllowSymmetricAlgs: true,
+	})
+	if _, err := verifier.Verify(ctx, raw); err != nil {
+		t.Errorf("verifying HS256 token: %v", err)
+	}
+
+	// at_hash and c_hash of HS256 tokens use SHA-256, like those of RS256
+	// tokens.
+	const accessToken, code = "jHkWEdUXMU1BwAsC4vtUsZwnNbJ0RE5aNG5b2FMnBjM", "Qcb0Orv1zh30vL1MPRsbm-diHiMwcLyZvn1arpZv-Jxf_11jnpEX3Tgfvk"
+	payload = `{"iss":"https://foo","aud":"client1","exp":4102444800,"at_hash":"hLsDo1eJK2x_1D4U6RWwRg","c_hash":"LDktKdoQak3Pk0cnXxCltA"}`
+	idToken, err := verifier.Verify(ctx, sign(jose.HS256, secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idToken.VerifyAccessToken(accessToken); err != nil {
+		t.Errorf("verifying HS256 at_hash: %v", err)
+	}
+	if err := idToken.VerifyCode(code); err != nil {
+		t.Errorf("verifying HS256 c_hash: %v", err)
+	}
+	if err := idToken.VerifyAccessToken("other"); err == nil {
+		t.Errorf("expected mismatched access token to be rejected")
+	}
+
+	// Public key sets must never accept an HMAC signature, even if an
+	// attacker uses the public key as the secret.
+	static := &StaticKeySet{PublicKeys: []crypto.PublicKey{rsaKey.pub}}
+	if _, err := static.VerifySignature(ctx, raw); err == nil {
+		t.Errorf("expected static key set to reject HS256")
+	}
+}
@@ -0,0 +1,185 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrNotCached is returned by a TokenCache that holds no tokens for a key.
+var ErrNotCached = errors.New("oidc: no cached tokens")
+
+// TokenCacheKey identifies the tokens of a login in a TokenCache: the issuer
+// that issued them and the client they were issued to.
+type TokenCacheKey struct {
+	Issuer   string
+	ClientID string
+}
+
+// CachedTokens are the tokens of a login kept by a TokenCache.
+type CachedTokens struct {
+	IDToken      string    `json:"id_token,omitempty"`
+	AccessToken  string    `json:"access_token,omitempty"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+// NewCachedTokens returns the tokens of an OAuth 2.0 token, including the ID
+// Token returned with it.
+func NewCachedTokens(t *oauth2.Token) *CachedTokens {
+	idToken, _ := t.Extra("id_token").(string)
+	return &CachedTokens{
+		IDToken:      idToken,
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.RefreshToken,
+		Expiry:       t.Expiry,
+	}
+}
+
+// Token returns the cached tokens as an OAuth 2.0 token. The ID Token is
+// available through its "id_token" extra value.
+func (c *CachedTokens) Token() *oauth2.Token {
+	t := &oauth2.Token{
+		AccessToken:  c.AccessToken,
+		TokenType:    c.TokenType,
+		RefreshToken: c.RefreshToken,
+		Expiry:       c.Expiry,
+	}
+	if c.IDToken != "" {
+		t = t.WithExtra(map[string]interface{}{"id_token": c.IDToken})
+	}
+	return t
+}
+
+// TokenCache keeps the tokens of logins between runs of command line tools
+// and other native apps, so users don't have to log in again every time. The
+// MemoryTokenCache of this package keeps them in the process; the keychain
+// package keeps them in the operating system's credential store.
+type TokenCache interface {
+	// Load returns the tokens stored for the key, or ErrNotCached.
+	Load(ctx context.Context, key TokenCacheKey) (*CachedTokens, error)
+	// Store stores the tokens for the key, replacing any stored before.
+	Store(ctx context.Context, key TokenCacheKey, tokens *CachedTokens) error
+	// Delete removes the tokens stored for the key, such as when the user
+	// logs out. Deleting tokens that aren't stored isn't an error.
+	Delete(ctx context.Context, key TokenCacheKey) error
+}
+
+// MemoryTokenCache is a TokenCache that keeps tokens in memory, for tests and
+// long-running processes.
+type MemoryTokenCache struct {
+	mu     sync.Mutex
+	tokens map[TokenCacheKey]CachedTokens
+}
+
+// NewMemoryTokenCache returns an empty cache.
+func NewMemoryTokenCache() *MemoryTokenCache {
+	return &MemoryTokenCache{tokens: make(map[TokenCacheKey]CachedTokens)}
+}
+
+// Load returns a copy of the tokens stored for the key, or ErrNotCached.
+func (c *MemoryTokenCache) Load(ctx context.Context, key TokenCacheKey) (*CachedTokens, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tokens, ok := c.tokens[key]
+	if !ok {
+		return nil, ErrNotCached
+	}
+	return &tokens, nil
+}
+
+// Store stores a copy of the tokens for the key.
+func (c *MemoryTokenCache) Store(ctx context.Context, key TokenCacheKey, tokens *CachedTokens) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[key] = *tokens
+	return nil
+}
+
+// Delete removes the tokens stored for the key.
+func (c *MemoryTokenCache) Delete(ctx context.Context, key TokenCacheKey) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tokens, key)
+	return nil
+}
+
+// CachedTokenSource returns a token source starting from the tokens cached for
+// the key, which refreshes them with the config when they expire and stores
+// the refreshed tokens, including rotated refresh tokens, back in the cache.
+// It returns ErrNotCached if the cache holds no tokens, in which case the
+// user must log in, for example with LoopbackLogin or the device
+// authorization grant, and the tokens be stored:
+//
+//	key := oidc.TokenCacheKey{Issuer: issuer, ClientID: config.ClientID}
+//	src, err := oidc.CachedTokenSource(ctx, cache, key, &config)
+//	if errors.Is(err, oidc.ErrNotCached) {
+//		token, err := login(ctx)
+//		if err != nil {
+//			// handle error
+//		}
+//		if err := cache.Store(ctx, key, oidc.NewCachedTokens(token)); err != nil {
+//			// handle error
+//		}
+//		src, err = oidc.CachedTokenSource(ctx, cache, key, &config)
+//	}
+//
+// If a refresh response has no ID Token, the cached ID Token is kept.
+func CachedTokenSource(ctx context.Context, cache TokenCache, key TokenCacheKey, config *oauth2.Config) (oauth2.TokenSource, error) {
+	cached, err := cache.Load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	t := cached.Token()
+	return &cachingTokenSource{
+		ctx:    ctx,
+		cache:  cache,
+		key:    key,
+		src:    config.TokenSource(ctx, t),
+		seen:   t,
+		last:   t,
+		cached: cached,
+	}, nil
+}
+
+type cachingTokenSource struct {
+	ctx   context.Context
+	cache TokenCache
+	key   TokenCacheKey
+	src   oauth2.TokenSource
+
+	mu sync.Mutex
+	// seen is the last token returned by src, and last the token returned
+	// for it, with the cached ID Token.
+	seen, last *oauth2.Token
+	cached     *CachedTokens
+}
+
+func (s *cachingTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	if t == s.seen {
+		return s.last, nil
+	}
+	seen := t
+	cached := NewCachedTokens(t)
+	if cached.IDToken == "" && s.cached.IDToken != "" {
+		cached.IDToken = s.cached.IDToken
+		t = t.WithExtra(map[string]interface{}{"id_token": cached.IDToken})
+	}
+	if err := s.cache.Store(s.ctx, s.key, cached); err != nil {
+		return nil, fmt.Errorf("oidc: caching refreshed tokens: %v", err)
+	}
+	s.seen, s.last, s.cached = seen, t, cached
+	return t, nil
+}
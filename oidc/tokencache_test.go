@@ -0,0 +1,70 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestCachedTokenSource(t *testing.T) {
+	refreshes := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		if r.PostFormValue("grant_type") != "refresh_token" || r.PostFormValue("refresh_token") != "rt1" {
+			t.Errorf("unexpected refresh request %v", r.PostForm)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"at2","token_type":"Bearer","refresh_token":"rt2","expires_in":3600}`))
+	}))
+	defer s.Close()
+	config := &oauth2.Config{ClientID: "cli", Endpoint: oauth2.Endpoint{TokenURL: s.URL, AuthStyle: oauth2.AuthStyleInParams}}
+
+	ctx := context.Background()
+	cache := NewMemoryTokenCache()
+	key := TokenCacheKey{Issuer: "https://foo", ClientID: "cli"}
+	if _, err := CachedTokenSource(ctx, cache, key, config); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("expected ErrNotCached, got %v", err)
+	}
+
+	login := (&oauth2.Token{AccessToken: "at1", RefreshToken: "rt1", Expiry: time.Now().Add(-time.Minute)}).
+		WithExtra(map[string]interface{}{"id_token": "idt"})
+	if err := cache.Store(ctx, key, NewCachedTokens(login)); err != nil {
+		t.Fatal(err)
+	}
+	src, err := CachedTokenSource(ctx, cache, key, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		token, err := src.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.AccessToken != "at2" || token.Extra("id_token") != "idt" {
+			t.Errorf("unexpected refreshed token %+v, id_token=%v", token, token.Extra("id_token"))
+		}
+	}
+	if refreshes != 1 {
+		t.Errorf("expected 1 refresh, got %d", refreshes)
+	}
+
+	cached, err := cache.Load(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.AccessToken != "at2" || cached.RefreshToken != "rt2" || cached.IDToken != "idt" {
+		t.Errorf("expected refreshed tokens to be cached, got %+v", cached)
+	}
+
+	if err := cache.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Load(ctx, key); !errors.Is(err, ErrNotCached) {
+		t.Errorf("expected ErrNotCached after delete, got %v", err)
+	}
+}
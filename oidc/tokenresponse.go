@@ -0,0 +1,203 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// TokenResponse is a successful response of the token endpoint, as described
+// by RFC 6749 section 5.1 and OpenID Connect Core section 3.1.3.3. It's
+// returned by the flow helpers of this package, and exposes the members that
+// oauth2.Token only makes available through Extra.
+//
+// Numeric members are accepted as JSON numbers or strings, since some
+// providers send the latter.
+type TokenResponse struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	// IDToken is the raw "id_token", if the provider issued one. It must be
+	// verified with an IDTokenVerifier before it's used.
+	IDToken string
+
+	// ExpiresIn is the lifetime of the access token, in seconds, or zero if
+	// the provider didn't say.
+	ExpiresIn int64
+	// RefreshExpiresIn is the lifetime of the refresh token, in seconds, as
+	// sent by providers such as Keycloak. Zero if the provider didn't say.
+	RefreshExpiresIn int64
+
+	// Scope is the scope of the access token. Per RFC 6749 it's only sent if
+	// it differs from the requested scope.
+	Scope Scopes
+	// IssuedTokenType is the type of the issued token of an RFC 8693 token
+	// exchange.
+	IssuedTokenType string
+	// AuthorizationDetails holds the "authorization_details" granted to the
+	// access token, as described by RFC 9396.
+	AuthorizationDetails json.RawMessage
+
+	// Extra holds all other members of the response.
+	Extra map[string]json.RawMessage
+
+	// Expiry and RefreshExpiry are the times the tokens expire, computed from
+	// ExpiresIn and RefreshExpiresIn when the response is received by this
+	// package. They aren't part of the JSON representation, and are zero if
+	// the response was decoded with json.Unmarshal.
+	Expiry        time.Time
+	RefreshExpiry time.Time
+}
+
+type tokenResponseJSON struct {
+	AccessToken          string          `json:"access_token"`
+	TokenType            string          `json:"token_type"`
+	RefreshToken         string          `json:"refresh_token,omitempty"`
+	IDToken              string          `json:"id_token,omitempty"`
+	ExpiresIn            json.Number     `json:"expires_in,omitempty"`
+	RefreshExpiresIn     json.Number     `json:"refresh_expires_in,omitempty"`
+	Scope                string          `json:"scope,omitempty"`
+	IssuedTokenType      string          `json:"issued_token_type,omitempty"`
+	AuthorizationDetails json.RawMessage `json:"authorization_details,omitempty"`
+}
+
+var tokenResponseMembers = []string{
+	"access_token", "token_type", "refresh_token", "id_token", "expires_in",
+	"refresh_expires_in", "scope", "issued_token_type", "authorization_details",
+}
+
+// UnmarshalJSON decodes a token response. Unknown members are stored in Extra.
+func (r *TokenResponse) UnmarshalJSON(b []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(b, &members); err != nil {
+		return err
+	}
+	var v struct {
+		tokenResponseJSON
+		Scope *Scopes `json:"scope"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	expiresIn, err := parseSeconds(v.ExpiresIn)
+	if err != nil {
+		return fmt.Errorf("oidc: invalid expires_in: %v", err)
+	}
+	refreshExpiresIn, err := parseSeconds(v.RefreshExpiresIn)
+	if err != nil {
+		return fmt.Errorf("oidc: invalid refresh_expires_in: %v", err)
+	}
+	for _, name := range tokenResponseMembers {
+		delete(members, name)
+	}
+	if len(members) == 0 {
+		members = nil
+	}
+	*r = TokenResponse{
+		AccessToken:          v.AccessToken,
+		TokenType:            v.TokenType,
+		RefreshToken:         v.RefreshToken,
+		IDToken:              v.IDToken,
+		ExpiresIn:            expiresIn,
+		RefreshExpiresIn:     refreshExpiresIn,
+		IssuedTokenType:      v.IssuedTokenType,
+		AuthorizationDetails: v.AuthorizationDetails,
+		Extra:                members,
+	}
+	if v.Scope != nil {
+		r.Scope = *v.Scope
+	}
+	return nil
+}
+
+// MarshalJSON encodes the response with the members of Extra. Known members
+// take precedence over entries of Extra with the same name.
+func (r TokenResponse) MarshalJSON() ([]byte, error) {
+	v := tokenResponseJSON{
+		AccessToken:          r.AccessToken,
+		TokenType:            r.TokenType,
+		RefreshToken:         r.RefreshToken,
+		IDToken:              r.IDToken,
+		IssuedTokenType:      r.IssuedTokenType,
+		AuthorizationDetails: r.AuthorizationDetails,
+	}
+	if r.ExpiresIn != 0 {
+		v.ExpiresIn = json.Number(fmt.Sprint(r.ExpiresIn))
+	}
+	if r.RefreshExpiresIn != 0 {
+		v.RefreshExpiresIn = json.Number(fmt.Sprint(r.RefreshExpiresIn))
+	}
+	if len(r.Scope) > 0 {
+		v.Scope = r.Scope.String()
+	}
+	known, err := json.Marshal(v)
+	if err != nil || len(r.Extra) == 0 {
+		return known, err
+	}
+	members := make(map[string]json.RawMessage, len(r.Extra)+len(tokenResponseMembers))
+	for k, m := range r.Extra {
+		members[k] = m
+	}
+	var knownMembers map[string]json.RawMessage
+	if err := json.Unmarshal(known, &knownMembers); err != nil {
+		return nil, err
+	}
+	for k, m := range knownMembers {
+		members[k] = m
+	}
+	return json.Marshal(members)
+}
+
+// parseSeconds parses a lifetime sent as a JSON number or string.
+func parseSeconds(n json.Number) (int64, error) {
+	if n == "" {
+		return 0, nil
+	}
+	if secs, err := n.Int64(); err == nil {
+		return secs, nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, err
+	}
+	return int64(f), nil
+}
+
+// parseTokenResponse decodes the body of a successful token response received
+// at now.
+func parseTokenResponse(body []byte, now time.Time) (*TokenResponse, error) {
+	var r TokenResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode token response: %v", err)
+	}
+	if r.AccessToken == "" {
+		return nil, errors.New("oidc: token response has no access_token")
+	}
+	if r.ExpiresIn > 0 {
+		r.Expiry = now.Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	if r.RefreshExpiresIn > 0 {
+		r.RefreshExpiry = now.Add(time.Duration(r.RefreshExpiresIn) * time.Second)
+	}
+	return &r, nil
+}
+
+// Token returns the response as an oauth2.Token, for use with the
+// golang.org/x/oauth2 package. All members of the response, including
+// "id_token", are available through the token's Extra method.
+func (r *TokenResponse) Token() *oauth2.Token {
+	t := &oauth2.Token{
+		AccessToken:  r.AccessToken,
+		TokenType:    r.TokenType,
+		RefreshToken: r.RefreshToken,
+		Expiry:       r.Expiry,
+	}
+	var extra map[string]interface{}
+	if b, err := json.Marshal(r); err == nil {
+		json.Unmarshal(b, &extra)
+	}
+	return t.WithExtra(extra)
+}
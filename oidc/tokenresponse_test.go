@@ -0,0 +1,68 @@
+package oidc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTokenResponse(t *testing.T) {
+	body := `{
+		"access_token": "at",
+		"token_type": "Bearer",
+		"refresh_token": "rt",
+		"id_token": "eyJ.eyJ.sig",
+		"expires_in": "300",
+		"refresh_expires_in": 1800,
+		"scope": "openid email",
+		"authorization_details": [{"type":"payment_initiation"}],
+		"session_state": "abc"
+	}`
+	now := time.Unix(1000, 0)
+	r, err := parseTokenResponse([]byte(body), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &TokenResponse{
+		AccessToken:          "at",
+		TokenType:            "Bearer",
+		RefreshToken:         "rt",
+		IDToken:              "eyJ.eyJ.sig",
+		ExpiresIn:            300,
+		RefreshExpiresIn:     1800,
+		Scope:                Scopes{"openid", "email"},
+		AuthorizationDetails: json.RawMessage(`[{"type":"payment_initiation"}]`),
+		Extra:                map[string]json.RawMessage{"session_state": json.RawMessage(`"abc"`)},
+		Expiry:               now.Add(300 * time.Second),
+		RefreshExpiry:        now.Add(1800 * time.Second),
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Fatalf("got %+v, want %+v", r, want)
+	}
+
+	token := r.Token()
+	if token.AccessToken != "at" || !token.Expiry.Equal(want.Expiry) || token.Extra("id_token") != "eyJ.eyJ.sig" || token.Extra("session_state") != "abc" {
+		t.Errorf("unexpected oauth2 token %+v", token)
+	}
+
+	// Round trip through JSON, which drops the computed expiry times.
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got TokenResponse
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want.Expiry, want.RefreshExpiry = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("round trip got %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []string{`{"token_type":"Bearer"}`, `{"access_token":"at","expires_in":"soon"}`, `[]`} {
+		if _, err := parseTokenResponse([]byte(bad), now); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package oidc
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultDiscoveryRetryInterval is the default time a TrustedIssuers verifier
+// waits before retrying a failed discovery of an issuer.
+const DefaultDiscoveryRetryInterval = time.Minute
+
+const (
+	// DefaultMaxTrustedIssuers is the default number of issuers a
+	// TrustedIssuers verifier keeps providers for.
+	DefaultMaxTrustedIssuers = 1000
+	// DefaultDiscoveryRate is the default number of discoveries a
+	// TrustedIssuers verifier starts per minute.
+	DefaultDiscoveryRate = 60
+)
+
+// ErrTooManyDiscoveries is wrapped by errors returned for tokens of issuers
+// that aren't discovered because the discovery rate of a TrustedIssuers
+// verifier was exceeded.
+var ErrTooManyDiscoveries = errors.New("oidc: too many issuer discoveries")
+
+// TrustedIssuers verifies tokens from any of a set of trusted issuers, such as
+// the identity providers of partners whose tokens an API gateway relays. Each
+// issuer is discovered the first time one of its tokens is seen, and its
+// provider and keys are reused afterwards. Tokens of other issuers are rejected
+// with an *UntrustedIssuerError before any request is made.
+//
+//	tenants, err := oidc.NewIssuerTemplate("https://login.microsoftonline.com/{tenantid}/v2.0")
+//	if err != nil {
+//		// handle error
+//	}
+//	trusted := oidc.NewTrustedIssuers(ctx, oidc.AnyIssuer(
+//		oidc.Issuers{"https://accounts.google.com"},
+//		tenants,
+//	), &oidc.Config{ClientID: "https://api.example.com"})
+//
+//	mux.Handle("/api/", oidc.BearerMiddleware(trusted)(apiHandler))
+type TrustedIssuers struct {
+	ctx     context.Context
+	issuers IssuerMatcher
+	config  *Config
+	opts    []ProviderOption
+
+	// RetryInterval is the time to wait before retrying the discovery of an
+	// issuer that failed. Tokens of the issuer are rejected in the meantime.
+	// Defaults to DefaultDiscoveryRetryInterval.
+	RetryInterval time.Duration
+
+	// MaxIssuers bounds the number of issuers whose providers are kept. Once
+	// it's reached, the least recently used issuer is forgotten, and
+	// discovered again when one of its tokens is next seen. Defaults to
+	// DefaultMaxTrustedIssuers.
+	MaxIssuers int
+
+	// DiscoveryRate bounds the number of discoveries started per minute, so
+	// that tokens naming many issuers matched by a template can't cause a
+	// flood of requests. Tokens of issuers that would exceed it are rejected
+	// with an error wrapping ErrTooManyDiscoveries, without a request.
+	// Defaults to DefaultDiscoveryRate.
+	DiscoveryRate int
+
+	mu        sync.Mutex
+	verifiers map[string]*list.Element
+	lru       *list.List
+	// Discoveries that may be started before the rate is exceeded, refilled
+	// continuously since lastRefill.
+	tokens     float64
+	lastRefill time.Time
+}
+
+type trustedIssuer struct {
+	issuer string
+	// Closed once discovery has finished.
+	done     chan struct{}
+	verifier *IDTokenVerifier
+	err      error
+	// Time after which a failed discovery is retried.
+	retryAt time.Time
+}
+
+// NewTrustedIssuers returns a verifier of tokens issued by the matching issuers.
+// The verifier of each issuer uses the configuration. The context and options
+// are used for discovery, and for fetching the issuers' keys.
+func NewTrustedIssuers(ctx context.Context, issuers IssuerMatcher, config *Config, opts ...ProviderOption) *TrustedIssuers {
+	return &TrustedIssuers{
+		ctx:       ctx,
+		issuers:   issuers,
+		config:    config,
+		opts:      opts,
+		verifiers: make(map[string]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+// Verify checks that the token's issuer is trusted, discovers the issuer if
+// it hasn't been seen before, and verifies the token with the issuer's keys.
+func (t *TrustedIssuers) Verify(ctx context.Context, rawToken string, opts ...VerifyOption) (*IDToken, error) {
+	unverified, err := parseUnverified(rawToken, t.config)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := t.Verifier(ctx, unverified.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	return verifier.Verify(ctx, rawToken, opts...)
+}
+
+// Verifier returns the verifier of a trusted issuer, discovering the issuer if
+// needed. It returns an *UntrustedIssuerError if the issuer isn't trusted.
+func (t *TrustedIssuers) Verifier(ctx context.Context, issuer string) (*IDTokenVerifier, error) {
+	if issuer == "" || !t.issuers.MatchIssuer(issuer) {
+		return nil, &UntrustedIssuerError{Issuer: issuer}
+	}
+
+	now := t.now()
+	t.mu.Lock()
+	var entry *trustedIssuer
+	e, ok := t.verifiers[issuer]
+	if ok {
+		entry = e.Value.(*trustedIssuer)
+		select {
+		case <-entry.done:
+			if entry.err != nil && !now.Before(entry.retryAt) {
+				ok = false
+			}
+		default:
+			// Discovery is in progress.
+		}
+	}
+	if ok {
+		t.lru.MoveToFront(e)
+	} else {
+		if !t.allowDiscoveryLocked(now) {
+			t.mu.Unlock()
+			return nil, fmt.Errorf("%w: not discovering %q", ErrTooManyDiscoveries, issuer)
+		}
+		entry = &trustedIssuer{issuer: issuer, done: make(chan struct{})}
+		if e != nil {
+			t.lru.Remove(e)
+		}
+		t.verifiers[issuer] = t.lru.PushFront(entry)
+		t.evictLocked()
+		go t.discover(issuer, entry)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return entry.verifier, nil
+}
+
+// discover runs in its own goroutine so that callers can give up waiting for
+// slow issuers without failing discovery for others.
+func (t *TrustedIssuers) discover(issuer string, entry *trustedIssuer) {
+	defer close(entry.done)
+	p, err := NewProvider(t.ctx, issuer, t.opts...)
+	if err != nil {
+		retry := t.RetryInterval
+		if retry <= 0 {
+			retry = DefaultDiscoveryRetryInterval
+		}
+		entry.err = fmt.Errorf("oidc: discovering trusted issuer %q: %w", issuer, err)
+		entry.retryAt = t.now().Add(retry)
+		return
+	}
+	entry.verifier = p.Verifier(t.config)
+}
+
+// allowDiscoveryLocked reports whether a discovery may be started at now
+// without exceeding the discovery rate, and if so counts it.
+func (t *TrustedIssuers) allowDiscoveryLocked(now time.Time) bool {
+	rate := float64(t.DiscoveryRate)
+	if rate <= 0 {
+		rate = DefaultDiscoveryRate
+	}
+	if t.lastRefill.IsZero() {
+		// The first discoveries may use the whole minute's allowance.
+		t.tokens = rate
+	} else if elapsed := now.Sub(t.lastRefill); elapsed > 0 {
+		t.tokens += elapsed.Minutes() * rate
+	}
+	if t.tokens > rate {
+		t.tokens = rate
+	}
+	t.lastRefill = now
+	if t.tokens < 1 {
+		return false
+	}
+	t.tokens--
+	return true
+}
+
+// evictLocked forgets the least recently used issuers beyond MaxIssuers, so
+// that issuers matched by a template can't grow the cache without bound.
+// Callers waiting on an evicted issuer's discovery still get its result.
+func (t *TrustedIssuers) evictLocked() {
+	max := t.MaxIssuers
+	if max <= 0 {
+		max = DefaultMaxTrustedIssuers
+	}
+	for t.lru.Len() > max {
+		oldest := t.lru.Back()
+		t.lru.Remove(oldest)
+		delete(t.verifiers, oldest.Value.(*trustedIssuer).issuer)
+	}
+}
+
+func (t *TrustedIssuers) now() time.Time {
+	if t.config.Now != nil {
+		return t.config.Now()
+	}
+	return time.Now()
+}
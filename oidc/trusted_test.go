@@ -0,0 +1,161 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// newIssuerServer returns a provider serving discovery and the key's JWKS, and
+// a counter of discovery requests.
+func newIssuerServer(t *testing.T, key *signingKey) (*httptest.Server, *int32) {
+	var discoveries int32
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			atomic.AddInt32(&discoveries, 1)
+			fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":"%s/keys","id_token_signing_alg_values_supported":["RS256"]}`, s.URL, s.URL)
+		case "/keys":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s, &discoveries
+}
+
+func TestTrustedIssuers(t *testing.T) {
+	keyA, keyB := newRSAKey(t), newRSAKey(t)
+	a, discoveriesA := newIssuerServer(t, keyA)
+	b, _ := newIssuerServer(t, keyB)
+	untrusted, discoveriesUntrusted := newIssuerServer(t, keyA)
+
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	trusted := NewTrustedIssuers(ctx, Issuers{a.URL, b.URL, "https://down.example.invalid"}, &Config{
+		ClientID: "api",
+		Now:      func() time.Time { return now },
+	})
+
+	token := func(key *signingKey, iss string) string {
+		return key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"api","sub":"jane","exp":4102444800}`, iss)))
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := trusted.Verify(ctx, token(keyA, a.URL)); err != nil {
+			t.Fatalf("verifying token of first issuer: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(discoveriesA); n != 1 {
+		t.Errorf("got %d discoveries of first issuer, want 1", n)
+	}
+	if _, err := trusted.Verify(ctx, token(keyB, b.URL)); err != nil {
+		t.Errorf("verifying token of second issuer: %v", err)
+	}
+	// Keys of one trusted issuer can't sign tokens of another.
+	if _, err := trusted.Verify(ctx, token(keyA, b.URL)); err == nil {
+		t.Errorf("expected token signed with another issuer's key to be rejected")
+	}
+
+	var untrustedErr *UntrustedIssuerError
+	if _, err := trusted.Verify(ctx, token(keyA, untrusted.URL)); !errors.As(err, &untrustedErr) || untrustedErr.Issuer != untrusted.URL {
+		t.Errorf("expected untrusted issuer error, got %v", err)
+	}
+	if n := atomic.LoadInt32(discoveriesUntrusted); n != 0 {
+		t.Errorf("untrusted issuer was contacted %d times", n)
+	}
+
+	if _, err := trusted.Verify(ctx, token(keyA, "https://down.example.invalid")); err == nil {
+		t.Errorf("expected error for issuer that can't be discovered")
+	}
+	cached := func() *trustedIssuer {
+		trusted.mu.Lock()
+		defer trusted.mu.Unlock()
+		if e, ok := trusted.verifiers["https://down.example.invalid"]; ok {
+			return e.Value.(*trustedIssuer)
+		}
+		return nil
+	}
+	entry := cached()
+	if _, err := trusted.Verifier(ctx, "https://down.example.invalid"); err == nil || cached() != entry {
+		t.Errorf("expected failed discovery to be cached, got %v", err)
+	}
+	now = now.Add(DefaultDiscoveryRetryInterval)
+	trusted.Verifier(ctx, "https://down.example.invalid")
+	if cached() == entry {
+		t.Errorf("expected failed discovery to be retried after the retry interval")
+	}
+}
+
+func TestTrustedIssuersLimits(t *testing.T) {
+	key := newRSAKey(t)
+	a, discoveriesA := newIssuerServer(t, key)
+	b, _ := newIssuerServer(t, key)
+	c, _ := newIssuerServer(t, key)
+
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	trusted := NewTrustedIssuers(ctx, Issuers{a.URL, b.URL, c.URL}, &Config{
+		ClientID: "api",
+		Now:      func() time.Time { return now },
+	})
+	trusted.MaxIssuers = 2
+	trusted.DiscoveryRate = 3
+
+	for _, iss := range []string{a.URL, b.URL, a.URL, c.URL} {
+		if _, err := trusted.Verifier(ctx, iss); err != nil {
+			t.Fatalf("discovering %s: %v", iss, err)
+		}
+	}
+	trusted.mu.Lock()
+	_, hasA := trusted.verifiers[a.URL]
+	_, hasB := trusted.verifiers[b.URL]
+	n := trusted.lru.Len()
+	trusted.mu.Unlock()
+	if n != 2 || !hasA || hasB {
+		t.Errorf("expected the least recently used issuer to be evicted, got %d issuers, a=%t b=%t", n, hasA, hasB)
+	}
+
+	// Three discoveries were started, which is the rate per minute.
+	if _, err := trusted.Verifier(ctx, b.URL); !errors.Is(err, ErrTooManyDiscoveries) {
+		t.Errorf("expected discovery over the rate to be rejected, got %v", err)
+	}
+	if _, err := trusted.Verifier(ctx, a.URL); err != nil {
+		t.Errorf("expected cached issuer to be unaffected by the rate, got %v", err)
+	}
+	now = now.Add(20 * time.Second)
+	if _, err := trusted.Verifier(ctx, b.URL); err != nil {
+		t.Errorf("expected discovery to be allowed once the rate refilled, got %v", err)
+	}
+	if n := atomic.LoadInt32(discoveriesA); n != 1 {
+		t.Errorf("got %d discoveries of first issuer, want 1", n)
+	}
+}
+
+func TestTrustedIssuersConfigLimits(t *testing.T) {
+	key := newRSAKey(t)
+	s, _ := newIssuerServer(t, key)
+
+	ctx := context.Background()
+	trusted := NewTrustedIssuers(ctx, Issuers{s.URL}, &Config{
+		ClientID:             "api",
+		AllowDuplicateClaims: true,
+		Now:                  func() time.Time { return time.Unix(1000, 0) },
+	})
+	token := key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"api","sub":"jane","sub":"jane","exp":4102444800}`, s.URL)))
+	if _, err := ParseUnverified(token); err == nil {
+		t.Fatalf("expected ParseUnverified to reject duplicate claims")
+	}
+	if _, err := trusted.Verify(ctx, token); err != nil {
+		t.Errorf("expected the issuer to be read with the configured limits, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// UnverifiedIDToken holds the header and claims of a JWT that has been parsed
+// WITHOUT verifying its signature or any of its claims. None of its values can
+// be trusted.
+//
+// It's intended for routing decisions that must happen before verification, such
+// as selecting the verifier for a token's issuer or tenant. The token MUST then
+// be verified with an IDTokenVerifier before any of its claims are used.
+type UnverifiedIDToken struct {
+	// Values from the JOSE header.
+	Algorithm string
+	KeyID     string
+	Type      string
+
+	// Values from the claims. These have the same meaning as the fields of
+	// IDToken.
+	Issuer   string
+	Audience []string
+	Subject  string
+	Expiry   time.Time
+	IssuedAt time.Time
+
+	claims []byte
+}
+
+// Claims unmarshals the raw JSON payload of the unverified token into the
+// provided value.
+func (u *UnverifiedIDToken) Claims(v interface{}) error {
+	if u.claims == nil {
+		return errors.New("oidc: claims not set")
+	}
+	return decodeClaims(u.claims, v, false)
+}
+
+// ClaimsUseNumber is like Claims, except that numbers decoded into interface{}
+// values are json.Number, as for IDToken.ClaimsUseNumber.
+func (u *UnverifiedIDToken) ClaimsUseNumber(v interface{}) error {
+	if u.claims == nil {
+		return errors.New("oidc: claims not set")
+	}
+	return decodeClaims(u.claims, v, true)
+}
+
+// ParseUnverified parses a JWT in compact serialization without verifying it.
+// The default size and nesting limits of Config apply, and malformed tokens
+// return a *MalformedTokenError.
+//
+//	unverified, err := oidc.ParseUnverified(rawIDToken)
+//	if err != nil {
+//		// handle error
+//	}
+//	verifier, ok := verifiersByIssuer[unverified.Issuer]
+//	if !ok {
+//		// reject the token
+//	}
+//	idToken, err := verifier.Verify(ctx, rawIDToken)
+func ParseUnverified(rawToken string) (*UnverifiedIDToken, error) {
+	return parseUnverified(rawToken, &Config{})
+}
+
+// parseUnverified is ParseUnverified with the size and nesting limits of the
+// config, for tokens about to be verified with it.
+func parseUnverified(rawToken string, c *Config) (*UnverifiedIDToken, error) {
+	rawToken = trimToken(rawToken)
+	if max := c.maxTokenSize(); len(rawToken) > max {
+		return nil, &MalformedTokenError{Err: fmt.Errorf("token size %d exceeds maximum of %d bytes", len(rawToken), max)}
+	}
+	jws, err := parseCompactJWS(rawToken)
+	if err != nil {
+		return nil, &MalformedTokenError{Err: err}
+	}
+	defer jws.release()
+
+	header, err := jws.decodeHeader()
+	if err != nil {
+		return nil, &MalformedTokenError{Err: err}
+	}
+	if err := c.checkClaims(jws.payload); err != nil {
+		return nil, &MalformedTokenError{Err: err}
+	}
+	var token idToken
+	if err := json.Unmarshal(jws.payload, &token); err != nil {
+		return nil, &MalformedTokenError{Err: fmt.Errorf("failed to unmarshal claims: %v", err)}
+	}
+	return &UnverifiedIDToken{
+		Algorithm: header.Algorithm,
+		KeyID:     header.KeyID,
+		Type:      header.Type,
+		Issuer:    token.Issuer,
+		Audience:  []string(token.Audience),
+		Subject:   token.Subject,
+		Expiry:    time.Time(token.Expiry),
+		IssuedAt:  time.Time(token.IssuedAt),
+		claims:    jws.payload,
+	}, nil
+}
@@ -0,0 +1,41 @@
+package oidc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseUnverified(t *testing.T) {
+	key := newRSAKey(t)
+	key.keyID = "key1"
+	raw := key.sign(t, []byte(`{"iss":"https://foo","aud":["a","b"],"sub":"user","exp":1643414400,"tenant":"t1"}`))
+
+	u, err := ParseUnverified(raw)
+	if err != nil {
+		t.Fatalf("parsing token: %v", err)
+	}
+	if u.Algorithm != RS256 || u.KeyID != "key1" {
+		t.Errorf("unexpected header values alg=%q kid=%q", u.Algorithm, u.KeyID)
+	}
+	if u.Issuer != "https://foo" || u.Subject != "user" || !reflect.DeepEqual(u.Audience, []string{"a", "b"}) {
+		t.Errorf("unexpected claims %+v", u)
+	}
+	if u.Expiry.Unix() != 1643414400 {
+		t.Errorf("unexpected expiry %v", u.Expiry)
+	}
+	var claims struct {
+		Tenant string `json:"tenant"`
+	}
+	if err := u.Claims(&claims); err != nil || claims.Tenant != "t1" {
+		t.Errorf("unexpected custom claims %+v, err=%v", claims, err)
+	}
+
+	for _, bad := range []string{"", "a.b", "a.b.c", raw + ".extra"} {
+		_, err := ParseUnverified(bad)
+		var merr *MalformedTokenError
+		if !errors.As(err, &merr) {
+			t.Errorf("ParseUnverified(%q): expected *MalformedTokenError, got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package vault
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// KeySet is an oidc.KeySet with public keys stored in a Vault secret, for
+// providers whose keys are distributed out of band. The field holds either a
+// JSON Web Key Set or one or more PEM encoded public keys.
+//
+//	client := &vault.Client{Address: vaultAddr, Token: vaultToken}
+//	keySet := vault.NewKeySet(client, "secret/data/oidc/issuer-keys", "jwks")
+//	verifier := oidc.NewVerifier(issuerURL, keySet, config)
+type KeySet struct {
+	leased leasedSecret
+	field  string
+
+	// mu is held while the secret is loaded, so that keys are only replaced
+	// by those of a newer secret.
+	mu   sync.Mutex
+	keys []jose.JSONWebKey
+	// from is the secret keys were parsed from.
+	from *secret
+}
+
+var (
+	_ oidc.KeySet      = (*KeySet)(nil)
+	_ oidc.KeyResolver = (*KeySet)(nil)
+)
+
+// NewKeySet returns a key set that loads keys from a field of the secret at
+// path. For the KV version 2 secrets engine the path includes "data/".
+func NewKeySet(client *Client, path, field string) *KeySet {
+	return &KeySet{leased: leasedSecret{client: client, path: path}, field: field}
+}
+
+// load returns the keys of the current secret, parsing them if the secret
+// changed. A secret whose keys can't be parsed is parsed again by the next
+// call.
+func (k *KeySet) load(ctx context.Context) ([]jose.JSONWebKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	s, err := k.leased.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s == k.from {
+		return k.keys, nil
+	}
+	value, err := s.field(k.field)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseKeys(value)
+	if err != nil {
+		return nil, err
+	}
+	k.keys, k.from = keys, s
+	return keys, nil
+}
+
+// parseKeys parses a JSON Web Key Set or a sequence of PEM public keys. Keys
+// are filtered as oidc.RemoteKeySet filters them.
+func parseKeys(value string) ([]jose.JSONWebKey, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "{") {
+		var jwks jose.JSONWebKeySet
+		if err := json.Unmarshal([]byte(value), &jwks); err != nil {
+			return nil, fmt.Errorf("vault: decoding jwks: %v", err)
+		}
+		keys := oidc.VerificationKeys(jwks.Keys)
+		if len(keys) == 0 {
+			return nil, errors.New("vault: jwks contains no public keys")
+		}
+		return keys, nil
+	}
+
+	var keys []jose.JSONWebKey
+	rest := []byte(value)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("vault: parsing public key: %v", err)
+		}
+		keys = append(keys, jose.JSONWebKey{Key: pub})
+	}
+	keys = oidc.VerificationKeys(keys)
+	if len(keys) == 0 {
+		return nil, errors.New("vault: no public keys found")
+	}
+	return keys, nil
+}
+
+// ResolveKeys returns the keys matching the token's key ID, or all keys if the
+// token or the keys don't have IDs.
+func (k *KeySet) ResolveKeys(ctx context.Context, header oidc.TokenHeader) ([]crypto.PublicKey, error) {
+	keys, err := k.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pubs []crypto.PublicKey
+	for _, key := range keys {
+		if header.KeyID == "" || key.KeyID == "" || key.KeyID == header.KeyID {
+			pubs = append(pubs, key.Key)
+		}
+	}
+	return pubs, nil
+}
+
+// VerifySignature verifies the JWT against the keys stored in Vault.
+func (k *KeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	keys, err := k.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pubs := make([]crypto.PublicKey, len(keys))
+	for i, key := range keys {
+		pubs[i] = key.Key
+	}
+	return (&oidc.StaticKeySet{PublicKeys: pubs}).VerifySignature(ctx, jwt)
+}
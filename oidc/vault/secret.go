@@ -0,0 +1,34 @@
+package vault
+
+import (
+	"context"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// SecretSource is an oidc.ClientSecretSource that loads the client secret from
+// a field of a Vault secret.
+//
+//	secrets := vault.NewSecretSource(client, "secret/data/oidc/client", "client_secret")
+//	config, err := oidc.ConfigWithClientSecret(ctx, &oauth2Config, secrets)
+type SecretSource struct {
+	leased leasedSecret
+	field  string
+}
+
+var _ oidc.ClientSecretSource = (*SecretSource)(nil)
+
+// NewSecretSource returns a source that reads the client secret from a field of
+// the secret at path.
+func NewSecretSource(client *Client, path, field string) *SecretSource {
+	return &SecretSource{leased: leasedSecret{client: client, path: path}, field: field}
+}
+
+// ClientSecret returns the current client secret.
+func (s *SecretSource) ClientSecret(ctx context.Context) (string, error) {
+	sec, err := s.leased.get(ctx)
+	if err != nil {
+		return "", err
+	}
+	return sec.field(s.field)
+}
@@ -0,0 +1,191 @@
+// Package vault loads verification keys and client secrets from HashiCorp Vault.
+//
+// The package talks to Vault's HTTP API directly, so it doesn't depend on the
+// Vault client library. Secrets are cached until their lease expires, or until
+// the refresh interval elapses for secrets without a lease, such as those in
+// the KV secrets engine. Renewable leases are renewed instead of read again.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRefreshInterval is how long secrets are cached if Client.RefreshInterval
+// isn't set.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// maxResponseSize bounds responses read from Vault.
+const maxResponseSize = 1 << 20
+
+// Client holds the connection details for a Vault server.
+type Client struct {
+	// Address of the Vault server, such as "https://vault.example.com:8200".
+	Address string
+	// Token authenticates requests, sent as the X-Vault-Token header.
+	Token string
+	// Namespace, if set, is sent as the X-Vault-Namespace header.
+	Namespace string
+	// HTTPClient is used for requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// RefreshInterval is the longest time a secret is cached, even if its
+	// lease is longer. Defaults to DefaultRefreshInterval.
+	RefreshInterval time.Duration
+
+	// now is used by tests.
+	now func() time.Time
+}
+
+// secret is a Vault API response.
+type secret struct {
+	LeaseID       string                     `json:"lease_id"`
+	LeaseDuration int                        `json:"lease_duration"`
+	Renewable     bool                       `json:"renewable"`
+	Data          map[string]json.RawMessage `json:"data"`
+}
+
+// field returns a string field of the secret, unwrapping the nested data of
+// the KV version 2 secrets engine.
+func (s *secret) field(name string) (string, error) {
+	data := s.Data
+	if nested, ok := data["data"]; ok {
+		if _, ok := data["metadata"]; ok {
+			data = nil
+			if err := json.Unmarshal(nested, &data); err != nil {
+				return "", fmt.Errorf("vault: decoding kv data: %v", err)
+			}
+		}
+	}
+	raw, ok := data[name]
+	if !ok {
+		return "", fmt.Errorf("vault: secret has no field %q", name)
+	}
+	var v string
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", fmt.Errorf("vault: field %q is not a string", name)
+	}
+	return v, nil
+}
+
+func (c *Client) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) (*secret, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	u := strings.TrimSuffix(c.Address, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, fmt.Errorf("vault: creating request: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", c.Token)
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("vault: reading response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(data, &e) == nil && len(e.Errors) > 0 {
+			return nil, fmt.Errorf("vault: %s %s: %s", method, path, strings.Join(e.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault: %s %s: %s", method, path, resp.Status)
+	}
+	var s secret
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("vault: decoding response: %v", err)
+	}
+	return &s, nil
+}
+
+// leasedSecret caches a secret read from a path, renewing or re-reading it once
+// it expires.
+type leasedSecret struct {
+	client *Client
+	path   string
+
+	mu      sync.Mutex
+	secret  *secret
+	expires time.Time
+}
+
+func (l *leasedSecret) expiry(leaseDuration int) time.Time {
+	refresh := l.client.RefreshInterval
+	if refresh <= 0 {
+		refresh = DefaultRefreshInterval
+	}
+	if lease := time.Duration(leaseDuration) * time.Second; lease > 0 && lease < refresh {
+		refresh = lease
+	}
+	return l.client.clock().Add(refresh)
+}
+
+// get returns the cached secret. A secret that's renewed is returned as the
+// same pointer, so callers can tell whether it was read again.
+func (l *leasedSecret) get(ctx context.Context) (*secret, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.client.clock()
+	if l.secret != nil && now.Before(l.expires) {
+		return l.secret, nil
+	}
+	if l.secret != nil && l.secret.Renewable && l.secret.LeaseID != "" {
+		renewed, err := l.client.do(ctx, http.MethodPut, "sys/leases/renew", map[string]interface{}{
+			"lease_id":  l.secret.LeaseID,
+			"increment": l.secret.LeaseDuration,
+		})
+		if err == nil && renewed.LeaseDuration > 0 {
+			l.secret.LeaseDuration = renewed.LeaseDuration
+			l.secret.Renewable = renewed.Renewable
+			l.expires = l.expiry(renewed.LeaseDuration)
+			return l.secret, nil
+		}
+		// Leases can't be renewed past their max TTL. Fall back to
+		// reading a new secret.
+	}
+	s, err := l.client.do(ctx, http.MethodGet, l.path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.Data == nil {
+		return nil, errors.New("vault: secret has no data")
+	}
+	l.secret = s
+	l.expires = l.expiry(s.LeaseDuration)
+	return s, nil
+}
@@ -0,0 +1,254 @@
+package vault
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	jose "github.com/go-jose/go-jose/v3"
+	"golang.org/x/oauth2"
+)
+
+type fakeVault struct {
+	mu       sync.Mutex
+	secrets  map[string]string
+	reads    int
+	renewals int
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("X-Vault-Token") != "token" {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"errors":["permission denied"]}`)
+		return
+	}
+	if r.Method == http.MethodPut && r.URL.Path == "/v1/sys/leases/renew" {
+		f.renewals++
+		fmt.Fprint(w, `{"lease_id":"lease1","renewable":true,"lease_duration":60}`)
+		return
+	}
+	body, ok := f.secrets[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errors":[]}`)
+		return
+	}
+	f.reads++
+	fmt.Fprint(w, body)
+}
+
+func (f *fakeVault) counts() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reads, f.renewals
+}
+
+func kvV2(t *testing.T, data map[string]string) string {
+	b, err := json.Marshal(map[string]interface{}{
+		"lease_duration": 0,
+		"data": map[string]interface{}{
+			"data":     data,
+			"metadata": map[string]interface{}{"version": 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestKeySet(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: priv.Public(), KeyID: "k1", Algorithm: "ES256", Use: "sig"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	fake := &fakeVault{secrets: map[string]string{
+		"/v1/secret/data/jwks": kvV2(t, map[string]string{"keys": string(jwks)}),
+		"/v1/secret/data/pem":  kvV2(t, map[string]string{"keys": pemKey}),
+	}}
+	s := httptest.NewServer(fake)
+	defer s.Close()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: priv, KeyID: "k1"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := signer.Sign([]byte(`{"iss":"https://op","aud":"client","exp":4102444800}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	client := &Client{Address: s.URL, Token: "token", now: func() time.Time { return now }}
+	for _, path := range []string{"secret/data/jwks", "secret/data/pem"} {
+		verifier := oidc.NewVerifier("https://op", NewKeySet(client, path, "keys"), &oidc.Config{
+			ClientID:             "client",
+			SupportedSigningAlgs: []string{oidc.ES256},
+		})
+		for i := 0; i < 3; i++ {
+			if _, err := verifier.Verify(context.Background(), raw); err != nil {
+				t.Fatalf("%s: verifying token: %v", path, err)
+			}
+		}
+		keySet := NewKeySet(client, path, "keys")
+		if _, err := keySet.VerifySignature(context.Background(), raw); err != nil {
+			t.Errorf("%s: VerifySignature: %v", path, err)
+		}
+	}
+	if reads, _ := fake.counts(); reads != 4 {
+		t.Errorf("expected secrets to be cached, got %d reads", reads)
+	}
+
+	bad := NewKeySet(&Client{Address: s.URL, Token: "wrong"}, "secret/data/jwks", "keys")
+	if _, err := bad.VerifySignature(context.Background(), raw); err == nil {
+		t.Errorf("expected error with invalid token")
+	}
+}
+
+func TestKeySetReload(t *testing.T) {
+	newKey := func() *ecdsa.PrivateKey {
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return priv
+	}
+	weak, err := rsa.GenerateKey(rand.Reader, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k1, k2, enc := newKey(), newKey(), newKey()
+	jwks := func(keys ...jose.JSONWebKey) string {
+		b, err := json.Marshal(jose.JSONWebKeySet{Keys: keys})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return kvV2(t, map[string]string{"keys": string(b)})
+	}
+	fake := &fakeVault{secrets: map[string]string{
+		"/v1/secret/data/jwks": jwks(
+			jose.JSONWebKey{Key: k1.Public(), KeyID: "k1"},
+			jose.JSONWebKey{Key: enc.Public(), KeyID: "enc", Use: "enc"},
+			jose.JSONWebKey{Key: weak.Public(), KeyID: "weak"},
+		),
+	}}
+	s := httptest.NewServer(fake)
+	defer s.Close()
+
+	now := time.Unix(1700000000, 0)
+	client := &Client{Address: s.URL, Token: "token", now: func() time.Time { return now }}
+	keySet := NewKeySet(client, "secret/data/jwks", "keys")
+	keyIDs := func() ([]string, error) {
+		keys, err := keySet.load(context.Background())
+		var ids []string
+		for _, key := range keys {
+			ids = append(ids, key.KeyID)
+		}
+		return ids, err
+	}
+	if ids, err := keyIDs(); err != nil || len(ids) != 1 || ids[0] != "k1" {
+		t.Errorf("expected encryption and weak keys to be dropped, got %v, err=%v", ids, err)
+	}
+
+	// Keys that fail to parse aren't replaced by the previous keys, and are
+	// parsed again on the next call.
+	fake.mu.Lock()
+	fake.secrets["/v1/secret/data/jwks"] = kvV2(t, map[string]string{"keys": "{"})
+	fake.mu.Unlock()
+	now = now.Add(DefaultRefreshInterval)
+	for i := 0; i < 2; i++ {
+		if ids, err := keyIDs(); err == nil {
+			t.Errorf("expected error for malformed keys, got %v", ids)
+		}
+	}
+	fake.mu.Lock()
+	fake.secrets["/v1/secret/data/jwks"] = jwks(jose.JSONWebKey{Key: k2.Public(), KeyID: "k2"})
+	fake.mu.Unlock()
+	now = now.Add(DefaultRefreshInterval)
+	if ids, err := keyIDs(); err != nil || len(ids) != 1 || ids[0] != "k2" {
+		t.Errorf("expected rotated keys, got %v, err=%v", ids, err)
+	}
+}
+
+func TestSecretSource(t *testing.T) {
+	fake := &fakeVault{secrets: map[string]string{
+		"/v1/secret/client":  `{"lease_duration":120,"data":{"client_secret":"s3cret"}}`,
+		"/v1/database/creds": `{"lease_id":"lease1","renewable":true,"lease_duration":60,"data":{"password":"p1"}}`,
+	}}
+	s := httptest.NewServer(fake)
+	defer s.Close()
+
+	now := time.Unix(1700000000, 0)
+	client := &Client{Address: s.URL, Token: "token", now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	kv := NewSecretSource(client, "secret/client", "client_secret")
+	for i := 0; i < 2; i++ {
+		got, err := kv.ClientSecret(ctx)
+		if err != nil || got != "s3cret" {
+			t.Fatalf("unexpected secret %q, err=%v", got, err)
+		}
+	}
+	if reads, _ := fake.counts(); reads != 1 {
+		t.Errorf("expected one read, got %d", reads)
+	}
+	// The shorter lease duration wins over the refresh interval.
+	now = now.Add(121 * time.Second)
+	if _, err := kv.ClientSecret(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if reads, _ := fake.counts(); reads != 2 {
+		t.Errorf("expected secret to be read again after lease expired, got %d reads", reads)
+	}
+
+	leased := NewSecretSource(client, "database/creds", "password")
+	if _, err := leased.ClientSecret(ctx); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(61 * time.Second)
+	if got, err := leased.ClientSecret(ctx); err != nil || got != "p1" {
+		t.Fatalf("unexpected secret %q, err=%v", got, err)
+	}
+	if reads, renewals := fake.counts(); reads != 3 || renewals != 1 {
+		t.Errorf("expected lease to be renewed, got %d reads, %d renewals", reads, renewals)
+	}
+
+	missing := NewSecretSource(client, "secret/client", "other")
+	if _, err := missing.ClientSecret(ctx); err == nil {
+		t.Errorf("expected error for missing field")
+	}
+
+	base := &oauth2.Config{ClientID: "client"}
+	config, err := oidc.ConfigWithClientSecret(ctx, base, kv)
+	if err != nil || config.ClientSecret != "s3cret" || base.ClientSecret != "" {
+		t.Errorf("unexpected config %+v, err=%v", config, err)
+	}
+}
@@ -3,7 +3,8 @@ package oidc
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
+	"crypto"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,7 +13,6 @@ import (
 	"strings"
 	"time"
 
-	jose "github.com/go-jose/go-jose/v3"
 	"golang.org/x/oauth2"
 )
 
@@ -36,6 +36,62 @@ type KeySet interface {
 	VerifySignature(ctx context.Context, jwt string) (payload []byte, err error)
 }
 
+// KeyResolver is an optional interface a KeySet can implement to select keys
+// using the token's header, instead of verifying the signature itself. This lets
+// key sets backed by HSMs or shared between tenants look up keys by ID without
+// trying every key, while signature checks stay within this package.
+//
+// When the KeySet passed to a verifier implements KeyResolver, VerifySignature
+// isn't called for ID Tokens.
+type KeyResolver interface {
+	// ResolveKeys returns the candidate public keys for a token. The header's
+	// algorithm has already been checked against the verifier's policy, and
+	// the key ID may be empty. Supported key types are *rsa.PublicKey,
+	// *ecdsa.PublicKey, ed25519.PublicKey and *jose.JSONWebKey values holding
+	// one of those types.
+	//
+	// If ResolveKeys makes HTTP requests, it's expected to use any HTTP client
+	// associated with the context through ClientContext.
+	ResolveKeys(ctx context.Context, header TokenHeader) ([]crypto.PublicKey, error)
+}
+
+// verifyWithResolver verifies the signature of a token with the keys returned by
+// a KeyResolver.
+func verifyWithResolver(ctx context.Context, r KeyResolver, jws *compactJWS, header TokenHeader) ([]byte, error) {
+	keys, err := r.ResolveKeys(ctx, header)
+	if err != nil {
+		return nil, err
+	}
+	attempts := getKeyAttempts(ctx)
+	for _, key := range keys {
+		// Symmetric keys are only accepted through SymmetricKeySet.
+		if _, ok := key.([]byte); ok {
+			return nil, fmt.Errorf("oidc: key resolver returned a symmetric key")
+		}
+		if err := attempts.next(); err != nil {
+			return nil, err
+		}
+		if payload, err := jws.verify(ctx, key); err == nil {
+			return payload, nil
+		}
+	}
+	return nil, fmt.Errorf("no keys able to verify jwt")
+}
+
+// TokenVerifier verifies tokens, either locally as JWTs or remotely, such as by
+// token introspection. It's implemented by IDTokenVerifier and
+// IntrospectionVerifier, so middleware and gateways can accept both JWT and
+// opaque access tokens.
+type TokenVerifier interface {
+	Verify(ctx context.Context, rawToken string, opts ...VerifyOption) (*IDToken, error)
+}
+
+var (
+	_ TokenVerifier = (*IDTokenVerifier)(nil)
+	_ TokenVerifier = (*IntrospectionVerifier)(nil)
+	_ TokenVerifier = (*TrustedIssuers)(nil)
+)
+
 // IDTokenVerifier provides verification for ID Tokens.
 type IDTokenVerifier struct {
 	keySet KeySet
@@ -74,8 +130,9 @@ type Config struct {
 	// If specified, only this set of algorithms may be used to sign the JWT.
 	//
 	// If the IDTokenVerifier is created from a provider with (*Provider).Verifier, this
-	// defaults to the set of algorithms the provider supports. Otherwise this values
-	// defaults to RS256.
+	// defaults to the set of algorithms the provider supports, as returned by
+	// Provider.SigningAlgorithms, so providers that only sign with ES256 or
+	// EdDSA work without configuration. Otherwise this values defaults to RS256.
 	SupportedSigningAlgs []string
 
 	// If true, no ClientID check performed. Must be true if ClientID field is empty.
@@ -91,8 +148,28 @@ type Config struct {
 	// unexpected, evaluate if the provided issuer URL is incorrect instead of enabling
 	// this option.
 	SkipIssuerCheck bool
+	// IssuerMatcher, if set, decides which issuers are accepted instead of
+	// comparing the "iss" claim to the verifier's issuer. See IssuerMatcher.
+	IssuerMatcher IssuerMatcher
+	// IssuerAliases lists other issuers whose tokens are accepted, such as the
+	// same issuer with a trailing slash or with http instead of https, or the
+	// legacy issuer of a provider being migrated. Aliases are compared
+	// exactly, and are accepted in addition to the verifier's issuer or the
+	// issuers matched by IssuerMatcher. IDToken.Issuer holds the issuer the
+	// token was actually issued by.
+	//
+	// Aliases must be served by the same key set as the verifier's issuer.
+	IssuerAliases []string
+	// LegacyIssuers lists issuers accepted until a deadline. See
+	// AcceptIssuerUntil.
+	LegacyIssuers []LegacyIssuer
 
-	// Time function to check Token expiry. Defaults to time.Now
+	// Time function to check Token expiry, and to expire entries of the
+	// VerificationCache. Defaults to the clock set by ClockContext when the
+	// verifier is created from a Provider, then time.Now.
+	//
+	// This is useful for deterministic tests and simulations, and is supported
+	// for that use.
 	Now func() time.Time
 
 	// InsecureSkipSignatureCheck causes this package to skip JWT signature validation.
@@ -105,13 +182,135 @@ type Config struct {
 	// This option MUST NOT be used when receiving an ID Token from sources other
 	// than the token endpoint.
 	InsecureSkipSignatureCheck bool
+
+	// MaxTokenSize is the maximum length, in bytes, of a raw ID Token. Larger
+	// tokens are rejected before any decoding takes place. Defaults to
+	// DefaultMaxTokenSize.
+	MaxTokenSize int
+	// MaxClaimDepth is the maximum nesting depth of objects and arrays within
+	// the token's claims. Defaults to DefaultMaxClaimDepth.
+	MaxClaimDepth int
+	// AllowDuplicateClaims accepts tokens whose claims contain duplicate keys
+	// within an object, such as two "aud" members, in which case the last
+	// one is used. By default such tokens are rejected: other parsers use the
+	// first member instead, which lets crafted tokens mean different things
+	// to different verifiers. Only enable this for compatibility with
+	// providers known to issue such tokens.
+	AllowDuplicateClaims bool
+
+	// DisallowedAlgs lists algorithms that are never accepted, even if they're
+	// part of SupportedSigningAlgs or advertised by the provider. This lets
+	// organization wide policies, such as "never RS256", be applied on top of
+	// provider defaults.
+	DisallowedAlgs []string
+	// InsecureAllowSymmetricAlgs permits HS256, HS384 and HS512 to be listed in
+	// SupportedSigningAlgs. Tokens signed with the client secret can be forged
+	// by anyone who knows the secret, so this should only be enabled for
+	// providers that offer no alternative. Symmetric algorithms are never used
+	// unless they're listed explicitly.
+	//
+	// The "none" algorithm is never accepted when signatures are checked.
+	InsecureAllowSymmetricAlgs bool
+
+	// RequireVerifiedEmail rejects tokens without an email claim, or whose
+	// email_verified claim isn't true. Set it when the email address is used
+	// to identify or authorize users. See IDToken.Email.
+	RequireVerifiedEmail bool
+
+	// MaxKeyAttempts is the maximum number of keys tried to verify the
+	// signature of a token, after keys that don't match its kid and alg have
+	// been skipped. Zero tries every key. It bounds the work spent on tokens
+	// without a kid by key sets holding many keys. The limit applies to the
+	// key sets of this package and to KeyResolver implementations, but not to
+	// other KeySet implementations.
+	MaxKeyAttempts int
+	// VerificationTimeout bounds the time spent verifying the signature of a
+	// token, including fetching keys, independently of the deadline of the
+	// context passed to Verify. Zero doesn't bound it. Verify returns when the
+	// timeout expires even if the key set ignores its context, as a slow HSM
+	// backed KeySet might, in which case the key set keeps running in the
+	// background.
+	VerificationTimeout time.Duration
+
+	// VerificationCache, if set, holds recently verified tokens. Tokens found in
+	// the cache skip signature and claim validation until they expire.
+	VerificationCache *VerificationCache
+
+	// Observer, if set, is told about each verification. Defaults to the
+	// observer set by ObserverContext when the verifier is created from a
+	// Provider.
+	Observer *Observer
+
+	// HardenedMode turns on the optional security checks of this package, for
+	// "secure unless I opt out" semantics. Checks disabled explicitly, such as
+	// with SkipExpiryCheck, stay disabled. In hardened mode:
+	//
+	//   - a token with several audiences must have an azp claim, and an azp
+	//     claim must be the expected audience
+	//   - the iat claim must not be in the future, allowing for clock skew
+	//   - the typ header, if set, must be "JWT", so other kinds of JWTs
+	//     signed by the provider, such as access or logout tokens, aren't
+	//     accepted as ID Tokens
+	//   - WithAccessToken requires the at_hash claim rather than only
+	//     checking it when present
+	//
+	// Application policies, such as RequireVerifiedEmail, aren't implied.
+	HardenedMode bool
+
+	// AuditSink, if set, receives an AuditEvent for each call to Verify,
+	// recording whether the token was accepted, whose it is and why it was
+	// rejected. Wrap it with SampleAudit to record only some decisions.
+	AuditSink AuditSink
+}
+
+// KeySetContext returns a new Context that carries a key set. Verifiers created
+// with Provider.VerifierContext and the returned context verify signatures with
+// it instead of the provider's keys, while keeping the provider's issuer,
+// algorithms and other defaults. It lets tests and applications inject a key
+// set, such as a StaticKeySet or one with custom caching, without constructing
+// the provider by hand.
+//
+//	ctx := oidc.KeySetContext(ctx, &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{testKey}})
+//	verifier := provider.VerifierContext(ctx, &oidc.Config{ClientID: clientID})
+func KeySetContext(ctx context.Context, keySet KeySet) context.Context {
+	return context.WithValue(ctx, keySetKey, keySet)
+}
+
+func getKeySet(ctx context.Context) KeySet {
+	if ks, ok := ctx.Value(keySetKey).(KeySet); ok {
+		return ks
+	}
+	return nil
 }
 
 // VerifierContext returns an IDTokenVerifier that uses the provider's key set to
 // verify JWTs. As opposed to Verifier, the context is used for all requests to
-// the upstream JWKs endpoint.
+// the upstream JWKs endpoint. If the context carries a key set set by
+// KeySetContext, it's used instead of the provider's. Otherwise the verifier
+// gets a key set of its own, which only caches keys for the algorithms the
+// config accepts.
 func (p *Provider) VerifierContext(ctx context.Context, config *Config) *IDTokenVerifier {
-	return p.newVerifier(NewRemoteKeySet(ctx, p.jwksURL), config)
+	if ks := getKeySet(ctx); ks != nil {
+		return p.newVerifier(ks, config)
+	}
+	if p.observer != nil && getObserver(ctx) == nil {
+		ctx = ObserverContext(ctx, p.observer)
+	}
+	if p.degraded != nil && getDegradedMode(ctx) == nil {
+		ctx = DegradedModeContext(ctx, p.degraded)
+	}
+	if p.keyRetention > 0 && getKeyRetention(ctx) == 0 {
+		ctx = KeyRetentionContext(ctx, p.keyRetention)
+	}
+	if p.sigVerifier != nil && getSignatureVerifier(ctx) == nil {
+		ctx = SignatureVerifierContext(ctx, p.sigVerifier)
+	}
+	// The key set belongs to this verifier alone, so it only caches keys for
+	// the algorithms the verifier accepts.
+	config = p.verifierConfig(config)
+	keySet := NewRemoteKeySet(p.clientContext(ctx), p.jwksURL)
+	keySet.algs = config.allowedAlgs()
+	return NewVerifier(p.issuer, keySet, config)
 }
 
 // Verifier returns an IDTokenVerifier that uses the provider's key set to verify JWTs.
@@ -123,26 +322,234 @@ func (p *Provider) Verifier(config *Config) *IDTokenVerifier {
 }
 
 func (p *Provider) newVerifier(keySet KeySet, config *Config) *IDTokenVerifier {
-	if len(config.SupportedSigningAlgs) == 0 && len(p.algorithms) > 0 {
+	return NewVerifier(p.issuer, keySet, p.verifierConfig(config))
+}
+
+// verifierConfig fills in the defaults of the provider missing from config.
+func (p *Provider) verifierConfig(config *Config) *Config {
+	if (len(config.SupportedSigningAlgs) == 0 && len(p.algorithms) > 0) || (config.Now == nil && p.now != nil) || (config.Observer == nil && p.observer != nil) {
 		// Make a copy so we don't modify the config values.
 		cp := &Config{}
 		*cp = *config
-		cp.SupportedSigningAlgs = p.algorithms
+		if len(cp.SupportedSigningAlgs) == 0 {
+			cp.SupportedSigningAlgs = p.algorithms
+		}
+		if cp.Now == nil {
+			cp.Now = p.now
+		}
+		if cp.Observer == nil {
+			cp.Observer = p.observer
+		}
 		config = cp
 	}
-	return NewVerifier(p.issuer, keySet, config)
+	return config
+}
+
+// ValidatedVerifier is like Verifier, but returns an error if the configuration
+// is invalid instead of failing every call to Verify. See Config.Validate.
+func (p *Provider) ValidatedVerifier(config *Config) (*IDTokenVerifier, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return p.Verifier(config), nil
+}
+
+// NewValidatedVerifier is like NewVerifier, but returns an error if the
+// configuration is invalid. See Config.Validate.
+func NewValidatedVerifier(issuerURL string, keySet KeySet, config *Config) (*IDTokenVerifier, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return NewVerifier(issuerURL, keySet, config), nil
+}
+
+// Validate reports configurations that are contradictory, or that would cause
+// every call to Verify to fail. For example, an empty ClientID without
+// SkipClientIDCheck, or SupportedSigningAlgs with InsecureSkipSignatureCheck,
+// which never checks the algorithm. All problems are reported in one error.
+//
+// NewVerifier and Provider.Verifier don't validate their configuration, for
+// compatibility. Use NewValidatedVerifier or Provider.ValidatedVerifier to
+// fail when the verifier is created.
+func (c *Config) Validate() error {
+	var problems []string
+	if c.ClientID == "" && !c.SkipClientIDCheck {
+		problems = append(problems, "ClientID must be provided or SkipClientIDCheck must be set")
+	}
+	if c.ClientID != "" && c.SkipClientIDCheck {
+		problems = append(problems, "ClientID is ignored when SkipClientIDCheck is set")
+	}
+	for _, alg := range c.SupportedSigningAlgs {
+		switch {
+		case alg == AlgNone:
+			problems = append(problems, `signing algorithm "none" is never accepted, use InsecureSkipSignatureCheck`)
+		case symmetricAlgorithms[alg]:
+			if !c.InsecureAllowSymmetricAlgs {
+				problems = append(problems, fmt.Sprintf("symmetric signing algorithm %q requires InsecureAllowSymmetricAlgs", alg))
+			}
+		case !supportedAlgorithms[alg]:
+			problems = append(problems, fmt.Sprintf("unsupported signing algorithm %q", alg))
+		case !fipsAllowed(alg):
+			problems = append(problems, fmt.Sprintf("signing algorithm %q is not approved in FIPS mode", alg))
+		}
+	}
+	if !c.InsecureSkipSignatureCheck && len(c.SupportedSigningAlgs) > 0 && len(c.allowedAlgs()) == 0 {
+		problems = append(problems, "no signing algorithms are allowed after applying DisallowedAlgs")
+	}
+	if c.InsecureSkipSignatureCheck {
+		if len(c.SupportedSigningAlgs) > 0 {
+			problems = append(problems, "SupportedSigningAlgs is ignored when InsecureSkipSignatureCheck is set")
+		}
+		if c.VerificationCache != nil {
+			problems = append(problems, "VerificationCache can't be used with InsecureSkipSignatureCheck")
+		}
+	}
+	if c.IssuerMatcher != nil && c.SkipIssuerCheck {
+		problems = append(problems, "IssuerMatcher is ignored when SkipIssuerCheck is set")
+	}
+	if len(c.IssuerAliases) > 0 && c.SkipIssuerCheck {
+		problems = append(problems, "IssuerAliases is ignored when SkipIssuerCheck is set")
+	}
+	if len(c.LegacyIssuers) > 0 && c.SkipIssuerCheck {
+		problems = append(problems, "LegacyIssuers is ignored when SkipIssuerCheck is set")
+	}
+	if contains(c.IssuerAliases, "") {
+		problems = append(problems, "IssuerAliases must not contain an empty issuer")
+	}
+	if c.MaxTokenSize < 0 {
+		problems = append(problems, "MaxTokenSize must not be negative")
+	}
+	if c.MaxClaimDepth < 0 {
+		problems = append(problems, "MaxClaimDepth must not be negative")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("oidc: invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+const (
+	// DefaultMaxTokenSize is the default maximum length of a raw ID Token.
+	DefaultMaxTokenSize = 256 << 10
+	// DefaultMaxClaimDepth is the default maximum nesting depth of ID Token
+	// claims.
+	DefaultMaxClaimDepth = 32
+)
+
+// defaultSigningAlgs is used when neither the Config nor the provider specify
+// the set of algorithms.
+var defaultSigningAlgs = []string{RS256}
+
+// algAllowed applies the algorithm policy of the config to a token's alg header.
+func (c *Config) algAllowed(alg string) bool {
+	if alg == AlgNone || contains(c.DisallowedAlgs, alg) || !fipsAllowed(alg) {
+		return false
+	}
+	if symmetricAlgorithms[alg] && !c.InsecureAllowSymmetricAlgs {
+		return false
+	}
+	algs := c.SupportedSigningAlgs
+	if len(algs) == 0 {
+		algs = defaultSigningAlgs
+	}
+	return contains(algs, alg)
+}
+
+// allowedAlgs returns the algorithms permitted by the config's policy.
+func (c *Config) allowedAlgs() []string {
+	algs := c.SupportedSigningAlgs
+	if len(algs) == 0 {
+		algs = defaultSigningAlgs
+	}
+	allowed := []string{}
+	for _, alg := range algs {
+		if c.algAllowed(alg) {
+			allowed = append(allowed, alg)
+		}
+	}
+	return allowed
+}
+
+// AlgorithmPolicy describes the signing algorithms a verifier accepts, for audit
+// and compliance tooling.
+type AlgorithmPolicy struct {
+	// Allowed holds the algorithms a token may be signed with, after defaults
+	// and DisallowedAlgs have been applied. If InsecureSkipSignatureCheck is
+	// set it's empty, since no algorithm is checked.
+	Allowed []string
+	// Disallowed holds the algorithms from Config.DisallowedAlgs.
+	Disallowed []string
+	// InsecureAllowSymmetricAlgs reports whether HMAC algorithms may be used.
+	InsecureAllowSymmetricAlgs bool
+	// InsecureSkipSignatureCheck reports whether signatures are checked at
+	// all. If true, tokens with any algorithm, including "none", are accepted.
+	InsecureSkipSignatureCheck bool
+}
+
+// AlgorithmPolicy returns the effective signing algorithm policy of the
+// verifier, including algorithms inherited from the provider.
+//
+//	policy := verifier.AlgorithmPolicy()
+//	if policy.InsecureSkipSignatureCheck || policy.InsecureAllowSymmetricAlgs {
+//		log.Printf("verifier allows insecure algorithms: %+v", policy)
+//	}
+func (v *IDTokenVerifier) AlgorithmPolicy() AlgorithmPolicy {
+	p := AlgorithmPolicy{
+		Disallowed:                 append([]string(nil), v.config.DisallowedAlgs...),
+		InsecureAllowSymmetricAlgs: v.config.InsecureAllowSymmetricAlgs,
+		InsecureSkipSignatureCheck: v.config.InsecureSkipSignatureCheck,
+	}
+	if !p.InsecureSkipSignatureCheck {
+		p.Allowed = v.config.allowedAlgs()
+	}
+	return p
+}
+
+func (c *Config) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func (c *Config) maxTokenSize() int {
+	if c.MaxTokenSize > 0 {
+		return c.MaxTokenSize
+	}
+	return DefaultMaxTokenSize
+}
+
+// checkClaims rejects claims that can't be decoded unambiguously. See checkJSON.
+func (c *Config) checkClaims(payload []byte) error {
+	return checkJSON(payload, c.maxClaimDepth(), c.AllowDuplicateClaims)
+}
+
+func (c *Config) maxClaimDepth() int {
+	if c.MaxClaimDepth > 0 {
+		return c.MaxClaimDepth
+	}
+	return DefaultMaxClaimDepth
 }
 
 func parseJWT(p string) ([]byte, error) {
-	parts := strings.Split(p, ".")
-	if len(parts) < 2 {
-		return nil, fmt.Errorf("oidc: malformed jwt, expected 3 parts got %d", len(parts))
+	if err := checkTokenAlphabet(p); err != nil {
+		return nil, err
+	}
+	// Avoid strings.Split, this is called for every verification.
+	i := strings.IndexByte(p, '.')
+	if i < 0 {
+		return nil, fmt.Errorf("oidc: malformed jwt, expected 3 parts got 1")
 	}
-	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	part := p[i+1:]
+	if j := strings.IndexByte(part, '.'); j >= 0 {
+		part = part[:j]
+	}
+	payload := make([]byte, b64.DecodedLen(len(part)))
+	n, err := b64.Decode(payload, []byte(part))
 	if err != nil {
 		return nil, fmt.Errorf("oidc: malformed jwt payload: %v", err)
 	}
-	return payload, nil
+	return payload[:n], nil
 }
 
 func contains(sli []string, ele string) bool {
@@ -156,6 +563,24 @@ func contains(sli []string, ele string) bool {
 
 // Returns the Claims from the distributed JWT token
 func resolveDistributedClaim(ctx context.Context, verifier *IDTokenVerifier, src claimSource) ([]byte, error) {
+	body, err := fetchClaimSource(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	token, err := verifier.Verify(ctx, string(body))
+	if err != nil {
+		return nil, fmt.Errorf("malformed response body: %v", err)
+	}
+
+	return token.claims, nil
+}
+
+// fetchClaimSource returns the JWT of an aggregated claim source, or requests
+// the JWT of a distributed claim source.
+func fetchClaimSource(ctx context.Context, src claimSource) ([]byte, error) {
+	if src.JWT != "" {
+		return []byte(src.JWT), nil
+	}
 	req, err := http.NewRequest("GET", src.Endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("malformed request: %v", err)
@@ -176,21 +601,21 @@ func resolveDistributedClaim(ctx context.Context, verifier *IDTokenVerifier, src
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("oidc: request failed: %v", resp.StatusCode)
-	}
-
-	token, err := verifier.Verify(ctx, string(body))
-	if err != nil {
-		return nil, fmt.Errorf("malformed response body: %v", err)
+		if oauthErr := parseOAuthError(resp, body); oauthErr != nil {
+			return nil, oauthErr
+		}
+		return nil, newHTTPError(resp, body, clockNow(ctx))
 	}
-
-	return token.claims, nil
+	return body, nil
 }
 
 // Verify parses a raw ID Token, verifies it's been signed by the provider, performs
 // any additional checks depending on the Config, and returns the payload.
 //
-// Verify does NOT do nonce validation, which is the callers responsibility.
+// Verify does NOT do nonce validation unless the WithNonce option is passed,
+// otherwise it's the callers responsibility. Options override the verifier's
+// configuration for this call only, so one verifier can serve requests with
+// different expectations.
 //
 // See: https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
 //
@@ -205,91 +630,285 @@ func resolveDistributedClaim(ctx context.Context, verifier *IDTokenVerifier, src
 //	    // handle error
 //	}
 //
-//	token, err := verifier.Verify(ctx, rawIDToken)
-func (v *IDTokenVerifier) Verify(ctx context.Context, rawIDToken string) (*IDToken, error) {
-	// Throw out tokens with invalid claims before trying to verify the token. This lets
-	// us do cheap checks before possibly re-syncing keys.
-	payload, err := parseJWT(rawIDToken)
+//	token, err := verifier.Verify(ctx, rawIDToken, oidc.WithNonce(nonce))
+func (v *IDTokenVerifier) Verify(ctx context.Context, rawIDToken string, opts ...VerifyOption) (*IDToken, error) {
+	rawIDToken = trimToken(rawIDToken)
+	o := v.config.Observer
+	observe := o != nil && o.Verification != nil
+	if !observe && v.config.AuditSink == nil {
+		t, _, err := v.verifyToken(ctx, rawIDToken, opts)
+		return t, err
+	}
+	start := time.Now()
+	t, cached, err := v.verifyToken(ctx, rawIDToken, opts)
+	duration := time.Since(start)
+
+	var u *UnverifiedIDToken
+	if t == nil && len(rawIDToken) <= v.config.maxTokenSize() {
+		u, _ = parseUnverified(rawIDToken, v.config)
+	}
+	if observe {
+		e := VerificationEvent{Issuer: v.issuer, Cached: cached, Duration: duration, Err: err}
+		if t != nil {
+			e.Algorithm = t.sigAlgorithm
+		} else if u != nil {
+			e.Algorithm = u.Algorithm
+		}
+		o.Verification(e)
+	}
+	if v.config.AuditSink != nil {
+		v.audit(t, u, cached, err)
+	}
+	return t, err
+}
+
+// verifyToken implements Verify, and reports whether the token was found in
+// the verification cache.
+func (v *IDTokenVerifier) verifyToken(ctx context.Context, rawIDToken string, opts []VerifyOption) (*IDToken, bool, error) {
+	if max := v.config.maxTokenSize(); len(rawIDToken) > max {
+		return nil, false, &MalformedTokenError{Err: fmt.Errorf("token size %d exceeds maximum of %d bytes", len(rawIDToken), max)}
+	}
+	o := &verifyOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var (
+		t      *IDToken
+		cached bool
+		err    error
+	)
+	cache := v.config.VerificationCache
+	if cache == nil {
+		t, err = v.verify(ctx, rawIDToken, o)
+	} else if t, cached = cache.get(rawIDToken, v.config.now()); cached {
+		// The token may have been cached by a call with a different
+		// audience, so check it against the one expected by this call.
+		err = v.checkAudience(t, o)
+		// Legacy issuers stop being accepted at their deadline, even if
+		// their tokens haven't expired.
+		if err == nil && len(v.config.LegacyIssuers) > 0 && !v.config.SkipIssuerCheck {
+			err = v.checkIssuer(t.Issuer)
+		}
+	} else {
+		t, err = v.verify(ctx, rawIDToken, o)
+		if err == nil {
+			cache.add(rawIDToken, t, v.config.now())
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
+		return nil, cached, err
 	}
-	var token idToken
-	if err := json.Unmarshal(payload, &token); err != nil {
-		return nil, fmt.Errorf("oidc: failed to unmarshal claims: %v", err)
+
+	if o.nonce != nil && subtle.ConstantTimeCompare([]byte(t.Nonce), []byte(*o.nonce)) != 1 {
+		return nil, cached, fmt.Errorf("oidc: id token nonce does not match")
+	}
+	if o.accessToken != nil && (t.AccessTokenHash != "" || v.config.HardenedMode) {
+		if err := t.VerifyAccessToken(*o.accessToken); err != nil {
+			return nil, cached, err
+		}
+	}
+	v.reportLegacyIssuer(t)
+	return t, cached, nil
+}
+
+// VerifyOption overrides the verifier's configuration for a single call to
+// Verify.
+type VerifyOption func(*verifyOptions)
+
+type verifyOptions struct {
+	nonce       *string
+	audience    *string
+	accessToken *string
+}
+
+// WithNonce requires the nonce claim of the ID Token to match the nonce sent
+// with the authentication request.
+func WithNonce(nonce string) VerifyOption {
+	return func(o *verifyOptions) {
+		o.nonce = &nonce
 	}
+}
 
-	distributedClaims := make(map[string]claimSource)
+// WithAccessToken requires the at_hash claim of the ID Token, if present, to
+// match the access token returned with it. Set Config.HardenedMode, or use
+// IDToken.VerifyAccessToken instead, when the claim is required.
+func WithAccessToken(accessToken string) VerifyOption {
+	return func(o *verifyOptions) {
+		o.accessToken = &accessToken
+	}
+}
 
-	//step through the token to map claim names to claim sources"
-	for cn, src := range token.ClaimNames {
-		if src == "" {
-			return nil, fmt.Errorf("oidc: failed to obtain source from claim name")
+// WithAudience requires the audience of the ID Token to contain aud instead of
+// Config.ClientID. The check applies even if SkipClientIDCheck is set.
+func WithAudience(aud string) VerifyOption {
+	return func(o *verifyOptions) {
+		o.audience = &aud
+	}
+}
+
+// checkAudience ensures the expected client ID is part of the token's audience.
+//
+// This check DOES NOT ensure that the ClientID is the party to which the ID Token
+// was issued (i.e. Authorized party).
+func (v *IDTokenVerifier) checkAudience(t *IDToken, o *verifyOptions) error {
+	if o.audience != nil {
+		if !contains(t.Audience, *o.audience) {
+			return &InvalidAudienceError{Expected: *o.audience, Actual: t.Audience}
 		}
-		s, ok := token.ClaimSources[src]
-		if !ok {
-			return nil, fmt.Errorf("oidc: source does not exist")
+		return v.checkAuthorizedParty(t, *o.audience)
+	}
+	// If a client ID has been provided, make sure it's part of the audience. SkipClientIDCheck must be true if ClientID is empty.
+	if !v.config.SkipClientIDCheck {
+		if v.config.ClientID != "" {
+			if !contains(t.Audience, v.config.ClientID) {
+				return &InvalidAudienceError{Expected: v.config.ClientID, Actual: t.Audience}
+			}
+		} else {
+			return fmt.Errorf("oidc: invalid configuration, clientID must be provided or SkipClientIDCheck must be set")
 		}
-		distributedClaims[cn] = s
+		return v.checkAuthorizedParty(t, v.config.ClientID)
 	}
+	return nil
+}
 
-	t := &IDToken{
-		Issuer:            token.Issuer,
-		Subject:           token.Subject,
-		Audience:          []string(token.Audience),
-		Expiry:            time.Time(token.Expiry),
-		IssuedAt:          time.Time(token.IssuedAt),
-		Nonce:             token.Nonce,
-		AccessTokenHash:   token.AtHash,
-		claims:            payload,
-		distributedClaims: distributedClaims,
+// checkAuthorizedParty checks the azp claim against the expected audience in
+// hardened mode.
+//
+// See: https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+func (v *IDTokenVerifier) checkAuthorizedParty(t *IDToken, aud string) error {
+	if !v.config.HardenedMode {
+		return nil
 	}
+	if t.authorizedParty == "" {
+		if len(t.Audience) > 1 {
+			return errors.New("oidc: id token with several audiences has no azp claim")
+		}
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(t.authorizedParty), []byte(aud)) != 1 {
+		return fmt.Errorf("oidc: id token azp %q doesn't match the expected audience %q", t.authorizedParty, aud)
+	}
+	return nil
+}
 
-	// Check issuer.
-	if !v.config.SkipIssuerCheck && t.Issuer != v.issuer {
+// legacyIssuer returns the entry of Config.LegacyIssuers for the issuer.
+func (v *IDTokenVerifier) legacyIssuer(issuer string) (LegacyIssuer, bool) {
+	for _, l := range v.config.LegacyIssuers {
+		if issuer != "" && l.Issuer == issuer {
+			return l, true
+		}
+	}
+	return LegacyIssuer{}, false
+}
+
+// reportLegacyIssuer tells the observer about a token verified with a legacy
+// issuer.
+func (v *IDTokenVerifier) reportLegacyIssuer(t *IDToken) {
+	o := v.config.Observer
+	if o == nil || o.LegacyIssuer == nil || t.Issuer == v.issuer {
+		return
+	}
+	if l, ok := v.legacyIssuer(t.Issuer); ok {
+		o.LegacyIssuer(LegacyIssuerEvent{Issuer: v.issuer, LegacyIssuer: l.Issuer, Until: l.Until, Subject: t.Subject})
+	}
+}
+
+func (v *IDTokenVerifier) checkIssuer(issuer string) error {
+	if issuer != "" && contains(v.config.IssuerAliases, issuer) {
+		return nil
+	}
+	if l, ok := v.legacyIssuer(issuer); ok && v.config.now().Before(l.Until) {
+		return nil
+	}
+	if m := v.config.IssuerMatcher; m != nil {
+		if !m.MatchIssuer(issuer) {
+			expected := v.issuer
+			if s, ok := m.(fmt.Stringer); ok && expected == "" {
+				expected = s.String()
+			}
+			return &InvalidIssuerError{Expected: expected, Actual: issuer}
+		}
+		return nil
+	}
+	if issuer != v.issuer {
 		// Google sometimes returns "accounts.google.com" as the issuer claim instead of
 		// the required "https://accounts.google.com". Detect this case and allow it only
 		// for Google.
 		//
 		// We will not add hooks to let other providers go off spec like this.
-		if !(v.issuer == issuerGoogleAccounts && t.Issuer == issuerGoogleAccountsNoScheme) {
-			return nil, &InvalidIssuerError{Expected: v.issuer, Actual: t.Issuer}
+		if !(v.issuer == issuerGoogleAccounts && issuer == issuerGoogleAccountsNoScheme) {
+			return &InvalidIssuerError{Expected: v.issuer, Actual: issuer}
 		}
 	}
+	return nil
+}
 
-	// If a client ID has been provided, make sure it's part of the audience. SkipClientIDCheck must be true if ClientID is empty.
+func (v *IDTokenVerifier) verify(ctx context.Context, rawIDToken string, o *verifyOptions) (*IDToken, error) {
+
+	// Throw out tokens with invalid claims before trying to verify the token. This lets
+	// us do cheap checks before possibly re-syncing keys.
 	//
-	// This check DOES NOT ensure that the ClientID is the party to which the ID Token was issued (i.e. Authorized party).
-	if !v.config.SkipClientIDCheck {
-		if v.config.ClientID != "" {
-			if !contains(t.Audience, v.config.ClientID) {
-				return nil, &InvalidAudienceError{Expected: v.config.ClientID, Actual: t.Audience}
-			}
-		} else {
-			return nil, fmt.Errorf("oidc: invalid configuration, clientID must be provided or SkipClientIDCheck must be set")
+	// Unless the signature check is skipped, the token is parsed exactly once and
+	// the same decoded payload is used for claims and the signature check.
+	var (
+		jws     *compactJWS
+		payload []byte
+		err     error
+	)
+	if v.config.InsecureSkipSignatureCheck {
+		payload, err = parseJWT(rawIDToken)
+	} else {
+		jws, err = parseCompactJWS(rawIDToken)
+		if err == nil {
+			payload = jws.payload
+			defer jws.release()
+		}
+	}
+	if err != nil {
+		return nil, &MalformedTokenError{Err: err}
+	}
+	if err := v.config.checkClaims(payload); err != nil {
+		return nil, &MalformedTokenError{Err: err}
+	}
+	var token idToken
+	if err := json.Unmarshal(payload, &token); err != nil {
+		return nil, fmt.Errorf("oidc: failed to unmarshal claims: %v", err)
+	}
+	t, err := newIDToken(rawIDToken, payload, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check issuer.
+	if !v.config.SkipIssuerCheck {
+		if err := v.checkIssuer(t.Issuer); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := v.checkAudience(t, o); err != nil {
+		return nil, err
+	}
+
+	if v.config.RequireVerifiedEmail {
+		if err := checkEmailVerified(t); err != nil {
+			return nil, err
 		}
 	}
 
 	// If a SkipExpiryCheck is false, make sure token is not expired.
 	if !v.config.SkipExpiryCheck {
-		now := time.Now
-		if v.config.Now != nil {
-			now = v.config.Now
+		nowTime := v.config.now()
+		if err := checkExpiry(t.Expiry, nowTime); err != nil {
+			return nil, err
 		}
-		nowTime := now()
-
-		if t.Expiry.Before(nowTime) {
-			return nil, &TokenExpiredError{Expiry: t.Expiry}
+		if err := checkNotBefore(token.NotBefore, nowTime); err != nil {
+			return nil, err
 		}
-
-		// If nbf claim is provided in token, ensure that it is indeed in the past.
-		if token.NotBefore != nil {
-			nbfTime := time.Time(*token.NotBefore)
-			// Set to 5 minutes since this is what other OpenID Connect providers do to deal with clock skew.
-			// https://github.com/AzureAD/azure-activedirectory-identitymodel-extensions-for-dotnet/blob/6.12.2/src/Microsoft.IdentityModel.Tokens/TokenValidationParameters.cs#L149-L153
-			leeway := 5 * time.Minute
-
-			if nowTime.Add(leeway).Before(nbfTime) {
-				return nil, fmt.Errorf("oidc: current time %v before the nbf (not before) time: %v", nowTime, nbfTime)
+		if v.config.HardenedMode {
+			if err := checkIssuedAt(t.IssuedAt, nowTime); err != nil {
+				return nil, err
 			}
 		}
 	}
@@ -298,43 +917,198 @@ func (v *IDTokenVerifier) Verify(ctx context.Context, rawIDToken string) (*IDTok
 		return t, nil
 	}
 
-	jws, err := jose.ParseSigned(rawIDToken)
+	// The header is only decoded once the cheap claim checks have passed.
+	header, err := jws.decodeHeader()
 	if err != nil {
-		return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
+		return nil, &MalformedTokenError{Err: err}
 	}
 
-	switch len(jws.Signatures) {
-	case 0:
-		return nil, fmt.Errorf("oidc: id token not signed")
-	case 1:
-	default:
-		return nil, fmt.Errorf("oidc: multiple signatures on id token not supported")
+	if err := v.checkAlgorithm(header.Algorithm); err != nil {
+		return nil, err
+	}
+	if v.config.HardenedMode && header.Type != "" && !strings.EqualFold(header.Type, "JWT") {
+		return nil, fmt.Errorf("oidc: token of type %q isn't an id token", header.Type)
 	}
 
-	sig := jws.Signatures[0]
-	supportedSigAlgs := v.config.SupportedSigningAlgs
-	if len(supportedSigAlgs) == 0 {
-		supportedSigAlgs = []string{RS256}
+	t.sigAlgorithm = header.Algorithm
+	t.header = header.tokenHeader()
+
+	if err := v.verifySignature(ctx, rawIDToken, jws, t.header); err != nil {
+		return nil, err
 	}
+	return t, nil
+}
+
+// distributedClaims maps the names of distributed and aggregated claims to
+// their sources, as listed by the "_claim_names" and "_claim_sources" claims.
+func distributedClaims(names map[string]string, sources map[string]claimSource) (map[string]claimSource, error) {
+	distributed := make(map[string]claimSource)
 
-	if !contains(supportedSigAlgs, sig.Header.Algorithm) {
-		return nil, fmt.Errorf("oidc: id token signed with unsupported algorithm, expected %q got %q", supportedSigAlgs, sig.Header.Algorithm)
+	//step through the token to map claim names to claim sources"
+	for cn, src := range names {
+		if src == "" {
+			return nil, fmt.Errorf("oidc: failed to obtain source from claim name")
+		}
+		s, ok := sources[src]
+		if !ok {
+			return nil, fmt.Errorf("oidc: source does not exist")
+		}
+		distributed[cn] = s
 	}
+	return distributed, nil
+}
 
-	t.sigAlgorithm = sig.Header.Algorithm
+// newIDToken returns the token with the decoded claims.
+func newIDToken(rawIDToken string, payload []byte, token *idToken) (*IDToken, error) {
+	distributedClaims, err := distributedClaims(token.ClaimNames, token.ClaimSources)
+	if err != nil {
+		return nil, err
+	}
 
-	ctx = context.WithValue(ctx, parsedJWTKey, jws)
-	gotPayload, err := v.keySet.VerifySignature(ctx, rawIDToken)
+	t := &IDToken{
+		Issuer:            token.Issuer,
+		Subject:           token.Subject,
+		Audience:          []string(token.Audience),
+		Expiry:            time.Time(token.Expiry),
+		IssuedAt:          time.Time(token.IssuedAt),
+		Nonce:             token.Nonce,
+		SessionID:         token.SessionID,
+		AccessTokenHash:   token.AtHash,
+		CodeHash:          token.CHash,
+		authorizedParty:   token.AuthzParty,
+		claims:            payload,
+		raw:               rawIDToken,
+		distributedClaims: distributedClaims,
+	}
+	if token.NotBefore != nil {
+		t.NotBefore = time.Time(*token.NotBefore)
+	}
+	if token.AuthTime != nil {
+		t.AuthTime = time.Time(*token.AuthTime)
+	}
+	// These claims are decoded leniently, since tokens with unexpected values
+	// were accepted before they were exposed.
+	json.Unmarshal(token.Email, &t.email)
+	json.Unmarshal(token.HostedDomain, &t.hostedDomain)
+	var verified LenientBool
+	if json.Unmarshal(token.EmailVerified, &verified) == nil {
+		t.emailVerified = bool(verified)
+	}
+	return t, nil
+}
+
+func (v *IDTokenVerifier) checkAlgorithm(alg string) error {
+	if !v.config.algAllowed(alg) {
+		return fmt.Errorf("oidc: id token signed with unsupported algorithm, expected %q got %q", v.config.allowedAlgs(), alg)
+	}
+	return nil
+}
+
+func checkEmailVerified(t *IDToken) error {
+	email, verified := t.Email()
+	if email == "" {
+		return errors.New("oidc: id token has no email claim")
+	}
+	if !verified {
+		return fmt.Errorf("oidc: id token email %q isn't verified", email)
+	}
+	return nil
+}
+
+func checkExpiry(expiry, now time.Time) error {
+	if expiry.Before(now) {
+		return &TokenExpiredError{Expiry: expiry}
+	}
+	return nil
+}
+
+// checkIssuedAt ensures that the iat claim, if provided, isn't in the future,
+// with the same leeway as checkNotBefore.
+func checkIssuedAt(iat, now time.Time) error {
+	if !iat.IsZero() && now.Add(5*time.Minute).Before(iat) {
+		return fmt.Errorf("oidc: id token issued in the future, at %v", iat)
+	}
+	return nil
+}
+
+// checkNotBefore ensures that the nbf claim, if provided, is in the past.
+func checkNotBefore(nbf *jsonTime, now time.Time) error {
+	if nbf == nil {
+		return nil
+	}
+	nbfTime := time.Time(*nbf)
+	// Set to 5 minutes since this is what other OpenID Connect providers do to deal with clock skew.
+	// https://github.com/AzureAD/azure-activedirectory-identitymodel-extensions-for-dotnet/blob/6.12.2/src/Microsoft.IdentityModel.Tokens/TokenValidationParameters.cs#L149-L153
+	leeway := 5 * time.Minute
+
+	if now.Add(leeway).Before(nbfTime) {
+		return fmt.Errorf("oidc: current time %v before the nbf (not before) time: %v", now, nbfTime)
+	}
+	return nil
+}
+
+// verifySignature checks the signature of the token with the verifier's key
+// set.
+func (v *IDTokenVerifier) verifySignature(ctx context.Context, rawIDToken string, jws *compactJWS, header TokenHeader) error {
+	if max := v.config.MaxKeyAttempts; max > 0 {
+		ctx = context.WithValue(ctx, keyAttemptsKey, &keyAttempts{max: max})
+	}
+	var (
+		gotPayload []byte
+		err        error
+	)
+	if v.config.VerificationTimeout > 0 {
+		gotPayload, err = v.verifyKeysWithin(ctx, v.config.VerificationTimeout, rawIDToken, header)
+	} else {
+		gotPayload, err = v.verifyKeys(ctx, rawIDToken, jws, header)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify signature: %v", err)
+		return &signatureError{err: err}
 	}
 
 	// Ensure that the payload returned by the square actually matches the payload parsed earlier.
-	if !bytes.Equal(gotPayload, payload) {
-		return nil, errors.New("oidc: internal error, payload parsed did not match previous payload")
+	if !bytes.Equal(gotPayload, jws.payload) {
+		return errors.New("oidc: internal error, payload parsed did not match previous payload")
+	}
+	return nil
+}
+
+func (v *IDTokenVerifier) verifyKeys(ctx context.Context, rawIDToken string, jws *compactJWS, header TokenHeader) ([]byte, error) {
+	if r, ok := v.keySet.(KeyResolver); ok {
+		return verifyWithResolver(ctx, r, jws, header)
 	}
+	ctx = context.WithValue(ctx, parsedJWTKey, jws)
+	return v.keySet.VerifySignature(ctx, rawIDToken)
+}
 
-	return t, nil
+// verifyKeysWithin is verifyKeys bounded by the timeout, even if the key set
+// ignores the context. The key set works on its own copy of the token, since
+// the caller's may be released while the key set is still using it.
+func (v *IDTokenVerifier) verifyKeysWithin(ctx context.Context, timeout time.Duration, rawIDToken string, header TokenHeader) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		payload []byte
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		jws, err := parseCompactJWS(rawIDToken)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer jws.release()
+		payload, err := v.verifyKeys(ctx, rawIDToken, jws, header)
+		done <- result{payload, err}
+	}()
+	select {
+	case r := <-done:
+		return r.payload, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("oidc: verifying signature: %w", ctx.Err())
+	}
 }
 
 // Nonce returns an auth code option which requires the ID Token created by the
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +15,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
 )
 
 func TestVerify(t *testing.T) {
@@ -284,6 +287,58 @@ func TestVerifySigningAlg(t *testing.T) {
 	}
 }
 
+func TestVerifyLimits(t *testing.T) {
+	tests := []verificationTest{
+		{
+			name:    "within limits",
+			idToken: `{"iss":"https://foo","groups":[["a"]]}`,
+			config: Config{
+				SkipClientIDCheck: true,
+				SkipExpiryCheck:   true,
+				MaxClaimDepth:     3,
+			},
+			signKey: newRSAKey(t),
+			errFunc: expectSuccess,
+		},
+		{
+			name:    "token too large",
+			idToken: `{"iss":"https://foo","padding":"` + strings.Repeat("a", 1024) + `"}`,
+			config: Config{
+				SkipClientIDCheck: true,
+				SkipExpiryCheck:   true,
+				MaxTokenSize:      512,
+			},
+			signKey: newRSAKey(t),
+			errFunc: expectErrorType[*MalformedTokenError],
+		},
+		{
+			name:    "claims too deep",
+			idToken: `{"iss":"https://foo","groups":[[["a"]]]}`,
+			config: Config{
+				SkipClientIDCheck: true,
+				SkipExpiryCheck:   true,
+				MaxClaimDepth:     3,
+			},
+			signKey: newRSAKey(t),
+			errFunc: expectErrorType[*MalformedTokenError],
+		},
+		{
+			name:    "brackets in strings ignored",
+			idToken: `{"iss":"https://foo","name":"[[[[\"[[[["}`,
+			config: Config{
+				SkipClientIDCheck: true,
+				SkipExpiryCheck:   true,
+				MaxClaimDepth:     1,
+			},
+			signKey: newRSAKey(t),
+			errFunc: expectSuccess,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, test.run)
+	}
+}
+
 func TestAccessTokenHash(t *testing.T) {
 	atHash := "piwt8oCH-K2D9pXlaS1Y-w"
 	vt := verificationTest{
@@ -311,6 +366,161 @@ func TestAccessTokenHash(t *testing.T) {
 	})
 }
 
+func TestTokenHeader(t *testing.T) {
+	key := newRSAKey(t)
+	opts := (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "key1").WithHeader("x5t", "dGh1bWJwcmludA")
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key.priv}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := signer.Sign([]byte(`{"iss":"https://foo"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		SkipClientIDCheck: true,
+		SkipExpiryCheck:   true,
+	})
+	tok, err := verifier.Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("verifying token: %v", err)
+	}
+	want := TokenHeader{
+		Algorithm:      RS256,
+		KeyID:          "key1",
+		Type:           "JWT",
+		X509Thumbprint: "dGh1bWJwcmludA",
+	}
+	if got := tok.Header(); got != want {
+		t.Errorf("expected header %+v, got %+v", want, got)
+	}
+}
+
+func TestRawAndPayload(t *testing.T) {
+	payload := `{"iss":"https://foo","aud":"client1"}`
+	key := newRSAKey(t)
+	raw := key.sign(t, []byte(payload))
+
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		ClientID:        "client1",
+		SkipExpiryCheck: true,
+	})
+	tok, err := verifier.Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("verifying token: %v", err)
+	}
+	if tok.Raw() != raw {
+		t.Errorf("expected raw token %q, got %q", raw, tok.Raw())
+	}
+	got := tok.Payload()
+	if string(got) != payload {
+		t.Errorf("expected payload %s, got %s", payload, got)
+	}
+
+	// Modifying the returned payload must not affect the token.
+	got[0] = '['
+	if string(tok.Payload()) != payload {
+		t.Errorf("payload modified through returned slice")
+	}
+}
+
+func TestSessionID(t *testing.T) {
+	key := newRSAKey(t)
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		ClientID:        "client1",
+		SkipExpiryCheck: true,
+	})
+	raw := key.sign(t, []byte(`{"iss":"https://foo","aud":"client1","sid":"08a5019c-17e1-4977-8f42-65a12843ea02"}`))
+	tok, err := verifier.Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("verifying token: %v", err)
+	}
+	if want := "08a5019c-17e1-4977-8f42-65a12843ea02"; tok.SessionID != want {
+		t.Errorf("expected session ID %q, got %q", want, tok.SessionID)
+	}
+}
+
+func TestEmail(t *testing.T) {
+	key := newRSAKey(t)
+	ks := &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}
+	verifier := NewVerifier("https://foo", ks, &Config{ClientID: "client1", SkipExpiryCheck: true})
+	strict := NewVerifier("https://foo", ks, &Config{ClientID: "client1", SkipExpiryCheck: true, RequireVerifiedEmail: true})
+
+	tests := []struct {
+		name         string
+		claims       string
+		wantEmail    string
+		wantVerified bool
+		wantHD       string
+	}{
+		{"verified", `"email":"jane@example.com","email_verified":true,"hd":"example.com"`, "jane@example.com", true, "example.com"},
+		{"string verified", `"email":"jane@example.com","email_verified":"True"`, "jane@example.com", true, ""},
+		{"unverified", `"email":"jane@example.com","email_verified":false`, "jane@example.com", false, ""},
+		{"string unverified", `"email":"jane@example.com","email_verified":"false"`, "jane@example.com", false, ""},
+		{"absent verified", `"email":"jane@example.com"`, "jane@example.com", false, ""},
+		{"malformed verified", `"email":"jane@example.com","email_verified":1`, "jane@example.com", false, ""},
+		{"no email", `"email_verified":true`, "", true, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw := key.sign(t, []byte(`{"iss":"https://foo","aud":"client1",`+test.claims+`}`))
+			tok, err := verifier.Verify(context.Background(), raw)
+			if err != nil {
+				t.Fatalf("verifying token: %v", err)
+			}
+			email, verified := tok.Email()
+			if email != test.wantEmail || verified != test.wantVerified {
+				t.Errorf("expected email %q verified %v, got %q %v", test.wantEmail, test.wantVerified, email, verified)
+			}
+			if hd := tok.HostedDomain(); hd != test.wantHD {
+				t.Errorf("expected hosted domain %q, got %q", test.wantHD, hd)
+			}
+
+			_, err = strict.Verify(context.Background(), raw)
+			if ok := test.wantEmail != "" && test.wantVerified; ok != (err == nil) {
+				t.Errorf("RequireVerifiedEmail: expected success %v, got %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestTokenTimes(t *testing.T) {
+	key := newRSAKey(t)
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		ClientID:        "client1",
+		SkipExpiryCheck: true,
+	})
+
+	raw := key.sign(t, []byte(`{"iss":"https://foo","aud":"client1","iat":1643414400,"nbf":1643414300,"auth_time":1643414000}`))
+	tok, err := verifier.Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("verifying token: %v", err)
+	}
+	if want := time.Unix(1643414400, 0); !tok.IssuedAt.Equal(want) {
+		t.Errorf("expected iat %v, got %v", want, tok.IssuedAt)
+	}
+	if want := time.Unix(1643414300, 0); !tok.NotBefore.Equal(want) {
+		t.Errorf("expected nbf %v, got %v", want, tok.NotBefore)
+	}
+	if want := time.Unix(1643414000, 0); !tok.AuthTime.Equal(want) {
+		t.Errorf("expected auth_time %v, got %v", want, tok.AuthTime)
+	}
+
+	raw = key.sign(t, []byte(`{"iss":"https://foo","aud":"client1"}`))
+	tok, err = verifier.Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("verifying token: %v", err)
+	}
+	if !tok.NotBefore.IsZero() || !tok.AuthTime.IsZero() {
+		t.Errorf("expected zero times for absent claims, got nbf %v, auth_time %v", tok.NotBefore, tok.AuthTime)
+	}
+}
+
 func TestDistributedClaims(t *testing.T) {
 	tests := []struct {
 		test    verificationTest
@@ -630,7 +840,11 @@ func (v verificationTest) runGetToken(t *testing.T) (*IDToken, error) {
 
 func (v verificationTest) run(t *testing.T) {
 	_, err := v.runGetToken(t)
-	if msg := v.errFunc(err); msg != "" {
+	errFunc := v.errFunc
+	if v.signKey != nil && fipsRejects(string(v.signKey.alg)) {
+		errFunc = expectError
+	}
+	if msg := errFunc(err); msg != "" {
 		t.Error(msg)
 	}
 }
@@ -687,3 +901,411 @@ func expectErrorMessage(expectedMsg string) errCheck {
 		return ""
 	}
 }
+
+func TestVerifyOptions(t *testing.T) {
+	key := newRSAKey(t)
+	raw := key.sign(t, []byte(`{"iss":"https://foo","aud":["client1","client2"],"nonce":"n1","exp":4102444800}`))
+
+	for _, cached := range []bool{false, true} {
+		config := &Config{ClientID: "client1"}
+		if cached {
+			config.VerificationCache = NewVerificationCache(10, 0)
+		}
+		verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, config)
+		ctx := context.Background()
+
+		if _, err := verifier.Verify(ctx, raw, WithNonce("n1")); err != nil {
+			t.Errorf("cached=%v: verifying with nonce: %v", cached, err)
+		}
+		if _, err := verifier.Verify(ctx, raw, WithNonce("n2")); err == nil {
+			t.Errorf("cached=%v: expected nonce mismatch", cached)
+		}
+		if _, err := verifier.Verify(ctx, raw, WithAudience("client2")); err != nil {
+			t.Errorf("cached=%v: verifying with audience: %v", cached, err)
+		}
+		_, err := verifier.Verify(ctx, raw, WithAudience("client3"))
+		var audErr *InvalidAudienceError
+		if !errors.As(err, &audErr) || audErr.Expected != "client3" {
+			t.Errorf("cached=%v: expected *InvalidAudienceError for client3, got %v", cached, err)
+		}
+		// The default audience still applies without options.
+		if _, err := verifier.Verify(ctx, raw); err != nil {
+			t.Errorf("cached=%v: verifying without options: %v", cached, err)
+		}
+	}
+
+	// WithAudience applies even if the client ID check is skipped.
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{SkipClientIDCheck: true})
+	if _, err := verifier.Verify(context.Background(), raw, WithAudience("client3")); err == nil {
+		t.Errorf("expected audience mismatch with SkipClientIDCheck")
+	}
+}
+
+func TestIssuerAliases(t *testing.T) {
+	key := newRSAKey(t)
+	verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+		ClientID:      "client1",
+		IssuerAliases: []string{"https://foo/", "https://legacy.example.com"},
+	})
+	for _, test := range []struct {
+		iss string
+		ok  bool
+	}{
+		{"https://foo", true},
+		{"https://foo/", true},
+		{"https://legacy.example.com", true},
+		{"http://foo", false},
+		{"", false},
+	} {
+		raw := key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"client1","exp":4102444800}`, test.iss)))
+		tok, err := verifier.Verify(context.Background(), raw)
+		if (err == nil) != test.ok {
+			t.Errorf("%q: expected success %v, got %v", test.iss, test.ok, err)
+			continue
+		}
+		if err == nil && tok.Issuer != test.iss {
+			t.Errorf("%q: expected token issuer to be kept, got %q", test.iss, tok.Issuer)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr []string
+	}{
+		{name: "valid", config: Config{ClientID: "client", SupportedSigningAlgs: []string{RS256, ES256}}},
+		{name: "skip client id", config: Config{SkipClientIDCheck: true}},
+		{name: "insecure", config: Config{ClientID: "client", InsecureSkipSignatureCheck: true}},
+		{
+			name:    "missing client id",
+			config:  Config{},
+			wantErr: []string{"ClientID must be provided"},
+		},
+		{
+			name:    "client id and skip",
+			config:  Config{ClientID: "client", SkipClientIDCheck: true},
+			wantErr: []string{"ClientID is ignored"},
+		},
+		{
+			name:    "unsupported alg",
+			config:  Config{ClientID: "client", SupportedSigningAlgs: []string{"HS256", "none", "RS1"}},
+			wantErr: []string{`"HS256" requires InsecureAllowSymmetricAlgs`, `"none" is never accepted`, `"RS1"`},
+		},
+		{
+			name:   "symmetric opt in",
+			config: Config{ClientID: "client", SupportedSigningAlgs: []string{"HS256"}, InsecureAllowSymmetricAlgs: true},
+		},
+		{
+			name:    "everything disallowed",
+			config:  Config{ClientID: "client", SupportedSigningAlgs: []string{RS256}, DisallowedAlgs: []string{RS256}},
+			wantErr: []string{"no signing algorithms are allowed"},
+		},
+		{
+			name: "insecure with algs and cache",
+			config: Config{
+				ClientID:                   "client",
+				InsecureSkipSignatureCheck: true,
+				SupportedSigningAlgs:       []string{RS256},
+				VerificationCache:          NewVerificationCache(1, 0),
+			},
+			wantErr: []string{"SupportedSigningAlgs is ignored", "VerificationCache can't be used"},
+		},
+		{
+			name:    "negative limits",
+			config:  Config{ClientID: "client", MaxTokenSize: -1, MaxClaimDepth: -1},
+			wantErr: []string{"MaxTokenSize", "MaxClaimDepth"},
+		},
+		{
+			name:    "issuer aliases",
+			config:  Config{ClientID: "client", SkipIssuerCheck: true, IssuerAliases: []string{""}},
+			wantErr: []string{"IssuerAliases is ignored", "empty issuer"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.Validate()
+			if len(test.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			for _, want := range test.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected error to contain %q, got %v", want, err)
+				}
+			}
+		})
+	}
+
+	if _, err := NewValidatedVerifier("https://foo", &StaticKeySet{}, &Config{}); err == nil {
+		t.Errorf("expected NewValidatedVerifier to fail")
+	}
+	p := &Provider{issuer: "https://foo"}
+	if _, err := p.ValidatedVerifier(&Config{SkipClientIDCheck: true}); err != nil {
+		t.Errorf("ValidatedVerifier: %v", err)
+	}
+}
+
+func TestAlgorithmPolicy(t *testing.T) {
+	key := newRSAKey(t)
+	keySet := &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}
+	payload := `{"iss":"https://foo","aud":"client1","exp":4102444800}`
+	raw := key.sign(t, []byte(payload))
+
+	verifier := NewVerifier("https://foo", keySet, &Config{ClientID: "client1", SupportedSigningAlgs: []string{RS256, ES256}})
+	if _, err := verifier.Verify(context.Background(), raw); err != nil {
+		t.Fatalf("verifying token: %v", err)
+	}
+
+	verifier = NewVerifier("https://foo", keySet, &Config{
+		ClientID:             "client1",
+		SupportedSigningAlgs: []string{RS256, ES256},
+		DisallowedAlgs:       []string{RS256},
+	})
+	if _, err := verifier.Verify(context.Background(), raw); err == nil || !strings.Contains(err.Error(), "unsupported algorithm") {
+		t.Errorf("expected disallowed algorithm to be rejected, got %v", err)
+	}
+	want := AlgorithmPolicy{Allowed: []string{ES256}, Disallowed: []string{RS256}}
+	if got := verifier.AlgorithmPolicy(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected policy, got=%+v, want=%+v", got, want)
+	}
+
+	// Unsigned and symmetric algorithms are rejected even if listed.
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte("sig"))
+	hmac := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte("sig"))
+	verifier = NewVerifier("https://foo", keySet, &Config{
+		ClientID:             "client1",
+		SupportedSigningAlgs: []string{AlgNone, HS256},
+	})
+	for _, tok := range []string{unsigned, hmac} {
+		if _, err := verifier.Verify(context.Background(), tok); err == nil || !strings.Contains(err.Error(), "unsupported algorithm") {
+			t.Errorf("expected algorithm to be rejected, got %v", err)
+		}
+	}
+	if got := verifier.AlgorithmPolicy(); len(got.Allowed) != 0 {
+		t.Errorf("expected no allowed algorithms, got %q", got.Allowed)
+	}
+
+	verifier = NewVerifier("https://foo", keySet, &Config{
+		ClientID:                   "client1",
+		SupportedSigningAlgs:       []string{AlgNone, HS256},
+		InsecureAllowSymmetricAlgs: true,
+	})
+	if got := verifier.AlgorithmPolicy(); !reflect.DeepEqual(got.Allowed, []string{HS256}) || !got.InsecureAllowSymmetricAlgs {
+		t.Errorf("unexpected policy with symmetric algorithms %+v", got)
+	}
+
+	verifier = NewVerifier("https://foo", keySet, &Config{ClientID: "client1", InsecureSkipSignatureCheck: true})
+	if got := verifier.AlgorithmPolicy(); !got.InsecureSkipSignatureCheck || got.Allowed != nil {
+		t.Errorf("unexpected policy with signature checks disabled %+v", got)
+	}
+}
+
+type testKeyResolver struct {
+	keys    map[string]crypto.PublicKey
+	headers []TokenHeader
+}
+
+func (r *testKeyResolver) ResolveKeys(ctx context.Context, header TokenHeader) ([]crypto.PublicKey, error) {
+	r.headers = append(r.headers, header)
+	key, ok := r.keys[header.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", header.KeyID)
+	}
+	return []crypto.PublicKey{key}, nil
+}
+
+func (r *testKeyResolver) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	return nil, errors.New("VerifySignature must not be called for key resolvers")
+}
+
+func TestKeyResolver(t *testing.T) {
+	key1, key2 := newRSAKey(t), newECDSAKey(t)
+	key1.keyID, key2.keyID = "key1", "key2"
+	resolver := &testKeyResolver{keys: map[string]crypto.PublicKey{"key1": key1.pub, "key2": key2.pub}}
+	verifier := NewVerifier("https://foo", resolver, &Config{
+		ClientID:             "client1",
+		SupportedSigningAlgs: []string{RS256, ES256},
+	})
+	payload := []byte(`{"iss":"https://foo","aud":"client1","exp":4102444800}`)
+
+	for _, key := range []*signingKey{key1, key2} {
+		if _, err := verifier.Verify(context.Background(), key.sign(t, payload)); err != nil {
+			t.Errorf("verifying token signed by %s: %v", key.keyID, err)
+		}
+	}
+	want := []TokenHeader{{Algorithm: RS256, KeyID: "key1"}, {Algorithm: ES256, KeyID: "key2"}}
+	if !reflect.DeepEqual(resolver.headers, want) {
+		t.Errorf("unexpected headers passed to resolver, got=%+v, want=%+v", resolver.headers, want)
+	}
+
+	// A key returned for the wrong key ID doesn't verify the token.
+	resolver.keys["key2"] = key1.pub
+	if _, err := verifier.Verify(context.Background(), key2.sign(t, payload)); err == nil {
+		t.Errorf("expected verification with the wrong key to fail")
+	}
+	key3 := newRSAKey(t)
+	key3.keyID = "key3"
+	if _, err := verifier.Verify(context.Background(), key3.sign(t, payload)); err == nil || !strings.Contains(err.Error(), `unknown key "key3"`) {
+		t.Errorf("expected resolver error, got %v", err)
+	}
+}
+
+// blockingKeySet is a KeySet that ignores its context and blocks until
+// released.
+type blockingKeySet struct {
+	release chan struct{}
+}
+
+func (b *blockingKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	<-b.release
+	return nil, errors.New("released")
+}
+
+func TestVerifyKeyLimits(t *testing.T) {
+	good := newRSAKey(t)
+	keys := []crypto.PublicKey{newRSAKey(t).pub, newRSAKey(t).pub, good.pub}
+	raw := good.sign(t, []byte(`{"iss":"https://foo","aud":"client1","exp":4102444800}`))
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		max int
+		ok  bool
+	}{{0, true}, {3, true}, {2, false}} {
+		verifier := NewVerifier("https://foo", &StaticKeySet{PublicKeys: keys}, &Config{ClientID: "client1", MaxKeyAttempts: test.max})
+		if _, err := verifier.Verify(ctx, raw); (err == nil) != test.ok {
+			t.Errorf("MaxKeyAttempts %d: expected success %v, got %v", test.max, test.ok, err)
+		}
+	}
+
+	blocking := &blockingKeySet{release: make(chan struct{})}
+	defer close(blocking.release)
+	verifier := NewVerifier("https://foo", blocking, &Config{ClientID: "client1", VerificationTimeout: 10 * time.Millisecond})
+	if _, err := verifier.Verify(ctx, raw); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected verification to time out, got %v", err)
+	}
+
+	verifier = NewVerifier("https://foo", &StaticKeySet{PublicKeys: keys}, &Config{ClientID: "client1", VerificationTimeout: time.Minute})
+	if _, err := verifier.Verify(ctx, raw); err != nil {
+		t.Errorf("expected verification within the timeout to succeed, got %v", err)
+	}
+}
+
+func TestProviderSigningAlgorithms(t *testing.T) {
+	key := newECDSAKey(t)
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":"%s/keys","id_token_signing_alg_values_supported":["ES256","HS256","none"]}`, s.URL, s.URL)
+		case "/keys":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.jwk()}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	ctx := context.Background()
+	p, err := NewProvider(ctx, s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if algs := p.SigningAlgorithms(); !reflect.DeepEqual(algs, []string{ES256}) {
+		t.Errorf("got algorithms %v, want [ES256]", algs)
+	}
+	raw := key.sign(t, []byte(fmt.Sprintf(`{"iss":%q,"aud":"app","sub":"jane","exp":4102444800}`, s.URL)))
+	if _, err := p.Verifier(&Config{ClientID: "app"}).Verify(ctx, raw); err != nil {
+		t.Errorf("verifying ES256 token with default algorithms: %v", err)
+	}
+	if _, err := p.Verifier(&Config{ClientID: "app", SupportedSigningAlgs: []string{RS256}}).Verify(ctx, raw); err == nil {
+		t.Error("expected SupportedSigningAlgs to override the provider's algorithms")
+	}
+}
+
+func TestKeySetContext(t *testing.T) {
+	key := newRSAKey(t)
+	// The provider's own key set is unreachable, so verification only
+	// succeeds with the injected key set.
+	p := (&ProviderConfig{IssuerURL: "https://foo", JWKSURL: "https://keys.example.invalid"}).NewProvider(context.Background())
+	raw := key.sign(t, []byte(`{"iss":"https://foo","aud":"app","sub":"jane","exp":4102444800}`))
+
+	ctx := KeySetContext(context.Background(), &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}})
+	if _, err := p.VerifierContext(ctx, &Config{ClientID: "app"}).Verify(ctx, raw); err != nil {
+		t.Fatalf("verifying with injected key set: %v", err)
+	}
+	other := KeySetContext(context.Background(), &StaticKeySet{PublicKeys: []crypto.PublicKey{newRSAKey(t).pub}})
+	if _, err := p.VerifierContext(other, &Config{ClientID: "app"}).Verify(ctx, raw); err == nil {
+		t.Error("expected verification with another key set to fail")
+	}
+}
+
+func TestHardenedMode(t *testing.T) {
+	key := newRSAKey(t)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	token := func(claims string) string {
+		return key.sign(t, []byte(claims))
+	}
+	typed := func(typ, claims string) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: key.alg, Key: key.priv}, (&jose.SignerOptions{}).WithType(jose.ContentType(typ)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		jws, err := signer.Sign([]byte(claims))
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := jws.CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	atHash, err := tokenHash(RS256, "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		opts     []VerifyOption
+		hardened bool // whether it fails only in hardened mode
+	}{
+		{"plain token", token(`{"iss":"https://foo","aud":"api","exp":4102444800}`), nil, false},
+		{"matching azp", token(`{"iss":"https://foo","aud":["api","other"],"azp":"api","exp":4102444800}`), nil, false},
+		{"typ JWT", typed("JWT", `{"iss":"https://foo","aud":"api","exp":4102444800}`), nil, false},
+		{"matching at_hash", token(`{"iss":"https://foo","aud":"api","exp":4102444800,"at_hash":"` + atHash + `"}`), []VerifyOption{WithAccessToken("access")}, false},
+		{"several audiences without azp", token(`{"iss":"https://foo","aud":["api","other"],"exp":4102444800}`), nil, true},
+		{"azp of another client", token(`{"iss":"https://foo","aud":["api","other"],"azp":"other","exp":4102444800}`), nil, true},
+		{"issued in the future", token(`{"iss":"https://foo","aud":"api","exp":4102444800,"iat":1800000000}`), nil, true},
+		{"access token type", typed("at+jwt", `{"iss":"https://foo","aud":"api","exp":4102444800}`), nil, true},
+		{"missing at_hash", token(`{"iss":"https://foo","aud":"api","exp":4102444800}`), []VerifyOption{WithAccessToken("access")}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, hardened := range []bool{false, true} {
+				v := NewVerifier("https://foo", &StaticKeySet{PublicKeys: []crypto.PublicKey{key.pub}}, &Config{
+					ClientID:     "api",
+					Now:          func() time.Time { return now },
+					HardenedMode: hardened,
+				})
+				_, err := v.Verify(ctx, test.token, test.opts...)
+				if wantErr := hardened && test.hardened; (err != nil) != wantErr {
+					t.Errorf("hardened=%v: got error %v, want error %v", hardened, err, wantErr)
+				}
+			}
+		})
+	}
+}
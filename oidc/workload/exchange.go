@@ -0,0 +1,235 @@
+package workload
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Token exchange parameters defined by RFC 8693.
+const (
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	TokenTypeJWT         = "urn:ietf:params:oauth:token-type:jwt"
+	TokenTypeIDToken     = "urn:ietf:params:oauth:token-type:id_token"
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// TokenExchange exchanges workload identity tokens for access tokens at an
+// RFC 8693 token exchange endpoint, such as Google's Security Token Service.
+type TokenExchange struct {
+	// Endpoint is the URL of the token exchange endpoint. Required.
+	Endpoint string
+	// Audience and Resource identify the service the token is requested for.
+	Audience string
+	Resource string
+	// Scope requested for the issued token.
+	Scope []string
+	// SubjectTokenType defaults to TokenTypeJWT.
+	SubjectTokenType string
+	// RequestedTokenType defaults to TokenTypeAccessToken.
+	RequestedTokenType string
+
+	// ClientID and ClientSecret, if set, authenticate the request with HTTP
+	// basic authentication.
+	ClientID     string
+	ClientSecret string
+
+	// HTTPClient is used for requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Exchange reads a token from the source and exchanges it. Errors returned by
+// the endpoint are of type *oidc.OAuthError.
+func (e *TokenExchange) Exchange(ctx context.Context, src TokenSource) (*oauth2.Token, error) {
+	if e.Endpoint == "" {
+		return nil, errors.New("workload: token exchange endpoint is required")
+	}
+	subject, err := src.IdentityToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v := url.Values{
+		"grant_type":           {GrantTypeTokenExchange},
+		"subject_token":        {subject},
+		"subject_token_type":   {or(e.SubjectTokenType, TokenTypeJWT)},
+		"requested_token_type": {or(e.RequestedTokenType, TokenTypeAccessToken)},
+	}
+	if e.Audience != "" {
+		v.Set("audience", e.Audience)
+	}
+	if e.Resource != "" {
+		v.Set("resource", e.Resource)
+	}
+	if len(e.Scope) > 0 {
+		v.Set("scope", strings.Join(e.Scope, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if e.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(e.ClientID), url.QueryEscape(e.ClientSecret))
+	}
+	body, status, err := do(e.HTTPClient, req)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, oauthError(status, body)
+	}
+	var resp struct {
+		AccessToken     string `json:"access_token"`
+		IssuedTokenType string `json:"issued_token_type"`
+		TokenType       string `json:"token_type"`
+		ExpiresIn       int64  `json:"expires_in"`
+		RefreshToken    string `json:"refresh_token"`
+		Scope           string `json:"scope"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("workload: decoding token exchange response: %v", err)
+	}
+	if resp.AccessToken == "" {
+		return nil, errors.New("workload: token exchange response has no access_token")
+	}
+	token := &oauth2.Token{
+		AccessToken:  resp.AccessToken,
+		TokenType:    resp.TokenType,
+		RefreshToken: resp.RefreshToken,
+	}
+	if resp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return token.WithExtra(map[string]interface{}{
+		"issued_token_type": resp.IssuedTokenType,
+		"scope":             resp.Scope,
+	}), nil
+}
+
+// TokenSource returns an oauth2.TokenSource that exchanges a fresh workload
+// token whenever the previous access token expires.
+func (e *TokenExchange) TokenSource(ctx context.Context, src TokenSource) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &exchangeTokenSource{ctx: ctx, exchange: e, src: src})
+}
+
+type exchangeTokenSource struct {
+	ctx      context.Context
+	exchange *TokenExchange
+	src      TokenSource
+}
+
+func (s *exchangeTokenSource) Token() (*oauth2.Token, error) {
+	return s.exchange.Exchange(s.ctx, s.src)
+}
+
+func or(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// AWSSTSEndpoint is the global endpoint of the AWS Security Token Service.
+const AWSSTSEndpoint = "https://sts.amazonaws.com/"
+
+// AWSCredentials are temporary AWS credentials.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// AWSRoleExchange assumes an AWS IAM role with a workload identity token
+// through the STS AssumeRoleWithWebIdentity action, which doesn't require
+// request signing.
+type AWSRoleExchange struct {
+	// RoleARN is the role to assume. Defaults to the AWS_ROLE_ARN environment
+	// variable, which IRSA sets alongside the token file.
+	RoleARN string
+	// SessionName identifies the session in CloudTrail. Required.
+	SessionName string
+	// Duration of the session. Uses the role's default if zero.
+	Duration time.Duration
+	// Endpoint overrides AWSSTSEndpoint, for example to use a regional
+	// endpoint.
+	Endpoint string
+
+	// HTTPClient is used for requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Exchange reads a token from the source and exchanges it for credentials.
+func (a *AWSRoleExchange) Exchange(ctx context.Context, src TokenSource) (*AWSCredentials, error) {
+	roleARN := or(a.RoleARN, os.Getenv(AWSRoleARNEnv))
+	if roleARN == "" || a.SessionName == "" {
+		return nil, errors.New("workload: role arn and session name are required")
+	}
+	subject, err := src.IdentityToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {a.SessionName},
+		"WebIdentityToken": {subject},
+	}
+	if a.Duration > 0 {
+		v.Set("DurationSeconds", fmt.Sprint(int64(a.Duration/time.Second)))
+	}
+	endpoint := or(a.Endpoint, AWSSTSEndpoint)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, status, err := do(a.HTTPClient, req)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		var e struct {
+			Error struct {
+				Code    string `xml:"Code"`
+				Message string `xml:"Message"`
+			} `xml:"Error"`
+		}
+		if xml.Unmarshal(body, &e) == nil && e.Error.Code != "" {
+			return nil, fmt.Errorf("workload: sts returned %d %s: %s", status, e.Error.Code, e.Error.Message)
+		}
+		return nil, fmt.Errorf("workload: sts returned %d: %s", status, body)
+	}
+	var resp struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("workload: decoding sts response: %v", err)
+	}
+	if resp.Credentials.AccessKeyID == "" {
+		return nil, errors.New("workload: sts response has no credentials")
+	}
+	return &AWSCredentials{
+		AccessKeyID:     resp.Credentials.AccessKeyID,
+		SecretAccessKey: resp.Credentials.SecretAccessKey,
+		SessionToken:    resp.Credentials.SessionToken,
+		Expiration:      resp.Credentials.Expiration,
+	}, nil
+}
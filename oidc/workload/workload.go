@@ -0,0 +1,158 @@
+// Package workload reads, verifies and exchanges workload identity tokens, the
+// OpenID Connect tokens platforms issue to workloads such as Kubernetes pods
+// (including AWS IRSA) and Google Cloud instances.
+//
+// A typical federation flow reads the platform's token, optionally verifies it
+// locally, and exchanges it for credentials of another system:
+//
+//	src := workload.FileTokenSource(os.Getenv(workload.AWSWebIdentityTokenFileEnv))
+//	exchange := &workload.TokenExchange{
+//		Endpoint: "https://sts.googleapis.com/v1/token",
+//		Audience: "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/aws",
+//		Scope:    []string{"https://www.googleapis.com/auth/cloud-platform"},
+//	}
+//	token, err := exchange.Exchange(ctx, src)
+package workload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// Well known locations of workload identity tokens.
+const (
+	// AWSWebIdentityTokenFileEnv names the environment variable holding the
+	// path of the token projected into pods by IAM Roles for Service Accounts.
+	AWSWebIdentityTokenFileEnv = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	// AWSRoleARNEnv names the environment variable holding the role to assume.
+	AWSRoleARNEnv = "AWS_ROLE_ARN"
+
+	// GCPMetadataIdentityURL is the metadata server endpoint returning ID
+	// Tokens for the instance's service account.
+	GCPMetadataIdentityURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/identity"
+)
+
+// maxResponseSize bounds responses read from metadata and token endpoints.
+const maxResponseSize = 1 << 20
+
+// TokenSource returns a workload identity token, an OpenID Connect JWT issued
+// to the workload by its platform.
+type TokenSource interface {
+	IdentityToken(ctx context.Context) (string, error)
+}
+
+// FileTokenSource reads the token from a file, such as a projected service
+// account token. The file is read on every call since platforms rotate tokens
+// in place.
+type FileTokenSource string
+
+// IdentityToken reads the token from the file.
+func (f FileTokenSource) IdentityToken(ctx context.Context) (string, error) {
+	if f == "" {
+		return "", errors.New("workload: token file path is empty")
+	}
+	b, err := os.ReadFile(string(f))
+	if err != nil {
+		return "", fmt.Errorf("workload: reading token: %v", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("workload: token file %s is empty", f)
+	}
+	return token, nil
+}
+
+// AWSTokenSource returns a source for the IRSA token of the pod, located through
+// the AWS_WEB_IDENTITY_TOKEN_FILE environment variable.
+func AWSTokenSource() (TokenSource, error) {
+	path := os.Getenv(AWSWebIdentityTokenFileEnv)
+	if path == "" {
+		return nil, fmt.Errorf("workload: %s is not set", AWSWebIdentityTokenFileEnv)
+	}
+	return FileTokenSource(path), nil
+}
+
+// GCPMetadataTokenSource requests ID Tokens for the instance's service account
+// from the Google Cloud metadata server.
+type GCPMetadataTokenSource struct {
+	// Audience of the requested token. Required.
+	Audience string
+	// URL overrides GCPMetadataIdentityURL.
+	URL string
+	// HTTPClient is used for requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// IdentityToken requests a token from the metadata server. Tokens use the
+// "full" format, which includes the instance's project and zone.
+func (g *GCPMetadataTokenSource) IdentityToken(ctx context.Context) (string, error) {
+	if g.Audience == "" {
+		return "", errors.New("workload: audience is required")
+	}
+	u := g.URL
+	if u == "" {
+		u = GCPMetadataIdentityURL
+	}
+	u += "?" + url.Values{"audience": {g.Audience}, "format": {"full"}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	body, status, err := do(g.HTTPClient, req)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("workload: metadata server returned %d: %s", status, body)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+func do(client *http.Client, req *http.Request) ([]byte, int, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("workload: request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, 0, fmt.Errorf("workload: reading response: %v", err)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// Verify reads a token from the source and verifies it. This is useful to fail
+// early on misconfigured audiences before exchanging the token.
+func Verify(ctx context.Context, src TokenSource, verifier *oidc.IDTokenVerifier) (*oidc.IDToken, error) {
+	raw, err := src.IdentityToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return verifier.Verify(ctx, raw)
+}
+
+// oauthError decodes an RFC 6749 error response.
+func oauthError(status int, body []byte) error {
+	var e struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		ErrorURI         string `json:"error_uri"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Error != "" {
+		return &oidc.OAuthError{StatusCode: status, Code: e.Error, Description: e.ErrorDescription, URI: e.ErrorURI, Body: body}
+	}
+	return fmt.Errorf("workload: token endpoint returned %d: %s", status, body)
+}
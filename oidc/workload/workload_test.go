@@ -0,0 +1,164 @@
+package workload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+type staticTokenSource string
+
+func (s staticTokenSource) IdentityToken(ctx context.Context) (string, error) {
+	return string(s), nil
+}
+
+func TestFileTokenSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("token1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(AWSWebIdentityTokenFileEnv, path)
+	src, err := AWSTokenSource()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := src.IdentityToken(context.Background()); err != nil || got != "token1" {
+		t.Fatalf("unexpected token %q, err=%v", got, err)
+	}
+	if err := os.WriteFile(path, []byte("token2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := src.IdentityToken(context.Background()); err != nil || got != "token2" {
+		t.Fatalf("expected rotated token, got %q, err=%v", got, err)
+	}
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.IdentityToken(context.Background()); err == nil {
+		t.Errorf("expected error for empty token file")
+	}
+
+	t.Setenv(AWSWebIdentityTokenFileEnv, "")
+	if _, err := AWSTokenSource(); err == nil {
+		t.Errorf("expected error when environment variable isn't set")
+	}
+}
+
+func TestGCPMetadataTokenSource(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, "token-for-%s-%s", r.URL.Query().Get("audience"), r.URL.Query().Get("format"))
+	}))
+	defer s.Close()
+
+	src := &GCPMetadataTokenSource{Audience: "my-service", URL: s.URL}
+	got, err := src.IdentityToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "token-for-my-service-full"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if _, err := (&GCPMetadataTokenSource{URL: s.URL}).IdentityToken(context.Background()); err == nil {
+		t.Errorf("expected audience to be required")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	verifier := oidc.NewVerifier("https://op", &oidc.StaticKeySet{}, &oidc.Config{ClientID: "client"})
+	if _, err := Verify(context.Background(), staticTokenSource("not-a-jwt"), verifier); err == nil {
+		t.Errorf("expected malformed token to fail verification")
+	}
+}
+
+func TestTokenExchange(t *testing.T) {
+	var requests int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("subject_token") != "workload-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant","error_description":"bad subject"}`)
+			return
+		}
+		for k, want := range map[string]string{
+			"grant_type":           GrantTypeTokenExchange,
+			"subject_token_type":   TokenTypeJWT,
+			"requested_token_type": TokenTypeAccessToken,
+			"audience":             "//iam.example.com/pool",
+			"scope":                "a b",
+		} {
+			if got := r.PostForm.Get(k); got != want {
+				t.Errorf("expected %s=%q, got %q", k, want, got)
+			}
+		}
+		fmt.Fprint(w, `{"access_token":"at","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer s.Close()
+
+	exchange := &TokenExchange{Endpoint: s.URL, Audience: "//iam.example.com/pool", Scope: []string{"a", "b"}}
+	ts := exchange.TokenSource(context.Background(), staticTokenSource("workload-token"))
+	for i := 0; i < 2; i++ {
+		token, err := ts.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.AccessToken != "at" || token.Expiry.Before(time.Now().Add(time.Hour-time.Minute)) {
+			t.Errorf("unexpected token %+v", token)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected token to be reused, got %d requests", requests)
+	}
+
+	_, err := exchange.Exchange(context.Background(), staticTokenSource("other"))
+	var oauthErr *oidc.OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_grant" {
+		t.Errorf("expected invalid_grant error, got %v", err)
+	}
+}
+
+func TestAWSRoleExchange(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.PostForm.Get("Action") != "AssumeRoleWithWebIdentity" || r.PostForm.Get("WebIdentityToken") != "workload-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `<ErrorResponse><Error><Code>InvalidIdentityToken</Code><Message>bad token</Message></Error></ErrorResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>
+			<AccessKeyId>AKID</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>
+			<SessionToken>session</SessionToken><Expiration>2030-01-01T00:00:00Z</Expiration>
+		</Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`)
+	}))
+	defer s.Close()
+
+	t.Setenv(AWSRoleARNEnv, "arn:aws:iam::123456789012:role/test")
+	exchange := &AWSRoleExchange{SessionName: "test", Endpoint: s.URL}
+	creds, err := exchange.Exchange(context.Background(), staticTokenSource("workload-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "AKID" || creds.SessionToken != "session" || creds.Expiration.Year() != 2030 {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+	if _, err := exchange.Exchange(context.Background(), staticTokenSource("other")); err == nil {
+		t.Errorf("expected sts error")
+	}
+}